@@ -0,0 +1,72 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+func NewAcknowledgeCmd(config *cmdutil.Config) *cobra.Command {
+	return newIncidentActionCmd(config, "acknowledge", "Queue up a v2 acknowledge event to PagerDuty")
+}
+
+// newIncidentActionCmd returns a command sending a v2 event acting on an
+// existing incident, e.g. resolving it, needing only its routing and dedup
+// keys. Events are sent as with `send --event-action`.
+func newIncidentActionCmd(config *cmdutil.Config, action, short string) *cobra.Command {
+	input := sendV2Input{eventAction: action}
+	var keyName string
+
+	cmd := &cobra.Command{
+		Use:   action,
+		Short: short,
+		Long: fmt.Sprintf(`%v.
+
+		Equivalent to "send --event-action %v", requiring only "routing-key",
+		or "key-name" to select a key configured under "keys", and the
+		"dedup-key" of the incident.`, short, action),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			input.client, input.clientURL, err = cmdutil.ResolveClient(input.client, input.clientURL)
+			if err != nil {
+				return err
+			}
+
+			input.routingKey, err = cmdutil.ResolveNamedKey(input.routingKey, keyName)
+			if err != nil {
+				return err
+			}
+			if err := validateSendV2Input(input); err != nil {
+				return err
+			}
+			return cmdutil.RunSendCommand(cmd.Context(), config, buildSendV2Event(input), nil)
+		},
+	}
+
+	cmd.Flags().StringVar(&input.routingKey, "routing-key", "", "Service Events API Key")
+	cmd.Flags().StringVar(&input.dedupKey, "dedup-key", "", "Deduplication key of the incident to "+action)
+	cmd.Flags().StringVar(&keyName, "key-name", "", "Name of a key configured under keys, used if no routing-key is given")
+	cmd.Flags().StringVarP(&input.client, "client", "c", "", `The client shown on the incident, instead of the client config value or "PagerDuty Agent on <hostname>"`)
+	cmd.Flags().StringVarP(&input.clientURL, "client-url", "u", "", "A URL linking the incident back to the client, instead of the client-url config value")
+
+	cmdutil.MarkSendCommand(cmd)
+
+	return cmd
+}
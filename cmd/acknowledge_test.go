@@ -0,0 +1,140 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+var incidentActionCmds = map[string]func(*cmdutil.Config) *cobra.Command{
+	"acknowledge": NewAcknowledgeCmd,
+	"resolve":     NewResolveCmd,
+}
+
+func TestIncidentAction_errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError error
+	}{
+		{
+			name:          "missingDedupKey",
+			args:          []string{"--routing-key", "abc"},
+			expectedError: errSendDedupKey,
+		},
+		{
+			name:          "missingRoutingKey",
+			args:          []string{"--dedup-key", "xyz"},
+			expectedError: errSendRoutingKey,
+		},
+		{
+			name:          "unknownKeyName",
+			args:          []string{"--key-name", "unknown", "--dedup-key", "xyz"},
+			expectedError: errors.New("unknown key name unknown, no keys are configured"),
+		},
+	}
+
+	for action, newCmd := range incidentActionCmds {
+		for _, tt := range tests {
+			t.Run(action+"/"+tt.name, func(t *testing.T) {
+				cmd := newCmd(cmdutil.NewConfig())
+				cmd.SetArgs(tt.args)
+				cmd.SilenceUsage = true
+
+				_, err := cmd.ExecuteC()
+
+				assert.Equal(t, tt.expectedError, err)
+				assert.Equal(t, cmdutil.ExitValidation, cmdutil.ExitCode(cmd, err))
+			})
+		}
+	}
+}
+
+func TestIncidentAction_validInputs(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	tests := []struct {
+		name       string
+		args       []string
+		routingKey string
+	}{
+		{"routingKey", []string{"--routing-key", "def", "--dedup-key", "xyz"}, "def"},
+		{"keyName", []string{"--key-name", "db", "--dedup-key", "xyz"}, "abc"},
+	}
+
+	viper.Set("keys", map[string]string{"db": "abc"})
+	defer viper.Set("keys", nil)
+
+	for action, newCmd := range incidentActionCmds {
+		for _, tt := range tests {
+			t.Run(action+"/"+tt.name, func(t *testing.T) {
+				defer gock.Off()
+
+				defaultHTTPClient := &http.Client{
+					Timeout: 5 * time.Minute,
+				}
+
+				realConfig := cmdutil.NewConfig()
+				realConfig.HttpClient = func() (*http.Client, error) {
+					return defaultHTTPClient, nil
+				}
+
+				cmd := newCmd(realConfig)
+				cmd.SetArgs(tt.args)
+
+				gock.New(cmdutil.GetDefaults().Address).
+					Post("/send").
+					MatchHeader("Pd-Event-Version", "v2").
+					JSON(map[string]interface{}{
+						"routing_key":  tt.routingKey,
+						"client":       cmdutil.DefaultClient(),
+						"event_action": action,
+						"dedup_key":    "xyz",
+						"payload": map[string]interface{}{
+							"summary":  "",
+							"source":   "",
+							"severity": "",
+						},
+					}).
+					Reply(200).
+					JSON(map[string]interface{}{"key": "xyz"})
+
+				gock.InterceptClient(defaultHTTPClient)
+
+				out, err := test.CaptureStdout(func() error {
+					_, err := cmd.ExecuteC()
+					return err
+				})
+
+				if err != nil {
+					t.Errorf("error running command `%v`: %v", action, err)
+				}
+
+				assert.True(t, gock.IsDone())
+				assert.Contains(t, out, `{"key":"xyz"}`)
+			})
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+const defaultStuckThreshold = 15 * time.Minute
+
+func NewStatusCmd(config *cmdutil.Config) *cobra.Command {
+	var jsonOutput bool
+	var stuckThreshold time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print agent health information.",
+		Long: `Print agent health information, including queue depth and recent send results.
+
+Exits non-zero if the agent is unreachable or the oldest pending event is older
+than the stuck threshold.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAgentStatusCommand(config, jsonOutput, stuckThreshold)
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the raw JSON status")
+	cmd.Flags().DurationVar(&stuckThreshold, "stuck-threshold", defaultStuckThreshold, "Age of the oldest pending event after which the queue is considered stuck")
+
+	return cmd
+}
+
+func runAgentStatusCommand(config *cmdutil.Config, jsonOutput bool, stuckThreshold time.Duration) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.AgentStatus()
+	if err != nil {
+		fmt.Printf("Agent unreachable: %v\n", err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != 200 {
+		fmt.Println(string(respBody))
+		os.Exit(1)
+	}
+
+	var status server.AgentStatusResponse
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		fmt.Println(string(respBody))
+	} else {
+		fmt.Print(formatAgentStatus(status))
+	}
+
+	if err := checkAgentStatus(status, stuckThreshold); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+// formatAgentStatus returns a human-readable summary of the agent's status.
+func formatAgentStatus(status server.AgentStatusResponse) string {
+	oldestPending := "none"
+	if status.QueueDepth > 0 {
+		oldestPending = fmt.Sprintf("%v ago", secondsToDuration(status.OldestPendingAgeSeconds))
+	}
+
+	lastSuccess := "never"
+	if status.LastSuccessAt != nil {
+		lastSuccess = status.LastSuccessAt.Format(time.RFC3339)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Version:              %v\n", status.Version)
+	fmt.Fprintf(&b, "Queue depth:          %v\n", status.QueueDepth)
+	fmt.Fprintf(&b, "Oldest pending:       %v\n", oldestPending)
+	fmt.Fprintf(&b, "Consecutive failures: %v\n", status.ConsecutiveFailures)
+	fmt.Fprintf(&b, "Last successful send: %v\n", lastSuccess)
+	if status.Maintenance {
+		fmt.Fprintf(&b, "Maintenance mode:     on\n")
+	}
+	if status.CircuitBreaker != "" {
+		fmt.Fprintf(&b, "Circuit breaker:      %v\n", status.CircuitBreaker)
+	}
+	return b.String()
+}
+
+// checkAgentStatus returns an error if the queue appears to be stuck.
+func checkAgentStatus(status server.AgentStatusResponse, stuckThreshold time.Duration) error {
+	age := secondsToDuration(status.OldestPendingAgeSeconds)
+	if status.QueueDepth > 0 && age > stuckThreshold {
+		return fmt.Errorf("queue appears stuck, oldest pending event is %v old (threshold %v)", age, stuckThreshold)
+	}
+	return nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestStatus_json(t *testing.T) {
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewStatusCmd(realConfig)
+	cmd.SetArgs([]string{"--json"})
+
+	body := `{"version":"0.1.0","queue_depth":2,"oldest_pending_age_seconds":30,"consecutive_failures":0}`
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Get("/status").
+		Reply(200).
+		BodyString(body)
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `status`: %v", err)
+	}
+
+	assert.Equal(t, body+"\n", out)
+}
+
+func TestStatus_format(t *testing.T) {
+	lastSuccess := time.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		status   server.AgentStatusResponse
+		expected string
+	}{
+		{
+			name:   "idle",
+			status: server.AgentStatusResponse{Version: "0.1.0"},
+			expected: "Version:              0.1.0\n" +
+				"Queue depth:          0\n" +
+				"Oldest pending:       none\n" +
+				"Consecutive failures: 0\n" +
+				"Last successful send: never\n",
+		},
+		{
+			name: "pending",
+			status: server.AgentStatusResponse{
+				Version:                 "0.1.0",
+				QueueDepth:              3,
+				OldestPendingAgeSeconds: 90.4,
+				ConsecutiveFailures:     2,
+				LastSuccessAt:           &lastSuccess,
+			},
+			expected: "Version:              0.1.0\n" +
+				"Queue depth:          3\n" +
+				"Oldest pending:       1m30s ago\n" +
+				"Consecutive failures: 2\n" +
+				"Last successful send: 2020-06-01T12:30:00Z\n",
+		},
+		{
+			name: "breaker open",
+			status: server.AgentStatusResponse{
+				Version:                 "0.1.0",
+				QueueDepth:              1,
+				OldestPendingAgeSeconds: 30,
+				ConsecutiveFailures:     5,
+				CircuitBreaker:          "open",
+			},
+			expected: "Version:              0.1.0\n" +
+				"Queue depth:          1\n" +
+				"Oldest pending:       30s ago\n" +
+				"Consecutive failures: 5\n" +
+				"Last successful send: never\n" +
+				"Circuit breaker:      open\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, formatAgentStatus(tt.status))
+		})
+	}
+}
+
+func TestStatus_stuck(t *testing.T) {
+	healthy := server.AgentStatusResponse{QueueDepth: 1, OldestPendingAgeSeconds: 60}
+	assert.NoError(t, checkAgentStatus(healthy, 5*time.Minute))
+
+	stuck := server.AgentStatusResponse{QueueDepth: 1, OldestPendingAgeSeconds: 600}
+	assert.EqualError(t, checkAgentStatus(stuck, 5*time.Minute), "queue appears stuck, oldest pending event is 10m0s old (threshold 5m0s)")
+}
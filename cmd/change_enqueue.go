@@ -0,0 +1,88 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/cobra"
+)
+
+var errLinkText = errors.New("link-text may not be given more times than link")
+
+func NewChangeEnqueueCmd(config *cmdutil.Config) *cobra.Command {
+	var customDetails map[string]string
+	var links []string
+	var linkTexts []string
+	var keyName string
+
+	var sendEvent = eventsapi.ChangeEventV2{
+		Payload: eventsapi.ChangePayloadV2{},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "enqueue",
+		Short: "Queue up a v2 change event to PagerDuty",
+		Long: `Queue up a v2 change event to PagerDuty.
+
+Change events, e.g. deploys or config changes, appear on service timelines
+without creating incidents.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			sendEvent.RoutingKey, err = cmdutil.ResolveNamedKey(sendEvent.RoutingKey, keyName)
+			if err != nil {
+				return err
+			}
+
+			if sendEvent.Payload.Timestamp == "" {
+				sendEvent.Payload.Timestamp = time.Now().UTC().Format(time.RFC3339)
+			} else if _, err := time.Parse(time.RFC3339, sendEvent.Payload.Timestamp); err != nil {
+				return eventsapi.ErrInvalidTimestamp
+			}
+
+			if len(linkTexts) > len(links) {
+				return errLinkText
+			}
+			for i, href := range links {
+				link := eventsapi.LinkV2{Href: href}
+				if i < len(linkTexts) {
+					link.Text = linkTexts[i]
+				}
+				sendEvent.Links = append(sendEvent.Links, link)
+			}
+
+			return cmdutil.RunSendCommand(cmd.Context(), config, &sendEvent, customDetails)
+		},
+	}
+
+	cmd.Flags().StringVarP(&sendEvent.RoutingKey, "routing-key", "k", "", "Service Events API Key")
+	cmd.Flags().StringVar(&keyName, "key-name", "", "Name of a key configured under keys or severity-routes, used if no routing-key is given")
+	cmd.Flags().StringVarP(&sendEvent.Payload.Summary, "summary", "d", "", "A brief text summary of the change")
+	cmd.Flags().StringVarP(&sendEvent.Payload.Source, "source", "u", "", "The unique location of the changed system")
+	cmd.Flags().StringVar(&sendEvent.Payload.Timestamp, "timestamp", "", "When the change occurred in RFC3339 format (default now)")
+	cmd.Flags().StringArrayVar(&links, "link", []string{}, "Add a link to the change, e.g. a build or pull request URL")
+	cmd.Flags().StringArrayVar(&linkTexts, "link-text", []string{}, "Text for the correspondingly ordered --link")
+	cmd.Flags().StringToStringVarP(&customDetails, "field", "f", map[string]string{}, "Add given KEY=VALUE pair to the event details")
+
+	cmd.MarkFlagRequired("summary")
+
+	cmdutil.MarkSendCommand(cmd)
+
+	return cmd
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestChangeEnqueue_validInput(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	const RoutingKey = "abc"
+	const Summary = "Deployed v1.2.3"
+	const Timestamp = "2020-07-17T08:42:58Z"
+
+	cmd := NewChangeEnqueueCmd(realConfig)
+	cmd.SetArgs([]string{
+		"-k", RoutingKey,
+		"-d", Summary,
+		"--timestamp", Timestamp,
+		"--link", "https://example.com/builds/1",
+		"--link-text", "Build",
+	})
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		MatchHeader("Pd-Event-Version", "change.v2").
+		JSON(map[string]interface{}{
+			"routing_key": RoutingKey,
+			"payload": map[string]string{
+				"summary":   Summary,
+				"timestamp": Timestamp,
+			},
+			"links": []map[string]string{
+				{"href": "https://example.com/builds/1", "text": "Build"},
+			},
+		}).
+		Reply(200).
+		JSON(map[string]interface{}{"key": "xyz"})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `change enqueue`: %v", err)
+	}
+
+	assert.Contains(t, out, `{"key":"xyz"}`)
+}
+
+func TestChangeEnqueue_invalidTimestamp(t *testing.T) {
+	cmd := NewChangeEnqueueCmd(cmdutil.NewConfig())
+	cmd.SetArgs([]string{
+		"-k", "abc",
+		"-d", "Deployed v1.2.3",
+		"--timestamp", "yesterday",
+	})
+	cmd.SilenceUsage = true
+
+	_, err := cmd.ExecuteC()
+
+	assert.Equal(t, eventsapi.ErrInvalidTimestamp, err)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+func NewDedupReportCmd(config *cmdutil.Config) *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Report duplicate events suppressed within the dedup window.",
+		Long: `Report duplicate events suppressed within the server's --dedup-window: totals
+by routing key since the daemon started, and the most recent suppressions with
+the key of the event each duplicated.
+
+Routing keys are masked. No suppressions while alerts are expected may mean
+the dedup window is disabled rather than that nothing was dropped.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDedupReportCommand(config, jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the raw JSON report")
+
+	return cmd
+}
+
+func runDedupReportCommand(config *cmdutil.Config, jsonOutput bool) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.DedupReport()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if jsonOutput || resp.StatusCode != 200 {
+		fmt.Println(string(respBody))
+		return nil
+	}
+
+	var report server.DedupReportResponse
+	if err := json.Unmarshal(respBody, &report); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Print(formatDedupReport(report))
+	return nil
+}
+
+// formatDedupReport returns suppression totals and recent suppressions as
+// human-readable tables.
+func formatDedupReport(report server.DedupReportResponse) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	routingKeys := make([]string, 0, len(report.Suppressed))
+	for rk := range report.Suppressed {
+		routingKeys = append(routingKeys, rk)
+	}
+	sort.Strings(routingKeys)
+
+	fmt.Fprintln(w, "ROUTING KEY\tSUPPRESSED")
+	for _, rk := range routingKeys {
+		fmt.Fprintf(w, "%v\t%v\n", rk, report.Suppressed[rk])
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "TIME\tROUTING KEY\tDUPLICATE OF")
+	for _, s := range report.Suppressions {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", s.Time.Format(time.RFC3339), s.RoutingKey, s.EventKey)
+	}
+
+	w.Flush()
+	return buf.String()
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestDedupReport(t *testing.T) {
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewDedupReportCmd(realConfig)
+	cmd.SetArgs([]string{})
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Get("/dedup").
+		Reply(200).
+		BodyString(`{"suppressed":{"11****de":2},"suppressions":[{"time":"2020-06-01T12:30:00Z","event_key":"xyz","routing_key":"11****de"}]}`)
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Contains(t, out, "11****de     2")
+	assert.Contains(t, out, "2020-06-01T12:30:00Z  11****de     xyz")
+}
+
+func TestDedupReport_format(t *testing.T) {
+	report := server.DedupReportResponse{
+		Suppressed: map[string]int{"ef****gh": 1, "ab****cd": 12},
+		Suppressions: []persistentqueue.Suppression{
+			{Time: time.Date(2020, 6, 1, 12, 31, 0, 0, time.UTC), EventKey: "key2", RoutingKey: "ef****gh"},
+			{Time: time.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC), EventKey: "key1", RoutingKey: "ab****cd"},
+		},
+	}
+
+	expected := "ROUTING KEY  SUPPRESSED\n" +
+		"ab****cd     12\n" +
+		"ef****gh     1\n" +
+		"\n" +
+		"TIME                  ROUTING KEY  DUPLICATE OF\n" +
+		"2020-06-01T12:31:00Z  ef****gh     key2\n" +
+		"2020-06-01T12:30:00Z  ab****cd     key1\n"
+
+	assert.Equal(t, expected, formatDedupReport(report))
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+func NewDrainCmd(config *cmdutil.Config) *cobra.Command {
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "drain",
+		Short: "Send the daemon's backlog, then stop it.",
+		Long: `Put the daemon into drain mode, e.g. before decommissioning its host.
+
+While draining, new events are refused with a 503, and everything queued is
+sent immediately, including retries and auto-resolves not yet due. Once the
+queue is empty the daemon exits 0. Should events remain after the timeout the
+daemon exits non-zero, leaving them in its database for the next start.
+
+Returns as soon as draining starts; the daemon's log records the outcome. To
+drain without starting the daemon as usual, run ` + "`pdagent server --drain`" + `.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDrainCommand(config, timeout)
+		},
+	}
+
+	cmd.Flags().DurationVar(&timeout, "drain-timeout", cmdutil.GetDefaults().DrainTimeout, "How long to drain for before giving up")
+
+	return cmd
+}
+
+func runDrainCommand(config *cmdutil.Config, timeout time.Duration) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.Drain(timeout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	return nil
+}
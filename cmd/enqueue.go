@@ -16,13 +16,29 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+// batchExcludedFlags describe a single event, so can't be used with batch.
+var batchExcludedFlags = []string{"event-action", "dedup-key", "summary", "source", "severity", "component", "group", "class", "field", "auto-resolve-after", "priority"}
+
+var errStrictWithoutBatch = errors.New("strict may only be used with batch")
+
 func NewEnqueueCmd(config *cmdutil.Config) *cobra.Command {
 	var customDetails map[string]string
+	var keyName string
+	var batch, strict bool
+	var autoResolveAfter time.Duration
+	var priority string
 
 	var sendEvent = eventsapi.EventV2{
 		Payload: eventsapi.PayloadV2{},
@@ -31,12 +47,53 @@ func NewEnqueueCmd(config *cmdutil.Config) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "enqueue",
 		Short: "Queue up a trigger, acknowledge, or resolve v2 event to PagerDuty",
+		Long: `Queue up a trigger, acknowledge, or resolve v2 event to PagerDuty.
+
+		With "batch", v2 events are instead read from stdin as newline-delimited
+		JSON, one event per line, each reported as it's enqueued. Events without
+		a routing key use "routing-key" or "key-name". Invalid lines are reported
+		and skipped unless "strict" is given, in which case nothing is enqueued.
+		Exits non-zero if any event wasn't enqueued.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return cmdutil.RunSendCommand(config, &sendEvent, customDetails)
+			if batch {
+				if flag := changedFlag(cmd.Flags(), batchExcludedFlags); flag != "" {
+					return fmt.Errorf("%v may not be combined with batch, set it on each event instead", flag)
+				}
+				out := cmd.OutOrStdout()
+				if viper.GetBool("quiet") {
+					out = ioutil.Discard
+				}
+				// Resolving a literal key once, rather than for each line.
+				routingKey, err := cmdutil.ResolveKey(sendEvent.RoutingKey)
+				if err != nil {
+					return err
+				}
+				keyFor := func(severity string) (string, error) {
+					return cmdutil.ResolveSeverityKey(routingKey, keyName, severity)
+				}
+				return runEnqueueBatch(cmd.Context(), config, cmd.InOrStdin(), out, cmd.ErrOrStderr(), keyFor, strict)
+			}
+			if strict {
+				return errStrictWithoutBatch
+			}
+
+			var err error
+			sendEvent.RoutingKey, err = cmdutil.ResolveSeverityKey(sendEvent.RoutingKey, keyName, sendEvent.Payload.Severity)
+			if err != nil {
+				return err
+			}
+
+			eventPriority, err := eventsapi.ParsePriority(priority)
+			if err != nil {
+				return err
+			}
+
+			return cmdutil.RunSendCommand(cmd.Context(), config, &sendEvent, customDetails, client.WithAutoResolveAfter(autoResolveAfter), client.WithPriority(eventPriority))
 		},
 	}
 
 	cmd.Flags().StringVarP(&sendEvent.RoutingKey, "routing-key", "k", "", "Service Events API Key")
+	cmd.Flags().StringVar(&keyName, "key-name", "", "Name of a key configured under keys or severity-routes, used if no routing-key is given")
 	cmd.Flags().StringVarP(&sendEvent.EventAction, "event-action", "t", "", "The type of event")
 	cmd.Flags().StringVarP(&sendEvent.DedupKey, "dedup-key", "y", "", "Deduplication key for correlating triggers and resolves")
 	cmd.Flags().StringVarP(&sendEvent.Payload.Summary, "summary", "d", "", "A brief text summary of the event")
@@ -46,6 +103,12 @@ func NewEnqueueCmd(config *cmdutil.Config) *cobra.Command {
 	cmd.Flags().StringVarP(&sendEvent.Payload.Group, "group", "g", "", "Logical grouping of components of a service")
 	cmd.Flags().StringVar(&sendEvent.Payload.Class, "class", "", "The class/type of the event")
 	cmd.Flags().StringToStringVarP(&customDetails, "field", "f", map[string]string{}, "Add given KEY=VALUE pair to the event details")
+	cmd.Flags().DurationVar(&autoResolveAfter, "auto-resolve-after", 0, "Resolve a trigger this long after it's delivered, e.g. 1h for point-in-time checks, even across agent restarts")
+	cmd.Flags().StringVar(&priority, "priority", "", `Send ahead of or behind other queued events, either "low", "normal", or "high", instead of by severity`)
+	cmd.Flags().BoolVar(&batch, "batch", false, "Read newline-delimited JSON v2 events from stdin and enqueue each of them")
+	cmd.Flags().BoolVar(&strict, "strict", false, "With batch, enqueue nothing if any line is invalid")
+
+	cmdutil.MarkSendCommand(cmd)
 
 	return cmd
 }
@@ -0,0 +1,198 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+)
+
+// maxBatchLineBytes bounds a single NDJSON line, allowing for events somewhat
+// over the Events API's maximum size that the agent will truncate.
+const maxBatchLineBytes = 2 * eventsapi.DefaultMaxPayloadBytes
+
+var errBatchFailed = errors.New("events failed to enqueue")
+var errBatchInvalid = errors.New("batch contains invalid events, none were enqueued")
+
+// batchLine is a single event read from a batch, or the reason it's invalid.
+type batchLine struct {
+	number int
+	event  *eventsapi.EventV2
+	err    error
+}
+
+// runEnqueueBatch enqueues newline-delimited JSON v2 events read from `in`,
+// reporting each line's outcome and a final summary.
+//
+// Events without a routing key use the one `keyFor` resolves for their
+// severity. Invalid lines are reported
+// and skipped, unless `strict`, in which case nothing is enqueued should any
+// line be invalid. Returns an error if any event wasn't enqueued.
+//
+// Cancelling `ctx` stops the batch, leaving the remaining events unsent.
+func runEnqueueBatch(ctx context.Context, config *cmdutil.Config, in io.Reader, out, errOut io.Writer, keyFor func(severity string) (string, error), strict bool) error {
+	lines, err := readBatch(in, keyFor)
+	if err != nil {
+		return err
+	}
+
+	invalid := 0
+	for _, line := range lines {
+		if line.err != nil {
+			invalid++
+			fmt.Fprintf(errOut, "line %v: invalid event: %v\n", line.number, cmdutil.Redact(line.err.Error()))
+		}
+	}
+	if strict && invalid > 0 {
+		return fmt.Errorf("%w: %v of %v invalid", errBatchInvalid, invalid, len(lines))
+	}
+
+	c, err := config.Client()
+	if err != nil {
+		return err
+	}
+
+	failed := invalid
+	for _, line := range lines {
+		if line.err != nil {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			fmt.Fprintf(errOut, "line %v: not enqueued, batch interrupted\n", line.number)
+			failed++
+			continue
+		}
+
+		key, err := enqueueBatchEvent(ctx, c, line.event)
+		if err != nil {
+			failed++
+			fmt.Fprintf(errOut, "line %v: failed to enqueue: %v\n", line.number, cmdutil.Redact(err.Error()))
+			continue
+		}
+		fmt.Fprintf(out, "line %v: enqueued %v\n", line.number, key)
+	}
+
+	fmt.Fprintf(out, "Enqueued %v of %v events, %v failed.\n", len(lines)-failed, len(lines), failed)
+
+	if failed > 0 {
+		return &cmdutil.ExitError{Code: cmdutil.ExitFailure, Err: fmt.Errorf("%v of %v %w", failed, len(lines), errBatchFailed)}
+	}
+	return nil
+}
+
+// readBatch parses and validates each non-blank line of a batch.
+func readBatch(in io.Reader, keyFor func(severity string) (string, error)) ([]batchLine, error) {
+	var lines []batchLine
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxBatchLineBytes)
+
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		line := batchLine{number: number}
+		line.event, line.err = parseBatchEvent([]byte(text), keyFor)
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read batch: %w", err)
+	}
+	return lines, nil
+}
+
+// parseBatchEvent parses a v2 event, validating it as `send` would.
+func parseBatchEvent(data []byte, keyFor func(severity string) (string, error)) (*eventsapi.EventV2, error) {
+	var event eventsapi.EventV2
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("malformed JSON: %w", err)
+	}
+
+	// Defaulting as the `send` and `enqueue` flags do.
+	if event.Payload.Severity == "" {
+		event.Payload.Severity = "error"
+	}
+	if event.RoutingKey == "" {
+		key, err := keyFor(event.Payload.Severity)
+		if err != nil {
+			return nil, err
+		}
+		event.RoutingKey = key
+	}
+	common.RegisterSecret(event.RoutingKey)
+
+	err := validateSendV2Input(sendV2Input{
+		routingKey:  event.RoutingKey,
+		eventAction: event.EventAction,
+		dedupKey:    event.DedupKey,
+		summary:     event.Payload.Summary,
+		source:      event.Payload.Source,
+		severity:    event.Payload.Severity,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// enqueueBatchEvent sends an event to the agent, returning its queue key.
+func enqueueBatchEvent(ctx context.Context, c *client.Client, event *eventsapi.EventV2) (string, error) {
+	resp, err := c.SendContext(ctx, event)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp server.ErrorResponse
+		if json.Unmarshal(body, &errResp) == nil && len(errResp.Errors) > 0 {
+			return "", fmt.Errorf("%v (HTTP %v)", strings.Join(errResp.Errors, ", "), resp.StatusCode)
+		}
+		return "", fmt.Errorf("HTTP %v", resp.StatusCode)
+	}
+
+	var sendResp server.SendResponse
+	if err := json.Unmarshal(body, &sendResp); err != nil {
+		return "", err
+	}
+	return sendResp.Key, nil
+}
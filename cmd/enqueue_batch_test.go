@@ -0,0 +1,206 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const batchRoutingKey = "11863b592c824bfc8989d9cba76abcde"
+
+var mixedBatch = strings.Join([]string{
+	`{"routing_key":"` + batchRoutingKey + `","event_action":"trigger","dedup_key":"disk","payload":{"summary":"Disk full","source":"web-1","severity":"critical"}}`,
+	`{"routing_key":"` + batchRoutingKey + `","event_action":"trigger"`,
+	``,
+	`{"event_action":"resolve","dedup_key":"disk"}`,
+	`{"routing_key":"` + batchRoutingKey + `","event_action":"alert","dedup_key":"cpu"}`,
+}, "\n")
+
+func runBatchCmd(t *testing.T, input string, args ...string) (string, string, int, error) {
+	defer gock.Off()
+
+	httpClient := &http.Client{}
+	config := cmdutil.NewConfig()
+	config.HttpClient = func() (*http.Client, error) {
+		return httpClient, nil
+	}
+
+	sent := 0
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			sent++
+			return true, nil
+		}).
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{"key": "queued"})
+	gock.InterceptClient(httpClient)
+
+	var stdout, stderr bytes.Buffer
+	cmd := NewEnqueueCmd(config)
+	cmd.SetArgs(append([]string{"--batch"}, args...))
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	_, err := cmd.ExecuteC()
+	return stdout.String(), stderr.String(), sent, err
+}
+
+func TestEnqueueBatch_mixed(t *testing.T) {
+	stdout, stderr, sent, err := runBatchCmd(t, mixedBatch, "--routing-key", batchRoutingKey)
+
+	assert.True(t, errors.Is(err, errBatchFailed), "expected errBatchFailed, was %v", err)
+	assert.Equal(t, 2, sent)
+
+	assert.Contains(t, stdout, "line 1: enqueued queued")
+	assert.Contains(t, stdout, "line 4: enqueued queued")
+	assert.Contains(t, stdout, "Enqueued 2 of 4 events, 2 failed.")
+
+	assert.Contains(t, stderr, "line 2: invalid event: malformed JSON")
+	assert.Contains(t, stderr, "line 5: invalid event: "+errSendEventAction.Error())
+	assert.NotContains(t, stderr, "line 3")
+	assert.NotContains(t, stderr+stdout, batchRoutingKey)
+}
+
+func TestEnqueueBatch_strict(t *testing.T) {
+	stdout, stderr, sent, err := runBatchCmd(t, mixedBatch, "--routing-key", batchRoutingKey, "--strict")
+
+	assert.True(t, errors.Is(err, errBatchInvalid), "expected errBatchInvalid, was %v", err)
+	assert.Equal(t, 0, sent)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "line 2: invalid event")
+	assert.Contains(t, stderr, "line 5: invalid event")
+}
+
+func TestEnqueueBatch_allValid(t *testing.T) {
+	input := `{"routing_key":"` + batchRoutingKey + `","event_action":"trigger","payload":{"summary":"Disk full","source":"web-1"}}` + "\n" +
+		`{"routing_key":"` + batchRoutingKey + `","event_action":"resolve","dedup_key":"disk"}` + "\n"
+
+	stdout, stderr, sent, err := runBatchCmd(t, input, "--strict")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Empty(t, stderr)
+	assert.Contains(t, stdout, "Enqueued 2 of 2 events, 0 failed.")
+}
+
+func TestEnqueueBatch_agentRejects(t *testing.T) {
+	defer gock.Off()
+
+	httpClient := &http.Client{}
+	config := cmdutil.NewConfig()
+	config.HttpClient = func() (*http.Client, error) {
+		return httpClient, nil
+	}
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		Reply(503).
+		JSON(map[string]interface{}{"errors": []string{"queue is shutting down"}})
+	gock.InterceptClient(httpClient)
+
+	var stdout, stderr bytes.Buffer
+	cmd := NewEnqueueCmd(config)
+	cmd.SetArgs([]string{"--batch"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	cmd.SetIn(strings.NewReader(`{"routing_key":"` + batchRoutingKey + `","event_action":"resolve","dedup_key":"disk"}`))
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	_, err := cmd.ExecuteC()
+
+	assert.True(t, errors.Is(err, errBatchFailed), "expected errBatchFailed, was %v", err)
+	assert.Contains(t, stderr.String(), "line 1: failed to enqueue: queue is shutting down (HTTP 503)")
+	assert.Contains(t, stdout.String(), "Enqueued 0 of 1 events, 1 failed.")
+}
+
+func TestEnqueueBatch_flagErrors(t *testing.T) {
+	_, _, _, err := runBatchCmd(t, "", "--summary", "Disk full")
+	assert.EqualError(t, err, "summary may not be combined with batch, set it on each event instead")
+
+	cmd := NewEnqueueCmd(cmdutil.NewConfig())
+	cmd.SetArgs([]string{"--strict"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	_, err = cmd.ExecuteC()
+	assert.Equal(t, errStrictWithoutBatch, err)
+}
+
+func TestEnqueueBatch_severityRoutes(t *testing.T) {
+	defer gock.Off()
+	viper.Set("severity-routes", map[string]interface{}{
+		"web": map[string]interface{}{
+			"critical": "22863b592c824bfc8989d9cba76abcde",
+			"default":  batchRoutingKey,
+		},
+	})
+	defer viper.Set("severity-routes", nil)
+
+	httpClient := &http.Client{}
+	config := cmdutil.NewConfig()
+	config.HttpClient = func() (*http.Client, error) {
+		return httpClient, nil
+	}
+
+	var keys []string
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			var event struct {
+				RoutingKey string `json:"routing_key"`
+			}
+			err := json.NewDecoder(req.Body).Decode(&event)
+			keys = append(keys, event.RoutingKey)
+			return true, err
+		}).
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{"key": "queued"})
+	gock.InterceptClient(httpClient)
+
+	input := `{"event_action":"trigger","payload":{"summary":"Disk full","source":"web-1","severity":"critical"}}` + "\n" +
+		`{"event_action":"trigger","payload":{"summary":"Disk filling","source":"web-1","severity":"warning"}}` + "\n"
+
+	var stdout, stderr bytes.Buffer
+	cmd := NewEnqueueCmd(config)
+	cmd.SetArgs([]string{"--batch", "--key-name", "web"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	_, err := cmd.ExecuteC()
+
+	assert.NoError(t, err)
+	assert.Empty(t, stderr.String())
+	assert.Equal(t, []string{"22863b592c824bfc8989d9cba76abcde", batchRoutingKey}, keys)
+}
@@ -21,12 +21,14 @@ import (
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/PagerDuty/go-pdagent/test"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
 )
 
 func TestEnqueue_noInput(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
 	defer gock.Off()
 
 	defaultHTTPClient := &http.Client{
@@ -61,6 +63,7 @@ func TestEnqueue_noInput(t *testing.T) {
 }
 
 func TestEnqueue_validInput(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
 	defer gock.Off()
 
 	defaultHTTPClient := &http.Client{
@@ -112,3 +115,46 @@ func TestEnqueue_validInput(t *testing.T) {
 
 	assert.Contains(t, out, `{"key":"xyz"}`)
 }
+
+func TestEnqueue_priority(t *testing.T) {
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewEnqueueCmd(realConfig)
+	cmd.SetArgs([]string{"-k", "abc", "-t", "trigger", "-u", "host", "-d", "Host down", "--priority", "high"})
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		MatchHeader("Pd-Priority", "high").
+		Reply(200).
+		JSON(map[string]interface{}{"key": "xyz"})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `enqueue`: %v", err)
+	}
+
+	assert.Contains(t, out, `{"key":"xyz"}`)
+
+	cmd = NewEnqueueCmd(realConfig)
+	cmd.SetArgs([]string{"-k", "abc", "-t", "trigger", "-u", "host", "-d", "Host down", "--priority", "urgent"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	_, err = cmd.ExecuteC()
+	assert.Equal(t, eventsapi.ErrInvalidPriority, err)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+func NewQueueFlushCmd(config *cmdutil.Config) *cobra.Command {
+	var wait time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "flush",
+		Short: "Send all pending events immediately.",
+		Long: `Send all pending events immediately, waiting for them to complete.
+
+Reports how many events succeeded, failed, and remained pending when the wait
+elapsed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFlushCommand(config, wait)
+		},
+	}
+
+	cmd.Flags().DurationVar(&wait, "wait", 5*time.Second, "How long to wait for pending events to be sent")
+
+	return cmd
+}
+
+func runFlushCommand(config *cmdutil.Config, wait time.Duration) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// The request blocks for up to the wait, so allow for it on top of the
+	// usual request timeout.
+	if c.HTTPClient.Timeout > 0 {
+		c.HTTPClient.Timeout += wait
+	}
+
+	resp, err := c.QueueFlush(wait)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestQueueFlush(t *testing.T) {
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewQueueFlushCmd(realConfig)
+	cmd.SetArgs([]string{"--wait", "2s"})
+
+	body := `{"succeeded":3,"failed":1,"remaining":0}`
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/queue/flush").
+		MatchParam("timeout", "2s").
+		Reply(200).
+		BodyString(body)
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `queue flush`: %v", err)
+	}
+
+	assert.True(t, gock.IsDone())
+	assert.Equal(t, body+"\n", out)
+	assert.Equal(t, 7*time.Second, defaultHTTPClient.Timeout)
+}
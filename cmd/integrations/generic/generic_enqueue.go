@@ -0,0 +1,276 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type genericEnqueueInput struct {
+	routingKey       string
+	keyName          string
+	dryRun           bool
+	verbose          bool
+	strictKey        bool
+	autoResolveAfter time.Duration
+}
+
+// genericMapping describes how environment variables map to event fields,
+// read from the "generic" section of the config file.
+//
+// Each `_from` field is expanded with variables such as `$ALERT_TITLE` or
+// `${ALERT_HOST}`, so may combine several of them with literal text. Values of
+// `action_values` and `severity_values` translate the monitoring tool's own
+// actions and severities, matched case-insensitively.
+type genericMapping struct {
+	RoutingKeyFrom  string            `mapstructure:"routing_key_from"`
+	EventActionFrom string            `mapstructure:"event_action_from"`
+	DedupKeyFrom    string            `mapstructure:"dedup_key_from"`
+	SummaryFrom     string            `mapstructure:"summary_from"`
+	SourceFrom      string            `mapstructure:"source_from"`
+	SeverityFrom    string            `mapstructure:"severity_from"`
+	ComponentFrom   string            `mapstructure:"component_from"`
+	GroupFrom       string            `mapstructure:"group_from"`
+	ClassFrom       string            `mapstructure:"class_from"`
+	DetailsPrefix   string            `mapstructure:"details_prefix"`
+	ActionValues    map[string]string `mapstructure:"action_values"`
+	SeverityValues  map[string]string `mapstructure:"severity_values"`
+}
+
+var allowedEventActions = []string{"trigger", "acknowledge", "resolve"}
+var allowedSeverities = []string{"critical", "error", "warning", "info"}
+
+var errRoutingKey = errors.New("a routing key must be set using the -k or --key-name flags or routing_key_from")
+var errEventAction = fmt.Errorf("event_action_from must resolve to one of: %v", strings.Join(allowedEventActions, ", "))
+var errSeverity = fmt.Errorf("severity_from must resolve to one of: %v", strings.Join(allowedSeverities, ", "))
+
+// defaultMapping is used for any fields not set in the config file.
+func defaultMapping() genericMapping {
+	return genericMapping{
+		RoutingKeyFrom:  "$PD_ROUTING_KEY",
+		EventActionFrom: "$ALERT_ACTION",
+		DedupKeyFrom:    "$ALERT_ID",
+		SummaryFrom:     "$ALERT_TITLE",
+		SourceFrom:      "$ALERT_HOST",
+		SeverityFrom:    "$ALERT_LEVEL",
+		ComponentFrom:   "$ALERT_COMPONENT",
+		GroupFrom:       "$ALERT_GROUP",
+		ClassFrom:       "$ALERT_CLASS",
+		DetailsPrefix:   "ALERT_DETAIL_",
+		ActionValues: map[string]string{
+			"problem":      "trigger",
+			"alert":        "trigger",
+			"acknowledged": "acknowledge",
+			"ok":           "resolve",
+			"recovery":     "resolve",
+			"resolved":     "resolve",
+		},
+		SeverityValues: map[string]string{
+			"high":    "error",
+			"medium":  "warning",
+			"low":     "info",
+			"notice":  "info",
+			"down":    "critical",
+			"up":      "info",
+			"unknown": "warning",
+			"fatal":   "critical",
+		},
+	}
+}
+
+// loadMapping returns the default mapping overridden by the config file.
+func loadMapping() (genericMapping, error) {
+	mapping := defaultMapping()
+	if err := viper.UnmarshalKey("generic", &mapping); err != nil {
+		return mapping, fmt.Errorf("invalid generic mapping in config: %w", err)
+	}
+	return mapping, nil
+}
+
+func NewGenericEnqueueCmd(config *cmdutil.Config) *cobra.Command {
+	var cmdInput genericEnqueueInput
+
+	cmd := &cobra.Command{
+		Use:   "enqueue",
+		Short: "Enqueue a v2 event to PagerDuty from environment variables.",
+		Long: `Enqueue a v2 event to PagerDuty from environment variables.
+
+	Intended to be called by monitoring tools, such as SolarWinds or homegrown
+	scripts, that run a command with an alert's details in its environment.
+	Variables are mapped to event fields under "generic" in the config file,
+	for example:
+
+	generic:
+	  summary_from: "$ALERT_TITLE on $ALERT_HOST"
+	  severity_from: $ALERT_LEVEL
+	  severity_values:
+	    sev1: critical
+
+	By default the routing key is read from PD_ROUTING_KEY; the action, dedup
+	key, summary, source, and severity from ALERT_ACTION, ALERT_ID, ALERT_TITLE,
+	ALERT_HOST, and ALERT_LEVEL; and variables prefixed ALERT_DETAIL_ are sent as
+	custom details. Missing actions default to trigger and severities to error.
+
+	The routing key may instead be given with -k or --key-name.
+		`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mapping, err := loadMapping()
+			if err != nil {
+				return err
+			}
+
+			sendEvent, err := buildSendEvent(cmdInput, mapping, os.Environ())
+			if err != nil {
+				return err
+			}
+
+			sendEvent.RoutingKey, err = cmdutil.ResolveSeverityKey(sendEvent.RoutingKey, cmdInput.keyName, sendEvent.Payload.Severity)
+			if err != nil {
+				return err
+			}
+
+			if cmdInput.strictKey {
+				if err := cmdutil.ValidateKeyFormat(sendEvent.RoutingKey, eventsapi.EventVersion2); err != nil {
+					return err
+				}
+			}
+
+			if cmdInput.dryRun {
+				return cmdutil.RunDryRunCommand(sendEvent, nil)
+			}
+
+			if cmdInput.verbose {
+				if err := cmdutil.PrintVerbose(cmd.ErrOrStderr(), cmd.Flags(), sendEvent); err != nil {
+					return err
+				}
+			}
+
+			return cmdutil.RunSendCommand(cmd.Context(), config, sendEvent, nil, client.WithAutoResolveAfter(cmdInput.autoResolveAfter), client.WithIntegration("generic"))
+		},
+	}
+
+	cmd.Flags().StringVarP(&cmdInput.routingKey, "routing-key", "k", "", "Service Events API Key, or @FILE or env:VAR to read it from a file or environment variable, instead of routing_key_from")
+	cmd.Flags().StringVar(&cmdInput.keyName, "key-name", "", "Name of a key configured under keys or severity-routes, used if no routing-key is given")
+	cmd.Flags().DurationVar(&cmdInput.autoResolveAfter, "auto-resolve-after", 0, "Resolve a trigger this long after it's delivered, e.g. 1h for point-in-time checks, even across agent restarts")
+	cmd.Flags().BoolVar(&cmdInput.dryRun, "dry-run", false, "Validate and print the event that would be sent without sending it")
+	cmd.Flags().BoolVarP(&cmdInput.verbose, "verbose", "v", false, "Print the flags and event to stderr before sending it")
+	cmd.Flags().BoolVar(&cmdInput.strictKey, "strict-key", false, "Check the key looks like a valid key for the Events API version before sending, see validate-key")
+
+	cmdutil.MarkSendCommand(cmd)
+
+	return cmd
+}
+
+// buildSendEvent maps environment variables, given as `KEY=value` strings, to
+// a v2 event, returning an error if a field required for the event's action
+// doesn't resolve.
+func buildSendEvent(cmdInputs genericEnqueueInput, mapping genericMapping, environ []string) (*eventsapi.EventV2, error) {
+	env := parseEnviron(environ)
+	expand := func(value string) string {
+		return strings.TrimSpace(os.Expand(value, func(name string) string { return env[name] }))
+	}
+
+	eventAction := translateValue(expand(mapping.EventActionFrom), mapping.ActionValues, "trigger")
+	if err := cmdutil.ValidateEnumField(eventAction, allowedEventActions, errEventAction); err != nil {
+		return nil, err
+	}
+
+	severity := translateValue(expand(mapping.SeverityFrom), mapping.SeverityValues, "error")
+	if err := cmdutil.ValidateEnumField(severity, allowedSeverities, errSeverity); err != nil {
+		return nil, err
+	}
+
+	sendEvent := &eventsapi.EventV2{
+		RoutingKey:  cmdInputs.routingKey,
+		EventAction: eventAction,
+		DedupKey:    expand(mapping.DedupKeyFrom),
+		Payload: eventsapi.PayloadV2{
+			Summary:   expand(mapping.SummaryFrom),
+			Source:    expand(mapping.SourceFrom),
+			Severity:  severity,
+			Component: expand(mapping.ComponentFrom),
+			Group:     expand(mapping.GroupFrom),
+			Class:     expand(mapping.ClassFrom),
+		},
+	}
+	if sendEvent.RoutingKey == "" && cmdInputs.keyName == "" {
+		sendEvent.RoutingKey = expand(mapping.RoutingKeyFrom)
+		if sendEvent.RoutingKey == "" {
+			return nil, errRoutingKey
+		}
+	}
+
+	if eventAction == "trigger" {
+		if sendEvent.Payload.Summary == "" {
+			return nil, unresolvedError("summary_from", mapping.SummaryFrom, eventAction)
+		}
+		if sendEvent.Payload.Source == "" {
+			return nil, unresolvedError("source_from", mapping.SourceFrom, eventAction)
+		}
+	} else if sendEvent.DedupKey == "" {
+		return nil, unresolvedError("dedup_key_from", mapping.DedupKeyFrom, eventAction)
+	}
+
+	if mapping.DetailsPrefix != "" {
+		for name, value := range env {
+			if detail := strings.TrimPrefix(name, mapping.DetailsPrefix); detail != name && detail != "" {
+				sendEvent.AddCustomDetail(strings.ToLower(detail), value)
+			}
+		}
+	}
+
+	return sendEvent, nil
+}
+
+// parseEnviron returns environment variables from `KEY=value` strings, as
+// returned by `os.Environ`.
+func parseEnviron(environ []string) map[string]string {
+	env := map[string]string{}
+	for _, kv := range environ {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+// translateValue returns a lowercased value translated by `values`, or the
+// default if the value is empty.
+func translateValue(value string, values map[string]string, defaultValue string) string {
+	value = strings.ToLower(value)
+	if value == "" {
+		return defaultValue
+	}
+	if translated, ok := values[value]; ok {
+		return strings.ToLower(translated)
+	}
+	return value
+}
+
+func unresolvedError(field, mapping, eventAction string) error {
+	return fmt.Errorf("%v (%q) must resolve to a value for %v events", field, mapping, eventAction)
+}
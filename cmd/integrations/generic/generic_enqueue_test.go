@@ -0,0 +1,236 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package generic
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+var sampleEnviron = []string{
+	"PD_ROUTING_KEY=11863b592c824bfc8989d9cba76abcde",
+	"ALERT_ACTION=PROBLEM",
+	"ALERT_ID=backup-db01",
+	"ALERT_TITLE=Nightly backup failed",
+	"ALERT_HOST=db01.example.com",
+	"ALERT_LEVEL=High",
+	"ALERT_COMPONENT=postgres",
+	"ALERT_DETAIL_EXIT_CODE=2",
+	"ALERT_DETAIL_LOG=/var/log/backup.log",
+	"PATH=/usr/bin",
+}
+
+func TestGenericBuildSendEvent_defaultMapping(t *testing.T) {
+	sendEvent, err := buildSendEvent(genericEnqueueInput{}, defaultMapping(), sampleEnviron)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, &eventsapi.EventV2{
+		RoutingKey:  "11863b592c824bfc8989d9cba76abcde",
+		EventAction: "trigger",
+		DedupKey:    "backup-db01",
+		Payload: eventsapi.PayloadV2{
+			Summary:   "Nightly backup failed",
+			Source:    "db01.example.com",
+			Severity:  "error",
+			Component: "postgres",
+			CustomDetails: map[string]interface{}{
+				"exit_code": "2",
+				"log":       "/var/log/backup.log",
+			},
+		},
+	}, sendEvent)
+}
+
+func TestGenericBuildSendEvent_customMapping(t *testing.T) {
+	mapping := defaultMapping()
+	mapping.EventActionFrom = "$STATE"
+	mapping.SummaryFrom = "$NAME on ${NODE}"
+	mapping.SourceFrom = "$NODE"
+	mapping.SeverityFrom = "$SEV"
+	mapping.DedupKeyFrom = "solarwinds:${NODE}:$NAME"
+	mapping.ActionValues = map[string]string{"down": "trigger", "up": "resolve"}
+	mapping.SeverityValues = map[string]string{"sev1": "critical"}
+	mapping.DetailsPrefix = ""
+
+	environ := []string{"STATE=Down", "NAME=Ping", "NODE=router01", "SEV=SEV1", "ALERT_DETAIL_IGNORED=1"}
+
+	sendEvent, err := buildSendEvent(genericEnqueueInput{routingKey: "xyz"}, mapping, environ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, &eventsapi.EventV2{
+		RoutingKey:  "xyz",
+		EventAction: "trigger",
+		DedupKey:    "solarwinds:router01:Ping",
+		Payload: eventsapi.PayloadV2{
+			Summary:  "Ping on router01",
+			Source:   "router01",
+			Severity: "critical",
+		},
+	}, sendEvent)
+
+	environ[0] = "STATE=Up"
+	sendEvent, err = buildSendEvent(genericEnqueueInput{routingKey: "xyz"}, mapping, environ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "resolve", sendEvent.EventAction)
+}
+
+func TestGenericBuildSendEvent_errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		cmdInputs     genericEnqueueInput
+		environ       []string
+		expectedError error
+	}{
+		{
+			name:          "missingRoutingKey",
+			environ:       []string{"ALERT_TITLE=Backup failed", "ALERT_HOST=db01"},
+			expectedError: errRoutingKey,
+		},
+		{
+			name:          "missingSummary",
+			cmdInputs:     genericEnqueueInput{routingKey: "xyz"},
+			environ:       []string{"ALERT_HOST=db01"},
+			expectedError: errors.New(`summary_from ("$ALERT_TITLE") must resolve to a value for trigger events`),
+		},
+		{
+			name:          "missingSource",
+			cmdInputs:     genericEnqueueInput{routingKey: "xyz"},
+			environ:       []string{"ALERT_TITLE=Backup failed"},
+			expectedError: errors.New(`source_from ("$ALERT_HOST") must resolve to a value for trigger events`),
+		},
+		{
+			name:          "missingDedupKey",
+			cmdInputs:     genericEnqueueInput{routingKey: "xyz"},
+			environ:       []string{"ALERT_ACTION=OK"},
+			expectedError: errors.New(`dedup_key_from ("$ALERT_ID") must resolve to a value for resolve events`),
+		},
+		{
+			name:          "invalidAction",
+			cmdInputs:     genericEnqueueInput{routingKey: "xyz"},
+			environ:       []string{"ALERT_ACTION=snooze"},
+			expectedError: errEventAction,
+		},
+		{
+			name:          "invalidSeverity",
+			cmdInputs:     genericEnqueueInput{routingKey: "xyz"},
+			environ:       []string{"ALERT_TITLE=Backup failed", "ALERT_HOST=db01", "ALERT_LEVEL=sev9"},
+			expectedError: errSeverity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildSendEvent(tt.cmdInputs, defaultMapping(), tt.environ)
+			assert.Equal(t, tt.expectedError, err)
+		})
+	}
+}
+
+func TestGenericEnqueue_configMapping(t *testing.T) {
+	test.InitConfigForIntegrationsTesting()
+
+	viper.Set("generic", map[string]interface{}{
+		"summary_from":    "$ALERT_TITLE ($ALERT_LEVEL)",
+		"severity_values": map[string]interface{}{"high": "critical"},
+	})
+	defer viper.Set("generic", nil)
+
+	mapping, err := loadMapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "$ALERT_TITLE ($ALERT_LEVEL)", mapping.SummaryFrom)
+	assert.Equal(t, "$ALERT_HOST", mapping.SourceFrom, "expected unset fields to keep their default")
+	assert.Equal(t, "critical", mapping.SeverityValues["high"])
+}
+
+func TestGenericEnqueue_send(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	for k, v := range parseEnviron(sampleEnviron) {
+		if k == "PATH" {
+			continue
+		}
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	expectedRequestBody := map[string]interface{}{
+		"routing_key":  "11863b592c824bfc8989d9cba76abcde",
+		"event_action": "trigger",
+		"dedup_key":    "backup-db01",
+		"payload": map[string]interface{}{
+			"summary":   "Nightly backup failed",
+			"source":    "db01.example.com",
+			"severity":  "error",
+			"component": "postgres",
+			"custom_details": map[string]interface{}{
+				"exit_code": "2",
+				"log":       "/var/log/backup.log",
+			},
+		},
+	}
+
+	test.InitConfigForIntegrationsTesting()
+
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewGenericEnqueueCmd(realConfig)
+	cmd.SetArgs([]string{})
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").JSON(expectedRequestBody).
+		Reply(200).JSON(map[string]interface{}{"key": "abc"})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `enqueue`: %v", err)
+	}
+
+	assert.True(t, gock.IsDone(), "expected payload was not posted")
+	assert.Contains(t, out, `{"key":"abc"}`)
+}
@@ -0,0 +1,342 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package icinga2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/cobra"
+)
+
+type icinga2EnqueueInput struct {
+	serviceKey         string
+	keyName            string
+	notificationType   string
+	sourceType         string
+	incidentKey        string
+	dedupKey           string
+	eventsAPIVersion   string
+	severity           string
+	dryRun             bool
+	verbose            bool
+	strictKey          bool
+	groupCustomDetails bool
+	autoResolveAfter   time.Duration
+	customFields       cmdutil.CustomFields
+	links              []string
+	images             []string
+}
+
+var allowedNotificationTypes = []string{"PROBLEM", "ACKNOWLEDGEMENT", "RECOVERY", "CUSTOM"}
+var allowedSourceTypes = []string{"host", "service"}
+var allowedEventsAPIVersions = []string{eventsapi.EventVersion1.String(), eventsapi.EventVersion2.String()}
+var allowedSeverities = []string{"critical", "error", "warning", "info"}
+
+var errServiceKey = errors.New("either service-key or key-name must be set")
+var errNotificationType = fmt.Errorf("notification-type must be one of: %v", strings.Join(allowedNotificationTypes, ", "))
+var errSourceType = fmt.Errorf("source-type must be one of: %v", strings.Join(allowedSourceTypes, ", "))
+var errEventsAPIVersion = fmt.Errorf("events-api-version must be one of: %v", strings.Join(allowedEventsAPIVersions, ", "))
+var errSeverity = fmt.Errorf("severity must be one of: %v", strings.Join(allowedSeverities, ", "))
+
+var requiredFields = map[string][]string{
+	"host":    {"host.name", "host.state"},
+	"service": {"host.name", "service.name", "service.state"},
+}
+
+// stateFields are the fields holding the current state for each source type.
+var stateFields = map[string]string{
+	"host":    "host.state",
+	"service": "service.state",
+}
+
+var icinga2ToPagerDutyEventType = map[string]string{
+	"PROBLEM":         "trigger",
+	"ACKNOWLEDGEMENT": "acknowledge",
+	"RECOVERY":        "resolve",
+}
+
+// icinga2StateToPagerDutySeverity maps host and service states to v2 event
+// severities, used when no severity is given explicitly.
+var icinga2StateToPagerDutySeverity = map[string]string{
+	"UP":       "info",
+	"DOWN":     "critical",
+	"OK":       "info",
+	"WARNING":  "warning",
+	"CRITICAL": "critical",
+	"UNKNOWN":  "error",
+}
+
+// icinga2OKStates are states considered healthy, resolving any incident when
+// sent with a `CUSTOM` notification.
+var icinga2OKStates = map[string]bool{
+	"UP": true,
+	"OK": true,
+}
+
+func NewIcinga2EnqueueCmd(config *cmdutil.Config) *cobra.Command {
+	cmdInput := icinga2EnqueueInput{customFields: cmdutil.CustomFields{}}
+	requiredFlags := []string{"notification-type", "source-type"}
+
+	cmd := &cobra.Command{
+		Use:   "enqueue",
+		Short: "Enqueue an event from Icinga2 to PagerDuty.",
+		Long: fmt.Sprintf(`Enqueue an event from Icinga2 to PagerDuty.
+
+	The following flags are required to be set for this command: %v, and
+	either service-key or key-name.
+
+	When the source type is "host", the following fields must be set using the -f flag:
+	%v
+
+	When the source type is "service", the following fields must be set using the -f flag:
+	%v
+
+	Fields are named after the Icinga2 macros they're populated from, e.g.
+	-f host.name=$host.name$. A "CUSTOM" notification triggers or resolves
+	based on the current host or service state.
+		`, strings.Join(requiredFlags, ", "), strings.Join(requiredFields["host"], ", "), strings.Join(requiredFields["service"], ", ")),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := validateIcinga2SendCommand(cmdInput)
+			if err != nil {
+				return err
+			}
+
+			cmdInput.serviceKey, err = cmdutil.ResolveSeverityKey(cmdInput.serviceKey, cmdInput.keyName, buildSeverity(cmdInput))
+			if err != nil {
+				return err
+			}
+
+			if cmdInput.strictKey {
+				if err := cmdutil.ValidateKeyFormat(cmdInput.serviceKey, eventsapi.EventVersion(cmdInput.eventsAPIVersion)); err != nil {
+					return err
+				}
+			}
+
+			sendEvent := buildSendEvent(cmdInput)
+
+			if cmdInput.dryRun {
+				return cmdutil.RunDryRunCommand(sendEvent, nil)
+			}
+
+			if cmdInput.verbose {
+				if err := cmdutil.PrintVerbose(cmd.ErrOrStderr(), cmd.Flags(), sendEvent); err != nil {
+					return err
+				}
+			}
+
+			return cmdutil.RunSendCommand(cmd.Context(), config, sendEvent, nil, client.WithAutoResolveAfter(cmdInput.autoResolveAfter), client.WithIntegration("icinga2"))
+		},
+	}
+
+	cmd.Flags().StringVarP(&cmdInput.serviceKey, "service-key", "k", "", "Service Events API Key, or @FILE or env:VAR to read it from a file or environment variable")
+	cmd.Flags().StringVar(&cmdInput.keyName, "key-name", "", "Name of a key configured under keys or severity-routes, used if no service-key is given")
+	cmd.Flags().StringVarP(&cmdInput.notificationType, "notification-type", "t", "", "The Icinga2 notification type, i.e. $notification.type$ (required)")
+	cmd.Flags().StringVarP(&cmdInput.sourceType, "source-type", "n", "", "The Icinga2 source type (host or service, required)")
+	cmd.Flags().StringVarP(&cmdInput.incidentKey, "incident-key", "y", "", "Incident key for correlating triggers and resolves")
+	cmd.Flags().StringVarP(&cmdInput.dedupKey, "dedup-key", "d", "", "Deduplication key for correlating triggers and resolves, overriding any incident key")
+	cmd.Flags().StringVar(&cmdInput.eventsAPIVersion, "events-api-version", config.APIVersion.String(), `The Events API version to send events with, either "v1" or "v2"`)
+	cmd.Flags().StringVarP(&cmdInput.severity, "severity", "e", "", "The perceived severity of the event, only used for v2 events (default derived from state)")
+	cmd.Flags().DurationVar(&cmdInput.autoResolveAfter, "auto-resolve-after", 0, "Resolve a trigger this long after it's delivered, e.g. 1h for point-in-time checks, even across agent restarts")
+	cmd.Flags().BoolVar(&cmdInput.dryRun, "dry-run", false, "Validate and print the event that would be sent without sending it")
+	cmd.Flags().BoolVarP(&cmdInput.verbose, "verbose", "v", false, "Print the flags, incident key, and event to stderr before sending it")
+	cmd.Flags().BoolVar(&cmdInput.strictKey, "strict-key", false, "Check the key looks like a valid key for the Events API version before sending, see validate-key")
+	cmd.Flags().VarP(cmdInput.customFields, "field", "f", "Add given KEY=VALUE pair to the event details, repeated keys are sent as a list")
+	cmd.Flags().StringArrayVar(&cmdInput.links, "link", []string{}, "Add a link to the event as URL[,TEXT], e.g. to Icinga Web; empty values are ignored")
+	cmd.Flags().StringArrayVar(&cmdInput.images, "image", []string{}, "Add an image to the event as SRC[,HREF[,ALT]], e.g. a graph; empty values are ignored")
+	cmd.Flags().BoolVar(&cmdInput.groupCustomDetails, "group-custom-details", false, "Group fields under a \"custom\" detail, apart from those set by the integration")
+
+	for _, flag := range requiredFlags {
+		cmd.MarkFlagRequired(flag)
+	}
+
+	cmdutil.MarkSendCommand(cmd)
+
+	return cmd
+}
+
+func buildSendEvent(cmdInputs icinga2EnqueueInput) eventsapi.Event {
+	sendEvent := buildBaseEvent(cmdInputs)
+
+	// Links and images have already been validated by
+	// validateIcinga2SendCommand.
+	links, _ := cmdutil.ParseLinks(cmdInputs.links)
+	images, _ := cmdutil.ParseImages(cmdInputs.images)
+	cmdutil.SetLinks(sendEvent, links, images)
+
+	for k, v := range buildCustomDetails(cmdInputs) {
+		sendEvent.AddCustomDetail(k, v)
+	}
+
+	return sendEvent
+}
+
+func buildBaseEvent(cmdInputs icinga2EnqueueInput) eventsapi.Event {
+	incidentKey := resolveIncidentKey(cmdInputs)
+
+	if cmdInputs.eventsAPIVersion == eventsapi.EventVersion2.String() {
+		return &eventsapi.EventV2{
+			RoutingKey:  cmdInputs.serviceKey,
+			EventAction: buildEventType(cmdInputs),
+			DedupKey:    incidentKey,
+			Payload: eventsapi.PayloadV2{
+				Summary:  buildEventDescription(cmdInputs),
+				Source:   cmdInputs.customFields.Get("host.name"),
+				Severity: buildSeverity(cmdInputs),
+			},
+		}
+	}
+
+	return &eventsapi.EventV1{
+		ServiceKey:  cmdInputs.serviceKey,
+		EventType:   buildEventType(cmdInputs),
+		IncidentKey: incidentKey,
+		Description: buildEventDescription(cmdInputs),
+	}
+}
+
+// buildEventType maps the notification type to a PagerDuty event type.
+//
+// `CUSTOM` notifications don't imply a transition, so instead the current
+// state determines whether to trigger or resolve.
+func buildEventType(cmdInputs icinga2EnqueueInput) string {
+	if eventType, ok := icinga2ToPagerDutyEventType[cmdInputs.notificationType]; ok {
+		return eventType
+	}
+
+	if icinga2OKStates[currentState(cmdInputs)] {
+		return "resolve"
+	}
+	return "trigger"
+}
+
+// buildSeverity returns the explicit severity if given, otherwise derives one
+// from the current state.
+func buildSeverity(cmdInputs icinga2EnqueueInput) string {
+	if cmdInputs.severity != "" {
+		return cmdInputs.severity
+	}
+
+	if severity, ok := icinga2StateToPagerDutySeverity[currentState(cmdInputs)]; ok {
+		return severity
+	}
+	return "error"
+}
+
+func currentState(cmdInputs icinga2EnqueueInput) string {
+	return strings.ToUpper(cmdInputs.customFields.Get(stateFields[cmdInputs.sourceType]))
+}
+
+// buildCustomDetails flattens the custom fields, and any configured default
+// details, into event details alongside the integration's own, which a field
+// of the same name never overwrites.
+//
+// Fields used for validation and key derivation always keep a single value so
+// details agree with the description and incident key.
+func buildCustomDetails(cmdInputs icinga2EnqueueInput) map[string]interface{} {
+	var singleValueFields []string
+	for _, fields := range requiredFields {
+		singleValueFields = append(singleValueFields, fields...)
+	}
+
+	reserved := map[string]interface{}{"pd_icinga2_object": cmdInputs.sourceType}
+	user := cmdutil.WithDefaultDetails("icinga2", cmdInputs.customFields.Details(singleValueFields))
+	return cmdutil.NamespacedDetails(reserved, user, cmdInputs.groupCustomDetails)
+}
+
+func buildEventDescription(cmdInputs icinga2EnqueueInput) string {
+	descriptionFields := []string{}
+
+	for _, field := range requiredFields[cmdInputs.sourceType] {
+		descriptionFields = append(descriptionFields, fmt.Sprintf("%v=%v", field, cmdInputs.customFields.Get(field)))
+	}
+
+	return strings.Join(descriptionFields, "; ")
+}
+
+// resolveIncidentKey returns the key used to correlate events, preferring an
+// explicit dedup key, then an explicit incident key, and finally falling back
+// to one derived from the host and service.
+//
+// Derived keys match those of the Nagios integration.
+func resolveIncidentKey(cmdInputs icinga2EnqueueInput) string {
+	if cmdInputs.dedupKey != "" {
+		return cmdInputs.dedupKey
+	}
+
+	if cmdInputs.incidentKey != "" {
+		return cmdInputs.incidentKey
+	}
+
+	return cmdutil.BuildIncidentKey(
+		cmdInputs.sourceType, cmdInputs.customFields.Get("host.name"), cmdInputs.customFields.Get("service.name"),
+	)
+}
+
+func validateIcinga2SendCommand(cmdInputs icinga2EnqueueInput) error {
+	if cmdInputs.serviceKey == "" && cmdInputs.keyName == "" {
+		return errServiceKey
+	}
+
+	if err := cmdutil.ValidateEnumField(cmdInputs.notificationType, allowedNotificationTypes, errNotificationType); err != nil {
+		return err
+	}
+
+	if err := cmdutil.ValidateEnumField(cmdInputs.sourceType, allowedSourceTypes, errSourceType); err != nil {
+		return err
+	}
+
+	if err := cmdutil.ValidateEnumField(cmdInputs.eventsAPIVersion, allowedEventsAPIVersions, errEventsAPIVersion); err != nil {
+		return err
+	}
+
+	if cmdInputs.eventsAPIVersion == eventsapi.EventVersion2.String() && cmdInputs.severity != "" {
+		if err := cmdutil.ValidateEnumField(cmdInputs.severity, allowedSeverities, errSeverity); err != nil {
+			return err
+		}
+	}
+
+	if err := validateCustomDetails(cmdInputs); err != nil {
+		return err
+	}
+
+	if _, err := cmdutil.ParseLinks(cmdInputs.links); err != nil {
+		return err
+	}
+
+	if _, err := cmdutil.ParseImages(cmdInputs.images); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateCustomDetails(cmdInputs icinga2EnqueueInput) error {
+	requiredKeys := requiredFields[cmdInputs.sourceType]
+
+	for _, key := range requiredKeys {
+		if !cmdInputs.customFields.Has(key) {
+			return fmt.Errorf("the %v field must be set for source-type \"%v\" using the -f flag", key, cmdInputs.sourceType)
+		}
+	}
+
+	return nil
+}
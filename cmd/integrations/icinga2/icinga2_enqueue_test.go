@@ -0,0 +1,522 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package icinga2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func buildCmdArgs(inputs icinga2EnqueueInput) []string {
+	args := []string{}
+
+	flags := []struct {
+		flag string
+		val  string
+	}{
+		{"-k", inputs.serviceKey}, {"--key-name", inputs.keyName}, {"-t", inputs.notificationType}, {"-n", inputs.sourceType}, {"-y", inputs.incidentKey},
+		{"-d", inputs.dedupKey}, {"--events-api-version", inputs.eventsAPIVersion}, {"-e", inputs.severity},
+	}
+	for _, f := range flags {
+		if f.val != "" {
+			args = append(args, f.flag, f.val)
+		}
+	}
+
+	if inputs.dryRun {
+		args = append(args, "--dry-run")
+	}
+
+	for _, link := range inputs.links {
+		args = append(args, "--link", link)
+	}
+	for _, image := range inputs.images {
+		args = append(args, "--image", image)
+	}
+
+	for k, vals := range inputs.customFields {
+		for _, v := range vals {
+			args = append(args, "-f", fmt.Sprintf("%v=%v", k, v))
+		}
+	}
+
+	return args
+}
+
+func TestIcinga2Enqueue_errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		inputs        icinga2EnqueueInput
+		expectedError error
+	}{
+		{
+			name:          "missingRequiredFlags",
+			inputs:        icinga2EnqueueInput{},
+			expectedError: errors.New("required flag(s) \"notification-type\", \"source-type\" not set"),
+		},
+		{
+			name: "missingServiceKey",
+			inputs: icinga2EnqueueInput{
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+			},
+			expectedError: errServiceKey,
+		},
+		{
+			name: "unknownKeyName",
+			inputs: icinga2EnqueueInput{
+				keyName:          "unknown",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				customFields: cmdutil.CustomFields{
+					"host.name":  {"computer.network"},
+					"host.state": {"DOWN"},
+				},
+			},
+			expectedError: errors.New("unknown key name unknown, no keys are configured"),
+		},
+		{
+			name: "invalidNotficationType",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "FLAPPINGSTART",
+				sourceType:       "host",
+			},
+			expectedError: errNotificationType,
+		},
+		{
+			name: "invalidSourceType",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "invalidSourceType",
+			},
+			expectedError: errSourceType,
+		},
+		{
+			name: "invalidEventsAPIVersion",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				eventsAPIVersion: "v3",
+			},
+			expectedError: errEventsAPIVersion,
+		},
+		{
+			name: "invalidV2Severity",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				eventsAPIVersion: "v2",
+				severity:         "catastrophic",
+			},
+			expectedError: errSeverity,
+		},
+		{
+			name: "dryRunInvalidNotificationType",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "trigger",
+				sourceType:       "host",
+				dryRun:           true,
+			},
+			expectedError: errNotificationType,
+		},
+		{
+			name: "hostNameNotSetServiceCustomDetails",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "RECOVERY",
+				sourceType:       "service",
+			},
+			expectedError: errors.New("the host.name field must be set for source-type \"service\" using the -f flag"),
+		},
+		{
+			name: "serviceNameNotSetServiceCustomDetails",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "RECOVERY",
+				sourceType:       "service",
+				customFields: cmdutil.CustomFields{
+					"host.name": {"computer.network"},
+				},
+			},
+			expectedError: errors.New("the service.name field must be set for source-type \"service\" using the -f flag"),
+		},
+		{
+			name: "serviceStateNotSetServiceCustomDetails",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "RECOVERY",
+				sourceType:       "service",
+				customFields: cmdutil.CustomFields{
+					"host.name":    {"computer.network"},
+					"service.name": {"a service"},
+				},
+			},
+			expectedError: errors.New("the service.state field must be set for source-type \"service\" using the -f flag"),
+		},
+		{
+			name: "hostNameNotSetHostCustomDetails",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "RECOVERY",
+				sourceType:       "host",
+			},
+			expectedError: errors.New("the host.name field must be set for source-type \"host\" using the -f flag"),
+		},
+		{
+			name: "hostStateNotSetHostCustomDetails",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "RECOVERY",
+				sourceType:       "host",
+				customFields: cmdutil.CustomFields{
+					"host.name": {"computer.network"},
+				},
+			},
+			expectedError: errors.New("the host.state field must be set for source-type \"host\" using the -f flag"),
+		},
+		{
+			name: "invalidLink",
+			inputs: icinga2EnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				customFields: cmdutil.CustomFields{
+					"host.name":  {"computer.network"},
+					"host.state": {"DOWN"},
+				},
+				links: []string{"/icingaweb2/host,Icinga"},
+			},
+			expectedError: errors.New(`link "/icingaweb2/host" must be an absolute URL`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			realConfig := cmdutil.NewConfig()
+
+			cmd := NewIcinga2EnqueueCmd(realConfig)
+			cmd.SetArgs(buildCmdArgs(tt.inputs))
+
+			_, err := cmd.ExecuteC()
+
+			assert.Error(t, err)
+			assert.Equal(t, tt.expectedError, err)
+		})
+	}
+}
+
+func TestIcinga2Enqueue_validInputs(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	tests := []struct {
+		name                string
+		cmdInputs           icinga2EnqueueInput
+		expectedRequestBody map[string]interface{}
+	}{
+		{
+			name: "validSourceHostInput",
+			cmdInputs: icinga2EnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				customFields: cmdutil.CustomFields{
+					"host.name":  {"computer.network"},
+					"host.state": {"DOWN"},
+				},
+			},
+			expectedRequestBody: map[string]interface{}{
+				"service_key":  "xyz",
+				"event_type":   "trigger",
+				"incident_key": "event_source=host;host_name=computer.network",
+				"description":  "host.name=computer.network; host.state=DOWN",
+				"details": map[string]interface{}{
+					"host.name":         "computer.network",
+					"host.state":        "DOWN",
+					"pd_icinga2_object": "host",
+				},
+			},
+		},
+		{
+			name: "validSourceServiceInput",
+			cmdInputs: icinga2EnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "ACKNOWLEDGEMENT",
+				sourceType:       "service",
+				customFields: cmdutil.CustomFields{
+					"host.name":     {"computer.network"},
+					"service.name":  {"serviceA"},
+					"service.state": {"CRITICAL"},
+				},
+			},
+			expectedRequestBody: map[string]interface{}{
+				"service_key":  "xyz",
+				"event_type":   "acknowledge",
+				"incident_key": "event_source=service;host_name=computer.network;service_desc=serviceA",
+				"description":  "host.name=computer.network; service.name=serviceA; service.state=CRITICAL",
+				"details": map[string]interface{}{
+					"host.name":         "computer.network",
+					"service.name":      "serviceA",
+					"service.state":     "CRITICAL",
+					"pd_icinga2_object": "service",
+				},
+			},
+		},
+		{
+			name: "customNotificationOKState",
+			cmdInputs: icinga2EnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "CUSTOM",
+				sourceType:       "service",
+				incidentKey:      "someincidentkey",
+				customFields: cmdutil.CustomFields{
+					"host.name":     {"computer.network"},
+					"service.name":  {"serviceA"},
+					"service.state": {"OK"},
+				},
+			},
+			expectedRequestBody: map[string]interface{}{
+				"service_key":  "xyz",
+				"event_type":   "resolve",
+				"incident_key": "someincidentkey",
+				"description":  "host.name=computer.network; service.name=serviceA; service.state=OK",
+				"details": map[string]interface{}{
+					"host.name":         "computer.network",
+					"service.name":      "serviceA",
+					"service.state":     "OK",
+					"pd_icinga2_object": "service",
+				},
+			},
+		},
+		{
+			name: "validV2SourceServiceInput",
+			cmdInputs: icinga2EnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "RECOVERY",
+				sourceType:       "service",
+				eventsAPIVersion: "v2",
+				customFields: cmdutil.CustomFields{
+					"host.name":     {"computer.network"},
+					"service.name":  {"serviceA"},
+					"service.state": {"WARNING"},
+				},
+			},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "xyz",
+				"event_action": "resolve",
+				"dedup_key":    "event_source=service;host_name=computer.network;service_desc=serviceA",
+				"payload": map[string]interface{}{
+					"summary":  "host.name=computer.network; service.name=serviceA; service.state=WARNING",
+					"source":   "computer.network",
+					"severity": "warning",
+					"custom_details": map[string]interface{}{
+						"host.name":         "computer.network",
+						"service.name":      "serviceA",
+						"service.state":     "WARNING",
+						"pd_icinga2_object": "service",
+					},
+				},
+			},
+		},
+		{
+			name: "customNotificationV2DedupKey",
+			cmdInputs: icinga2EnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "CUSTOM",
+				sourceType:       "host",
+				dedupKey:         "somededupkey",
+				eventsAPIVersion: "v2",
+				severity:         "info",
+				customFields: cmdutil.CustomFields{
+					"host.name":  {"computer.network"},
+					"host.state": {"DOWN"},
+				},
+			},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "xyz",
+				"event_action": "trigger",
+				"dedup_key":    "somededupkey",
+				"payload": map[string]interface{}{
+					"summary":  "host.name=computer.network; host.state=DOWN",
+					"source":   "computer.network",
+					"severity": "info",
+					"custom_details": map[string]interface{}{
+						"host.name":         "computer.network",
+						"host.state":        "DOWN",
+						"pd_icinga2_object": "host",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{
+				Timeout: 5 * time.Minute,
+			}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewIcinga2EnqueueCmd(realConfig)
+			cmd.SetArgs(buildCmdArgs(tt.cmdInputs))
+
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").JSON(tt.expectedRequestBody).
+				Reply(200).JSON(map[string]interface{}{"key": tt.cmdInputs.serviceKey})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if err != nil {
+				t.Errorf("error running command `enqueue`: %v", err)
+			}
+
+			assert.True(t, gock.IsDone(), "expected payload was not posted")
+			assert.Contains(t, out, fmt.Sprintf(`{"key":"%v"}`, tt.cmdInputs.serviceKey))
+		})
+	}
+}
+
+func TestIcinga2Enqueue_customDetailsCollision(t *testing.T) {
+	cmdInputs := icinga2EnqueueInput{
+		sourceType: "host",
+		customFields: cmdutil.CustomFields{
+			"HOSTNAME":          {"computer.network"},
+			"pd_icinga2_object": {"user value"},
+		},
+	}
+
+	details := buildCustomDetails(cmdInputs)
+	assert.Equal(t, "host", details["pd_icinga2_object"])
+	assert.Equal(t, "user value", details["custom_pd_icinga2_object"])
+
+	cmdInputs.groupCustomDetails = true
+	details = buildCustomDetails(cmdInputs)
+	assert.Equal(t, map[string]interface{}{
+		"pd_icinga2_object": "host",
+		"custom":            map[string]interface{}{"HOSTNAME": "computer.network", "pd_icinga2_object": "user value"},
+	}, details)
+}
+
+func TestIcinga2Enqueue_linksAndImages(t *testing.T) {
+	cmdInputs := icinga2EnqueueInput{
+		serviceKey:       "xyz",
+		notificationType: "PROBLEM",
+		sourceType:       "host",
+		customFields: cmdutil.CustomFields{
+			"host.name":  {"computer.network"},
+			"host.state": {"DOWN"},
+		},
+		links:  []string{"https://icinga.example.com/host,Icinga", ""},
+		images: []string{"https://graphs.example.com/cpu.png,,CPU"},
+	}
+
+	tests := []struct {
+		version  string
+		expected map[string]interface{}
+	}{
+		{
+			version: "v1",
+			expected: map[string]interface{}{
+				"contexts": []interface{}{
+					map[string]interface{}{"type": "link", "href": "https://icinga.example.com/host", "text": "Icinga"},
+					map[string]interface{}{"type": "image", "src": "https://graphs.example.com/cpu.png", "alt": "CPU"},
+				},
+			},
+		},
+		{
+			version: "v2",
+			expected: map[string]interface{}{
+				"links":  []interface{}{map[string]interface{}{"href": "https://icinga.example.com/host", "text": "Icinga"}},
+				"images": []interface{}{map[string]interface{}{"src": "https://graphs.example.com/cpu.png", "alt": "CPU"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			cmdInputs.eventsAPIVersion = tt.version
+
+			data, err := json.Marshal(buildSendEvent(cmdInputs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, field := range []string{"contexts", "links", "images"} {
+				assert.Equal(t, tt.expected[field], body[field], field)
+			}
+		})
+	}
+}
+
+func TestIcinga2Enqueue_defaultDetails(t *testing.T) {
+	viper.Set("default-details", map[string]interface{}{
+		"icinga2": map[string]string{"datacenter": "us-east-1", "environment": "production"},
+	})
+	defer viper.Set("default-details", nil)
+
+	cmdInputs := icinga2EnqueueInput{
+		sourceType: "host",
+		customFields: cmdutil.CustomFields{
+			"host.name":   {"computer.network"},
+			"environment": {"staging"},
+		},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"pd_icinga2_object": "host",
+		"host.name":         "computer.network",
+		"datacenter":        "us-east-1",
+		"environment":       "staging",
+	}, buildCustomDetails(cmdInputs))
+
+	cmdInputs.groupCustomDetails = true
+	assert.Equal(t, map[string]interface{}{
+		"pd_icinga2_object": "host",
+		"custom":            map[string]interface{}{"host.name": "computer.network", "datacenter": "us-east-1", "environment": "staging"},
+	}, buildCustomDetails(cmdInputs))
+}
@@ -16,118 +16,529 @@ limitations under the License.
 package nagios
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/PagerDuty/go-pdagent/pkg/client"
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/spf13/cobra"
 )
 
 type nagiosEnqueueInput struct {
-	serviceKey       string
-	notificationType string
-	sourceType       string
-	incidentKey      string
-	customFields     map[string]string
+	serviceKey          string
+	keyName             string
+	notificationType    string
+	sourceType          string
+	incidentKey         string
+	incidentKeyTemplate string
+	dedupKey            string
+	eventsAPIVersion    string
+	severity            string
+	source              string
+	group               string
+	class               string
+	client              string
+	clientURL           string
+	suppressDowntime    string
+	resolveDowntimeEnd  bool
+	dryRun              bool
+	expandHosts         bool
+	fromEnv             bool
+	requireAgent        bool
+	wait                bool
+	waitTimeout         time.Duration
+	ttl                 time.Duration
+	autoResolveAfter    time.Duration
+	groupCustomDetails  bool
+	verbose             bool
+	strictKey           bool
+	customFields        cmdutil.CustomFields
+	links               []string
+	images              []string
 }
 
-var allowedNotificationTypes = []string{"PROBLEM", "ACKNOWLEDGEMENT", "RECOVERY"}
+var allowedNotificationTypes = []string{"PROBLEM", "ACKNOWLEDGEMENT", "RECOVERY", "FLAPPINGSTART", "FLAPPINGSTOP", "DOWNTIMESTART", "DOWNTIMEEND"}
 var allowedSourceTypes = []string{"host", "service"}
+var allowedEventsAPIVersions = []string{eventsapi.EventVersion1.String(), eventsapi.EventVersion2.String()}
+var allowedSeverities = []string{"critical", "error", "warning", "info"}
+var allowedDowntimeSuppressions = []string{"drop", "info"}
 
+var errServiceKey = errors.New("either service-key or key-name must be set")
 var errNotificationType = fmt.Errorf("notification-type must be one of: %v", strings.Join(allowedNotificationTypes, ", "))
 var errSourceType = fmt.Errorf("source-type must be one of: %v", strings.Join(allowedSourceTypes, ", "))
+var errEventsAPIVersion = fmt.Errorf("events-api-version must be one of: %v", strings.Join(allowedEventsAPIVersions, ", "))
+var errSeverity = fmt.Errorf("severity-override must be one of: %v", strings.Join(allowedSeverities, ", "))
+var errSuppressDowntime = fmt.Errorf("suppress-downtime must be one of: %v", strings.Join(allowedDowntimeSuppressions, ", "))
+var errSuppressDowntimeV1 = errors.New(`suppress-downtime "info" requires events-api-version v2, as v1 events have no severity`)
+var errWaitTimeout = errors.New("wait-timeout must be positive")
+var errExpandHostsKey = errors.New("expand-hosts derives an incident key for each host, so can't be combined with incident-key or dedup-key")
+var errAcknowledgeKey = errors.New("acknowledgements require a dedup-key, incident-key, or HOSTNAME field to derive the incident key from")
 
 var requiredFields = map[string][]string{
 	"host":    {"HOSTNAME", "HOSTSTATE"},
 	"service": {"HOSTNAME", "SERVICEDESC", "SERVICESTATE"},
 }
 
+// nagiosToPagerDutyEventType maps notification types to event actions.
+//
+// Flapping stopping doesn't mean the host or service has recovered, so
+// FLAPPINGSTOP triggers (deduplicating against any open incident) unless the
+// state shows a recovery, in which case it resolves as usual.
+//
+// Downtime notifications are suppressed unless resolving on DOWNTIMEEND, see
+// resolveEventAction; their mapping only applies to suppressed "info" events.
 var nagiosToPagerDutyEventType = map[string]string{
 	"PROBLEM":         "trigger",
 	"ACKNOWLEDGEMENT": "acknowledge",
 	"RECOVERY":        "resolve",
+	"FLAPPINGSTART":   "trigger",
+	"FLAPPINGSTOP":    "trigger",
+	"DOWNTIMESTART":   "trigger",
+	"DOWNTIMEEND":     "trigger",
 }
 
+// nagiosStateToSeverity maps host and service states to v2 severities.
+//
+// Recovered states always resolve, so their severity is only informational.
+var nagiosStateToSeverity = map[string]string{
+	"DOWN":        "critical",
+	"UNREACHABLE": "error",
+	"CRITICAL":    "critical",
+	"WARNING":     "warning",
+	"UNKNOWN":     "error",
+	"UP":          "info",
+	"OK":          "info",
+}
+
+// defaultSeverity is used for states missing from nagiosStateToSeverity.
+const defaultSeverity = "error"
+
+var recoveredStates = map[string]bool{"UP": true, "OK": true}
+
 func NewNagiosEnqueueCmd(config *cmdutil.Config) *cobra.Command {
-	var cmdInput nagiosEnqueueInput
+	cmdInput := nagiosEnqueueInput{customFields: cmdutil.CustomFields{}}
 
-	requiredFlags := []string{"service-key", "notification-type", "source-type"}
+	requiredFlags := []string{"notification-type", "source-type"}
 
 	cmd := &cobra.Command{
 		Use:   "enqueue",
 		Short: "Enqueue an event from Nagios to PagerDuty.",
 		Long: fmt.Sprintf(`Enqueue an event from Nagios to PagerDuty.
 
-	The following flags are required to be set for this command: %v.
+	The following flags are required to be set for this command: %v, and
+	either service-key or key-name.
 
 	When the source type is "host", the following fields must be set using the -f flag:
 	%v
 
 	When the source type is "service", the following fields must be set using the -f flag:
 	%v
+
+	With --from-env these are instead read from the NAGIOS_* environment
+	variables Nagios sets with enable_environment_macros, the flags taking
+	precedence over them: NAGIOS_NOTIFICATIONTYPE sets the notification type,
+	NAGIOS_CONTACTPAGER the service key, and every other macro a field, e.g.
+	NAGIOS_HOSTNAME the HOSTNAME field. The source type is "service" if
+	NAGIOS_SERVICEDESC is set, otherwise "host".
+
+	With --expand-hosts, a HOSTNAME field listing several comma-separated
+	hosts sends an event for each host, each with its own incident key.
 		`, strings.Join(requiredFlags, ", "), strings.Join(requiredFields["host"], ", "), strings.Join(requiredFields["service"], ", ")),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if !cmdInput.fromEnv {
+				return nil
+			}
+			return applyNagiosEnv(cmd.Flags(), &cmdInput, os.Environ())
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			err := validateNagiosSendCommand(cmdInput)
 			if err != nil {
 				return err
 			}
 
-			sendEvent, customDetails := buildSendEvent(cmdInput)
+			cmdInput.serviceKey, err = cmdutil.ResolveSeverityKey(cmdInput.serviceKey, cmdInput.keyName, resolveSeverity(cmdInput))
+			if err != nil {
+				return err
+			}
+
+			if cmdInput.strictKey {
+				if err := cmdutil.ValidateKeyFormat(cmdInput.serviceKey, eventsapi.EventVersion(cmdInput.eventsAPIVersion)); err != nil {
+					return err
+				}
+			}
 
-			return cmdutil.RunSendCommand(config, &sendEvent, customDetails)
+			cmdInput.client, cmdInput.clientURL, err = resolveClient(cmdInput)
+			if err != nil {
+				return err
+			}
+
+			// Enqueuing every host's event even should one fail, so they
+			// aren't all lost to the first error.
+			var firstErr error
+			for _, hostInput := range expandHostInputs(cmdInput) {
+				if err := enqueueNagiosEvent(cmd, config, hostInput); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			return firstErr
 		},
 	}
 
-	cmd.Flags().StringVarP(&cmdInput.serviceKey, "service-key", "k", "", "Service Events API Key (required)")
+	cmd.Flags().StringVarP(&cmdInput.serviceKey, "service-key", "k", "", "Service Events API Key, or @FILE or env:VAR to read it from a file or environment variable")
+	cmd.Flags().StringVar(&cmdInput.keyName, "key-name", "", "Name of a key configured under keys or severity-routes, used if no service-key is given")
 	cmd.Flags().StringVarP(&cmdInput.notificationType, "notification-type", "t", "", "The Nagios notification type (required)")
 	cmd.Flags().StringVarP(&cmdInput.sourceType, "source-type", "n", "", "The Nagios source type (host or service, required)")
 	cmd.Flags().StringVarP(&cmdInput.incidentKey, "incident-key", "y", "", "Incident key for correlating triggers and resolves")
-	cmd.Flags().StringToStringVarP(&cmdInput.customFields, "field", "f", map[string]string{}, "Add given KEY=VALUE pair to the event details")
+	cmd.Flags().StringVar(&cmdInput.incidentKeyTemplate, "incident-key-template", "", "Go template deriving the incident key from fields, e.g. {{.sourceType}}:{{.HOSTNAME}}:{{.SERVICEDESC}}")
+	cmd.Flags().StringVarP(&cmdInput.dedupKey, "dedup-key", "d", "", "Deduplication key for correlating triggers and resolves, overriding any incident key")
+	cmd.Flags().StringVar(&cmdInput.eventsAPIVersion, "events-api-version", config.APIVersion.String(), `The Events API version to send events with, either "v1" or "v2"`)
+	cmd.Flags().StringVar(&cmdInput.severity, "severity-override", "", "The perceived severity of the event instead of one derived from the host or service state, only used for v2 events")
+	cmd.Flags().StringVarP(&cmdInput.severity, "severity", "e", "", "Deprecated alias of --severity-override")
+	cmd.Flags().StringVar(&cmdInput.source, "source", "", "The source of the event, e.g. the Nagios instance, instead of the HOSTNAME field; sent as the client for v1 events")
+	cmd.Flags().StringVar(&cmdInput.group, "group", "", "The logical group of the event, e.g. a host or service group, instead of the SERVICEGROUP or HOSTGROUP field, only used for v2 events")
+	cmd.Flags().StringVar(&cmdInput.class, "class", "", "The class or type of the event, e.g. the check, instead of the command in the SERVICECHECKCOMMAND or HOSTCHECKCOMMAND field, only used for v2 events")
+	cmd.Flags().StringVar(&cmdInput.client, "client", "", "The client shown on the incident, e.g. the Nagios instance, instead of the client config value or \"PagerDuty Agent on <hostname>\"")
+	cmd.Flags().StringVar(&cmdInput.clientURL, "client-url", "", "A URL linking the incident back to the client, e.g. the Nagios UI, instead of the client-url config value")
+	cmd.Flags().StringVar(&cmdInput.suppressDowntime, "suppress-downtime", "drop", `How to suppress DOWNTIMESTART and DOWNTIMEEND notifications, either "drop" to not send them or "info" to send them as info severity events, only supported by v2`)
+	cmd.Flags().BoolVar(&cmdInput.resolveDowntimeEnd, "resolve-on-downtime-end", false, "Resolve the incident for the host or service on DOWNTIMEEND rather than suppressing it")
+	cmd.Flags().BoolVar(&cmdInput.fromEnv, "from-env", false, "Read the notification type, service key, and fields from NAGIOS_* environment variables not given as flags")
+	cmd.Flags().BoolVar(&cmdInput.expandHosts, "expand-hosts", false, "Send an event for each host in a comma-separated HOSTNAME field, e.g. for a host group, each with its own incident key")
+	cmd.Flags().BoolVar(&cmdInput.dryRun, "dry-run", false, "Validate and print the event that would be sent without sending it")
+	cmd.Flags().DurationVar(&cmdInput.ttl, "ttl", 0, "Discard the event if the agent can't send it within this long, e.g. 1h, instead of the agent's event-ttl")
+	cmd.Flags().DurationVar(&cmdInput.autoResolveAfter, "auto-resolve-after", 0, "Resolve a trigger this long after it's delivered, e.g. 1h for point-in-time checks, even across agent restarts")
+	cmd.Flags().BoolVar(&cmdInput.requireAgent, "require-agent", false, "Check the agent is reachable before enqueuing, exiting non-zero if not so Nagios retries the notification")
+	cmd.Flags().BoolVar(&cmdInput.wait, "wait", false, "Wait for the agent to deliver the event to PagerDuty, printing the dedup key it returned, and exit non-zero if it fails or isn't delivered in time")
+	cmd.Flags().DurationVar(&cmdInput.waitTimeout, "wait-timeout", cmdutil.DefaultWaitTimeout, "How long --wait waits for the event to be delivered")
+	cmd.Flags().BoolVarP(&cmdInput.verbose, "verbose", "v", false, "Print the flags, incident key, and event to stderr before sending it")
+	cmd.Flags().BoolVar(&cmdInput.strictKey, "strict-key", false, "Check the key looks like a valid key for the Events API version before sending, see validate-key")
+	cmd.Flags().VarP(cmdInput.customFields, "field", "f", "Add given KEY=VALUE pair to the event details, repeated keys are sent as a list")
+	cmd.Flags().BoolVar(&cmdInput.groupCustomDetails, "group-custom-details", false, "Group fields under a \"custom\" detail, apart from those set by the integration")
+	cmd.Flags().StringArrayVar(&cmdInput.links, "link", []string{}, "Add a link to the event as URL[,TEXT], e.g. to the Nagios UI; empty values are ignored")
+	cmd.Flags().StringArrayVar(&cmdInput.images, "image", []string{}, "Add an image to the event as SRC[,HREF[,ALT]], e.g. a graph; empty values are ignored")
 
 	for _, flag := range requiredFlags {
 		cmd.MarkFlagRequired(flag)
 	}
+	cmd.Flags().MarkDeprecated("severity", "use --severity-override instead")
+
+	cmdutil.MarkSendCommand(cmd)
 
 	return cmd
 }
 
-func buildSendEvent(cmdInputs nagiosEnqueueInput) (eventsapi.EventV1, map[string]string) {
-	sendEvent := eventsapi.EventV1{
+// enqueueNagiosEvent builds and sends the event for a notification, as
+// validated by `validateNagiosSendCommand`, unless it's suppressed.
+func enqueueNagiosEvent(cmd *cobra.Command, config *cmdutil.Config, cmdInput nagiosEnqueueInput) error {
+	if cmdInput.incidentKey == "" && cmdInput.incidentKeyTemplate != "" {
+		incidentKey, err := buildTemplatedIncidentKey(cmdInput)
+		if err != nil {
+			return err
+		}
+		cmdInput.incidentKey = incidentKey
+	}
+
+	if err := validateIncidentKey(cmdInput); err != nil {
+		return err
+	}
+
+	if isSuppressedDowntime(cmdInput) && cmdInput.suppressDowntime == "drop" {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Dropping %v notification for %v: downtime notifications are suppressed.\n",
+			cmdInput.notificationType, resolveIncidentKey(cmdInput))
+		return nil
+	}
+
+	sendEvent := buildSendEvent(cmdInput)
+
+	if cmdInput.dryRun {
+		return cmdutil.RunDryRunCommand(sendEvent, nil)
+	}
+
+	if cmdInput.verbose {
+		if err := cmdutil.PrintVerbose(cmd.ErrOrStderr(), cmd.Flags(), sendEvent); err != nil {
+			return err
+		}
+	}
+
+	if cmdInput.requireAgent {
+		if err := cmdutil.RequireAgent(config); err != nil {
+			return err
+		}
+	}
+
+	options := []client.SendOption{client.WithTTL(cmdInput.ttl), client.WithAutoResolveAfter(cmdInput.autoResolveAfter), client.WithIntegration("nagios")}
+	if cmdInput.wait {
+		return cmdutil.RunSendAndWaitCommand(cmd.Context(), config, sendEvent, nil, cmdInput.waitTimeout, options...)
+	}
+	return cmdutil.RunSendCommand(cmd.Context(), config, sendEvent, nil, options...)
+}
+
+// expandHostInputs splits a notification whose HOSTNAME lists several
+// comma-separated hosts, e.g. for a host group, into one per host when
+// expanding hosts, each deriving its own incident key.
+func expandHostInputs(cmdInput nagiosEnqueueInput) []nagiosEnqueueInput {
+	if !cmdInput.expandHosts {
+		return []nagiosEnqueueInput{cmdInput}
+	}
+
+	var hostInputs []nagiosEnqueueInput
+	for _, host := range strings.Split(cmdInput.customFields.Get("HOSTNAME"), ",") {
+		if host = strings.TrimSpace(host); host == "" {
+			continue
+		}
+
+		hostInput := cmdInput
+		hostInput.customFields = cmdutil.CustomFields{}
+		for k, vals := range cmdInput.customFields {
+			hostInput.customFields[k] = vals
+		}
+		hostInput.customFields["HOSTNAME"] = []string{host}
+		hostInputs = append(hostInputs, hostInput)
+	}
+
+	if len(hostInputs) == 0 {
+		return []nagiosEnqueueInput{cmdInput}
+	}
+	return hostInputs
+}
+
+func buildSendEvent(cmdInputs nagiosEnqueueInput) eventsapi.Event {
+	sendEvent := buildBaseEvent(cmdInputs)
+
+	// Links and images have already been validated by
+	// validateNagiosSendCommand.
+	links, _ := cmdutil.ParseLinks(cmdInputs.links)
+	images, _ := cmdutil.ParseImages(cmdInputs.images)
+	cmdutil.SetLinks(sendEvent, links, images)
+
+	for k, v := range buildCustomDetails(cmdInputs) {
+		sendEvent.AddCustomDetail(k, v)
+	}
+
+	return sendEvent
+}
+
+func buildBaseEvent(cmdInputs nagiosEnqueueInput) eventsapi.Event {
+	incidentKey := resolveIncidentKey(cmdInputs)
+	eventAction := resolveEventAction(cmdInputs)
+
+	if cmdInputs.eventsAPIVersion == eventsapi.EventVersion2.String() {
+		return &eventsapi.EventV2{
+			RoutingKey:  cmdInputs.serviceKey,
+			EventAction: eventAction,
+			DedupKey:    incidentKey,
+			Payload: eventsapi.PayloadV2{
+				Summary:  buildEventDescription(cmdInputs),
+				Source:   resolveSource(cmdInputs),
+				Severity: resolveSeverity(cmdInputs),
+				Group:    resolveGroup(cmdInputs),
+				Class:    resolveClass(cmdInputs),
+			},
+			Client:    cmdInputs.client,
+			ClientURL: cmdInputs.clientURL,
+		}
+	}
+
+	return &eventsapi.EventV1{
 		ServiceKey:  cmdInputs.serviceKey,
-		EventType:   nagiosToPagerDutyEventType[cmdInputs.notificationType],
-		IncidentKey: cmdInputs.incidentKey,
+		EventType:   eventAction,
+		IncidentKey: incidentKey,
 		Description: buildEventDescription(cmdInputs),
+		Client:      cmdInputs.client,
+		ClientURL:   cmdInputs.clientURL,
 	}
-	if sendEvent.IncidentKey == "" {
-		sendEvent.IncidentKey = buildIncidentKey(cmdInputs)
+}
+
+// nagiosState returns the host or service state, depending on the source type.
+func nagiosState(cmdInputs nagiosEnqueueInput) string {
+	if cmdInputs.sourceType == "service" {
+		return strings.ToUpper(cmdInputs.customFields.Get("SERVICESTATE"))
 	}
+	return strings.ToUpper(cmdInputs.customFields.Get("HOSTSTATE"))
+}
 
-	customDetails := cmdInputs.customFields
-	customDetails["pd_nagios_object"] = cmdInputs.sourceType
+// resolveEventAction maps the notification type to an event action, except
+// that a recovered host or service always resolves.
+//
+// Downtime notifications ignore the state, only resolving on DOWNTIMEEND if
+// configured to.
+func resolveEventAction(cmdInputs nagiosEnqueueInput) string {
+	if isDowntime(cmdInputs) {
+		if isSuppressedDowntime(cmdInputs) {
+			return nagiosToPagerDutyEventType[cmdInputs.notificationType]
+		}
+		return "resolve"
+	}
+	if recoveredStates[nagiosState(cmdInputs)] {
+		return "resolve"
+	}
+	return nagiosToPagerDutyEventType[cmdInputs.notificationType]
+}
 
-	return sendEvent, customDetails
+// resolveSource returns the source override if given, otherwise the host the
+// event is about.
+func resolveSource(cmdInputs nagiosEnqueueInput) string {
+	if cmdInputs.source != "" {
+		return cmdInputs.source
+	}
+	return cmdInputs.customFields.Get("HOSTNAME")
+}
+
+// resolveGroup returns the group override if given, otherwise the service or
+// host group, if set.
+func resolveGroup(cmdInputs nagiosEnqueueInput) string {
+	if cmdInputs.group != "" {
+		return cmdInputs.group
+	}
+	if cmdInputs.sourceType == "service" {
+		if group := cmdInputs.customFields.Get("SERVICEGROUP"); group != "" {
+			return group
+		}
+	}
+	return cmdInputs.customFields.Get("HOSTGROUP")
+}
+
+// resolveClass returns the class override if given, otherwise the name of the
+// service or host check command, if set, without its arguments.
+func resolveClass(cmdInputs nagiosEnqueueInput) string {
+	if cmdInputs.class != "" {
+		return cmdInputs.class
+	}
+	command := cmdInputs.customFields.Get("HOSTCHECKCOMMAND")
+	if cmdInputs.sourceType == "service" {
+		command = cmdInputs.customFields.Get("SERVICECHECKCOMMAND")
+	}
+	return strings.SplitN(command, "!", 2)[0]
+}
+
+// resolveClient returns the client and client URL for the event, as with
+// `cmdutil.ResolveClient`. V1 events have no source, so a source override is
+// sent as the client unless a client is given.
+func resolveClient(cmdInputs nagiosEnqueueInput) (string, string, error) {
+	client := cmdInputs.client
+	if client == "" && cmdInputs.eventsAPIVersion != eventsapi.EventVersion2.String() {
+		client = cmdInputs.source
+	}
+	return cmdutil.ResolveClient(client, cmdInputs.clientURL)
+}
+
+// isDowntime returns true for scheduled downtime starting or ending.
+func isDowntime(cmdInputs nagiosEnqueueInput) bool {
+	return cmdInputs.notificationType == "DOWNTIMESTART" || cmdInputs.notificationType == "DOWNTIMEEND"
+}
+
+// isSuppressedDowntime returns true for downtime notifications that shouldn't
+// page, i.e. all but DOWNTIMEEND when resolving on it.
+func isSuppressedDowntime(cmdInputs nagiosEnqueueInput) bool {
+	return isDowntime(cmdInputs) && !(cmdInputs.notificationType == "DOWNTIMEEND" && cmdInputs.resolveDowntimeEnd)
+}
+
+// resolveSeverity returns the severity override if given, otherwise deriving
+// one from the host or service state. Suppressed downtime notifications are
+// always info.
+func resolveSeverity(cmdInputs nagiosEnqueueInput) string {
+	if isSuppressedDowntime(cmdInputs) {
+		return "info"
+	}
+	if cmdInputs.severity != "" {
+		return cmdInputs.severity
+	}
+	if severity, ok := nagiosStateToSeverity[nagiosState(cmdInputs)]; ok {
+		return severity
+	}
+	return defaultSeverity
+}
+
+// buildCustomDetails flattens the custom fields, and any configured default
+// details, into event details alongside the integration's own, which a field
+// of the same name never overwrites.
+//
+// Fields used for validation and key derivation always keep a single value so
+// details agree with the description and incident key.
+func buildCustomDetails(cmdInputs nagiosEnqueueInput) map[string]interface{} {
+	var singleValueFields []string
+	for _, fields := range requiredFields {
+		singleValueFields = append(singleValueFields, fields...)
+	}
+
+	reserved := map[string]interface{}{"pd_nagios_object": cmdInputs.sourceType}
+	user := cmdutil.WithDefaultDetails("nagios", cmdInputs.customFields.Details(singleValueFields))
+	return cmdutil.NamespacedDetails(reserved, user, cmdInputs.groupCustomDetails)
 }
 
 func buildEventDescription(cmdInputs nagiosEnqueueInput) string {
 	descriptionFields := []string{}
 	for _, field := range requiredFields[cmdInputs.sourceType] {
-		descriptionFields = append(descriptionFields, fmt.Sprintf("%v=%v", field, cmdInputs.customFields[field]))
+		descriptionFields = append(descriptionFields, fmt.Sprintf("%v=%v", field, cmdInputs.customFields.Get(field)))
 	}
 	return strings.Join(descriptionFields, "; ")
 }
 
-func buildIncidentKey(cmdInputs nagiosEnqueueInput) string {
-	if cmdInputs.sourceType == "host" {
-		return fmt.Sprintf("event_source=host;host_name=%v", cmdInputs.customFields["HOSTNAME"])
+// resolveIncidentKey returns the key used to correlate events, preferring an
+// explicit dedup key, then an explicit (or templated) incident key, and
+// finally falling back to one derived from the host and service.
+func resolveIncidentKey(cmdInputs nagiosEnqueueInput) string {
+	if cmdInputs.dedupKey != "" {
+		return cmdInputs.dedupKey
 	}
-	return fmt.Sprintf(
-		"event_source=service;host_name=%v;service_desc=%v",
-		cmdInputs.customFields["HOSTNAME"], cmdInputs.customFields["SERVICEDESC"],
+	if cmdInputs.incidentKey != "" {
+		return cmdInputs.incidentKey
+	}
+	return buildIncidentKey(cmdInputs)
+}
+
+// buildIncidentKey derives a key from the host and, for service events, the
+// service description.
+func buildIncidentKey(cmdInputs nagiosEnqueueInput) string {
+	return cmdutil.BuildIncidentKey(
+		cmdInputs.sourceType, cmdInputs.customFields.Get("HOSTNAME"), cmdInputs.customFields.Get("SERVICEDESC"),
 	)
 }
 
+// buildTemplatedIncidentKey derives a key by evaluating the incident key
+// template against the custom fields and command inputs.
+//
+// Referencing a field that isn't set is an error rather than silently
+// producing a key containing "<no value>".
+func buildTemplatedIncidentKey(cmdInputs nagiosEnqueueInput) (string, error) {
+	tmpl, err := parseIncidentKeyTemplate(cmdInputs.incidentKeyTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	data := map[string]string{}
+	for k := range cmdInputs.customFields {
+		data[k] = cmdInputs.customFields.Get(k)
+	}
+	data["notificationType"] = cmdInputs.notificationType
+	data["sourceType"] = cmdInputs.sourceType
+	data["eventsAPIVersion"] = cmdInputs.eventsAPIVersion
+	data["severity"] = resolveSeverity(cmdInputs)
+
+	var key strings.Builder
+	if err := tmpl.Execute(&key, data); err != nil {
+		return "", fmt.Errorf("incident-key-template could not be evaluated: %v", err)
+	}
+	return key.String(), nil
+}
+
+func parseIncidentKeyTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("incident-key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("incident-key-template is invalid: %v", err)
+	}
+	return tmpl, nil
+}
+
 func validateNagiosSendCommand(cmdInputs nagiosEnqueueInput) error {
+	if cmdInputs.serviceKey == "" && cmdInputs.keyName == "" {
+		return errServiceKey
+	}
+
 	if err := cmdutil.ValidateEnumField(cmdInputs.notificationType, allowedNotificationTypes, errNotificationType); err != nil {
 		return err
 	}
@@ -136,17 +547,71 @@ func validateNagiosSendCommand(cmdInputs nagiosEnqueueInput) error {
 		return err
 	}
 
+	if err := cmdutil.ValidateEnumField(cmdInputs.eventsAPIVersion, allowedEventsAPIVersions, errEventsAPIVersion); err != nil {
+		return err
+	}
+
+	if cmdInputs.eventsAPIVersion == eventsapi.EventVersion2.String() && cmdInputs.severity != "" {
+		if err := cmdutil.ValidateEnumField(cmdInputs.severity, allowedSeverities, errSeverity); err != nil {
+			return err
+		}
+	}
+
+	if isDowntime(cmdInputs) {
+		if err := cmdutil.ValidateEnumField(cmdInputs.suppressDowntime, allowedDowntimeSuppressions, errSuppressDowntime); err != nil {
+			return err
+		}
+		if isSuppressedDowntime(cmdInputs) && cmdInputs.suppressDowntime == "info" && cmdInputs.eventsAPIVersion != eventsapi.EventVersion2.String() {
+			return errSuppressDowntimeV1
+		}
+	}
+
 	if err := validateCustomDetails(cmdInputs); err != nil {
 		return err
 	}
 
+	if cmdInputs.expandHosts && (cmdInputs.incidentKey != "" || cmdInputs.dedupKey != "") {
+		return errExpandHostsKey
+	}
+
+	if cmdInputs.incidentKeyTemplate != "" {
+		if _, err := parseIncidentKeyTemplate(cmdInputs.incidentKeyTemplate); err != nil {
+			return err
+		}
+	}
+
+	if _, err := cmdutil.ParseLinks(cmdInputs.links); err != nil {
+		return err
+	}
+
+	if _, err := cmdutil.ParseImages(cmdInputs.images); err != nil {
+		return err
+	}
+
+	if cmdInputs.wait && cmdInputs.waitTimeout <= 0 {
+		return errWaitTimeout
+	}
+
+	return nil
+}
+
+// validateIncidentKey ensures acknowledgements have a key to land on the
+// incident opened by the original PROBLEM, as a key derived from an empty
+// HOSTNAME would never match it.
+func validateIncidentKey(cmdInputs nagiosEnqueueInput) error {
+	if resolveEventAction(cmdInputs) != "acknowledge" {
+		return nil
+	}
+	if cmdInputs.dedupKey == "" && cmdInputs.incidentKey == "" && cmdInputs.customFields.Get("HOSTNAME") == "" {
+		return errAcknowledgeKey
+	}
 	return nil
 }
 
 func validateCustomDetails(cmdInputs nagiosEnqueueInput) error {
 	requiredKeys := requiredFields[cmdInputs.sourceType]
 	for _, key := range requiredKeys {
-		if _, ok := cmdInputs.customFields[key]; !ok {
+		if !cmdInputs.customFields.Has(key) {
 			return fmt.Errorf("the %v field must be set for source-type \"%v\" using the -f flag", key, cmdInputs.sourceType)
 		}
 	}
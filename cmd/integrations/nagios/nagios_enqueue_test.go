@@ -16,33 +16,77 @@ limitations under the License.
 package nagios
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
 )
 
+// durationArg formats a duration flag, omitting it if zero.
+func durationArg(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
 func buildCmdArgs(inputs nagiosEnqueueInput) []string {
 	args := []string{}
 	flags := []struct {
 		flag string
 		val  string
 	}{
-		{"-k", inputs.serviceKey}, {"-t", inputs.notificationType}, {"-n", inputs.sourceType}, {"-y", inputs.incidentKey},
+		{"-k", inputs.serviceKey}, {"--key-name", inputs.keyName}, {"-t", inputs.notificationType}, {"-n", inputs.sourceType}, {"-y", inputs.incidentKey},
+		{"-d", inputs.dedupKey}, {"--events-api-version", inputs.eventsAPIVersion}, {"--severity-override", inputs.severity},
+		{"--incident-key-template", inputs.incidentKeyTemplate}, {"--source", inputs.source},
+		{"--group", inputs.group}, {"--class", inputs.class},
+		{"--client", inputs.client}, {"--client-url", inputs.clientURL},
+		{"--suppress-downtime", inputs.suppressDowntime},
+		{"--wait-timeout", durationArg(inputs.waitTimeout)},
 	}
 	for _, f := range flags {
 		if f.val != "" {
 			args = append(args, f.flag, f.val)
 		}
 	}
-	for k, v := range inputs.customFields {
-		args = append(args, "-f", fmt.Sprintf("%v=%v", k, v))
+	if inputs.dryRun {
+		args = append(args, "--dry-run")
+	}
+	if inputs.fromEnv {
+		args = append(args, "--from-env")
+	}
+	if inputs.expandHosts {
+		args = append(args, "--expand-hosts")
+	}
+	if inputs.wait {
+		args = append(args, "--wait")
+	}
+	if inputs.resolveDowntimeEnd {
+		args = append(args, "--resolve-on-downtime-end")
+	}
+	for k, vals := range inputs.customFields {
+		for _, v := range vals {
+			args = append(args, "-f", fmt.Sprintf("%v=%v", k, v))
+		}
+	}
+	for _, link := range inputs.links {
+		args = append(args, "--link", link)
+	}
+	for _, image := range inputs.images {
+		args = append(args, "--image", image)
 	}
 	return args
 }
@@ -56,7 +100,28 @@ func TestNagiosEnqueue_errors(t *testing.T) {
 		{
 			name:          "missingRequiredFlags",
 			inputs:        nagiosEnqueueInput{},
-			expectedError: errors.New("required flag(s) \"notification-type\", \"service-key\", \"source-type\" not set"),
+			expectedError: errors.New("required flag(s) \"notification-type\", \"source-type\" not set"),
+		},
+		{
+			name: "missingServiceKey",
+			inputs: nagiosEnqueueInput{
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+			},
+			expectedError: errServiceKey,
+		},
+		{
+			name: "unknownKeyName",
+			inputs: nagiosEnqueueInput{
+				keyName:          "unknown",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":  {"computer.network"},
+					"HOSTSTATE": {"DOWN"},
+				},
+			},
+			expectedError: errors.New("unknown key name unknown, no keys are configured"),
 		},
 		{
 			name: "invalidNotficationType",
@@ -76,6 +141,47 @@ func TestNagiosEnqueue_errors(t *testing.T) {
 			},
 			expectedError: errSourceType,
 		},
+		{
+			name: "dryRunInvalidNotificationType",
+			inputs: nagiosEnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "trigger",
+				sourceType:       "host",
+				dryRun:           true,
+			},
+			expectedError: errNotificationType,
+		},
+		{
+			name: "dryRunInvalidSourceType",
+			inputs: nagiosEnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "invalidSourceType",
+				dryRun:           true,
+			},
+			expectedError: errSourceType,
+		},
+		{
+			name: "invalidEventsAPIVersion",
+			inputs: nagiosEnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				eventsAPIVersion: "v3",
+			},
+			expectedError: errEventsAPIVersion,
+		},
+		{
+			name: "invalidV2Severity",
+			inputs: nagiosEnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				eventsAPIVersion: "v2",
+				severity:         "catastrophic",
+			},
+			expectedError: errSeverity,
+		},
 		{
 			name: "hostnameNotSetServiceCustomDetails",
 			inputs: nagiosEnqueueInput{
@@ -91,8 +197,8 @@ func TestNagiosEnqueue_errors(t *testing.T) {
 				serviceKey:       "abc",
 				notificationType: "RECOVERY",
 				sourceType:       "service",
-				customFields: map[string]string{
-					"HOSTNAME": "computer.network",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME": {"computer.network"},
 				},
 			},
 			expectedError: errors.New("the SERVICEDESC field must be set for source-type \"service\" using the -f flag"),
@@ -103,9 +209,9 @@ func TestNagiosEnqueue_errors(t *testing.T) {
 				serviceKey:       "abc",
 				notificationType: "RECOVERY",
 				sourceType:       "service",
-				customFields: map[string]string{
-					"HOSTNAME":    "computer.network",
-					"SERVICEDESC": "a service",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":    {"computer.network"},
+					"SERVICEDESC": {"a service"},
 				},
 			},
 			expectedError: errors.New("the SERVICESTATE field must be set for source-type \"service\" using the -f flag"),
@@ -125,12 +231,27 @@ func TestNagiosEnqueue_errors(t *testing.T) {
 				serviceKey:       "abc",
 				notificationType: "RECOVERY",
 				sourceType:       "host",
-				customFields: map[string]string{
-					"HOSTNAME": "computer.network",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME": {"computer.network"},
 				},
 			},
 			expectedError: errors.New("the HOSTSTATE field must be set for source-type \"host\" using the -f flag"),
 		},
+		{
+			name: "expandHostsWithIncidentKey",
+			inputs: nagiosEnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				incidentKey:      "someincidentkey",
+				expandHosts:      true,
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":  {"web1,web2"},
+					"HOSTSTATE": {"DOWN"},
+				},
+			},
+			expectedError: errExpandHostsKey,
+		},
 	}
 
 	for _, tt := range tests {
@@ -151,6 +272,7 @@ func TestNagiosEnqueue_errors(t *testing.T) {
 }
 
 func TestNagiosEnqueue_validInputs(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
 	tests := []struct {
 		name      string
 		cmdInputs nagiosEnqueueInput
@@ -161,9 +283,9 @@ func TestNagiosEnqueue_validInputs(t *testing.T) {
 				serviceKey:       "xyz",
 				notificationType: "PROBLEM",
 				sourceType:       "host",
-				customFields: map[string]string{
-					"HOSTNAME":  "computer.network",
-					"HOSTSTATE": "down",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":  {"computer.network"},
+					"HOSTSTATE": {"down"},
 				},
 			},
 		},
@@ -173,10 +295,10 @@ func TestNagiosEnqueue_validInputs(t *testing.T) {
 				serviceKey:       "xyz",
 				notificationType: "PROBLEM",
 				sourceType:       "service",
-				customFields: map[string]string{
-					"HOSTNAME":     "computer.network",
-					"SERVICESTATE": "down",
-					"SERVICEDESC":  "serviceA",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":     {"computer.network"},
+					"SERVICESTATE": {"down"},
+					"SERVICEDESC":  {"serviceA"},
 				},
 			},
 		},
@@ -187,10 +309,42 @@ func TestNagiosEnqueue_validInputs(t *testing.T) {
 				notificationType: "PROBLEM",
 				sourceType:       "service",
 				incidentKey:      "someincidentkey",
-				customFields: map[string]string{
-					"HOSTNAME":     "computer.network",
-					"SERVICESTATE": "down",
-					"SERVICEDESC":  "serviceA",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":     {"computer.network"},
+					"SERVICESTATE": {"down"},
+					"SERVICEDESC":  {"serviceA"},
+				},
+			},
+		},
+		{
+			name: "userProvidedDedupKey",
+			cmdInputs: nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "service",
+				incidentKey:      "someincidentkey",
+				dedupKey:         "somededupkey",
+				eventsAPIVersion: "v2",
+				severity:         "error",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":     {"computer.network"},
+					"SERVICESTATE": {"down"},
+					"SERVICEDESC":  {"serviceA"},
+				},
+			},
+		},
+		{
+			name: "validV2SourceServiceInput",
+			cmdInputs: nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "service",
+				eventsAPIVersion: "v2",
+				severity:         "critical",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":     {"computer.network"},
+					"SERVICESTATE": {"down"},
+					"SERVICEDESC":  {"serviceA"},
 				},
 			},
 		},
@@ -214,15 +368,12 @@ func TestNagiosEnqueue_validInputs(t *testing.T) {
 			cmd := NewNagiosEnqueueCmd(realConfig)
 			cmd.SetArgs(buildCmdArgs(tt.cmdInputs))
 
-			incidentKey := tt.cmdInputs.incidentKey
-			if incidentKey == "" {
-				incidentKey = buildIncidentKey(tt.cmdInputs)
-			}
+			incidentKey := resolveIncidentKey(tt.cmdInputs)
 
-			customDetails := map[string]string{
+			customDetails := map[string]interface{}{
 				"pd_nagios_object": tt.cmdInputs.sourceType,
 			}
-			for k, v := range tt.cmdInputs.customFields {
+			for k, v := range tt.cmdInputs.customFields.Details(nil) {
 				customDetails[k] = v
 			}
 
@@ -231,8 +382,23 @@ func TestNagiosEnqueue_validInputs(t *testing.T) {
 				"event_type":   nagiosToPagerDutyEventType[tt.cmdInputs.notificationType],
 				"incident_key": incidentKey,
 				"description":  buildEventDescription(tt.cmdInputs),
+				"client":       cmdutil.DefaultClient(),
 				"details":      customDetails,
 			}
+			if tt.cmdInputs.eventsAPIVersion == "v2" {
+				expectedRequestBody = map[string]interface{}{
+					"routing_key":  tt.cmdInputs.serviceKey,
+					"event_action": nagiosToPagerDutyEventType[tt.cmdInputs.notificationType],
+					"dedup_key":    incidentKey,
+					"client":       cmdutil.DefaultClient(),
+					"payload": map[string]interface{}{
+						"summary":        buildEventDescription(tt.cmdInputs),
+						"source":         tt.cmdInputs.customFields.Get("HOSTNAME"),
+						"severity":       tt.cmdInputs.severity,
+						"custom_details": customDetails,
+					},
+				}
+			}
 
 			gock.New(cmdutil.GetDefaults().Address).
 				Post("/send").JSON(expectedRequestBody).
@@ -253,3 +419,1703 @@ func TestNagiosEnqueue_validInputs(t *testing.T) {
 		})
 	}
 }
+
+func TestNagiosEnqueue_dedupKey(t *testing.T) {
+	hostFields := cmdutil.CustomFields{
+		"HOSTNAME":  {"computer.network"},
+		"HOSTSTATE": {"DOWN"},
+	}
+	serviceFields := cmdutil.CustomFields{
+		"HOSTNAME":     {"computer.network"},
+		"SERVICEDESC":  {"serviceA"},
+		"SERVICESTATE": {"CRITICAL"},
+	}
+
+	tests := []struct {
+		name             string
+		cmdInputs        nagiosEnqueueInput
+		expectedDedupKey string
+	}{
+		{
+			name: "dedupKeyOverride",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "PROBLEM",
+				sourceType:       "service",
+				incidentKey:      "someincidentkey",
+				dedupKey:         "somededupkey",
+				customFields:     serviceFields,
+			},
+			expectedDedupKey: "somededupkey",
+		},
+		{
+			name: "incidentKeyFallback",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "PROBLEM",
+				sourceType:       "service",
+				incidentKey:      "someincidentkey",
+				customFields:     serviceFields,
+			},
+			expectedDedupKey: "someincidentkey",
+		},
+		{
+			name: "derivedHostKey",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				customFields:     hostFields,
+			},
+			expectedDedupKey: "event_source=host;host_name=computer.network",
+		},
+		{
+			name: "derivedServiceKey",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "PROBLEM",
+				sourceType:       "service",
+				customFields:     serviceFields,
+			},
+			expectedDedupKey: "event_source=service;host_name=computer.network;service_desc=serviceA",
+		},
+		{
+			name: "derivedServiceKeyStableAcrossRecovery",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "RECOVERY",
+				sourceType:       "service",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":     {"computer.network"},
+					"SERVICEDESC":  {"serviceA"},
+					"SERVICESTATE": {"OK"},
+				},
+			},
+			expectedDedupKey: "event_source=service;host_name=computer.network;service_desc=serviceA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cmdInputs.serviceKey = "xyz"
+			tt.cmdInputs.eventsAPIVersion = "v2"
+			tt.cmdInputs.severity = "error"
+
+			sendEvent := buildSendEvent(tt.cmdInputs)
+
+			assert.Equal(t, tt.expectedDedupKey, sendEvent.(*eventsapi.EventV2).DedupKey)
+		})
+	}
+}
+
+func TestNagiosEnqueue_acknowledgementIncidentKey(t *testing.T) {
+	for _, sourceType := range []string{"host", "service"} {
+		t.Run(sourceType, func(t *testing.T) {
+			var keys []string
+			for _, notificationType := range []string{"PROBLEM", "ACKNOWLEDGEMENT", "FLAPPINGSTART", "FLAPPINGSTOP"} {
+				cmdInputs := nagiosEnqueueInput{
+					serviceKey:       "xyz",
+					notificationType: notificationType,
+					sourceType:       sourceType,
+					eventsAPIVersion: "v2",
+					customFields: cmdutil.CustomFields{
+						"HOSTNAME":     {"computer.network"},
+						"HOSTSTATE":    {"DOWN"},
+						"SERVICEDESC":  {"serviceA"},
+						"SERVICESTATE": {"CRITICAL"},
+					},
+				}
+				keys = append(keys, buildSendEvent(cmdInputs).(*eventsapi.EventV2).DedupKey)
+			}
+
+			for _, key := range keys[1:] {
+				assert.Equal(t, keys[0], key)
+			}
+		})
+	}
+}
+
+func TestNagiosEnqueue_acknowledgementRequiresKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		cmdInputs     nagiosEnqueueInput
+		expectedError error
+		expectedKey   string
+	}{
+		{
+			name: "emptyHostname",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "ACKNOWLEDGEMENT",
+				customFields:     cmdutil.CustomFields{"HOSTNAME": {""}, "HOSTSTATE": {"DOWN"}},
+			},
+			expectedError: errAcknowledgeKey,
+		},
+		{
+			name: "emptyHostnameWithIncidentKey",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "ACKNOWLEDGEMENT",
+				incidentKey:      "someincidentkey",
+				customFields:     cmdutil.CustomFields{"HOSTNAME": {""}, "HOSTSTATE": {"DOWN"}},
+			},
+			expectedKey: "someincidentkey",
+		},
+		{
+			name: "emptyHostnameWithDedupKey",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "ACKNOWLEDGEMENT",
+				dedupKey:         "somededupkey",
+				customFields:     cmdutil.CustomFields{"HOSTNAME": {""}, "HOSTSTATE": {"DOWN"}},
+			},
+			expectedKey: "somededupkey",
+		},
+		{
+			name: "derivedKey",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "ACKNOWLEDGEMENT",
+				customFields:     cmdutil.CustomFields{"HOSTNAME": {"computer.network"}, "HOSTSTATE": {"DOWN"}},
+			},
+			expectedKey: "event_source=host;host_name=computer.network",
+		},
+		{
+			name: "triggerWithEmptyHostname",
+			cmdInputs: nagiosEnqueueInput{
+				notificationType: "PROBLEM",
+				customFields:     cmdutil.CustomFields{"HOSTNAME": {""}, "HOSTSTATE": {"DOWN"}},
+			},
+			expectedKey: "event_source=host;host_name=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			tt.cmdInputs.serviceKey = "xyz"
+			tt.cmdInputs.sourceType = "host"
+			tt.cmdInputs.eventsAPIVersion = "v2"
+			tt.cmdInputs.dryRun = true
+
+			cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+			cmd.SetArgs(buildCmdArgs(tt.cmdInputs))
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			var printedEvent map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+			assert.Equal(t, nagiosToPagerDutyEventType[tt.cmdInputs.notificationType], printedEvent["event_action"])
+			assert.Equal(t, tt.expectedKey, printedEvent["dedup_key"])
+		})
+	}
+}
+
+func TestNagiosEnqueue_severityMapping(t *testing.T) {
+	tests := []struct {
+		sourceType       string
+		state            string
+		notificationType string
+		expectedSeverity string
+		expectedAction   string
+	}{
+		{"host", "DOWN", "PROBLEM", "critical", "trigger"},
+		{"host", "UNREACHABLE", "PROBLEM", "error", "trigger"},
+		{"host", "UP", "RECOVERY", "info", "resolve"},
+		{"host", "down", "ACKNOWLEDGEMENT", "critical", "acknowledge"},
+		{"service", "CRITICAL", "PROBLEM", "critical", "trigger"},
+		{"service", "WARNING", "PROBLEM", "warning", "trigger"},
+		{"service", "UNKNOWN", "PROBLEM", "error", "trigger"},
+		{"service", "OK", "RECOVERY", "info", "resolve"},
+		{"service", "OK", "PROBLEM", "info", "resolve"},
+		{"service", "PENDING", "PROBLEM", "error", "trigger"},
+		{"service", "CRITICAL", "FLAPPINGSTART", "critical", "trigger"},
+		{"service", "CRITICAL", "FLAPPINGSTOP", "critical", "trigger"},
+		{"service", "OK", "FLAPPINGSTOP", "info", "resolve"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sourceType+"_"+tt.state+"_"+tt.notificationType, func(t *testing.T) {
+			cmdInputs := nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: tt.notificationType,
+				sourceType:       tt.sourceType,
+				eventsAPIVersion: "v2",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":    {"computer.network"},
+					"SERVICEDESC": {"serviceA"},
+				},
+			}
+			if tt.sourceType == "host" {
+				cmdInputs.customFields["HOSTSTATE"] = []string{tt.state}
+			} else {
+				cmdInputs.customFields["SERVICESTATE"] = []string{tt.state}
+			}
+
+			sendEvent := buildSendEvent(cmdInputs).(*eventsapi.EventV2)
+
+			assert.Equal(t, tt.expectedSeverity, sendEvent.Payload.Severity)
+			assert.Equal(t, tt.expectedAction, sendEvent.EventAction)
+		})
+	}
+}
+
+func TestNagiosEnqueue_severityOverride(t *testing.T) {
+	for _, flag := range []string{"--severity-override", "-e"} {
+		t.Run(flag, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+			cmd.SetArgs([]string{
+				"-k", "xyz", "-t", "PROBLEM", "-n", "service", "--events-api-version", "v2", "--dry-run",
+				"-f", "HOSTNAME=computer.network", "-f", "SERVICEDESC=serviceA", "-f", "SERVICESTATE=CRITICAL",
+				flag, "warning",
+			})
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+			assert.NoError(t, err)
+
+			var printedEvent eventsapi.EventV2
+			assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+			assert.Equal(t, "warning", printedEvent.Payload.Severity)
+		})
+	}
+}
+
+func TestNagiosEnqueue_sourceOverride(t *testing.T) {
+	fields := cmdutil.CustomFields{
+		"HOSTNAME":     {"computer.network"},
+		"SERVICEDESC":  {"serviceA"},
+		"SERVICESTATE": {"CRITICAL"},
+	}
+
+	tests := []struct {
+		name             string
+		eventsAPIVersion string
+		source           string
+		expectedField    string
+		expectedSource   interface{}
+	}{
+		{"v2Override", "v2", "nagios-east", "source", "nagios-east"},
+		{"v2Default", "v2", "", "source", "computer.network"},
+		{"v1Override", "v1", "nagios-east", "client", "nagios-east"},
+		{"v1Default", "v1", "", "client", cmdutil.DefaultClient()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewNagiosEnqueueCmd(realConfig)
+			cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "service",
+				eventsAPIVersion: tt.eventsAPIVersion,
+				source:           tt.source,
+				customFields:     fields,
+			}))
+
+			var posted map[string]interface{}
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").
+				AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+					return true, json.NewDecoder(req.Body).Decode(&posted)
+				}).
+				Reply(200).JSON(map[string]interface{}{"key": "xyz"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			_, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+			assert.NoError(t, err)
+
+			event := posted
+			if tt.eventsAPIVersion == "v2" {
+				event, _ = posted["payload"].(map[string]interface{})
+			}
+			assert.Equal(t, tt.expectedSource, event[tt.expectedField])
+		})
+	}
+}
+
+func TestNagiosEnqueue_groupAndClass(t *testing.T) {
+	tests := []struct {
+		name          string
+		sourceType    string
+		group         string
+		class         string
+		fields        cmdutil.CustomFields
+		expectedGroup interface{}
+		expectedClass interface{}
+	}{
+		{"flags", "service", "web", "http", cmdutil.CustomFields{"SERVICEGROUP": {"db"}}, "web", "http"},
+		{"serviceFields", "service", "", "", cmdutil.CustomFields{"SERVICEGROUP": {"db"}, "HOSTGROUP": {"linux"}, "SERVICECHECKCOMMAND": {"check_http!-p 8080"}}, "db", "check_http"},
+		{"serviceHostGroup", "service", "", "", cmdutil.CustomFields{"HOSTGROUP": {"linux"}}, "linux", nil},
+		{"hostFields", "host", "", "", cmdutil.CustomFields{"SERVICEGROUP": {"db"}, "HOSTGROUP": {"linux"}, "HOSTCHECKCOMMAND": {"check-host-alive"}}, "linux", "check-host-alive"},
+		{"absent", "service", "", "", cmdutil.CustomFields{}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			fields := cmdutil.CustomFields{
+				"HOSTNAME":     {"computer.network"},
+				"HOSTSTATE":    {"DOWN"},
+				"SERVICEDESC":  {"serviceA"},
+				"SERVICESTATE": {"CRITICAL"},
+			}
+			for k, v := range tt.fields {
+				fields[k] = v
+			}
+
+			cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+			cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       tt.sourceType,
+				eventsAPIVersion: "v2",
+				group:            tt.group,
+				class:            tt.class,
+				dryRun:           true,
+				customFields:     fields,
+			}))
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+			assert.NoError(t, err)
+
+			var printedEvent map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+			payload, _ := printedEvent["payload"].(map[string]interface{})
+
+			group, ok := payload["group"]
+			assert.Equal(t, tt.expectedGroup != nil, ok, "unexpected presence of group")
+			assert.Equal(t, tt.expectedGroup, group)
+
+			class, ok := payload["class"]
+			assert.Equal(t, tt.expectedClass != nil, ok, "unexpected presence of class")
+			assert.Equal(t, tt.expectedClass, class)
+		})
+	}
+}
+
+// setNagiosEnv sets environment variables, returning a function unsetting
+// them again.
+func setNagiosEnv(env map[string]string) func() {
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestNagiosEnqueue_fromEnv(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	serviceEnv := map[string]string{
+		"NAGIOS_NOTIFICATIONTYPE": "PROBLEM",
+		"NAGIOS_CONTACTPAGER":     "envkey",
+		"NAGIOS_HOSTNAME":         "computer.network",
+		"NAGIOS_SERVICEDESC":      "serviceA",
+		"NAGIOS_SERVICESTATE":     "CRITICAL",
+		"NAGIOS_SERVICEOUTPUT":    "",
+	}
+
+	tests := []struct {
+		name               string
+		env                map[string]string
+		inputs             nagiosEnqueueInput
+		expectedError      error
+		expectedRoutingKey string
+		expectedAction     string
+		expectedDedupKey   string
+		expectedDetails    map[string]interface{}
+	}{
+		{
+			name:               "service",
+			env:                serviceEnv,
+			expectedRoutingKey: "envkey",
+			expectedAction:     "trigger",
+			expectedDedupKey:   "event_source=service;host_name=computer.network;service_desc=serviceA",
+			expectedDetails: map[string]interface{}{
+				"HOSTNAME":         "computer.network",
+				"SERVICEDESC":      "serviceA",
+				"SERVICESTATE":     "CRITICAL",
+				"pd_nagios_object": "service",
+			},
+		},
+		{
+			name: "host",
+			env: map[string]string{
+				"NAGIOS_NOTIFICATIONTYPE": "PROBLEM",
+				"NAGIOS_CONTACTPAGER":     "envkey",
+				"NAGIOS_HOSTNAME":         "computer.network",
+				"NAGIOS_HOSTSTATE":        "DOWN",
+			},
+			expectedRoutingKey: "envkey",
+			expectedAction:     "trigger",
+			expectedDedupKey:   "event_source=host;host_name=computer.network",
+			expectedDetails: map[string]interface{}{
+				"HOSTNAME":         "computer.network",
+				"HOSTSTATE":        "DOWN",
+				"pd_nagios_object": "host",
+			},
+		},
+		{
+			name: "flagsOverrideEnv",
+			env:  serviceEnv,
+			inputs: nagiosEnqueueInput{
+				serviceKey:       "flagkey",
+				notificationType: "RECOVERY",
+				customFields:     cmdutil.CustomFields{"HOSTNAME": {"other.network"}},
+			},
+			expectedRoutingKey: "flagkey",
+			expectedAction:     "resolve",
+			expectedDedupKey:   "event_source=service;host_name=other.network;service_desc=serviceA",
+			expectedDetails: map[string]interface{}{
+				"HOSTNAME":         "other.network",
+				"SERVICEDESC":      "serviceA",
+				"SERVICESTATE":     "CRITICAL",
+				"pd_nagios_object": "service",
+			},
+		},
+		{
+			name: "missingField",
+			env: map[string]string{
+				"NAGIOS_NOTIFICATIONTYPE": "PROBLEM",
+				"NAGIOS_CONTACTPAGER":     "envkey",
+				"NAGIOS_HOSTNAME":         "computer.network",
+			},
+			expectedError: errors.New("the HOSTSTATE field must be set for source-type \"host\" using the -f flag"),
+		},
+		{
+			name: "invalidNotificationType",
+			env: map[string]string{
+				"NAGIOS_NOTIFICATIONTYPE": "trigger",
+				"NAGIOS_CONTACTPAGER":     "envkey",
+				"NAGIOS_HOSTNAME":         "computer.network",
+				"NAGIOS_HOSTSTATE":        "DOWN",
+			},
+			expectedError: errNotificationType,
+		},
+		{
+			name:          "missingEnv",
+			env:           map[string]string{},
+			expectedError: errors.New("required flag(s) \"notification-type\", \"source-type\" not set"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+			defer setNagiosEnv(tt.env)()
+
+			inputs := tt.inputs
+			inputs.eventsAPIVersion = "v2"
+			inputs.fromEnv = true
+			inputs.dryRun = true
+
+			cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+			cmd.SetArgs(buildCmdArgs(inputs))
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			var printedEvent eventsapi.EventV2
+			assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+			assert.Equal(t, tt.expectedRoutingKey, printedEvent.RoutingKey)
+			assert.Equal(t, tt.expectedAction, printedEvent.EventAction)
+			assert.Equal(t, tt.expectedDedupKey, printedEvent.DedupKey)
+			assert.Equal(t, tt.expectedDetails, printedEvent.Payload.CustomDetails)
+		})
+	}
+}
+
+func TestNagiosEnqueue_envIgnoredWithoutFromEnv(t *testing.T) {
+	test.InitConfigForIntegrationsTesting()
+	defer setNagiosEnv(map[string]string{
+		"NAGIOS_NOTIFICATIONTYPE": "PROBLEM",
+		"NAGIOS_HOSTNAME":         "computer.network",
+	})()
+
+	cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+	cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{}))
+
+	_, err := cmd.ExecuteC()
+	assert.Equal(t, errors.New("required flag(s) \"notification-type\", \"source-type\" not set"), err)
+}
+
+func TestNagiosEnqueue_wait(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        map[string]interface{}
+		waitTimeout   time.Duration
+		expectedOut   string
+		expectedError error
+	}{
+		{
+			name:        "confirmed",
+			status:      map[string]interface{}{"key": "xyz", "status": "success", "dedup_key": "returned-dedup-key"},
+			expectedOut: "returned-dedup-key\n",
+		},
+		{
+			name:          "failed",
+			status:        map[string]interface{}{"key": "xyz", "status": "error", "error": "invalid event"},
+			expectedError: errors.New("the agent failed to deliver event xyz: invalid event"),
+		},
+		{
+			name:          "invalidTimeout",
+			waitTimeout:   -time.Second,
+			expectedError: errWaitTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewNagiosEnqueueCmd(realConfig)
+			cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{
+				serviceKey:       "abc",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				wait:             true,
+				waitTimeout:      tt.waitTimeout,
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":  {"computer.network"},
+					"HOSTSTATE": {"DOWN"},
+				},
+			}))
+
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").
+				Reply(200).JSON(map[string]interface{}{"key": "xyz"})
+			gock.New(cmdutil.GetDefaults().Address).
+				Get("/queue/event").
+				MatchParam("key", "xyz").
+				Reply(200).JSON(tt.status)
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if tt.expectedError != nil {
+				assert.EqualError(t, err, tt.expectedError.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOut, out)
+		})
+	}
+}
+
+func TestNagiosEnqueue_client(t *testing.T) {
+	fields := cmdutil.CustomFields{
+		"HOSTNAME":  {"computer.network"},
+		"HOSTSTATE": {"DOWN"},
+	}
+
+	tests := []struct {
+		name              string
+		eventsAPIVersion  string
+		client            string
+		clientURL         string
+		expectedError     error
+		expectedClient    interface{}
+		expectedClientURL interface{}
+	}{
+		{"v2Client", "v2", "nagios-east", "https://nagios.example.com/nagios", nil, "nagios-east", "https://nagios.example.com/nagios"},
+		{"v2Default", "v2", "", "", nil, cmdutil.DefaultClient(), nil},
+		{"v1Client", "v1", "nagios-east", "https://nagios.example.com/nagios", nil, "nagios-east", "https://nagios.example.com/nagios"},
+		{"v1Default", "v1", "", "", nil, cmdutil.DefaultClient(), nil},
+		{"invalidClientURL", "v2", "", "nagios.example.com", cmdutil.ErrInvalidClientURL, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewNagiosEnqueueCmd(realConfig)
+			cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				eventsAPIVersion: tt.eventsAPIVersion,
+				client:           tt.client,
+				clientURL:        tt.clientURL,
+				customFields:     fields,
+			}))
+
+			var posted map[string]interface{}
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").
+				AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+					return true, json.NewDecoder(req.Body).Decode(&posted)
+				}).
+				Reply(200).JSON(map[string]interface{}{"key": "xyz"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			_, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.True(t, gock.IsPending(), "expected no request to be sent")
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedClient, posted["client"])
+			assert.Equal(t, tt.expectedClientURL, posted["client_url"])
+		})
+	}
+}
+
+func TestNagiosEnqueue_downtime(t *testing.T) {
+	tests := []struct {
+		name             string
+		cmdInputs        nagiosEnqueueInput
+		expectedError    error
+		expectedDropped  bool
+		expectedAction   string
+		expectedSeverity interface{}
+	}{
+		{
+			name:            "downtimeStartDropped",
+			cmdInputs:       nagiosEnqueueInput{notificationType: "DOWNTIMESTART"},
+			expectedDropped: true,
+		},
+		{
+			name:            "downtimeEndDropped",
+			cmdInputs:       nagiosEnqueueInput{notificationType: "DOWNTIMEEND"},
+			expectedDropped: true,
+		},
+		{
+			name:            "downtimeStartDroppedWhenResolvingOnEnd",
+			cmdInputs:       nagiosEnqueueInput{notificationType: "DOWNTIMESTART", resolveDowntimeEnd: true},
+			expectedDropped: true,
+		},
+		{
+			name:             "downtimeStartInfo",
+			cmdInputs:        nagiosEnqueueInput{notificationType: "DOWNTIMESTART", suppressDowntime: "info", eventsAPIVersion: "v2"},
+			expectedAction:   "trigger",
+			expectedSeverity: "info",
+		},
+		{
+			name:             "downtimeEndInfo",
+			cmdInputs:        nagiosEnqueueInput{notificationType: "DOWNTIMEEND", suppressDowntime: "info", eventsAPIVersion: "v2", severity: "critical"},
+			expectedAction:   "trigger",
+			expectedSeverity: "info",
+		},
+		{
+			name:             "downtimeEndResolves",
+			cmdInputs:        nagiosEnqueueInput{notificationType: "DOWNTIMEEND", resolveDowntimeEnd: true, eventsAPIVersion: "v2"},
+			expectedAction:   "resolve",
+			expectedSeverity: "critical",
+		},
+		{
+			name:           "downtimeEndResolvesV1",
+			cmdInputs:      nagiosEnqueueInput{notificationType: "DOWNTIMEEND", resolveDowntimeEnd: true, eventsAPIVersion: "v1"},
+			expectedAction: "resolve",
+		},
+		{
+			name:          "infoRequiresV2",
+			cmdInputs:     nagiosEnqueueInput{notificationType: "DOWNTIMESTART", suppressDowntime: "info", eventsAPIVersion: "v1"},
+			expectedError: errSuppressDowntimeV1,
+		},
+		{
+			name:          "invalidSuppression",
+			cmdInputs:     nagiosEnqueueInput{notificationType: "DOWNTIMESTART", suppressDowntime: "page"},
+			expectedError: errSuppressDowntime,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			tt.cmdInputs.serviceKey = "xyz"
+			tt.cmdInputs.sourceType = "host"
+			tt.cmdInputs.customFields = cmdutil.CustomFields{"HOSTNAME": {"computer.network"}, "HOSTSTATE": {"DOWN"}}
+
+			cmd := NewNagiosEnqueueCmd(realConfig)
+			cmd.SetArgs(buildCmdArgs(tt.cmdInputs))
+
+			var stderr bytes.Buffer
+			cmd.SetErr(&stderr)
+
+			var posted map[string]interface{}
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").
+				AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+					return true, json.NewDecoder(req.Body).Decode(&posted)
+				}).
+				Reply(200).JSON(map[string]interface{}{"key": "xyz"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			_, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			if tt.expectedDropped {
+				assert.Nil(t, posted, "expected no event to be sent")
+				assert.Contains(t, stderr.String(), "Dropping "+tt.cmdInputs.notificationType+" notification for event_source=host;host_name=computer.network")
+				return
+			}
+
+			if tt.cmdInputs.eventsAPIVersion == "v1" {
+				assert.Equal(t, tt.expectedAction, posted["event_type"])
+				return
+			}
+			assert.Equal(t, tt.expectedAction, posted["event_action"])
+			assert.Equal(t, "event_source=host;host_name=computer.network", posted["dedup_key"])
+			payload, _ := posted["payload"].(map[string]interface{})
+			assert.Equal(t, tt.expectedSeverity, payload["severity"])
+		})
+	}
+}
+
+func TestNagiosEnqueue_repeatedFields(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	test.InitConfigForIntegrationsTesting()
+
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewNagiosEnqueueCmd(realConfig)
+	cmd.SetArgs([]string{
+		"-k", "xyz",
+		"-t", "PROBLEM",
+		"-n", "service",
+		"-f", "HOSTNAME=old.network",
+		"-f", "HOSTNAME=computer.network",
+		"-f", "SERVICEDESC=serviceA",
+		"-f", "SERVICESTATE=down",
+		"-f", "NOTES=first note",
+		"-f", "NOTES=second, longer note",
+		"-f", "OWNER=ops",
+	})
+
+	cmdInputs := nagiosEnqueueInput{
+		sourceType: "service",
+		customFields: cmdutil.CustomFields{
+			"HOSTNAME":     {"computer.network"},
+			"SERVICEDESC":  {"serviceA"},
+			"SERVICESTATE": {"down"},
+		},
+	}
+
+	expectedRequestBody := map[string]interface{}{
+		"service_key":  "xyz",
+		"event_type":   "trigger",
+		"incident_key": buildIncidentKey(cmdInputs),
+		"description":  buildEventDescription(cmdInputs),
+		"client":       cmdutil.DefaultClient(),
+		"details": map[string]interface{}{
+			"HOSTNAME":         "computer.network",
+			"SERVICEDESC":      "serviceA",
+			"SERVICESTATE":     "down",
+			"NOTES":            []string{"first note", "second, longer note"},
+			"OWNER":            "ops",
+			"pd_nagios_object": "service",
+		},
+	}
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").JSON(expectedRequestBody).
+		Reply(200).JSON(map[string]interface{}{"key": "xyz"})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `enqueue`: %v", err)
+	}
+
+	assert.Contains(t, out, `{"key":"xyz"}`)
+}
+
+func TestNagiosEnqueue_repeatedFieldsValidation(t *testing.T) {
+	cmdInputs := nagiosEnqueueInput{
+		serviceKey:       "xyz",
+		notificationType: "PROBLEM",
+		sourceType:       "host",
+		eventsAPIVersion: "v1",
+		customFields: cmdutil.CustomFields{
+			"HOSTNAME":  {"old.network", "computer.network"},
+			"HOSTSTATE": {"UP", "DOWN"},
+		},
+	}
+
+	assert.NoError(t, validateNagiosSendCommand(cmdInputs))
+	assert.Equal(t, "HOSTNAME=computer.network; HOSTSTATE=DOWN", buildEventDescription(cmdInputs))
+	assert.Equal(t, "event_source=host;host_name=computer.network", buildIncidentKey(cmdInputs))
+}
+
+func TestNagiosEnqueue_dryRun(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	test.InitConfigForIntegrationsTesting()
+
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmdInputs := nagiosEnqueueInput{
+		serviceKey:       "xyz",
+		notificationType: "PROBLEM",
+		sourceType:       "host",
+		dryRun:           true,
+		customFields: cmdutil.CustomFields{
+			"HOSTNAME":  {"computer.network"},
+			"HOSTSTATE": {"down"},
+		},
+	}
+
+	cmd := NewNagiosEnqueueCmd(realConfig)
+	cmd.SetArgs(buildCmdArgs(cmdInputs))
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		Reply(200).JSON(map[string]interface{}{"key": "xyz"})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `enqueue`: %v", err)
+	}
+
+	assert.True(t, gock.IsPending(), "expected no request to be sent in dry-run mode")
+
+	var printedEvent map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+	assert.Equal(t, map[string]interface{}{
+		"service_key":  "xyz",
+		"event_type":   "trigger",
+		"incident_key": "event_source=host;host_name=computer.network",
+		"description":  "HOSTNAME=computer.network; HOSTSTATE=down",
+		"client":       cmdutil.DefaultClient(),
+		"details": map[string]interface{}{
+			"HOSTNAME":         "computer.network",
+			"HOSTSTATE":        "down",
+			"pd_nagios_object": "host",
+		},
+	}, printedEvent)
+}
+
+func TestNagiosEnqueue_agentMetadata(t *testing.T) {
+	test.InitConfigForIntegrationsTesting()
+	cmdInputs := nagiosEnqueueInput{
+		serviceKey:       "xyz",
+		notificationType: "PROBLEM",
+		sourceType:       "host",
+		dryRun:           true,
+		customFields: cmdutil.CustomFields{
+			"HOSTNAME":  {"computer.network"},
+			"HOSTSTATE": {"down"},
+		},
+	}
+
+	cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+	cmd.SetArgs(buildCmdArgs(cmdInputs))
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+	assert.NoError(t, err)
+
+	var printedEvent struct {
+		Details map[string]interface{} `json:"details"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+	assert.Equal(t, cmdutil.AgentHost(), printedEvent.Details[cmdutil.AgentHostDetail])
+	assert.Equal(t, float64(os.Getpid()), printedEvent.Details[cmdutil.AgentPIDDetail])
+	assert.Equal(t, "host", printedEvent.Details["pd_nagios_object"])
+}
+
+func TestNagiosEnqueue_strictKey(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"v1Valid", []string{"-k", "a1863b592c824bfc8989d9cba76abxyz"}, ""},
+		{"v1Malformed", []string{"-k", "a1863b592c824bfc"}, "service key a1****fc is 16 characters long, expected 32"},
+		{"v2Valid", []string{"-k", "11863b592c824bfc8989d9cba76abcde", "--events-api-version", "v2"}, ""},
+		{"v2Malformed", []string{"-k", "a1863b592c824bfc8989d9cba76abxyz", "--events-api-version", "v2"}, `routing key a1****yz may only contain hexadecimal characters, found 'x'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+			cmd.SetArgs(append([]string{"-t", "PROBLEM", "-n", "host", "-f", "HOSTNAME=computer.network", "-f", "HOSTSTATE=down", "--dry-run", "--strict-key"}, tt.args...))
+
+			_, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestNagiosEnqueue_incidentKeyTemplate(t *testing.T) {
+	serviceFields := cmdutil.CustomFields{
+		"HOSTNAME":     {"computer.network"},
+		"HOSTGROUP":    {"databases"},
+		"SERVICEDESC":  {"serviceA"},
+		"SERVICESTATE": {"CRITICAL"},
+	}
+
+	tests := []struct {
+		name          string
+		cmdInputs     nagiosEnqueueInput
+		expectedKey   string
+		expectedError string
+	}{
+		{
+			name: "customTemplate",
+			cmdInputs: nagiosEnqueueInput{
+				incidentKeyTemplate: "{{.sourceType}}:{{.HOSTNAME}}:{{.SERVICEDESC}}",
+			},
+			expectedKey: "service:computer.network:serviceA",
+		},
+		{
+			name: "hostgroupPrefixTemplate",
+			cmdInputs: nagiosEnqueueInput{
+				incidentKeyTemplate: "team-a/{{.HOSTGROUP}}/{{.HOSTNAME}}",
+			},
+			expectedKey: "team-a/databases/computer.network",
+		},
+		{
+			name: "explicitIncidentKeyPreferred",
+			cmdInputs: nagiosEnqueueInput{
+				incidentKey:         "someincidentkey",
+				incidentKeyTemplate: "{{.sourceType}}:{{.HOSTNAME}}",
+			},
+			expectedKey: "someincidentkey",
+		},
+		{
+			name: "malformedTemplate",
+			cmdInputs: nagiosEnqueueInput{
+				incidentKeyTemplate: "{{.sourceType}:{{.HOSTNAME}}",
+			},
+			expectedError: "incident-key-template is invalid",
+		},
+		{
+			name: "missingField",
+			cmdInputs: nagiosEnqueueInput{
+				incidentKeyTemplate: "{{.HOSTNAME}}:{{.SERVICEGROUP}}",
+			},
+			expectedError: "incident-key-template could not be evaluated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			tt.cmdInputs.serviceKey = "xyz"
+			tt.cmdInputs.notificationType = "PROBLEM"
+			tt.cmdInputs.sourceType = "service"
+			tt.cmdInputs.dryRun = true
+			tt.cmdInputs.customFields = serviceFields
+
+			cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+			cmd.SetArgs(buildCmdArgs(tt.cmdInputs))
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			var printedEvent map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+			assert.Equal(t, tt.expectedKey, printedEvent["incident_key"])
+		})
+	}
+}
+
+func TestNagiosEnqueue_linksAndImages(t *testing.T) {
+	links := []string{"https://nagios.example.com/host/computer.network,Nagios", "", " ,empty", "https://wiki.example.com"}
+	images := []string{"https://graphs.example.com/cpu.png,https://graphs.example.com/cpu,CPU", ""}
+
+	tests := []struct {
+		name          string
+		version       string
+		expectedField string
+		expected      []interface{}
+	}{
+		{
+			name:          "v2",
+			version:       "v2",
+			expectedField: "links",
+			expected: []interface{}{
+				map[string]interface{}{"href": "https://nagios.example.com/host/computer.network", "text": "Nagios"},
+				map[string]interface{}{"href": "https://wiki.example.com", "text": ""},
+			},
+		},
+		{
+			name:          "v2Images",
+			version:       "v2",
+			expectedField: "images",
+			expected: []interface{}{
+				map[string]interface{}{"src": "https://graphs.example.com/cpu.png", "href": "https://graphs.example.com/cpu", "alt": "CPU"},
+			},
+		},
+		{
+			name:          "v1Contexts",
+			version:       "v1",
+			expectedField: "contexts",
+			expected: []interface{}{
+				map[string]interface{}{"type": "link", "href": "https://nagios.example.com/host/computer.network", "text": "Nagios"},
+				map[string]interface{}{"type": "link", "href": "https://wiki.example.com"},
+				map[string]interface{}{"type": "image", "src": "https://graphs.example.com/cpu.png", "href": "https://graphs.example.com/cpu", "alt": "CPU"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{
+				Timeout: 5 * time.Minute,
+			}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmdInputs := nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				eventsAPIVersion: tt.version,
+				severity:         "error",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":  {"computer.network"},
+					"HOSTSTATE": {"DOWN"},
+				},
+				links:  links,
+				images: images,
+			}
+
+			var body map[string]interface{}
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").
+				AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+					return true, json.NewDecoder(req.Body).Decode(&body)
+				}).
+				Reply(200).JSON(map[string]interface{}{"key": "abc"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			cmd := NewNagiosEnqueueCmd(realConfig)
+			cmd.SetArgs(buildCmdArgs(cmdInputs))
+
+			_, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, body[tt.expectedField])
+		})
+	}
+}
+
+func TestNagiosEnqueue_invalidLinksAndImages(t *testing.T) {
+	tests := []struct {
+		name          string
+		links         []string
+		images        []string
+		expectedError string
+	}{
+		{
+			name:          "relativeLink",
+			links:         []string{"/nagios/host,Nagios"},
+			expectedError: `link "/nagios/host" must be an absolute URL`,
+		},
+		{
+			name:          "unparseableImage",
+			images:        []string{"https://graphs.example.com/%zz.png"},
+			expectedError: `image "https://graphs.example.com/%zz.png" must be an absolute URL`,
+		},
+		{
+			name:          "invalidImageHref",
+			images:        []string{"https://graphs.example.com/cpu.png,graphs"},
+			expectedError: `image href "graphs" must be an absolute URL`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			cmdInputs := nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				dryRun:           true,
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":  {"computer.network"},
+					"HOSTSTATE": {"DOWN"},
+				},
+				links:  tt.links,
+				images: tt.images,
+			}
+
+			cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+			cmd.SetArgs(buildCmdArgs(cmdInputs))
+
+			_, err := cmd.ExecuteC()
+
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestNagiosEnqueue_verbose(t *testing.T) {
+	test.InitConfigForIntegrationsTesting()
+
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	serviceKey := "11863b592c824bfc8989d9cba76abcde"
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		Reply(200).JSON(map[string]interface{}{"key": "abc"})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	cmd := NewNagiosEnqueueCmd(realConfig)
+	cmd.SetArgs([]string{
+		"-k", serviceKey, "-t", "PROBLEM", "-n", "host", "-v",
+		"-f", "HOSTNAME=computer.network", "-f", "HOSTSTATE=DOWN",
+	})
+
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Equal(t, `{"key":"abc"}`, strings.TrimSpace(out))
+
+	verbose := stderr.String()
+	assert.NotContains(t, verbose, serviceKey)
+	assert.Contains(t, verbose, "--service-key=11****de")
+	assert.Contains(t, verbose, "Incident key: event_source=host;host_name=computer.network")
+	assert.Contains(t, verbose, `"service_key": "11****de"`)
+	assert.Contains(t, verbose, `"description": "HOSTNAME=computer.network; HOSTSTATE=DOWN"`)
+}
+
+func TestNagiosEnqueue_keyName(t *testing.T) {
+	test.InitConfigForIntegrationsTesting()
+	viper.Set("keys", map[string]string{"db": "11863b592c824bfc8989d9cba76abcde"})
+	defer viper.Set("keys", nil)
+
+	tests := []struct {
+		name        string
+		serviceKey  string
+		expectedKey string
+	}{
+		{"resolvedByName", "", "11863b592c824bfc8989d9cba76abcde"},
+		{"literalPrecedence", "xyz", "xyz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+			cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{
+				serviceKey:       tt.serviceKey,
+				keyName:          "db",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				dryRun:           true,
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":  {"computer.network"},
+					"HOSTSTATE": {"DOWN"},
+				},
+			}))
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+			assert.NoError(t, err)
+
+			var printedEvent map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+			assert.Equal(t, tt.expectedKey, printedEvent["service_key"])
+		})
+	}
+}
+
+func TestNagiosEnqueue_agentOverloaded(t *testing.T) {
+	test.InitConfigForIntegrationsTesting()
+
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewNagiosEnqueueCmd(realConfig)
+	cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{
+		serviceKey:       "xyz",
+		notificationType: "PROBLEM",
+		sourceType:       "host",
+		customFields: cmdutil.CustomFields{
+			"HOSTNAME":  {"computer.network"},
+			"HOSTSTATE": {"DOWN"},
+		},
+	}))
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		Persist().
+		Reply(429).
+		SetHeader("Retry-After", "0").
+		JSON(map[string]interface{}{"errors": []string{"Too many events, retry after 1 seconds."}})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	_, err := cmd.ExecuteC()
+	assert.Equal(t, cmdutil.ErrAgentOverloaded, err)
+}
+
+func TestNagiosEnqueue_requireAgent(t *testing.T) {
+	tests := []struct {
+		name           string
+		requireAgent   bool
+		mockHealthz    func(*gock.Request)
+		expectedError  bool
+		expectedSent   bool
+		expectedHealth bool
+	}{
+		{
+			name:           "reachable",
+			requireAgent:   true,
+			mockHealthz:    func(r *gock.Request) { r.Reply(200).BodyString("OK") },
+			expectedSent:   true,
+			expectedHealth: true,
+		},
+		{
+			name:           "unreachable",
+			requireAgent:   true,
+			mockHealthz:    func(r *gock.Request) { r.ReplyError(errors.New("connection refused")) },
+			expectedError:  true,
+			expectedHealth: true,
+		},
+		{
+			name:           "unhealthy",
+			requireAgent:   true,
+			mockHealthz:    func(r *gock.Request) { r.Reply(503) },
+			expectedError:  true,
+			expectedHealth: true,
+		},
+		{
+			name:         "notRequired",
+			mockHealthz:  func(r *gock.Request) { r.ReplyError(errors.New("connection refused")) },
+			expectedSent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			args := buildCmdArgs(nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "host",
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":  {"computer.network"},
+					"HOSTSTATE": {"DOWN"},
+				},
+			})
+			if tt.requireAgent {
+				args = append(args, "--require-agent")
+			}
+
+			cmd := NewNagiosEnqueueCmd(realConfig)
+			cmd.SetArgs(args)
+
+			healthz := gock.New(cmdutil.GetDefaults().Address).Get("/healthz")
+			tt.mockHealthz(healthz)
+			send := gock.New(cmdutil.GetDefaults().Address).Post("/send")
+			send.Reply(200).JSON(map[string]interface{}{"key": "abc"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			_, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if tt.expectedError {
+				assert.True(t, errors.Is(err, cmdutil.ErrAgentUnreachable), "expected ErrAgentUnreachable, got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedHealth, healthz.Mock.Done(), "health checked")
+			assert.Equal(t, tt.expectedSent, send.Mock.Done(), "event sent")
+		})
+	}
+}
+
+func TestNagiosEnqueue_customDetailsNamespacing(t *testing.T) {
+	fields := cmdutil.CustomFields{
+		"HOSTNAME":                {"computer.network"},
+		"HOSTSTATE":               {"DOWN"},
+		"pd_nagios_object":        {"user value"},
+		"custom_pd_nagios_object": {"other user value"},
+	}
+
+	tests := []struct {
+		name     string
+		group    bool
+		expected map[string]interface{}
+	}{
+		{
+			name: "flatCollisionPrefixed",
+			expected: map[string]interface{}{
+				"pd_nagios_object":               "host",
+				"HOSTNAME":                       "computer.network",
+				"HOSTSTATE":                      "DOWN",
+				"custom_pd_nagios_object":        "other user value",
+				"custom_custom_pd_nagios_object": "user value",
+			},
+		},
+		{
+			name:  "grouped",
+			group: true,
+			expected: map[string]interface{}{
+				"pd_nagios_object": "host",
+				"custom": map[string]interface{}{
+					"HOSTNAME":                "computer.network",
+					"HOSTSTATE":               "DOWN",
+					"pd_nagios_object":        "user value",
+					"custom_pd_nagios_object": "other user value",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sendEvent := buildSendEvent(nagiosEnqueueInput{
+				serviceKey:         "xyz",
+				notificationType:   "PROBLEM",
+				sourceType:         "host",
+				eventsAPIVersion:   "v2",
+				groupCustomDetails: tt.group,
+				customFields:       fields,
+			})
+
+			assert.Equal(t, tt.expected, sendEvent.(*eventsapi.EventV2).Payload.CustomDetails)
+		})
+	}
+}
+
+func TestNagiosEnqueue_groupCustomDetailsFlag(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	test.InitConfigForIntegrationsTesting()
+
+	cmd := NewNagiosEnqueueCmd(cmdutil.NewConfig())
+	cmd.SetArgs([]string{
+		"-k", "xyz", "-t", "PROBLEM", "-n", "host", "--dry-run", "--group-custom-details",
+		"-f", "HOSTNAME=computer.network", "-f", "HOSTSTATE=DOWN",
+	})
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+	assert.NoError(t, err)
+
+	var printedEvent map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(out), &printedEvent))
+	assert.Equal(t, map[string]interface{}{
+		"pd_nagios_object": "host",
+		"custom":           map[string]interface{}{"HOSTNAME": "computer.network", "HOSTSTATE": "DOWN"},
+	}, printedEvent["details"])
+}
+
+func TestNagiosEnqueue_defaultDetails(t *testing.T) {
+	viper.Set("default-details", map[string]interface{}{
+		"nagios":  map[string]string{"datacenter": "us-east-1", "environment": "production", "pd_nagios_object": "default value"},
+		"icinga2": map[string]string{"datacenter": "eu-west-1"},
+	})
+	defer viper.Set("default-details", nil)
+
+	sendEvent := buildSendEvent(nagiosEnqueueInput{
+		serviceKey:       "xyz",
+		notificationType: "PROBLEM",
+		sourceType:       "host",
+		eventsAPIVersion: "v2",
+		customFields: cmdutil.CustomFields{
+			"HOSTNAME":    {"computer.network"},
+			"HOSTSTATE":   {"DOWN"},
+			"environment": {"staging"},
+		},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"pd_nagios_object":        "host",
+		"HOSTNAME":                "computer.network",
+		"HOSTSTATE":               "DOWN",
+		"datacenter":              "us-east-1",
+		"environment":             "staging",
+		"custom_pd_nagios_object": "default value",
+	}, sendEvent.(*eventsapi.EventV2).Payload.CustomDetails)
+}
+
+func TestNagiosEnqueue_userAgent(t *testing.T) {
+	test.InitConfigForIntegrationsTesting()
+
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewNagiosEnqueueCmd(realConfig)
+	cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{
+		serviceKey:       "xyz",
+		notificationType: "PROBLEM",
+		sourceType:       "host",
+		customFields: cmdutil.CustomFields{
+			"HOSTNAME":  {"computer.network"},
+			"HOSTSTATE": {"down"},
+		},
+	}))
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		MatchHeader("Pd-Integration", "^nagios$").
+		MatchHeader("User-Agent", `^go-pdagent/.* \(nagios; `).
+		Reply(200).JSON(map[string]interface{}{"key": "xyz"})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	_, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestNagiosEnqueue_expandHosts(t *testing.T) {
+	tests := []struct {
+		name         string
+		expandHosts  bool
+		hostname     string
+		incidentKeys []string
+	}{
+		{
+			name:        "expanded",
+			expandHosts: true,
+			hostname:    "web1, web2,,web3",
+			incidentKeys: []string{
+				"event_source=service;host_name=web1;service_desc=HTTP",
+				"event_source=service;host_name=web2;service_desc=HTTP",
+				"event_source=service;host_name=web3;service_desc=HTTP",
+			},
+		},
+		{
+			name:         "notExpandedByDefault",
+			hostname:     "web1,web2",
+			incidentKeys: []string{"event_source=service;host_name=web1,web2;service_desc=HTTP"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewNagiosEnqueueCmd(realConfig)
+			cmd.SetArgs(buildCmdArgs(nagiosEnqueueInput{
+				serviceKey:       "xyz",
+				notificationType: "PROBLEM",
+				sourceType:       "service",
+				expandHosts:      tt.expandHosts,
+				customFields: cmdutil.CustomFields{
+					"HOSTNAME":     {tt.hostname},
+					"SERVICEDESC":  {"HTTP"},
+					"SERVICESTATE": {"CRITICAL"},
+				},
+			}))
+
+			// One event per host, each matched by its own incident key.
+			for _, incidentKey := range tt.incidentKeys {
+				gock.New(cmdutil.GetDefaults().Address).
+					Post("/send").
+					BodyString(`"incident_key":"` + regexp.QuoteMeta(incidentKey) + `"`).
+					Reply(200).JSON(map[string]interface{}{"key": "xyz"})
+			}
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			_, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			assert.NoError(t, err)
+			assert.True(t, gock.IsDone(), "Expected an event for each of %v.", tt.incidentKeys)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package nagios
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// nagiosEnvPrefix prefixes the macros Nagios exports to notification commands
+// with `enable_environment_macros`.
+const nagiosEnvPrefix = "NAGIOS_"
+
+// nagiosEnvFlags maps macros to the flags they set with --from-env.
+var nagiosEnvFlags = map[string]string{
+	"NOTIFICATIONTYPE": "notification-type",
+	"CONTACTPAGER":     "service-key",
+}
+
+// applyNagiosEnv sets flags and fields from the Nagios macros in `environ`,
+// as returned by `os.Environ`, leaving any set on the command line as they
+// are.
+//
+// The source type is "service" if a SERVICEDESC is set, otherwise "host", and
+// the contact's pager is used as the service key unless a key name is given.
+// Every other non-empty macro, e.g. NAGIOS_HOSTNAME, becomes a field named
+// without its prefix.
+func applyNagiosEnv(flags *pflag.FlagSet, cmdInput *nagiosEnqueueInput, environ []string) error {
+	macros := parseNagiosEnv(environ)
+
+	if flags.Changed("key-name") {
+		delete(macros, "CONTACTPAGER")
+	}
+
+	for name, value := range macros {
+		if flag, ok := nagiosEnvFlags[name]; ok {
+			if !flags.Changed(flag) {
+				if err := flags.Set(flag, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, ok := cmdInput.customFields[name]; !ok {
+			cmdInput.customFields[name] = []string{value}
+		}
+	}
+
+	if !flags.Changed("source-type") && macros["HOSTNAME"] != "" {
+		sourceType := "host"
+		if macros["SERVICEDESC"] != "" {
+			sourceType = "service"
+		}
+		return flags.Set("source-type", sourceType)
+	}
+	return nil
+}
+
+// parseNagiosEnv returns the non-empty Nagios macros in `environ` by name,
+// without their prefix.
+func parseNagiosEnv(environ []string) map[string]string {
+	macros := map[string]string{}
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[1] == "" || !strings.HasPrefix(parts[0], nagiosEnvPrefix) {
+			continue
+		}
+		macros[strings.TrimPrefix(parts[0], nagiosEnvPrefix)] = parts[1]
+	}
+	return macros
+}
@@ -0,0 +1,250 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package zabbix
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/cobra"
+)
+
+type zabbixEnqueueInput struct {
+	routingKey       string
+	keyName          string
+	recipient        string
+	subject          string
+	message          string
+	eventsAPIVersion string
+	dryRun           bool
+	verbose          bool
+	strictKey        bool
+	autoResolveAfter time.Duration
+}
+
+// zabbixMessage is a parsed Zabbix alert message, consisting of `key:value`
+// lines with keys lowercased.
+type zabbixMessage map[string]string
+
+var allowedStatuses = []string{"PROBLEM", "OK"}
+var allowedEventsAPIVersions = []string{eventsapi.EventVersion1.String(), eventsapi.EventVersion2.String()}
+
+var errStatus = fmt.Errorf("status must be one of: %v", strings.Join(allowedStatuses, ", "))
+var errEventsAPIVersion = fmt.Errorf("events-api-version must be one of: %v", strings.Join(allowedEventsAPIVersions, ", "))
+var errRoutingKey = fmt.Errorf("a routing key must be set using the -k or --key-name flags or as the recipient")
+
+var requiredFields = []string{"event_id", "status", "hostname"}
+
+var zabbixToPagerDutyEventType = map[string]string{
+	"PROBLEM": "trigger",
+	"OK":      "resolve",
+}
+
+var zabbixToPagerDutySeverity = map[string]string{
+	"not classified": "info",
+	"information":    "info",
+	"warning":        "warning",
+	"average":        "error",
+	"high":           "error",
+	"disaster":       "critical",
+}
+
+const exampleMessage = `name:{TRIGGER.NAME}
+event_id:{EVENT.ID}
+status:{TRIGGER.STATUS}
+severity:{TRIGGER.SEVERITY}
+hostname:{HOST.NAME}`
+
+func NewZabbixEnqueueCmd(config *cmdutil.Config) *cobra.Command {
+	var cmdInput zabbixEnqueueInput
+
+	cmd := &cobra.Command{
+		Use:   "enqueue RECIPIENT SUBJECT MESSAGE",
+		Short: "Enqueue an event from Zabbix to PagerDuty.",
+		Long: fmt.Sprintf(`Enqueue an event from Zabbix to PagerDuty.
+
+	Intended to be called by a Zabbix script media type with the {ALERT.SENDTO},
+	{ALERT.SUBJECT}, and {ALERT.MESSAGE} parameters. The subject is used as the
+	event description and the message must consist of "key:value" lines
+	including: %v
+
+	For example:
+
+%v
+
+	The routing key is read from the -k flag if set, then a key configured under
+	keys named by --key-name, or otherwise the recipient.
+		`, strings.Join(requiredFields, ", "), exampleMessage),
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmdInput.recipient, cmdInput.subject, cmdInput.message = args[0], args[1], args[2]
+
+			msg := parseMessage(cmdInput.message)
+
+			err := validateZabbixSendCommand(cmdInput, msg)
+			if err != nil {
+				return err
+			}
+
+			cmdInput.routingKey, err = cmdutil.ResolveSeverityKey(resolveRoutingKey(cmdInput), cmdInput.keyName, buildSeverity(msg))
+			if err != nil {
+				return err
+			}
+
+			if cmdInput.strictKey {
+				if err := cmdutil.ValidateKeyFormat(cmdInput.routingKey, eventsapi.EventVersion(cmdInput.eventsAPIVersion)); err != nil {
+					return err
+				}
+			}
+
+			sendEvent := buildSendEvent(cmdInput, msg)
+
+			if cmdInput.dryRun {
+				return cmdutil.RunDryRunCommand(sendEvent, nil)
+			}
+
+			if cmdInput.verbose {
+				if err := cmdutil.PrintVerbose(cmd.ErrOrStderr(), cmd.Flags(), sendEvent); err != nil {
+					return err
+				}
+			}
+
+			return cmdutil.RunSendCommand(cmd.Context(), config, sendEvent, nil, client.WithAutoResolveAfter(cmdInput.autoResolveAfter), client.WithIntegration("zabbix"))
+		},
+	}
+
+	cmd.Flags().StringVarP(&cmdInput.routingKey, "routing-key", "k", "", "Service Events API Key, or @FILE or env:VAR to read it from a file or environment variable (default is the recipient)")
+	cmd.Flags().StringVar(&cmdInput.keyName, "key-name", "", "Name of a key configured under keys or severity-routes, used if no routing-key is given")
+	cmd.Flags().StringVar(&cmdInput.eventsAPIVersion, "events-api-version", config.APIVersion.String(), `The Events API version to send events with, either "v1" or "v2"`)
+	cmd.Flags().DurationVar(&cmdInput.autoResolveAfter, "auto-resolve-after", 0, "Resolve a trigger this long after it's delivered, e.g. 1h for point-in-time checks, even across agent restarts")
+	cmd.Flags().BoolVar(&cmdInput.dryRun, "dry-run", false, "Validate and print the event that would be sent without sending it")
+	cmd.Flags().BoolVarP(&cmdInput.verbose, "verbose", "v", false, "Print the flags, incident key, and event to stderr before sending it")
+	cmd.Flags().BoolVar(&cmdInput.strictKey, "strict-key", false, "Check the key looks like a valid key for the Events API version before sending, see validate-key")
+
+	cmdutil.MarkSendCommand(cmd)
+
+	return cmd
+}
+
+// parseMessage parses `key:value` lines from a Zabbix message, ignoring any
+// lines that don't match.
+func parseMessage(message string) zabbixMessage {
+	msg := zabbixMessage{}
+
+	for _, line := range strings.Split(message, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if key == "" {
+			continue
+		}
+		msg[key] = strings.TrimSpace(kv[1])
+	}
+
+	return msg
+}
+
+// resolveRoutingKey returns the literal routing key to use, which is empty if
+// a key name should be used instead.
+func resolveRoutingKey(cmdInputs zabbixEnqueueInput) string {
+	if cmdInputs.routingKey != "" {
+		return cmdInputs.routingKey
+	}
+	if cmdInputs.keyName != "" {
+		return ""
+	}
+
+	return strings.TrimSpace(cmdInputs.recipient)
+}
+
+func buildSendEvent(cmdInputs zabbixEnqueueInput, msg zabbixMessage) eventsapi.Event {
+	sendEvent := buildBaseEvent(cmdInputs, msg)
+
+	for k, v := range msg {
+		sendEvent.AddCustomDetail(k, v)
+	}
+
+	return sendEvent
+}
+
+func buildBaseEvent(cmdInputs zabbixEnqueueInput, msg zabbixMessage) eventsapi.Event {
+	eventType := zabbixToPagerDutyEventType[strings.ToUpper(msg["status"])]
+
+	if cmdInputs.eventsAPIVersion == eventsapi.EventVersion2.String() {
+		return &eventsapi.EventV2{
+			RoutingKey:  cmdInputs.routingKey,
+			EventAction: eventType,
+			DedupKey:    buildIncidentKey(msg),
+			Payload: eventsapi.PayloadV2{
+				Summary:  cmdInputs.subject,
+				Source:   msg["hostname"],
+				Severity: buildSeverity(msg),
+			},
+		}
+	}
+
+	return &eventsapi.EventV1{
+		ServiceKey:  cmdInputs.routingKey,
+		EventType:   eventType,
+		IncidentKey: buildIncidentKey(msg),
+		Description: cmdInputs.subject,
+	}
+}
+
+// buildIncidentKey derives a key from the Zabbix event ID.
+//
+// Zabbix sets {EVENT.ID} to the original problem's ID in recovery messages, so
+// the problem and its recovery share a key.
+func buildIncidentKey(msg zabbixMessage) string {
+	return fmt.Sprintf("event_source=zabbix;event_id=%v", msg["event_id"])
+}
+
+func buildSeverity(msg zabbixMessage) string {
+	if severity, ok := zabbixToPagerDutySeverity[strings.ToLower(msg["severity"])]; ok {
+		return severity
+	}
+
+	return "error"
+}
+
+func validateZabbixSendCommand(cmdInputs zabbixEnqueueInput, msg zabbixMessage) error {
+	if resolveRoutingKey(cmdInputs) == "" && cmdInputs.keyName == "" {
+		return errRoutingKey
+	}
+
+	if err := cmdutil.ValidateEnumField(cmdInputs.eventsAPIVersion, allowedEventsAPIVersions, errEventsAPIVersion); err != nil {
+		return err
+	}
+
+	for _, key := range requiredFields {
+		if msg[key] == "" {
+			return fmt.Errorf("the %v field must be set in the message", key)
+		}
+	}
+
+	if err := cmdutil.ValidateEnumField(strings.ToUpper(msg["status"]), allowedStatuses, errStatus); err != nil {
+		return err
+	}
+
+	return nil
+}
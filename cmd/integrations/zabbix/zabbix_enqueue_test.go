@@ -0,0 +1,240 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package zabbix
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const problemMessage = `name:Disk space is low on /var
+event_id:12345
+status:PROBLEM
+severity:High
+hostname:db01.example.com
+ip:10.0.0.5`
+
+const recoveryMessage = `name:Disk space is low on /var
+event_id:12345
+status:OK
+severity:High
+hostname:db01.example.com
+ip:10.0.0.5`
+
+func TestZabbixEnqueue_errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError error
+	}{
+		{
+			name:          "missingArgs",
+			args:          []string{"xyz", "subject"},
+			expectedError: errors.New("accepts 3 arg(s), received 2"),
+		},
+		{
+			name:          "missingRoutingKey",
+			args:          []string{"", "subject", problemMessage},
+			expectedError: errRoutingKey,
+		},
+		{
+			name:          "invalidEventsAPIVersion",
+			args:          []string{"xyz", "subject", problemMessage, "--events-api-version", "v3"},
+			expectedError: errEventsAPIVersion,
+		},
+		{
+			name:          "missingEventID",
+			args:          []string{"xyz", "subject", "status:PROBLEM\nhostname:db01.example.com"},
+			expectedError: errors.New("the event_id field must be set in the message"),
+		},
+		{
+			name:          "missingHostname",
+			args:          []string{"xyz", "subject", "event_id:12345\nstatus:PROBLEM"},
+			expectedError: errors.New("the hostname field must be set in the message"),
+		},
+		{
+			name:          "invalidStatus",
+			args:          []string{"xyz", "subject", "event_id:12345\nstatus:UNKNOWN\nhostname:db01.example.com"},
+			expectedError: errStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			realConfig := cmdutil.NewConfig()
+
+			cmd := NewZabbixEnqueueCmd(realConfig)
+			cmd.SetArgs(tt.args)
+			cmd.SilenceUsage = true
+
+			_, err := cmd.ExecuteC()
+
+			assert.Error(t, err)
+			assert.Equal(t, tt.expectedError, err)
+		})
+	}
+}
+
+func TestZabbixEnqueue_validInputs(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	os.Setenv("TEST_ZABBIX_ROUTING_KEY", "envkey")
+	defer os.Unsetenv("TEST_ZABBIX_ROUTING_KEY")
+
+	problemDetails := map[string]interface{}{
+		"name":     "Disk space is low on /var",
+		"event_id": "12345",
+		"status":   "PROBLEM",
+		"severity": "High",
+		"hostname": "db01.example.com",
+		"ip":       "10.0.0.5",
+	}
+
+	tests := []struct {
+		name                string
+		args                []string
+		expectedRequestBody map[string]interface{}
+	}{
+		{
+			name: "problem",
+			args: []string{"xyz", "PROBLEM: Disk space is low on /var", problemMessage},
+			expectedRequestBody: map[string]interface{}{
+				"service_key":  "xyz",
+				"event_type":   "trigger",
+				"incident_key": "event_source=zabbix;event_id=12345",
+				"description":  "PROBLEM: Disk space is low on /var",
+				"details":      problemDetails,
+			},
+		},
+		{
+			name: "recoveryWithCRLF",
+			args: []string{"xyz", "OK: Disk space is low on /var", "event_id:12345\r\nstatus:OK\r\nhostname:db01.example.com\r\n"},
+			expectedRequestBody: map[string]interface{}{
+				"service_key":  "xyz",
+				"event_type":   "resolve",
+				"incident_key": "event_source=zabbix;event_id=12345",
+				"description":  "OK: Disk space is low on /var",
+				"details": map[string]interface{}{
+					"event_id": "12345",
+					"status":   "OK",
+					"hostname": "db01.example.com",
+				},
+			},
+		},
+		{
+			name: "routingKeyFromEnv",
+			args: []string{"recipient", "OK: Disk space is low on /var", recoveryMessage, "-k", "env:TEST_ZABBIX_ROUTING_KEY"},
+			expectedRequestBody: map[string]interface{}{
+				"service_key":  "envkey",
+				"event_type":   "resolve",
+				"incident_key": "event_source=zabbix;event_id=12345",
+				"description":  "OK: Disk space is low on /var",
+				"details": map[string]interface{}{
+					"name":     "Disk space is low on /var",
+					"event_id": "12345",
+					"status":   "OK",
+					"severity": "High",
+					"hostname": "db01.example.com",
+					"ip":       "10.0.0.5",
+				},
+			},
+		},
+		{
+			name: "problemV2",
+			args: []string{"xyz", "PROBLEM: Disk space is low on /var", problemMessage, "--events-api-version", "v2"},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "xyz",
+				"event_action": "trigger",
+				"dedup_key":    "event_source=zabbix;event_id=12345",
+				"payload": map[string]interface{}{
+					"summary":        "PROBLEM: Disk space is low on /var",
+					"source":         "db01.example.com",
+					"severity":       "error",
+					"custom_details": problemDetails,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.InitConfigForIntegrationsTesting()
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{
+				Timeout: 5 * time.Minute,
+			}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewZabbixEnqueueCmd(realConfig)
+			cmd.SetArgs(tt.args)
+
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").JSON(tt.expectedRequestBody).
+				Reply(200).JSON(map[string]interface{}{"key": "abc"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if err != nil {
+				t.Errorf("error running command `enqueue`: %v", err)
+			}
+
+			assert.True(t, gock.IsDone(), "expected payload was not posted")
+			assert.Contains(t, out, `{"key":"abc"}`)
+		})
+	}
+}
+
+func TestZabbixEnqueue_severity(t *testing.T) {
+	tests := []struct {
+		severity string
+		expected string
+	}{
+		{"Not classified", "info"},
+		{"Information", "info"},
+		{"Warning", "warning"},
+		{"Average", "error"},
+		{"High", "error"},
+		{"Disaster", "critical"},
+		{"", "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("severity%v", tt.severity), func(t *testing.T) {
+			assert.Equal(t, tt.expected, buildSeverity(zabbixMessage{"severity": tt.severity}))
+		})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+var errMaintenanceState = errors.New(`maintenance state must be either "on" or "off"`)
+
+func NewMaintenanceCmd(config *cmdutil.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "maintenance <on|off>",
+		Short: "Toggle the daemon's maintenance mode.",
+		Long: `Toggle the daemon's maintenance mode.
+
+While in maintenance mode events are still accepted and queued, but not sent
+to PagerDuty. Turning maintenance mode off sends any events queued in the
+meantime. Maintenance mode persists across restarts of the daemon.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdutil.ValidateEnumField(args[0], []string{"on", "off"}, errMaintenanceState); err != nil {
+				return err
+			}
+			return runMaintenanceCommand(config, args[0] == "on")
+		},
+	}
+
+	return cmd
+}
+
+func runMaintenanceCommand(config *cmdutil.Config, enabled bool) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.Maintenance(enabled)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	return nil
+}
@@ -26,6 +26,10 @@ func NewQueueCmd(config *cmdutil.Config) *cobra.Command {
 		Short: "Access the daemon's event queue.",
 	}
 
+	cmd.AddCommand(NewQueueExportCmd(config))
+	cmd.AddCommand(NewQueueFlushCmd(config))
+	cmd.AddCommand(NewQueueListCmd(config))
+	cmd.AddCommand(NewQueuePurgeCmd(config))
 	cmd.AddCommand(NewQueueRetryCmd(config))
 	cmd.AddCommand(NewQueueStatusCmd(config))
 
@@ -0,0 +1,83 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+func NewQueueExportCmd(config *cmdutil.Config) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the queue's contents as a support bundle.",
+		Long: `Export the queue's pending, in-flight, and dead-lettered events as a single
+JSON bundle, along with the agent's version and a summary of its configuration,
+e.g. to hand over to PagerDuty support.
+
+Routing keys are masked throughout. The queue is left unchanged, so this is safe
+while the agent is running.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runQueueExportCommand(config, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the bundle to, instead of stdout")
+
+	return cmd
+}
+
+func runQueueExportCommand(config *cmdutil.Config, output string) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.QueueExport()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != 200 {
+		fmt.Println(string(respBody))
+		os.Exit(1)
+	}
+	if output == "" {
+		fmt.Println(string(respBody))
+		return nil
+	}
+
+	// Bundles include event payloads, so are only readable by their owner.
+	if err := ioutil.WriteFile(output, respBody, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Exported queue to %v.\n", output)
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var allowedQueueListStatuses = []string{"pending", "failed"}
+
+var errQueueListStatus = errors.New(`status must be either "pending" or "failed"`)
+
+// queueListStatuses maps user-facing statuses to those stored in the queue.
+var queueListStatuses = map[string]string{
+	"pending": persistentqueue.StatusPending,
+	"failed":  persistentqueue.StatusError,
+}
+
+func NewQueueListCmd(config *cmdutil.Config) *cobra.Command {
+	var status string
+	var routingKey string
+	var limit int
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List events in the queue.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if status != "" {
+				if err := cmdutil.ValidateEnumField(status, allowedQueueListStatuses, errQueueListStatus); err != nil {
+					return err
+				}
+			}
+			return runQueueListCommand(config, queueListStatuses[status], routingKey, limit, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringVar(&status, "status", "", `Only list events with this status, either "pending" or "failed"`)
+	cmd.Flags().StringVarP(&routingKey, "routing-key", "k", "", "Only list events for this Events API Key")
+	cmd.Flags().IntVar(&limit, "limit", 50, "Maximum number of events to list, 0 for all")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the raw JSON list")
+
+	return cmd
+}
+
+func runQueueListCommand(config *cmdutil.Config, status, routingKey string, limit int, jsonOutput bool) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.QueueList(status, routingKey, limit)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if jsonOutput || resp.StatusCode != 200 {
+		fmt.Println(string(respBody))
+		return nil
+	}
+
+	var list server.QueueListResponse
+	if err := json.Unmarshal(respBody, &list); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Print(formatQueueList(list))
+	return nil
+}
+
+// formatQueueList returns queued events as a human-readable table.
+func formatQueueList(list server.QueueListResponse) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "ID\tROUTING KEY\tEVENT TYPE\tSTATUS\tATTEMPTS\tENQUEUED\tNEXT ATTEMPT")
+	for _, e := range list.Events {
+		nextAttempt := "-"
+		if e.NextAttemptAt != nil {
+			nextAttempt = e.NextAttemptAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", e.ID, e.RoutingKey, e.EventType, e.Status, e.Attempts, e.EnqueuedAt.Format(time.RFC3339), nextAttempt)
+	}
+
+	w.Flush()
+	return buf.String()
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestQueueList_filters(t *testing.T) {
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewQueueListCmd(realConfig)
+	cmd.SetArgs([]string{"--status", "failed", "-k", "abc", "--limit", "10", "--json"})
+
+	body := `{"events":[{"id":1,"key":"xyz","routing_key":"ab****cd","event_type":"trigger","status":"error","attempts":3,"enqueued_at":"2020-06-01T12:30:00Z"}]}`
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Get("/queue").
+		MatchParam("status", "error").
+		MatchParam("rk", "abc").
+		MatchParam("limit", "10").
+		Reply(200).
+		BodyString(body)
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `queue list`: %v", err)
+	}
+
+	assert.True(t, gock.IsDone())
+	assert.Equal(t, body+"\n", out)
+}
+
+func TestQueueList_invalidStatus(t *testing.T) {
+	cmd := NewQueueListCmd(cmdutil.NewConfig())
+	cmd.SetArgs([]string{"--status", "error"})
+	cmd.SilenceUsage = true
+
+	_, err := cmd.ExecuteC()
+
+	assert.Equal(t, errQueueListStatus, err)
+}
+
+func TestQueueList_format(t *testing.T) {
+	nextAttempt := time.Date(2020, 6, 1, 12, 33, 0, 0, time.UTC)
+	list := server.QueueListResponse{
+		Events: []server.QueueListItem{
+			{
+				ID:         1,
+				RoutingKey: "ab****cd",
+				EventType:  "trigger",
+				Status:     "pending",
+				EnqueuedAt: time.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC),
+			},
+			{
+				ID:         12,
+				RoutingKey: "ef****gh",
+				EventType:  "acknowledge",
+				Status:     "error",
+				Attempts:   3,
+				EnqueuedAt: time.Date(2020, 6, 1, 12, 31, 0, 0, time.UTC),
+			},
+			{
+				ID:            13,
+				RoutingKey:    "ef****gh",
+				EventType:     "trigger",
+				Status:        "scheduled",
+				Attempts:      2,
+				EnqueuedAt:    time.Date(2020, 6, 1, 12, 31, 0, 0, time.UTC),
+				NextAttemptAt: &nextAttempt,
+			},
+		},
+	}
+
+	expected := "ID  ROUTING KEY  EVENT TYPE   STATUS     ATTEMPTS  ENQUEUED              NEXT ATTEMPT\n" +
+		"1   ab****cd     trigger      pending    0         2020-06-01T12:30:00Z  -\n" +
+		"12  ef****gh     acknowledge  error      3         2020-06-01T12:31:00Z  -\n" +
+		"13  ef****gh     trigger      scheduled  2         2020-06-01T12:31:00Z  2020-06-01T12:33:00Z\n"
+
+	assert.Equal(t, expected, formatQueueList(list))
+}
@@ -0,0 +1,92 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/spf13/cobra"
+)
+
+var errPurgeNotConfirmed = errors.New("purging permanently deletes queued events, rerun with --confirm to proceed")
+
+func NewQueuePurgeCmd(config *cmdutil.Config) *cobra.Command {
+	var confirm bool
+	var deadLetters bool
+
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete all pending events from the queue.",
+		Long: `Delete all pending events from the queue, e.g. to clear out test events.
+
+Sends already in progress are allowed to complete first. Dead letters are only
+deleted with --dead-letters. As purged events can't be recovered, --confirm is
+required.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !confirm {
+				return errPurgeNotConfirmed
+			}
+			return runQueuePurgeCommand(config, deadLetters)
+		},
+	}
+
+	cmd.Flags().BoolVar(&confirm, "confirm", false, "Confirm deleting the queued events")
+	cmd.Flags().BoolVar(&deadLetters, "dead-letters", false, "Also delete all dead letters")
+
+	return cmd
+}
+
+func runQueuePurgeCommand(config *cmdutil.Config, deadLetters bool) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.QueuePurge(deadLetters)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if resp.StatusCode != 200 {
+		fmt.Println(string(respBody))
+		return nil
+	}
+
+	var result persistentqueue.PurgeResult
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Pending events purged:   %v\n", result.Pending)
+	fmt.Printf("In-flight events purged: %v\n", result.InFlight)
+	fmt.Printf("Dead letters purged:     %v\n", result.DeadLetters)
+	return nil
+}
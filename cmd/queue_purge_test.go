@@ -0,0 +1,104 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestQueuePurge(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		deadLetters string
+	}{
+		{"events", []string{"--confirm"}, "false"},
+		{"deadLetters", []string{"--confirm", "--dead-letters"}, "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{
+				Timeout: 5 * time.Second,
+			}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewQueuePurgeCmd(realConfig)
+			cmd.SetArgs(tt.args)
+
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/queue/purge").
+				MatchParam("dead_letters", tt.deadLetters).
+				Reply(200).
+				BodyString(`{"pending":3,"in_flight":1,"dead_letters":2}`)
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if err != nil {
+				t.Errorf("error running command `queue purge`: %v", err)
+			}
+
+			assert.True(t, gock.IsDone())
+			assert.Equal(t, "Pending events purged:   3\nIn-flight events purged: 1\nDead letters purged:     2\n", out)
+		})
+	}
+}
+
+func TestQueuePurge_notConfirmed(t *testing.T) {
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewQueuePurgeCmd(realConfig)
+	cmd.SetArgs([]string{"--dead-letters"})
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/queue/purge").
+		Reply(200).
+		BodyString(`{"pending":0,"in_flight":0,"dead_letters":0}`)
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	_, err := cmd.ExecuteC()
+
+	assert.Equal(t, errPurgeNotConfirmed, err)
+	assert.True(t, gock.IsPending(), "expected no request to be sent without --confirm")
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+var errReplayTarget = errors.New("replay requires either a dead letter id or --all, but not both")
+
+func NewReplayCmd(config *cmdutil.Config) *cobra.Command {
+	var all bool
+	var routingKey string
+
+	cmd := &cobra.Command{
+		Use:   "replay [<id>]",
+		Short: "Re-send a dead-lettered event.",
+		Long: `Re-send the original event of a dead letter, or of every dead letter
+with --all, e.g. once a bad routing key has been fixed.
+
+With --routing-key the event is re-sent to that key instead of its own.
+Replayed events start over, retrying as usual before they can be
+dead-lettered again.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return errReplayTarget
+			}
+
+			id := 0
+			if !all {
+				var err error
+				id, err = strconv.Atoi(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid dead letter id: %v", args[0])
+				}
+			}
+			return runReplayCommand(config, id, all, routingKey)
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Replay every dead letter")
+	cmd.Flags().StringVarP(&routingKey, "routing-key", "k", "", "Events API Key to send the replayed events to instead of their own")
+
+	return cmd
+}
+
+func runReplayCommand(config *cmdutil.Config, id int, all bool, routingKey string) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.DeadLetterReplay(id, all, routingKey)
+	if err != nil {
+		fmt.Println(cmdutil.Redact(err.Error()))
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	return nil
+}
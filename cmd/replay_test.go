@@ -0,0 +1,109 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		params map[string]string
+		body   string
+	}{
+		{
+			name:   "single",
+			args:   []string{"3", "--routing-key", "fixedkey"},
+			params: map[string]string{"id": "3", "rk": "fixedkey"},
+			body:   `{"message":"Replaying dead letter 3.","key":"abc"}`,
+		},
+		{
+			name:   "all",
+			args:   []string{"--all", "-k", "fixedkey"},
+			params: map[string]string{"all": "true", "rk": "fixedkey"},
+			body:   `{"message":"Replaying 4 dead letters."}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{
+				Timeout: 5 * time.Second,
+			}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewReplayCmd(realConfig)
+			cmd.SetArgs(tt.args)
+
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/dead-letters/replay").
+				MatchParams(tt.params).
+				Reply(200).
+				BodyString(tt.body)
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if err != nil {
+				t.Errorf("error running command `replay`: %v", err)
+			}
+
+			assert.True(t, gock.IsDone())
+			assert.Equal(t, tt.body+"\n", out)
+		})
+	}
+}
+
+func TestReplay_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"noTarget", []string{}},
+		{"idAndAll", []string{"3", "--all"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewReplayCmd(cmdutil.NewConfig())
+			cmd.SetArgs(tt.args)
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			_, err := cmd.ExecuteC()
+			assert.Equal(t, errReplayTarget, err)
+		})
+	}
+}
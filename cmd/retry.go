@@ -16,22 +16,44 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
 	"github.com/spf13/cobra"
 )
 
+var errRetryTarget = errors.New("retry takes either an event id or --routing-key, but not both")
+
 func NewQueueRetryCmd(config *cmdutil.Config) *cobra.Command {
 	var routingKey string
 
 	cmd := &cobra.Command{
-		Use:   "retry",
+		Use:   "retry [<id>]",
 		Short: "Retry failed events.",
+		Long: `Retry failed events, either all of them, those for --routing-key, or
+the single failed or scheduled event with the given id, as shown by
+"queue list".
+
+Retried events are sent immediately with their attempts reset, so one
+that keeps failing is retried with the usual growing delay.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runRetryCommand(config, routingKey)
+			if len(args) == 0 {
+				return runRetryCommand(config, routingKey)
+			}
+			if routingKey != "" {
+				return errRetryTarget
+			}
+
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid event id: %v", args[0])
+			}
+			return runRetryEventCommand(config, id)
 		},
 	}
 
@@ -41,7 +63,11 @@ func NewQueueRetryCmd(config *cmdutil.Config) *cobra.Command {
 }
 
 func runRetryCommand(config *cmdutil.Config, routingKey string) error {
-	c, _ := config.Client()
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	resp, err := c.QueueRetry(routingKey)
 	if err != nil {
@@ -58,3 +84,26 @@ func runRetryCommand(config *cmdutil.Config, routingKey string) error {
 	fmt.Println(string(respBody))
 	return nil
 }
+
+func runRetryEventCommand(config *cmdutil.Config, id int) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.QueueRetryEvent(id)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	return nil
+}
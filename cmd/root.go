@@ -19,9 +19,12 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/PagerDuty/go-pdagent/cmd/integrations/generic"
+	"github.com/PagerDuty/go-pdagent/cmd/integrations/icinga2"
 	"github.com/PagerDuty/go-pdagent/cmd/integrations/nagios"
+	"github.com/PagerDuty/go-pdagent/cmd/integrations/zabbix"
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
-	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/version"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -31,10 +34,20 @@ var rootCmd *cobra.Command
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+//
+// Commands are cancelled on an interrupt or termination signal, see
+// `cmdutil.SignalContext`.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	ctx, cancel := cmdutil.SignalContext()
+	defer cancel()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		cancel()
+		fmt.Println(cmdutil.RedactError(err))
+
+		// The command that failed, to determine its exit code.
+		cmd, _, _ := rootCmd.Find(os.Args[1:])
+		os.Exit(cmdutil.ExitCode(cmd, err))
 	}
 }
 
@@ -62,18 +75,36 @@ func NewRootCmd(config *cmdutil.Config) *cobra.Command {
 	}
 
 	defaults := cmdutil.GetDefaults()
-	rootCmd.Version = common.Version
+	rootCmd.Version = version.Version
 
 	pflags := rootCmd.PersistentFlags()
-	pflags.StringVar(&cmdutil.CfgFile, "config", "", "config file (default is $HOME/.go-pdagent.yaml)")
+	pflags.StringVar(&cmdutil.CfgFile, "config", "", "config file (default is $PD_AGENT_CONFIG, ./pdagent.yaml, then /etc/pdagent/pdagent.yaml)")
 	pflags.StringP("address", "a", defaults.Address, "address to run and access the agent server on.")
+	pflags.Int("port", 0, "port to run and access the agent server on, overriding the port in address.")
 	pflags.String("pidfile", defaults.Pidfile, "pidfile for the currently running pdagent instance, if any.")
 	pflags.StringP("secret", "s", defaults.Secret, "secret used to authorize agent access.")
+	pflags.String("signing-secret", "", "shared secret signing events sent to the agent server, which then rejects unsigned /send and /ingest requests.")
+	pflags.String("proxy-url", "", "proxy for outgoing requests, taking precedence over HTTP_PROXY and HTTPS_PROXY.")
+	pflags.Duration("timeout", defaults.RequestTimeout, "timeout for requests to the agent server.")
+	pflags.Duration("dial-timeout", defaults.DialTimeout, "timeout for connecting to the agent server.")
+	pflags.Int("max-idle-conns", defaults.MaxIdleConns, "maximum idle connections kept alive for reuse across all hosts, 0 for no limit.")
+	pflags.Int("max-idle-conns-per-host", defaults.MaxIdlePerHost, "maximum idle connections kept alive for reuse per host, e.g. events.pagerduty.com.")
+	pflags.Duration("idle-conn-timeout", defaults.IdleConnTimeout, "how long idle connections are kept alive before closing, 0 for no limit.")
+	pflags.String("log-format", "", `log format, either "text" or "json" (default is text, or json in production).`)
+	pflags.String("log-level", "", `minimum log level, one of "debug", "info", "warn", or "error".`)
+	pflags.BoolP("quiet", "q", false, "don't print the agent's response after enqueuing an event, leaving only the exit code.")
+	pflags.Bool("no-redact", false, "show routing keys and secrets unmasked in logs and errors, only for debugging.")
+	pflags.Bool("no-agent-metadata", false, "don't add the host and PID of the command sending an event to its custom details as pd_agent_host and pd_agent_pid.")
+	pflags.String("user-agent", "", `User-Agent for outgoing requests, replacing the default "go-pdagent/<version> (<integration>; ...)".`)
 
 	if err := viper.BindPFlag("address", pflags.Lookup("address")); err != nil {
 		fmt.Println(err)
 	}
 
+	if err := viper.BindPFlag("port", pflags.Lookup("port")); err != nil {
+		fmt.Println(err)
+	}
+
 	if err := viper.BindPFlag("pidfile", pflags.Lookup("pidfile")); err != nil {
 		fmt.Println(err)
 	}
@@ -82,14 +113,80 @@ func NewRootCmd(config *cmdutil.Config) *cobra.Command {
 		fmt.Println(err)
 	}
 
+	if err := viper.BindPFlag("signing-secret", pflags.Lookup("signing-secret")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("proxy-url", pflags.Lookup("proxy-url")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("timeout", pflags.Lookup("timeout")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("dial-timeout", pflags.Lookup("dial-timeout")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("max-idle-conns", pflags.Lookup("max-idle-conns")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("max-idle-conns-per-host", pflags.Lookup("max-idle-conns-per-host")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("idle-conn-timeout", pflags.Lookup("idle-conn-timeout")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("log-format", pflags.Lookup("log-format")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("log-level", pflags.Lookup("log-level")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("quiet", pflags.Lookup("quiet")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("no-redact", pflags.Lookup("no-redact")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("no-agent-metadata", pflags.Lookup("no-agent-metadata")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("user-agent", pflags.Lookup("user-agent")); err != nil {
+		fmt.Println(err)
+	}
+
 	// All top-level commands go here
+	rootCmd.AddCommand(NewAcknowledgeCmd(config))
+	rootCmd.AddCommand(NewChangeCmd(config))
+	rootCmd.AddCommand(NewDeadLettersCmd(config))
+	rootCmd.AddCommand(NewDedupCmd(config))
+	rootCmd.AddCommand(NewDrainCmd(config))
 	rootCmd.AddCommand(NewEnqueueCmd(config))
 	rootCmd.AddCommand(NewInitCmd())
+	rootCmd.AddCommand(NewMaintenanceCmd(config))
 	rootCmd.AddCommand(NewQueueCmd(config))
+	rootCmd.AddCommand(NewReplayCmd(config))
+	rootCmd.AddCommand(NewResolveCmd(config))
 	rootCmd.AddCommand(NewSendCmd(config))
 	rootCmd.AddCommand(NewServerCmd())
+	rootCmd.AddCommand(NewStatusCmd(config))
+	rootCmd.AddCommand(NewTestConnectionCmd())
+	rootCmd.AddCommand(NewValidateKeyCmd())
 	rootCmd.AddCommand(NewVersionCmd())
 	rootCmd.AddCommand(nagios.NewNagiosCmd(config))
+	rootCmd.AddCommand(icinga2.NewIcinga2Cmd(config))
+	rootCmd.AddCommand(zabbix.NewZabbixCmd(config))
+	rootCmd.AddCommand(generic.NewGenericCmd(config))
 
 	return rootCmd
 }
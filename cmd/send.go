@@ -16,11 +16,46 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// sendV2Input holds the flags for sending an arbitrary v2 event.
+type sendV2Input struct {
+	routingKey  string
+	eventAction string
+	dedupKey    string
+	summary     string
+	source      string
+	severity    string
+	client      string
+	clientURL   string
+	details     cmdutil.CustomFields
+}
+
+var legacySendFlags = []string{"service-key", "event-type", "description", "incident-key", "field"}
+var sendV2Flags = []string{"routing-key", "event-action", "dedup-key", "summary", "source", "severity", "detail"}
+
+var allowedSendEventActions = []string{"trigger", "acknowledge", "resolve"}
+var allowedSendSeverities = []string{"critical", "error", "warning", "info"}
+
+var errSendEventAction = fmt.Errorf("event-action must be one of: %v", strings.Join(allowedSendEventActions, ", "))
+var errSendSeverity = fmt.Errorf("severity must be one of: %v", strings.Join(allowedSendSeverities, ", "))
+var errSendMixedFlags = errors.New("v1 flags (e.g. service-key, event-type) may not be combined with v2 flags (e.g. routing-key, event-action)")
+var errSendLegacyRequired = errors.New(`required flag(s) "event-type", "service-key" not set`)
+var errSendRoutingKey = errors.New("routing-key must be set")
+var errSendSummary = errors.New("summary must be set for trigger events")
+var errSendSource = errors.New("source must be set for trigger events")
+var errSendDedupKey = errors.New("dedup-key must be set for acknowledge and resolve events")
+
 func NewSendCmd(config *cmdutil.Config) *cobra.Command {
 	var customDetails map[string]string
 
@@ -28,16 +63,62 @@ func NewSendCmd(config *cmdutil.Config) *cobra.Command {
 		Details: eventsapi.DetailsV1{},
 	}
 
+	v2Input := sendV2Input{details: cmdutil.CustomFields{}}
+	var keyName string
+	var ttl, autoResolveAfter time.Duration
+	var priority string
+
 	cmd := &cobra.Command{
 		Use:   "send",
-		Short: "Queue up a trigger, acknowledge, or resolve a V1 event to PagerDuty",
-		Long: `Queue up a trigger, acknowledge, or resolve V1 event to PagerDuty
-		using a backwards-compatible set of flags.
+		Short: "Queue up a trigger, acknowledge, or resolve event to PagerDuty",
+		Long: `Queue up a trigger, acknowledge, or resolve event to PagerDuty.
+
+		By default a V1 event is sent using a backwards-compatible set of flags,
+		requiring "service-key" and "event-type".
+
+		Either form may use "key-name" to select a key configured under "keys"
+		instead of passing it literally.
 
-		Required flags: "service-key", "event-type"`,
+		Alternatively a V2 event is sent when using "routing-key" and
+		"event-action", additionally requiring "summary" and "source" for
+		triggers and "dedup-key" for acknowledges and resolves.`,
 
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return cmdutil.RunSendCommand(config, &sendEvent, customDetails)
+			eventPriority, err := eventsapi.ParsePriority(priority)
+			if err != nil {
+				return err
+			}
+
+			v2 := anyFlagChanged(cmd.Flags(), sendV2Flags)
+			if v2 && anyFlagChanged(cmd.Flags(), legacySendFlags) {
+				return errSendMixedFlags
+			}
+
+			sendEvent.Client, sendEvent.ClientURL, err = cmdutil.ResolveClient(sendEvent.Client, sendEvent.ClientURL)
+			if err != nil {
+				return err
+			}
+
+			if v2 {
+				v2Input.client, v2Input.clientURL = sendEvent.Client, sendEvent.ClientURL
+				v2Input.routingKey, err = cmdutil.ResolveSeverityKey(v2Input.routingKey, keyName, v2Input.severity)
+				if err != nil {
+					return err
+				}
+				if err := validateSendV2Input(v2Input); err != nil {
+					return err
+				}
+				return cmdutil.RunSendCommand(cmd.Context(), config, buildSendV2Event(v2Input), nil, client.WithTTL(ttl), client.WithAutoResolveAfter(autoResolveAfter), client.WithPriority(eventPriority))
+			}
+
+			sendEvent.ServiceKey, err = cmdutil.ResolveNamedKey(sendEvent.ServiceKey, keyName)
+			if err != nil {
+				return err
+			}
+			if sendEvent.ServiceKey == "" || sendEvent.EventType == "" {
+				return errSendLegacyRequired
+			}
+			return cmdutil.RunSendCommand(cmd.Context(), config, &sendEvent, customDetails, client.WithTTL(ttl), client.WithAutoResolveAfter(autoResolveAfter), client.WithPriority(eventPriority))
 		},
 	}
 
@@ -45,12 +126,85 @@ func NewSendCmd(config *cmdutil.Config) *cobra.Command {
 	cmd.Flags().StringVarP(&sendEvent.EventType, "event-type", "t", "", `Event type, either "trigger", "acknowledge", or "resolve"`)
 	cmd.Flags().StringVarP(&sendEvent.Description, "description", "d", "", "Short description of the problem")
 	cmd.Flags().StringVarP(&sendEvent.IncidentKey, "incident-key", "i", "", "Incident Key")
-	cmd.Flags().StringVarP(&sendEvent.Client, "client", "c", "", "Client")
-	cmd.Flags().StringVarP(&sendEvent.ClientURL, "client-url", "u", "", "Client URL")
+	cmd.Flags().StringVarP(&sendEvent.Client, "client", "c", "", `The client shown on the incident, instead of the client config value or "PagerDuty Agent on <hostname>"`)
+	cmd.Flags().StringVarP(&sendEvent.ClientURL, "client-url", "u", "", "A URL linking the incident back to the client, instead of the client-url config value")
 	cmd.Flags().StringToStringVarP(&customDetails, "field", "f", map[string]string{}, "Add given KEY=VALUE pair to the event details")
 
-	cmd.MarkFlagRequired("service-key")
-	cmd.MarkFlagRequired("event-type")
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "Discard the event if the agent can't send it within this long, e.g. 1h, instead of the agent's event-ttl")
+	cmd.Flags().DurationVar(&autoResolveAfter, "auto-resolve-after", 0, "Resolve a trigger this long after it's delivered, e.g. 1h for point-in-time checks, even across agent restarts")
+	cmd.Flags().StringVar(&priority, "priority", "", `Send ahead of or behind other queued events, either "low", "normal", or "high", instead of by severity`)
+	cmd.Flags().StringVar(&keyName, "key-name", "", "Name of a key configured under keys or severity-routes, used if no service-key or routing-key is given")
+
+	cmd.Flags().StringVar(&v2Input.routingKey, "routing-key", "", "Service Events API Key, sending a v2 event")
+	cmd.Flags().StringVar(&v2Input.eventAction, "event-action", "", `V2 event action, either "trigger", "acknowledge", or "resolve"`)
+	cmd.Flags().StringVar(&v2Input.dedupKey, "dedup-key", "", "V2 deduplication key for correlating triggers and resolves")
+	cmd.Flags().StringVar(&v2Input.summary, "summary", "", "A brief text summary of the v2 event")
+	cmd.Flags().StringVar(&v2Input.source, "source", "", "The unique location of the affected system")
+	cmd.Flags().StringVar(&v2Input.severity, "severity", "error", "The perceived severity of the v2 event")
+	cmd.Flags().Var(v2Input.details, "detail", "Add given KEY=VALUE pair to the v2 event custom details, repeated keys are sent as a list")
+
+	cmdutil.MarkSendCommand(cmd)
 
 	return cmd
 }
+
+func validateSendV2Input(input sendV2Input) error {
+	if input.routingKey == "" {
+		return errSendRoutingKey
+	}
+
+	if err := cmdutil.ValidateEnumField(input.eventAction, allowedSendEventActions, errSendEventAction); err != nil {
+		return err
+	}
+
+	if input.eventAction == "trigger" {
+		if input.summary == "" {
+			return errSendSummary
+		}
+		if input.source == "" {
+			return errSendSource
+		}
+		if err := cmdutil.ValidateEnumField(input.severity, allowedSendSeverities, errSendSeverity); err != nil {
+			return err
+		}
+	} else if input.dedupKey == "" {
+		return errSendDedupKey
+	}
+
+	return nil
+}
+
+func buildSendV2Event(input sendV2Input) *eventsapi.EventV2 {
+	event := eventsapi.EventV2{
+		RoutingKey:  input.routingKey,
+		EventAction: input.eventAction,
+		DedupKey:    input.dedupKey,
+		Payload: eventsapi.PayloadV2{
+			Summary:  input.summary,
+			Source:   input.source,
+			Severity: input.severity,
+		},
+		Client:    input.client,
+		ClientURL: input.clientURL,
+	}
+
+	for k, v := range input.details.Details(nil) {
+		event.AddCustomDetail(k, v)
+	}
+
+	return &event
+}
+
+func anyFlagChanged(flags *pflag.FlagSet, names []string) bool {
+	return changedFlag(flags, names) != ""
+}
+
+// changedFlag returns the first of `names` set on the command line, if any.
+func changedFlag(flags *pflag.FlagSet, names []string) string {
+	for _, name := range names {
+		if flags.Changed(name) {
+			return name
+		}
+	}
+	return ""
+}
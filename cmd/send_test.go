@@ -0,0 +1,430 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestSend_errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError error
+	}{
+		{
+			name:          "legacyMissingRequiredFlags",
+			args:          []string{"-d", "description"},
+			expectedError: errSendLegacyRequired,
+		},
+		{
+			name:          "mixedFlags",
+			args:          []string{"-k", "abc", "--event-action", "trigger"},
+			expectedError: errSendMixedFlags,
+		},
+		{
+			name:          "missingRoutingKey",
+			args:          []string{"--event-action", "trigger", "--summary", "summary", "--source", "source"},
+			expectedError: errSendRoutingKey,
+		},
+		{
+			name:          "invalidEventAction",
+			args:          []string{"--routing-key", "abc", "--event-action", "alert"},
+			expectedError: errSendEventAction,
+		},
+		{
+			name:          "triggerMissingSummary",
+			args:          []string{"--routing-key", "abc", "--event-action", "trigger", "--source", "source"},
+			expectedError: errSendSummary,
+		},
+		{
+			name:          "triggerMissingSource",
+			args:          []string{"--routing-key", "abc", "--event-action", "trigger", "--summary", "summary"},
+			expectedError: errSendSource,
+		},
+		{
+			name:          "triggerInvalidSeverity",
+			args:          []string{"--routing-key", "abc", "--event-action", "trigger", "--summary", "summary", "--source", "source", "--severity", "catastrophic"},
+			expectedError: errSendSeverity,
+		},
+		{
+			name:          "unknownKeyName",
+			args:          []string{"--key-name", "unknown", "--event-action", "resolve", "--dedup-key", "xyz"},
+			expectedError: errors.New("unknown key name unknown, no keys are configured"),
+		},
+		{
+			name:          "invalidClientURL",
+			args:          []string{"--routing-key", "abc", "--event-action", "resolve", "--dedup-key", "xyz", "--client-url", "nagios.example.com"},
+			expectedError: cmdutil.ErrInvalidClientURL,
+		},
+		{
+			name:          "acknowledgeMissingDedupKey",
+			args:          []string{"--routing-key", "abc", "--event-action", "acknowledge"},
+			expectedError: errSendDedupKey,
+		},
+		{
+			name:          "resolveMissingDedupKey",
+			args:          []string{"--routing-key", "abc", "--event-action", "resolve", "--summary", "summary"},
+			expectedError: errSendDedupKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewSendCmd(cmdutil.NewConfig())
+			cmd.SetArgs(tt.args)
+			cmd.SilenceUsage = true
+
+			_, err := cmd.ExecuteC()
+
+			assert.Equal(t, tt.expectedError, err)
+			assert.Equal(t, cmdutil.ExitValidation, cmdutil.ExitCode(cmd, err))
+		})
+	}
+}
+
+func TestSend_validInputs(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	tests := []struct {
+		name                string
+		args                []string
+		expectedRequestBody map[string]interface{}
+	}{
+		{
+			name: "legacyV1",
+			args: []string{"-k", "abc", "-t", "trigger", "-d", "description", "-f", "a=b"},
+			expectedRequestBody: map[string]interface{}{
+				"service_key": "abc",
+				"client":      cmdutil.DefaultClient(),
+				"event_type":  "trigger",
+				"description": "description",
+				"details":     map[string]string{"a": "b"},
+			},
+		},
+		{
+			name: "v2Trigger",
+			args: []string{
+				"--routing-key", "abc", "--event-action", "trigger", "--summary", "summary", "--source", "source",
+				"--severity", "warning", "--detail", "a=b", "--detail", "c=d", "--detail", "c=e",
+			},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "abc",
+				"client":       cmdutil.DefaultClient(),
+				"event_action": "trigger",
+				"payload": map[string]interface{}{
+					"summary":  "summary",
+					"source":   "source",
+					"severity": "warning",
+					"custom_details": map[string]interface{}{
+						"a": "b",
+						"c": []string{"d", "e"},
+					},
+				},
+			},
+		},
+		{
+			name: "v1Client",
+			args: []string{"-k", "abc", "-t", "trigger", "-d", "description", "-c", "nagios-east", "-u", "https://nagios.example.com"},
+			expectedRequestBody: map[string]interface{}{
+				"service_key": "abc",
+				"event_type":  "trigger",
+				"description": "description",
+				"client":      "nagios-east",
+				"client_url":  "https://nagios.example.com",
+			},
+		},
+		{
+			name: "v2Client",
+			args: []string{
+				"--routing-key", "abc", "--event-action", "resolve", "--dedup-key", "xyz",
+				"--client", "nagios-east", "--client-url", "https://nagios.example.com",
+			},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "abc",
+				"event_action": "resolve",
+				"dedup_key":    "xyz",
+				"client":       "nagios-east",
+				"client_url":   "https://nagios.example.com",
+				"payload": map[string]interface{}{
+					"summary":  "",
+					"source":   "",
+					"severity": "error",
+				},
+			},
+		},
+		{
+			name: "v2KeyName",
+			args: []string{"--key-name", "db", "--event-action", "resolve", "--dedup-key", "xyz"},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "abc",
+				"client":       cmdutil.DefaultClient(),
+				"event_action": "resolve",
+				"dedup_key":    "xyz",
+				"payload": map[string]interface{}{
+					"summary":  "",
+					"source":   "",
+					"severity": "error",
+				},
+			},
+		},
+		{
+			name: "v2KeyNameLiteralPrecedence",
+			args: []string{"--routing-key", "def", "--key-name", "db", "--event-action", "resolve", "--dedup-key", "xyz"},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "def",
+				"client":       cmdutil.DefaultClient(),
+				"event_action": "resolve",
+				"dedup_key":    "xyz",
+				"payload": map[string]interface{}{
+					"summary":  "",
+					"source":   "",
+					"severity": "error",
+				},
+			},
+		},
+		{
+			name: "v2Acknowledge",
+			args: []string{"--routing-key", "abc", "--event-action", "acknowledge", "--dedup-key", "xyz"},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "abc",
+				"client":       cmdutil.DefaultClient(),
+				"event_action": "acknowledge",
+				"dedup_key":    "xyz",
+				"payload": map[string]interface{}{
+					"summary":  "",
+					"source":   "",
+					"severity": "error",
+				},
+			},
+		},
+		{
+			name: "v2Resolve",
+			args: []string{"--routing-key", "abc", "--event-action", "resolve", "--dedup-key", "xyz"},
+			expectedRequestBody: map[string]interface{}{
+				"routing_key":  "abc",
+				"client":       cmdutil.DefaultClient(),
+				"event_action": "resolve",
+				"dedup_key":    "xyz",
+				"payload": map[string]interface{}{
+					"summary":  "",
+					"source":   "",
+					"severity": "error",
+				},
+			},
+		},
+	}
+
+	viper.Set("keys", map[string]string{"db": "abc"})
+	defer viper.Set("keys", nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{
+				Timeout: 5 * time.Minute,
+			}
+
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewSendCmd(realConfig)
+			cmd.SetArgs(tt.args)
+
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").
+				JSON(tt.expectedRequestBody).
+				Reply(200).
+				JSON(map[string]interface{}{"key": "xyz"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if err != nil {
+				t.Errorf("error running command `send`: %v", err)
+			}
+
+			assert.Contains(t, out, `{"key":"xyz"}`)
+		})
+	}
+}
+
+func TestSend_clientConfigDefault(t *testing.T) {
+	defer cmdutil.WithoutAgentMetadata()()
+	viper.Set("client", "web-1 agent")
+	viper.Set("client-url", "https://web-1.example.com")
+	defer viper.Set("client", nil)
+	defer viper.Set("client-url", nil)
+
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{}
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewSendCmd(realConfig)
+	cmd.SetArgs([]string{"-k", "abc", "-t", "trigger", "-d", "description"})
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		JSON(map[string]interface{}{
+			"service_key": "abc",
+			"event_type":  "trigger",
+			"description": "description",
+			"client":      "web-1 agent",
+			"client_url":  "https://web-1.example.com",
+		}).
+		Reply(200).
+		JSON(map[string]interface{}{"key": "xyz"})
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, out, `{"key":"xyz"}`)
+}
+
+func TestSend_agentMetadata(t *testing.T) {
+	tests := []struct {
+		name            string
+		noAgentMetadata bool
+		expectedDetails map[string]interface{}
+	}{
+		{
+			name:            "default",
+			expectedDetails: map[string]interface{}{"a": "b", cmdutil.AgentHostDetail: cmdutil.AgentHost(), cmdutil.AgentPIDDetail: os.Getpid()},
+		},
+		{
+			name:            "disabled",
+			noAgentMetadata: true,
+			expectedDetails: map[string]interface{}{"a": "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("no-agent-metadata", tt.noAgentMetadata)
+			defer viper.Set("no-agent-metadata", false)
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{}
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewSendCmd(realConfig)
+			cmd.SetArgs([]string{"-k", "abc", "-t", "trigger", "-d", "description", "-f", "a=b"})
+
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").
+				JSON(map[string]interface{}{
+					"service_key": "abc",
+					"client":      cmdutil.DefaultClient(),
+					"event_type":  "trigger",
+					"description": "description",
+					"details":     tt.expectedDetails,
+				}).
+				Reply(200).
+				JSON(map[string]interface{}{"key": "xyz"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			assert.NoError(t, err)
+			assert.Contains(t, out, `{"key":"xyz"}`)
+		})
+	}
+}
+
+func TestSend_exitCodes(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		quiet        bool
+		expectedCode int
+		expectedOut  string
+	}{
+		{name: "accepted", status: 200, expectedCode: cmdutil.ExitSuccess, expectedOut: `{"key":"xyz"}`},
+		{name: "acceptedQuiet", status: 200, quiet: true, expectedCode: cmdutil.ExitSuccess},
+		{name: "rejected", status: 400, expectedCode: cmdutil.ExitRejected},
+		{name: "shuttingDown", status: 503, expectedCode: cmdutil.ExitAgentUnreachable},
+		{name: "notConfirmed", status: 500, expectedCode: cmdutil.ExitNotConfirmed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("quiet", tt.quiet)
+			defer viper.Set("quiet", nil)
+
+			defer gock.Off()
+
+			defaultHTTPClient := &http.Client{}
+			realConfig := cmdutil.NewConfig()
+			realConfig.HttpClient = func() (*http.Client, error) {
+				return defaultHTTPClient, nil
+			}
+
+			cmd := NewSendCmd(realConfig)
+			cmd.SetArgs([]string{"-k", "abc", "-t", "trigger", "-d", "description"})
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			gock.New(cmdutil.GetDefaults().Address).
+				Post("/send").
+				Reply(tt.status).
+				JSON(map[string]interface{}{"key": "xyz"})
+
+			gock.InterceptClient(defaultHTTPClient)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			assert.Equal(t, tt.expectedCode, cmdutil.ExitCode(cmd, err))
+			assert.Equal(t, tt.expectedOut, strings.TrimSpace(out))
+		})
+	}
+}
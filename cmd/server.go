@@ -18,16 +18,32 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
 	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/PagerDuty/go-pdagent/pkg/tracing"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
+var allowedRegions = []string{"us", "eu"}
 var errInvalidRegion = errors.New(`region must be either "us" or "eu"`)
+var allowedQueueBackends = []string{"bolt", "sqlite", "memory"}
+var errInvalidQueueBackend = errors.New(`queue-backend must be one of "bolt", "sqlite", or "memory"`)
+var allowedMinSeverities = []string{"info", "warning", "error", "critical"}
+var errInvalidMinSeverity = errors.New(`min-severity must be one of: info, warning, error, critical`)
+var allowedOverflows = []string{persistentqueue.OverflowReject, persistentqueue.OverflowDropOldest}
+var errInvalidOverflow = errors.New(`overflow must be either "reject" or "drop-oldest"`)
+var errInvalidStatsInterval = errors.New("stats-interval must be positive")
 
 func NewServerCmd() *cobra.Command {
 
@@ -44,6 +60,55 @@ func NewServerCmd() *cobra.Command {
 
 	cmd.PersistentFlags().String("database", defaults.Database, "database file for event queuing")
 	cmd.PersistentFlags().String("region", defaults.Region, `PagerDuty region the daemon sends events to, either "us" or "eu"`)
+	cmd.PersistentFlags().String("events-url", "", "base URL to send events to instead of the region's Events API, e.g. an internal event gateway")
+	cmd.PersistentFlags().String("otel-endpoint", "", "OpenTelemetry collector to export traces of each event's enqueue and sends to over OTLP/HTTP, e.g. http://localhost:4318")
+	cmd.PersistentFlags().Bool("metrics-enabled", false, "expose Prometheus-format queue metrics on /metrics")
+	cmd.PersistentFlags().Bool("unauthenticated-probes", false, "allow /health, /healthz, and /readyz without the secret, e.g. for load balancers and Kubernetes probes")
+	cmd.PersistentFlags().Duration("retry-base-delay", defaults.RetryBaseDelay, "initial limit on the random delay before retrying a failed send, doubling with each retry")
+	cmd.PersistentFlags().Duration("retry-max-delay", defaults.RetryMaxDelay, "maximum delay between retries of a failed send")
+	cmd.PersistentFlags().Int("retry-max-attempts", defaults.RetryMaxAttempts, "maximum requests within each of an event's attempts (see max-retries) before that attempt fails")
+	cmd.PersistentFlags().Duration("shutdown-grace-period", defaults.ShutdownGrace, "how long to wait for in-flight events when stopping")
+	cmd.PersistentFlags().Bool("drain", false, "send the queued backlog without accepting new events, then exit, e.g. when decommissioning the host")
+	cmd.PersistentFlags().Duration("drain-timeout", defaults.DrainTimeout, "how long to drain for before exiting non-zero, leaving any events left in the queue")
+	cmd.PersistentFlags().Int("batch-size", defaults.BatchSize, "maximum queued events per routing key a worker picks up at once")
+	cmd.PersistentFlags().Int("max-concurrent-sends", defaults.MaxConcurrent, "maximum concurrent sends per routing key within a batch")
+	cmd.PersistentFlags().Float64("per-key-rate-limit", defaults.PerKeyRateLimit, "maximum sends per second for each routing key, 0 to disable")
+	cmd.PersistentFlags().Int("per-key-burst", defaults.PerKeyBurst, "sends per routing key allowed in a burst above the rate limit")
+	cmd.PersistentFlags().Int("breaker-threshold", defaults.BreakerThreshold, "consecutive failed sends before pausing all sends, 0 to disable")
+	cmd.PersistentFlags().Duration("breaker-cooldown", defaults.BreakerCooldown, "how long sends stay paused before probing for recovery")
+	cmd.PersistentFlags().Duration("worker-stall-timeout", defaults.StallTimeout, "restart a routing key's worker after a send makes no progress for this long, e.g. stuck on a socket, 0 to disable")
+	cmd.PersistentFlags().Int("max-payload-bytes", defaults.MaxPayloadBytes, "truncate custom details of events larger than this many bytes before sending, 0 to disable")
+	cmd.PersistentFlags().Bool("compress", false, "gzip request bodies sent to PagerDuty, e.g. for large custom details over constrained links")
+	cmd.PersistentFlags().Int("compress-min-bytes", defaults.CompressMinBytes, "only compress request bodies of at least this many bytes")
+	cmd.PersistentFlags().StringSlice("header", []string{}, `add a "Name: value" header to every request to PagerDuty, e.g. for a proxy, repeatable`)
+	cmd.PersistentFlags().String("audit-log-path", "", "append a JSON line per event enqueued, delivered, or dead-lettered to this file, with routing keys masked")
+	cmd.PersistentFlags().Int64("audit-log-max-bytes", defaults.AuditLogMaxBytes, "rotate the audit log to <audit-log-path>.1 once it reaches this many bytes, 0 to never rotate")
+	cmd.PersistentFlags().String("queue-backend", "bolt", `queue storage backend, "bolt" or "sqlite" for the database file, or "memory" to lose undelivered events on restart`)
+	cmd.PersistentFlags().String("spool-path", defaults.Spool, "append events to this file should storing them fail, e.g. with the disk full, importing them once the queue recovers, empty to disable")
+	cmd.PersistentFlags().Bool("maintenance", false, "start in maintenance mode, queuing events without sending them")
+	cmd.PersistentFlags().Duration("dedup-window", defaults.DedupWindow, "suppress identical events enqueued within this window, 0 to disable")
+	cmd.PersistentFlags().Duration("event-ttl", defaults.EventTTL, "dead-letter events not sent within this long of being enqueued rather than sending them late, 0 to never expire")
+	cmd.PersistentFlags().Duration("resolve-event-ttl", defaults.ResolveEventTTL, "event-ttl for resolve events, 0 to never expire")
+	cmd.PersistentFlags().Int("max-retries", defaults.MaxRetries, "attempts to deliver an event, resending retryable failures after a growing delay, before dead-lettering it; each attempt is retried up to retry-max-attempts times")
+	cmd.PersistentFlags().Int("max-queue-depth", defaults.MaxQueueDepth, "most events waiting to be sent, including those scheduled for a retry, before triggers overflow; acknowledges and resolves are always accepted; 0 for no limit")
+	cmd.PersistentFlags().String("overflow", defaults.Overflow, `what to do with a trigger enqueued at max-queue-depth: "reject" it with a 503, or "drop-oldest" to drop the oldest of the lowest priority triggers waiting`)
+	cmd.PersistentFlags().String("stats-file-path", "", "write a JSON snapshot of the queue's depth, failures, and last success to this file every stats-interval, empty to disable")
+	cmd.PersistentFlags().Duration("stats-interval", defaults.StatsInterval, "how often to write the stats file")
+	cmd.PersistentFlags().Int("failure-alert-threshold", defaults.AlertThreshold, "alert locally once this many sends in a row have failed to reach PagerDuty for failure-alert-duration, once until a send succeeds; 0 to disable")
+	cmd.PersistentFlags().Duration("failure-alert-duration", defaults.AlertDuration, "how long sends must have been failing for to alert")
+	cmd.PersistentFlags().String("failure-alert-path", "", "append a JSON line to this file for each failure alert")
+	cmd.PersistentFlags().String("failure-alert-exec", "", "command run for each failure alert, e.g. to send an email, with the alert in PD_ALERT_MESSAGE")
+	cmd.PersistentFlags().String("min-severity", "", `drop triggers below this severity, e.g. "warning" to drop info events, rather than enqueuing them; acknowledges and resolves are never dropped`)
+	cmd.PersistentFlags().String("on-success-exec", "", "command run after each delivered event, with {{.DedupKey}}, {{.EventID}}, and {{.RoutingKey}} replaced in its arguments")
+	cmd.PersistentFlags().String("ingest-token", "", "enable the /ingest endpoint for generic JSON alerts, requiring this token in the X-Agent-Token header")
+	cmd.PersistentFlags().Float64("ingest-rate-limit", defaults.IngestRateLimit, "maximum events per second accepted across /send, /ingest, /alertmanager, and /datadog before responding 429, 0 to disable")
+	cmd.PersistentFlags().Int("ingest-burst", defaults.IngestBurst, "events accepted in a burst above the ingest rate limit")
+	cmd.PersistentFlags().String("alertmanager-routing-key", "", "routing key for Alertmanager webhooks without a pagerduty_routing_key label")
+	cmd.PersistentFlags().String("datadog-routing-key", "", "routing key for Datadog webhooks without a routing_key query parameter")
+	cmd.PersistentFlags().String("ca-cert-file", "", "PEM file of additional CAs to trust for outgoing requests")
+	cmd.PersistentFlags().String("client-cert-file", "", "PEM client certificate for outgoing requests requiring mutual TLS")
+	cmd.PersistentFlags().String("client-key-file", "", "PEM private key for the client certificate")
+	cmd.PersistentFlags().Bool("insecure-skip-verify", false, "disable TLS certificate verification for outgoing requests, only for testing against a mock endpoint")
 
 	if err := viper.BindPFlag("database", cmd.PersistentFlags().Lookup("database")); err != nil {
 		fmt.Println(err)
@@ -51,28 +116,337 @@ func NewServerCmd() *cobra.Command {
 	if err := viper.BindPFlag("region", cmd.PersistentFlags().Lookup("region")); err != nil {
 		fmt.Println(err)
 	}
+	if err := viper.BindPFlag("events-url", cmd.PersistentFlags().Lookup("events-url")); err != nil {
+		fmt.Println(err)
+	}
+
+	if err := viper.BindPFlag("otel-endpoint", cmd.PersistentFlags().Lookup("otel-endpoint")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("metrics-enabled", cmd.PersistentFlags().Lookup("metrics-enabled")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("unauthenticated-probes", cmd.PersistentFlags().Lookup("unauthenticated-probes")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("retry-base-delay", cmd.PersistentFlags().Lookup("retry-base-delay")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("retry-max-delay", cmd.PersistentFlags().Lookup("retry-max-delay")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("retry-max-attempts", cmd.PersistentFlags().Lookup("retry-max-attempts")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("shutdown-grace-period", cmd.PersistentFlags().Lookup("shutdown-grace-period")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("drain", cmd.PersistentFlags().Lookup("drain")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("drain-timeout", cmd.PersistentFlags().Lookup("drain-timeout")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("batch-size", cmd.PersistentFlags().Lookup("batch-size")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("max-concurrent-sends", cmd.PersistentFlags().Lookup("max-concurrent-sends")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("per-key-rate-limit", cmd.PersistentFlags().Lookup("per-key-rate-limit")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("per-key-burst", cmd.PersistentFlags().Lookup("per-key-burst")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("breaker-threshold", cmd.PersistentFlags().Lookup("breaker-threshold")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("breaker-cooldown", cmd.PersistentFlags().Lookup("breaker-cooldown")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("worker-stall-timeout", cmd.PersistentFlags().Lookup("worker-stall-timeout")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("max-payload-bytes", cmd.PersistentFlags().Lookup("max-payload-bytes")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("compress", cmd.PersistentFlags().Lookup("compress")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("compress-min-bytes", cmd.PersistentFlags().Lookup("compress-min-bytes")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("headers", cmd.PersistentFlags().Lookup("header")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("audit-log-path", cmd.PersistentFlags().Lookup("audit-log-path")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("audit-log-max-bytes", cmd.PersistentFlags().Lookup("audit-log-max-bytes")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("queue-backend", cmd.PersistentFlags().Lookup("queue-backend")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("spool-path", cmd.PersistentFlags().Lookup("spool-path")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("maintenance", cmd.PersistentFlags().Lookup("maintenance")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("dedup-window", cmd.PersistentFlags().Lookup("dedup-window")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("event-ttl", cmd.PersistentFlags().Lookup("event-ttl")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("resolve-event-ttl", cmd.PersistentFlags().Lookup("resolve-event-ttl")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("max-retries", cmd.PersistentFlags().Lookup("max-retries")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("max-queue-depth", cmd.PersistentFlags().Lookup("max-queue-depth")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("overflow", cmd.PersistentFlags().Lookup("overflow")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("stats-file-path", cmd.PersistentFlags().Lookup("stats-file-path")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("stats-interval", cmd.PersistentFlags().Lookup("stats-interval")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("failure-alert-threshold", cmd.PersistentFlags().Lookup("failure-alert-threshold")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("failure-alert-duration", cmd.PersistentFlags().Lookup("failure-alert-duration")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("failure-alert-path", cmd.PersistentFlags().Lookup("failure-alert-path")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("failure-alert-exec", cmd.PersistentFlags().Lookup("failure-alert-exec")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("min-severity", cmd.PersistentFlags().Lookup("min-severity")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("on-success-exec", cmd.PersistentFlags().Lookup("on-success-exec")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("ingest-token", cmd.PersistentFlags().Lookup("ingest-token")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("ingest-rate-limit", cmd.PersistentFlags().Lookup("ingest-rate-limit")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("ingest-burst", cmd.PersistentFlags().Lookup("ingest-burst")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("alertmanager-routing-key", cmd.PersistentFlags().Lookup("alertmanager-routing-key")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("datadog-routing-key", cmd.PersistentFlags().Lookup("datadog-routing-key")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("ca-cert-file", cmd.PersistentFlags().Lookup("ca-cert-file")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("client-cert-file", cmd.PersistentFlags().Lookup("client-cert-file")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("client-key-file", cmd.PersistentFlags().Lookup("client-key-file")); err != nil {
+		fmt.Println(err)
+	}
+	if err := viper.BindPFlag("insecure-skip-verify", cmd.PersistentFlags().Lookup("insecure-skip-verify")); err != nil {
+		fmt.Println(err)
+	}
 
+	cmd.AddCommand(NewServerReloadCmd())
 	cmd.AddCommand(NewServerStopCmd())
 
 	return cmd
 }
 
 func runServerCommand() error {
-	address := viper.GetString("address")
+	address, err := cmdutil.ServerAddress()
+	if err != nil {
+		return err
+	}
 	database := viper.GetString("database")
 	pidfile := viper.GetString("pidfile")
 	secret := viper.GetString("secret")
 	region := viper.GetString("region")
+	metricsEnabled := viper.GetBool("metrics-enabled")
 
-	allowedRegions := []string{"us", "eu"}
 	if err := cmdutil.ValidateEnumField(region, allowedRegions, errInvalidRegion); err != nil {
 		return err
 	}
+	if err := cmdutil.ValidateEventsURL(viper.GetString("events-url")); err != nil {
+		return err
+	}
+	otelEndpoint := viper.GetString("otel-endpoint")
+	if err := cmdutil.ValidateOTelEndpoint(otelEndpoint); err != nil {
+		return err
+	}
+
+	queueBackend := viper.GetString("queue-backend")
+	if err := cmdutil.ValidateEnumField(queueBackend, allowedQueueBackends, errInvalidQueueBackend); err != nil {
+		return err
+	}
+
+	minSeverity := viper.GetString("min-severity")
+	if minSeverity != "" {
+		if err := cmdutil.ValidateEnumField(minSeverity, allowedMinSeverities, errInvalidMinSeverity); err != nil {
+			return err
+		}
+	}
+
+	overflow := viper.GetString("overflow")
+	if err := cmdutil.ValidateEnumField(overflow, allowedOverflows, errInvalidOverflow); err != nil {
+		return err
+	}
+
+	statsFilePath := viper.GetString("stats-file-path")
+	statsInterval := viper.GetDuration("stats-interval")
+	if statsFilePath != "" && statsInterval <= 0 {
+		return errInvalidStatsInterval
+	}
+
+	baseTransport, err := common.NewTransport(viper.GetString("proxy-url"))
+	if err != nil {
+		return err
+	}
+	cmdutil.ConnPool().Apply(baseTransport)
+
+	tlsConfig := common.TLSConfig{
+		CACertFile:         viper.GetString("ca-cert-file"),
+		ClientCertFile:     viper.GetString("client-cert-file"),
+		ClientKeyFile:      viper.GetString("client-key-file"),
+		InsecureSkipVerify: viper.GetBool("insecure-skip-verify"),
+	}
+	baseTransport.TLSClientConfig, err = tlsConfig.Build()
+	if err != nil {
+		return err
+	}
+
+	// Configured headers go on every request to PagerDuty, including
+	// heartbeats.
+	var pagerDutyTransport http.RoundTripper = baseTransport
+	headers, err := common.ParseHeaders(viper.GetStringSlice("headers"))
+	if err != nil {
+		return err
+	}
+	if len(headers) > 0 {
+		pagerDutyTransport = common.NewHeaderTransport(baseTransport, headers)
+	}
+
+	// Traced beneath retries, so each attempt sending an event is a span.
+	sendTransport := pagerDutyTransport
+	var tracerProvider *sdktrace.TracerProvider
+	if otelEndpoint != "" {
+		tracerProvider, err = tracing.NewTracerProvider(otelEndpoint, &http.Client{Transport: baseTransport, Timeout: 30 * time.Second})
+		if err != nil {
+			return err
+		}
+		otel.SetTracerProvider(tracerProvider)
+		sendTransport = tracing.NewTransport(pagerDutyTransport)
+	}
+
+	// Swappable so retry settings can be reloaded.
+	retryTransport := newRetryTransport(sendTransport, nil)
+	transport := common.NewSwapTransport(retryTransport)
+
+	// Compressing once per send, with retries resending the compressed body.
+	var eventsTransport http.RoundTripper = transport
+	if viper.GetBool("compress") {
+		eventsTransport = common.NewCompressTransport(transport, viper.GetInt("compress-min-bytes"))
+	}
 
-	queue := persistentqueue.NewPersistentQueue(persistentqueue.WithFile(database))
+	eventQueue := eventqueue.NewEventQueue()
+	eventQueue.Processor = eventqueue.NewEventProcessor(
+		eventsapi.WithHTTPClient(eventsapi.NewHTTPClient(eventsTransport)),
+		eventsapi.WithMaxPayloadBytes(viper.GetInt("max-payload-bytes")),
+	)
+	eventQueue.BatchSize = viper.GetInt("batch-size")
+	eventQueue.MaxConcurrentSends = viper.GetInt("max-concurrent-sends")
+	eventQueue.PerKeyRateLimit = viper.GetFloat64("per-key-rate-limit")
+	eventQueue.PerKeyBurst = viper.GetInt("per-key-burst")
+	if threshold := viper.GetInt("breaker-threshold"); threshold > 0 {
+		eventQueue.Breaker = eventqueue.NewCircuitBreaker(threshold, viper.GetDuration("breaker-cooldown"))
+	}
+	eventQueue.WorkerStallTimeout = viper.GetDuration("worker-stall-timeout")
 
-	server := server.NewServer(address, secret, pidfile, queue)
-	err := server.Start()
+	queueOptions := []persistentqueue.Option{
+		persistentqueue.WithFile(database),
+		persistentqueue.WithEventQueue(eventQueue),
+		persistentqueue.WithShutdownGracePeriod(viper.GetDuration("shutdown-grace-period")),
+		persistentqueue.WithDedupWindow(viper.GetDuration("dedup-window")),
+		persistentqueue.WithEventTTL(viper.GetDuration("event-ttl"), viper.GetDuration("resolve-event-ttl")),
+		persistentqueue.WithMaxRetries(viper.GetInt("max-retries")),
+		persistentqueue.WithRetryDelay(viper.GetDuration("retry-base-delay"), viper.GetDuration("retry-max-delay")),
+		persistentqueue.WithMaxQueueDepth(viper.GetInt("max-queue-depth"), overflow),
+		persistentqueue.WithMinSeverity(minSeverity),
+		persistentqueue.WithSpool(viper.GetString("spool-path")),
+		persistentqueue.WithStatsFile(statsFilePath, statsInterval),
+		persistentqueue.WithFailureAlert(persistentqueue.FailureAlert{
+			Threshold: viper.GetInt("failure-alert-threshold"),
+			Duration:  viper.GetDuration("failure-alert-duration"),
+			Path:      viper.GetString("failure-alert-path"),
+			Command:   viper.GetString("failure-alert-exec"),
+		}),
+		persistentqueue.WithMaintenance(viper.GetBool("maintenance")),
+	}
+	switch queueBackend {
+	case "sqlite":
+		queueOptions = append(queueOptions, persistentqueue.WithSQLite())
+	case "memory":
+		queueOptions = append(queueOptions, persistentqueue.WithMemory())
+	}
+	if command := viper.GetString("on-success-exec"); command != "" {
+		hook, err := persistentqueue.NewExecHook(command)
+		if err != nil {
+			return err
+		}
+		queueOptions = append(queueOptions, persistentqueue.WithSuccessHook(hook))
+	}
+	if auditLogPath := viper.GetString("audit-log-path"); auditLogPath != "" {
+		auditLog, err := persistentqueue.NewAuditLog(auditLogPath, viper.GetInt64("audit-log-max-bytes"))
+		if err != nil {
+			return err
+		}
+		queueOptions = append(queueOptions, persistentqueue.WithAuditLog(auditLog))
+	}
+	queue := persistentqueue.NewPersistentQueue(queueOptions...)
+
+	common.RegisterSecret(viper.GetString("ingest-token"))
+	common.RegisterSecret(viper.GetString("alertmanager-routing-key"))
+	common.RegisterSecret(viper.GetString("datadog-routing-key"))
+
+	// Assigned once the server it reloads exists.
+	var reloader *serverReloader
+	serverOptions := []server.Option{
+		server.WithReloader(func() error { return reloader.reload() }),
+		server.WithMetricsEnabled(metricsEnabled),
+		server.WithUnauthenticatedProbes(viper.GetBool("unauthenticated-probes")),
+		server.WithTransport(pagerDutyTransport),
+		server.WithIngestToken(viper.GetString("ingest-token")),
+		server.WithSigningSecret(viper.GetString("signing-secret")),
+		server.WithAlertmanagerRoutingKey(viper.GetString("alertmanager-routing-key")),
+		server.WithDatadogRoutingKey(viper.GetString("datadog-routing-key")),
+		server.WithCircuitBreaker(eventQueue.Breaker),
+		server.WithIngestRateLimit(viper.GetFloat64("ingest-rate-limit"), viper.GetInt("ingest-burst")),
+		server.WithTracerProvider(tracerProvider),
+	}
+	if viper.GetBool("drain") {
+		serverOptions = append(serverOptions, server.WithDrain(viper.GetDuration("drain-timeout")))
+	}
+	server := server.NewServer(address, secret, pidfile, queue, serverOptions...)
+	reloader = newServerReloader(server, eventQueue, transport, retryTransport.Gate, sendTransport)
+	err = server.Start()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -0,0 +1,145 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// restartSettings can't be changed without restarting the server, so changes
+// to them are ignored on reload.
+var restartSettings = []string{"address", "database", "otel-endpoint", "pidfile", "port", "queue-backend"}
+
+func NewServerReloadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reload",
+		Short: "Reload a running pdagent server's configuration.",
+		Long: `Signals a running pdagent server to re-read its config file and apply
+changes to settings that are safe to change while running: the log level,
+ingest and per routing key rate limits, default routing keys for webhooks,
+and retry backoff. Events queued and the listening address are unaffected.
+
+Changes to the address, port, database, pidfile, queue backend, or OTel
+endpoint are logged as ignored, and need a restart. Settings given as flags when starting the
+server take precedence over the config file, so can't be reloaded.
+
+The server's log records the outcome of the reload.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReloadCommand()
+		},
+	}
+
+	return cmd
+}
+
+func runReloadCommand() error {
+	pidfile := viper.GetString("pidfile")
+
+	if err := common.ReloadProcess(pidfile); err != nil {
+		if err == common.ErrPidfileDoesntExist {
+			fmt.Println("This normally means a server isn't currently running, or you're running this command using a different configuration.")
+		}
+		return fmt.Errorf("error reloading server: %w", err)
+	}
+
+	fmt.Println("Server signalled to reload, see its log for the outcome.")
+	return nil
+}
+
+// serverReloader applies configuration changes to a running server's
+// components.
+type serverReloader struct {
+	server     *server.Server
+	eventQueue *eventqueue.EventQueue
+
+	// The retry transport is rebuilt around `pagerDuty`, keeping its gate so
+	// a pending `Retry-After` still applies.
+	retry     *common.SwapTransport
+	retryGate *common.RetryGate
+	pagerDuty http.RoundTripper
+
+	started map[string]string
+}
+
+func newServerReloader(s *server.Server, eventQueue *eventqueue.EventQueue, retry *common.SwapTransport, retryGate *common.RetryGate, pagerDuty http.RoundTripper) *serverReloader {
+	started := make(map[string]string, len(restartSettings))
+	for _, name := range restartSettings {
+		started[name] = viper.GetString(name)
+	}
+
+	return &serverReloader{
+		server:     s,
+		eventQueue: eventQueue,
+		retry:      retry,
+		retryGate:  retryGate,
+		pagerDuty:  pagerDuty,
+		started:    started,
+	}
+}
+
+// newRetryTransport returns the transport retrying sends to PagerDuty, as
+// configured.
+func newRetryTransport(pagerDuty http.RoundTripper, gate *common.RetryGate) common.RetryTransport {
+	transport := common.NewRetryTransport()
+	transport.Transport = pagerDuty
+	transport.BaseInterval = viper.GetDuration("retry-base-delay")
+	transport.MaxInterval = viper.GetDuration("retry-max-delay")
+	transport.MaxRetries = viper.GetInt("retry-max-attempts")
+	if gate != nil {
+		transport.Gate = gate
+	}
+	return transport
+}
+
+// reload re-reads the config file, applying changes to settings that are
+// safe to change while running.
+func (r *serverReloader) reload() error {
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	// Validated before anything else is changed.
+	if err := common.SetLogLevel(viper.GetString("log-level")); err != nil {
+		return err
+	}
+
+	r.server.SetIngestRateLimit(viper.GetFloat64("ingest-rate-limit"), viper.GetInt("ingest-burst"))
+	r.eventQueue.SetPerKeyRateLimit(viper.GetFloat64("per-key-rate-limit"), viper.GetInt("per-key-burst"))
+
+	alertmanagerKey := viper.GetString("alertmanager-routing-key")
+	datadogKey := viper.GetString("datadog-routing-key")
+	common.RegisterSecret(alertmanagerKey)
+	common.RegisterSecret(datadogKey)
+	r.server.SetDefaultRoutingKeys(alertmanagerKey, datadogKey)
+
+	r.retry.Swap(newRetryTransport(r.pagerDuty, r.retryGate))
+
+	logger := common.Logger.Named("Server")
+	for _, name := range restartSettings {
+		if viper.GetString(name) != r.started[name] {
+			logger.Warnf("Ignoring change to %v on reload, restart the server to apply it.", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/spf13/viper"
+	"go.uber.org/zap/zapcore"
+)
+
+// useConfigFile points viper at a config file with `contents`, returning a
+// function clearing it.
+func useConfigFile(t *testing.T, contents string) func() {
+	dir, err := ioutil.TempDir("", "pdagent-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(file, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(file)
+
+	return func() {
+		// Reading an empty config drops the test's settings.
+		_ = ioutil.WriteFile(file, nil, 0600)
+		_ = viper.ReadInConfig()
+		viper.SetConfigFile("")
+		os.RemoveAll(dir)
+	}
+}
+
+func TestServerReload(t *testing.T) {
+	if err := common.InitLogger("", "info"); err != nil {
+		t.Fatal(err)
+	}
+	defer common.InitLogger("", "")
+	logger := common.Logger.Named("Server").Desugar()
+
+	eventQueue := eventqueue.NewEventQueue()
+	defer eventQueue.Shutdown()
+	s := server.NewServer("127.0.0.1:0", "secret", "", persistentqueue.NewPersistentQueue())
+	retryTransport := newRetryTransport(http.DefaultTransport, nil)
+	reloader := newServerReloader(s, eventQueue, common.NewSwapTransport(retryTransport), retryTransport.Gate, http.DefaultTransport)
+
+	defer useConfigFile(t, "log-level: error\nper-key-rate-limit: 5\nqueue-backend: memory\n")()
+
+	if !logger.Core().Enabled(zapcore.InfoLevel) {
+		t.Fatal("Expected info logging before reload.")
+	}
+
+	if err := reloader.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if logger.Core().Enabled(zapcore.InfoLevel) {
+		t.Error("Expected info logging to be disabled after reloading with an error log level.")
+	}
+	if !logger.Core().Enabled(zapcore.ErrorLevel) {
+		t.Error("Expected error logging after reload.")
+	}
+	if eventQueue.PerKeyRateLimit != 5 {
+		t.Errorf("Expected per-key rate limit of 5 after reload, was %v.", eventQueue.PerKeyRateLimit)
+	}
+}
+
+func TestServerReload_invalidLogLevel(t *testing.T) {
+	if err := common.InitLogger("", "info"); err != nil {
+		t.Fatal(err)
+	}
+	defer common.InitLogger("", "")
+	logger := common.Logger.Named("Server").Desugar()
+
+	eventQueue := eventqueue.NewEventQueue()
+	defer eventQueue.Shutdown()
+	s := server.NewServer("127.0.0.1:0", "secret", "", persistentqueue.NewPersistentQueue())
+	retryTransport := newRetryTransport(http.DefaultTransport, nil)
+	reloader := newServerReloader(s, eventQueue, common.NewSwapTransport(retryTransport), retryTransport.Gate, http.DefaultTransport)
+
+	defer useConfigFile(t, "log-level: loud\nper-key-rate-limit: 5\n")()
+
+	if err := reloader.reload(); err == nil {
+		t.Error("Expected an error reloading an invalid log level.")
+	}
+	if !logger.Core().Enabled(zapcore.InfoLevel) {
+		t.Error("Expected the log level to be unchanged.")
+	}
+	if eventQueue.PerKeyRateLimit != 0 {
+		t.Errorf("Expected no settings to be applied, per-key rate limit was %v.", eventQueue.PerKeyRateLimit)
+	}
+}
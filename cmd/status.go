@@ -41,7 +41,11 @@ func NewQueueStatusCmd(config *cmdutil.Config) *cobra.Command {
 }
 
 func runStatusCommand(config *cmdutil.Config, routingKey string) error {
-	c, _ := config.Client()
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	resp, err := c.QueueStatus(routingKey)
 	if err != nil {
@@ -0,0 +1,150 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var errTestConnectionKey = errors.New("either routing-key or key-name must be set")
+var errConnectionFailed = errors.New("unable to reach PagerDuty")
+var errConnectionRejected = errors.New("PagerDuty rejected the test event, check the routing key")
+var errConnectionUnavailable = errors.New("PagerDuty is currently unable to accept events")
+
+// testConnectionDedupKeyPrefix prefixes the random dedup key of test events,
+// making them easy to recognize should they show up anywhere.
+const testConnectionDedupKeyPrefix = "pdagent-test-connection-"
+
+type testConnectionInput struct {
+	routingKey string
+	keyName    string
+	region     string
+	eventsURL  string
+	timeout    time.Duration
+}
+
+func NewTestConnectionCmd() *cobra.Command {
+	var cmdInput testConnectionInput
+
+	cmd := &cobra.Command{
+		Use:   "test-connection",
+		Short: "Verify PagerDuty is reachable and accepts a routing key.",
+		Long: `Verify PagerDuty is reachable and accepts a routing key.
+
+Sends a resolve for a random dedup key directly to the Events API, bypassing
+the daemon. As no incident has that key the event has no effect, but PagerDuty
+still validates the routing key.
+
+Reports the HTTP status and latency, exiting with an error if PagerDuty can't
+be reached, rejects the event, or is otherwise unable to accept it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmdInput.routingKey == "" && cmdInput.keyName == "" {
+				return errTestConnectionKey
+			}
+
+			// The region and events URL otherwise come from the config file,
+			// as shared with the daemon.
+			if cmdInput.region != "" {
+				if err := cmdutil.ValidateEnumField(cmdInput.region, allowedRegions, errInvalidRegion); err != nil {
+					return err
+				}
+				viper.Set("region", cmdInput.region)
+			}
+			if cmdInput.eventsURL != "" {
+				if err := cmdutil.ValidateEventsURL(cmdInput.eventsURL); err != nil {
+					return err
+				}
+				viper.Set("events-url", cmdInput.eventsURL)
+			}
+
+			routingKey, err := cmdutil.ResolveNamedKey(cmdInput.routingKey, cmdInput.keyName)
+			if err != nil {
+				return err
+			}
+
+			return runTestConnection(routingKey, cmdInput.timeout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&cmdInput.routingKey, "routing-key", "k", "", "Events API routing key to test, or @FILE or env:VAR to read it from a file or environment variable")
+	cmd.Flags().StringVar(&cmdInput.keyName, "key-name", "", "Name of a key configured under keys or severity-routes, used if no routing-key is given")
+	cmd.Flags().StringVar(&cmdInput.region, "region", "", `PagerDuty region to test, either "us" or "eu", overriding the config file`)
+	cmd.Flags().StringVar(&cmdInput.eventsURL, "events-url", "", "Base URL to test instead of the region's Events API, overriding the config file")
+	cmd.Flags().DurationVar(&cmdInput.timeout, "timeout", 10*time.Second, "How long to wait for PagerDuty to respond")
+
+	return cmd
+}
+
+func runTestConnection(routingKey string, timeout time.Duration) error {
+	// Deliberately not a `common.RetryTransport`: retries would hide failures
+	// and skew the reported latency.
+	client := eventsapi.NewHTTPClient(http.DefaultTransport)
+	client.Timeout = timeout
+
+	event := eventsapi.EventV2{
+		RoutingKey:  routingKey,
+		EventAction: "resolve",
+		DedupKey:    testConnectionDedupKeyPrefix + common.GenerateKey(),
+		Payload: eventsapi.PayloadV2{
+			Summary:  "PagerDuty Agent connection test",
+			Source:   "pdagent",
+			Severity: "info",
+		},
+	}
+
+	url := common.PdEventsUrl()
+	fmt.Printf("Testing connection to %v...\n", url)
+
+	start := time.Now()
+	resp, err := eventsapi.EnqueueV2(context.Background(), client, &event)
+	latency := time.Since(start).Round(time.Millisecond)
+
+	httpResp := resp.GetHTTPResponse()
+	if httpResp == nil {
+		fmt.Printf("Connection failed after %v: %v\n", latency, err)
+		return errConnectionFailed
+	}
+
+	fmt.Printf("HTTP status:          %v\n", httpResp.Status)
+	fmt.Printf("Latency:              %v\n", latency)
+	if resp.Message != "" {
+		fmt.Printf("Message:              %v\n", resp.Message)
+	}
+	if len(resp.Errors) > 0 {
+		fmt.Printf("Errors:               %v\n", strings.Join(resp.Errors, "; "))
+	}
+
+	switch {
+	case err == nil:
+		fmt.Println("Connection OK.")
+		return nil
+	case httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode >= 500:
+		return errConnectionUnavailable
+	default:
+		return errConnectionRejected
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestTestConnection(t *testing.T) {
+	routingKey := "11863b592c824bfc8989d9cba76abcde"
+
+	tests := []struct {
+		name          string
+		mock          func(*gock.Request)
+		expectedError error
+		expectedOut   []string
+	}{
+		{
+			name: "success",
+			mock: func(r *gock.Request) {
+				r.Reply(202).JSON(map[string]interface{}{"status": "success", "message": "Event processed"})
+			},
+			expectedOut: []string{"HTTP status:          202 Accepted", "Latency:", "Connection OK."},
+		},
+		{
+			name: "invalidKey",
+			mock: func(r *gock.Request) {
+				r.Reply(400).JSON(map[string]interface{}{
+					"status":  "invalid event",
+					"message": "Event object is invalid",
+					"errors":  []string{"Invalid routing key"},
+				})
+			},
+			expectedError: errConnectionRejected,
+			expectedOut:   []string{"HTTP status:          400 Bad Request", "Errors:               Invalid routing key"},
+		},
+		{
+			name: "unavailable",
+			mock: func(r *gock.Request) {
+				r.Reply(503)
+			},
+			expectedError: errConnectionUnavailable,
+			expectedOut:   []string{"HTTP status:          503 Service Unavailable"},
+		},
+		{
+			name: "networkFailure",
+			mock: func(r *gock.Request) {
+				r.ReplyError(errors.New("connection refused"))
+			},
+			expectedError: errConnectionFailed,
+			expectedOut:   []string{"Connection failed after", "connection refused"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+
+			var body map[string]interface{}
+			req := gock.New("https://events.pagerduty.com").
+				Post("/v2/enqueue").
+				AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+					return true, json.NewDecoder(req.Body).Decode(&body)
+				})
+			tt.mock(req)
+
+			cmd := NewTestConnectionCmd()
+			cmd.SetArgs([]string{"--routing-key", routingKey})
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			assert.Equal(t, tt.expectedError, err)
+			assert.True(t, gock.IsDone())
+			for _, expected := range tt.expectedOut {
+				assert.Contains(t, out, expected)
+			}
+
+			assert.Equal(t, routingKey, body["routing_key"])
+			assert.Equal(t, "resolve", body["event_action"])
+			assert.True(t, strings.HasPrefix(body["dedup_key"].(string), testConnectionDedupKeyPrefix))
+		})
+	}
+}
+
+func TestTestConnection_missingKey(t *testing.T) {
+	cmd := NewTestConnectionCmd()
+	cmd.SetArgs([]string{})
+
+	_, err := cmd.ExecuteC()
+	assert.Equal(t, errTestConnectionKey, err)
+}
+
+func TestTestConnection_region(t *testing.T) {
+	defer viper.Set("region", nil)
+
+	tests := []struct {
+		region      string
+		expectedURL string
+	}{
+		{"us", "https://events.pagerduty.com"},
+		{"eu", "https://events.eu.pagerduty.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			defer gock.Off()
+
+			gock.New(tt.expectedURL).
+				Post("/v2/enqueue").
+				Reply(202).
+				JSON(map[string]interface{}{"status": "success", "message": "Event processed"})
+
+			cmd := NewTestConnectionCmd()
+			cmd.SetArgs([]string{"--routing-key", "11863b592c824bfc8989d9cba76abcde", "--region", tt.region})
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			assert.NoError(t, err)
+			assert.True(t, gock.IsDone())
+			assert.Contains(t, out, "Testing connection to "+tt.expectedURL)
+		})
+	}
+}
+
+func TestTestConnection_invalidRegion(t *testing.T) {
+	cmd := NewTestConnectionCmd()
+	cmd.SetArgs([]string{"--routing-key", "11863b592c824bfc8989d9cba76abcde", "--region", "apac"})
+
+	_, err := cmd.ExecuteC()
+	assert.Equal(t, errInvalidRegion, err)
+}
+
+func TestTestConnection_eventsURL(t *testing.T) {
+	defer viper.Set("events-url", nil)
+	defer gock.Off()
+
+	gock.New("https://gateway.example.com").
+		Post("/pagerduty/v2/enqueue").
+		Reply(202).
+		JSON(map[string]interface{}{"status": "success", "message": "Event processed"})
+
+	cmd := NewTestConnectionCmd()
+	cmd.SetArgs([]string{"--routing-key", "11863b592c824bfc8989d9cba76abcde", "--region", "eu", "--events-url", "https://gateway.example.com/pagerduty/"})
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Contains(t, out, "Testing connection to https://gateway.example.com/pagerduty")
+}
+
+func TestTestConnection_invalidEventsURL(t *testing.T) {
+	cmd := NewTestConnectionCmd()
+	cmd.SetArgs([]string{"--routing-key", "11863b592c824bfc8989d9cba76abcde", "--events-url", "gateway.example.com"})
+
+	_, err := cmd.ExecuteC()
+	assert.EqualError(t, err, `events-url "gateway.example.com" must be an absolute http or https URL`)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/cobra"
+)
+
+var errValidateKeyVersion = errors.New(`events-api-version must be either "v1" or "v2"`)
+
+func NewValidateKeyCmd() *cobra.Command {
+	var eventsAPIVersion string
+
+	cmd := &cobra.Command{
+		Use:   "validate-key <key>",
+		Short: "Check a routing or service key looks valid.",
+		Long: `Check a key's length and characters match those of a v2 routing key, 32
+hexadecimal characters, or with --events-api-version v1 a service key, 32
+letters or digits, catching typos before an event fails to send.
+
+The key may be given as @FILE or env:VAR, as for other commands. PagerDuty
+isn't contacted, so a key that looks valid may still not exist; use
+"test-connection" to check that.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, ok := eventsapi.StringToEventVersion[eventsAPIVersion]
+			if !ok || version == eventsapi.EventVersionChange2 {
+				return &cmdutil.ExitError{Code: cmdutil.ExitValidation, Err: errValidateKeyVersion}
+			}
+
+			key, err := cmdutil.ResolveKey(args[0])
+			if err != nil {
+				return &cmdutil.ExitError{Code: cmdutil.ExitValidation, Err: err}
+			}
+			if err := cmdutil.ValidateKeyFormat(key, version); err != nil {
+				return &cmdutil.ExitError{Code: cmdutil.ExitValidation, Err: err}
+			}
+
+			kind := "routing key"
+			if version == eventsapi.EventVersion1 {
+				kind = "service key"
+			}
+			fmt.Printf("%v looks like a valid %v %v.\n", common.MaskSecret(key), version, kind)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&eventsAPIVersion, "events-api-version", eventsapi.EventVersion2.String(), `The Events API version the key is for, either "v1" or "v2"`)
+
+	return cmd
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+		err      string
+	}{
+		{"v2", []string{"11863b592c824bfc8989d9cba76abcde"}, "11****de looks like a valid v2 routing key.\n", ""},
+		{"v2Malformed", []string{"11863b592c824bfc8989d9cba76abcdz"}, "", `routing key 11****dz may only contain hexadecimal characters, found 'z'`},
+		{"v1", []string{"--events-api-version", "v1", "a1863b592c824bfc8989d9cba76abxyz"}, "a1****yz looks like a valid v1 service key.\n", ""},
+		{"v1Malformed", []string{"--events-api-version", "v1", "a1863b592c824bfc"}, "", "service key a1****fc is 16 characters long, expected 32"},
+		{"unknownVersion", []string{"--events-api-version", "v3", "11863b592c824bfc8989d9cba76abcde"}, "", errValidateKeyVersion.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewValidateKeyCmd()
+			cmd.SetArgs(tt.args)
+
+			out, err := test.CaptureStdout(func() error {
+				_, err := cmd.ExecuteC()
+				return err
+			})
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, out)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.err, err.Error())
+				assert.Equal(t, cmdutil.ExitValidation, cmdutil.ExitCode(cmd, err))
+			}
+		})
+	}
+}
@@ -18,7 +18,7 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/version"
 	"github.com/spf13/cobra"
 )
 
@@ -27,9 +27,9 @@ func NewVersionCmd() *cobra.Command {
 		Use:   "version",
 		Short: "Version and build information.",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("Version: %v\n", common.Version)
-			fmt.Printf("Build date: %v\n", common.Date)
-			fmt.Printf("Build commit: %v\n", common.Commit)
+			fmt.Printf("Version: %v\n", version.Version)
+			fmt.Printf("Build date: %v\n", version.Date)
+			fmt.Printf("Build commit: %v\n", version.Commit)
 		},
 	}
 }
@@ -16,13 +16,22 @@ limitations under the License.
 package cmd
 
 import (
-	"strings"
 	"testing"
 
+	"github.com/PagerDuty/go-pdagent/pkg/version"
 	"github.com/PagerDuty/go-pdagent/test"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestVersionCommand(t *testing.T) {
+	defer func(v, c, d string) {
+		version.Version, version.Commit, version.Date = v, c, d
+	}(version.Version, version.Commit, version.Date)
+
+	version.Version = "v1.2.3"
+	version.Commit = "abc1234"
+	version.Date = "2020-06-01T12:30:00Z"
+
 	cmd := NewVersionCmd()
 
 	out, err := test.CaptureStdout(func() error {
@@ -30,19 +39,6 @@ func TestVersionCommand(t *testing.T) {
 		return err
 	})
 
-	if err != nil {
-		t.Error("Did not expect error")
-	}
-
-	if !strings.Contains(out, "Version:") {
-		t.Error("Expected 'version' output")
-	}
-
-	if !strings.Contains(out, "Build date:") {
-		t.Error("Expected 'build date' output")
-	}
-
-	if !strings.Contains(out, "Build commit:") {
-		t.Error("Expected 'build commit' output")
-	}
+	assert.NoError(t, err)
+	assert.Equal(t, "Version: v1.2.3\nBuild date: 2020-06-01T12:30:00Z\nBuild commit: abc1234\n", out)
 }
@@ -2,18 +2,84 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/tracing"
 )
 
+// sendRetries is how many times `Send` retries after the server rate limits
+// it, waiting for the server's `Retry-After` up to maxSendRetryDelay. Retries
+// stay brief so commands run from monitoring tools don't hang.
+const (
+	sendRetries       = 3
+	maxSendRetryDelay = 5 * time.Second
+)
+
+// defaultSendRetryDelay is used when a rate limited response has no valid
+// `Retry-After`.
+const defaultSendRetryDelay = time.Second
+
+// SendOption configures how the agent handles an event sent with `Send`.
+type SendOption func(*http.Request)
+
+// WithTTL is an option expiring the event if the agent can't send it within
+// `ttl`, overriding the agent's default.
+func WithTTL(ttl time.Duration) SendOption {
+	return func(req *http.Request) {
+		if ttl > 0 {
+			req.Header.Set("Pd-Event-Ttl", ttl.String())
+		}
+	}
+}
+
+// WithAutoResolveAfter is an option having the agent resolve a trigger
+// `delay` after it's delivered, e.g. for point-in-time checks.
+func WithAutoResolveAfter(delay time.Duration) SendOption {
+	return func(req *http.Request) {
+		if delay > 0 {
+			req.Header.Set("Pd-Auto-Resolve-After", delay.String())
+		}
+	}
+}
+
+// WithPriority is an option overriding the priority the agent sends the
+// event with, otherwise derived from its severity.
+func WithPriority(priority eventsapi.Priority) SendOption {
+	return func(req *http.Request) {
+		if priority != 0 {
+			req.Header.Set("Pd-Priority", priority.String())
+		}
+	}
+}
+
+// WithIntegration is an option naming the integration sending the event, e.g.
+// "nagios", which the agent includes in its `User-Agent` when sending it on to
+// PagerDuty.
+func WithIntegration(integration string) SendOption {
+	return func(req *http.Request) {
+		if integration != "" {
+			req.Header.Set("Pd-Integration", integration)
+			req.Header.Set("User-Agent", common.UserAgent(integration))
+		}
+	}
+}
+
 type Client struct {
 	HTTPClient    *http.Client
 	ServerAddress string
 
+	// SigningSecret, when set, signs events sent with `Send`, for servers
+	// requiring signed requests.
+	SigningSecret string
+
 	secret string
 }
 
@@ -27,11 +93,26 @@ func NewClient(httpClient *http.Client, serverAddress, secret string) *Client {
 
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	req.Header.Add("Authorization", fmt.Sprintf("token %v", c.secret))
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", common.UserAgent(""))
+	}
 	return c.HTTPClient.Do(req)
 }
 
 // Send an event to the agent daemon server.
-func (c *Client) Send(event eventsapi.Event) (*http.Response, error) {
+//
+// Should the server be rate limiting, the 429 is retried briefly before being
+// returned to the caller.
+func (c *Client) Send(event eventsapi.Event, options ...SendOption) (*http.Response, error) {
+	return c.SendContext(context.Background(), event, options...)
+}
+
+// SendContext sends an event as with `Send`, aborting the request and any
+// retries once `ctx` is cancelled, e.g. on Ctrl-C.
+//
+// Should `ctx` carry a trace, it's propagated to the server with a
+// `Traceparent` header, so the event's spans join it.
+func (c *Client) SendContext(ctx context.Context, event eventsapi.Event, options ...SendOption) (*http.Response, error) {
 	url := generateURL(c.ServerAddress, "/send")
 
 	body, err := json.Marshal(event)
@@ -39,21 +120,129 @@ func (c *Client) Send(event eventsapi.Event) (*http.Response, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url.String(), bytes.NewBuffer(body))
+	for try := 0; ; try++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", url.String(), bytes.NewBuffer(body))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Add("Accept", "application/json")
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Pd-Event-Version", event.Version().String())
+		if traceparent := tracing.Traceparent(ctx); traceparent != "" {
+			req.Header.Set("Traceparent", traceparent)
+		}
+		for _, option := range options {
+			option(req)
+		}
+		// Signed on each try, so retries carry a current timestamp.
+		if c.SigningSecret != "" {
+			req.Header.Set(common.SignatureHeader, common.SignBody(c.SigningSecret, body, time.Now()))
+		}
+
+		resp, err := c.Do(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || try >= sendRetries {
+			return resp, err
+		}
+
+		delay, ok := common.RetryAfter(resp, maxSendRetryDelay, time.Now())
+		if !ok {
+			delay = defaultSendRetryDelay
+		}
+		resp.Body.Close()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) QueueRetry(routingKey string) (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/queue/retry")
+	url.RawQuery = fmt.Sprintf("rk=%v", routingKey)
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// QueueRetryEvent immediately retries a single failed or scheduled event,
+// resetting its attempts.
+func (c *Client) QueueRetryEvent(id int) (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/queue/retry")
+	url.RawQuery = fmt.Sprintf("id=%v", id)
+
+	req, err := http.NewRequest("POST", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
+	return c.Do(req)
+}
 
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Pd-Event-Version", event.Version().String())
+// QueueList lists queued events, optionally filtered by status and routing
+// key. A limit of zero returns all matching events.
+func (c *Client) QueueList(status, routingKey string, limit int) (*http.Response, error) {
+	query := url.Values{}
+	query.Set("status", status)
+	query.Set("rk", routingKey)
+	query.Set("limit", strconv.Itoa(limit))
 
+	url := generateURL(c.ServerAddress, "/queue")
+	url.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
 	return c.Do(req)
 }
 
-func (c *Client) QueueRetry(routingKey string) (*http.Response, error) {
-	url := generateURL(c.ServerAddress, "/queue/retry")
-	url.RawQuery = fmt.Sprintf("rk=%v", routingKey)
+// QueueExport returns a support bundle of the queue's undelivered events and
+// dead letters, with routing keys masked.
+func (c *Client) QueueExport() (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/queue/export")
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// DedupReport returns counts of duplicates suppressed within the dedup
+// window, by masked routing key, and the most recent suppressions.
+func (c *Client) DedupReport() (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/dedup")
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// QueueFlush sends all pending events, waiting up to `wait` for them to
+// complete.
+func (c *Client) QueueFlush(wait time.Duration) (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/queue/flush")
+	url.RawQuery = fmt.Sprintf("timeout=%v", wait)
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// QueuePurge deletes all undelivered events, and all dead letters if
+// `deadLetters` is set.
+func (c *Client) QueuePurge(deadLetters bool) (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/queue/purge")
+	url.RawQuery = fmt.Sprintf("dead_letters=%v", deadLetters)
 
 	req, err := http.NewRequest("POST", url.String(), nil)
 	if err != nil {
@@ -73,6 +262,116 @@ func (c *Client) QueueStatus(routingKey string) (*http.Response, error) {
 	return c.Do(req)
 }
 
+// EventStatus returns the delivery state of the event with `key`, as
+// returned when it was enqueued.
+func (c *Client) EventStatus(key string) (*http.Response, error) {
+	query := url.Values{}
+	query.Set("key", key)
+
+	url := generateURL(c.ServerAddress, "/queue/event")
+	url.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// AgentStatus returns the overall health of the agent daemon server.
+func (c *Client) AgentStatus() (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/status")
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Healthz checks the agent daemon server is up, without depending on the
+// queue or PagerDuty.
+func (c *Client) Healthz() (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/healthz")
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Drain starts draining the server's queue, after which it exits once every
+// event is sent or the timeout elapses.
+func (c *Client) Drain(timeout time.Duration) (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/drain")
+	url.RawQuery = fmt.Sprintf("timeout=%v", timeout)
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Maintenance toggles the agent daemon server's maintenance mode.
+func (c *Client) Maintenance(enabled bool) (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/maintenance")
+	url.RawQuery = fmt.Sprintf("enabled=%v", enabled)
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) DeadLetters(routingKey string) (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/dead-letters")
+	url.RawQuery = fmt.Sprintf("rk=%v", routingKey)
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) DeadLetterRetry(id int) (*http.Response, error) {
+	url := generateURL(c.ServerAddress, "/dead-letters/retry")
+	url.RawQuery = fmt.Sprintf("id=%v", id)
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// DeadLetterReplay re-sends the event of the dead letter `id`, or of every
+// dead letter if `all` is set, replacing its routing key with `routingKey` if
+// not empty.
+func (c *Client) DeadLetterReplay(id int, all bool, routingKey string) (*http.Response, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "true")
+	} else {
+		query.Set("id", strconv.Itoa(id))
+	}
+	if routingKey != "" {
+		query.Set("rk", routingKey)
+	}
+
+	url := generateURL(c.ServerAddress, "/dead-letters/replay")
+	url.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
 func generateURL(serverAddress, path string) *url.URL {
 	return &url.URL{
 		Scheme: "http",
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/tracing"
+)
+
+func TestGenerateURL(t *testing.T) {
+	url := generateURL("127.0.0.1:50000", "/send")
+
+	if url.String() != "http://127.0.0.1:50000/send" {
+		t.Errorf("Expected URL to use the configured address, was %v.", url.String())
+	}
+}
+
+func TestSendRetriesRateLimited(t *testing.T) {
+	tests := []struct {
+		name             string
+		rateLimited      int
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{"recovers", 2, 200, 3},
+		{"givesUp", 10, 429, sendRetries + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				attempts++
+				if attempts <= tt.rateLimited {
+					rw.Header().Set("Retry-After", "0")
+					rw.WriteHeader(429)
+					return
+				}
+				_, _ = rw.Write([]byte(`{"key":"abc"}`))
+			}))
+			defer ts.Close()
+
+			u, _ := url.Parse(ts.URL)
+			c := NewClient(http.DefaultClient, u.Host, "secret")
+
+			resp, err := c.Send(&eventsapi.EventV2{RoutingKey: "11863b592c824bfc8989d9cba76abcde", EventAction: "trigger"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %v, was %v", tt.expectedStatus, resp.StatusCode)
+			}
+			if attempts != tt.expectedAttempts {
+				t.Errorf("Expected %v attempts, was %v", tt.expectedAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestSendSigned(t *testing.T) {
+	var verifyErr error
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		verifyErr = common.VerifySignature("signing-secret", req.Header.Get(common.SignatureHeader), body, time.Now(), common.DefaultSignatureSkew)
+		_, _ = rw.Write([]byte(`{"key":"abc"}`))
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c := NewClient(http.DefaultClient, u.Host, "secret")
+	c.SigningSecret = "signing-secret"
+
+	resp, err := c.Send(&eventsapi.EventV2{RoutingKey: "11863b592c824bfc8989d9cba76abcde", EventAction: "trigger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if verifyErr != nil {
+		t.Errorf("Expected a valid signature, got %v.", verifyErr)
+	}
+}
+
+func TestSendTraceparent(t *testing.T) {
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("Traceparent")
+		_, _ = rw.Write([]byte(`{"key":"abc"}`))
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c := NewClient(http.DefaultClient, u.Host, "secret")
+	event := &eventsapi.EventV2{RoutingKey: "11863b592c824bfc8989d9cba76abcde", EventAction: "trigger"}
+
+	resp, err := c.Send(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if traceparent != "" {
+		t.Errorf("Expected no traceparent without a trace, got %v.", traceparent)
+	}
+
+	caller := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	resp, err = c.SendContext(tracing.ContextWithTraceparent(context.Background(), caller), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if traceparent != caller {
+		t.Errorf("Expected the caller's traceparent %v, got %v.", caller, traceparent)
+	}
+}
@@ -0,0 +1,35 @@
+package cmdutil
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/spf13/viper"
+)
+
+// ServerAddress returns the agent server's address from config, shared by the
+// server when listening and the client when connecting.
+func ServerAddress() (string, error) {
+	return ResolveAddress(viper.GetString("address"), viper.GetInt("port"))
+}
+
+// ResolveAddress validates a `host:port` address, replacing its port with
+// `port` when non-zero.
+func ResolveAddress(address string, port int) (string, error) {
+	host, addressPort, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %v: %v", address, err)
+	}
+
+	if port != 0 {
+		addressPort = strconv.Itoa(port)
+	}
+
+	p, err := strconv.Atoi(addressPort)
+	if err != nil || p < 1 || p > 65535 {
+		return "", fmt.Errorf("invalid port %v, must be between 1 and 65535", addressPort)
+	}
+
+	return net.JoinHostPort(host, addressPort), nil
+}
@@ -0,0 +1,52 @@
+package cmdutil
+
+import (
+	"testing"
+)
+
+func TestResolveAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		port     int
+		expected string
+	}{
+		{"default", "127.0.0.1:49463", 0, "127.0.0.1:49463"},
+		{"portOverride", "127.0.0.1:49463", 50000, "127.0.0.1:50000"},
+		{"hostname", "localhost:8080", 0, "localhost:8080"},
+		{"ipv6", "[::1]:49463", 50000, "[::1]:50000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, err := ResolveAddress(tt.address, tt.port)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if address != tt.expected {
+				t.Errorf("Expected address %v, was %v.", tt.expected, address)
+			}
+		})
+	}
+}
+
+func TestResolveAddressInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		port    int
+	}{
+		{"missingPort", "127.0.0.1", 0},
+		{"nonNumericPort", "127.0.0.1:http", 0},
+		{"portOutOfRange", "127.0.0.1:49463", 70000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ResolveAddress(tt.address, tt.port); err == nil {
+				t.Errorf("Expected an error resolving %v with port %v.", tt.address, tt.port)
+			}
+		})
+	}
+}
@@ -16,43 +16,141 @@ limitations under the License.
 package cmdutil
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/spf13/viper"
 )
 
 var CfgFile string
 
+// ConfigFileEnv names the environment variable that may point at a config
+// file when `--config` isn't given.
+const ConfigFileEnv = "PD_AGENT_CONFIG"
+
+// configSearchPaths are checked, in order, for a config file when neither
+// `--config` nor `ConfigFileEnv` point at one.
+var configSearchPaths = []string{"pdagent.yaml", "/etc/pdagent/pdagent.yaml"}
+
 type Config struct {
 	HttpClient func() (*http.Client, error)
 	Client     func() (*client.Client, error)
+
+	// APIVersion is the Events API version integrations build events for
+	// unless explicitly overridden.
+	APIVersion eventsapi.EventVersion
 }
 
 func NewConfig() *Config {
-	httpClientFunc := func() (*http.Client, error) {
-		client := &http.Client{
-			Transport: http.DefaultTransport,
-			Timeout:   5 * time.Second,
+	config := &Config{
+		APIVersion: eventsapi.EventVersion1,
+		HttpClient: func() (*http.Client, error) {
+			transport, err := common.NewTransport(viper.GetString("proxy-url"))
+			if err != nil {
+				return nil, err
+			}
+
+			defaults := GetDefaults()
+			transport.DialContext = (&net.Dialer{
+				Timeout:   durationOrDefault("dial-timeout", defaults.DialTimeout),
+				KeepAlive: 30 * time.Second,
+			}).DialContext
+			ConnPool().Apply(transport)
+
+			client := &http.Client{
+				Transport: transport,
+				Timeout:   durationOrDefault("timeout", defaults.RequestTimeout),
+			}
+			return client, nil
+		},
+	}
+
+	config.Client = func() (*client.Client, error) {
+		httpClient, err := config.HttpClient()
+		if err != nil {
+			return nil, err
 		}
-		return client, nil
+		address, err := ServerAddress()
+		if err != nil {
+			return nil, err
+		}
+		c := client.NewClient(httpClient, address, viper.GetString("secret"))
+		c.SigningSecret = viper.GetString("signing-secret")
+		return c, nil
 	}
 
-	return &Config{
-		HttpClient: httpClientFunc,
-		Client: func() (*client.Client, error) {
-			httpClient, _ := httpClientFunc()
-			c := client.NewClient(httpClient, viper.GetString("address"), viper.GetString("secret"))
-			return c, nil
-		},
+	return config
+}
+
+// ConnPool returns the configured idle connection pool tuning for outgoing
+// requests.
+func ConnPool() common.ConnPoolConfig {
+	return common.ConnPoolConfig{
+		MaxIdleConns:        viper.GetInt("max-idle-conns"),
+		MaxIdleConnsPerHost: viper.GetInt("max-idle-conns-per-host"),
+		IdleConnTimeout:     viper.GetDuration("idle-conn-timeout"),
 	}
 }
 
+// durationOrDefault returns a positive duration from config, otherwise the
+// provided default.
+func durationOrDefault(key string, defaultDuration time.Duration) time.Duration {
+	if d := viper.GetDuration(key); d > 0 {
+		return d
+	}
+	return defaultDuration
+}
+
+// FindConfigFile returns the config file to use, with the first existing file
+// in the following order winning:
+//
+//   1. The `--config` flag, which is an error if it doesn't exist.
+//   2. The file named by the `PD_AGENT_CONFIG` environment variable.
+//   3. `./pdagent.yaml`.
+//   4. `/etc/pdagent/pdagent.yaml`.
+//
+// Returns an empty path if none exist.
+func FindConfigFile() (string, error) {
+	if CfgFile != "" {
+		if !fileExists(CfgFile) {
+			return "", fmt.Errorf("config file %v does not exist", CfgFile)
+		}
+		return CfgFile, nil
+	}
+
+	candidates := append([]string{os.Getenv(ConfigFileEnv)}, configSearchPaths...)
+	for _, candidate := range candidates {
+		if candidate != "" && fileExists(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
 // InitConfig reads in config file and ENV variables if set.
+//
+// Falls back to a `config` file in `/etc/pdagent` or the default config path,
+// as generated by `init`, if `FindConfigFile` finds nothing.
 func InitConfig() {
-	if CfgFile != "" {
-		viper.SetConfigFile(CfgFile)
+	configFile, err := FindConfigFile()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
 	} else {
 		// We add both production and dev paths here such that either config
 		// will be automatically picked up.
@@ -65,4 +163,13 @@ func InitConfig() {
 
 	// If a config file is found, read it in.
 	_ = viper.ReadInConfig()
+
+	common.SetRedaction(!viper.GetBool("no-redact"))
+	common.RegisterSecret(viper.GetString("secret"))
+	common.RegisterSecret(viper.GetString("signing-secret"))
+
+	if err := common.InitLogger(viper.GetString("log-format"), viper.GetString("log-level")); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
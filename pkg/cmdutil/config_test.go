@@ -0,0 +1,176 @@
+package cmdutil
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Using httptest rather than gock here as gock's response delays can't be
+// interrupted by client timeouts.
+func TestConfigHttpClientTimeout(t *testing.T) {
+	defer viper.Set("timeout", nil)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	defer ts.Close()
+
+	viper.Set("timeout", 100*time.Millisecond)
+
+	httpClient, err := NewConfig().HttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = httpClient.Get(ts.URL + "/health")
+	elapsed := time.Since(start)
+
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("Expected a timeout error, got %v.", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected request to time out quickly, took %v.", elapsed)
+	}
+}
+
+func TestConfigHttpClientDefaultTimeout(t *testing.T) {
+	httpClient, err := NewConfig().HttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if httpClient.Timeout != GetDefaults().RequestTimeout {
+		t.Errorf("Expected default timeout of %v, was %v.", GetDefaults().RequestTimeout, httpClient.Timeout)
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-pdagent-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	flagFile := path.Join(dir, "flag.yaml")
+	envFile := path.Join(dir, "env.yaml")
+	localFile := path.Join(dir, "pdagent.yaml")
+	systemFile := path.Join(dir, "system.yaml")
+	for _, f := range []string{flagFile, envFile, localFile, systemFile} {
+		if err := ioutil.WriteFile(f, []byte("secret: abc\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(paths []string) { configSearchPaths = paths }(configSearchPaths)
+	defer func(cfgFile string) { CfgFile = cfgFile }(CfgFile)
+	defer os.Unsetenv(ConfigFileEnv)
+
+	tests := []struct {
+		name        string
+		cfgFile     string
+		env         string
+		searchPaths []string
+		expected    string
+	}{
+		{"flag", flagFile, envFile, []string{localFile, systemFile}, flagFile},
+		{"env", "", envFile, []string{localFile, systemFile}, envFile},
+		{"missingEnv", "", path.Join(dir, "missing.yaml"), []string{localFile, systemFile}, localFile},
+		{"local", "", "", []string{localFile, systemFile}, localFile},
+		{"system", "", "", []string{path.Join(dir, "missing.yaml"), systemFile}, systemFile},
+		{"none", "", "", []string{path.Join(dir, "missing.yaml")}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CfgFile = tt.cfgFile
+			os.Setenv(ConfigFileEnv, tt.env)
+			configSearchPaths = tt.searchPaths
+
+			actual, err := FindConfigFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expected {
+				t.Errorf("Expected config file %v, got %v.", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFindConfigFileMissingFlag(t *testing.T) {
+	defer func(cfgFile string) { CfgFile = cfgFile }(CfgFile)
+	CfgFile = "/nonexistent/pdagent.yaml"
+
+	_, err := FindConfigFile()
+	if err == nil || err.Error() != "config file /nonexistent/pdagent.yaml does not exist" {
+		t.Errorf("Expected missing config file error, got %v.", err)
+	}
+}
+
+func TestFindConfigFileWorkingDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-pdagent-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile("pdagent.yaml", []byte("secret: abc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := FindConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != "pdagent.yaml" {
+		t.Errorf("Expected ./pdagent.yaml to be found, got %v.", actual)
+	}
+}
+
+func TestConfigHttpClientConnPool(t *testing.T) {
+	defer viper.Set("max-idle-conns", nil)
+	defer viper.Set("max-idle-conns-per-host", nil)
+	defer viper.Set("idle-conn-timeout", nil)
+
+	viper.Set("max-idle-conns", 50)
+	viper.Set("max-idle-conns-per-host", 20)
+	viper.Set("idle-conn-timeout", time.Minute)
+
+	httpClient, err := NewConfig().HttpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected an *http.Transport, was %T.", httpClient.Transport)
+	}
+	if transport.MaxIdleConns != 50 || transport.MaxIdleConnsPerHost != 20 || transport.IdleConnTimeout != time.Minute {
+		t.Errorf("Expected transport configured from max-idle-conns, max-idle-conns-per-host, and idle-conn-timeout, was %v, %v, and %v.",
+			transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+}
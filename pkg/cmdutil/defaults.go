@@ -19,18 +19,52 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/mitchellh/go-homedir"
 )
 
 type Defaults struct {
-	Address    string
-	ConfigPath string
-	Database   string
-	Pidfile    string
-	Secret     string
-	Region     string
+	Address          string
+	ConfigPath       string
+	Database         string
+	Spool            string
+	Pidfile          string
+	Secret           string
+	Region           string
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryMaxAttempts int
+	ShutdownGrace    time.Duration
+	DrainTimeout     time.Duration
+	BatchSize        int
+	MaxConcurrent    int
+	PerKeyRateLimit  float64
+	PerKeyBurst      int
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	StallTimeout     time.Duration
+	MaxPayloadBytes  int
+	CompressMinBytes int
+	AuditLogMaxBytes int64
+	IngestRateLimit  float64
+	IngestBurst      int
+	DedupWindow      time.Duration
+	EventTTL         time.Duration
+	ResolveEventTTL  time.Duration
+	MaxRetries       int
+	MaxQueueDepth    int
+	Overflow         string
+	StatsInterval    time.Duration
+	AlertThreshold   int
+	AlertDuration    time.Duration
+	RequestTimeout   time.Duration
+	DialTimeout      time.Duration
+	MaxIdleConns     int
+	MaxIdlePerHost   int
+	IdleConnTimeout  time.Duration
 }
 
 func GetDefaults() Defaults {
@@ -38,24 +72,88 @@ func GetDefaults() Defaults {
 
 	if prod {
 		return Defaults{
-			Address:    "127.0.0.1:49463",
-			ConfigPath: "/etc/pdagent/",
-			Database:   "/var/db/pdagent/pdagent.db",
-			Pidfile:    "/var/run/pdagent/pidfile",
-			Secret:     common.GenerateKey(),
-			Region:     "us",
+			Address:          "127.0.0.1:49463",
+			ConfigPath:       "/etc/pdagent/",
+			Database:         "/var/db/pdagent/pdagent.db",
+			Spool:            "/var/db/pdagent/pdagent.spool",
+			Pidfile:          "/var/run/pdagent/pidfile",
+			Secret:           common.GenerateKey(),
+			Region:           "us",
+			RetryBaseDelay:   time.Second,
+			RetryMaxDelay:    30 * time.Second,
+			RetryMaxAttempts: 10,
+			ShutdownGrace:    30 * time.Second,
+			DrainTimeout:     5 * time.Minute,
+			BatchSize:        1,
+			MaxConcurrent:    1,
+			PerKeyRateLimit:  0,
+			PerKeyBurst:      1,
+			BreakerThreshold: 5,
+			BreakerCooldown:  time.Minute,
+			StallTimeout:     10 * time.Minute,
+			MaxPayloadBytes:  eventsapi.DefaultMaxPayloadBytes,
+			CompressMinBytes: common.DefaultCompressMinBytes,
+			AuditLogMaxBytes: 100 * 1024 * 1024,
+			IngestRateLimit:  0,
+			IngestBurst:      100,
+			DedupWindow:      0,
+			EventTTL:         0,
+			ResolveEventTTL:  0,
+			MaxRetries:       1,
+			MaxQueueDepth:    0,
+			Overflow:         "reject",
+			StatsInterval:    time.Minute,
+			AlertThreshold:   0,
+			AlertDuration:    15 * time.Minute,
+			RequestTimeout:   5 * time.Second,
+			DialTimeout:      2 * time.Second,
+			MaxIdleConns:     100,
+			MaxIdlePerHost:   10,
+			IdleConnTimeout:  90 * time.Second,
 		}
 	}
 
 	configPath := getDefaultConfigPath()
 
 	return Defaults{
-		Address:    "127.0.0.1:49463",
-		ConfigPath: configPath,
-		Database:   path.Join(configPath, "pdagent.db"),
-		Pidfile:    path.Join(configPath, "pidfile"),
-		Secret:     common.GenerateKey(),
-		Region:     "us",
+		Address:          "127.0.0.1:49463",
+		ConfigPath:       configPath,
+		Database:         path.Join(configPath, "pdagent.db"),
+		Spool:            path.Join(configPath, "pdagent.spool"),
+		Pidfile:          path.Join(configPath, "pidfile"),
+		Secret:           common.GenerateKey(),
+		Region:           "us",
+		RetryBaseDelay:   time.Second,
+		RetryMaxDelay:    30 * time.Second,
+		RetryMaxAttempts: 10,
+		ShutdownGrace:    30 * time.Second,
+		DrainTimeout:     5 * time.Minute,
+		BatchSize:        1,
+		MaxConcurrent:    1,
+		PerKeyRateLimit:  0,
+		PerKeyBurst:      1,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Minute,
+		StallTimeout:     10 * time.Minute,
+		MaxPayloadBytes:  eventsapi.DefaultMaxPayloadBytes,
+		CompressMinBytes: common.DefaultCompressMinBytes,
+		AuditLogMaxBytes: 100 * 1024 * 1024,
+		IngestRateLimit:  0,
+		IngestBurst:      100,
+		DedupWindow:      0,
+		EventTTL:         0,
+		ResolveEventTTL:  0,
+		MaxRetries:       1,
+		MaxQueueDepth:    0,
+		Overflow:         "reject",
+		StatsInterval:    time.Minute,
+		AlertThreshold:   0,
+		AlertDuration:    15 * time.Minute,
+		RequestTimeout:   5 * time.Second,
+		DialTimeout:      2 * time.Second,
+		MaxIdleConns:     100,
+		MaxIdlePerHost:   10,
+		IdleConnTimeout:  90 * time.Second,
 	}
 }
 
@@ -0,0 +1,55 @@
+package cmdutil
+
+import (
+	"errors"
+	"net/url"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+// ErrInvalidClientURL occurs when a client URL isn't an absolute URL.
+var ErrInvalidClientURL = errors.New("client-url must be an absolute URL, e.g. https://nagios.example.com")
+
+// DefaultClient returns the client shown on incidents when none is given,
+// naming the host the agent runs on.
+func DefaultClient() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "PagerDuty Agent"
+	}
+	return "PagerDuty Agent on " + hostname
+}
+
+// ResolveClient returns the client and client URL to send with an event:
+// those given on the command line, otherwise the `client` and `client-url`
+// config values, otherwise `DefaultClient` and no URL.
+func ResolveClient(client, clientURL string) (string, string, error) {
+	if client == "" {
+		client = viper.GetString("client")
+	}
+	if client == "" {
+		client = DefaultClient()
+	}
+
+	if clientURL == "" {
+		clientURL = viper.GetString("client-url")
+	}
+	if err := validateClientURL(clientURL); err != nil {
+		return "", "", err
+	}
+
+	return client, clientURL, nil
+}
+
+func validateClientURL(clientURL string) error {
+	if clientURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(clientURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ErrInvalidClientURL
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cmdutil
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestResolveClient(t *testing.T) {
+	client, clientURL, err := ResolveClient("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != DefaultClient() || clientURL != "" {
+		t.Errorf("Expected default client %q and no URL, was %q and %q.", DefaultClient(), client, clientURL)
+	}
+
+	viper.Set("client", "web-1 agent")
+	viper.Set("client-url", "https://web-1.example.com")
+	defer viper.Set("client", nil)
+	defer viper.Set("client-url", nil)
+
+	client, clientURL, err = ResolveClient("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != "web-1 agent" || clientURL != "https://web-1.example.com" {
+		t.Errorf("Expected configured client, was %q and %q.", client, clientURL)
+	}
+
+	client, clientURL, err = ResolveClient("nagios", "https://nagios.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != "nagios" || clientURL != "https://nagios.example.com" {
+		t.Errorf("Expected given client to take precedence, was %q and %q.", client, clientURL)
+	}
+}
+
+func TestResolveClientInvalidURL(t *testing.T) {
+	for _, clientURL := range []string{"nagios.example.com", "/nagios", "https://", "://bad"} {
+		if _, _, err := ResolveClient("", clientURL); err != ErrInvalidClientURL {
+			t.Errorf("Expected ErrInvalidClientURL for %q, was %v.", clientURL, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package cmdutil
+
+import (
+	"errors"
+	"net"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of commands sending events, letting scripts react to a failure
+// without parsing output.
+const (
+	// ExitSuccess means the agent accepted and queued the event.
+	ExitSuccess = 0
+
+	// ExitFailure is any other failure.
+	ExitFailure = 1
+
+	// ExitValidation means the command's flags, arguments, or event were
+	// invalid, so nothing was sent.
+	ExitValidation = 2
+
+	// ExitAgentUnreachable means the agent couldn't be reached or was too busy
+	// to accept the event, so it wasn't queued and may be resent.
+	ExitAgentUnreachable = 3
+
+	// ExitRejected means the agent rejected the event, e.g. as invalid or
+	// unauthorized, so resending it won't help.
+	ExitRejected = 4
+
+	// ExitNotConfirmed means the event reached the agent but it's unknown
+	// whether it was queued, e.g. the response timed out, or when waiting for
+	// delivery, whether it reached PagerDuty in time.
+	ExitNotConfirmed = 5
+
+	// ExitUndelivered means the agent queued the event but, when waiting for
+	// delivery, failed to send it to PagerDuty.
+	ExitUndelivered = 6
+)
+
+// sendCommandAnnotation marks commands whose errors map to the exit codes.
+const sendCommandAnnotation = "pdagent_send_command"
+
+// ExitError is an error exiting the CLI with a specific code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// MarkSendCommand marks a command as sending events, so any of its errors not
+// otherwise classified, such as invalid flags, exit with `ExitValidation`.
+func MarkSendCommand(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[sendCommandAnnotation] = "true"
+}
+
+// ExitCode returns the code the CLI should exit with after `cmd` returned
+// `err`.
+func ExitCode(cmd *cobra.Command, err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+
+	var exitErr *ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case errors.Is(err, ErrAgentUnreachable), errors.Is(err, ErrAgentOverloaded):
+		return ExitAgentUnreachable
+	case errors.Is(err, ErrSendCancelled):
+		return ExitNotConfirmed
+	case cmd != nil && cmd.Annotations[sendCommandAnnotation] != "":
+		return ExitValidation
+	default:
+		return ExitFailure
+	}
+}
+
+// sendErrorCode classifies an error sending a request to the agent. Only a
+// failure to connect means the request certainly wasn't received.
+func sendErrorCode(err error) int {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ExitAgentUnreachable
+	}
+	return ExitNotConfirmed
+}
+
+// responseCode classifies an agent's non-2xx response to an event.
+func responseCode(statusCode int) int {
+	switch {
+	case statusCode == 429 || statusCode == 503:
+		return ExitAgentUnreachable
+	case statusCode >= 400 && statusCode < 500:
+		return ExitRejected
+	default:
+		return ExitNotConfirmed
+	}
+}
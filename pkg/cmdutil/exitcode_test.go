@@ -0,0 +1,188 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// captureStdout returns what `f` prints, as with `test.CaptureStdout`, which
+// can't be imported here without a cycle.
+func captureStdout(f func() error) (string, error) {
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := f()
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+
+	return buf.String(), err
+}
+
+// testSendConfig returns a config whose client sends to `address`.
+func testSendConfig(address string, timeout time.Duration) *Config {
+	return &Config{
+		Client: func() (*client.Client, error) {
+			return client.NewClient(&http.Client{Timeout: timeout}, address, "secret"), nil
+		},
+	}
+}
+
+func testSendEvent() eventsapi.Event {
+	return &eventsapi.EventV2{
+		RoutingKey:  "11863b592c824bfc8989d9cba76abcde",
+		EventAction: "trigger",
+		Payload: eventsapi.PayloadV2{
+			Summary:  "Exit code test",
+			Source:   "pdagent",
+			Severity: "error",
+		},
+	}
+}
+
+func TestRunSendCommandExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected int
+	}{
+		{
+			name: "success",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				rw.Write([]byte(`{"key":"abc"}`))
+			},
+			expected: ExitSuccess,
+		},
+		{
+			name: "rejected",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusBadRequest)
+				rw.Write([]byte(`{"errors":["invalid event"]}`))
+			},
+			expected: ExitRejected,
+		},
+		{
+			name: "unauthorized",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusUnauthorized)
+			},
+			expected: ExitRejected,
+		},
+		{
+			name: "shuttingDown",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expected: ExitAgentUnreachable,
+		},
+		{
+			name: "notConfirmed",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusInternalServerError)
+			},
+			expected: ExitNotConfirmed,
+		},
+		{
+			name: "timeout",
+			handler: func(rw http.ResponseWriter, req *http.Request) {
+				time.Sleep(500 * time.Millisecond)
+			},
+			expected: ExitNotConfirmed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(tt.handler)
+			defer ts.Close()
+
+			config := testSendConfig(ts.Listener.Addr().String(), 100*time.Millisecond)
+			_, err := captureStdout(func() error {
+				return RunSendCommand(context.Background(), config, testSendEvent(), nil)
+			})
+
+			if code := ExitCode(nil, err); code != tt.expected {
+				t.Errorf("Expected exit code %v, was %v for %v.", tt.expected, code, err)
+			}
+		})
+	}
+}
+
+func TestRunSendCommandUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	err = RunSendCommand(context.Background(), testSendConfig(address, time.Second), testSendEvent(), nil)
+	if code := ExitCode(nil, err); code != ExitAgentUnreachable {
+		t.Errorf("Expected exit code %v, was %v for %v.", ExitAgentUnreachable, code, err)
+	}
+}
+
+func TestRunSendCommandQuiet(t *testing.T) {
+	viper.Set("quiet", true)
+	defer viper.Set("quiet", nil)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"key":"abc"}`))
+	}))
+	defer ts.Close()
+
+	out, err := captureStdout(func() error {
+		return RunSendCommand(context.Background(), testSendConfig(ts.Listener.Addr().String(), time.Second), testSendEvent(), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out) != "" {
+		t.Errorf("Expected no output when quiet, got %q.", out)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	sendCmd := &cobra.Command{}
+	MarkSendCommand(sendCmd)
+
+	tests := []struct {
+		name     string
+		cmd      *cobra.Command
+		err      error
+		expected int
+	}{
+		{"success", sendCmd, nil, ExitSuccess},
+		{"validation", sendCmd, errors.New("summary must be set for trigger events"), ExitValidation},
+		{"otherCommand", &cobra.Command{}, errors.New("failed"), ExitFailure},
+		{"agentUnreachable", sendCmd, ErrAgentUnreachable, ExitAgentUnreachable},
+		{"agentOverloaded", sendCmd, ErrAgentOverloaded, ExitAgentUnreachable},
+		{"cancelled", sendCmd, ErrSendCancelled, ExitNotConfirmed},
+		{"redacted", sendCmd, RedactError(&ExitError{Code: ExitRejected, Err: errors.New("rejected")}), ExitRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := ExitCode(tt.cmd, tt.err); code != tt.expected {
+				t.Errorf("Expected exit code %v, was %v.", tt.expected, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package cmdutil
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// CustomFields is a `pflag.Value` collecting KEY=VALUE pairs where, unlike
+// `StringToString` flags, repeating a key keeps every value given.
+//
+// Parsing otherwise matches `StringToString`, so `-f a=1,b=2` still sets two
+// keys.
+type CustomFields map[string][]string
+
+func (f CustomFields) Set(val string) error {
+	var pairs []string
+	switch strings.Count(val, "=") {
+	case 0:
+		return fmt.Errorf("%s must be formatted as key=value", val)
+	case 1:
+		pairs = append(pairs, strings.Trim(val, `"`))
+	default:
+		var err error
+		pairs, err = csv.NewReader(strings.NewReader(val)).Read()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%s must be formatted as key=value", pair)
+		}
+		f[kv[0]] = append(f[kv[0]], kv[1])
+	}
+	return nil
+}
+
+func (f CustomFields) Type() string {
+	return "stringToString"
+}
+
+func (f CustomFields) String() string {
+	var records []string
+	for k, vals := range f {
+		for _, v := range vals {
+			records = append(records, k+"="+v)
+		}
+	}
+	sort.Strings(records)
+	return "[" + strings.Join(records, ",") + "]"
+}
+
+// Get returns the last value set for a key, matching the behavior of a
+// repeated `StringToString` flag.
+func (f CustomFields) Get(key string) string {
+	vals := f[key]
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[len(vals)-1]
+}
+
+// Has returns true if at least one value was set for a key.
+func (f CustomFields) Has(key string) bool {
+	return len(f[key]) > 0
+}
+
+// Details flattens fields into an event details object.
+//
+// Keys with a single value, or listed in `singleValueKeys`, are set to their
+// last value; keys repeated more than once become an array of every value.
+func (f CustomFields) Details(singleValueKeys []string) map[string]interface{} {
+	single := map[string]bool{}
+	for _, k := range singleValueKeys {
+		single[k] = true
+	}
+
+	details := make(map[string]interface{}, len(f))
+	for k, vals := range f {
+		if len(vals) == 0 {
+			continue
+		}
+		if len(vals) == 1 || single[k] {
+			details[k] = f.Get(k)
+		} else {
+			details[k] = vals
+		}
+	}
+	return details
+}
+
+// WithDefaultDetails adds the details configured for an integration under
+// `default-details.<integration>` to `details`, for any key it doesn't
+// already set, so values from the command line win.
+//
+// As with all config keys, the configured detail names are lowercase.
+func WithDefaultDetails(integration string, details map[string]interface{}) map[string]interface{} {
+	for k, v := range viper.GetStringMapString("default-details." + integration) {
+		if _, ok := details[k]; !ok {
+			details[k] = v
+		}
+	}
+	return details
+}
+
+// CustomDetailsGroup is the key user fields are grouped under when grouping
+// custom details.
+const CustomDetailsGroup = "custom"
+
+// reservedKeyPrefix is prepended to user fields colliding with a key reserved
+// by an integration.
+const reservedKeyPrefix = "custom_"
+
+// NamespacedDetails combines the details an integration reserves with those
+// from user fields, without either overwriting the other.
+//
+// When grouped, user details are nested under `CustomDetailsGroup`. Otherwise
+// they're merged flat, with any colliding user key prefixed with "custom_".
+func NamespacedDetails(reserved, user map[string]interface{}, group bool) map[string]interface{} {
+	details := make(map[string]interface{}, len(reserved)+len(user))
+	for k, v := range reserved {
+		details[k] = v
+	}
+
+	if group {
+		details[CustomDetailsGroup] = user
+		return details
+	}
+
+	for k, v := range user {
+		if _, ok := reserved[k]; !ok {
+			details[k] = v
+		}
+	}
+	for k, v := range user {
+		if _, ok := reserved[k]; !ok {
+			continue
+		}
+		key := reservedKeyPrefix + k
+		for exists(details, key) {
+			key = reservedKeyPrefix + key
+		}
+		details[key] = v
+	}
+	return details
+}
+
+func exists(details map[string]interface{}, key string) bool {
+	_, ok := details[key]
+	return ok
+}
@@ -0,0 +1,18 @@
+package cmdutil
+
+import "fmt"
+
+// BuildIncidentKey derives an incident key from a monitored host and, for
+// service events, the service description.
+//
+// Notification type and state are intentionally excluded so that triggers,
+// acknowledgements, and resolves for the same object share a key. The format
+// is shared across monitoring integrations so that keys remain stable when
+// migrating between them.
+func BuildIncidentKey(sourceType, hostName, serviceDesc string) string {
+	if sourceType == "host" {
+		return fmt.Sprintf("event_source=host;host_name=%v", hostName)
+	}
+
+	return fmt.Sprintf("event_source=service;host_name=%v;service_desc=%v", hostName, serviceDesc)
+}
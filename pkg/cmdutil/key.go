@@ -0,0 +1,163 @@
+package cmdutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/viper"
+)
+
+const keyFilePrefix = "@"
+const keyEnvPrefix = "env:"
+
+// ResolveKey resolves a routing or service key given on the command line.
+//
+// Keys may be given as a literal value, as `@/path/to/keyfile` to read the key
+// from a file, or as `env:NAME` to read it from an environment variable. The
+// latter two keep keys out of process listings.
+//
+// Resolved keys are registered for redaction, see `Redact`.
+func ResolveKey(val string) (string, error) {
+	key, err := resolveKey(val)
+	if err == nil {
+		common.RegisterSecret(key)
+	}
+	return key, err
+}
+
+func resolveKey(val string) (string, error) {
+	switch {
+	case strings.HasPrefix(val, keyFilePrefix):
+		file := strings.TrimPrefix(val, keyFilePrefix)
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("unable to read key file %v: %v", file, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case strings.HasPrefix(val, keyEnvPrefix):
+		name := strings.TrimPrefix(val, keyEnvPrefix)
+		key, ok := os.LookupEnv(name)
+		if !ok || key == "" {
+			return "", fmt.Errorf("environment variable %v for key is not set", name)
+		}
+		return key, nil
+	default:
+		return val, nil
+	}
+}
+
+// ResolveNamedKey resolves a key given either literally, as with `ResolveKey`,
+// or by the name of a key configured under `keys`, e.g.:
+//
+//     keys:
+//       db: 11863b592c824bfc8989d9cba76abcde
+//       web: env:PD_WEB_KEY
+//
+// A literal key takes precedence over a name, and if neither are given an
+// empty key is returned. Names are case-insensitive.
+//
+// Names may also be of a route under `severity-routes`, see
+// `ResolveSeverityKey`, in which case its default key is returned.
+func ResolveNamedKey(val, name string) (string, error) {
+	return ResolveSeverityKey(val, name, "")
+}
+
+// ResolveSeverityKey resolves a key as `ResolveNamedKey` does, except that a
+// name may be of a route under `severity-routes` choosing a key by the event's
+// severity, e.g.:
+//
+//     severity-routes:
+//       web:
+//         critical: web-high
+//         warning: web-low
+//         default: web-low
+//
+// Each severity maps to the name of a key under `keys` or to a key itself,
+// given as with `ResolveKey`. Severities without a key of their own use the
+// route's `default`.
+func ResolveSeverityKey(val, name, severity string) (string, error) {
+	if val != "" || name == "" {
+		return ResolveKey(val)
+	}
+
+	keys := viper.GetStringMapString("keys")
+	if routes := viper.GetStringMapString("severity-routes." + strings.ToLower(name)); len(routes) > 0 {
+		return resolveRoute(keys, routes, name, severity)
+	}
+
+	key, ok := keys[strings.ToLower(name)]
+	if !ok {
+		var names []string
+		for k := range keys {
+			names = append(names, k)
+		}
+		for k := range viper.GetStringMap("severity-routes") {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		if len(names) == 0 {
+			return "", fmt.Errorf("unknown key name %v, no keys are configured", name)
+		}
+		return "", fmt.Errorf("unknown key name %v, expected one of: %v", name, strings.Join(names, ", "))
+	}
+
+	return ResolveKey(key)
+}
+
+// resolveRoute resolves the key a severity route maps `severity` to.
+func resolveRoute(keys, routes map[string]string, name, severity string) (string, error) {
+	target, ok := routes[strings.ToLower(severity)]
+	if !ok {
+		target, ok = routes["default"]
+	}
+	if !ok && severity == "" {
+		return "", fmt.Errorf("severity route %v has no default key", name)
+	}
+	if !ok {
+		return "", fmt.Errorf("severity route %v has no key for severity %v and no default", name, severity)
+	}
+
+	if key, ok := keys[strings.ToLower(target)]; ok {
+		return ResolveKey(key)
+	}
+	return ResolveKey(target)
+}
+
+// keyLength is the length of both routing and service keys.
+const keyLength = 32
+
+// ValidateKeyFormat returns an error unless `key` looks like a key for the
+// Events API `version`, without checking with PagerDuty that it exists.
+//
+// V2 and change event routing keys are 32 hexadecimal characters, while V1
+// service keys are 32 letters or digits. Errors mask the key.
+func ValidateKeyFormat(key string, version eventsapi.EventVersion) error {
+	kind, chars, isValidChar := "routing key", "hexadecimal characters", isHexChar
+	if version == eventsapi.EventVersion1 {
+		kind, chars, isValidChar = "service key", "letters and digits", isAlphanumericChar
+	}
+
+	if len(key) != keyLength {
+		return fmt.Errorf("%v %v is %v characters long, expected %v", kind, common.MaskSecret(key), len(key), keyLength)
+	}
+	for _, c := range key {
+		if !isValidChar(c) {
+			return fmt.Errorf("%v %v may only contain %v, found %q", kind, common.MaskSecret(key), chars, c)
+		}
+	}
+	return nil
+}
+
+func isHexChar(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isAlphanumericChar(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,203 @@
+package cmdutil
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/viper"
+)
+
+func TestResolveKeyLiteral(t *testing.T) {
+	key, err := ResolveKey("11863b592c824bfc8989d9cba76abcde")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key != "11863b592c824bfc8989d9cba76abcde" {
+		t.Errorf("Expected literal key to be returned unchanged, was %v.", key)
+	}
+}
+
+func TestResolveKeyFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "pdagent-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString("11863b592c824bfc8989d9cba76abcde\n"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	key, err := ResolveKey("@" + file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key != "11863b592c824bfc8989d9cba76abcde" {
+		t.Errorf("Expected key to be read from file, was %v.", key)
+	}
+
+	if _, err := ResolveKey("@/nonexistent/pdagent-key"); err == nil {
+		t.Error("Expected an error for an unreadable key file.")
+	}
+}
+
+func TestResolveKeyEnv(t *testing.T) {
+	os.Setenv("PD_TEST_SERVICE_KEY", "11863b592c824bfc8989d9cba76abcde")
+	defer os.Unsetenv("PD_TEST_SERVICE_KEY")
+
+	key, err := ResolveKey("env:PD_TEST_SERVICE_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key != "11863b592c824bfc8989d9cba76abcde" {
+		t.Errorf("Expected key to be read from the environment, was %v.", key)
+	}
+
+	if _, err := ResolveKey("env:PD_TEST_UNSET_SERVICE_KEY"); err == nil {
+		t.Error("Expected an error for an unset environment variable.")
+	}
+}
+
+func TestResolveNamedKey(t *testing.T) {
+	viper.Set("keys", map[string]string{
+		"db":  "11863b592c824bfc8989d9cba76abcde",
+		"web": "env:PDAGENT_TEST_WEB_KEY",
+	})
+	defer viper.Set("keys", nil)
+
+	os.Setenv("PDAGENT_TEST_WEB_KEY", "22863b592c824bfc8989d9cba76abcde")
+	defer os.Unsetenv("PDAGENT_TEST_WEB_KEY")
+
+	tests := []struct {
+		name     string
+		val      string
+		keyName  string
+		expected string
+	}{
+		{"name", "", "db", "11863b592c824bfc8989d9cba76abcde"},
+		{"nameCaseInsensitive", "", "DB", "11863b592c824bfc8989d9cba76abcde"},
+		{"nameFromEnv", "", "web", "22863b592c824bfc8989d9cba76abcde"},
+		{"literalPrecedence", "33863b592c824bfc8989d9cba76abcde", "db", "33863b592c824bfc8989d9cba76abcde"},
+		{"neither", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ResolveNamedKey(tt.val, tt.keyName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if key != tt.expected {
+				t.Errorf("Expected key %v, was %v.", tt.expected, key)
+			}
+		})
+	}
+}
+
+func TestResolveNamedKeyUnknownName(t *testing.T) {
+	viper.Set("keys", map[string]string{
+		"db":  "11863b592c824bfc8989d9cba76abcde",
+		"web": "22863b592c824bfc8989d9cba76abcde",
+	})
+	defer viper.Set("keys", nil)
+
+	_, err := ResolveNamedKey("", "cache")
+	if err == nil || err.Error() != "unknown key name cache, expected one of: db, web" {
+		t.Errorf("Expected unknown key name error, was %v.", err)
+	}
+}
+
+func TestResolveSeverityKey(t *testing.T) {
+	viper.Set("keys", map[string]string{
+		"web-high": "11863b592c824bfc8989d9cba76abcde",
+		"web-low":  "22863b592c824bfc8989d9cba76abcde",
+	})
+	defer viper.Set("keys", nil)
+	viper.Set("severity-routes", map[string]interface{}{
+		"web": map[string]interface{}{
+			"critical": "web-high",
+			"error":    "33863b592c824bfc8989d9cba76abcde",
+			"default":  "web-low",
+		},
+	})
+	defer viper.Set("severity-routes", nil)
+
+	tests := []struct {
+		name     string
+		val      string
+		keyName  string
+		severity string
+		expected string
+	}{
+		{"critical", "", "web", "critical", "11863b592c824bfc8989d9cba76abcde"},
+		{"warning", "", "web", "warning", "22863b592c824bfc8989d9cba76abcde"},
+		{"literalTarget", "", "web", "error", "33863b592c824bfc8989d9cba76abcde"},
+		{"severityCaseInsensitive", "", "WEB", "CRITICAL", "11863b592c824bfc8989d9cba76abcde"},
+		{"noSeverity", "", "web", "", "22863b592c824bfc8989d9cba76abcde"},
+		{"namedKey", "", "web-high", "warning", "11863b592c824bfc8989d9cba76abcde"},
+		{"literalPrecedence", "44863b592c824bfc8989d9cba76abcde", "web", "critical", "44863b592c824bfc8989d9cba76abcde"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ResolveSeverityKey(tt.val, tt.keyName, tt.severity)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if key != tt.expected {
+				t.Errorf("Expected key %v, was %v.", tt.expected, key)
+			}
+		})
+	}
+}
+
+func TestResolveSeverityKeyNoDefault(t *testing.T) {
+	viper.Set("severity-routes", map[string]interface{}{
+		"web": map[string]interface{}{"critical": "11863b592c824bfc8989d9cba76abcde"},
+	})
+	defer viper.Set("severity-routes", nil)
+
+	_, err := ResolveSeverityKey("", "web", "warning")
+	if err == nil || err.Error() != "severity route web has no key for severity warning and no default" {
+		t.Errorf("Expected missing route error, was %v.", err)
+	}
+}
+
+func TestValidateKeyFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		version eventsapi.EventVersion
+		err     string
+	}{
+		{"v2", "11863b592c824bfc8989d9cba76abcde", eventsapi.EventVersion2, ""},
+		{"v2Uppercase", "11863B592C824BFC8989D9CBA76ABCDE", eventsapi.EventVersion2, ""},
+		{"v2Short", "11863b592c824bfc8989d9cba76abcd", eventsapi.EventVersion2, "routing key 11****cd is 31 characters long, expected 32"},
+		{"v2NotHex", "11863b592c824bfc8989d9cba76abcdz", eventsapi.EventVersion2, `routing key 11****dz may only contain hexadecimal characters, found 'z'`},
+		{"v2Whitespace", "11863b592c824bfc8989d9cba76abcd ", eventsapi.EventVersion2, `routing key 11****d  may only contain hexadecimal characters, found ' '`},
+		{"change", "11863b592c824bfc8989d9cba76abcde", eventsapi.EventVersionChange2, ""},
+		{"v1", "a1863b592c824bfc8989d9cba76abxyz", eventsapi.EventVersion1, ""},
+		{"v1Long", "a1863b592c824bfc8989d9cba76abxyz0", eventsapi.EventVersion1, "service key a1****z0 is 33 characters long, expected 32"},
+		{"v1Punctuation", "a1863b592c824bfc8989d9cba76ab-yz", eventsapi.EventVersion1, `service key a1****yz may only contain letters and digits, found '-'`},
+		{"empty", "", eventsapi.EventVersion2, "routing key **** is 0 characters long, expected 32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateKeyFormat(tt.key, tt.version)
+			if tt.err == "" && err != nil {
+				t.Errorf("Expected a valid key, got %v.", err)
+			} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Errorf("Expected error %q, got %v.", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package cmdutil
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func TestSetLinks(t *testing.T) {
+	links := []eventsapi.LinkV2{{Href: "https://nagios.example.com/host", Text: "Nagios"}}
+	images := []eventsapi.ImageV2{{Source: "https://graphs.example.com/cpu.png", Alt: "CPU"}}
+
+	tests := []struct {
+		name     string
+		event    eventsapi.Event
+		expected string
+	}{
+		{
+			name:     "v1",
+			event:    &eventsapi.EventV1{},
+			expected: `[{"type":"link","href":"https://nagios.example.com/host","text":"Nagios"},{"type":"image","src":"https://graphs.example.com/cpu.png","alt":"CPU"}]`,
+		},
+		{
+			name:     "v2",
+			event:    &eventsapi.EventV2{},
+			expected: `[{"href":"https://nagios.example.com/host","text":"Nagios"}][{"src":"https://graphs.example.com/cpu.png","alt":"CPU"}]`,
+		},
+		{
+			name:     "change",
+			event:    &eventsapi.ChangeEventV2{},
+			expected: `[{"href":"https://nagios.example.com/host","text":"Nagios"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetLinks(tt.event, links, images)
+
+			data, err := json.Marshal(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+
+			actual := string(fields["contexts"]) + string(fields["links"]) + string(fields["images"])
+			if actual != tt.expected {
+				t.Errorf("Expected %v, was %v.", tt.expected, actual)
+			}
+		})
+	}
+}
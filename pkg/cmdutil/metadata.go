@@ -0,0 +1,56 @@
+package cmdutil
+
+import (
+	"os"
+	"sync"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/viper"
+)
+
+// Custom details identifying the host and process an event was sent from,
+// prefixed so they can't collide with user details.
+const (
+	AgentHostDetail = "pd_agent_host"
+	AgentPIDDetail  = "pd_agent_pid"
+)
+
+var (
+	agentHostOnce sync.Once
+	agentHost     string
+)
+
+// AgentHost returns the hostname events are sent from, resolved on first use
+// and cached for the life of the process.
+func AgentHost() string {
+	agentHostOnce.Do(func() {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		agentHost = host
+	})
+	return agentHost
+}
+
+// WithoutAgentMetadata disables agent metadata, e.g. so tests can match the
+// events they send exactly, returning a function restoring the setting.
+func WithoutAgentMetadata() func() {
+	old := viper.GetBool("no-agent-metadata")
+	viper.Set("no-agent-metadata", true)
+	return func() { viper.Set("no-agent-metadata", old) }
+}
+
+// addAgentMetadata adds the host and process of the command sending an event
+// to its custom details, unless disabled with `no-agent-metadata`.
+//
+// Only events sent by the CLI carry this: it's added before the event reaches
+// the agent server, so events the server receives some other way, e.g. on
+// `/ingest`, don't.
+func addAgentMetadata(sendEvent eventsapi.Event) {
+	if viper.GetBool("no-agent-metadata") {
+		return
+	}
+	sendEvent.AddCustomDetail(AgentHostDetail, AgentHost())
+	sendEvent.AddCustomDetail(AgentPIDDetail, os.Getpid())
+}
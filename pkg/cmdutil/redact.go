@@ -0,0 +1,32 @@
+package cmdutil
+
+import "github.com/PagerDuty/go-pdagent/pkg/common"
+
+// Redact masks routing and service keys in output shown to users, keeping
+// only the first and last two characters. See `common.RedactSecrets`, which
+// the logger applies to every entry.
+func Redact(s string) string {
+	return common.RedactSecrets(s)
+}
+
+// RedactError returns an error whose message has keys masked, as with
+// `Redact`, while still wrapping the original error. Returns nil for a nil
+// error.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err}
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
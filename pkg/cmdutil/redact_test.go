@@ -0,0 +1,38 @@
+package cmdutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+)
+
+func TestRedactError(t *testing.T) {
+	key := "6b0c4d9e2f1a4c8b9d7e3f2a1b0c9d8e"
+	common.RegisterSecret(key)
+
+	cause := errors.New("unknown routing key " + key)
+	err := RedactError(cause)
+
+	if strings.Contains(err.Error(), key) {
+		t.Errorf("Expected key to be redacted, was %q.", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected redacted error to wrap the original.")
+	}
+	if RedactError(nil) != nil {
+		t.Error("Expected nil for a nil error.")
+	}
+}
+
+func TestResolveKeyRegistersSecret(t *testing.T) {
+	key, err := ResolveKey("f00dfeed0123456789abcdef01234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Redact("sending to " + key); strings.Contains(got, key) {
+		t.Errorf("Expected resolved key to be redacted, was %q.", got)
+	}
+}
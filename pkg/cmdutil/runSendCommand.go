@@ -16,31 +16,192 @@ limitations under the License.
 package cmdutil
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"strings"
 
+	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
-func RunSendCommand(config *Config, sendEvent eventsapi.Event, customDetails map[string]string) error {
+// ErrAgentOverloaded occurs when the agent server is still rate limiting
+// events after the client's retries.
+var ErrAgentOverloaded = errors.New("the agent is overloaded with events and rejected this one, try again shortly")
+
+// ErrAgentUnreachable occurs when the agent server doesn't respond healthy
+// before sending an event.
+var ErrAgentUnreachable = errors.New("the agent isn't reachable, check `pdagent server` is running")
+
+// RequireAgent returns an error unless the agent server responds healthy, so
+// commands can fail before enqueuing rather than leave callers, e.g. Nagios,
+// believing an event was accepted.
+func RequireAgent(config *Config) error {
+	c, err := config.Client()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Healthz()
+	if err != nil {
+		return fmt.Errorf("%w at %v: %v", ErrAgentUnreachable, c.ServerAddress, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w at %v: health check returned HTTP %v", ErrAgentUnreachable, c.ServerAddress, resp.StatusCode)
+	}
+	return nil
+}
+
+// ErrSendCancelled occurs when a send is interrupted, e.g. by Ctrl-C, before
+// the agent server accepted the event.
+var ErrSendCancelled = errors.New("interrupted before the agent accepted the event")
+
+// RunSendCommand sends an event to the agent server, printing its response
+// unless `quiet` is set. Cancelling `ctx` abandons the request.
+//
+// Failures are returned as an `ExitError`, or an error `ExitCode` otherwise
+// classifies, should the agent be unreachable or not accept the event.
+func RunSendCommand(ctx context.Context, config *Config, sendEvent eventsapi.Event, customDetails map[string]string, options ...client.SendOption) error {
+	respBody, err := sendToAgent(ctx, config, sendEvent, customDetails, options...)
+	if err != nil {
+		return err
+	}
+
+	if !viper.GetBool("quiet") {
+		fmt.Println(Redact(string(respBody)))
+	}
+	return nil
+}
+
+// sendToAgent sends an event to the agent server, returning the body of its
+// response once it's accepted.
+func sendToAgent(ctx context.Context, config *Config, sendEvent eventsapi.Event, customDetails map[string]string, options ...client.SendOption) ([]byte, error) {
 	// Manually inserting each custom detail due to the map type mismatch.
 	for k, v := range customDetails {
 		sendEvent.AddCustomDetail(k, v)
 	}
+	addAgentMetadata(sendEvent)
 
-	c, _ := config.Client()
+	common.RegisterSecret(sendEvent.GetRoutingKey())
 
-	resp, err := c.Send(sendEvent)
+	c, err := config.Client()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	resp, err := c.SendContext(ctx, sendEvent, options...)
+	if errors.Is(err, context.Canceled) {
+		return nil, ErrSendCancelled
+	} else if err != nil {
+		return nil, &ExitError{Code: sendErrorCode(err), Err: RedactError(err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, ErrAgentOverloaded
 	}
 
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println(err)
+		return nil, &ExitError{Code: ExitNotConfirmed, Err: err}
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, &ExitError{Code: responseCode(resp.StatusCode), Err: responseError(resp.StatusCode, respBody)}
+	}
+	return respBody, nil
+}
+
+// responseError describes an agent's non-2xx response, including any errors
+// it gave.
+func responseError(statusCode int, body []byte) error {
+	var errResp struct {
+		Errors []string `json:"errors"`
+	}
+	if json.Unmarshal(body, &errResp) == nil && len(errResp.Errors) > 0 {
+		return fmt.Errorf("the agent didn't accept the event (HTTP %v): %v", statusCode, Redact(strings.Join(errResp.Errors, ", ")))
+	}
+	return fmt.Errorf("the agent didn't accept the event (HTTP %v)", statusCode)
+}
+
+// RunDryRunCommand prints the event that `RunSendCommand` would send as
+// indented JSON, without contacting the agent server.
+func RunDryRunCommand(sendEvent eventsapi.Event, customDetails map[string]string) error {
+	for k, v := range customDetails {
+		sendEvent.AddCustomDetail(k, v)
+	}
+	addAgentMetadata(sendEvent)
+
+	body, err := json.MarshalIndent(sendEvent, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(body))
+	return nil
+}
+
+// maskedKeyFields are event fields holding the routing key.
+var maskedKeyFields = []string{"routing_key", "service_key"}
+
+// PrintVerbose writes the flags set, incident key, and event about to be sent
+// to `w`, e.g. stderr, for troubleshooting. The routing key is masked
+// wherever it appears.
+func PrintVerbose(w io.Writer, flags *pflag.FlagSet, sendEvent eventsapi.Event) error {
+	routingKey := sendEvent.GetRoutingKey()
+	mask := func(val string) string {
+		if routingKey != "" && val == routingKey {
+			return common.MaskSecret(val)
+		}
+		return val
+	}
+
+	fmt.Fprintln(w, "Flags:")
+	flags.Visit(func(f *pflag.Flag) {
+		fmt.Fprintf(w, "  --%v=%v\n", f.Name, mask(f.Value.String()))
+	})
+
+	fmt.Fprintf(w, "Incident key: %v\n", incidentKey(sendEvent))
+
+	body, err := json.Marshal(sendEvent)
+	if err != nil {
+		return err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
 		return err
 	}
+	for _, field := range maskedKeyFields {
+		if key, ok := payload[field].(string); ok {
+			payload[field] = common.MaskSecret(key)
+		}
+	}
 
-	fmt.Println(string(respBody))
+	body, err = json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Payload:\n%v\n", string(body))
 	return nil
 }
+
+// incidentKey returns the key correlating an event with others, i.e. the V1
+// incident key or V2 dedup key.
+func incidentKey(sendEvent eventsapi.Event) string {
+	switch e := sendEvent.(type) {
+	case *eventsapi.EventV1:
+		return e.IncidentKey
+	case *eventsapi.EventV2:
+		return e.DedupKey
+	default:
+		return ""
+	}
+}
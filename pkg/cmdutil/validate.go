@@ -1,5 +1,10 @@
 package cmdutil
 
+import (
+	"fmt"
+	"net/url"
+)
+
 func ValidateEnumField(inputVal string, allowedValues []string, err error) error {
 	for _, value := range allowedValues {
 		if value == inputVal {
@@ -8,3 +13,26 @@ func ValidateEnumField(inputVal string, allowedValues []string, err error) error
 	}
 	return err
 }
+
+// ValidateEventsURL returns an error unless `val`, an Events API base URL
+// overriding the region's, is empty or an absolute HTTP or HTTPS URL.
+func ValidateEventsURL(val string) error {
+	return validateHTTPURL("events-url", val)
+}
+
+// ValidateOTelEndpoint returns an error unless `val`, the OpenTelemetry
+// collector spans are exported to, is empty or an absolute HTTP or HTTPS URL.
+func ValidateOTelEndpoint(val string) error {
+	return validateHTTPURL("otel-endpoint", val)
+}
+
+func validateHTTPURL(name, val string) error {
+	if val == "" {
+		return nil
+	}
+	u, err := url.Parse(val)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%v %q must be an absolute http or https URL", name, val)
+	}
+	return nil
+}
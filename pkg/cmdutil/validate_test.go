@@ -0,0 +1,26 @@
+package cmdutil
+
+import "testing"
+
+func TestValidateEventsURL(t *testing.T) {
+	tests := []struct {
+		val   string
+		valid bool
+	}{
+		{"", true},
+		{"https://gateway.example.com", true},
+		{"http://10.0.0.1:8080/pagerduty/", true},
+		{"gateway.example.com", false},
+		{"/pagerduty", false},
+		{"ftp://gateway.example.com", false},
+		{"https://", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			if err := ValidateEventsURL(tt.val); (err == nil) != tt.valid {
+				t.Errorf("Expected valid to be %v, got error %v", tt.valid, err)
+			}
+		})
+	}
+}
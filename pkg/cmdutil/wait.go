@@ -0,0 +1,133 @@
+package cmdutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/viper"
+)
+
+// DefaultWaitTimeout bounds how long commands wait for delivery, allowing for
+// a few of the agent's retries.
+const DefaultWaitTimeout = time.Minute
+
+// waitPollInterval is how often the agent is asked whether an event was
+// delivered, replaceable in tests.
+var waitPollInterval = 500 * time.Millisecond
+
+var errWaitTimeout = errors.New("timed out waiting for the agent to deliver the event")
+var errWaitCancelled = errors.New("interrupted waiting for the agent to deliver the event")
+
+// eventStatus is the agent's delivery state of an event, as returned by
+// `client.EventStatus`.
+type eventStatus struct {
+	Key      string `json:"key"`
+	Status   string `json:"status"`
+	DedupKey string `json:"dedup_key"`
+	Error    string `json:"error"`
+}
+
+// RunSendAndWaitCommand sends an event as `RunSendCommand` does, then waits
+// up to `timeout` for the agent to deliver it to PagerDuty, printing the
+// dedup (or incident) key PagerDuty returned unless `quiet` is set.
+//
+// Should the agent fail to deliver the event the error exits with
+// `ExitUndelivered`, while timing out or being cancelled exits with
+// `ExitNotConfirmed` as the event may yet be delivered.
+func RunSendAndWaitCommand(ctx context.Context, config *Config, sendEvent eventsapi.Event, customDetails map[string]string, timeout time.Duration, options ...client.SendOption) error {
+	respBody, err := sendToAgent(ctx, config, sendEvent, customDetails, options...)
+	if err != nil {
+		return err
+	}
+
+	var sendResp struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(respBody, &sendResp); err != nil || sendResp.Key == "" {
+		return &ExitError{Code: ExitNotConfirmed, Err: fmt.Errorf("the agent didn't return the event's key: %v", Redact(string(respBody)))}
+	}
+
+	c, err := config.Client()
+	if err != nil {
+		return err
+	}
+
+	status, err := waitForDelivery(ctx, c, sendResp.Key, timeout)
+	if err != nil {
+		return err
+	}
+
+	if !viper.GetBool("quiet") {
+		fmt.Println(status.DedupKey)
+	}
+	return nil
+}
+
+// waitForDelivery polls the agent until the event with `key` is delivered or
+// fails, or `timeout` elapses.
+//
+// Errors polling are retried, e.g. should the agent be restarting, and
+// reported should the wait time out.
+func waitForDelivery(ctx context.Context, c *client.Client, key string, timeout time.Duration) (*eventStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		status, err := fetchEventStatus(c, key)
+		switch {
+		case err != nil:
+			lastErr = err
+		case status.Status == "success":
+			return status, nil
+		case status.Status == "error":
+			return nil, &ExitError{Code: ExitUndelivered, Err: fmt.Errorf("the agent failed to deliver event %v: %v", key, Redact(status.Error))}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			waitErr := errWaitTimeout
+			if errors.Is(ctx.Err(), context.Canceled) {
+				waitErr = errWaitCancelled
+			}
+			if lastErr != nil {
+				waitErr = fmt.Errorf("%w, last error: %v", waitErr, RedactError(lastErr))
+			}
+			return nil, &ExitError{Code: ExitNotConfirmed, Err: waitErr}
+		}
+	}
+}
+
+// fetchEventStatus returns the agent's delivery state of an event.
+func fetchEventStatus(c *client.Client, key string) (*eventStatus, error) {
+	resp, err := c.EventStatus(key)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("event status returned HTTP %v", resp.StatusCode)
+	}
+
+	var status eventStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
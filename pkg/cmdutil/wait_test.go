@@ -0,0 +1,79 @@
+package cmdutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunSendAndWaitCommand(t *testing.T) {
+	waitPollInterval = 10 * time.Millisecond
+	defer func() { waitPollInterval = 500 * time.Millisecond }()
+
+	tests := []struct {
+		name      string
+		statuses  []string
+		timeout   time.Duration
+		expected  int
+		expectOut string
+	}{
+		{
+			name:      "confirmed",
+			statuses:  []string{`{"key":"abc","status":"pending"}`, `{"key":"abc","status":"success","dedup_key":"returned-dedup-key"}`},
+			timeout:   time.Second,
+			expected:  ExitSuccess,
+			expectOut: "returned-dedup-key",
+		},
+		{
+			name:     "failed",
+			statuses: []string{`{"key":"abc","status":"in_flight"}`, `{"key":"abc","status":"error","error":"invalid event"}`},
+			timeout:  time.Second,
+			expected: ExitUndelivered,
+		},
+		{
+			name:     "timeout",
+			statuses: []string{`{"key":"abc","status":"pending"}`},
+			timeout:  100 * time.Millisecond,
+			expected: ExitNotConfirmed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls int32
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/send":
+					rw.Write([]byte(`{"key":"abc"}`))
+				case "/queue/event":
+					if req.URL.Query().Get("key") != "abc" {
+						rw.WriteHeader(http.StatusNotFound)
+						return
+					}
+					i := int(atomic.AddInt32(&polls, 1)) - 1
+					if i >= len(tt.statuses) {
+						i = len(tt.statuses) - 1
+					}
+					rw.Write([]byte(tt.statuses[i]))
+				}
+			}))
+			defer ts.Close()
+
+			config := testSendConfig(ts.Listener.Addr().String(), time.Second)
+			out, err := captureStdout(func() error {
+				return RunSendAndWaitCommand(context.Background(), config, testSendEvent(), nil, tt.timeout)
+			})
+
+			if code := ExitCode(nil, err); code != tt.expected {
+				t.Errorf("Expected exit code %v, was %v for %v.", tt.expected, code, err)
+			}
+			if strings.TrimSpace(out) != tt.expectOut {
+				t.Errorf("Expected output %q, got %q.", tt.expectOut, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package common
+
+import "time"
+
+// Clock is a source of time, so behavior that depends on it, such as retries,
+// TTLs, and rate limits, can be tested without waiting for real time to pass.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// RealClock is a `Clock` using the system time.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (RealClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
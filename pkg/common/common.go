@@ -4,44 +4,38 @@ import (
 	"fmt"
 	"os"
 	"runtime"
-	"time"
+	"strings"
 
+	"github.com/PagerDuty/go-pdagent/pkg/version"
 	"github.com/spf13/viper"
 )
 
-// Normally set at build time.
-var Commit = "unavailable"
-var Date = time.Now().Format(time.RFC3339)
-var Version = "unavailable"
-
-func init() {
-	if Commit == "" {
-		Commit = "unavailable"
-	}
-
-	if Date == "" {
-		Date = time.Now().Format(time.RFC3339)
-	}
-
-	if Version == "" {
-		Version = "unavailable"
-	}
-}
-
 func IsProduction() bool {
 	return os.Getenv("APP_ENV") == "production"
 }
 
-func UserAgent() string {
-	version := Version
-	system := runtime.GOOS
-	commit := Commit
-	date := Date
-
-	return fmt.Sprintf("go-pdagent/%v (%v, commit: %v, date: %v)", version, system, commit, date)
+// UserAgent returns the `User-Agent` the agent identifies itself with, naming
+// the integration the request is on behalf of, e.g. "nagios", if any.
+//
+// The `user-agent` setting replaces it entirely, e.g. for proxies expecting a
+// particular value.
+func UserAgent(integration string) string {
+	if userAgent := viper.GetString("user-agent"); userAgent != "" {
+		return userAgent
+	}
+	if integration != "" {
+		return fmt.Sprintf("go-pdagent/%v (%v; %v, commit: %v, date: %v)", version.Version, integration, runtime.GOOS, version.Commit, version.Date)
+	}
+	return fmt.Sprintf("go-pdagent/%v (%v, commit: %v, date: %v)", version.Version, runtime.GOOS, version.Commit, version.Date)
 }
 
+// PdEventsUrl returns the base URL events are sent to: `events-url` if set,
+// e.g. for an internal event gateway, otherwise the region's Events API.
 func PdEventsUrl() string {
+	if eventsURL := viper.GetString("events-url"); eventsURL != "" {
+		return strings.TrimRight(eventsURL, "/")
+	}
+
 	region := viper.GetString("region")
 	if region == "eu" {
 		return "https://events.eu.pagerduty.com"
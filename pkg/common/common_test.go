@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/version"
+	"github.com/spf13/viper"
+)
+
+func TestRegionURLs(t *testing.T) {
+	defer viper.Set("region", nil)
+
+	tests := []struct {
+		region         string
+		expectedEvents string
+		expectedAPI    string
+	}{
+		{"", "https://events.pagerduty.com", "https://api.pagerduty.com"},
+		{"us", "https://events.pagerduty.com", "https://api.pagerduty.com"},
+		{"eu", "https://events.eu.pagerduty.com", "https://api.eu.pagerduty.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			viper.Set("region", tt.region)
+
+			if url := PdEventsUrl(); url != tt.expectedEvents {
+				t.Errorf("Expected events URL %v, was %v", tt.expectedEvents, url)
+			}
+			if url := PdApiUrl(); url != tt.expectedAPI {
+				t.Errorf("Expected API URL %v, was %v", tt.expectedAPI, url)
+			}
+		})
+	}
+}
+
+func TestEventsURLOverride(t *testing.T) {
+	defer viper.Set("region", nil)
+	defer viper.Set("events-url", nil)
+
+	viper.Set("region", "eu")
+	viper.Set("events-url", "https://gateway.example.com/pagerduty/")
+
+	if url := PdEventsUrl(); url != "https://gateway.example.com/pagerduty" {
+		t.Errorf("Expected the overridden events URL, was %v", url)
+	}
+	if url := PdApiUrl(); url != "https://api.eu.pagerduty.com" {
+		t.Errorf("Expected the region's API URL, was %v", url)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	defer viper.Set("user-agent", nil)
+
+	base := fmt.Sprintf("go-pdagent/%v (%v, commit: %v, date: %v)", version.Version, runtime.GOOS, version.Commit, version.Date)
+	if userAgent := UserAgent(""); userAgent != base {
+		t.Errorf("Expected %q, was %q", base, userAgent)
+	}
+
+	withIntegration := fmt.Sprintf("go-pdagent/%v (nagios; %v, commit: %v, date: %v)", version.Version, runtime.GOOS, version.Commit, version.Date)
+	if userAgent := UserAgent("nagios"); userAgent != withIntegration {
+		t.Errorf("Expected %q, was %q", withIntegration, userAgent)
+	}
+
+	viper.Set("user-agent", "custom-agent/1.0")
+	for _, integration := range []string{"", "nagios"} {
+		if userAgent := UserAgent(integration); userAgent != "custom-agent/1.0" {
+			t.Errorf("Expected the override for %q, was %q", integration, userAgent)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// DefaultCompressMinBytes is the smallest request body `CompressTransport`
+// compresses by default, below which gzip's overhead outweighs any saving.
+const DefaultCompressMinBytes = 1024
+
+// CompressTransport gzips request bodies of at least `MinBytes` as an
+// `http.RoundTripper`, setting `Content-Encoding: gzip`.
+//
+// Smaller bodies, those of unknown length, and those already encoded are sent
+// as is.
+type CompressTransport struct {
+	MinBytes  int
+	Transport http.RoundTripper
+}
+
+func NewCompressTransport(transport http.RoundTripper, minBytes int) CompressTransport {
+	return CompressTransport{
+		MinBytes:  minBytes,
+		Transport: transport,
+	}
+}
+
+// Implementing the `http.RoundTripper` interface.
+func (c CompressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.ContentLength <= 0 || req.ContentLength < int64(c.MinBytes) || req.Header.Get("Content-Encoding") != "" {
+		return c.Transport.RoundTrip(req)
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	compressed := buf.Bytes()
+
+	// A `RoundTripper` mustn't modify the request it's given.
+	compressedReq := req.Clone(req.Context())
+	compressedReq.Header.Set("Content-Encoding", "gzip")
+	compressedReq.ContentLength = int64(len(compressed))
+	compressedReq.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+	compressedReq.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+	}
+
+	return c.Transport.RoundTrip(compressedReq)
+}
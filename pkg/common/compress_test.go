@@ -0,0 +1,58 @@
+package common
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/h2non/gock.v1"
+)
+
+func compressTestClient(minBytes int) *http.Client {
+	return &http.Client{Transport: NewCompressTransport(gock.NewTransport(), minBytes)}
+}
+
+func TestCompressTransportCompresses(t *testing.T) {
+	defer gock.Off()
+
+	expectedBody := map[string]string{"summary": strings.Repeat("disk full ", 200)}
+
+	// Requires `Content-Encoding: gzip`, matching the decompressed body.
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Compression("gzip").
+		JSON(expectedBody).
+		Reply(202)
+
+	body := []byte(`{"summary":"` + expectedBody["summary"] + `"}`)
+	resp, err := compressTestClient(DefaultCompressMinBytes).Post("https://events.pagerduty.com/test", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 202 {
+		t.Errorf("Expected a compressed request to match, response was %+v.", resp)
+	}
+}
+
+func TestCompressTransportSkipsSmallBodies(t *testing.T) {
+	defer gock.Off()
+
+	expectedBody := map[string]string{"summary": "disk full"}
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("Content-Encoding") == "", nil
+		}).
+		JSON(expectedBody).
+		Reply(202)
+
+	resp, err := compressTestClient(DefaultCompressMinBytes).Post("https://events.pagerduty.com/test", "application/json", bytes.NewBufferString(`{"summary":"disk full"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 202 {
+		t.Errorf("Expected an uncompressed request to match, response was %+v.", resp)
+	}
+}
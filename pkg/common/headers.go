@@ -0,0 +1,75 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// reservedHeaders are set by the agent itself and may not be overridden by
+// configured headers.
+var reservedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Connection":          true,
+	"Content-Encoding":    true,
+	"Content-Length":      true,
+	"Content-Type":        true,
+	"Host":                true,
+	"Proxy-Authorization": true,
+	"Transfer-Encoding":   true,
+	"User-Agent":          true,
+}
+
+// ParseHeaders parses headers given as "Name: value", e.g. from a repeated
+// `--header` flag, returning an error for malformed or reserved headers such
+// as `Authorization` or `Content-Type`.
+func ParseHeaders(headers []string) (http.Header, error) {
+	parsed := http.Header{}
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
+		}
+		if reservedHeaders[http.CanonicalHeaderKey(name)] {
+			return nil, fmt.Errorf("header %v is set by the agent and can't be overridden", http.CanonicalHeaderKey(name))
+		}
+		parsed.Add(name, strings.TrimSpace(parts[1]))
+	}
+	return parsed, nil
+}
+
+// HeaderTransport adds `Headers` to every request as an `http.RoundTripper`,
+// e.g. for a proxy routing on a tenant header.
+//
+// Reserved headers are never set, even if present in `Headers`.
+type HeaderTransport struct {
+	Headers   http.Header
+	Transport http.RoundTripper
+}
+
+func NewHeaderTransport(transport http.RoundTripper, headers http.Header) HeaderTransport {
+	return HeaderTransport{
+		Headers:   headers,
+		Transport: transport,
+	}
+}
+
+// Implementing the `http.RoundTripper` interface.
+func (h HeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(h.Headers) == 0 {
+		return h.Transport.RoundTrip(req)
+	}
+
+	// A `RoundTripper` mustn't modify the request it's given.
+	req = req.Clone(req.Context())
+	for name, values := range h.Headers {
+		name = http.CanonicalHeaderKey(name)
+		if reservedHeaders[name] {
+			continue
+		}
+		req.Header[name] = append([]string(nil), values...)
+	}
+
+	return h.Transport.RoundTrip(req)
+}
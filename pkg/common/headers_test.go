@@ -0,0 +1,73 @@
+package common
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{"X-Tenant-Id: tenant-a", "x-route:  eu, primary "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := headers.Get("X-Tenant-Id"); v != "tenant-a" {
+		t.Errorf("Expected X-Tenant-Id to be tenant-a, was %q.", v)
+	}
+	if v := headers.Get("X-Route"); v != "eu, primary" {
+		t.Errorf("Expected X-Route to be trimmed, was %q.", v)
+	}
+
+	invalid := []string{"X-Tenant-Id", ": value", "X Tenant: value"}
+	for _, header := range invalid {
+		if _, err := ParseHeaders([]string{header}); err == nil || !strings.Contains(err.Error(), "invalid header") {
+			t.Errorf("Expected %q to be invalid, error was %v.", header, err)
+		}
+	}
+
+	reserved := []string{"Content-Type: text/plain", "authorization: token abc", "User-Agent: other"}
+	for _, header := range reserved {
+		if _, err := ParseHeaders([]string{header}); err == nil || !strings.Contains(err.Error(), "can't be overridden") {
+			t.Errorf("Expected %q to be reserved, error was %v.", header, err)
+		}
+	}
+}
+
+func TestHeaderTransport(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		MatchHeader("X-Tenant-Id", "tenant-a").
+		MatchHeader("Content-Type", "application/json").
+		MatchHeader("Authorization", "token secret").
+		Reply(202)
+
+	// Reserved headers are skipped even if configured directly.
+	headers := http.Header{
+		"X-Tenant-Id":   {"tenant-a"},
+		"Content-Type":  {"text/plain"},
+		"Authorization": {"token clobbered"},
+	}
+	client := &http.Client{Transport: NewHeaderTransport(gock.NewTransport(), headers)}
+
+	req, err := http.NewRequest("POST", "https://events.pagerduty.com/test", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "token secret")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 202 {
+		t.Errorf("Expected configured headers without clobbering reserved ones, response was %+v.", resp)
+	}
+	if req.Header.Get("X-Tenant-Id") != "" {
+		t.Error("Expected the original request to be left unmodified.")
+	}
+}
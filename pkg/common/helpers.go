@@ -1,6 +1,8 @@
 package common
 
 import (
+	crand "crypto/rand"
+	"fmt"
 	"math/rand"
 	"time"
 )
@@ -24,6 +26,18 @@ func GenerateKey() string {
 	return string(rk)
 }
 
+// GenerateUUID generates a random (version 4) UUID, falling back to
+// `GenerateKey` should the system's random source fail.
+func GenerateUUID() string {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return GenerateKey()
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
 func randChar() byte {
 	return rkChars[r.Intn(len(rkChars))]
 }
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"regexp"
 	"testing"
 )
 
@@ -9,3 +10,14 @@ func TestGenerateRoutingKey(t *testing.T) {
 		t.Error("Expected routing key to be exactly 32 characters.")
 	}
 }
+
+func TestGenerateUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	uuid := GenerateUUID()
+	if !pattern.MatchString(uuid) {
+		t.Errorf("Expected a version 4 UUID, got %v.", uuid)
+	}
+	if GenerateUUID() == uuid {
+		t.Error("Expected each UUID to be unique.")
+	}
+}
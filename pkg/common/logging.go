@@ -1,23 +1,118 @@
 package common
 
 import (
+	"fmt"
+
+	"github.com/PagerDuty/go-pdagent/pkg/version"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Field names used for structured logging, kept consistent across packages so
+// entries can be correlated by log aggregators.
+const (
+	LogFieldEventID    = "event_id"
+	LogFieldRoutingKey = "routing_key"
+	LogFieldHTTPStatus = "http_status"
+	LogFieldAttempt    = "attempt"
+	LogFieldVersion    = "version"
 )
 
 var BaseLogger *zap.Logger
 var Logger *zap.SugaredLogger
 
+// logLevel is shared by all loggers built by `InitLogger`, so `SetLogLevel`
+// changes it for those already derived from `Logger` too.
+var logLevel = zap.NewAtomicLevel()
+
 // TODO: Eventually move configuration to config files.
 func init() {
+	if err := InitLogger("", ""); err != nil {
+		BaseLogger, _ = zap.NewDevelopment()
+		Logger = BaseLogger.Sugar()
+	}
+}
+
+// InitLogger (re)initializes the package loggers.
+//
+// `format` is either "text" or "json" and `level` one of "debug", "info",
+// "warn", or "error". Empty values keep the environment's defaults:
+// human-readable debug logging in development and JSON info logging to a file
+// in production.
+//
+// Routing and service keys are masked in all entries, see `RedactSecrets`.
+//
+// Loggers derived from `Logger` before calling this keep their original
+// configuration.
+func InitLogger(format, level string) error {
+	config, err := NewLoggerConfig(format, level)
+	if err != nil {
+		return err
+	}
+
+	logger, err := config.Build(zap.WrapCore(NewRedactCore))
+	if err != nil {
+		return err
+	}
+
+	BaseLogger = logger
+	Logger = BaseLogger.Sugar()
+	logLevel = config.Level
+	return nil
+}
+
+// SetLogLevel changes the minimum level of loggers built by `InitLogger`,
+// including those already derived from `Logger`, e.g. on reload.
+//
+// `level` is as for `InitLogger`, with an empty value restoring the
+// environment's default.
+func SetLogLevel(level string) error {
+	config, err := NewLoggerConfig("", level)
+	if err != nil {
+		return err
+	}
+
+	logLevel.SetLevel(config.Level.Level())
+	return nil
+}
+
+// NewLoggerConfig returns the logger configuration for a format and level,
+// see `InitLogger`.
+func NewLoggerConfig(format, level string) (zap.Config, error) {
+	var config zap.Config
 	if IsProduction() {
-		config := zap.NewProductionConfig()
+		config = zap.NewProductionConfig()
 		config.OutputPaths = []string{
 			"/var/log/pdagent/pdagent.log",
 		}
-		BaseLogger, _ = config.Build()
 	} else {
-		BaseLogger, _ = zap.NewDevelopment()
+		config = zap.NewDevelopmentConfig()
 	}
 
-	Logger = BaseLogger.Sugar()
+	switch format {
+	case "":
+	case "text":
+		config.Encoding = "console"
+	case "json":
+		config.Encoding = "json"
+		config.EncoderConfig = zap.NewProductionEncoderConfig()
+	default:
+		return config, fmt.Errorf(`log format must be either "text" or "json", was %q`, format)
+	}
+
+	if level != "" {
+		var l zapcore.Level
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return config, fmt.Errorf(`log level must be one of "debug", "info", "warn", or "error", was %q`, level)
+		}
+		config.Level = zap.NewAtomicLevelAt(l)
+	}
+
+	// Structured logs include the agent version so entries from a fleet can be
+	// attributed to a build.
+	if config.Encoding == "json" {
+		config.InitialFields = map[string]interface{}{LogFieldVersion: version.Version}
+	}
+
+	return config, nil
 }
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/version"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestJSONLogging(t *testing.T) {
+	logFile, err := ioutil.TempFile("", "pdagent-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile.Close()
+	defer os.Remove(logFile.Name())
+
+	config, err := NewLoggerConfig("json", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.OutputPaths = []string{logFile.Name()}
+
+	logger, err := config.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := logger.Sugar()
+	log.Debugw("Not logged at info level.")
+	log.Infow("Event sent.",
+		LogFieldEventID, "abc",
+		LogFieldRoutingKey, MaskSecret("11863b592c824bfc8989d9cba76abcde"),
+		LogFieldHTTPStatus, 202,
+		LogFieldAttempt, 1,
+	)
+	_ = logger.Sync()
+
+	f, err := os.Open(logFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected valid JSON log entry, was %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("Expected exactly one entry at info level, was %v.", len(entries))
+	}
+
+	entry := entries[0]
+	for _, key := range []string{"level", "ts", "msg", LogFieldEventID, LogFieldRoutingKey, LogFieldHTTPStatus, LogFieldAttempt} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("Expected log entry to contain %v, was %v.", key, entry)
+		}
+	}
+
+	if entry[LogFieldRoutingKey] != "11****de" {
+		t.Errorf("Expected routing key to be redacted, was %v.", entry[LogFieldRoutingKey])
+	}
+
+	if entry[LogFieldVersion] != version.Version {
+		t.Errorf("Expected log entry to include version %v, was %v.", version.Version, entry[LogFieldVersion])
+	}
+}
+
+func TestLoggerConfigInvalid(t *testing.T) {
+	if _, err := NewLoggerConfig("xml", ""); err == nil {
+		t.Error("Expected an error for an invalid log format.")
+	}
+
+	if _, err := NewLoggerConfig("", "loud"); err == nil {
+		t.Error("Expected an error for an invalid log level.")
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	if err := InitLogger("", "info"); err != nil {
+		t.Fatal(err)
+	}
+	defer InitLogger("", "")
+	logger := Logger.Named("Derived").Desugar()
+
+	if err := SetLogLevel("error"); err != nil {
+		t.Fatal(err)
+	}
+	if logger.Core().Enabled(zapcore.WarnLevel) {
+		t.Error("Expected warnings to be disabled at error level.")
+	}
+	if !logger.Core().Enabled(zapcore.ErrorLevel) {
+		t.Error("Expected errors to be enabled at error level.")
+	}
+
+	// Restoring the development default.
+	if err := SetLogLevel(""); err != nil {
+		t.Fatal(err)
+	}
+	if !logger.Core().Enabled(zapcore.DebugLevel) {
+		t.Error("Expected the default level to be restored.")
+	}
+
+	if err := SetLogLevel("loud"); err == nil {
+		t.Error("Expected an error for an invalid log level.")
+	}
+}
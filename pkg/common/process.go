@@ -70,6 +70,17 @@ func TerminateProcess(pidfile string) error {
 	return proc.Signal(syscall.SIGTERM)
 }
 
+// ReloadProcess signals the process in `pidfile` to reload its
+// configuration.
+func ReloadProcess(pidfile string) error {
+	proc, err := getProcess(pidfile)
+	if err != nil {
+		return err
+	}
+
+	return proc.Signal(syscall.SIGHUP)
+}
+
 func fileExists(f string) (bool, error) {
 	_, err := os.Stat(f)
 	if os.IsNotExist(err) {
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a basic token bucket rate limiter, refilling at `rate` tokens
+// per second up to a maximum of `burst` tokens.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return NewTokenBucketWithClock(rate, burst, RealClock{})
+}
+
+// NewTokenBucketWithClock returns a `TokenBucket` refilling, and waiting for
+// tokens, by `clock`.
+func NewTokenBucketWithClock(rate float64, burst int, clock Clock) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+		clock:  clock,
+	}
+}
+
+// Reserve takes a token from the bucket, returning how long the caller must
+// wait before using it.
+func (b *TokenBucket) Reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Allow takes a token from the bucket only if one is available now. Otherwise
+// it returns false along with how long until one will be.
+func (b *TokenBucket) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Wait blocks until a token is available or `stop` is closed, returning nil,
+// or until `ctx` is done, returning its error.
+func (b *TokenBucket) Wait(ctx context.Context, stop <-chan bool) error {
+	delay := b.Reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-b.clock.After(delay):
+	case <-stop:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// refill adds tokens for the time elapsed since the last refill. Callers must
+// hold `mu`.
+func (b *TokenBucket) refill() {
+	now := b.clock.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
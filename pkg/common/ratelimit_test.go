@@ -0,0 +1,29 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketAllow(t *testing.T) {
+	b := NewTokenBucket(1, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.Allow(); !ok {
+			t.Fatalf("Expected burst of 2 to be allowed, failed on %v", i+1)
+		}
+	}
+
+	ok, retryAfter := b.Allow()
+	if ok {
+		t.Error("Expected token to be unavailable once the burst is used.")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("Expected retry within a second, was %v", retryAfter)
+	}
+
+	// Unlike Reserve, a refused Allow doesn't take a token.
+	if delay := b.Reserve(); delay > time.Second {
+		t.Errorf("Expected refused Allow not to be counted, Reserve delay was %v", delay)
+	}
+}
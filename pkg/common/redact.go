@@ -0,0 +1,169 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxRegisteredSecrets bounds the number of keys remembered for redaction.
+const maxRegisteredSecrets = 1000
+
+// secretFieldPattern matches a routing, service, or integration key given as
+// a flag, query parameter, or JSON field, e.g. `"service_key":"abc..."` or
+// `--routing-key=abc...`, capturing the name and the key separately.
+var secretFieldPattern = regexp.MustCompile(`(?i)((?:routing|service|integration)[_-]?key["']?\s*[:=]\s*["']?)([0-9a-z]{8,})`)
+
+var redaction = struct {
+	sync.RWMutex
+	enabled  bool
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}{
+	enabled:  true,
+	secrets:  map[string]bool{},
+	replacer: strings.NewReplacer(),
+}
+
+// SetRedaction enables or disables `RedactSecrets`, e.g. with `--no-redact`
+// while debugging. Redaction is enabled by default.
+func SetRedaction(enabled bool) {
+	redaction.Lock()
+	defer redaction.Unlock()
+	redaction.enabled = enabled
+}
+
+// RegisterSecret adds a key, e.g. a routing key the agent is sending with,
+// to those masked wherever they appear by `RedactSecrets`.
+//
+// Bare keys are only masked once registered: event IDs share the routing
+// key format, so masking every key-like string would hide them too.
+func RegisterSecret(secret string) {
+	if len(secret) < 8 {
+		return
+	}
+
+	redaction.Lock()
+	defer redaction.Unlock()
+
+	if redaction.secrets[secret] || len(redaction.secrets) >= maxRegisteredSecrets {
+		return
+	}
+	redaction.secrets[secret] = true
+
+	pairs := make([]string, 0, 2*len(redaction.secrets))
+	for s := range redaction.secrets {
+		pairs = append(pairs, s, maskSecret(s))
+	}
+	redaction.replacer = strings.NewReplacer(pairs...)
+}
+
+// RedactSecrets masks routing and service keys in `s`, both registered keys
+// and any given as a named field or flag. Returns `s` unchanged if redaction
+// is disabled.
+func RedactSecrets(s string) string {
+	redaction.RLock()
+	enabled, replacer := redaction.enabled, redaction.replacer
+	redaction.RUnlock()
+
+	if !enabled {
+		return s
+	}
+
+	s = replacer.Replace(s)
+	return secretFieldPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := secretFieldPattern.FindStringSubmatch(match)
+		return parts[1] + maskSecret(parts[2])
+	})
+}
+
+// MaskSecret obscures all but the first and last two characters of a key,
+// e.g. a routing key being logged. Returns `secret` unchanged if redaction is
+// disabled.
+func MaskSecret(secret string) string {
+	redaction.RLock()
+	enabled := redaction.enabled
+	redaction.RUnlock()
+
+	if !enabled {
+		return secret
+	}
+	return maskSecret(secret)
+}
+
+func maskSecret(secret string) string {
+	const visible = 2
+	if len(secret) < 4*visible {
+		return "****"
+	}
+	return secret[:visible] + "****" + secret[len(secret)-visible:]
+}
+
+// redactCore wraps a logger core, applying `RedactSecrets` to messages and
+// fields before they're written. Structured fields, e.g. from `zap.Any` or
+// `zap.Object`, are encoded as JSON to be redacted.
+type redactCore struct {
+	zapcore.Core
+}
+
+// NewRedactCore returns a core redacting secrets from entries written to
+// `core`.
+func NewRedactCore(core zapcore.Core) zapcore.Core {
+	return &redactCore{core}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{c.Core.With(redactFields(fields))}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = RedactSecrets(ent.Message)
+	return c.Core.Write(ent, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = RedactSecrets(f.String)
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				f = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: RedactSecrets(err.Error())}
+			}
+		case zapcore.StringerType:
+			if s, ok := f.Interface.(interface{ String() string }); ok && s != nil {
+				f = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: RedactSecrets(s.String())}
+			}
+		case zapcore.ReflectType, zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+			f = redactStructuredField(f)
+		}
+		redacted[i] = f
+	}
+	return redacted
+}
+
+// redactStructuredField encodes a field as JSON and redacts it, keeping its
+// structure in the written entry.
+func redactStructuredField(f zapcore.Field) zapcore.Field {
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+
+	encoded, err := json.Marshal(enc.Fields[f.Key])
+	if err != nil {
+		return zap.String(f.Key, RedactSecrets(fmt.Sprintf("%+v", f.Interface)))
+	}
+	return zap.Reflect(f.Key, json.RawMessage(RedactSecrets(string(encoded))))
+}
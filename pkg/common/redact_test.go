@@ -0,0 +1,143 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedactSecretsFields(t *testing.T) {
+	key := "11863b592c824bfc8989d9cba76abcde"
+
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{`{"service_key":"` + key + `","event_type":"trigger"}`, `{"service_key":"11****de","event_type":"trigger"}`},
+		{`{"routing_key": "` + key + `"}`, `{"routing_key": "11****de"}`},
+		{"--routing-key=" + key, "--routing-key=11****de"},
+		{"/send?integration_key=" + key, "/send?integration_key=11****de"},
+		{"routing key must be set", "routing key must be set"},
+		{"event " + GenerateKey() + " sent", ""},
+	}
+
+	for _, c := range cases {
+		got := RedactSecrets(c.in)
+		if c.expected == "" {
+			c.expected = c.in
+		}
+		if got != c.expected {
+			t.Errorf("Expected %q to be redacted to %q, was %q.", c.in, c.expected, got)
+		}
+	}
+}
+
+func TestRedactSecretsRegistered(t *testing.T) {
+	key := "R015OQ8ZCAA2BI8EWFJE0Z3VGJO1ABCD"
+	RegisterSecret(key)
+
+	got := RedactSecrets("failed to send to " + key + ": unauthorized")
+	if strings.Contains(got, key) {
+		t.Errorf("Expected registered key to be redacted, was %q.", got)
+	}
+	if got != "failed to send to R0****CD: unauthorized" {
+		t.Errorf("Unexpected redaction %q.", got)
+	}
+
+	SetRedaction(false)
+	defer SetRedaction(true)
+	if got := RedactSecrets(key); got != key {
+		t.Errorf("Expected key to be unmasked with redaction disabled, was %q.", got)
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	if got := MaskSecret("11863b592c824bfc8989d9cba76abcde"); got != "11****de" {
+		t.Errorf("Unexpected mask %q.", got)
+	}
+	if got := MaskSecret("short"); got != "****" {
+		t.Errorf("Expected short secrets to be fully masked, was %q.", got)
+	}
+
+	SetRedaction(false)
+	defer SetRedaction(true)
+	if got := MaskSecret("11863b592c824bfc8989d9cba76abcde"); got != "11863b592c824bfc8989d9cba76abcde" {
+		t.Errorf("Expected secret to be unmasked with redaction disabled, was %q.", got)
+	}
+}
+
+func TestRedactCore(t *testing.T) {
+	key := "3a7f9c15d4e24b0e8f6a1c2d3e4f5a6b"
+	RegisterSecret(key)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(NewRedactCore(core)).Sugar()
+
+	logger.With("payload", `{"routing_key":"`+key+`"}`).Infof("Sending to %v.", key)
+	logger.Errorw("Failed to send event.", "error", errors.New("invalid routing key "+key), "key", key)
+	logger.Error(errors.New("rejected " + key))
+
+	if logs.Len() != 3 {
+		t.Fatalf("Expected 3 log entries, was %v.", logs.Len())
+	}
+
+	for _, entry := range logs.All() {
+		if strings.Contains(entry.Message, key) {
+			t.Errorf("Expected key to be redacted from message %q.", entry.Message)
+		}
+		for k, v := range entry.ContextMap() {
+			if s, ok := v.(string); ok && strings.Contains(s, key) {
+				t.Errorf("Expected key to be redacted from field %v, was %q.", k, s)
+			}
+		}
+	}
+}
+
+func TestRedactCoreStructured(t *testing.T) {
+	key := "5c1e8a3f7b2d4e6a9f0b1c2d3e4f5a6b"
+	RegisterSecret(key)
+
+	type event struct {
+		RoutingKey string `json:"routing_key"`
+		Summary    string `json:"summary"`
+	}
+	object := zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("routing_key", key)
+		return nil
+	})
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(NewRedactCore(core))
+
+	logger.Info("Sending event.", zap.Any("event", event{RoutingKey: key, Summary: "Disk full"}))
+	logger.Info("Sending event.", zap.Reflect("events", []event{{RoutingKey: key}}))
+	logger.Info("Sending event.", zap.Object("event", object))
+	logger.With(zap.Strings("keys", []string{key})).Info("Sending event.")
+
+	if logs.Len() != 4 {
+		t.Fatalf("Expected 4 log entries, was %v.", logs.Len())
+	}
+
+	for _, entry := range logs.All() {
+		fields, err := json.Marshal(entry.ContextMap())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(fields), key) {
+			t.Errorf("Expected key to be redacted from fields, was %s.", fields)
+		}
+		if !strings.Contains(string(fields), MaskSecret(key)) {
+			t.Errorf("Expected fields to keep the masked key, was %s.", fields)
+		}
+	}
+
+	if summary := logs.All()[0].ContextMap()["event"]; !strings.Contains(fmt.Sprintf("%s", summary), `"summary":"Disk full"`) {
+		t.Errorf("Expected the rest of the struct to be logged, was %s.", summary)
+	}
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// RetryGate is a shared "don't send before" deadline, letting a single 429's
+// `Retry-After` pause every request sharing the gate rather than just the one
+// that was rate limited.
+//
+// The zero value is an open gate.
+type RetryGate struct {
+	// Clock times the wait for the deadline, defaulting to the system time.
+	Clock Clock
+
+	deadline int64
+}
+
+// NewRetryGate returns an open RetryGate.
+func NewRetryGate() *RetryGate {
+	return &RetryGate{}
+}
+
+// Until returns the time before which requests are held, zero if open.
+func (g *RetryGate) Until() time.Time {
+	deadline := atomic.LoadInt64(&g.deadline)
+	if deadline == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, deadline)
+}
+
+// DeferUntil holds requests until `t`, unless already held for longer.
+func (g *RetryGate) DeferUntil(t time.Time) {
+	deadline := t.UnixNano()
+	for {
+		current := atomic.LoadInt64(&g.deadline)
+		if current >= deadline {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&g.deadline, current, deadline) {
+			return
+		}
+	}
+}
+
+// Wait blocks until the gate's deadline passes or the context is done.
+//
+// The deadline is rechecked after waking, as it may have been extended by
+// another request in the meantime.
+func (g *RetryGate) Wait(ctx context.Context) error {
+	for {
+		delay := g.Until().Sub(g.clock().Now())
+		if delay <= 0 {
+			return nil
+		}
+
+		select {
+		case <-g.clock().After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (g *RetryGate) clock() Clock {
+	if g.Clock == nil {
+		return RealClock{}
+	}
+	return g.Clock
+}
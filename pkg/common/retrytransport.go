@@ -2,14 +2,17 @@ package common
 
 import (
 	"math"
+	"math/rand"
 	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/net/http2"
 )
 
+const defaultBaseInterval = time.Second
 const defaultMaxInterval = 30 * time.Second
 const defaultMaxRetries = 10
 
@@ -17,7 +20,12 @@ const defaultMaxRetries = 10
 //
 // Default cases are when a 429 or 500-series error is encountered, with
 // an exponential backoff determined by `Backoff` and a maximum retry count
-// of `MaxRetries`.
+// of `MaxRetries`. A 429's `Retry-After` header takes precedence over
+// `Backoff`, capped at `MaxInterval`. These retries all happen within a single
+// send, so a queue retrying failed sends multiplies them by its own attempts.
+//
+// The `Retry-After` delay also closes `Gate`, holding every request through
+// the transport (or any other sharing the gate) until it passes.
 //
 // Example basic usage:
 //
@@ -28,21 +36,30 @@ const defaultMaxRetries = 10
 //     client.Get("https://www.pagerduty.com")
 //
 type RetryTransport struct {
-	MaxRetries  int
-	MaxInterval time.Duration
-	Transport   http.RoundTripper
-	Backoff     func(int, time.Duration) time.Duration
-	IsRetryable func(*http.Response, error) bool
-	IsSuccess   func(*http.Response, error) bool
+	MaxRetries   int
+	BaseInterval time.Duration
+	MaxInterval  time.Duration
+	Transport    http.RoundTripper
+	Backoff      func(int, time.Duration, time.Duration) time.Duration
+	IsRetryable  func(*http.Response, error) bool
+	IsSuccess    func(*http.Response, error) bool
+	Gate         *RetryGate
+
+	// Clock times backoffs, defaulting to the system time. It should match
+	// the `Gate`'s.
+	Clock Clock
 
 	log *zap.SugaredLogger
 }
 
 func NewRetryTransport() RetryTransport {
 	return RetryTransport{
-		MaxRetries:  defaultMaxRetries,
-		MaxInterval: defaultMaxInterval,
-		Transport:   http.DefaultTransport,
+		MaxRetries:   defaultMaxRetries,
+		BaseInterval: defaultBaseInterval,
+		MaxInterval:  defaultMaxInterval,
+		Transport:    http.DefaultTransport,
+		Gate:         NewRetryGate(),
+		Clock:        RealClock{},
 
 		Backoff:     calculateBackoff,
 		IsRetryable: isRetryable,
@@ -58,24 +75,57 @@ func (r RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	ctx := req.Context()
 
 	for tries := 0; tries < r.MaxRetries; tries++ {
+		if r.Gate != nil {
+			if err := r.Gate.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		// The previous try read the body, so each retry sends a fresh copy.
+		if tries > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
 		resp, err = r.Transport.RoundTrip(req)
 
 		if r.IsSuccess(resp, err) {
-			r.log.Debugf("Successful or non-retryable response.")
+			r.log.Debugw("Successful response.", LogFieldAttempt, tries+1, LogFieldHTTPStatus, resp.StatusCode)
 			return resp, err
 		} else if !r.IsRetryable(resp, err) {
 			if resp != nil {
-				r.log.Errorf("Non-retryable response: %v", resp.Status)
+				r.log.Errorw("Non-retryable response.", LogFieldAttempt, tries+1, LogFieldHTTPStatus, resp.StatusCode)
 				return resp, nil
 			}
 
-			r.log.Errorf("Non-retryable error: %v", err)
+			r.log.Errorw("Non-retryable error.", LogFieldAttempt, tries+1, "error", err)
 			return nil, err
 		}
 
-		backoff := r.Backoff(tries, r.MaxInterval)
-		sleep := time.After(backoff)
-		r.log.Infof("Retrying job, attempt %v, delay %v", tries+1, backoff)
+		// The last response is returned as is, rather than waited out.
+		if tries+1 >= r.MaxRetries {
+			break
+		}
+
+		now := r.clock().Now()
+		backoff, ok := RetryAfter(resp, r.MaxInterval, now)
+		if ok && r.Gate != nil {
+			r.Gate.DeferUntil(now.Add(backoff))
+		} else if !ok {
+			backoff = r.Backoff(tries, r.BaseInterval, r.MaxInterval)
+		}
+		sleep := r.clock().After(backoff)
+		r.log.Infow("Retrying request.", LogFieldAttempt, tries+1, LogFieldHTTPStatus, statusCode(resp), "delay", backoff.String(), "error", err)
+
+		// The retried response is discarded, so it's closed to free its
+		// connection.
+		if resp != nil {
+			resp.Body.Close()
+		}
 
 		select {
 		case <-sleep:
@@ -83,31 +133,77 @@ func (r RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		case <-ctx.Done():
 			// The underlying `Transport` should also handle this, but our
 			// handling breaks us out of sleep.
-			err = ctx.Err()
-			break
+			return nil, ctx.Err()
 		}
 	}
 
 	// If we exhaust our retries, return the last response and error received.
 	if resp != nil {
-		r.log.Errorf("Exhausted retries, status was: %v", resp.StatusCode)
+		r.log.Errorw("Exhausted retries.", LogFieldAttempt, r.MaxRetries, LogFieldHTTPStatus, resp.StatusCode)
 		return resp, nil
 	}
 
-	r.log.Errorf("Exhausted retries, error was: %v", err)
+	r.log.Errorw("Exhausted retries.", LogFieldAttempt, r.MaxRetries, "error", err)
 	return nil, err
 }
 
-// calculateBackoff returns an exponential duration based on the try count.
+func (r RetryTransport) clock() Clock {
+	if r.Clock == nil {
+		return RealClock{}
+	}
+	return r.Clock
+}
+
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// calculateBackoff returns a fully jittered exponential duration based on the
+// try count.
 //
-// Currently back-off looks like: 1s, 2s, 4s, 8s, 16s, then capping at
-// MaxRetryTimeout.
-func calculateBackoff(try int, maxInterval time.Duration) time.Duration {
-	duration := time.Duration(math.Pow(2, float64(try))) * time.Second
-	if duration > maxInterval {
-		duration = maxInterval
+// The window doubles with each try (with a 1s base: 1s, 2s, 4s, 8s, ...) and
+// caps at `maxInterval`, with the returned duration chosen uniformly between
+// zero and the window so that agents don't retry in lockstep.
+func calculateBackoff(try int, baseInterval, maxInterval time.Duration) time.Duration {
+	window := time.Duration(math.Pow(2, float64(try))) * baseInterval
+	if window > maxInterval || window <= 0 {
+		window = maxInterval
+	}
+	return time.Duration(rand.Int63n(int64(window) + 1))
+}
+
+// RetryAfter returns the delay requested by a 429's `Retry-After` header,
+// capped at `maxInterval`, if one was provided. An HTTP-date is taken relative
+// to `now`.
+func RetryAfter(resp *http.Response, maxInterval time.Duration, now time.Time) (time.Duration, bool) {
+	if resp == nil || resp.StatusCode != 429 {
+		return 0, false
+	}
+
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		delay = date.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxInterval {
+		delay = maxInterval
 	}
-	return duration
+	return delay, true
 }
 
 // isRetryable returns true if the corresponding request failed but can be
@@ -2,7 +2,11 @@ package common
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,7 +30,7 @@ func TestRetryTransportSuccess(t *testing.T) {
 
 	transport := NewRetryTransport()
 	transport.Transport = gock.NewTransport()
-	transport.Backoff = func(_ int, _ time.Duration) time.Duration { return time.Millisecond }
+	transport.Backoff = func(_ int, _, _ time.Duration) time.Duration { return time.Millisecond }
 
 	client := &http.Client{
 		Transport: transport,
@@ -54,7 +58,7 @@ func TestRetryTransportLimited(t *testing.T) {
 
 	transport := NewRetryTransport()
 	transport.Transport = gock.NewTransport()
-	transport.Backoff = func(_ int, _ time.Duration) time.Duration { return time.Millisecond }
+	transport.Backoff = func(_ int, _, _ time.Duration) time.Duration { return time.Millisecond }
 
 	client := &http.Client{
 		Transport: transport,
@@ -70,3 +74,308 @@ func TestRetryTransportLimited(t *testing.T) {
 		t.Errorf("Expected a too-many-requests response, response was %+v.", resp)
 	}
 }
+
+func TestRetryTransportNonRetryable(t *testing.T) {
+	defer gock.Off()
+
+	// Respond once with a 400, which shouldn't be retried.
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Reply(400)
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Reply(200)
+
+	transport := NewRetryTransport()
+	transport.Transport = gock.NewTransport()
+	transport.Backoff = func(_ int, _, _ time.Duration) time.Duration { return time.Millisecond }
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}
+
+	resp, err := client.Post("https://events.pagerduty.com/test", "application/json", bytes.NewBuffer([]byte("Hello")))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected a bad request response, response was %+v.", resp)
+	}
+}
+
+func TestRetryTransportBaseInterval(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Reply(429)
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Reply(200)
+
+	transport := NewRetryTransport()
+	transport.Transport = gock.NewTransport()
+	transport.BaseInterval = 100 * time.Millisecond
+	transport.MaxInterval = 10 * time.Second
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}
+
+	start := time.Now()
+	resp, err := client.Post("https://events.pagerduty.com/test", "application/json", bytes.NewBuffer([]byte("Hello")))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected a success response, response was %+v.", resp)
+	}
+
+	// The first retry waits up to the base interval, not the default 1s.
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Expected to wait at most %v before retrying, waited %v.", transport.BaseInterval, elapsed)
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Reply(429).
+		SetHeader("Retry-After", "1")
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Reply(200)
+
+	transport := NewRetryTransport()
+	transport.Transport = gock.NewTransport()
+	transport.Backoff = func(_ int, _, _ time.Duration) time.Duration { return time.Millisecond }
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}
+
+	start := time.Now()
+	resp, err := client.Post("https://events.pagerduty.com/test", "application/json", bytes.NewBuffer([]byte("Hello")))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected a success response, response was %+v.", resp)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected to honor Retry-After of 1s, waited %v.", elapsed)
+	}
+}
+
+func TestRetryTransportRetryAfterSharedGate(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Reply(429).
+		SetHeader("Retry-After", "2")
+
+	gock.New("https://events.pagerduty.com").
+		Post("/test").
+		Reply(200)
+
+	gock.New("https://events.pagerduty.com").
+		Post("/other").
+		Reply(200)
+
+	transport := NewRetryTransport()
+	transport.Transport = gock.NewTransport()
+	transport.Backoff = func(_ int, _, _ time.Duration) time.Duration { return time.Millisecond }
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}
+
+	limited := make(chan error)
+	go func() {
+		_, err := client.Post("https://events.pagerduty.com/test", "application/json", bytes.NewBuffer([]byte("Hello")))
+		limited <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for transport.Gate.Until().IsZero() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if transport.Gate.Until().IsZero() {
+		t.Fatal("Expected a 429's Retry-After to close the shared gate.")
+	}
+
+	start := time.Now()
+	resp, err := client.Post("https://events.pagerduty.com/other", "application/json", bytes.NewBuffer([]byte("Hello")))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected a success response, response was %+v.", resp)
+	}
+
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Errorf("Expected other requests to be deferred by the shared Retry-After of 2s, waited %v.", elapsed)
+	}
+
+	if err := <-limited; err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+// closeTracker is a response body recording whether it was closed.
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+// recordingTransport fails each request with a 500 until the last of
+// `responses`, recording the request bodies it receives.
+type recordingTransport struct {
+	bodies    []string
+	responses []*closeTracker
+	attempts  int
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.bodies = append(r.bodies, string(body))
+
+	status := 500
+	if r.attempts++; r.attempts == len(r.responses) {
+		status = 200
+	}
+	return &http.Response{StatusCode: status, Body: r.responses[r.attempts-1], Request: req}, nil
+}
+
+func TestRetryTransportResendsBody(t *testing.T) {
+	recorder := &recordingTransport{}
+	for i := 0; i < 3; i++ {
+		recorder.responses = append(recorder.responses, &closeTracker{Reader: strings.NewReader("")})
+	}
+
+	transport := NewRetryTransport()
+	transport.Transport = recorder
+	transport.Backoff = func(_ int, _, _ time.Duration) time.Duration { return time.Millisecond }
+
+	client := &http.Client{Transport: transport}
+	resp, err := client.Post("https://events.pagerduty.com/test", "application/json", bytes.NewBuffer([]byte("Hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected a success response, response was %+v.", resp)
+	}
+
+	for i, body := range recorder.bodies {
+		if body != "Hello" {
+			t.Errorf("Expected try %v to send the whole body, sent %q.", i+1, body)
+		}
+	}
+	for i, retried := range recorder.responses[:2] {
+		if !retried.closed {
+			t.Errorf("Expected retried response %v to be closed.", i+1)
+		}
+	}
+	if recorder.responses[2].closed {
+		t.Error("Expected the returned response to be left open.")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	withHeader := func(status int, header string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if header != "" {
+			resp.Header.Set("Retry-After", header)
+		}
+		return resp
+	}
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	date := now.Add(10 * time.Second).Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		resp    *http.Response
+		min     time.Duration
+		max     time.Duration
+		present bool
+	}{
+		{"seconds", withHeader(429, "2"), 2 * time.Second, 2 * time.Second, true},
+		{"httpDate", withHeader(429, date), 10 * time.Second, 10 * time.Second, true},
+		{"capped", withHeader(429, "120"), 30 * time.Second, 30 * time.Second, true},
+		{"pastDate", withHeader(429, "Mon, 01 Jun 2020 11:00:00 GMT"), 0, 0, true},
+		{"invalid", withHeader(429, "soon"), 0, 0, false},
+		{"missing", withHeader(429, ""), 0, 0, false},
+		{"notRateLimited", withHeader(503, "2"), 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		delay, ok := RetryAfter(tt.resp, defaultMaxInterval, now)
+		if ok != tt.present {
+			t.Errorf("%v: expected present %v, was %v", tt.name, tt.present, ok)
+		}
+		if delay < tt.min || delay > tt.max {
+			t.Errorf("%v: expected delay between %v and %v, was %v", tt.name, tt.min, tt.max, delay)
+		}
+	}
+}
+
+func TestRetryGateDeferUntil(t *testing.T) {
+	gate := NewRetryGate()
+	if !gate.Until().IsZero() {
+		t.Error("Expected a new gate to be open.")
+	}
+
+	later := time.Now().Add(time.Minute)
+	gate.DeferUntil(later)
+	gate.DeferUntil(time.Now().Add(time.Second))
+
+	if !gate.Until().Equal(later) {
+		t.Errorf("Expected a shorter deferral not to override the gate, was %v.", gate.Until())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := gate.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected wait to be interrupted by the context, got %v.", err)
+	}
+}
+
+func TestCalculateBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := time.Second
+
+	for try := 0; try < 10; try++ {
+		window := base << uint(try)
+		if window > max {
+			window = max
+		}
+
+		backoff := calculateBackoff(try, base, max)
+		if backoff < 0 || backoff > window {
+			t.Errorf("Expected backoff for try %v to be between 0 and %v, was %v.", try, window, backoff)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries a request body's signature, see `SignBody`.
+const SignatureHeader = "X-Agent-Signature"
+
+// DefaultSignatureSkew is how far a signature's timestamp may be from the
+// verifier's clock, limiting how long a captured request can be replayed.
+const DefaultSignatureSkew = 5 * time.Minute
+
+var (
+	ErrSignatureMissing = errors.New("request signature missing")
+	ErrSignatureInvalid = errors.New("request signature invalid")
+	ErrSignatureExpired = errors.New("request signature timestamp outside the allowed clock skew")
+)
+
+// SignBody returns the `SignatureHeader` value signing `body` at `now`, of
+// the form `t=<unix seconds>,v1=<hex HMAC-SHA256>`.
+//
+// The HMAC is keyed by `secret` over the timestamp, a period, and the body,
+// so neither can be changed without invalidating it.
+func SignBody(secret string, body []byte, now time.Time) string {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	return fmt.Sprintf("t=%v,v1=%v", timestamp, hex.EncodeToString(signature(secret, timestamp, body)))
+}
+
+// VerifySignature checks a `SignatureHeader` value against `body`, returning
+// an error if it's missing, doesn't match, or was signed more than `skew`
+// away from `now`.
+func VerifySignature(secret, header string, body []byte, now time.Time, skew time.Duration) error {
+	if header == "" {
+		return ErrSignatureMissing
+	}
+
+	var timestamp, sig string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return ErrSignatureInvalid
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			sig = kv[1]
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	decoded, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(decoded, signature(secret, timestamp, body)) {
+		return ErrSignatureInvalid
+	}
+
+	// Only checked once the timestamp is known to be authentic.
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-skew)) || signedAt.After(now.Add(skew)) {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+func signature(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "signing-secret"
+	body := []byte(`{"event_action":"trigger"}`)
+	now := time.Unix(1600000000, 0)
+	valid := SignBody(secret, body, now)
+
+	tests := []struct {
+		name     string
+		secret   string
+		header   string
+		body     []byte
+		now      time.Time
+		expected error
+	}{
+		{"valid", secret, valid, body, now, nil},
+		{"withinSkew", secret, valid, body, now.Add(4 * time.Minute), nil},
+		{"missing", secret, "", body, now, ErrSignatureMissing},
+		{"tamperedBody", secret, valid, []byte(`{"event_action":"resolve"}`), now, ErrSignatureInvalid},
+		{"tamperedTimestamp", secret, "t=1600000100" + valid[len("t=1600000000"):], body, now, ErrSignatureInvalid},
+		{"wrongSecret", "other-secret", valid, body, now, ErrSignatureInvalid},
+		{"malformed", secret, "v1", body, now, ErrSignatureInvalid},
+		{"stale", secret, valid, body, now.Add(6 * time.Minute), ErrSignatureExpired},
+		{"future", secret, valid, body, now.Add(-6 * time.Minute), ErrSignatureExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.secret, tt.header, tt.body, tt.now, DefaultSignatureSkew)
+			if err != tt.expected {
+				t.Errorf("Expected %v, got %v.", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// SwapTransport is an `http.RoundTripper` delegating to a transport that may
+// be replaced while in use, e.g. to apply new retry settings on reload.
+//
+// Requests already in progress complete with the transport they started
+// with.
+type SwapTransport struct {
+	transport atomic.Value
+}
+
+// swappedTransport wraps transports so `atomic.Value` always stores the same
+// concrete type.
+type swappedTransport struct {
+	http.RoundTripper
+}
+
+func NewSwapTransport(transport http.RoundTripper) *SwapTransport {
+	s := &SwapTransport{}
+	s.Swap(transport)
+	return s
+}
+
+// Swap replaces the transport used for subsequent requests.
+func (s *SwapTransport) Swap(transport http.RoundTripper) {
+	s.transport.Store(swappedTransport{transport})
+}
+
+// Implementing the `http.RoundTripper` interface.
+func (s *SwapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return s.transport.Load().(swappedTransport).RoundTrip(req)
+}
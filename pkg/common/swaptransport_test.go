@@ -0,0 +1,38 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+)
+
+// statusTransport responds to every request with `status`.
+type statusTransport struct {
+	status int
+}
+
+func (s statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: s.status, Body: http.NoBody, Request: req}, nil
+}
+
+func TestSwapTransport(t *testing.T) {
+	transport := NewSwapTransport(statusTransport{202})
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get("https://events.pagerduty.com/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 202 {
+		t.Errorf("Expected the original transport's 202, was %v.", resp.StatusCode)
+	}
+
+	transport.Swap(statusTransport{204})
+
+	resp, err = client.Get("https://events.pagerduty.com/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 204 {
+		t.Errorf("Expected the swapped transport's 204, was %v.", resp.StatusCode)
+	}
+}
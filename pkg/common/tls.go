@@ -0,0 +1,73 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig holds optional files customizing TLS for outgoing requests, e.g.
+// when traffic passes through a TLS-terminating gateway requiring mutual TLS.
+type TLSConfig struct {
+	// CACertFile is a PEM bundle of CAs trusted in addition to the system's.
+	CACertFile string
+
+	// ClientCertFile and ClientKeyFile are a PEM keypair presented to servers
+	// requesting a client certificate. Both must be set together.
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// InsecureSkipVerify disables verification of server certificates, only
+	// intended for testing against e.g. a mock PagerDuty with a self-signed
+	// certificate.
+	InsecureSkipVerify bool
+}
+
+// Build returns a `tls.Config` for the configured files, or nil if none are
+// set so that Go's defaults apply.
+func (c TLSConfig) Build() (*tls.Config, error) {
+	if c.CACertFile == "" && c.ClientCertFile == "" && c.ClientKeyFile == "" && !c.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if c.InsecureSkipVerify {
+		Logger.Warn("TLS certificate verification is DISABLED for outgoing requests. This is insecure and only intended for testing, never enable it in production.")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	if c.CACertFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := ioutil.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA certificate file: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("unable to parse CA certificate file %v: no PEM certificates found", c.CACertFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		if c.ClientCertFile == "" || c.ClientKeyFile == "" {
+			return nil, errors.New("client certificate and key files must be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate %v: %v", c.ClientCertFile, err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,184 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSConfigCACertFile(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("OK"))
+	}))
+	defer ts.Close()
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+	if err := tlsGet(ts.URL, TLSConfig{}); err == nil {
+		t.Error("Expected self-signed server not to be trusted without a CA file.")
+	}
+
+	if err := tlsGet(ts.URL, TLSConfig{CACertFile: caFile}); err != nil {
+		t.Errorf("Expected self-signed server to be trusted with CA file, got %v.", err)
+	}
+}
+
+func TestTLSConfigInsecureSkipVerify(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("OK"))
+	}))
+	defer ts.Close()
+
+	if err := tlsGet(ts.URL, TLSConfig{}); err == nil {
+		t.Error("Expected self-signed server not to be trusted by default.")
+	}
+
+	if err := tlsGet(ts.URL, TLSConfig{InsecureSkipVerify: true}); err != nil {
+		t.Errorf("Expected self-signed server to be accepted when skipping verification, got %v.", err)
+	}
+}
+
+func TestTLSConfigClientCert(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	certDER, key := generateCert(t)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", certDER)
+	keyFile := writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+
+	clientCert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("OK"))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+	if err := tlsGet(ts.URL, TLSConfig{CACertFile: caFile}); err == nil {
+		t.Error("Expected request without a client certificate to be rejected.")
+	}
+
+	config := TLSConfig{CACertFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile}
+	if err := tlsGet(ts.URL, config); err != nil {
+		t.Errorf("Expected request with a client certificate to succeed, got %v.", err)
+	}
+}
+
+func TestTLSConfigInvalid(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	garbage := path.Join(dir, "garbage.pem")
+	if err := ioutil.WriteFile(garbage, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		config   TLSConfig
+		expected string
+	}{
+		{"missingCAFile", TLSConfig{CACertFile: path.Join(dir, "missing.pem")}, "unable to read CA certificate file"},
+		{"unparseableCAFile", TLSConfig{CACertFile: garbage}, "unable to parse CA certificate file"},
+		{"missingClientKey", TLSConfig{ClientCertFile: garbage}, "client certificate and key files must be set together"},
+		{"unparseableClientCert", TLSConfig{ClientCertFile: garbage, ClientKeyFile: garbage}, "unable to load client certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.config.Build()
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v.", tt.expected, err)
+			}
+		})
+	}
+}
+
+func tlsGet(url string, config TLSConfig) error {
+	tlsConfig, err := config.Build()
+	if err != nil {
+		return err
+	}
+
+	transport, err := NewTransport("")
+	if err != nil {
+		return err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	resp, err := (&http.Client{Transport: transport, Timeout: 5 * time.Second}).Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "go-pdagent-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	file := path.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// generateCert returns a self-signed client certificate and its key.
+func generateCert(t *testing.T) ([]byte, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pdagent"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der, key
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NewTransport returns a copy of `http.DefaultTransport` configured to use an
+// explicit proxy.
+//
+// When `proxyURL` is empty the standard HTTP_PROXY, HTTPS_PROXY, and NO_PROXY
+// environment variables are used instead. Either way NO_PROXY is honored and
+// requests to localhost, such as those from the CLI to the agent server, are
+// never proxied.
+func NewTransport(proxyURL string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL == "" {
+		transport.Proxy = http.ProxyFromEnvironment
+		return transport, nil
+	}
+
+	if _, err := url.Parse(proxyURL); err != nil {
+		return nil, err
+	}
+
+	proxyConfig := httpproxy.Config{
+		HTTPProxy:  proxyURL,
+		HTTPSProxy: proxyURL,
+		NoProxy:    getEnvAny("NO_PROXY", "no_proxy"),
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	return transport, nil
+}
+
+// ConnPoolConfig tunes how many idle connections a transport keeps alive for
+// reuse, sparing busy hosts a new connection and TLS handshake per event.
+type ConnPoolConfig struct {
+	// MaxIdleConns limits idle connections across all hosts, 0 for no limit.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost limits idle connections kept for each host, e.g.
+	// events.pagerduty.com. Go's default of 2 is low for heavy senders.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept before closing,
+	// 0 for no limit.
+	IdleConnTimeout time.Duration
+}
+
+// Apply configures a transport's idle connection pool.
+func (c ConnPoolConfig) Apply(transport *http.Transport) {
+	transport.MaxIdleConns = c.MaxIdleConns
+	transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = c.IdleConnTimeout
+}
+
+func getEnvAny(names ...string) string {
+	for _, n := range names {
+		if val := os.Getenv(n); val != "" {
+			return val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewTransportProxyURL(t *testing.T) {
+	transport, err := NewTransport("http://proxy.example.com:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("POST", "https://events.pagerduty.com/v2/enqueue", nil)
+	proxy, err := transport.Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proxy == nil || proxy.String() != "http://proxy.example.com:3128" {
+		t.Errorf("Expected request to be proxied through configured URL, was %v.", proxy)
+	}
+}
+
+func TestNewTransportLocalhostNotProxied(t *testing.T) {
+	transport, err := NewTransport("http://proxy.example.com:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:49463/send", nil)
+	proxy, err := transport.Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proxy != nil {
+		t.Errorf("Expected local server requests not to be proxied, was %v.", proxy)
+	}
+}
+
+func TestNewTransportNoProxy(t *testing.T) {
+	old := os.Getenv("NO_PROXY")
+	os.Setenv("NO_PROXY", "events.pagerduty.com")
+	defer os.Setenv("NO_PROXY", old)
+
+	transport, err := NewTransport("http://proxy.example.com:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("POST", "https://events.pagerduty.com/v2/enqueue", nil)
+	proxy, err := transport.Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proxy != nil {
+		t.Errorf("Expected NO_PROXY host not to be proxied, was %v.", proxy)
+	}
+}
+
+func TestConnPoolConfigApply(t *testing.T) {
+	transport, err := NewTransport("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ConnPoolConfig{
+		MaxIdleConns:        50,
+		MaxIdleConnsPerHost: 20,
+		IdleConnTimeout:     time.Minute,
+	}.Apply(transport)
+
+	if transport.MaxIdleConns != 50 {
+		t.Errorf("Expected MaxIdleConns of 50, was %v.", transport.MaxIdleConns)
+	}
+	if transport.MaxIdleConnsPerHost != 20 {
+		t.Errorf("Expected MaxIdleConnsPerHost of 20, was %v.", transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != time.Minute {
+		t.Errorf("Expected IdleConnTimeout of %v, was %v.", time.Minute, transport.IdleConnTimeout)
+	}
+}
+
+// Reports `conns/op`, the new connections opened per request, which should be
+// close to 0 with keep-alive and 1 without.
+func BenchmarkTransportKeepAlive(b *testing.B) {
+	for _, keepAlive := range []bool{true, false} {
+		b.Run(fmt.Sprintf("keepalive=%v", keepAlive), func(b *testing.B) {
+			var conns int64
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+			ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt64(&conns, 1)
+				}
+			}
+			ts.Start()
+			defer ts.Close()
+
+			transport, err := NewTransport("")
+			if err != nil {
+				b.Fatal(err)
+			}
+			ConnPoolConfig{MaxIdleConns: 100, MaxIdleConnsPerHost: 10, IdleConnTimeout: time.Minute}.Apply(transport)
+			transport.DisableKeepAlives = !keepAlive
+			defer transport.CloseIdleConnections()
+
+			client := &http.Client{Transport: transport}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resp, err := client.Post(ts.URL, "application/json", nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(atomic.LoadInt64(&conns))/float64(b.N), "conns/op")
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package eventqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// Circuit breaker states, as reported by `CircuitBreaker.State`.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// breakerPollInterval is how often blocked sends recheck an open breaker.
+const breakerPollInterval = 100 * time.Millisecond
+
+// CircuitBreaker stops sends to PagerDuty after `threshold` consecutive
+// failures, e.g. during an outage, rather than retrying each event in turn.
+//
+// Once `cooldown` has elapsed the breaker half-opens, allowing a single probe
+// through. A successful probe closes the breaker, while a failed one reopens
+// it for another cooldown. Events remain queued while the breaker is open.
+type CircuitBreaker struct {
+	// Clock times the cooldown, defaulting to the system time.
+	Clock common.Clock
+
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Clock:     common.RealClock{},
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// State returns the current breaker state, accounting for an elapsed cooldown.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkCooldown()
+	return b.state
+}
+
+// Record the outcome of a send allowed through by the breaker.
+func (b *CircuitBreaker) Record(failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if !failure {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.Clock.Now()
+	}
+}
+
+// allow returns true if a send may proceed, claiming the single probe when
+// half-open.
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkCooldown()
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return false
+	}
+}
+
+// checkCooldown half-opens the breaker once its cooldown has elapsed. Callers
+// must hold `mu`.
+func (b *CircuitBreaker) checkCooldown() {
+	if b.state == BreakerOpen && b.Clock.Now().Sub(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+	}
+}
+
+// wait blocks until the breaker allows a send, returning nil, or until `ctx`
+// is done or `stop` is closed, returning an error wrapping
+// `context.Canceled`.
+func (b *CircuitBreaker) wait(ctx context.Context, stop <-chan bool) error {
+	for !b.allow() {
+		select {
+		case <-b.Clock.After(breakerPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stop:
+			return context.Canceled
+		}
+	}
+	return nil
+}
+
+// IsSendFailure returns true if a response indicates PagerDuty is unreachable,
+// i.e. a `RetryableError` from a transport error, rate limit, or server error.
+// Other errors, such as an invalid or unsent event, say nothing about
+// PagerDuty's health.
+func IsSendFailure(resp Response) bool {
+	var retryable *eventsapi.RetryableError
+	return errors.As(resp.Error, &retryable) && retryable.StatusCode/100 != 2
+}
@@ -9,6 +9,10 @@ var ErrAPIError = errors.New("An API error was encountered while processing even
 
 var ErrJobStopped = errors.New("Job stopped while retrying.")
 
+// ErrWorkerStalled is the cause of a `RetryableError` responding to a send
+// the watchdog cancelled after its worker stalled.
+var ErrWorkerStalled = errors.New("worker stalled, send cancelled")
+
 type ErrBufferOverflow struct {
 	key  string
 	size int
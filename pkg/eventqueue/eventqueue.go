@@ -1,7 +1,9 @@
 package eventqueue
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
@@ -10,6 +12,20 @@ import (
 
 const DefaultBufferSize = 1000
 
+// DefaultBatchSize and DefaultMaxConcurrentSends process events strictly
+// serially, one at a time.
+const (
+	DefaultBatchSize          = 1
+	DefaultMaxConcurrentSends = 1
+)
+
+// DefaultPerKeyRateLimit disables rate limiting, while DefaultPerKeyBurst
+// allows a single send at a time once a rate limit is set.
+const (
+	DefaultPerKeyRateLimit = 0
+	DefaultPerKeyBurst     = 1
+)
+
 // EventQueues are a basic thread-safe queue for processing PagerDuty events.
 //
 // Each EventQueue is internally composed of several individual queues
@@ -22,6 +38,26 @@ const DefaultBufferSize = 1000
 // EventQueues also have a configurable, synchronous processor. By default this
 // processor sends events to PagerDuty's events API..
 //
+// Waiting events are sent highest priority first, see
+// `eventsapi.EventPriority`, with those of equal priority in the order they
+// were enqueued.
+//
+// Workers may optionally pull up to `BatchSize` ready events at a time and
+// process them with up to `MaxConcurrentSends` concurrent processors. Events
+// sharing a dedup (or incident) key are always processed in order, so e.g. a
+// resolve never races ahead of its trigger.
+//
+// Sends may also be limited to `PerKeyRateLimit` events per second for each
+// routing key, allowing bursts of up to `PerKeyBurst`. Events over the limit
+// wait in their routing key's queue; other routing keys are unaffected.
+//
+// An optional `Breaker` pauses sends across all routing keys after repeated
+// failures, leaving events in their queues until PagerDuty recovers.
+//
+// Should a worker have a send in progress for `WorkerStallTimeout` without any
+// other starting or finishing, e.g. stuck on a socket, a watchdog cancels its
+// sends and restarts it. Zero disables the watchdog.
+//
 // Example usage:
 //
 //     queue := eventqueue.NewEventQueue()
@@ -52,13 +88,28 @@ const DefaultBufferSize = 1000
 //     // When you're done with the queue.
 //     queue.Shutdown()
 type EventQueue struct {
-	Processor Processor
+	Processor          Processor
+	BatchSize          int
+	MaxConcurrentSends int
+	PerKeyRateLimit    float64
+	PerKeyBurst        int
+	Breaker            *CircuitBreaker
+	WorkerStallTimeout time.Duration
 
-	logger *zap.SugaredLogger
-	mu     sync.Mutex
-	queues map[string]chan Job
-	stop   chan bool
-	wg     sync.WaitGroup
+	// Clock times retries and rate limits, defaulting to the system time.
+	Clock common.Clock
+
+	ctx          context.Context
+	cancel       context.CancelFunc
+	logger       *zap.SugaredLogger
+	mu           sync.Mutex
+	queues       map[string]chan Job
+	shutdown     chan bool
+	stalls       int32
+	stop         chan bool
+	watchdogOnce sync.Once
+	wg           sync.WaitGroup
+	workers      map[string]*keyWorker
 }
 
 // NewEventQueue initializes a new default EventQueue.
@@ -66,11 +117,22 @@ func NewEventQueue() *EventQueue {
 	logger := common.Logger.Named("EventQueue")
 	logger.Info("Creating new EventQueue.")
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &EventQueue{
-		Processor: DefaultProcessor,
-		logger:    logger,
-		queues:    make(map[string]chan Job),
-		stop:      make(chan bool),
+		ctx:                ctx,
+		cancel:             cancel,
+		Processor:          DefaultProcessor,
+		BatchSize:          DefaultBatchSize,
+		MaxConcurrentSends: DefaultMaxConcurrentSends,
+		PerKeyBurst:        DefaultPerKeyBurst,
+		WorkerStallTimeout: DefaultWorkerStallTimeout,
+		Clock:              common.RealClock{},
+		logger:             logger,
+		queues:             make(map[string]chan Job),
+		shutdown:           make(chan bool),
+		stop:               make(chan bool),
+		workers:            make(map[string]*keyWorker),
 	}
 }
 
@@ -83,11 +145,23 @@ func (q *EventQueue) Shutdown() {
 	for _, w := range q.queues {
 		close(w)
 	}
+	// Sends held by the breaker are released rather than waiting out its
+	// cooldown, while the rest drain as usual before stopping.
+	close(q.shutdown)
 	q.wg.Wait()
 	close(q.stop)
+	q.cancel()
 	q.logger.Info("Shut down EventQueue.")
 }
 
+// Cancel in-flight and queued sends, e.g. once a shutdown grace period has
+// elapsed. Cancelled events respond with an error wrapping
+// `context.Canceled`.
+func (q *EventQueue) Cancel() {
+	q.logger.Info("Cancelling in-flight events.")
+	q.cancel()
+}
+
 // Enqueue a PagerDuty event for processing.
 //
 // Accepts an event and a channel over which to communicate responses. Errors
@@ -109,7 +183,7 @@ func (q *EventQueue) Enqueue(eventContainer *eventsapi.EventContainer, respChan
 	q.ensureWorker(key)
 
 	select {
-	case q.queues[key] <- Job{eventContainer, respChan, q.logger.Named(key)}:
+	case q.queues[key] <- Job{eventContainer, respChan, q.workerLogger(key), dedupKey(event), eventsapi.EventPriority(eventContainer, event), q.ctx, q.Clock}:
 		return nil
 	default:
 		respChan <- Response{Error: &ErrBufferOverflow{key, DefaultBufferSize}}
@@ -126,27 +200,296 @@ func (q *EventQueue) ensureWorker(key string) {
 	}
 
 	c := make(chan Job, DefaultBufferSize)
+	w := &keyWorker{key: key, c: c, limiter: q.newLimiter(), open: true, run: newWorkerRun(q.ctx, q.Clock.Now())}
+
 	q.wg.Add(1)
-	go q.worker(key, c)
+	go q.worker(w, w.run)
 	q.queues[key] = c
+	q.workers[key] = w
+
+	if q.WorkerStallTimeout > 0 {
+		q.watchdogOnce.Do(func() { go q.watchdog() })
+	}
+}
+
+// newLimiter returns a routing key's rate limiter, or nil if unlimited.
+// Callers must hold `mu`.
+func (q *EventQueue) newLimiter() *common.TokenBucket {
+	if q.PerKeyRateLimit <= 0 {
+		return nil
+	}
+	return common.NewTokenBucketWithClock(q.PerKeyRateLimit, q.PerKeyBurst, q.Clock)
+}
+
+// SetPerKeyRateLimit changes the per routing key rate limit, e.g. on reload,
+// including for routing keys already being sent. A zero rate disables it.
+func (q *EventQueue) SetPerKeyRateLimit(rate float64, burst int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.PerKeyRateLimit = rate
+	q.PerKeyBurst = burst
+	for _, w := range q.workers {
+		limiter := q.newLimiter()
+		w.mu.Lock()
+		w.limiter = limiter
+		w.mu.Unlock()
+	}
 }
 
-func (q *EventQueue) worker(key string, c <-chan Job) {
-	defer q.wg.Done()
-	logger := q.logger.Named(key)
+func (q *EventQueue) worker(w *keyWorker, run *workerRun) {
+	defer func() {
+		// A replaced run's place in the wait group was taken by its
+		// replacement.
+		w.mu.Lock()
+		current := w.run == run
+		w.mu.Unlock()
+		if current {
+			q.wg.Done()
+		}
+	}()
+	logger := q.workerLogger(w.key)
 
 	logger.Infof("Worker started.")
-	for job := range c {
-		logger.Infof("Job started, %v pending.", len(c))
-		q.Processor(job, q.stop)
+	for {
+		batch, pending, ok := q.takeBatch(w, run)
+		if !ok {
+			break
+		}
+		logger.Infof("Batch of %v jobs started, %v pending.", len(batch), pending)
+		q.processBatch(w, run, batch)
 	}
 	logger.Infof("Worker stopped.")
 }
 
+// takeBatch waits for a job then takes the next batch from those ready,
+// returning it and how many jobs remain pending.
+//
+// Returns false once the channel is closed and drained, or should the run
+// have been replaced after stalling.
+func (q *EventQueue) takeBatch(w *keyWorker, run *workerRun) ([]Job, int, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.run != run {
+		return nil, 0, false
+	}
+	if len(w.ready) == 0 && w.open {
+		// Without a send in progress the run can't be replaced meanwhile.
+		w.mu.Unlock()
+		job, ok := <-w.c
+		w.mu.Lock()
+		if ok {
+			w.ready = append(w.ready, job)
+		} else {
+			w.open = false
+		}
+	}
+	if len(w.ready) == 0 {
+		return nil, 0, false
+	}
+	w.ready, w.open = q.receiveReady(w.ready, w.c, w.open)
+
+	var batch []Job
+	batch, w.ready = q.nextBatch(w.ready)
+	return batch, len(w.ready) + len(w.c), true
+}
+
+// receiveReady adds jobs already waiting on the channel to those ready to
+// process without blocking, so they can be ordered by priority. At most
+// `DefaultBufferSize` jobs are held, leaving any others on the channel.
+//
+// Returns whether the channel is still open.
+func (q *EventQueue) receiveReady(ready []Job, c <-chan Job, open bool) ([]Job, bool) {
+	for open && len(ready) < DefaultBufferSize {
+		select {
+		case job, ok := <-c:
+			if !ok {
+				return ready, false
+			}
+			ready = append(ready, job)
+		default:
+			return ready, open
+		}
+	}
+	return ready, open
+}
+
+// nextBatch removes up to `BatchSize` jobs from those ready, highest priority
+// first, returning the batch and the jobs remaining.
+func (q *EventQueue) nextBatch(ready []Job) ([]Job, []Job) {
+	var batch []Job
+	for len(batch) < q.BatchSize && len(ready) > 0 {
+		i := nextJob(ready)
+		batch = append(batch, ready[i])
+		ready = append(ready[:i], ready[i+1:]...)
+	}
+	return batch, ready
+}
+
+// nextJob returns the index of the ready job to process next: the earliest of
+// the highest priority, unless an earlier job shares its dedup key, in which
+// case that one goes first so events for an incident stay in order.
+func nextJob(ready []Job) int {
+	next := 0
+	for i := range ready {
+		if ready[i].Priority > ready[next].Priority {
+			next = i
+		}
+	}
+
+	if key := ready[next].DedupKey; key != "" {
+		for i := 0; i < next; i++ {
+			if ready[i].DedupKey == key {
+				return i
+			}
+		}
+	}
+	return next
+}
+
+// processBatch runs the processor over a batch, returning once every job is
+// complete.
+//
+// Jobs are grouped by dedup key, with each group processed serially and
+// groups processed concurrently up to `MaxConcurrentSends`.
+func (q *EventQueue) processBatch(w *keyWorker, run *workerRun, batch []Job) {
+	if len(batch) == 1 || q.MaxConcurrentSends <= 1 {
+		for _, job := range batch {
+			q.process(w, run, job)
+		}
+		return
+	}
+
+	var groups [][]Job
+	groupIndex := make(map[string]int)
+	for _, job := range batch {
+		// Without a dedup key each event is independent of the others.
+		if job.DedupKey == "" {
+			groups = append(groups, []Job{job})
+			continue
+		}
+
+		i, ok := groupIndex[job.DedupKey]
+		if !ok {
+			i = len(groups)
+			groupIndex[job.DedupKey] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], job)
+	}
+
+	sem := make(chan struct{}, q.MaxConcurrentSends)
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(group []Job) {
+			defer wg.Done()
+			for _, job := range group {
+				q.process(w, run, job)
+			}
+			<-sem
+		}(group)
+	}
+	wg.Wait()
+}
+
+// process runs the processor over a single job, first waiting on the routing
+// key's rate limiter if there is one.
+//
+// The job's send is cancelled along with the worker run, e.g. should it
+// stall.
+func (q *EventQueue) process(w *keyWorker, run *workerRun, job Job) {
+	w.mu.Lock()
+	limiter := w.limiter
+	w.mu.Unlock()
+	job.Context = run.ctx
+	if limiter != nil {
+		if err := limiter.Wait(job.Context, q.stop); err != nil {
+			job.ResponseChan <- Response{Error: q.cancelledError(run, err)}
+			return
+		}
+	}
+	if q.Breaker == nil {
+		q.send(w, run, job)
+		return
+	}
+
+	// Jobs held by the breaker at shutdown are left unsent, as if cancelled.
+	if err := q.Breaker.wait(job.Context, q.shutdown); err != nil {
+		job.ResponseChan <- Response{Error: q.cancelledError(run, err)}
+		return
+	}
+
+	respChan := job.ResponseChan
+	intercepted := make(chan Response, 1)
+	job.ResponseChan = intercepted
+	q.send(w, run, job)
+
+	// Processors respond synchronously, so any response is already buffered.
+	select {
+	case resp := <-intercepted:
+		q.Breaker.Record(IsSendFailure(resp))
+		respChan <- resp
+	default:
+	}
+}
+
+// send runs the processor over a job, recording the worker run's progress.
+//
+// A send cancelled by the watchdog responds with a retryable
+// `ErrWorkerStalled`, unlike one cancelled along with the queue.
+func (q *EventQueue) send(w *keyWorker, run *workerRun, job Job) {
+	respChan := job.ResponseChan
+	intercepted := make(chan Response, 1)
+	job.ResponseChan = intercepted
+
+	w.sendStarted(run, q.Clock.Now())
+	q.Processor(job, q.stop)
+	w.sendFinished(run, q.Clock.Now())
+
+	select {
+	case resp := <-intercepted:
+		if resp.Error != nil {
+			resp.Error = q.cancelledError(run, resp.Error)
+		}
+		respChan <- resp
+	default:
+	}
+}
+
+// dedupKey returns the key PagerDuty uses to correlate an event with others,
+// i.e. the V2 dedup key or V1 incident key.
+func dedupKey(event eventsapi.Event) string {
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		return e.IncidentKey
+	case *eventsapi.EventV2:
+		return e.DedupKey
+	default:
+		return ""
+	}
+}
+
+func (q *EventQueue) workerLogger(key string) *zap.SugaredLogger {
+	return q.logger.With(common.LogFieldRoutingKey, common.MaskSecret(key))
+}
+
 type Job struct {
 	EventContainer *eventsapi.EventContainer
 	ResponseChan   chan<- Response
 	Logger         *zap.SugaredLogger
+	DedupKey       string
+
+	// Priority orders the job against others waiting for its routing key.
+	Priority eventsapi.Priority
+
+	// Context cancels the job's send, defaulting to never.
+	Context context.Context
+
+	// Clock times the job's retries, defaulting to the system time.
+	Clock common.Clock
 }
 
 type Response struct {
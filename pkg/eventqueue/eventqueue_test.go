@@ -1,12 +1,20 @@
 package eventqueue
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/PagerDuty/go-pdagent/test"
+	"gopkg.in/h2non/gock.v1"
 )
 
 func TestEventQueueSimple(t *testing.T) {
@@ -42,6 +50,32 @@ func TestEventQueueSimple(t *testing.T) {
 	}
 }
 
+func TestEventQueueCancel(t *testing.T) {
+	transport := &blockingTransport{started: make(chan struct{}, 1)}
+
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+	eq.Processor = NewEventProcessor(eventsapi.WithHTTPClient(&http.Client{Transport: transport}))
+
+	respChan := make(chan Response)
+	event := test.BuildV2EventContainer(common.GenerateKey())
+	if err := eq.Enqueue(&event, respChan); err != nil {
+		t.Fatal(err)
+	}
+
+	<-transport.started
+	eq.Cancel()
+
+	select {
+	case resp := <-respChan:
+		if !errors.Is(resp.Error, context.Canceled) {
+			t.Errorf("Expected a cancelled error, got %v.", resp.Error)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected response for cancelled event, none received.")
+	}
+}
+
 // For this test we enqueue two events in the same queue (by routing key)
 // then add a delay to the first one on the processing side.
 //
@@ -118,3 +152,689 @@ func TestEventQueueMultiOrdering(t *testing.T) {
 		t.Error("Expected first event, but instead out of order..")
 	}
 }
+
+// For this test we enqueue interleaved events for several dedup keys in the
+// same queue, then process them in batches with random delays.
+//
+// The expectation is events sharing a dedup key are still processed in the
+// order they were enqueued, despite being processed concurrently.
+func TestEventQueueBatchDedupKeyOrdering(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+	eq.BatchSize = 50
+	eq.MaxConcurrentSends = 10
+
+	key := common.GenerateKey()
+	dedupKeys := []string{"a", "b", "c", "d", "e"}
+	eventsPerDedupKey := 20
+
+	var events []*eventsapi.EventContainer
+	sequence := make(map[*eventsapi.EventContainer]int)
+	for i := 0; i < eventsPerDedupKey; i++ {
+		for _, dedupKey := range dedupKeys {
+			event := buildV2EventContainerWithDedupKey(key, dedupKey)
+			events = append(events, &event)
+			sequence[&event] = i
+		}
+	}
+
+	var mu sync.Mutex
+	lastSequence := make(map[string]int)
+	maxConcurrent, concurrent := 0, 0
+
+	processor := func(job Job, _ chan bool) {
+		mu.Lock()
+		concurrent++
+		if concurrent > maxConcurrent {
+			maxConcurrent = concurrent
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+
+		mu.Lock()
+		concurrent--
+		seq := sequence[job.EventContainer]
+		if last, ok := lastSequence[job.DedupKey]; ok && seq != last+1 {
+			t.Errorf("Expected %v event %v to follow %v, but instead out of order.", job.DedupKey, seq, last)
+		}
+		lastSequence[job.DedupKey] = seq
+		mu.Unlock()
+
+		job.ResponseChan <- Response{}
+	}
+	eq.Processor = processor
+
+	respChan := make(chan Response, len(events))
+	for _, event := range events {
+		if err := eq.Enqueue(event, respChan); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range events {
+		<-respChan
+	}
+
+	if maxConcurrent > eq.MaxConcurrentSends {
+		t.Errorf("Expected at most %v concurrent sends, was %v.", eq.MaxConcurrentSends, maxConcurrent)
+	}
+	for _, dedupKey := range dedupKeys {
+		if lastSequence[dedupKey] != eventsPerDedupKey-1 {
+			t.Errorf("Expected all %v events to be processed, last was %v.", dedupKey, lastSequence[dedupKey])
+		}
+	}
+}
+
+// For this test we hold up the first event, enqueue a backlog of low priority
+// events then a critical one behind it.
+//
+// The expectation is the critical event is processed first once the backlog
+// is released, followed by the rest in the order they were enqueued.
+func TestEventQueuePriority(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+
+	key := common.GenerateKey()
+	first := test.BuildV2EventContainer(key)
+	var backlog []*eventsapi.EventContainer
+	for i := 0; i < 5; i++ {
+		event := test.BuildV2EventContainer(key)
+		event.Priority = eventsapi.PriorityLow
+		backlog = append(backlog, &event)
+	}
+	critical := buildV2EventContainerWithSeverity(key, "", "critical")
+
+	release := make(chan struct{})
+	var receivedEvents []*eventsapi.EventContainer
+	processor := func(job Job, _ chan bool) {
+		if job.EventContainer == &first {
+			<-release
+		}
+		receivedEvents = append(receivedEvents, job.EventContainer)
+		job.ResponseChan <- Response{}
+	}
+	eq.Processor = processor
+
+	respChan := make(chan Response, len(backlog)+2)
+	_ = eq.Enqueue(&first, respChan)
+	time.Sleep(50 * time.Millisecond)
+	for _, event := range backlog {
+		_ = eq.Enqueue(event, respChan)
+	}
+	_ = eq.Enqueue(&critical, respChan)
+	close(release)
+	for i := 0; i < len(backlog)+2; i++ {
+		<-respChan
+	}
+
+	expected := append([]*eventsapi.EventContainer{&first, &critical}, backlog...)
+	for i := range expected {
+		if receivedEvents[i] != expected[i] {
+			t.Errorf("Expected event %v to be %p, but instead was %p.", i, expected[i], receivedEvents[i])
+		}
+	}
+}
+
+// A higher priority event shouldn't jump ahead of earlier events sharing its
+// dedup key, such as a resolve ahead of its trigger.
+func TestEventQueuePriorityDedupKeyOrdering(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+
+	key := common.GenerateKey()
+	first := test.BuildV2EventContainer(key)
+	low := buildV2EventContainerWithSeverity(key, "disk-full", "warning")
+	other := buildV2EventContainerWithSeverity(key, "", "warning")
+	critical := buildV2EventContainerWithSeverity(key, "disk-full", "critical")
+
+	release := make(chan struct{})
+	var receivedEvents []*eventsapi.EventContainer
+	processor := func(job Job, _ chan bool) {
+		if job.EventContainer == &first {
+			<-release
+		}
+		receivedEvents = append(receivedEvents, job.EventContainer)
+		job.ResponseChan <- Response{}
+	}
+	eq.Processor = processor
+
+	respChan := make(chan Response, 4)
+	_ = eq.Enqueue(&first, respChan)
+	time.Sleep(50 * time.Millisecond)
+	_ = eq.Enqueue(&low, respChan)
+	_ = eq.Enqueue(&other, respChan)
+	_ = eq.Enqueue(&critical, respChan)
+	close(release)
+	for i := 0; i < 4; i++ {
+		<-respChan
+	}
+
+	expected := []*eventsapi.EventContainer{&first, &low, &critical, &other}
+	for i := range expected {
+		if receivedEvents[i] != expected[i] {
+			t.Errorf("Expected event %v to be %p, but instead was %p.", i, expected[i], receivedEvents[i])
+		}
+	}
+}
+
+// Events for a rate limited routing key should be spaced according to the
+// configured rate, while another routing key's events aren't held up behind
+// them.
+func TestEventQueuePerKeyRateLimit(t *testing.T) {
+	eq := NewEventQueue()
+	eq.PerKeyRateLimit = 20
+	eq.PerKeyBurst = 1
+	defer eq.Shutdown()
+
+	hotKey := common.GenerateKey()
+	otherKey := common.GenerateKey()
+	const eventCount = 6
+	interval := time.Second / 20
+
+	var mu sync.Mutex
+	sentAt := make(map[*eventsapi.EventContainer]time.Time)
+	eq.Processor = func(job Job, _ chan bool) {
+		mu.Lock()
+		sentAt[job.EventContainer] = time.Now()
+		mu.Unlock()
+		job.ResponseChan <- Response{}
+	}
+
+	respChan := make(chan Response, eventCount+1)
+	hotEvents := make([]eventsapi.EventContainer, eventCount)
+	for i := range hotEvents {
+		hotEvents[i] = test.BuildV2EventContainer(hotKey)
+		_ = eq.Enqueue(&hotEvents[i], respChan)
+	}
+	otherEvent := test.BuildV2EventContainer(otherKey)
+	_ = eq.Enqueue(&otherEvent, respChan)
+
+	for i := 0; i < eventCount+1; i++ {
+		<-respChan
+	}
+
+	// Allowing some slack for timer imprecision.
+	minGap := interval * 9 / 10
+	for i := 1; i < eventCount; i++ {
+		gap := sentAt[&hotEvents[i]].Sub(sentAt[&hotEvents[i-1]])
+		if gap < minGap {
+			t.Errorf("Expected event %v to be sent at least %v after the previous, was %v.", i, minGap, gap)
+		}
+	}
+
+	if !sentAt[&otherEvent].Before(sentAt[&hotEvents[eventCount-1]]) {
+		t.Error("Expected other routing key's event to be sent without waiting on the rate limited key.")
+	}
+}
+
+func TestEventQueueSetPerKeyRateLimit(t *testing.T) {
+	eq := NewEventQueue()
+	eq.PerKeyRateLimit = 1
+	eq.PerKeyBurst = 1
+	defer eq.Shutdown()
+
+	eq.Processor = func(job Job, _ chan bool) {
+		job.ResponseChan <- Response{}
+	}
+
+	key := common.GenerateKey()
+	respChan := make(chan Response, 1)
+	first := test.BuildV2EventContainer(key)
+	_ = eq.Enqueue(&first, respChan)
+	<-respChan
+
+	// The key's worker is already running with the original limit.
+	eq.SetPerKeyRateLimit(0, 1)
+
+	const eventCount = 3
+	start := time.Now()
+	events := make([]eventsapi.EventContainer, eventCount)
+	for i := range events {
+		events[i] = test.BuildV2EventContainer(key)
+		_ = eq.Enqueue(&events[i], respChan)
+		<-respChan
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected events to be sent without the removed limit, took %v.", elapsed)
+	}
+}
+
+func TestEventQueueCircuitBreaker(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://events.pagerduty.com").
+		Post("/v2/enqueue").
+		Times(2).
+		Reply(500)
+	gock.New("https://events.pagerduty.com").
+		Post("/v2/enqueue").
+		Reply(202).
+		JSON(map[string]string{"status": "success"})
+
+	client := &http.Client{}
+	gock.InterceptClient(client)
+
+	eq := NewEventQueue()
+	eq.Processor = NewEventProcessor(eventsapi.WithHTTPClient(client))
+	eq.Breaker = NewCircuitBreaker(2, 300*time.Millisecond)
+	defer eq.Shutdown()
+
+	respChan := make(chan Response)
+	key := common.GenerateKey()
+	events := make([]eventsapi.EventContainer, 3)
+	for i := range events {
+		events[i] = test.BuildV2EventContainer(key)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := eq.Enqueue(&events[i], respChan); err != nil {
+			t.Fatal(err)
+		}
+		if resp := <-respChan; resp.Error == nil {
+			t.Errorf("Expected event %v to fail.", i)
+		}
+	}
+	if state := eq.Breaker.State(); state != BreakerOpen {
+		t.Fatalf("Expected breaker to be open after consecutive failures, got %v.", state)
+	}
+
+	if err := eq.Enqueue(&events[2], respChan); err != nil {
+		t.Error("Expected enqueue to succeed while the breaker is open.")
+	}
+
+	select {
+	case <-respChan:
+		t.Fatal("Expected event to remain queued while the breaker is open.")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	select {
+	case resp := <-respChan:
+		if resp.Error != nil {
+			t.Errorf("Expected probe to succeed, got %v.", resp.Error)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected probe to be sent after the cooldown.")
+	}
+
+	if state := eq.Breaker.State(); state != BreakerClosed {
+		t.Errorf("Expected breaker to close after a successful probe, got %v.", state)
+	}
+	if !gock.IsDone() {
+		t.Error("Expected all mocked requests to be made.")
+	}
+}
+
+// For this test an event is held by an open breaker when the queue is shut
+// down.
+//
+// The expectation is shutdown isn't held up by the breaker, with the event
+// left unsent as if cancelled.
+func TestEventQueueBreakerShutdown(t *testing.T) {
+	eq := NewEventQueue()
+	eq.Breaker = NewCircuitBreaker(1, time.Hour)
+	eq.Breaker.Record(true)
+	eq.Processor = func(job Job, _ chan bool) {
+		t.Error("Expected no send while the breaker is open.")
+		job.ResponseChan <- Response{}
+	}
+
+	event := test.BuildV2EventContainer(common.GenerateKey())
+	respChan := make(chan Response, 1)
+	_ = eq.Enqueue(&event, respChan)
+
+	shutdown := make(chan struct{})
+	go func() {
+		eq.Shutdown()
+		close(shutdown)
+	}()
+
+	select {
+	case <-shutdown:
+	case <-time.After(time.Second):
+		t.Fatal("Expected shutdown not to wait on the open breaker.")
+	}
+	if resp := <-respChan; !errors.Is(resp.Error, context.Canceled) {
+		t.Errorf("Expected the held event to be cancelled, got %v.", resp.Error)
+	}
+}
+
+// For this test events are still queued when the queue shuts down.
+//
+// The expectation is they're sent as usual before shutdown completes, without
+// `stop` cutting their retries short.
+func TestEventQueueShutdownDrains(t *testing.T) {
+	eq := NewEventQueue()
+	eq.Breaker = NewCircuitBreaker(5, time.Hour)
+	eq.Processor = func(job Job, stop chan bool) {
+		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-stop:
+			job.ResponseChan <- Response{Error: ErrJobStopped}
+		default:
+			job.ResponseChan <- Response{}
+		}
+	}
+
+	key := common.GenerateKey()
+	respChans := make([]chan Response, 5)
+	for i := range respChans {
+		event := test.BuildV2EventContainer(key)
+		respChans[i] = make(chan Response, 1)
+		_ = eq.Enqueue(&event, respChans[i])
+	}
+	eq.Shutdown()
+
+	for i, respChan := range respChans {
+		select {
+		case resp := <-respChan:
+			if resp.Error != nil {
+				t.Errorf("Expected queued event %v to be sent during shutdown, got %v.", i+1, resp.Error)
+			}
+		default:
+			t.Errorf("Expected queued event %v to be sent before shutdown completed.", i+1)
+		}
+	}
+}
+
+// For this test an event is held by an open breaker when the queue's sends
+// are cancelled.
+func TestEventQueueBreakerCancel(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+	eq.Breaker = NewCircuitBreaker(1, time.Hour)
+	eq.Breaker.Record(true)
+
+	event := test.BuildV2EventContainer(common.GenerateKey())
+	respChan := make(chan Response, 1)
+	_ = eq.Enqueue(&event, respChan)
+	eq.Cancel()
+
+	select {
+	case resp := <-respChan:
+		if !errors.Is(resp.Error, context.Canceled) {
+			t.Errorf("Expected the held event to be cancelled, got %v.", resp.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected cancelling to release the event held by the breaker.")
+	}
+}
+
+// For this test the first send hangs until its context is cancelled, as a
+// stuck socket would without the watchdog.
+//
+// The expectation is the worker is restarted, cancelling the stalled send with
+// a retryable error and sending the next event.
+func TestEventQueueWorkerStall(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+	eq.WorkerStallTimeout = 100 * time.Millisecond
+
+	key := common.GenerateKey()
+	event1 := test.BuildV2EventContainer(key)
+	event2 := test.BuildV2EventContainer(key)
+	respChan1 := make(chan Response, 1)
+	respChan2 := make(chan Response, 1)
+
+	eq.Processor = func(job Job, _ chan bool) {
+		if job.EventContainer == &event1 {
+			<-job.Context.Done()
+			job.ResponseChan <- Response{Error: job.Context.Err()}
+			return
+		}
+		job.ResponseChan <- Response{}
+	}
+
+	_ = eq.Enqueue(&event1, respChan1)
+	_ = eq.Enqueue(&event2, respChan2)
+
+	select {
+	case resp := <-respChan1:
+		var retryable *eventsapi.RetryableError
+		if !errors.As(resp.Error, &retryable) || !errors.Is(resp.Error, ErrWorkerStalled) {
+			t.Errorf("Expected the stalled send to fail with a retryable %v, got %v.", ErrWorkerStalled, resp.Error)
+		}
+		if errors.Is(resp.Error, context.Canceled) {
+			t.Error("Expected the stalled send not to look cancelled by shutdown.")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the stalled send to be cancelled, it wasn't.")
+	}
+
+	select {
+	case resp := <-respChan2:
+		if resp.Error != nil {
+			t.Errorf("Expected the next event to be sent, got %v.", resp.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the restarted worker to send the next event, it didn't.")
+	}
+
+	if stalls := eq.Stalls(); stalls != 1 {
+		t.Errorf("Expected 1 stall, got %v.", stalls)
+	}
+}
+
+// For this test two events are sent concurrently through a half-open breaker,
+// so one is the probe and stalls while the other is held by the breaker.
+//
+// The expectation is both are cancelled with a retryable error when the worker
+// is restarted, rather than the held one looking cancelled by shutdown.
+func TestEventQueueWorkerStallBreakerOpen(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+	eq.WorkerStallTimeout = 100 * time.Millisecond
+	eq.BatchSize = 2
+	eq.MaxConcurrentSends = 2
+	eq.Breaker = NewCircuitBreaker(1, 0)
+
+	key := common.GenerateKey()
+	gate := test.BuildV2EventContainer(key)
+	event1 := test.BuildV2EventContainer(key)
+	event2 := test.BuildV2EventContainer(key)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	// The first event fails, opening the breaker, once the others are queued
+	// behind it so they're taken as a single batch.
+	eq.Processor = func(job Job, _ chan bool) {
+		if job.EventContainer == &gate {
+			close(started)
+			<-release
+			job.ResponseChan <- Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+			return
+		}
+		<-job.Context.Done()
+		job.ResponseChan <- Response{Error: job.Context.Err()}
+	}
+
+	_ = eq.Enqueue(&gate, make(chan Response, 1))
+	<-started
+	respChans := []chan Response{make(chan Response, 1), make(chan Response, 1)}
+	_ = eq.Enqueue(&event1, respChans[0])
+	_ = eq.Enqueue(&event2, respChans[1])
+	close(release)
+
+	for i, respChan := range respChans {
+		select {
+		case resp := <-respChan:
+			var retryable *eventsapi.RetryableError
+			if !errors.As(resp.Error, &retryable) || !errors.Is(resp.Error, ErrWorkerStalled) {
+				t.Errorf("Expected event %v to fail with a retryable %v, got %v.", i+1, ErrWorkerStalled, resp.Error)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected event %v to be cancelled by the stall, it wasn't.", i+1)
+		}
+	}
+}
+
+// For this test a slow but progressing worker shouldn't be restarted.
+func TestEventQueueWorkerNoStall(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+	eq.WorkerStallTimeout = 100 * time.Millisecond
+
+	key := common.GenerateKey()
+	eq.Processor = func(job Job, _ chan bool) {
+		time.Sleep(50 * time.Millisecond)
+		job.ResponseChan <- Response{}
+	}
+
+	respChan := make(chan Response, 5)
+	for i := 0; i < 5; i++ {
+		event := test.BuildV2EventContainer(key)
+		_ = eq.Enqueue(&event, respChan)
+	}
+	for i := 0; i < 5; i++ {
+		if resp := <-respChan; resp.Error != nil {
+			t.Errorf("Expected event to be sent, got %v.", resp.Error)
+		}
+	}
+
+	if stalls := eq.Stalls(); stalls != 0 {
+		t.Errorf("Expected no stalls, got %v.", stalls)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	clock := test.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := NewCircuitBreaker(1, time.Minute)
+	b.Clock = clock
+
+	b.Record(true)
+	if state := b.State(); state != BreakerOpen {
+		t.Fatalf("Expected breaker to be open, got %v.", state)
+	}
+	if b.allow() {
+		t.Error("Expected open breaker to block sends.")
+	}
+
+	clock.Advance(time.Minute - time.Second)
+	if state := b.State(); state != BreakerOpen {
+		t.Fatalf("Expected breaker to stay open during the cooldown, got %v.", state)
+	}
+
+	clock.Advance(time.Second)
+	if state := b.State(); state != BreakerHalfOpen {
+		t.Fatalf("Expected breaker to be half-open after the cooldown, got %v.", state)
+	}
+	if !b.allow() {
+		t.Error("Expected half-open breaker to allow a probe.")
+	}
+	if b.allow() {
+		t.Error("Expected half-open breaker to allow only a single probe.")
+	}
+
+	b.Record(true)
+	if state := b.State(); state != BreakerOpen {
+		t.Fatalf("Expected failed probe to reopen the breaker, got %v.", state)
+	}
+
+	clock.Advance(time.Minute)
+	if !b.allow() {
+		t.Error("Expected half-open breaker to allow a probe.")
+	}
+	b.Record(false)
+	if state := b.State(); state != BreakerClosed {
+		t.Errorf("Expected successful probe to close the breaker, got %v.", state)
+	}
+}
+
+func TestIsSendFailure(t *testing.T) {
+	withStatus := func(code int) Response {
+		resp := &eventsapi.ResponseV2{}
+		resp.SetHTTPResponse(&http.Response{StatusCode: code})
+		if code == http.StatusTooManyRequests || code >= 500 {
+			return Response{Response: resp, Error: &eventsapi.RetryableError{StatusCode: code, Err: eventsapi.ErrAPIError}}
+		}
+		return Response{Response: resp, Error: &eventsapi.TerminalError{StatusCode: code, Err: eventsapi.ErrAPIError}}
+	}
+
+	tests := []struct {
+		name     string
+		resp     Response
+		expected bool
+	}{
+		{"success", Response{Response: &eventsapi.ResponseV2{}}, false},
+		{"transport error", Response{Response: &eventsapi.ResponseV2{}, Error: &eventsapi.RetryableError{Err: errors.New("connection refused")}}, true},
+		{"unsent event", Response{Error: errors.New("unexpected end of JSON input")}, false},
+		{"invalid event", withStatus(400), false},
+		{"rate limited", withStatus(429), true},
+		{"server error", withStatus(503), true},
+	}
+
+	for _, tt := range tests {
+		if actual := IsSendFailure(tt.resp); actual != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func BenchmarkEventQueueSerial(b *testing.B) {
+	benchmarkEventQueue(b, 1, 1)
+}
+
+func BenchmarkEventQueueBatched(b *testing.B) {
+	benchmarkEventQueue(b, 50, 10)
+}
+
+// benchmarkEventQueue sends events with distinct dedup keys through a single
+// routing key's queue, simulating a 1ms round trip per send.
+func benchmarkEventQueue(b *testing.B, batchSize, maxConcurrentSends int) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+	eq.BatchSize = batchSize
+	eq.MaxConcurrentSends = maxConcurrentSends
+	eq.Processor = func(job Job, _ chan bool) {
+		time.Sleep(time.Millisecond)
+		job.ResponseChan <- Response{}
+	}
+
+	key := common.GenerateKey()
+	events := make([]eventsapi.EventContainer, b.N)
+	for i := range events {
+		events[i] = buildV2EventContainerWithDedupKey(key, strconv.Itoa(i))
+	}
+	respChan := make(chan Response, b.N)
+
+	b.ResetTimer()
+
+	// Enqueuing in chunks no larger than the buffer to avoid overflowing.
+	for start := 0; start < len(events); start += DefaultBufferSize {
+		end := start + DefaultBufferSize
+		if end > len(events) {
+			end = len(events)
+		}
+		for i := start; i < end; i++ {
+			_ = eq.Enqueue(&events[i], respChan)
+		}
+		for i := start; i < end; i++ {
+			<-respChan
+		}
+	}
+}
+
+func buildV2EventContainerWithDedupKey(key, dedupKey string) eventsapi.EventContainer {
+	return buildV2EventContainerWithSeverity(key, dedupKey, "Error")
+}
+
+func buildV2EventContainerWithSeverity(key, dedupKey, severity string) eventsapi.EventContainer {
+	eventV2 := eventsapi.EventV2{
+		RoutingKey:  key,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: eventsapi.PayloadV2{
+			Summary:  "Test summary",
+			Source:   "Test source",
+			Severity: severity,
+		},
+	}
+
+	jsonEvent, _ := json.Marshal(eventV2)
+
+	return eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData:    jsonEvent,
+	}
+}
@@ -2,16 +2,28 @@ package eventqueue
 
 import (
 	"context"
+	"errors"
 	"math"
 	"time"
 
+	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/tracing"
 )
 
 var DefaultProcessor = EventProcessor
 
 const MaxRetryTimeout = 30 * time.Second
 
+// MaxResponseRetries is how many times an event is resent after the API
+// accepts the request but replies with an unsuccessful or malformed body.
+// Failed requests are instead retried by the HTTP client's transport.
+const MaxResponseRetries = 3
+
+// responseBackoff returns the delay before resending after an unsuccessful
+// response, replaceable in tests.
+var responseBackoff = calculateBackoff
+
 type Processor func(Job, chan bool)
 
 // EventProcessor is a Job processor for use by an EventQueue specifically
@@ -19,10 +31,55 @@ type Processor func(Job, chan bool)
 //
 // It accepts a Job containing an EventContainer
 func EventProcessor(job Job, stop chan bool) {
-	ctx := context.Background()
-	resp, err := eventsapi.Enqueue(ctx, job.EventContainer)
+	job.ResponseChan <- enqueueWithRetries(job, stop)
+}
+
+// NewEventProcessor returns an EventProcessor that sends events using the
+// provided `eventsapi` options, e.g. a custom HTTP client.
+func NewEventProcessor(options ...eventsapi.EnqueueOption) Processor {
+	return func(job Job, stop chan bool) {
+		job.ResponseChan <- enqueueWithRetries(job, stop, options...)
+	}
+}
+
+// enqueueWithRetries sends a job's event, resending with back-off while the
+// API replies 2xx with a body that isn't successful, until either
+// `MaxResponseRetries` is reached or `stop` is closed.
+//
+// Cancelling the job's context aborts the request, including any retries.
+func enqueueWithRetries(job Job, stop chan bool, options ...eventsapi.EnqueueOption) Response {
+	ctx := job.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// Each attempt's request is traced as a child of the enqueue span, if any.
+	ctx = tracing.ContextWithTraceparent(ctx, job.EventContainer.TraceParent)
+	clock := job.Clock
+	if clock == nil {
+		clock = common.RealClock{}
+	}
+
+	for try := 0; ; try++ {
+		resp, err := eventsapi.Enqueue(ctx, job.EventContainer, options...)
+		if !isUnsuccessfulResponse(resp, err) || try >= MaxResponseRetries {
+			return Response{resp, err}
+		}
+
+		select {
+		case <-clock.After(responseBackoff(try)):
+		case <-stop:
+			return Response{resp, err}
+		case <-ctx.Done():
+			return Response{resp, ctx.Err()}
+		}
+	}
+}
 
-	job.ResponseChan <- Response{resp, err}
+// isUnsuccessfulResponse returns true if the API accepted a request, with a
+// 2xx, but its body doesn't report success.
+func isUnsuccessfulResponse(resp eventsapi.Response, err error) bool {
+	var retryable *eventsapi.RetryableError
+	return errors.As(err, &retryable) && retryable.StatusCode/100 == 2
 }
 
 // calculateBackoff returns an exponential duration based on the try count.
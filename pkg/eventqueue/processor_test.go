@@ -1,6 +1,9 @@
 package eventqueue
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"testing"
 	"time"
 
@@ -103,3 +106,100 @@ func TestEventsV2ProcessorError(t *testing.T) {
 		t.Error("Expected response from processor, none received.")
 	}
 }
+
+func TestEventsV2ProcessorUnsuccessfulResponse(t *testing.T) {
+	defer gock.Off()
+	defer func(backoff func(int) time.Duration) { responseBackoff = backoff }(responseBackoff)
+	responseBackoff = func(int) time.Duration { return 0 }
+
+	// A 200 whose body reports a failure is resent until it succeeds.
+	gock.New("https://events.pagerduty.com").
+		Post("/v2/enqueue").
+		Reply(200).
+		JSON(map[string]interface{}{"status": "failure", "message": "Try again"})
+	gock.New("https://events.pagerduty.com").
+		Post("/v2/enqueue").
+		Reply(202).
+		JSON(map[string]interface{}{"status": "success", "message": "Event processed", "dedup_key": "12345"})
+	gock.InterceptClient(eventsapi.DefaultHTTPClient)
+
+	respChan := make(chan Response, 1)
+	event := test.BuildV2EventContainer(common.GenerateKey())
+	job := Job{EventContainer: &event, ResponseChan: respChan, Logger: common.Logger}
+
+	EventProcessor(job, make(chan bool))
+
+	resp := <-respChan
+	if resp.Error != nil {
+		t.Errorf("Unexpected error after resending: %v", resp.Error)
+	}
+	if dedupKey := resp.Response.(*eventsapi.ResponseV2).DedupKey; dedupKey != "12345" {
+		t.Errorf("Expected dedup key 12345, got %q", dedupKey)
+	}
+	if !gock.IsDone() {
+		t.Error("Expected the event to be resent.")
+	}
+}
+
+func TestEventsV2ProcessorMalformedResponse(t *testing.T) {
+	defer gock.Off()
+	defer func(backoff func(int) time.Duration) { responseBackoff = backoff }(responseBackoff)
+	responseBackoff = func(int) time.Duration { return 0 }
+
+	gock.New("https://events.pagerduty.com").
+		Post("/v2/enqueue").
+		Times(MaxResponseRetries + 1).
+		Reply(200).
+		BodyString("<html>Gateway</html>")
+	gock.InterceptClient(eventsapi.DefaultHTTPClient)
+
+	respChan := make(chan Response, 1)
+	event := test.BuildV2EventContainer(common.GenerateKey())
+	job := Job{EventContainer: &event, ResponseChan: respChan, Logger: common.Logger}
+
+	EventProcessor(job, make(chan bool))
+
+	resp := <-respChan
+	if !errors.Is(resp.Error, eventsapi.ErrMalformedResponse) {
+		t.Errorf("Expected ErrMalformedResponse, got %v", resp.Error)
+	}
+	if !gock.IsDone() {
+		t.Errorf("Expected %v attempts.", MaxResponseRetries+1)
+	}
+}
+
+// blockingTransport holds requests until they're cancelled, signalling
+// `started` as each arrives.
+type blockingTransport struct {
+	started chan struct{}
+}
+
+func (b *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.started <- struct{}{}
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestEventsV2ProcessorCancelled(t *testing.T) {
+	transport := &blockingTransport{started: make(chan struct{}, 1)}
+	processor := NewEventProcessor(eventsapi.WithHTTPClient(&http.Client{Transport: transport}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	respChan := make(chan Response)
+	event := test.BuildV2EventContainer(common.GenerateKey())
+	job := Job{EventContainer: &event, ResponseChan: respChan, Logger: common.Logger, Context: ctx}
+
+	go processor(job, make(chan bool))
+
+	<-transport.started
+	cancel()
+
+	select {
+	case resp := <-respChan:
+		if !errors.Is(resp.Error, context.Canceled) {
+			t.Errorf("Expected a cancelled error, got %v.", resp.Error)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected response from cancelled processor, none received.")
+	}
+}
@@ -0,0 +1,124 @@
+package eventqueue
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// DefaultWorkerStallTimeout allows for a send retried by the HTTP transport
+// at its maximum delay several times over before the worker is restarted.
+const DefaultWorkerStallTimeout = 10 * time.Minute
+
+// keyWorker is a routing key's worker and its ready jobs, which a restarted
+// worker takes over.
+type keyWorker struct {
+	key     string
+	c       chan Job
+	limiter *common.TokenBucket
+
+	mu    sync.Mutex
+	ready []Job
+	open  bool
+	run   *workerRun
+}
+
+// workerRun is a single worker goroutine, cancelled should it stall.
+type workerRun struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	lastProgress time.Time
+	sending      int
+}
+
+func newWorkerRun(parent context.Context, now time.Time) *workerRun {
+	ctx, cancel := context.WithCancel(parent)
+	return &workerRun{ctx: ctx, cancel: cancel, lastProgress: now}
+}
+
+// sendStarted and sendFinished record a run's progress around each send.
+func (w *keyWorker) sendStarted(run *workerRun, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	run.sending++
+	run.lastProgress = now
+}
+
+func (w *keyWorker) sendFinished(run *workerRun, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	run.sending--
+	run.lastProgress = now
+}
+
+// stalled returns true if a run's sends were cancelled by the watchdog,
+// rather than by the queue being cancelled or shut down.
+func (q *EventQueue) stalled(run *workerRun) bool {
+	return run.ctx.Err() != nil && q.ctx.Err() == nil
+}
+
+// cancelledError returns the error a job of `run` responds with when its
+// wait or send fails with `err`: a retryable `ErrWorkerStalled` should the
+// watchdog have cancelled the run, so the event is resent rather than left
+// for the next start.
+func (q *EventQueue) cancelledError(run *workerRun, err error) error {
+	if q.stalled(run) {
+		return &eventsapi.RetryableError{Err: ErrWorkerStalled}
+	}
+	return err
+}
+
+// Stalls returns how many times a stalled worker has been restarted.
+func (q *EventQueue) Stalls() int {
+	return int(atomic.LoadInt32(&q.stalls))
+}
+
+// watchdog restarts stalled workers until the queue shuts down.
+func (q *EventQueue) watchdog() {
+	for {
+		select {
+		case <-q.Clock.After(q.WorkerStallTimeout / 4):
+			q.mu.Lock()
+			workers := make([]*keyWorker, 0, len(q.workers))
+			for _, w := range q.workers {
+				workers = append(workers, w)
+			}
+			q.mu.Unlock()
+
+			for _, w := range workers {
+				q.restartIfStalled(w)
+			}
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// restartIfStalled replaces a worker that's had a send in progress without
+// any other send starting or finishing for `WorkerStallTimeout`.
+//
+// The stalled run's context is cancelled, aborting its sends with
+// `ErrWorkerStalled` so they can be resent, and a new worker continues with
+// the remaining jobs. Should the stalled run ever
+// return it exits without touching them.
+func (q *EventQueue) restartIfStalled(w *keyWorker) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	run := w.run
+	if run.sending == 0 || q.Clock.Now().Sub(run.lastProgress) < q.WorkerStallTimeout {
+		return
+	}
+
+	q.workerLogger(w.key).Warnf("Worker made no progress for %v with a send in progress, restarting it.", q.WorkerStallTimeout)
+	atomic.AddInt32(&q.stalls, 1)
+	run.cancel()
+
+	// The new run takes over the stalled one's place in the wait group.
+	w.run = newWorkerRun(q.ctx, q.Clock.Now())
+	go q.worker(w, w.run)
+}
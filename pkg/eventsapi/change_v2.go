@@ -0,0 +1,70 @@
+package eventsapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+)
+
+const endpointChangeV2 = "/v2/change/enqueue"
+
+// ErrInvalidTimestamp occurs when a change event's timestamp isn't RFC3339.
+var ErrInvalidTimestamp = errors.New("timestamp must be in RFC3339 format")
+
+// ChangeEventV2 corresponds to a V2 change event object.
+//
+// Change events are informational, e.g. deploys or config changes, appearing
+// on service timelines without creating incidents.
+type ChangeEventV2 struct {
+	RoutingKey string          `json:"routing_key"`
+	Payload    ChangePayloadV2 `json:"payload"`
+	Links      []LinkV2        `json:"links,omitempty"`
+}
+
+// ChangePayloadV2 corresponds to a V2 change event payload object.
+type ChangePayloadV2 struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source,omitempty"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+func (e *ChangeEventV2) GetRoutingKey() string {
+	return e.RoutingKey
+}
+
+func (e *ChangeEventV2) Validate() error {
+	if err := validateRoutingKey(e.RoutingKey); err != nil {
+		return err
+	}
+
+	if e.Payload.Timestamp != "" {
+		if _, err := time.Parse(time.RFC3339, e.Payload.Timestamp); err != nil {
+			return ErrInvalidTimestamp
+		}
+	}
+
+	return nil
+}
+
+func (e *ChangeEventV2) Version() EventVersion {
+	return EventVersionChange2
+}
+
+func (e *ChangeEventV2) AddCustomDetail(k string, v interface{}) {
+	if e.Payload.CustomDetails == nil {
+		e.Payload.CustomDetails = map[string]interface{}{}
+	}
+	e.Payload.CustomDetails[k] = v
+}
+
+// EnqueueChangeV2 sends a change event explicitly to the Events API V2.
+func EnqueueChangeV2(context context.Context, client *http.Client, event *ChangeEventV2) (*ResponseV2, error) {
+	var response ResponseV2
+	url := common.PdEventsUrl() + endpointChangeV2
+	err := enqueueEvent(context, client, url, event, &response)
+	return &response, err
+}
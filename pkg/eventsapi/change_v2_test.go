@@ -0,0 +1,114 @@
+package eventsapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestEnqueueChangeV2Success(t *testing.T) {
+	defer gock.Off()
+
+	mockResponse := ResponseV2{
+		Status:  "success",
+		Message: "Change event processed",
+	}
+
+	mockEndpointChangeV2(202, mockResponse)
+
+	event := ChangeEventV2{
+		RoutingKey: "11863b592c824bfc8989d9cba76abcde",
+		Payload: ChangePayloadV2{
+			Summary:   "Deployed v1.2.3",
+			Source:    "ci",
+			Timestamp: "2020-07-17T08:42:58Z",
+		},
+		Links: []LinkV2{{Href: "https://example.com/builds/1", Text: "Build"}},
+	}
+
+	resp, err := EnqueueChangeV2(context.Background(), http.DefaultClient, &event)
+	if err != nil {
+		t.Error("Unexpected error during change event enqueue", err)
+		return
+	}
+
+	if resp.Status != "success" {
+		t.Errorf("Expected status to be \"success\", was \"%v\"", resp.Status)
+	}
+}
+
+func TestChangeEventV2Marshal(t *testing.T) {
+	event := ChangeEventV2{
+		RoutingKey: "11863b592c824bfc8989d9cba76abcde",
+		Payload: ChangePayloadV2{
+			Summary:   "Deployed v1.2.3",
+			Source:    "ci",
+			Timestamp: "2020-07-17T08:42:58Z",
+		},
+		Links: []LinkV2{{Href: "https://example.com/builds/1", Text: "Build"}},
+	}
+
+	body, err := json.Marshal(&event)
+	if err != nil {
+		t.Fatal("Unexpected error marshaling change event", err)
+	}
+
+	expected := `{"routing_key":"11863b592c824bfc8989d9cba76abcde","payload":{"summary":"Deployed v1.2.3","source":"ci","timestamp":"2020-07-17T08:42:58Z"},"links":[{"href":"https://example.com/builds/1","text":"Build"}]}`
+	if string(body) != expected {
+		t.Errorf("Expected body to be %v, was %v", expected, string(body))
+	}
+}
+
+func TestEnqueueChangeV2InvalidEventNotRetried(t *testing.T) {
+	defer gock.Off()
+
+	mockEndpointChangeV2(400, nil)
+
+	client := &http.Client{Transport: common.NewRetryTransport()}
+
+	event := ChangeEventV2{
+		RoutingKey: "11863b592c824bfc8989d9cba76abcde",
+		Payload:    ChangePayloadV2{Summary: "Deployed v1.2.3"},
+	}
+
+	resp, err := EnqueueChangeV2(context.Background(), client, &event)
+	if err == nil {
+		t.Error("Expected error during change event enqueue")
+	}
+
+	if resp.HTTPResponse.StatusCode != 400 {
+		t.Errorf("Expected status code to be 400, was %v", resp.HTTPResponse.StatusCode)
+	}
+
+	if gock.HasUnmatchedRequest() {
+		t.Error("Expected a 400 response not to be retried")
+	}
+}
+
+func TestChangeEventV2ValidateTimestamp(t *testing.T) {
+	event := ChangeEventV2{
+		RoutingKey: "11863b592c824bfc8989d9cba76abcde",
+		Payload: ChangePayloadV2{
+			Summary:   "Deployed v1.2.3",
+			Timestamp: "yesterday",
+		},
+	}
+
+	if err := event.Validate(); err != ErrInvalidTimestamp {
+		t.Errorf("Expected ErrInvalidTimestamp, got %v", err)
+	}
+
+	event.Payload.Timestamp = "2020-07-17T08:42:58.315+0000"
+	if err := event.Validate(); err != ErrInvalidTimestamp {
+		t.Errorf("Expected ErrInvalidTimestamp, got %v", err)
+	}
+
+	event.Payload.Timestamp = "2020-07-17T08:42:58Z"
+	if err := event.Validate(); err != nil {
+		t.Errorf("Unexpected error validating event: %v", err)
+	}
+}
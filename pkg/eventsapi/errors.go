@@ -1,5 +1,64 @@
 package eventsapi
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
 
 var ErrAPIError = errors.New("an API error was encountered while processing events")
+
+// ErrMalformedResponse occurs when the API replies 2xx with a body that can't
+// be parsed.
+var ErrMalformedResponse = errors.New("the API returned a malformed response")
+
+// ErrUnsuccessfulResponse occurs when the API replies 2xx with a body whose
+// status isn't "success".
+var ErrUnsuccessfulResponse = errors.New("the API returned an unsuccessful response")
+
+// RetryableError is a failure that may succeed if the event is sent again,
+// i.e. a network error (with no status code), rate limit, server error, or a
+// 2xx whose body reports a failure.
+type RetryableError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *RetryableError) Error() string {
+	return statusError(e.StatusCode, e.Err)
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// TerminalError is a failure that sending the same event again won't fix,
+// e.g. a 400 for an invalid event or 403 for a disabled routing key. Events
+// rejected before sending, such as those too large, have no status code.
+type TerminalError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *TerminalError) Error() string {
+	return statusError(e.StatusCode, e.Err)
+}
+
+func (e *TerminalError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError classifies a failed response by its HTTP status code.
+func newStatusError(statusCode int, err error) error {
+	if statusCode == http.StatusTooManyRequests || statusCode >= 500 || statusCode/100 == 2 {
+		return &RetryableError{StatusCode: statusCode, Err: err}
+	}
+	return &TerminalError{StatusCode: statusCode, Err: err}
+}
+
+func statusError(statusCode int, err error) string {
+	if statusCode == 0 {
+		return err.Error()
+	}
+	return fmt.Sprintf("%v (HTTP %v)", err, statusCode)
+}
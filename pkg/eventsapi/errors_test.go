@@ -0,0 +1,76 @@
+package eventsapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestEnqueueErrorTypes(t *testing.T) {
+	tests := []struct {
+		name       string
+		mock       func(*gock.Request)
+		statusCode int
+		retryable  bool
+	}{
+		{"invalid event", func(r *gock.Request) { r.Reply(400).JSON(map[string]interface{}{"status": "invalid event"}) }, 400, false},
+		{"unauthorized", func(r *gock.Request) { r.Reply(401) }, 401, false},
+		{"forbidden", func(r *gock.Request) { r.Reply(403) }, 403, false},
+		{"not found", func(r *gock.Request) { r.Reply(404) }, 404, false},
+		{"rate limited", func(r *gock.Request) { r.Reply(429) }, 429, true},
+		{"server error", func(r *gock.Request) { r.Reply(500) }, 500, true},
+		{"unavailable", func(r *gock.Request) { r.Reply(503) }, 503, true},
+		{"malformed response", func(r *gock.Request) { r.Reply(202).BodyString(`{"status": "succ`) }, 202, true},
+		{"network error", func(r *gock.Request) { r.ReplyError(errors.New("connection refused")) }, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+
+			tt.mock(gock.New("https://events.pagerduty.com").Post("/v2/enqueue"))
+			gock.InterceptClient(DefaultHTTPClient)
+
+			event := EventV2{RoutingKey: "11863b592c824bfc8989d9cba76abcde", EventAction: "trigger"}
+			_, err := EnqueueV2(context.Background(), DefaultHTTPClient, &event)
+
+			var retryable *RetryableError
+			var terminal *TerminalError
+
+			switch {
+			case errors.As(err, &retryable):
+				if !tt.retryable {
+					t.Fatalf("Expected TerminalError, got RetryableError %v", err)
+				}
+				if retryable.StatusCode != tt.statusCode {
+					t.Errorf("Expected status code %v, got %v", tt.statusCode, retryable.StatusCode)
+				}
+			case errors.As(err, &terminal):
+				if tt.retryable {
+					t.Fatalf("Expected RetryableError, got TerminalError %v", err)
+				}
+				if terminal.StatusCode != tt.statusCode {
+					t.Errorf("Expected status code %v, got %v", tt.statusCode, terminal.StatusCode)
+				}
+				if !errors.Is(err, ErrAPIError) {
+					t.Errorf("Expected error to wrap ErrAPIError, got %v", err)
+				}
+			default:
+				t.Fatalf("Expected a RetryableError or TerminalError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEnqueueUnrecognizedEventIsTerminal(t *testing.T) {
+	event := EventContainer{EventVersion: "v3", EventData: []byte(`{}`)}
+
+	_, err := Enqueue(context.Background(), &event)
+
+	var terminal *TerminalError
+	if !errors.As(err, &terminal) || !errors.Is(err, ErrUnrecognizedEventType) {
+		t.Errorf("Expected TerminalError wrapping ErrUnrecognizedEventType, got %v", err)
+	}
+}
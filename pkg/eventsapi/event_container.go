@@ -2,11 +2,34 @@ package eventsapi
 
 import (
 	"encoding/json"
+	"time"
 )
 
+// EventContainer holds an encoded event along with how the agent should
+// handle it.
+//
+// TTL, when positive, is how long the event may wait in the agent's queue
+// before expiring unsent, overriding the queue's default.
+//
+// AutoResolveAfter, when positive, has the agent send a resolve this long
+// after a trigger is delivered, e.g. for point-in-time checks.
+//
+// Priority, when set, overrides the priority derived from the event's
+// severity, see `EventPriority`.
+//
+// TraceParent, when tracing, is the W3C `traceparent` of the span enqueuing
+// the event, letting later spans sending it link back to it.
 type EventContainer struct {
-	EventVersion EventVersion
-	EventData    json.RawMessage
+	EventVersion     EventVersion
+	EventData        json.RawMessage
+	TTL              time.Duration `json:",omitempty"`
+	AutoResolveAfter time.Duration `json:",omitempty"`
+	Priority         Priority      `json:",omitempty"`
+	TraceParent      string        `json:",omitempty"`
+
+	// Integration names the integration that sent the event, e.g. "nagios",
+	// identifying it to PagerDuty in the `User-Agent`.
+	Integration string `json:",omitempty"`
 }
 
 func (ec *EventContainer) UnmarshalEvent() (Event, error) {
@@ -19,6 +42,10 @@ func (ec *EventContainer) UnmarshalEvent() (Event, error) {
 		var event EventV2
 		err := json.Unmarshal(ec.EventData, &event)
 		return &event, err
+	case EventVersionChange2:
+		var event ChangeEventV2
+		err := json.Unmarshal(ec.EventData, &event)
+		return &event, err
 	default:
 		return nil, ErrUnrecognizedEventType
 	}
@@ -4,12 +4,14 @@ type EventVersion string
 
 var EventVersion1 EventVersion = "v1"
 var EventVersion2 EventVersion = "v2"
+var EventVersionChange2 EventVersion = "change.v2"
 
 func (ev EventVersion) String() string {
 	return string(ev)
 }
 
 var StringToEventVersion = map[string]EventVersion{
-	"v1": EventVersion1,
-	"v2": EventVersion2,
+	"v1":        EventVersion1,
+	"v2":        EventVersion2,
+	"change.v2": EventVersionChange2,
 }
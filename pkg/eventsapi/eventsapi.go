@@ -29,11 +29,12 @@ type Event interface {
 type Response interface {
 	GetHTTPResponse() *http.Response
 	SetHTTPResponse(*http.Response)
+	Successful() bool
 }
 
 // BaseResponse is a minimal implementation of the `Response` interface.
 type BaseResponse struct {
-	HTTPResponse *http.Response
+	HTTPResponse *http.Response `json:"-"`
 	retryable    bool
 }
 
@@ -46,27 +47,37 @@ func (br *BaseResponse) SetHTTPResponse(resp *http.Response) {
 	br.HTTPResponse = resp
 }
 
+// Successful returns true if the HTTP response was a 2xx. Responses with a
+// body override this to also check the status it reports.
+func (br *BaseResponse) Successful() bool {
+	return common.IsSuccessResponse(br.HTTPResponse, nil)
+}
+
 type enqueueConfig struct {
-	HTTPClient *http.Client
+	HTTPClient      *http.Client
+	MaxPayloadBytes int
 }
 
 var DefaultHTTPClient *http.Client
 
 var defaultEnqueueConfig enqueueConfig
 
-var defaultUserAgent string
-
 func init() {
-	DefaultHTTPClient = &http.Client{
-		Transport: common.NewRetryTransport(),
-		Timeout:   5 * time.Minute,
-	}
+	DefaultHTTPClient = NewHTTPClient(common.NewRetryTransport())
 
 	defaultEnqueueConfig = enqueueConfig{
-		HTTPClient: DefaultHTTPClient,
+		HTTPClient:      DefaultHTTPClient,
+		MaxPayloadBytes: DefaultMaxPayloadBytes,
 	}
+}
 
-	defaultUserAgent = common.UserAgent()
+// NewHTTPClient returns an HTTP client suitable for the events API using the
+// provided transport, normally a `common.RetryTransport`.
+func NewHTTPClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: transport,
+		Timeout:   5 * time.Minute,
+	}
 }
 
 type EnqueueOption func(*enqueueConfig)
@@ -79,7 +90,19 @@ func WithHTTPClient(client *http.Client) EnqueueOption {
 	}
 }
 
+// WithMaxPayloadBytes is an option for use in conjunction with Enqueue
+// overriding the size events are truncated to before sending, or disabling
+// truncation if not positive.
+func WithMaxPayloadBytes(maxBytes int) EnqueueOption {
+	return func(ec *enqueueConfig) {
+		ec.MaxPayloadBytes = maxBytes
+	}
+}
+
 // Enqueue an event to either the V1 or V2 events API depending on event type.
+//
+// Events that can't be decoded or made to fit are returned as a
+// `TerminalError` without being sent.
 func Enqueue(context context.Context, eventContainer *EventContainer, options ...EnqueueOption) (Response, error) {
 	config := defaultEnqueueConfig
 	for _, option := range options {
@@ -88,21 +111,48 @@ func Enqueue(context context.Context, eventContainer *EventContainer, options ..
 
 	event, err := eventContainer.UnmarshalEvent()
 	if err != nil {
-		return nil, err
+		return nil, &TerminalError{Err: err}
+	}
+	if eventContainer.Integration != "" {
+		context = withIntegration(context, eventContainer.Integration)
+	}
+
+	if config.MaxPayloadBytes > 0 {
+		if _, err := Truncate(event, config.MaxPayloadBytes); err != nil {
+			return nil, &TerminalError{Err: err}
+		}
 	}
 
 	switch e := event.(type) {
 	case *EventV1:
 		return CreateV1(context, config.HTTPClient, e)
 	case *EventV2:
-		return EnqueueV2(context, config.HTTPClient, e)
+		return NewV2Client(config.HTTPClient).Enqueue(context, e)
+	case *ChangeEventV2:
+		return EnqueueChangeV2(context, config.HTTPClient, e)
 	default:
-		return nil, ErrUnrecognizedEventType
+		return nil, &TerminalError{Err: ErrUnrecognizedEventType}
 	}
 }
 
+type integrationKey struct{}
+
+// withIntegration names the integration requests made with `ctx` are on
+// behalf of, identifying it in their `User-Agent`.
+func withIntegration(ctx context.Context, integration string) context.Context {
+	return context.WithValue(ctx, integrationKey{}, integration)
+}
+
+func integrationFromContext(ctx context.Context) string {
+	integration, _ := ctx.Value(integrationKey{}).(string)
+	return integration
+}
+
 // enqueueEvent handles common operations around encoding, sending, then
 // receiving and decoding from both the V1 and V2 events APIs.
+//
+// Failures to send or unsuccessful responses are returned as either a
+// `RetryableError` or `TerminalError`.
 func enqueueEvent(context context.Context, client *http.Client, url string, event Event, response Response) error {
 	body, err := json.Marshal(event)
 	if err != nil {
@@ -114,27 +164,35 @@ func enqueueEvent(context context.Context, client *http.Client, url string, even
 		return err
 	}
 
-	req.Header.Add("User-Agent", defaultUserAgent)
+	req.Header.Add("User-Agent", common.UserAgent(integrationFromContext(context)))
 	req = req.WithContext(context)
 
 	httpResp, err := client.Do(req)
 	if err != nil {
-		return err
+		return &RetryableError{Err: err}
 	}
 	defer httpResp.Body.Close()
 	response.SetHTTPResponse(httpResp)
 
 	respBody, err := ioutil.ReadAll(httpResp.Body)
 	if err != nil {
-		return err
+		return &RetryableError{StatusCode: httpResp.StatusCode, Err: err}
 	}
 
-	_ = json.Unmarshal(respBody, &response)
-	if common.IsSuccessResponse(httpResp, err) {
-		return nil
+	// The API occasionally replies 2xx with a body reporting a failure, or
+	// one that can't be parsed at all, so success also depends on the body.
+	err = json.Unmarshal(respBody, &response)
+	if !common.IsSuccessResponse(httpResp, nil) {
+		return newStatusError(httpResp.StatusCode, ErrAPIError)
+	}
+	if err != nil {
+		return newStatusError(httpResp.StatusCode, ErrMalformedResponse)
+	}
+	if !response.Successful() {
+		return newStatusError(httpResp.StatusCode, ErrUnsuccessfulResponse)
 	}
 
-	return ErrAPIError
+	return nil
 }
 
 func validateRoutingKey(routingKey string) error {
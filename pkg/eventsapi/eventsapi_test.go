@@ -2,8 +2,13 @@ package eventsapi
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"regexp"
 	"testing"
 
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/spf13/viper"
 	"gopkg.in/h2non/gock.v1"
 )
 
@@ -98,3 +103,119 @@ func TestCommonEnqueueV1(t *testing.T) {
 		t.Errorf("Expected message to be \"12345\", was \"%v\"", resp.IncidentKey)
 	}
 }
+
+func TestEnqueueUnsuccessfulResponse(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://events.pagerduty.com").
+		Post("/v2/enqueue").
+		Reply(200).
+		JSON(map[string]interface{}{"status": "invalid event", "message": "Event object is invalid"})
+	gock.InterceptClient(DefaultHTTPClient)
+
+	event := EventV2{RoutingKey: "11863b592c824bfc8989d9cba76abcde", EventAction: "trigger"}
+	resp, err := EnqueueV2(context.Background(), DefaultHTTPClient, &event)
+
+	if !errors.Is(err, ErrUnsuccessfulResponse) {
+		t.Errorf("Expected ErrUnsuccessfulResponse, got %v", err)
+	}
+	if resp.Successful() {
+		t.Error("Expected response to be unsuccessful.")
+	}
+	if resp.Status != "invalid event" || resp.Message != "Event object is invalid" {
+		t.Errorf("Expected status and message to be parsed, got %q and %q", resp.Status, resp.Message)
+	}
+}
+
+func TestEnqueueMalformedResponse(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://events.pagerduty.com").
+		Post("/generic/2010-04-15/create_event.json").
+		Reply(200).
+		BodyString(`{"status": "succ`)
+	gock.InterceptClient(DefaultHTTPClient)
+
+	event := EventV1{ServiceKey: "11863b592c824bfc8989d9cba76abcde", EventType: "trigger"}
+	resp, err := CreateV1(context.Background(), DefaultHTTPClient, &event)
+
+	if !errors.Is(err, ErrMalformedResponse) {
+		t.Errorf("Expected ErrMalformedResponse, got %v", err)
+	}
+	if resp.Successful() {
+		t.Error("Expected response to be unsuccessful.")
+	}
+	if resp.GetHTTPResponse().StatusCode != 200 {
+		t.Errorf("Expected HTTP response to be kept, got %v", resp.GetHTTPResponse())
+	}
+}
+
+func TestEnqueueEventsURL(t *testing.T) {
+	defer gock.Off()
+	defer viper.Set("events-url", nil)
+	viper.Set("events-url", "https://gateway.example.com/pagerduty")
+
+	gock.New("https://gateway.example.com").
+		Post("/pagerduty/v2/enqueue").
+		Reply(202).
+		JSON(ResponseV2{Status: "success"})
+	gock.New("https://gateway.example.com").
+		Post("/pagerduty/v2/change/enqueue").
+		Reply(202).
+		JSON(ResponseV2{Status: "success"})
+
+	event := EventV2{
+		RoutingKey:  "11863b592c824bfc8989d9cba76abcde",
+		EventAction: "trigger",
+		Payload:     PayloadV2{Summary: "Test", Source: "pdagent", Severity: "error"},
+	}
+	if _, err := EnqueueV2(context.Background(), http.DefaultClient, &event); err != nil {
+		t.Fatal(err)
+	}
+
+	change := ChangeEventV2{
+		RoutingKey: "11863b592c824bfc8989d9cba76abcde",
+		Payload:    ChangePayloadV2{Summary: "Deployed v1.2.3", Source: "ci"},
+	}
+	if _, err := EnqueueChangeV2(context.Background(), http.DefaultClient, &change); err != nil {
+		t.Fatal(err)
+	}
+
+	if !gock.IsDone() {
+		t.Error("Expected both events to be sent to the overridden host.")
+	}
+}
+
+func TestEnqueueUserAgent(t *testing.T) {
+	defer gock.Off()
+	defer viper.Set("user-agent", nil)
+
+	gock.New("https://events.pagerduty.com").
+		Post("/v2/enqueue").
+		MatchHeader("User-Agent", regexp.QuoteMeta(common.UserAgent("zabbix"))).
+		Reply(202).
+		JSON(ResponseV2{Status: "success"})
+	gock.New("https://events.pagerduty.com").
+		Post("/v2/enqueue").
+		MatchHeader("User-Agent", "^custom-agent/1.0$").
+		Reply(202).
+		JSON(ResponseV2{Status: "success"})
+
+	event := EventContainer{
+		EventVersion: EventVersion2,
+		EventData:    []byte(`{"routing_key":"11863b592c824bfc8989d9cba76abcde","event_action":"trigger","payload":{"summary":"Test","source":"pdagent","severity":"error"}}`),
+		Integration:  "zabbix",
+	}
+	if _, err := Enqueue(context.Background(), &event, WithHTTPClient(http.DefaultClient)); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("user-agent", "custom-agent/1.0")
+	if _, err := Enqueue(context.Background(), &event, WithHTTPClient(http.DefaultClient)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !gock.IsDone() {
+		t.Error("Expected the integration's User-Agent, then the override.")
+	}
+}
@@ -25,3 +25,15 @@ func mockEndpointV2(statusCode int, response interface{}) *gock.Response {
 
 	return mock
 }
+
+func mockEndpointChangeV2(statusCode int, response interface{}) *gock.Response {
+	mock := gock.New("https://events.pagerduty.com").
+		Post("/v2/change/enqueue").
+		Reply(statusCode)
+
+	if response != nil {
+		mock = mock.JSON(response)
+	}
+
+	return mock
+}
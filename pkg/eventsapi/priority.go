@@ -0,0 +1,68 @@
+package eventsapi
+
+import (
+	"errors"
+	"strings"
+)
+
+// Priority orders events waiting to be sent by the agent, with higher
+// priorities sent first. The zero value derives priority from the event.
+type Priority int
+
+const (
+	PriorityLow Priority = iota + 1
+	PriorityNormal
+	PriorityHigh
+)
+
+var priorityNames = map[Priority]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+}
+
+// ErrInvalidPriority occurs when parsing an unrecognized priority.
+var ErrInvalidPriority = errors.New(`priority must be one of: low, normal, high`)
+
+// ParsePriority parses a priority's name, returning the zero `Priority` for
+// an empty name.
+func ParsePriority(name string) (Priority, error) {
+	if name == "" {
+		return 0, nil
+	}
+	for p, n := range priorityNames {
+		if strings.EqualFold(name, n) {
+			return p, nil
+		}
+	}
+	return 0, ErrInvalidPriority
+}
+
+func (p Priority) String() string {
+	return priorityNames[p]
+}
+
+// EventPriority returns the priority an event is sent with, either that set
+// on its container or one derived from its severity.
+//
+// Critical events are high priority while warnings and info are low, so e.g.
+// a host going down is sent ahead of a backlog of warnings. Events without a
+// severity are normal priority.
+func EventPriority(eventContainer *EventContainer, event Event) Priority {
+	if eventContainer.Priority != 0 {
+		return eventContainer.Priority
+	}
+
+	e, ok := event.(*EventV2)
+	if !ok {
+		return PriorityNormal
+	}
+	switch strings.ToLower(e.Payload.Severity) {
+	case "critical":
+		return PriorityHigh
+	case "warning", "info":
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
@@ -0,0 +1,59 @@
+package eventsapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Priority
+		err      error
+	}{
+		{"", 0, nil},
+		{"low", PriorityLow, nil},
+		{"Normal", PriorityNormal, nil},
+		{"HIGH", PriorityHigh, nil},
+		{"urgent", 0, ErrInvalidPriority},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePriority(tt.name)
+		if p != tt.expected || err != tt.err {
+			t.Errorf("Expected %q to parse as %v, %v, was %v, %v.", tt.name, tt.expected, tt.err, p, err)
+		}
+	}
+}
+
+func TestEventPriority(t *testing.T) {
+	v2 := func(severity string) *EventV2 {
+		return &EventV2{EventAction: "trigger", Payload: PayloadV2{Severity: severity}}
+	}
+
+	tests := []struct {
+		name     string
+		priority Priority
+		event    Event
+		expected Priority
+	}{
+		{"critical", 0, v2("critical"), PriorityHigh},
+		{"error", 0, v2("error"), PriorityNormal},
+		{"warning", 0, v2("warning"), PriorityLow},
+		{"info", 0, v2("info"), PriorityLow},
+		{"noSeverity", 0, v2(""), PriorityNormal},
+		{"v1", 0, &EventV1{EventType: "trigger"}, PriorityNormal},
+		{"override", PriorityHigh, v2("info"), PriorityHigh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(tt.event)
+			eventContainer := &EventContainer{EventVersion: tt.event.Version(), EventData: data, Priority: tt.priority}
+
+			if p := EventPriority(eventContainer, tt.event); p != tt.expected {
+				t.Errorf("Expected priority %v, was %v.", tt.expected, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package eventsapi
+
+import (
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+)
+
+// DefaultMaxPayloadBytes is the Events API's maximum event size.
+const DefaultMaxPayloadBytes = 512 * 1024
+
+// TruncationMarker is appended to values shortened to fit an event within the
+// maximum payload size.
+const TruncationMarker = "...[truncated]"
+
+// maxRequiredFieldBytes is the length summaries and descriptions are cut to
+// when truncating custom details alone isn't enough. The Events API shortens
+// summaries beyond this length regardless.
+const maxRequiredFieldBytes = 1024
+
+// ErrPayloadTooLarge occurs when an event exceeds the maximum payload size
+// even after truncation, e.g. because of a very large number of links.
+var ErrPayloadTooLarge = errors.New("event payload exceeds maximum size")
+
+// Truncate shrinks an event in place so its marshaled form fits within
+// `maxBytes`, returning true if anything was truncated.
+//
+// The longest custom detail values are shortened first, with non-string
+// values replaced by their truncated JSON encoding. Should that not suffice,
+// the summary or description is cut to a safe length; neither is ever
+// dropped.
+func Truncate(event Event, maxBytes int) (bool, error) {
+	size, err := payloadSize(event)
+	if err != nil || size <= maxBytes {
+		return false, err
+	}
+
+	details, required := truncatableFields(event)
+
+	exhausted := map[string]bool{}
+	for size > maxBytes {
+		key, val, ok := longestDetail(details, exhausted)
+		if !ok {
+			break
+		}
+
+		if len(val) <= len(TruncationMarker) {
+			exhausted[key] = true
+			continue
+		}
+
+		keep := len(val) - (size - maxBytes) - len(TruncationMarker)
+		if keep <= 0 {
+			keep = 0
+			exhausted[key] = true
+		}
+		details[key] = truncateString(val, keep)
+
+		if size, err = payloadSize(event); err != nil {
+			return true, err
+		}
+	}
+
+	if size > maxBytes && required != nil && len(*required) > maxRequiredFieldBytes {
+		*required = truncateString(*required, maxRequiredFieldBytes-len(TruncationMarker))
+		if size, err = payloadSize(event); err != nil {
+			return true, err
+		}
+	}
+
+	if size > maxBytes {
+		return true, ErrPayloadTooLarge
+	}
+	return true, nil
+}
+
+func payloadSize(event Event) (int, error) {
+	body, err := json.Marshal(event)
+	return len(body), err
+}
+
+// truncatableFields returns an event's custom details along with its required
+// summary or description.
+func truncatableFields(event Event) (map[string]interface{}, *string) {
+	switch e := event.(type) {
+	case *EventV1:
+		return e.Details, &e.Description
+	case *EventV2:
+		return e.Payload.CustomDetails, &e.Payload.Summary
+	case *ChangeEventV2:
+		return e.Payload.CustomDetails, &e.Payload.Summary
+	default:
+		return nil, nil
+	}
+}
+
+// longestDetail returns the custom detail with the longest string form,
+// skipping any too short to shorten further.
+func longestDetail(details map[string]interface{}, exhausted map[string]bool) (string, string, bool) {
+	var longestKey, longestVal string
+	found := false
+
+	for k, v := range details {
+		if exhausted[k] {
+			continue
+		}
+		s := detailString(v)
+		if !found || len(s) > len(longestVal) || (len(s) == len(longestVal) && k < longestKey) {
+			longestKey, longestVal, found = k, s, true
+		}
+	}
+
+	return longestKey, longestVal, found
+}
+
+func detailString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
+
+// truncateString cuts `s` to at most `n` bytes, without splitting a UTF-8
+// character, and appends the truncation marker.
+func truncateString(s string, n int) string {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(s) {
+		n = len(s)
+	}
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + TruncationMarker
+}
@@ -0,0 +1,164 @@
+package eventsapi
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/h2non/gock.v1"
+)
+
+func oversizedEventV2(detailBytes int) *EventV2 {
+	return &EventV2{
+		RoutingKey:  "11863b592c824bfc8989d9cba76abcde",
+		EventAction: "trigger",
+		Payload: PayloadV2{
+			Summary:  "Disk full on web-1",
+			Source:   "web-1",
+			Severity: "critical",
+			CustomDetails: map[string]interface{}{
+				"output":  strings.Repeat("x", detailBytes),
+				"command": "check_disk",
+			},
+		},
+	}
+}
+
+func TestTruncateOversizedDetail(t *testing.T) {
+	maxBytes := 1024
+	event := oversizedEventV2(4096)
+
+	truncated, err := Truncate(event, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated {
+		t.Error("Expected event to be truncated.")
+	}
+
+	size, _ := payloadSize(event)
+	if size > maxBytes {
+		t.Errorf("Expected payload of at most %v bytes, was %v", maxBytes, size)
+	}
+
+	output := event.Payload.CustomDetails["output"].(string)
+	if !strings.HasSuffix(output, TruncationMarker) {
+		t.Errorf("Expected truncated detail to end with marker, was %q", output)
+	}
+	if event.Payload.CustomDetails["command"] != "check_disk" {
+		t.Errorf("Expected short detail to be untouched, was %q", event.Payload.CustomDetails["command"])
+	}
+	if event.Payload.Summary != "Disk full on web-1" {
+		t.Errorf("Expected summary to be untouched, was %q", event.Payload.Summary)
+	}
+}
+
+func TestTruncateUnderLimit(t *testing.T) {
+	event := oversizedEventV2(10)
+
+	truncated, err := Truncate(event, DefaultMaxPayloadBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated {
+		t.Error("Expected event under the limit to be untouched.")
+	}
+	if event.Payload.CustomDetails["output"] != strings.Repeat("x", 10) {
+		t.Errorf("Unexpected detail %q", event.Payload.CustomDetails["output"])
+	}
+}
+
+func TestTruncateNonStringDetail(t *testing.T) {
+	maxBytes := 1024
+	event := oversizedEventV2(0)
+	event.Payload.CustomDetails["lines"] = strings.Split(strings.Repeat("line\n", 1000), "\n")
+
+	if _, err := Truncate(event, maxBytes); err != nil {
+		t.Fatal(err)
+	}
+
+	size, _ := payloadSize(event)
+	if size > maxBytes {
+		t.Errorf("Expected payload of at most %v bytes, was %v", maxBytes, size)
+	}
+
+	lines, ok := event.Payload.CustomDetails["lines"].(string)
+	if !ok || !strings.HasPrefix(lines, `["line","line"`) || !strings.HasSuffix(lines, TruncationMarker) {
+		t.Errorf("Expected truncated JSON encoding of detail, was %v", event.Payload.CustomDetails["lines"])
+	}
+}
+
+func TestTruncateRequiredField(t *testing.T) {
+	maxBytes := 2048
+	event := &EventV1{
+		ServiceKey:  "11863b592c824bfc8989d9cba76abcde",
+		EventType:   "trigger",
+		Description: strings.Repeat("d", 4096),
+	}
+
+	if _, err := Truncate(event, maxBytes); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(event.Description) != maxRequiredFieldBytes {
+		t.Errorf("Expected description of %v bytes, was %v", maxRequiredFieldBytes, len(event.Description))
+	}
+	if !strings.HasSuffix(event.Description, TruncationMarker) {
+		t.Errorf("Expected truncated description to end with marker, was %q", event.Description)
+	}
+}
+
+func TestTruncateTooLarge(t *testing.T) {
+	event := &ChangeEventV2{
+		RoutingKey: "11863b592c824bfc8989d9cba76abcde",
+		Payload:    ChangePayloadV2{Summary: "Deployed"},
+	}
+	for i := 0; i < 100; i++ {
+		event.Links = append(event.Links, LinkV2{Href: "https://example.com/" + strings.Repeat("a", 100)})
+	}
+
+	if _, err := Truncate(event, 1024); err != ErrPayloadTooLarge {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+	if event.Payload.Summary != "Deployed" {
+		t.Errorf("Expected summary to be untouched, was %q", event.Payload.Summary)
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	if got := truncateString("héllo", 2); got != "h"+TruncationMarker {
+		t.Errorf("Expected multi-byte character to be kept whole, got %q", got)
+	}
+	if got := truncateString("abc", 10); got != "abc"+TruncationMarker {
+		t.Errorf("Unexpected truncation %q", got)
+	}
+}
+
+func TestEnqueueWithMaxPayloadBytes(t *testing.T) {
+	defer gock.Off()
+
+	maxBytes := 1024
+	var bodySize int
+
+	mockEndpointV2(202, ResponseV2{Status: "success"})
+	gock.InterceptClient(DefaultHTTPClient)
+	gock.Observe(func(req *http.Request, _ gock.Mock) {
+		body, _ := ioutil.ReadAll(req.Body)
+		bodySize = len(body)
+	})
+	defer gock.Observe(nil)
+
+	data, _ := json.Marshal(oversizedEventV2(4096))
+	event := EventContainer{EventVersion: EventVersion2, EventData: data}
+
+	if _, err := Enqueue(context.Background(), &event, WithMaxPayloadBytes(maxBytes)); err != nil {
+		t.Fatal(err)
+	}
+
+	if bodySize == 0 || bodySize > maxBytes {
+		t.Errorf("Expected request body of at most %v bytes, was %v", maxBytes, bodySize)
+	}
+}
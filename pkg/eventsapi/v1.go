@@ -53,9 +53,9 @@ type DetailsV1 map[string]interface{}
 // currently representing as a single type for convenience.
 type ContextV1 struct {
 	Type   string `json:"type"`
-	Href   string `json:"href"`
+	Href   string `json:"href,omitempty"`
 	Text   string `json:"text,omitempty"`
-	Source string `json:"src"`
+	Source string `json:"src,omitempty"`
 	Alt    string `json:"alt,omitempty"`
 }
 
@@ -69,6 +69,12 @@ type ResponseV1 struct {
 	Errors      []string `json:"errors,omitempty"`
 }
 
+// Successful returns true if the HTTP response was a 2xx and its body reports
+// a status of "success".
+func (r *ResponseV1) Successful() bool {
+	return r.BaseResponse.Successful() && r.Status == "success"
+}
+
 // CreateV1 sends an event to explicitly the Events API V1.
 //
 // Keeping the `create` semantics versus `enqueue` to more closely match the
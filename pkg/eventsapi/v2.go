@@ -15,6 +15,8 @@ type EventV2 struct {
 	EventAction string    `json:"event_action"`
 	DedupKey    string    `json:"dedup_key,omitempty"`
 	Payload     PayloadV2 `json:"payload"`
+	Client      string    `json:"client,omitempty"`
+	ClientURL   string    `json:"client_url,omitempty"`
 	Images      []ImageV2 `json:"images,omitempty"`
 	Links       []LinkV2  `json:"links,omitempty"`
 }
@@ -73,10 +75,16 @@ type ResponseV2 struct {
 
 	Status   string   `json:"status,omitempty"`
 	Message  string   `json:"message,omitempty"`
-	DedupKey string   `json:"dedupkey,omitempty"`
+	DedupKey string   `json:"dedup_key,omitempty"`
 	Errors   []string `json:"errors,omitempty"`
 }
 
+// Successful returns true if the HTTP response was a 2xx and its body reports
+// a status of "success".
+func (r *ResponseV2) Successful() bool {
+	return r.BaseResponse.Successful() && r.Status == "success"
+}
+
 // EnqueueV2 sends an event explicitly to the Events API V2.
 func EnqueueV2(context context.Context, client *http.Client, event *EventV2) (*ResponseV2, error) {
 	var response ResponseV2
@@ -84,3 +92,26 @@ func EnqueueV2(context context.Context, client *http.Client, event *EventV2) (*R
 	err := enqueueEvent(context, client, url, event, &response)
 	return &response, err
 }
+
+// V2Client sends events directly to the Events API V2's `/v2/enqueue`
+// endpoint.
+type V2Client struct {
+	HTTPClient *http.Client
+}
+
+// NewV2Client returns a V2Client sending with the given HTTP client, or with
+// `DefaultHTTPClient` if nil.
+func NewV2Client(client *http.Client) *V2Client {
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	return &V2Client{HTTPClient: client}
+}
+
+// Enqueue validates the event and posts it to the Events API V2.
+func (c *V2Client) Enqueue(context context.Context, event *EventV2) (*ResponseV2, error) {
+	if err := event.Validate(); err != nil {
+		return &ResponseV2{}, err
+	}
+	return EnqueueV2(context, c.HTTPClient, event)
+}
@@ -111,3 +111,55 @@ func TestEnqueueV2TooManyRequests(t *testing.T) {
 		t.Errorf("Expected status code to be 429, was %v", resp.Status)
 	}
 }
+
+func TestV2ClientEnqueue(t *testing.T) {
+	defer gock.Off()
+
+	mockEndpointV2(202, ResponseV2{
+		Status:   "success",
+		Message:  "Event processed",
+		DedupKey: "12345",
+	})
+
+	event := EventV2{
+		RoutingKey:  "11863b592c824bfc8989d9cba76abcde",
+		EventAction: "trigger",
+		Payload: PayloadV2{
+			Summary:  "PagerDuty Agent `V2Client` Test",
+			Source:   "pdagent",
+			Severity: "error",
+		},
+	}
+
+	resp, err := NewV2Client(http.DefaultClient).Enqueue(context.Background(), &event)
+	if err != nil {
+		t.Fatal("Unexpected error during event enqueue", err)
+	}
+
+	if resp.Status != "success" {
+		t.Errorf("Expected status to be \"success\", was \"%v\"", resp.Status)
+	}
+
+	if !gock.IsDone() {
+		t.Error("Expected event to be posted to /v2/enqueue")
+	}
+}
+
+func TestV2ClientEnqueueInvalidEvent(t *testing.T) {
+	defer gock.Off()
+
+	mockEndpointV2(202, nil)
+
+	event := EventV2{
+		RoutingKey:  "11863b592c824bfc",
+		EventAction: "trigger",
+	}
+
+	if _, err := NewV2Client(nil).Enqueue(context.Background(), &event); err == nil {
+		t.Error("Expected error for an invalid routing key")
+	}
+
+	if gock.IsDone() {
+		t.Error("Expected invalid event not to be posted")
+	}
+}
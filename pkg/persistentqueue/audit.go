@@ -0,0 +1,225 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"go.uber.org/zap"
+)
+
+// DefaultAuditLogMaxBytes is the size an audit log is rotated at by default.
+const DefaultAuditLogMaxBytes = 100 * 1024 * 1024
+
+// auditBufferSize bounds the audit entries waiting to be written.
+const auditBufferSize = 1000
+
+// Audit log actions, recording an event being enqueued and its final
+// disposition, or it being dropped instead of enqueued.
+const (
+	AuditEnqueued     = "enqueued"
+	AuditDelivered    = "delivered"
+	AuditDeadLettered = "dead_lettered"
+	AuditDropped      = "dropped"
+)
+
+// AuditEntry is a single line of an audit log. Routing keys are masked, as
+// in logs.
+type AuditEntry struct {
+	Time         time.Time `json:"time"`
+	Action       string    `json:"action"`
+	EventID      string    `json:"event_id"`
+	RoutingKey   string    `json:"routing_key"`
+	EventVersion string    `json:"event_version"`
+	EventType    string    `json:"event_type,omitempty"`
+	DedupKey     string    `json:"dedup_key,omitempty"`
+	Summary      string    `json:"summary,omitempty"`
+	Attempts     int       `json:"attempts,omitempty"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// AuditLog appends a JSON line per event enqueued and per event delivered or
+// dead-lettered to a file, e.g. to keep a local record for compliance.
+//
+// Entries are buffered and written in the background so sending is never
+// held up by the disk; should the buffer fill, entries are dropped with a
+// warning. Once the file reaches `maxBytes` it's renamed with a ".1" suffix,
+// replacing any previous one, and a new file started.
+type AuditLog struct {
+	path     string
+	maxBytes int64
+
+	closed  bool
+	done    chan struct{}
+	entries chan AuditEntry
+	file    *os.File
+	logger  *zap.SugaredLogger
+	mu      sync.RWMutex
+	size    int64
+}
+
+// NewAuditLog opens an audit log appending to `path`, rotated once it reaches
+// `maxBytes` or never if not positive.
+func NewAuditLog(path string, maxBytes int64) (*AuditLog, error) {
+	a := AuditLog{
+		path:     path,
+		maxBytes: maxBytes,
+		done:     make(chan struct{}),
+		entries:  make(chan AuditEntry, auditBufferSize),
+		logger:   common.Logger.Named("AuditLog"),
+	}
+
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+
+	go a.run()
+	return &a, nil
+}
+
+// WithAuditLog is an option recording each event enqueued, delivered, and
+// dead-lettered in an audit log, closed when the queue shuts down.
+func WithAuditLog(auditLog *AuditLog) Option {
+	return func(q *PersistentQueue) {
+		q.auditLog = auditLog
+	}
+}
+
+// Record buffers an entry to be written without blocking.
+func (a *AuditLog) Record(entry AuditEntry) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return
+	}
+
+	select {
+	case a.entries <- entry:
+	default:
+		a.logger.Warnf("Audit log buffer full, dropped %v entry for %v.", entry.Action, entry.EventID)
+	}
+}
+
+// Close writes any buffered entries then closes the file.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.entries)
+	a.mu.Unlock()
+
+	<-a.done
+	return a.file.Close()
+}
+
+func (a *AuditLog) run() {
+	defer close(a.done)
+
+	for entry := range a.entries {
+		if err := a.write(entry); err != nil {
+			a.logger.Errorf("Failed to write audit log entry for %v: %v", entry.EventID, err)
+		}
+	}
+}
+
+func (a *AuditLog) write(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if a.maxBytes > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxBytes {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+func (a *AuditLog) open() error {
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	a.file = file
+	a.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file with a ".1" suffix and starts a new one.
+func (a *AuditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(a.path, a.path+".1"); err != nil {
+		return err
+	}
+	a.logger.Infof("Rotated audit log to %v.1.", a.path)
+	return a.open()
+}
+
+// audit records an action for an event in the audit log, if there is one.
+func (q *PersistentQueue) audit(action string, e *Event, resp eventqueue.Response) {
+	if q.auditLog == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:         q.clock.Now(),
+		Action:       action,
+		EventID:      e.Key,
+		RoutingKey:   common.MaskSecret(e.RoutingKey),
+		EventVersion: e.Event.EventVersion.String(),
+		Attempts:     e.Attempts,
+	}
+
+	if event, err := e.Event.UnmarshalEvent(); err == nil {
+		switch ev := event.(type) {
+		case *eventsapi.EventV1:
+			entry.EventType = ev.EventType
+			entry.DedupKey = ev.IncidentKey
+			entry.Summary = ev.Description
+		case *eventsapi.EventV2:
+			entry.EventType = ev.EventAction
+			entry.DedupKey = ev.DedupKey
+			entry.Summary = ev.Payload.Summary
+		case *eventsapi.ChangeEventV2:
+			entry.EventType = "change"
+			entry.Summary = ev.Payload.Summary
+		}
+	}
+
+	if dedupKey := responseDedupKey(resp.Response); dedupKey != "" {
+		entry.DedupKey = dedupKey
+	}
+	if resp.Response != nil {
+		if httpResp := resp.Response.GetHTTPResponse(); httpResp != nil {
+			entry.StatusCode = httpResp.StatusCode
+		}
+	}
+	if resp.Error != nil {
+		entry.Error = common.RedactSecrets(resp.Error.Error())
+	}
+
+	q.auditLog.Record(entry)
+}
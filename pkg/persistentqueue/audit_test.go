@@ -0,0 +1,157 @@
+package persistentqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+var tmpAuditLog = path.Join(tmpDir, "audit.log")
+
+func readAuditLog(t *testing.T, file string) []AuditEntry {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected a JSON audit entry, got %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func startAudited(t *testing.T, eq *MockEventQueue, options ...Option) *PersistentQueue {
+	os.Remove(tmpAuditLog)
+	os.Remove(tmpAuditLog + ".1")
+
+	auditLog, err := NewAuditLog(tmpAuditLog, DefaultAuditLogMaxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options = append([]Option{WithEventQueue(eq), WithFile(tmpDbFile), WithAuditLog(auditLog)}, options...)
+	q := NewPersistentQueue(options...)
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestPersistentQueueAuditLogDelivered(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer os.Remove(tmpAuditLog)
+
+	eq := NewMockEventQueue()
+	eq.Response = dedupKeyResponse("disk-full")
+	q := startAudited(t, eq)
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readAuditLog(t, tmpAuditLog)
+	if len(entries) != 2 {
+		t.Fatalf("Expected enqueued and delivered audit entries, found %v.", len(entries))
+	}
+
+	for i, action := range []string{AuditEnqueued, AuditDelivered} {
+		entry := entries[i]
+		if entry.Action != action || entry.EventID != key {
+			t.Errorf("Expected entry %v to be %v for %v, was %v for %v.", i, action, key, entry.Action, entry.EventID)
+		}
+		if entry.RoutingKey != "11****de" {
+			t.Errorf("Expected a masked routing key, was %q.", entry.RoutingKey)
+		}
+		if entry.EventType != "trigger" || entry.DedupKey != "disk-full" {
+			t.Errorf("Expected the event's action and dedup key, were %q and %q.", entry.EventType, entry.DedupKey)
+		}
+	}
+	if entries[1].Attempts != 1 {
+		t.Errorf("Expected delivery after one attempt, was %v.", entries[1].Attempts)
+	}
+}
+
+func TestPersistentQueueAuditLogDeadLettered(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer os.Remove(tmpAuditLog)
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}}
+	q := startAudited(t, eq)
+
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readAuditLog(t, tmpAuditLog)
+	if len(entries) != 2 {
+		t.Fatalf("Expected enqueued and dead-lettered audit entries, found %v.", len(entries))
+	}
+	if entries[1].Action != AuditDeadLettered || !strings.Contains(entries[1].Error, "invalid event") {
+		t.Errorf("Expected a dead-lettered entry with its error, was %+v.", entries[1])
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	setup(t)
+	defer os.Remove(tmpAuditLog)
+	defer os.Remove(tmpAuditLog + ".1")
+	os.Remove(tmpAuditLog)
+	os.Remove(tmpAuditLog + ".1")
+
+	auditLog, err := NewAuditLog(tmpAuditLog, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		auditLog.Record(AuditEntry{Action: AuditEnqueued, EventID: strings.Repeat("x", 32), RoutingKey: "11****de"})
+	}
+	if err := auditLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, rotated := readAuditLog(t, tmpAuditLog), readAuditLog(t, tmpAuditLog+".1")
+	if len(rotated) == 0 || len(current) == 0 {
+		t.Fatalf("Expected entries in both the rotated and the current file, found %v and %v.", len(rotated), len(current))
+	}
+
+	info, err := os.Stat(tmpAuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 300 {
+		t.Errorf("Expected the current file to stay within 300 bytes, was %v.", info.Size())
+	}
+
+	// Auditing after closing, e.g. a send completing late in shutdown, is
+	// ignored rather than panicking.
+	auditLog.Record(AuditEntry{Action: AuditDelivered})
+}
@@ -0,0 +1,175 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// StatusScheduled is that of an event waiting to be sent at `SendAt`, such as
+// an automatic resolve.
+const StatusScheduled = "scheduled"
+
+// scheduleAutoResolve enqueues a resolve for a delivered trigger with an
+// `AutoResolveAfter`, using the dedup (or incident) key PagerDuty returned.
+//
+// The resolve is stored as scheduled so it survives a restart, then sent once
+// the delay elapses.
+func (q *PersistentQueue) scheduleAutoResolve(e *Event, resp eventsapi.Response) {
+	delay := e.Event.AutoResolveAfter
+	if delay <= 0 {
+		return
+	}
+
+	dedupKey := responseDedupKey(resp)
+	if dedupKey == "" {
+		q.logger.Warnf("No dedup key returned for %v, unable to schedule its auto-resolve.", e.Key)
+		return
+	}
+
+	eventContainer, err := autoResolveEvent(e.Event, dedupKey)
+	if err != nil {
+		q.logger.Errorf("Failed to build auto-resolve for %v: %v", e.Key, err)
+		return
+	} else if eventContainer == nil {
+		return
+	}
+
+	now := q.clock.Now()
+	resolve, err := NewEventAt(eventContainer, now)
+	if err != nil {
+		q.logger.Errorf("Failed to build auto-resolve for %v: %v", e.Key, err)
+		return
+	}
+	resolve.Status = StatusScheduled
+	resolve.SendAt = now.Add(delay)
+
+	if err := resolve.CreateAt(q.Store, now); err != nil {
+		q.logger.Errorf("Failed to schedule auto-resolve for %v: %v", e.Key, err)
+		return
+	}
+	q.logger.Infow("Scheduled auto-resolve.", common.LogFieldEventID, resolve.Key, "trigger", e.Key, "send_at", resolve.SendAt)
+
+	q.armScheduled(resolve)
+}
+
+// loadScheduled arms timers for scheduled events, e.g. on start, sending any
+// already due immediately.
+func (q *PersistentQueue) loadScheduled() error {
+	events, err := q.Store.FindEvents(EventQuery{Statuses: []string{StatusScheduled}})
+	if err != nil {
+		return err
+	}
+
+	if len(events) > 0 {
+		q.logger.Infof("Loaded %v scheduled events.", len(events))
+	}
+	for i := range events {
+		q.armScheduled(&events[i])
+	}
+	return nil
+}
+
+// armScheduled sends a scheduled event once its `SendAt` is reached.
+//
+// Events scheduled while shutting down aren't armed, staying scheduled in the
+// database until the next start.
+func (q *PersistentQueue) armScheduled(e *Event) {
+	key := e.Key
+
+	q.scheduledMu.Lock()
+	defer q.scheduledMu.Unlock()
+
+	if q.isStopping() {
+		return
+	}
+	if _, ok := q.scheduled[key]; ok {
+		return
+	}
+	stop := make(chan struct{})
+	q.scheduled[key] = stop
+	due := q.clock.After(e.SendAt.Sub(q.clock.Now()))
+	go func() {
+		select {
+		case <-due:
+		case <-stop:
+			return
+		}
+
+		q.scheduledMu.Lock()
+		delete(q.scheduled, key)
+		q.scheduledMu.Unlock()
+
+		q.sendScheduled(key)
+	}()
+}
+
+// sendScheduled moves a scheduled event to pending and sends it.
+func (q *PersistentQueue) sendScheduled(key string) {
+	e, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		q.logger.Errorf("Failed to load scheduled event %v: %v", key, err)
+		return
+	}
+	if e.Status != StatusScheduled {
+		return
+	}
+
+	e.Status = StatusPending
+	if err := e.UpdateAt(q.Store, q.clock.Now()); err != nil {
+		q.logger.Errorf("Failed to mark %v pending: %v", e.Key, err)
+		return
+	}
+	q.logger.Infof("Sending scheduled event %v.", e.Key)
+	q.processEvent(e)
+}
+
+// stopScheduled stops waiting on all scheduled events, which stay scheduled
+// in the database until the next start.
+func (q *PersistentQueue) stopScheduled() {
+	q.scheduledMu.Lock()
+	defer q.scheduledMu.Unlock()
+
+	for key, stop := range q.scheduled {
+		close(stop)
+		delete(q.scheduled, key)
+	}
+}
+
+// autoResolveEvent returns a resolve for a trigger, carrying over its routing
+// key and details, or nil if the event isn't a trigger.
+func autoResolveEvent(eventContainer *eventsapi.EventContainer, dedupKey string) (*eventsapi.EventContainer, error) {
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		if e.EventType != "trigger" {
+			return nil, nil
+		}
+		e.EventType = "resolve"
+		e.IncidentKey = dedupKey
+	case *eventsapi.EventV2:
+		if e.EventAction != "trigger" {
+			return nil, nil
+		}
+		e.EventAction = "resolve"
+		e.DedupKey = dedupKey
+	default:
+		return nil, nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventsapi.EventContainer{
+		EventVersion: eventContainer.EventVersion,
+		EventData:    data,
+		Integration:  eventContainer.Integration,
+	}, nil
+}
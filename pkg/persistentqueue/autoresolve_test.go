@@ -0,0 +1,193 @@
+package persistentqueue
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func autoResolveEventContainer(action string, delay time.Duration) *eventsapi.EventContainer {
+	eventContainer := ttlEventContainer(action, 0)
+	eventContainer.AutoResolveAfter = delay
+	return eventContainer
+}
+
+func dedupKeyResponse(dedupKey string) eventqueue.Response {
+	return eventqueue.Response{Response: &eventsapi.ResponseV2{Status: "success", DedupKey: dedupKey}}
+}
+
+// findAutoResolve returns the auto-resolve scheduled for `triggerKey`, the
+// only other event in the queue.
+func findAutoResolve(t *testing.T, q *PersistentQueue, triggerKey string) *Event {
+	events, err := q.Store.FindEvents(EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected a trigger and its auto-resolve, found %v events.", len(events))
+	}
+	for i := range events {
+		if events[i].Key != triggerKey {
+			return &events[i]
+		}
+	}
+	t.Fatal("Expected an auto-resolve event.")
+	return nil
+}
+
+func TestPersistentQueueAutoResolve(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = dedupKeyResponse("returned-dedup-key")
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(autoResolveEventContainer("trigger", 200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	resolve := findAutoResolve(t, q, key)
+	if resolve.Status != StatusScheduled {
+		t.Errorf("Expected auto-resolve to be %v before its delay, was %v.", StatusScheduled, resolve.Status)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected only the trigger to be sent before the delay, was sent %v times.", calls)
+	}
+
+	event, err := resolve.Event.UnmarshalEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, ok := event.(*eventsapi.EventV2)
+	if !ok {
+		t.Fatalf("Expected a v2 auto-resolve, was %T.", event)
+	}
+	if v2.EventAction != "resolve" || v2.DedupKey != "returned-dedup-key" {
+		t.Errorf("Expected a resolve with the returned dedup key, was %v with %q.", v2.EventAction, v2.DedupKey)
+	}
+	if resolve.Event.AutoResolveAfter != 0 {
+		t.Errorf("Expected the auto-resolve not to schedule another, was %v.", resolve.Event.AutoResolveAfter)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	resolve, err = q.Store.FindEventByKey(resolve.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolve.Status != StatusSuccess {
+		t.Errorf("Expected auto-resolve to be sent after its delay, was %v.", resolve.Status)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 2 {
+		t.Errorf("Expected trigger and auto-resolve to be sent, was sent %v times.", calls)
+	}
+}
+
+func TestPersistentQueueAutoResolveSurvivesRestart(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = dedupKeyResponse("returned-dedup-key")
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := q.Enqueue(autoResolveEventContainer("trigger", 200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	resolveKey := findAutoResolve(t, q, key).Key
+
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	eq = NewMockEventQueue()
+	q = NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 0 {
+		t.Errorf("Expected auto-resolve not to be sent early on restart, was sent %v times.", calls)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	resolve, err := q.Store.FindEventByKey(resolveKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolve.Status != StatusSuccess {
+		t.Errorf("Expected auto-resolve to be sent after restart, was %v.", resolve.Status)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected auto-resolve to be sent once after restart, was sent %v times.", calls)
+	}
+}
+
+func TestPersistentQueueAutoResolveOnlyTriggers(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = dedupKeyResponse("returned-dedup-key")
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if _, err := q.Enqueue(autoResolveEventContainer("acknowledge", 10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	events, err := q.Store.FindEvents(EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("Expected no auto-resolve for an acknowledge, found %v events.", len(events))
+	}
+}
+
+func TestPersistentQueueAutoResolveFailedTrigger(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if _, err := q.Enqueue(autoResolveEventContainer("trigger", 10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	events, err := q.Store.FindEvents(EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("Expected no auto-resolve for an undelivered trigger, found %v events.", len(events))
+	}
+}
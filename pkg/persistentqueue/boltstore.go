@@ -0,0 +1,179 @@
+package persistentqueue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	stormq "github.com/asdine/storm/q"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltStore is a `Store` in a BoltDB database, via storm.
+type boltStore struct {
+	db          *storm.DB
+	events      storm.Node
+	deadLetters storm.Node
+}
+
+// openBoltStore opens a BoltDB store at `path`, waiting up to `lockTimeout`
+// for another process to release it.
+//
+// BoltDB holds an exclusive lock on the file while open, released by the OS
+// should the process holding it crash, so a lock is never stale.
+func openBoltStore(path string, lockTimeout time.Duration) (*boltStore, error) {
+	db, err := storm.Open(path, storm.BoltOptions(0600, &bolt.Options{Timeout: lockTimeout}))
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%w: %v", ErrQueueLocked, path)
+	} else if err != nil {
+		return nil, err
+	}
+	return newBoltStore(db), nil
+}
+
+func newBoltStore(db *storm.DB) *boltStore {
+	return &boltStore{
+		db:          db,
+		events:      db.From("events"),
+		deadLetters: db.From("dead_letters"),
+	}
+}
+
+// notFound translates storm's `ErrNotFound` to the store's.
+func notFound(err error) error {
+	if err == storm.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *boltStore) CreateEvent(e *Event) error {
+	return s.events.Save(e)
+}
+
+func (s *boltStore) UpdateEvent(e *Event) error {
+	// Storm's `Update` skips zero fields, so the event is replaced instead,
+	// once known to still exist.
+	tx, err := s.events.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing Event
+	if err := tx.One("ID", e.ID, &existing); err != nil {
+		return notFound(err)
+	}
+	if err := tx.Save(e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *boltStore) DeleteEvent(e *Event) error {
+	return notFound(s.events.DeleteStruct(e))
+}
+
+func (s *boltStore) FindEvent(id int) (*Event, error) {
+	var e Event
+	if err := s.events.One("ID", id, &e); err != nil {
+		return nil, notFound(err)
+	}
+	return &e, nil
+}
+
+func (s *boltStore) FindEventByKey(key string) (*Event, error) {
+	var e Event
+	if err := s.events.One("Key", key, &e); err != nil {
+		return nil, notFound(err)
+	}
+	return &e, nil
+}
+
+func (s *boltStore) FindEvents(query EventQuery) ([]Event, error) {
+	var matchers []stormq.Matcher
+	if len(query.Statuses) > 0 {
+		matchers = append(matchers, stormq.In("Status", query.Statuses))
+	}
+	if query.RoutingKey != "" {
+		matchers = append(matchers, stormq.Eq("RoutingKey", query.RoutingKey))
+	}
+
+	selected := s.events.Select(matchers...).OrderBy("ID")
+	if query.Limit > 0 {
+		selected = selected.Limit(query.Limit)
+	}
+
+	var events []Event
+	if err := selected.Find(&events); err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *boltStore) CountEvents(statuses ...string) (int, error) {
+	return s.events.Select(stormq.In("Status", statuses)).Count(&Event{})
+}
+
+func (s *boltStore) DeadLetters(routingKey string) ([]DeadLetter, error) {
+	var err error
+	var deadLetters []DeadLetter
+
+	if routingKey == "" {
+		err = s.deadLetters.All(&deadLetters)
+	} else {
+		err = s.deadLetters.Find("RoutingKey", routingKey, &deadLetters)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+func (s *boltStore) FindDeadLetter(id int) (*DeadLetter, error) {
+	var d DeadLetter
+	if err := s.deadLetters.One("ID", id, &d); err != nil {
+		return nil, notFound(err)
+	}
+	return &d, nil
+}
+
+func (s *boltStore) FindDeadLetterByEventKey(key string) (*DeadLetter, error) {
+	var d DeadLetter
+	if err := s.deadLetters.One("EventKey", key, &d); err != nil {
+		return nil, notFound(err)
+	}
+	return &d, nil
+}
+
+func (s *boltStore) SaveDeadLetter(d *DeadLetter) error {
+	return s.deadLetters.Save(d)
+}
+
+func (s *boltStore) DeleteDeadLetter(d *DeadLetter) error {
+	return notFound(s.deadLetters.DeleteStruct(d))
+}
+
+func (s *boltStore) CountDeadLetters() (int, error) {
+	return s.deadLetters.Count(&DeadLetter{})
+}
+
+func (s *boltStore) DeleteDeadLetters() error {
+	if err := s.deadLetters.Select().Delete(&DeadLetter{}); err != nil && err != storm.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+func (s *boltStore) GetSetting(key string, value interface{}) error {
+	return notFound(s.db.Get(settingsBucket, key, value))
+}
+
+func (s *boltStore) SetSetting(key string, value interface{}) error {
+	return s.db.Set(settingsBucket, key, value)
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
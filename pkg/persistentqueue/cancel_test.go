@@ -0,0 +1,182 @@
+package persistentqueue
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+// blockingTransport holds requests until they're cancelled, signalling
+// `started` as each arrives.
+type blockingTransport struct {
+	started chan struct{}
+}
+
+func (b *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.started <- struct{}{}
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func newBlockingEventQueue() (*eventqueue.EventQueue, *blockingTransport) {
+	transport := &blockingTransport{started: make(chan struct{}, 1)}
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = eventqueue.NewEventProcessor(eventsapi.WithHTTPClient(&http.Client{Transport: transport}))
+	return eq, transport
+}
+
+func TestPersistentQueueCancelledSend(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq, transport := newBlockingEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-transport.started
+	eq.Cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusPending {
+		t.Errorf("Expected cancelled event to return to %v, was %v.", StatusPending, event.Status)
+	}
+	if event.Attempts != 0 {
+		t.Errorf("Expected cancelled send not to count as an attempt, was %v.", event.Attempts)
+	}
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 0 {
+		t.Errorf("Expected cancelled event not to be dead-lettered, found %v dead letters.", len(deadLetters))
+	}
+}
+
+func TestPersistentQueueShutdownCancelsSends(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq, transport := newBlockingEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithShutdownGracePeriod(50*time.Millisecond))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-transport.started
+
+	started := time.Now()
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > cancelGracePeriod {
+		t.Errorf("Expected shutdown to cancel the in-flight send promptly, took %v.", elapsed)
+	}
+
+	db, err := openBoltStore(tmpDbFile, dbLockTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	event, err := db.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusPending {
+		t.Errorf("Expected event in-flight at shutdown to be %v, was %v.", StatusPending, event.Status)
+	}
+}
+
+// stallingTransport holds the first request until it's cancelled, as a stuck
+// socket would, then accepts the rest.
+type stallingTransport struct {
+	requests int32
+}
+
+func (s *stallingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.AddInt32(&s.requests, 1) == 1 {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		StatusCode: http.StatusAccepted,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"status":"success","message":"Event processed","dedup_key":"disk-full"}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestPersistentQueueStalledSendResent(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	const stallTimeout = time.Minute
+	clock := test.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	transport := &stallingTransport{}
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = eventqueue.NewEventProcessor(eventsapi.WithHTTPClient(&http.Client{Transport: transport}))
+	eq.WorkerStallTimeout = stallTimeout
+	eq.Clock = clock
+
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(3), WithClock(clock))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Advance past the stall timeout until the watchdog restarts the worker,
+	// then past the retry delay until the event is resent and delivered.
+	deadline := time.After(5 * time.Second)
+	for {
+		event, err := q.Store.FindEventByKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Status == StatusSuccess {
+			break
+		}
+
+		select {
+		case <-deadline:
+			t.Fatalf("Expected the stalled event to be resent, was %v after %v requests.", event.Status, atomic.LoadInt32(&transport.requests))
+		case <-time.After(5 * time.Millisecond):
+		}
+		clock.Advance(stallTimeout)
+	}
+
+	if stalls := eq.Stalls(); stalls != 1 {
+		t.Errorf("Expected 1 stall, got %v.", stalls)
+	}
+	if requests := atomic.LoadInt32(&transport.requests); requests != 2 {
+		t.Errorf("Expected the event to be sent twice, was sent %v times.", requests)
+	}
+}
@@ -0,0 +1,100 @@
+package persistentqueue
+
+import (
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// DeadLetter records an event that failed to send after the underlying
+// transport exhausted its retries or received a terminal (e.g. 400) response.
+//
+// `Retryable` distinguishes failures a later retry may fix, such as a server
+// error, from terminal ones like an invalid event.
+//
+// Each event has at most one dead letter, updated on repeated failures and
+// removed once the event is sent successfully.
+type DeadLetter struct {
+	ID         int                       `storm:"id,increment" json:"id"`
+	EventKey   string                    `storm:"unique" json:"event_key"`
+	RoutingKey string                    `storm:"index" json:"routing_key"`
+	Event      *eventsapi.EventContainer `json:"event"`
+	StatusCode int                       `json:"status_code,omitempty"`
+	Error      string                    `json:"error"`
+	Retryable  bool                      `json:"retryable"`
+	CreatedAt  time.Time                 `storm:"index" json:"created_at"`
+}
+
+// DeadLetters returns dead-lettered events, either for a routing key or for
+// all routing keys if none is provided.
+func (q *PersistentQueue) DeadLetters(routingKey string) ([]DeadLetter, error) {
+	return q.Store.DeadLetters(routingKey)
+}
+
+// RetryDeadLetter requeues the event corresponding to a dead letter.
+//
+// The dead letter is removed before requeuing; should the event fail again a
+// new one is recorded. As with `Retry`, the event no longer expires.
+func (q *PersistentQueue) RetryDeadLetter(id int) error {
+	deadLetter, err := q.Store.FindDeadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	event, err := q.Store.FindEventByKey(deadLetter.EventKey)
+	if err != nil {
+		return err
+	}
+
+	if err := q.Store.DeleteDeadLetter(deadLetter); err != nil {
+		return err
+	}
+
+	q.logger.Infof("Retrying dead letter %v for %v.", deadLetter.ID, event.Key)
+	if err := q.clearExpiry(event); err != nil {
+		return err
+	}
+	q.processEvent(event)
+
+	return nil
+}
+
+// deadLetter creates or updates the dead letter for a failed event.
+func (q *PersistentQueue) deadLetter(e *Event, resp eventqueue.Response) error {
+	deadLetter, err := q.Store.FindDeadLetterByEventKey(e.Key)
+	if err == ErrNotFound {
+		deadLetter = &DeadLetter{}
+	} else if err != nil {
+		return err
+	}
+
+	deadLetter.EventKey = e.Key
+	deadLetter.RoutingKey = e.RoutingKey
+	deadLetter.Event = e.Event
+	deadLetter.StatusCode = 0
+	deadLetter.Error = resp.Error.Error()
+	deadLetter.Retryable = isRetryable(resp.Error)
+	deadLetter.CreatedAt = q.clock.Now()
+
+	if resp.Response != nil {
+		if httpResp := resp.Response.GetHTTPResponse(); httpResp != nil {
+			deadLetter.StatusCode = httpResp.StatusCode
+		}
+	}
+
+	return q.Store.SaveDeadLetter(deadLetter)
+}
+
+// clearDeadLetter removes any dead letter for an event, e.g. after a
+// successful retry.
+func (q *PersistentQueue) clearDeadLetter(e *Event) error {
+	deadLetter, err := q.Store.FindDeadLetterByEventKey(e.Key)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return q.Store.DeleteDeadLetter(deadLetter)
+}
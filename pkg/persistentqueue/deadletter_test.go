@@ -0,0 +1,104 @@
+package persistentqueue
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func TestPersistentQueueDeadLetter(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{
+		Response: &eventsapi.ResponseV2{
+			BaseResponse: eventsapi.BaseResponse{HTTPResponse: &http.Response{StatusCode: 400}},
+		},
+		Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")},
+	}
+
+	q := NewPersistentQueue(WithEventQueue(eq))
+
+	err := q.Start()
+	if err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+
+	eventContainer := eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData: []byte(`
+			{
+				"routing_key":  "11863b592c824bfc8989d9cba76abcde",
+				"event_action": "trigger",
+				"payload": {
+					"summary":  "PagerDuty Agent CreateV1 Test",
+					"source":   "pdagent",
+					"severity": "error"
+				}
+			}
+		`),
+	}
+
+	key, err := q.Enqueue(&eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deadLetters) != 1 {
+		t.Fatalf("Expected one dead letter, found %v.", len(deadLetters))
+	}
+
+	deadLetter := deadLetters[0]
+	if deadLetter.EventKey != key {
+		t.Errorf("Expected dead letter for %v, was %v.", key, deadLetter.EventKey)
+	}
+	if deadLetter.StatusCode != 400 {
+		t.Errorf("Expected dead letter status code to be 400, was %v.", deadLetter.StatusCode)
+	}
+	if deadLetter.Error != "invalid event (HTTP 400)" {
+		t.Errorf("Expected dead letter error to be recorded, was %v.", deadLetter.Error)
+	}
+	if deadLetter.Retryable {
+		t.Error("Expected dead letter for an invalid event to not be retryable.")
+	}
+
+	eq.SetResponse(eventqueue.Response{})
+
+	if err := q.RetryDeadLetter(deadLetter.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	deadLetters, err = q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deadLetters) != 0 {
+		t.Fatalf("Expected dead letter to be removed after retry, found %v.", len(deadLetters))
+	}
+
+	persistedEvent, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal("Could not find persisted event.")
+	}
+
+	if persistedEvent.Status != StatusSuccess {
+		t.Fatalf("Expected event status to be success, was %v.", persistedEvent.Status)
+	}
+
+	_ = q.Shutdown()
+}
@@ -0,0 +1,142 @@
+package persistentqueue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// maxRecentSuppressions is how many suppressed duplicates are kept for
+// `Suppressions`.
+const maxRecentSuppressions = 100
+
+// Suppression is a duplicate event suppressed within the dedup window, with
+// its routing key masked.
+type Suppression struct {
+	Time       time.Time `json:"time"`
+	EventKey   string    `json:"event_key"`
+	RoutingKey string    `json:"routing_key"`
+}
+
+// dedupCache remembers recently enqueued payloads so identical enqueues
+// within a window, e.g. a monitoring tool retrying a notification command,
+// can be suppressed before reaching PagerDuty.
+type dedupCache struct {
+	mu         sync.Mutex
+	seen       map[string]dedupEntry
+	suppressed []Suppression
+	window     time.Duration
+}
+
+type dedupEntry struct {
+	key  string
+	seen time.Time
+}
+
+func newDedupCache(window time.Duration) *dedupCache {
+	return &dedupCache{
+		seen:   make(map[string]dedupEntry),
+		window: window,
+	}
+}
+
+// reserve records an event about to be enqueued as `key`, returning true,
+// unless an identical event was enqueued within the window, returning false
+// and its key instead.
+//
+// Checking and recording under one lock, so of concurrent identical enqueues
+// only one is enqueued.
+func (c *dedupCache) reserve(hash, key string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(now)
+
+	if entry, ok := c.seen[hash]; ok {
+		return entry.key, false
+	}
+	c.seen[hash] = dedupEntry{key: key, seen: now}
+	return key, true
+}
+
+// release forgets a reservation for an event that failed to be enqueued, so
+// retrying it isn't suppressed.
+func (c *dedupCache) release(hash, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.seen[hash].key == key {
+		delete(c.seen, hash)
+	}
+}
+
+// suppress records a duplicate suppressed in favor of the event `key`,
+// keeping only the most recent.
+func (c *dedupCache) suppress(key, routingKey string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.suppressed = append(c.suppressed, Suppression{Time: now, EventKey: key, RoutingKey: common.MaskSecret(routingKey)})
+	if len(c.suppressed) > maxRecentSuppressions {
+		c.suppressed = c.suppressed[len(c.suppressed)-maxRecentSuppressions:]
+	}
+}
+
+// Suppressions returns the most recently suppressed duplicates, newest first,
+// or none if the dedup window is disabled.
+func (q *PersistentQueue) Suppressions() []Suppression {
+	if q.dedup == nil {
+		return []Suppression{}
+	}
+
+	q.dedup.mu.Lock()
+	defer q.dedup.mu.Unlock()
+
+	suppressions := make([]Suppression, len(q.dedup.suppressed))
+	for i, s := range q.dedup.suppressed {
+		suppressions[len(suppressions)-1-i] = s
+	}
+	return suppressions
+}
+
+// clear forgets all enqueued events.
+func (c *dedupCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen = make(map[string]dedupEntry)
+}
+
+func (c *dedupCache) prune(now time.Time) {
+	for hash, entry := range c.seen {
+		if now.Sub(entry.seen) >= c.window {
+			delete(c.seen, hash)
+		}
+	}
+}
+
+// payloadHash hashes the fields identifying a notification: routing key,
+// incident (or dedup) key, event type (or action), and description (or
+// summary).
+func payloadHash(event eventsapi.Event) string {
+	var fields []string
+
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		fields = []string{e.ServiceKey, e.IncidentKey, e.EventType, e.Description}
+	case *eventsapi.EventV2:
+		fields = []string{e.RoutingKey, e.DedupKey, e.EventAction, e.Payload.Summary}
+	case *eventsapi.ChangeEventV2:
+		fields = []string{e.RoutingKey, e.Payload.Timestamp, "change", e.Payload.Summary}
+	default:
+		fields = []string{event.GetRoutingKey()}
+	}
+
+	hash := sha256.Sum256([]byte(event.Version().String() + "\x00" + strings.Join(fields, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
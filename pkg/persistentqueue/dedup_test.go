@@ -0,0 +1,225 @@
+package persistentqueue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func buildDedupEventContainer(summary string) eventsapi.EventContainer {
+	return eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData: []byte(`
+			{
+				"routing_key":  "11863b592c824bfc8989d9cba76abcde",
+				"event_action": "trigger",
+				"dedup_key":    "12345",
+				"payload": {
+					"summary":  "` + summary + `",
+					"source":   "pdagent",
+					"severity": "error"
+				}
+			}
+		`),
+	}
+}
+
+func countEvents(t *testing.T, q *PersistentQueue) int {
+	events, err := q.Store.FindEvents(EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(events)
+}
+
+func TestPersistentQueueDedupWithinWindow(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithDedupWindow(time.Minute))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	event := buildDedupEventContainer("Disk full")
+	key1, err := q.Enqueue(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	duplicate := buildDedupEventContainer("Disk full")
+	key2, err := q.Enqueue(&duplicate)
+	if err != nil {
+		t.Fatalf("Expected duplicate to succeed, got %v.", err)
+	}
+
+	if key2 != key1 {
+		t.Errorf("Expected duplicate to return original key %v, was %v.", key1, key2)
+	}
+	if count := countEvents(t, q); count != 1 {
+		t.Errorf("Expected 1 event to be stored, found %v.", count)
+	}
+
+	different := buildDedupEventContainer("Disk nearly full")
+	key3, err := q.Enqueue(&different)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key3 == key1 {
+		t.Error("Expected a different payload to be enqueued separately.")
+	}
+	if count := countEvents(t, q); count != 2 {
+		t.Errorf("Expected 2 events to be stored, found %v.", count)
+	}
+}
+
+func TestPersistentQueueDedupConcurrent(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithDedupWindow(time.Minute))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	const enqueues = 20
+	keys := make([]string, enqueues)
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			event := buildDedupEventContainer("Disk full")
+			key, err := q.Enqueue(&event)
+			if err != nil {
+				t.Error(err)
+			}
+			keys[i] = key
+		}(i)
+	}
+	wg.Wait()
+
+	if count := countEvents(t, q); count != 1 {
+		t.Errorf("Expected concurrent duplicates to enqueue 1 event, enqueued %v.", count)
+	}
+	for _, key := range keys {
+		if key != keys[0] {
+			t.Errorf("Expected every duplicate to return key %v, got %v.", keys[0], key)
+		}
+	}
+	if suppressions := q.Suppressions(); len(suppressions) != enqueues-1 {
+		t.Errorf("Expected %v suppressions, got %v.", enqueues-1, len(suppressions))
+	}
+}
+
+func TestPersistentQueueDedupOutsideWindow(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithDedupWindow(100*time.Millisecond))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	event := buildDedupEventContainer("Disk full")
+	key1, err := q.Enqueue(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	duplicate := buildDedupEventContainer("Disk full")
+	key2, err := q.Enqueue(&duplicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key2 == key1 {
+		t.Error("Expected event outside the dedup window to be enqueued separately.")
+	}
+	if count := countEvents(t, q); count != 2 {
+		t.Errorf("Expected 2 events to be stored, found %v.", count)
+	}
+}
+
+func TestPersistentQueueDedupDisabled(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	event := buildDedupEventContainer("Disk full")
+	duplicate := buildDedupEventContainer("Disk full")
+	key1, _ := q.Enqueue(&event)
+	key2, _ := q.Enqueue(&duplicate)
+
+	if key2 == key1 {
+		t.Error("Expected duplicates to be enqueued when no dedup window is set.")
+	}
+}
+
+func TestPersistentQueueDedupSuppressions(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithDedupWindow(time.Minute))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	event := buildDedupEventContainer("Disk full")
+	key, err := q.Enqueue(&event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := q.Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Suppressed) != 0 {
+		t.Errorf("Expected no suppressions before a duplicate, got %v.", m.Suppressed)
+	}
+
+	for i := 0; i < 2; i++ {
+		duplicate := buildDedupEventContainer("Disk full")
+		if _, err := q.Enqueue(&duplicate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err = q.Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Suppressed["11****de"] != 2 || len(m.Suppressed) != 1 {
+		t.Errorf("Expected 2 suppressions for the masked routing key, got %v.", m.Suppressed)
+	}
+
+	suppressions := q.Suppressions()
+	if len(suppressions) != 2 || suppressions[0].EventKey != key || suppressions[0].RoutingKey != "11****de" {
+		t.Errorf("Expected 2 suppressions of %v, got %+v.", key, suppressions)
+	}
+	if suppressions[0].Time.Before(suppressions[1].Time) {
+		t.Errorf("Expected the newest suppression first, got %+v.", suppressions)
+	}
+}
+
+func TestPersistentQueueDedupSuppressionsDisabled(t *testing.T) {
+	q := NewPersistentQueue()
+	if suppressions := q.Suppressions(); len(suppressions) != 0 {
+		t.Errorf("Expected no suppressions without a dedup window, got %+v.", suppressions)
+	}
+}
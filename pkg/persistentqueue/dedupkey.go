@@ -0,0 +1,41 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// withDedupKey returns a copy of a trigger without a dedup (or incident) key
+// given a generated one, or the event container unchanged otherwise.
+//
+// PagerDuty treats triggers with the same key as the same alert, so should a
+// send fail after reaching PagerDuty, e.g. timing out awaiting its response,
+// resending it can't open a duplicate incident. The key is stored with the
+// event, so it's the same for every attempt, including after a restart.
+func withDedupKey(eventContainer *eventsapi.EventContainer, event eventsapi.Event) (*eventsapi.EventContainer, error) {
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		if e.EventType != "trigger" || e.IncidentKey != "" {
+			return eventContainer, nil
+		}
+		e.IncidentKey = common.GenerateUUID()
+	case *eventsapi.EventV2:
+		if e.EventAction != "trigger" || e.DedupKey != "" {
+			return eventContainer, nil
+		}
+		e.DedupKey = common.GenerateUUID()
+	default:
+		return eventContainer, nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	keyed := *eventContainer
+	keyed.EventData = data
+	return &keyed, nil
+}
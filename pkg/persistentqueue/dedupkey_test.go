@@ -0,0 +1,122 @@
+package persistentqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func unkeyedEventContainer(action string) *eventsapi.EventContainer {
+	return &eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData: []byte(`
+			{
+				"routing_key":  "11863b592c824bfc8989d9cba76abcde",
+				"event_action": "` + action + `",
+				"payload": {
+					"summary":  "PagerDuty Agent Dedup Key Test",
+					"source":   "pdagent",
+					"severity": "error"
+				}
+			}
+		`),
+	}
+}
+
+// sentDedupKeys returns the dedup key of each V2 event sent.
+func sentDedupKeys(t *testing.T, eq *MockEventQueue) []string {
+	var keys []string
+	for _, eventContainer := range eq.Sent() {
+		event, err := eventContainer.UnmarshalEvent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, event.(*eventsapi.EventV2).DedupKey)
+	}
+	return keys
+}
+
+func TestPersistentQueueDedupKeyRetries(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer withRetryDelay(10 * time.Millisecond)()
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500, Err: errors.New("server error")}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(2))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if _, err := q.Enqueue(unkeyedEventContainer("trigger")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	keys := sentDedupKeys(t, eq)
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 attempts, got %v.", len(keys))
+	}
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Expected both attempts to carry the same generated dedup key, got %q and %q.", keys[0], keys[1])
+	}
+}
+
+func TestPersistentQueueDedupKeySurvivesRestart(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	// Sends never complete, so each start resends the event, as it would
+	// an attempt interrupted by a crash.
+	var keys []string
+	for i := 0; i < 2; i++ {
+		eq := NewMockEventQueue()
+		eq.Delay = time.Hour
+		q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithShutdownGracePeriod(0))
+		if err := q.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if _, err := q.Enqueue(unkeyedEventContainer("trigger")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		keys = append(keys, sentDedupKeys(t, eq)...)
+		if err := q.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Expected both attempts to carry the same generated dedup key, got %q.", keys)
+	}
+}
+
+func TestPersistentQueueDedupKeyOnlyUnkeyedTriggers(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	for _, eventContainer := range []*eventsapi.EventContainer{ttlEventContainer("trigger", 0), unkeyedEventContainer("acknowledge")} {
+		if _, err := q.Enqueue(eventContainer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	keys := sentDedupKeys(t, eq)
+	if len(keys) != 2 || keys[0] != "disk-full" || keys[1] != "" {
+		t.Errorf("Expected the given dedup key kept and none added to the acknowledge, got %q.", keys)
+	}
+}
@@ -0,0 +1,88 @@
+package persistentqueue
+
+import (
+	"errors"
+	"time"
+)
+
+// drainPollInterval is how often a drain checks whether the queue is empty,
+// sending any events that were scheduled for a retry in the meantime.
+var drainPollInterval = 100 * time.Millisecond
+
+var (
+	// ErrQueueDraining occurs when enqueuing an event while the queue is
+	// being drained.
+	ErrQueueDraining = errors.New("queue is draining, not accepting new events")
+
+	// ErrDrainTimeout occurs when the queue still has events waiting to be sent
+	// once a drain's timeout elapses.
+	ErrDrainTimeout = errors.New("timed out draining queue")
+)
+
+// Draining returns true once the queue has started draining.
+func (q *PersistentQueue) Draining() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.draining
+}
+
+// Drain stops accepting new events and sends everything waiting, blocking
+// until there's nothing left to send or the timeout elapses, e.g. before
+// decommissioning a host. Returns how many events remain, with
+// `ErrDrainTimeout` if any do.
+//
+// Draining disables maintenance mode, and sends events scheduled for a retry
+// or an auto-resolve immediately rather than when due. Events that fail to
+// send are still dead-lettered as usual, and don't count as remaining. Once
+// started a drain can't be undone, short of restarting.
+func (q *PersistentQueue) Drain(timeout time.Duration) (int, error) {
+	q.mu.Lock()
+	q.draining = true
+	q.mu.Unlock()
+
+	if q.Maintenance() {
+		q.logger.Info("Disabling maintenance mode to drain the queue.")
+		if err := q.SetMaintenance(false); err != nil {
+			return 0, err
+		}
+	}
+
+	deadline := q.clock.Now().Add(timeout)
+	for {
+		remaining, err := q.drainOnce()
+		if err != nil {
+			return remaining, err
+		}
+		if remaining == 0 {
+			q.logger.Info("Drained queue, all events sent.")
+			return 0, nil
+		}
+		if !q.clock.Now().Before(deadline) {
+			q.logger.Warnf("Timed out draining queue, %v events remain.", remaining)
+			return remaining, ErrDrainTimeout
+		}
+
+		q.logger.Infof("Draining queue, %v events remain.", remaining)
+		q.clock.Sleep(drainPollInterval)
+	}
+}
+
+// drainOnce sends pending and scheduled events not already being sent,
+// returning how many events are waiting to be sent.
+func (q *PersistentQueue) drainOnce() (int, error) {
+	events, err := q.Store.FindEvents(EventQuery{Statuses: queuedStatuses})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range events {
+		switch events[i].Status {
+		case StatusPending:
+			q.processEvent(&events[i])
+		case StatusScheduled:
+			q.disarmScheduled(events[i].Key)
+			q.sendScheduled(events[i].Key)
+		}
+	}
+	return len(events), nil
+}
@@ -0,0 +1,104 @@
+package persistentqueue
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func TestPersistentQueueDrain(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = dedupKeyResponse("returned-dedup-key")
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaintenance(true))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	// A backlog built up during maintenance, one trigger with an auto-resolve
+	// not due for an hour.
+	if _, err := q.Enqueue(autoResolveEventContainer("trigger", time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	remaining, err := q.Drain(time.Second)
+	if err != nil || remaining != 0 {
+		t.Fatalf("Expected the queue to drain, got %v remaining: %v", remaining, err)
+	}
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 3 {
+		t.Errorf("Expected both triggers and the auto-resolve to be sent, was sent %v times.", calls)
+	}
+	events, err := q.Store.FindEvents(EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if e.Status != StatusSuccess {
+			t.Errorf("Expected every event to be %v, %v was %v.", StatusSuccess, e.Key, e.Status)
+		}
+	}
+
+	if q.Maintenance() {
+		t.Error("Expected draining to disable maintenance mode.")
+	}
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != ErrQueueDraining {
+		t.Errorf("Expected new events to be refused once draining, got %v.", err)
+	}
+	if err := q.Ready(); err != ErrQueueDraining {
+		t.Errorf("Expected the queue not to be ready once draining, got %v.", err)
+	}
+}
+
+func TestPersistentQueueDrainTimeout(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(100))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remaining, err := q.Drain(300 * time.Millisecond)
+	if err != ErrDrainTimeout || remaining != 1 {
+		t.Fatalf("Expected the drain to time out with 1 event remaining, got %v remaining: %v", remaining, err)
+	}
+	// Retries are resent without waiting for their delay.
+	if calls := atomic.LoadInt32(&eq.Calls); calls < 2 {
+		t.Errorf("Expected the failing event to be retried while draining, was sent %v times.", calls)
+	}
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The event is left in the store for the next start.
+	q = NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithFile(tmpDbFile), WithMaintenance(true))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status == StatusSuccess || event.Status == StatusError {
+		t.Errorf("Expected the event to still be waiting to be sent, was %v.", event.Status)
+	}
+}
@@ -1,8 +1,15 @@
 package persistentqueue
 
 import (
+	"context"
+	"errors"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Enqueue adds an event to the persistent queue for processing.
@@ -13,61 +20,297 @@ import (
 // cases where we might not have a per-event response channel (e.g. processing
 // a backlog).
 func (q *PersistentQueue) Enqueue(eventContainer *eventsapi.EventContainer) (string, error) {
+	// Continuing any trace the event arrived with, and recording this span
+	// with the event so those sending it are its children.
+	ctx, span := tracing.Tracer().Start(tracing.ContextWithTraceparent(context.Background(), eventContainer.TraceParent), "pdagent.enqueue")
+	if traceparent := tracing.Traceparent(ctx); traceparent != "" {
+		eventContainer.TraceParent = traceparent
+	}
+
+	key, err := q.enqueue(eventContainer)
+	span.SetAttributes(attribute.String("pdagent.event_key", key))
+	tracing.SetError(span, err)
+	span.End()
+	return key, err
+}
+
+func (q *PersistentQueue) enqueue(eventContainer *eventsapi.EventContainer) (string, error) {
+	if q.isStopping() {
+		return "", ErrQueueShutdown
+	}
+	if q.Draining() {
+		return "", ErrQueueDraining
+	}
+
 	event, err := eventContainer.UnmarshalEvent()
 	if err != nil {
-		q.logger.Errorf("Failed to unmarshal event container in queue", err)
+		q.logger.Errorw("Failed to unmarshal event container in queue.", "error", err)
 		return "", err
 	}
 
+	common.RegisterSecret(event.GetRoutingKey())
+
 	if err := event.Validate(); err != nil {
-		q.logger.Errorf("Failed to validate event in queue %v.", event.GetRoutingKey(), err)
+		q.logger.Errorw("Failed to validate event in queue.", common.LogFieldRoutingKey, common.MaskSecret(event.GetRoutingKey()), "error", err)
 		return "", err
 	}
 
-	e, err := NewEvent(eventContainer)
+	if q.belowMinSeverity(event) {
+		return q.drop(eventContainer, event)
+	}
+
+	now := q.clock.Now()
+	key := common.GenerateKey()
+	stored := false
+	if q.dedup != nil {
+		hash := payloadHash(event)
+		if existing, ok := q.dedup.reserve(hash, key, now); !ok {
+			q.logger.Infow("Suppressed duplicate event.", common.LogFieldEventID, existing, common.LogFieldRoutingKey, common.MaskSecret(event.GetRoutingKey()))
+			q.dedup.suppress(existing, event.GetRoutingKey(), now)
+			q.metrics.incSuppressed(event.GetRoutingKey())
+			return existing, nil
+		}
+		defer func() {
+			if !stored {
+				q.dedup.release(hash, key)
+			}
+		}()
+	}
+
+	if q.maxQueueDepth > 0 {
+		q.overflowMu.Lock()
+		defer q.overflowMu.Unlock()
+		if err := q.makeRoom(event); err != nil {
+			return "", err
+		}
+	}
+
+	// Only once checked for duplicates, which are matched on the key the
+	// event was sent with.
+	eventContainer, err = withDedupKey(eventContainer, event)
 	if err != nil {
 		return "", err
 	}
-	q.logger.Infof("Enqueuing to %v with key %v.", event.GetRoutingKey(), e.Key)
 
-	if err := e.Create(q.Events); err != nil {
-		q.logger.Errorf("Failed to create event %v: %v.", e.Key, err)
+	e, err := NewEventAt(eventContainer, now)
+	if err != nil {
+		return "", err
+	}
+	e.Key = key
+	e.ExpiresAt = q.expiresAt(eventContainer, event, now)
+	q.logger.Infow("Enqueuing event.", common.LogFieldEventID, e.Key, common.LogFieldRoutingKey, common.MaskSecret(e.RoutingKey))
+
+	if err := e.CreateAt(q.Store, now); err != nil {
+		err = q.spool(e, err)
+		stored = err == nil
 		return e.Key, err
 	}
+	stored = true
 	q.logger.Infof("Event enqueued with key %v, ID %v.", e.Key, e.ID)
-
-	q.processEvent(e)
+	q.enqueued(e)
 
 	return e.Key, nil
 }
 
-func (q *PersistentQueue) processEvent(e *Event) {
+// enqueued records and sends a newly stored event.
+func (q *PersistentQueue) enqueued(e *Event) {
+	q.metrics.incEnqueued()
+	q.audit(AuditEnqueued, e, eventqueue.Response{})
+
+	q.processEvent(e)
+}
+
+// processEvent sends an event via the underlying event queue, returning a
+// channel closed once the send completes and its status is recorded.
+//
+// Events already being sent, e.g. by a concurrent flush, aren't sent again and
+// instead the existing send's channel is returned. Returns nil if the queue is
+// shutting down or in maintenance mode.
+func (q *PersistentQueue) processEvent(e *Event) <-chan struct{} {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	// Events that arrive while shutting down remain pending in the database.
+	if q.stopping {
+		q.logger.Infof("Queue shutting down, %v will be sent on next start.", e.Key)
+		return nil
+	}
+
+	// Events that arrive, or are retried, during maintenance are left pending
+	// to be sent once it's disabled.
+	if q.maintenance {
+		if e.Status != StatusPending {
+			e.Status = StatusPending
+			if err := e.UpdateAt(q.Store, q.clock.Now()); err != nil {
+				q.logger.Errorf("Failed to mark %v pending: %v", e.Key, err)
+			}
+		}
+		q.logger.Infof("Maintenance mode enabled, %v will be sent once it is disabled.", e.Key)
+		return nil
+	}
+
+	key := e.Key
+	q.sendingMu.Lock()
+	if done, ok := q.sending[key]; ok {
+		q.sendingMu.Unlock()
+		q.logger.Infof("Event %v is already being sent.", key)
+		return done
+	}
+	if done, ok := q.held[key]; ok {
+		q.sendingMu.Unlock()
+		return done
+	}
+	orderKey, trigger := orderingKey(e.Event)
+	if e.Expired(q.clock.Now()) {
+		q.sendingMu.Unlock()
+		q.expire(e)
+		if trigger {
+			q.sendingMu.Lock()
+			q.triggerSettled(orderKey)
+			q.sendingMu.Unlock()
+		}
+
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	if !trigger && orderKey != "" {
+		pending, err := q.triggerPending(e, orderKey)
+		if err != nil {
+			q.logger.Errorf("Failed to check for a trigger before sending %v: %v", e.Key, err)
+		}
+		if pending {
+			defer q.sendingMu.Unlock()
+			return q.sendAfterTrigger(e, orderKey)
+		}
+	}
+	done := make(chan struct{})
+	q.sending[key] = done
+	q.sendingMu.Unlock()
+
+	// Marking in-flight first, so should we crash before recording a response
+	// the event is resent on the next start.
+	e.Status = StatusInFlight
+	if err := e.UpdateAt(q.Store, q.clock.Now()); err != nil {
+		q.logger.Errorf("Failed to mark %v in-flight: %v", e.Key, err)
+	}
+
 	q.wg.Add(1)
 	respChan := make(chan eventqueue.Response)
 
 	// Ignoring error -- currently only occurs if event fails validation, which
 	// we check in Enqueue.
 	q.logger.Infof("Enqueuing %v with EventQueue.", e.Key)
+	started := q.clock.Now()
+	if parent := tracing.ContextWithTraceparent(context.Background(), e.Event.TraceParent); trace.SpanContextFromContext(parent).IsValid() && e.Attempts == 0 {
+		_, span := tracing.Tracer().Start(parent, "pdagent.queue_wait",
+			trace.WithTimestamp(e.CreatedAt), trace.WithAttributes(attribute.String("pdagent.event_key", e.Key)))
+		span.End(trace.WithTimestamp(started))
+	}
+	q.metrics.sendStarted()
 	_ = q.EventQueue.Enqueue(e.Event, respChan)
 
 	go func() {
 		q.logger.Debugf("Waiting for response for %v.", e.Key)
 		resp := <-respChan
 		q.logger.Debugf("Received response for %v.", e.Key)
+		now := q.clock.Now()
+		q.metrics.sendFinished(started, now, resp.Error == nil)
+		q.observeFailures(resp, now)
 
-		if resp.Error != nil {
-			e.Status = StatusError
-			q.logger.Infof("EventQueue returned error for %v: %v, %+v", e.Key, resp.Error, resp.Response)
+		if errors.Is(resp.Error, context.Canceled) {
+			// Not a failure of the event itself, so it's resent on the next
+			// start rather than dead-lettered.
+			e.Status = StatusPending
+			q.logger.Infow("Send cancelled, event will be resent.", eventLogFields(e, resp)...)
+		} else if resp.Error != nil {
+			e.Attempts++
+			if !q.retryLater(e, resp) {
+				e.Status = StatusError
+				q.logger.Infow("Failed to send event.", eventLogFields(e, resp)...)
+
+				if err := q.deadLetter(e, resp); err != nil {
+					q.logger.Errorf("Failed to dead letter %v: %v", e.Key, err)
+				} else {
+					q.metrics.incDeadLettered(e, now)
+				}
+				q.audit(AuditDeadLettered, e, resp)
+			}
 		} else {
+			e.Attempts++
 			e.Status = StatusSuccess
-			q.logger.Infof("EventQueue returned success for %v. ", e.Key)
+			e.DedupKey = responseDedupKey(resp.Response)
+			q.logger.Infow("Sent event.", eventLogFields(e, resp)...)
+			q.metrics.observeDelivered(e, now)
+
+			if err := q.clearDeadLetter(e); err != nil {
+				q.logger.Errorf("Failed to clear dead letter for %v: %v", e.Key, err)
+			}
+			q.audit(AuditDelivered, e, resp)
 		}
 
-		err := e.Update(q.Events)
+		err := e.UpdateAt(q.Store, now)
 		if err != nil {
 			q.logger.Error(err)
 		}
 		q.logger.Infof("Set status of %v to %v.", e.Key, e.Status)
+
+		// Only once the delivery is recorded, so a failing hook can't undo it.
+		if e.Status == StatusSuccess {
+			q.runSuccessHooks(e, resp.Response)
+			q.scheduleAutoResolve(e, resp.Response)
+		}
+
+		q.sendingMu.Lock()
+		delete(q.sending, key)
+		// Follow-ups keep waiting while the trigger is to be resent.
+		if trigger && (e.Status == StatusSuccess || e.Status == StatusError) {
+			q.triggerSettled(orderKey)
+		}
+		q.sendingMu.Unlock()
+
+		// Only once no longer sending, so an early retry isn't skipped as
+		// already in progress.
+		if e.Status == StatusScheduled {
+			q.armScheduled(e)
+		}
+		close(done)
+
 		q.wg.Done()
 	}()
+
+	return done
+}
+
+// eventLogFields returns consistent structured logging fields for an event's
+// send response.
+func eventLogFields(e *Event, resp eventqueue.Response) []interface{} {
+	fields := []interface{}{
+		common.LogFieldEventID, e.Key,
+		common.LogFieldRoutingKey, common.MaskSecret(e.RoutingKey),
+	}
+
+	if resp.Response != nil {
+		if httpResp := resp.Response.GetHTTPResponse(); httpResp != nil {
+			fields = append(fields, common.LogFieldHTTPStatus, httpResp.StatusCode)
+		}
+	}
+
+	if resp.Error != nil {
+		fields = append(fields, "error", resp.Error.Error(), "retryable", isRetryable(resp.Error))
+	}
+
+	return fields
+}
+
+func (q *PersistentQueue) isStopping() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.stopping
+}
+
+// isRetryable returns true if an error is one sending the event again may fix.
+func isRetryable(err error) bool {
+	var retryable *eventsapi.RetryableError
+	return errors.As(err, &retryable)
 }
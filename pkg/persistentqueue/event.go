@@ -5,13 +5,18 @@ import (
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
-	"github.com/asdine/storm"
 )
 
+// Events move from pending to in-flight once handed to the event queue, then
+// to success or error once a response is recorded.
 const StatusPending = "pending"
+const StatusInFlight = "in_flight"
 const StatusError = "error"
 const StatusSuccess = "success"
 
+// undeliveredStatuses are those of events not yet confirmed sent or failed.
+var undeliveredStatuses = []string{StatusPending, StatusInFlight}
+
 // Event represents an queued or processed event.
 type Event struct {
 	ID           int    `storm:"id,increment"`
@@ -20,11 +25,28 @@ type Event struct {
 	Status       string `storm:"index"`
 	Event        *eventsapi.EventContainer
 	ResponseBody []byte
+	Attempts     int
 	CreatedAt    time.Time `storm:"index"`
 	UpdatedAt    time.Time `storm:"index"`
+
+	// ExpiresAt is when the event is dead-lettered rather than sent, or zero
+	// if it never expires.
+	ExpiresAt time.Time
+
+	// SendAt is when a scheduled event, such as an auto-resolve, is sent.
+	SendAt time.Time
+
+	// DedupKey is the dedup (or incident) key PagerDuty returned once the
+	// event was delivered.
+	DedupKey string
 }
 
 func NewEvent(eventContainer *eventsapi.EventContainer) (*Event, error) {
+	return NewEventAt(eventContainer, time.Now())
+}
+
+// NewEventAt returns a new event as `NewEvent` does, as of `now`.
+func NewEventAt(eventContainer *eventsapi.EventContainer, now time.Time) (*Event, error) {
 	event, err := eventContainer.UnmarshalEvent()
 	if err != nil {
 		return nil, err
@@ -35,30 +57,34 @@ func NewEvent(eventContainer *eventsapi.EventContainer) (*Event, error) {
 		RoutingKey: event.GetRoutingKey(),
 		Status:     StatusPending,
 		Event:      eventContainer,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
 	}, nil
 }
 
 // Create an event within the specified Queue.
 //
 // Main convenience is ensuring that CreatedAt and UpdatedAt are set.
-func (e *Event) Create(db storm.Node) error {
-	e.CreatedAt = time.Now()
-	e.UpdatedAt = e.CreatedAt
-	return db.Save(e)
+func (e *Event) Create(store Store) error {
+	return e.CreateAt(store, time.Now())
+}
+
+// CreateAt creates an event as `Create` does, as of `now`.
+func (e *Event) CreateAt(store Store, now time.Time) error {
+	e.CreatedAt = now
+	e.UpdatedAt = now
+	return store.CreateEvent(e)
 }
 
 // Update an event within the specified Queue.
 //
 // Main convenience is ensuring that UpdatedAt is updated.
-func (e *Event) Update(db storm.Node) error {
-	e.UpdatedAt = time.Now()
-	return db.Update(e)
+func (e *Event) Update(store Store) error {
+	return e.UpdateAt(store, time.Now())
 }
 
-func FindEventByKey(db storm.Node, key string) (*Event, error) {
-	var event Event
-	err := db.One("Key", key, &event)
-	return &event, err
+// UpdateAt updates an event as `Update` does, as of `now`.
+func (e *Event) UpdateAt(store Store, now time.Time) error {
+	e.UpdatedAt = now
+	return store.UpdateEvent(e)
 }
@@ -4,19 +4,17 @@ import (
 	"testing"
 
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
-	"github.com/asdine/storm"
 )
 
 func TestEvent(t *testing.T) {
 	setup(t)
 	defer teardown(t)
 
-	db, err := storm.Open(tmpDbFile)
+	db, err := openBoltStore(tmpDbFile, dbLockTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	eventsDb := db.From("events")
+	defer db.Close()
 
 	eventContainer := eventsapi.EventContainer{
 		EventVersion: eventsapi.EventVersion2,
@@ -38,11 +36,11 @@ func TestEvent(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err = event.Create(eventsDb); err != nil {
+	if err = event.Create(db); err != nil {
 		t.Fatal(err)
 	}
 
-	retrievedEvent, err := FindEventByKey(eventsDb, event.Key)
+	retrievedEvent, err := db.FindEventByKey(event.Key)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -53,11 +51,11 @@ func TestEvent(t *testing.T) {
 
 	event.Status = StatusSuccess
 
-	if err = event.Update(eventsDb); err != nil {
+	if err = event.Update(db); err != nil {
 		t.Fatal(err)
 	}
 
-	retrievedEvent, err = FindEventByKey(eventsDb, event.Key)
+	retrievedEvent, err = db.FindEventByKey(event.Key)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,89 @@
+package persistentqueue
+
+import (
+	"errors"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// ErrEventExpired is recorded as the dead letter error for events that
+// outlived their TTL before being sent.
+var ErrEventExpired = errors.New("event expired before it could be sent")
+
+// WithEventTTL is an option expiring events not sent within `ttl` of being
+// enqueued, e.g. after the agent has been offline, so a long since recovered
+// problem doesn't page someone. Expired events are dead-lettered instead.
+//
+// Resolves use `resolveTTL` instead, as a late resolve is usually still
+// wanted. A zero TTL never expires events; a TTL given with an event always
+// takes precedence.
+func WithEventTTL(ttl, resolveTTL time.Duration) Option {
+	return func(q *PersistentQueue) {
+		q.eventTTL = ttl
+		q.resolveEventTTL = resolveTTL
+	}
+}
+
+// expiresAt returns when an event enqueued at `now` expires, or zero if it
+// never does.
+func (q *PersistentQueue) expiresAt(eventContainer *eventsapi.EventContainer, event eventsapi.Event, now time.Time) time.Time {
+	ttl := eventContainer.TTL
+	if ttl <= 0 {
+		if isResolve(event) {
+			ttl = q.resolveEventTTL
+		} else {
+			ttl = q.eventTTL
+		}
+	}
+
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// Expired returns true if the event has an expiry that has passed.
+func (e *Event) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// expire dead-letters an event that outlived its TTL rather than sending it.
+func (q *PersistentQueue) expire(e *Event) {
+	resp := eventqueue.Response{Error: ErrEventExpired}
+
+	e.Status = StatusError
+	q.logger.Infow("Expired event.", eventLogFields(e, resp)...)
+
+	if err := q.deadLetter(e, resp); err != nil {
+		q.logger.Errorf("Failed to dead letter %v: %v", e.Key, err)
+	} else {
+		q.metrics.incDeadLettered(e, q.clock.Now())
+	}
+	q.audit(AuditDeadLettered, e, resp)
+
+	if err := e.UpdateAt(q.Store, q.clock.Now()); err != nil {
+		q.logger.Error(err)
+	}
+}
+
+// clearExpiry stops an event from expiring.
+func (q *PersistentQueue) clearExpiry(e *Event) error {
+	if e.ExpiresAt.IsZero() {
+		return nil
+	}
+	e.ExpiresAt = time.Time{}
+	return q.Store.UpdateEvent(e)
+}
+
+func isResolve(event eventsapi.Event) bool {
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		return e.EventType == "resolve"
+	case *eventsapi.EventV2:
+		return e.EventAction == "resolve"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,152 @@
+package persistentqueue
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func ttlEventContainer(action string, ttl time.Duration) *eventsapi.EventContainer {
+	return &eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData: []byte(`
+			{
+				"routing_key":  "11863b592c824bfc8989d9cba76abcde",
+				"event_action": "` + action + `",
+				"dedup_key":    "disk-full",
+				"payload": {
+					"summary":  "PagerDuty Agent TTL Test",
+					"source":   "pdagent",
+					"severity": "error"
+				}
+			}
+		`),
+		TTL: ttl,
+	}
+}
+
+func TestPersistentQueueExpiredEvent(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", time.Nanosecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 0 {
+		t.Errorf("Expected expired event not to be sent, was sent %v times.", calls)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusError {
+		t.Errorf("Expected expired event status to be %v, was %v.", StatusError, event.Status)
+	}
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 {
+		t.Fatalf("Expected one dead letter, found %v.", len(deadLetters))
+	}
+	if deadLetters[0].Error != ErrEventExpired.Error() {
+		t.Errorf("Expected dead letter error %q, was %q.", ErrEventExpired, deadLetters[0].Error)
+	}
+
+	if err := q.RetryDeadLetter(deadLetters[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected retried dead letter to be sent once, was sent %v times.", calls)
+	}
+}
+
+func TestPersistentQueueFreshEvent(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithEventTTL(time.Hour, 0))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected fresh event to be sent once, was sent %v times.", calls)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusSuccess {
+		t.Errorf("Expected fresh event status to be %v, was %v.", StatusSuccess, event.Status)
+	}
+}
+
+func TestPersistentQueueExpiresAt(t *testing.T) {
+	q := NewPersistentQueue(WithEventTTL(time.Hour, 24*time.Hour))
+	now := time.Now()
+
+	cases := []struct {
+		name      string
+		container *eventsapi.EventContainer
+		event     eventsapi.Event
+		expected  time.Time
+	}{
+		{"trigger", &eventsapi.EventContainer{}, &eventsapi.EventV2{EventAction: "trigger"}, now.Add(time.Hour)},
+		{"v2 resolve", &eventsapi.EventContainer{}, &eventsapi.EventV2{EventAction: "resolve"}, now.Add(24 * time.Hour)},
+		{"v1 resolve", &eventsapi.EventContainer{}, &eventsapi.EventV1{EventType: "resolve"}, now.Add(24 * time.Hour)},
+		{"event ttl", &eventsapi.EventContainer{TTL: time.Minute}, &eventsapi.EventV2{EventAction: "resolve"}, now.Add(time.Minute)},
+	}
+
+	for _, c := range cases {
+		if got := q.expiresAt(c.container, c.event, now); !got.Equal(c.expected) {
+			t.Errorf("%v: expected expiry %v, was %v.", c.name, c.expected, got)
+		}
+	}
+
+	never := NewPersistentQueue()
+	if got := never.expiresAt(&eventsapi.EventContainer{}, &eventsapi.EventV2{}, now); !got.IsZero() {
+		t.Errorf("Expected no expiry without a TTL, was %v.", got)
+	}
+}
+
+func TestEventExpired(t *testing.T) {
+	now := time.Now()
+
+	if (&Event{}).Expired(now) {
+		t.Error("Expected event without an expiry not to expire.")
+	}
+	if !(&Event{ExpiresAt: now.Add(-time.Second)}).Expired(now) {
+		t.Error("Expected event past its expiry to be expired.")
+	}
+	if (&Event{ExpiresAt: now.Add(time.Second)}).Expired(now) {
+		t.Error("Expected event before its expiry not to be expired.")
+	}
+}
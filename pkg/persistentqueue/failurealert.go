@@ -0,0 +1,194 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+)
+
+// FailureAlert configures alerting locally should sends to PagerDuty keep
+// failing, see `WithFailureAlert`.
+type FailureAlert struct {
+	// Threshold is how many sends in a row must fail to reach PagerDuty to
+	// alert, as classified by `eventqueue.IsSendFailure`.
+	Threshold int
+
+	// Duration is how long sends must have been failing for to alert, from
+	// the first failure since the last success.
+	Duration time.Duration
+
+	// Path, if set, is a file each alert is appended to as a JSON line.
+	Path string
+
+	// Command, if set, is run for each alert, e.g. to notify by email or
+	// SMS. It's split on whitespace and run directly rather than through a
+	// shell, with the alert in the `PD_ALERT_MESSAGE`,
+	// `PD_CONSECUTIVE_FAILURES`, `PD_FAILING_SINCE`, and `PD_LAST_ERROR`
+	// environment variables, and killed after `DefaultExecHookTimeout`.
+	Command string
+}
+
+// FailureAlertEntry is a line of the failure alert file.
+type FailureAlertEntry struct {
+	Time                time.Time `json:"time"`
+	Message             string    `json:"message"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	FailingSince        time.Time `json:"failing_since"`
+	LastError           string    `json:"last_error"`
+}
+
+// failureAlerter tracks the failing sends toward a `FailureAlert`.
+type failureAlerter struct {
+	FailureAlert
+
+	mu           sync.Mutex
+	failures     int
+	failingSince time.Time
+	fired        bool
+}
+
+// WithFailureAlert is an option alerting locally, as a last resort, once
+// `Threshold` sends in a row have failed over at least `Duration`, so an
+// agent unable to reach PagerDuty doesn't go unnoticed.
+//
+// Each alert is logged as an error, appended to `Path`, and `Command` run,
+// once per outage: no further alert is raised until a send has succeeded and
+// sends start failing again. Events the command itself sends via the agent
+// can't raise another alert, but are unlikely to reach PagerDuty either. A
+// threshold of 0 disables this.
+func WithFailureAlert(alert FailureAlert) Option {
+	return func(q *PersistentQueue) {
+		if alert.Threshold > 0 {
+			q.failureAlert = &failureAlerter{FailureAlert: alert}
+		} else {
+			q.failureAlert = nil
+		}
+	}
+}
+
+// observe records a send's result at `now`, returning an alert should it be
+// raised, and whether it ended an outage already alerted on.
+func (a *failureAlerter) observe(sendErr error, now time.Time) (*FailureAlertEntry, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if sendErr == nil {
+		recovered := a.fired
+		a.failures = 0
+		a.failingSince = time.Time{}
+		a.fired = false
+		return nil, recovered
+	}
+
+	if a.failures == 0 {
+		a.failingSince = now
+	}
+	a.failures++
+	if a.fired || a.failures < a.Threshold || now.Sub(a.failingSince) < a.Duration {
+		return nil, false
+	}
+
+	a.fired = true
+	lastError := common.RedactSecrets(sendErr.Error())
+	return &FailureAlertEntry{
+		Time: now,
+		Message: fmt.Sprintf("PagerDuty Agent unable to send events: %v sends in a row failed since %v, last with: %v",
+			a.failures, a.failingSince.Format(time.RFC3339), lastError),
+		ConsecutiveFailures: a.failures,
+		FailingSince:        a.failingSince,
+		LastError:           lastError,
+	}, false
+}
+
+// observeFailures checks a send's response against any failure alert,
+// raising it once its threshold is reached.
+//
+// Only failures to reach PagerDuty count. Events it rejects, such as invalid
+// ones, and cancelled sends show nothing either way, so they neither count
+// nor end an outage.
+func (q *PersistentQueue) observeFailures(resp eventqueue.Response, now time.Time) {
+	if q.failureAlert == nil || (resp.Error != nil && !eventqueue.IsSendFailure(resp)) {
+		return
+	}
+
+	entry, recovered := q.failureAlert.observe(resp.Error, now)
+	if recovered {
+		q.logger.Warn("Sent an event to PagerDuty, recovering from the failures alerted on.")
+	}
+	if entry == nil {
+		return
+	}
+
+	q.logger.Errorw(entry.Message, "consecutive_failures", entry.ConsecutiveFailures, "failing_since", entry.FailingSince)
+
+	// In the background, so a slow command doesn't hold up recording sends.
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		if err := q.failureAlert.write(entry); err != nil {
+			q.logger.Errorf("Failed to write failure alert to %v: %v", q.failureAlert.Path, err)
+		}
+		if err := q.failureAlert.run(entry); err != nil {
+			q.logger.Errorf("Failure alert command failed: %v", err)
+		}
+	}()
+}
+
+// write appends an alert to the alert file, if any.
+func (a *failureAlerter) write(entry *FailureAlertEntry) error {
+	if a.Path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(a.Path), 0744); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(a.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// run runs the alert command, if any.
+func (a *failureAlerter) run(entry *FailureAlertEntry) error {
+	args := strings.Fields(a.Command)
+	if len(args) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultExecHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"PD_ALERT_MESSAGE="+entry.Message,
+		"PD_CONSECUTIVE_FAILURES="+strconv.Itoa(entry.ConsecutiveFailures),
+		"PD_FAILING_SINCE="+entry.FailingSince.Format(time.RFC3339),
+		"PD_LAST_ERROR="+entry.LastError,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %w: %s", args[0], err, bytes.TrimSpace(output))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+// readLines returns the non-empty lines of a file, or none if it doesn't
+// exist.
+func readLines(t *testing.T, file string) [][]byte {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+}
+
+// waitForLines waits for a file to have `n` lines, as alerts are written in
+// the background, failing should it not within a second.
+func waitForLines(t *testing.T, file string, n int) [][]byte {
+	deadline := time.Now().Add(time.Second)
+	for {
+		lines := readLines(t, file)
+		if len(lines) >= n || time.Now().After(deadline) {
+			return lines
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// sendAt enqueues an event once the clock has advanced by `advance`, with the
+// event queue responding `resp`, and waits for its send to be recorded.
+func sendAt(t *testing.T, q *PersistentQueue, eq *MockEventQueue, clock *test.FakeClock, resp eventqueue.Response, advance time.Duration) {
+	clock.Advance(advance)
+	eq.SetResponse(resp)
+	calls := len(eq.Sent())
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+		t.Fatal(err)
+	}
+	eq.WaitForCalls(t, calls+1)
+	if err := q.waitForSends(time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var failingResponse = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500, Err: errors.New("server error")}}
+
+func TestPersistentQueueFailureAlert(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	dir := path.Join(tmpDir, "failure-alert")
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	alertFile := path.Join(dir, "alerts.log")
+	commandLog := path.Join(dir, "command.log")
+	script := path.Join(dir, "alert.sh")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$PD_CONSECUTIVE_FAILURES\" >> "+commandLog+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := test.NewFakeClock(start)
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithClock(clock), WithFailureAlert(FailureAlert{
+		Threshold: 3,
+		Duration:  10 * time.Minute,
+		Path:      alertFile,
+		Command:   script,
+	}))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	failing := failingResponse
+	send := func(resp eventqueue.Response, advance time.Duration) {
+		sendAt(t, q, eq, clock, resp, advance)
+	}
+
+	// The threshold is reached before the duration, which the fourth failure
+	// reaches.
+	for i := 0; i < 3; i++ {
+		send(failing, time.Minute)
+	}
+	if lines := readLines(t, alertFile); len(lines) != 0 {
+		t.Fatalf("Expected no alert before failing for the duration, got %q.", lines)
+	}
+	send(failing, 10*time.Minute)
+
+	lines := waitForLines(t, alertFile, 1)
+	if len(lines) != 1 {
+		t.Fatalf("Expected an alert once failing for the duration, got %q.", lines)
+	}
+	var entry FailureAlertEntry
+	if err := json.Unmarshal(lines[0], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.ConsecutiveFailures != 4 || !entry.FailingSince.Equal(start.Add(time.Minute)) || entry.LastError != "server error (HTTP 500)" {
+		t.Errorf("Expected an alert for 4 failures since the first, got %+v.", entry)
+	}
+
+	// Further failures don't alert again.
+	send(failing, time.Hour)
+	send(failing, time.Hour)
+	if lines := readLines(t, alertFile); len(lines) != 1 {
+		t.Errorf("Expected a single alert while still failing, got %q.", lines)
+	}
+	if lines := waitForLines(t, commandLog, 1); len(lines) != 1 || string(lines[0]) != "4" {
+		t.Errorf("Expected the command to run once, got %q.", lines)
+	}
+
+	// Until a success, after which failing again alerts again.
+	send(eventqueue.Response{}, time.Minute)
+	for i := 0; i < 3; i++ {
+		send(failing, 5*time.Minute)
+	}
+	if lines := waitForLines(t, alertFile, 2); len(lines) != 2 {
+		t.Errorf("Expected a second alert once failing again after recovering, got %q.", lines)
+	}
+	if lines := waitForLines(t, commandLog, 2); len(lines) != 2 || string(lines[1]) != "3" {
+		t.Errorf("Expected the command to run again, got %q.", lines)
+	}
+}
+
+func TestPersistentQueueFailureAlertIgnoresRejections(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	alertFile := path.Join(tmpDir, "failure-alerts.log")
+	defer os.Remove(alertFile)
+
+	clock := test.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithClock(clock), WithFailureAlert(FailureAlert{
+		Threshold: 3,
+		Path:      alertFile,
+	}))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	// Rejections show PagerDuty is reachable, so they neither count toward
+	// the alert nor reset the failures before them.
+	rejected := eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}}
+	sendAt(t, q, eq, clock, failingResponse, time.Minute)
+	sendAt(t, q, eq, clock, failingResponse, time.Minute)
+	for i := 0; i < 3; i++ {
+		sendAt(t, q, eq, clock, rejected, time.Minute)
+	}
+	if lines := readLines(t, alertFile); len(lines) != 0 {
+		t.Fatalf("Expected rejections not to count toward the alert, got %q.", lines)
+	}
+
+	sendAt(t, q, eq, clock, failingResponse, time.Minute)
+	lines := waitForLines(t, alertFile, 1)
+	if len(lines) != 1 {
+		t.Fatalf("Expected an alert on the third failure, got %q.", lines)
+	}
+	var entry FailureAlertEntry
+	if err := json.Unmarshal(lines[0], &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.ConsecutiveFailures != 3 {
+		t.Errorf("Expected an alert for 3 failures, got %+v.", entry)
+	}
+}
+
+func TestPersistentQueueFailureAlertDisabled(t *testing.T) {
+	q := NewPersistentQueue(WithFailureAlert(FailureAlert{Path: "alerts.log"}))
+	if q.failureAlert != nil {
+		t.Error("Expected no failure alert without a threshold.")
+	}
+}
@@ -0,0 +1,69 @@
+package persistentqueue
+
+import "time"
+
+// FlushResult summarizes the outcome of a `Flush`.
+type FlushResult struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Remaining int `json:"remaining"`
+}
+
+// Flush immediately sends all pending events, blocking until they complete or
+// the timeout elapses.
+//
+// Events already being sent aren't sent again, so concurrent flushes wait on
+// the same sends. Events not completed within the timeout are counted as
+// remaining and continue to be sent in the background.
+func (q *PersistentQueue) Flush(timeout time.Duration) (FlushResult, error) {
+	var result FlushResult
+
+	pendingEvents, err := q.Store.FindEvents(EventQuery{Statuses: undeliveredStatuses})
+	if err != nil {
+		return result, err
+	}
+
+	q.logger.Infof("Flushing %v pending events.", len(pendingEvents))
+
+	sends := make([]<-chan struct{}, len(pendingEvents))
+	for i := range pendingEvents {
+		sends[i] = q.processEvent(&pendingEvents[i])
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	timedOut := false
+	for i, done := range sends {
+		if done == nil {
+			result.Remaining++
+			continue
+		}
+
+		if !timedOut {
+			select {
+			case <-done:
+			case <-deadline.C:
+				timedOut = true
+			}
+		}
+
+		// Reading the recorded status, as a concurrent send may have updated a
+		// different copy of the event.
+		e, err := q.Store.FindEventByKey(pendingEvents[i].Key)
+		if err != nil {
+			return result, err
+		}
+
+		switch e.Status {
+		case StatusSuccess:
+			result.Succeeded++
+		case StatusError:
+			result.Failed++
+		default:
+			result.Remaining++
+		}
+	}
+
+	return result, nil
+}
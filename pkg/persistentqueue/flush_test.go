@@ -0,0 +1,122 @@
+package persistentqueue
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+// createPendingEvents stores pending events without sending them, as if left
+// over from maintenance or a previous run.
+func createPendingEvents(t *testing.T, q *PersistentQueue, count int) {
+	for i := 0; i < count; i++ {
+		eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+		e, err := NewEvent(&eventContainer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Create(q.Store); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPersistentQueueFlush(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Delay = 50 * time.Millisecond
+
+	q := NewPersistentQueue(WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	createPendingEvents(t, q, 5)
+
+	result, err := q.Flush(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (FlushResult{Succeeded: 5}) {
+		t.Errorf("Expected 5 events to succeed, was %+v.", result)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 5 {
+		t.Errorf("Expected 5 events to be sent, was %v.", calls)
+	}
+
+	m, err := q.Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Pending != 0 {
+		t.Errorf("Expected no pending events after flush, was %v.", m.Pending)
+	}
+}
+
+func TestPersistentQueueFlushConcurrent(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Delay = 200 * time.Millisecond
+
+	q := NewPersistentQueue(WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	createPendingEvents(t, q, 5)
+
+	var wg sync.WaitGroup
+	results := make([]FlushResult, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = q.Flush(5 * time.Second)
+		}(i)
+	}
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 5 {
+		t.Errorf("Expected each event to be sent once, was %v sends.", calls)
+	}
+	for _, result := range results {
+		if result.Failed != 0 {
+			t.Errorf("Expected no failures, was %+v.", result)
+		}
+	}
+}
+
+func TestPersistentQueueFlushTimeout(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Delay = time.Second
+
+	q := NewPersistentQueue(WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	createPendingEvents(t, q, 3)
+
+	result, err := q.Flush(100 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (FlushResult{Remaining: 3}) {
+		t.Errorf("Expected 3 events to remain, was %+v.", result)
+	}
+}
@@ -0,0 +1,42 @@
+package persistentqueue
+
+import "time"
+
+// Health summarizes whether the queue is making progress delivering events.
+//
+// `OldestPending` and `LastSuccess` are zero if there are no pending events or
+// no events have been delivered since the agent started, respectively.
+type Health struct {
+	Pending             int
+	OldestPending       time.Time
+	ConsecutiveFailures int
+	LastSuccess         time.Time
+	Maintenance         bool
+}
+
+// Health returns a snapshot of the queue's health.
+func (q *PersistentQueue) Health() (Health, error) {
+	pending, err := q.Store.FindEvents(EventQuery{Statuses: undeliveredStatuses})
+	if err != nil {
+		return Health{}, err
+	}
+
+	maintenance := q.Maintenance()
+
+	q.metrics.mu.Lock()
+	defer q.metrics.mu.Unlock()
+
+	health := Health{
+		Pending:             len(pending),
+		ConsecutiveFailures: q.metrics.consecutiveFailures,
+		LastSuccess:         q.metrics.lastSuccess,
+		Maintenance:         maintenance,
+	}
+	for _, e := range pending {
+		if health.OldestPending.IsZero() || e.CreatedAt.Before(health.OldestPending) {
+			health.OldestPending = e.CreatedAt
+		}
+	}
+
+	return health, nil
+}
@@ -3,7 +3,10 @@ package persistentqueue
 import (
 	"os"
 	"path"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
@@ -16,36 +19,88 @@ const tmpDir = "../../tmp"
 
 var tmpDbFile = path.Join(tmpDir, "test.db")
 
+// MockEventQueue responds to every event with `Response` after `Delay`.
+//
+// Once sends may have started, change the response with `SetResponse`.
 type MockEventQueue struct {
-	logger *zap.SugaredLogger
+	Response eventqueue.Response
+	Delay    time.Duration
+	Calls    int32
+
+	logger  *zap.SugaredLogger
+	mu      sync.Mutex
+	sent    []*eventsapi.EventContainer
+	changed chan struct{}
 }
 
 func NewMockEventQueue() *MockEventQueue {
 	return &MockEventQueue{
-		logger: common.Logger.Named("MockEventQueue"),
+		logger:  common.Logger.Named("MockEventQueue"),
+		changed: make(chan struct{}),
 	}
 }
 
+// SetResponse replaces the response to events enqueued from now on.
+func (q *MockEventQueue) SetResponse(resp eventqueue.Response) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.Response = resp
+}
+
 func (q *MockEventQueue) Shutdown() {
 	q.logger.Debug("Shutdown called.")
 }
 
-func (q *MockEventQueue) Enqueue(_ *eventsapi.EventContainer, c chan<- eventqueue.Response) error {
+func (q *MockEventQueue) Enqueue(eventContainer *eventsapi.EventContainer, c chan<- eventqueue.Response) error {
 	q.logger.Debug("Enqueue called.")
+	atomic.AddInt32(&q.Calls, 1)
+	q.mu.Lock()
+	q.sent = append(q.sent, eventContainer)
+	resp := q.Response
+	close(q.changed)
+	q.changed = make(chan struct{})
+	q.mu.Unlock()
 	go func() {
+		time.Sleep(q.Delay)
 		q.logger.Debug("Response sent called.")
-		c <- eventqueue.Response{}
+		c <- resp
 	}()
 
 	q.logger.Debug("Enqueue returning.")
 	return nil
 }
 
+// Sent returns the events enqueued so far, in order.
+func (q *MockEventQueue) Sent() []*eventsapi.EventContainer {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]*eventsapi.EventContainer(nil), q.sent...)
+}
+
+// WaitForCalls blocks until at least `n` events have been enqueued, failing
+// the test should that take longer than a second.
+func (q *MockEventQueue) WaitForCalls(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		q.mu.Lock()
+		sent, changed := len(q.sent), q.changed
+		q.mu.Unlock()
+		if sent >= n {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("Expected %v events to be enqueued, got %v.", n, sent)
+		}
+	}
+}
+
 // Clean up any existing tmp directory contents and create if necessary.
 func setup(t *testing.T) {
-	if err := os.RemoveAll(tmpDbFile); err != nil {
-		t.Fatal(err)
-	}
+	removeDbFiles(t)
 
 	if err := os.Mkdir(tmpDir, 0777); err != nil && !os.IsExist(err) {
 		t.Fatal(err)
@@ -56,7 +111,14 @@ func setup(t *testing.T) {
 //
 // Useful to comment out when troubleshooting DB issues.
 func teardown(t *testing.T) {
-	if err := os.RemoveAll(tmpDbFile); err != nil {
-		t.Fatal(err)
+	removeDbFiles(t)
+}
+
+// removeDbFiles removes the database, along with any SQLite write-ahead log.
+func removeDbFiles(t *testing.T) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.RemoveAll(tmpDbFile + suffix); err != nil {
+			t.Fatal(err)
+		}
 	}
 }
@@ -0,0 +1,130 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// DefaultExecHookTimeout limits how long an `ExecHook` command may run.
+const DefaultExecHookTimeout = 10 * time.Second
+
+// SuccessHook is invoked once an event is confirmed delivered, with the dedup
+// (or incident) key PagerDuty returned.
+//
+// Hooks run after the event is marked successful, so a failing hook is logged
+// but never causes the event to be resent or dead-lettered.
+type SuccessHook interface {
+	OnSuccess(e *Event, dedupKey string) error
+}
+
+// WithSuccessHook is an option adding a hook run after each delivered event.
+func WithSuccessHook(hook SuccessHook) Option {
+	return func(q *PersistentQueue) {
+		q.successHooks = append(q.successHooks, hook)
+	}
+}
+
+// runSuccessHooks invokes each success hook for a delivered event.
+func (q *PersistentQueue) runSuccessHooks(e *Event, resp eventsapi.Response) {
+	dedupKey := responseDedupKey(resp)
+	for _, hook := range q.successHooks {
+		if err := hook.OnSuccess(e, dedupKey); err != nil {
+			q.logger.Warnf("Success hook failed for %v: %v", e.Key, err)
+		}
+	}
+}
+
+// responseDedupKey returns the dedup or incident key from a response, if any.
+func responseDedupKey(resp eventsapi.Response) string {
+	switch r := resp.(type) {
+	case *eventsapi.ResponseV1:
+		return r.IncidentKey
+	case *eventsapi.ResponseV2:
+		return r.DedupKey
+	default:
+		return ""
+	}
+}
+
+// ExecHook is a `SuccessHook` running a command, e.g. to write to a local
+// audit log.
+//
+// The command is split on whitespace and each argument rendered as a
+// template with `.DedupKey`, `.EventID`, and `.RoutingKey`. Arguments are
+// passed directly rather than through a shell, so keys can't inject further
+// commands. The same values are set in the `PD_DEDUP_KEY`, `PD_EVENT_ID`,
+// and `PD_ROUTING_KEY` environment variables.
+type ExecHook struct {
+	Timeout time.Duration
+
+	args []*template.Template
+}
+
+// ExecHookData is the data `ExecHook` command templates are rendered with.
+type ExecHookData struct {
+	DedupKey   string
+	EventID    string
+	RoutingKey string
+}
+
+// NewExecHook parses a command template, returning an error if it's empty or
+// invalid.
+func NewExecHook(command string) (*ExecHook, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("empty success hook command")
+	}
+
+	hook := ExecHook{Timeout: DefaultExecHookTimeout}
+	for i, field := range fields {
+		tmpl, err := template.New(fmt.Sprintf("arg%v", i)).Option("missingkey=error").Parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid success hook command: %w", err)
+		}
+		hook.args = append(hook.args, tmpl)
+	}
+
+	return &hook, nil
+}
+
+// OnSuccess runs the hook's command for a delivered event.
+func (h *ExecHook) OnSuccess(e *Event, dedupKey string) error {
+	data := ExecHookData{
+		DedupKey:   dedupKey,
+		EventID:    e.Key,
+		RoutingKey: e.RoutingKey,
+	}
+
+	args := make([]string, len(h.args))
+	for i, tmpl := range h.args {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return err
+		}
+		args[i] = buf.String()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"PD_DEDUP_KEY="+data.DedupKey,
+		"PD_EVENT_ID="+data.EventID,
+		"PD_ROUTING_KEY="+data.RoutingKey,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %w: %s", args[0], err, bytes.TrimSpace(output))
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package persistentqueue
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+type recordingHook struct {
+	mu        sync.Mutex
+	eventIDs  []string
+	dedupKeys []string
+	err       error
+}
+
+func (h *recordingHook) OnSuccess(e *Event, dedupKey string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.eventIDs = append(h.eventIDs, e.Key)
+	h.dedupKeys = append(h.dedupKeys, dedupKey)
+	return h.err
+}
+
+func successResponse(dedupKey string) eventqueue.Response {
+	return eventqueue.Response{
+		Response: &eventsapi.ResponseV2{Status: "success", DedupKey: dedupKey},
+	}
+}
+
+func TestPersistentQueueSuccessHook(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = successResponse("srv01/disk")
+
+	hook := &recordingHook{}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithSuccessHook(hook))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+
+	if len(hook.eventIDs) != 1 {
+		t.Fatalf("Expected hook to be called once, was called %v times.", len(hook.eventIDs))
+	}
+	if hook.eventIDs[0] != key {
+		t.Errorf("Expected hook for %v, was %v.", key, hook.eventIDs[0])
+	}
+	if hook.dedupKeys[0] != "srv01/disk" {
+		t.Errorf("Expected hook to receive dedup key srv01/disk, was %q.", hook.dedupKeys[0])
+	}
+}
+
+func TestPersistentQueueFailingSuccessHook(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = successResponse("srv01/disk")
+
+	hook := &recordingHook{err: errors.New("audit log unavailable")}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithSuccessHook(hook))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected event to be sent once despite the failing hook, was sent %v times.", calls)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusSuccess {
+		t.Errorf("Expected event status to remain %v, was %v.", StatusSuccess, event.Status)
+	}
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 0 {
+		t.Errorf("Expected no dead letters, found %v.", len(deadLetters))
+	}
+}
+
+func TestPersistentQueueFailedSendSkipsHook(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}}
+
+	hook := &recordingHook{}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithSuccessHook(hook))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if len(hook.eventIDs) != 0 {
+		t.Errorf("Expected hook not to be called for a failed send, was called %v times.", len(hook.eventIDs))
+	}
+}
+
+func TestExecHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pdagent-hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hook, err := NewExecHook("touch " + dir + "/{{.DedupKey}}-{{.EventID}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hook.OnSuccess(&Event{Key: "abc123"}, "disk"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(dir, "disk-abc123")); err != nil {
+		t.Errorf("Expected hook command to create its file: %v", err)
+	}
+}
+
+func TestExecHookFailure(t *testing.T) {
+	hook, err := NewExecHook("false")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hook.OnSuccess(&Event{Key: "abc123"}, "disk"); err == nil {
+		t.Error("Expected error from a failing command.")
+	}
+}
+
+func TestNewExecHookInvalid(t *testing.T) {
+	if _, err := NewExecHook("  "); err == nil {
+		t.Error("Expected error for an empty command.")
+	}
+	if _, err := NewExecHook("echo {{.DedupKey"); err == nil {
+		t.Error("Expected error for an invalid template.")
+	}
+}
@@ -0,0 +1,23 @@
+package persistentqueue
+
+// ListOptions filters the events returned by `List`.
+//
+// Empty fields match all events, and a zero `Limit` returns every match. A
+// pending `Status` also matches events in-flight, as neither are delivered.
+type ListOptions struct {
+	Status     string
+	RoutingKey string
+	Limit      int
+}
+
+// List returns queued events in the order they were enqueued.
+func (q *PersistentQueue) List(options ListOptions) ([]Event, error) {
+	query := EventQuery{RoutingKey: options.RoutingKey, Limit: options.Limit}
+	if options.Status == StatusPending {
+		query.Statuses = undeliveredStatuses
+	} else if options.Status != "" {
+		query.Statuses = []string{options.Status}
+	}
+
+	return q.Store.FindEvents(query)
+}
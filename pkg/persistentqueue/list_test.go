@@ -0,0 +1,65 @@
+package persistentqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestPersistentQueueList(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: errors.New("server error")}
+
+	q := NewPersistentQueue(WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	key1 := "11863b592c824bfc8989d9cba76abcde"
+	key2 := "22863b592c824bfc8989d9cba76abcde"
+	for _, key := range []string{key1, key2, key1} {
+		event := test.BuildV2EventContainer(key)
+		if _, err := q.Enqueue(&event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	tests := []struct {
+		name     string
+		options  ListOptions
+		expected int
+	}{
+		{"all", ListOptions{}, 3},
+		{"status", ListOptions{Status: StatusError}, 3},
+		{"noMatchingStatus", ListOptions{Status: StatusPending}, 0},
+		{"routingKey", ListOptions{RoutingKey: key1}, 2},
+		{"limit", ListOptions{Limit: 1}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := q.List(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(events) != tt.expected {
+				t.Errorf("Expected %v events, found %v.", tt.expected, len(events))
+			}
+			for _, e := range events {
+				if e.Attempts != 1 {
+					t.Errorf("Expected one attempt for %v, was %v.", e.Key, e.Attempts)
+				}
+			}
+		})
+	}
+}
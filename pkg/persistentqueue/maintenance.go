@@ -0,0 +1,58 @@
+package persistentqueue
+
+const (
+	settingsBucket = "settings"
+	maintenanceKey = "maintenance"
+)
+
+// WithMaintenance is an option starting the queue in maintenance mode,
+// regardless of the persisted state.
+func WithMaintenance(enabled bool) Option {
+	return func(q *PersistentQueue) {
+		q.forceMaintenance = enabled
+	}
+}
+
+// Maintenance returns true if the queue is in maintenance mode.
+func (q *PersistentQueue) Maintenance() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.maintenance
+}
+
+// SetMaintenance toggles maintenance mode, persisting it across restarts.
+//
+// While in maintenance mode events are still accepted and stored, but not
+// sent. Disabling maintenance mode sends all pending events.
+func (q *PersistentQueue) SetMaintenance(enabled bool) error {
+	if err := q.Store.SetSetting(maintenanceKey, enabled); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.maintenance = enabled
+	q.mu.Unlock()
+
+	if enabled {
+		q.logger.Info("Maintenance mode enabled, events will be queued but not sent.")
+		return nil
+	}
+
+	q.logger.Info("Maintenance mode disabled.")
+	return q.sendPending()
+}
+
+// loadMaintenance restores the persisted maintenance mode, or enables it if
+// the queue was configured to start in maintenance mode.
+func (q *PersistentQueue) loadMaintenance() error {
+	if q.forceMaintenance {
+		q.maintenance = true
+		return q.Store.SetSetting(maintenanceKey, true)
+	}
+
+	err := q.Store.GetSetting(maintenanceKey, &q.maintenance)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package persistentqueue
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestPersistentQueueMaintenance(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+
+	const eventCount = 3
+	for i := 0; i < eventCount; i++ {
+		eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+		if _, err := q.Enqueue(&eventContainer); err != nil {
+			t.Fatalf("Expected enqueue to succeed during maintenance, got %v.", err)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 0 {
+		t.Errorf("Expected no events to be sent during maintenance, %v were sent.", calls)
+	}
+
+	health, err := q.Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Pending != eventCount || !health.Maintenance {
+		t.Errorf("Expected %v pending events in maintenance, was %v with maintenance %v.", eventCount, health.Pending, health.Maintenance)
+	}
+
+	result, err := q.Flush(50 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Remaining != eventCount {
+		t.Errorf("Expected flush to leave events remaining during maintenance, result was %+v.", result)
+	}
+
+	if err := q.SetMaintenance(false); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		health, err = q.Health()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if health.Pending == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if health.Pending != 0 {
+		t.Errorf("Expected backlog to drain after maintenance, %v events pending.", health.Pending)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != eventCount {
+		t.Errorf("Expected %v events to be sent after maintenance, %v were sent.", eventCount, calls)
+	}
+}
+
+func TestPersistentQueueMaintenancePersists(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	key, err := q.Enqueue(&eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	eq := NewMockEventQueue()
+	restarted := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := restarted.Start(); err != nil {
+		t.Fatal("Error restarting persistent queue.")
+	}
+	defer restarted.Shutdown()
+
+	if !restarted.Maintenance() {
+		t.Fatal("Expected maintenance mode to persist across restarts.")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 0 {
+		t.Errorf("Expected no events to be sent on start in maintenance, %v were sent.", calls)
+	}
+
+	persistedEvent, err := restarted.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal("Could not find persisted event after restart.")
+	}
+	if persistedEvent.Status != StatusPending {
+		t.Errorf("Expected event to remain pending, status was %v.", persistedEvent.Status)
+	}
+}
+
+func TestPersistentQueueWithMaintenance(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq), WithMaintenance(true))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	if !q.Maintenance() {
+		t.Error("Expected queue to start in maintenance mode.")
+	}
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	if _, err := q.Enqueue(&eventContainer); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 0 {
+		t.Errorf("Expected no events to be sent in maintenance, %v were sent.", calls)
+	}
+}
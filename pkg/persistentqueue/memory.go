@@ -0,0 +1,13 @@
+package persistentqueue
+
+// WithMemory is an option keeping the queue in memory rather than on disk,
+// e.g. for containers without a persistent or writable filesystem.
+//
+// Queue behavior is unchanged except that undelivered events, dead letters,
+// and maintenance mode are lost on restart.
+func WithMemory() Option {
+	return func(q *PersistentQueue) {
+		q.backend = BackendMemory
+		q.tmp = false
+	}
+}
@@ -0,0 +1,91 @@
+package persistentqueue
+
+import (
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/test"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPersistentQueueMemoryWarning(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	q := NewPersistentQueue(WithMemory(), WithEventQueue(NewMockEventQueue()))
+	q.logger = zap.New(core).Sugar()
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	warnings := logs.FilterMessageSnippet("lost on restart").All()
+	if len(warnings) != 1 {
+		t.Errorf("Expected a warning that events are lost on restart, got %v.", logs.All())
+	}
+}
+
+func TestPersistentQueueMemoryLostOnRestart(t *testing.T) {
+	q := NewPersistentQueue(WithMemory(), WithEventQueue(NewMockEventQueue()), WithMaintenance(true))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	if _, err := q.Enqueue(&eventContainer); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	q = NewPersistentQueue(WithMemory(), WithEventQueue(NewMockEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	health, err := q.Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Pending != 0 || health.Maintenance {
+		t.Errorf("Expected a fresh queue after restart, was %v pending with maintenance %v.", health.Pending, health.Maintenance)
+	}
+}
+
+// As with the durable stores, events are only changed in memory once updated.
+func TestMemoryStoreCopiesEvents(t *testing.T) {
+	store := newMemoryStore()
+	defer store.Close()
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	e, err := NewEvent(&eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Create(store); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := store.FindEvent(e.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found.Status = StatusSuccess
+	found.Event.EventData[0] = ' '
+
+	stored, err := store.FindEvent(e.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != StatusPending || stored.Event.EventData[0] != '{' {
+		t.Errorf("Expected the stored event to be unchanged, was %v with %s.", stored.Status, stored.Event.EventData)
+	}
+
+	if err := found.Update(store); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err = store.FindEvent(e.ID); err != nil || stored.Status != StatusSuccess {
+		t.Errorf("Expected the update to be stored, was %v (%v).", stored.Status, err)
+	}
+}
@@ -0,0 +1,296 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// memoryStore is a `Store` held in memory, lost once closed.
+//
+// Events and dead letters are copied in and out, so as with the durable
+// stores changes are only kept once saved.
+type memoryStore struct {
+	mu sync.RWMutex
+
+	events       map[int]Event
+	nextEventID  int
+	deadLetters  map[int]DeadLetter
+	nextLetterID int
+	settings     map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		events:      make(map[int]Event),
+		deadLetters: make(map[int]DeadLetter),
+		settings:    make(map[string][]byte),
+	}
+}
+
+// copyEvent copies an event, including its payload and response.
+func copyEvent(e Event) Event {
+	if e.Event != nil {
+		eventContainer := *e.Event
+		eventContainer.EventData = append(json.RawMessage(nil), e.Event.EventData...)
+		e.Event = &eventContainer
+	}
+	e.ResponseBody = append([]byte(nil), e.ResponseBody...)
+	return e
+}
+
+// copyDeadLetter copies a dead letter, including its payload.
+func copyDeadLetter(d DeadLetter) DeadLetter {
+	if d.Event != nil {
+		eventContainer := *d.Event
+		eventContainer.EventData = append(json.RawMessage(nil), d.Event.EventData...)
+		d.Event = &eventContainer
+	}
+	return d
+}
+
+// eventIDs returns the IDs of stored events in order.
+func (s *memoryStore) eventIDs() []int {
+	ids := make([]int, 0, len(s.events))
+	for id := range s.events {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// deadLetterIDs returns the IDs of stored dead letters in order.
+func (s *memoryStore) deadLetterIDs() []int {
+	ids := make([]int, 0, len(s.deadLetters))
+	for id := range s.deadLetters {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *memoryStore) CreateEvent(e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.ID == 0 {
+		s.nextEventID++
+		e.ID = s.nextEventID
+	} else if e.ID > s.nextEventID {
+		s.nextEventID = e.ID
+	}
+	s.events[e.ID] = copyEvent(*e)
+	return nil
+}
+
+func (s *memoryStore) UpdateEvent(e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[e.ID]; !ok {
+		return ErrNotFound
+	}
+	s.events[e.ID] = copyEvent(*e)
+	return nil
+}
+
+func (s *memoryStore) DeleteEvent(e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[e.ID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.events, e.ID)
+	return nil
+}
+
+func (s *memoryStore) FindEvent(id int) (*Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.events[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	e = copyEvent(e)
+	return &e, nil
+}
+
+func (s *memoryStore) FindEventByKey(key string) (*Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.events {
+		if e.Key == key {
+			e = copyEvent(e)
+			return &e, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) FindEvents(query EventQuery) ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []Event
+	for _, id := range s.eventIDs() {
+		e := s.events[id]
+		if len(query.Statuses) > 0 && !hasStatus(e.Status, query.Statuses) {
+			continue
+		}
+		if query.RoutingKey != "" && e.RoutingKey != query.RoutingKey {
+			continue
+		}
+
+		events = append(events, copyEvent(e))
+		if query.Limit > 0 && len(events) == query.Limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+func hasStatus(status string, statuses []string) bool {
+	for _, s := range statuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) CountEvents(statuses ...string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, e := range s.events {
+		if hasStatus(e.Status, statuses) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryStore) DeadLetters(routingKey string) ([]DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deadLetters []DeadLetter
+	for _, id := range s.deadLetterIDs() {
+		if d := s.deadLetters[id]; routingKey == "" || d.RoutingKey == routingKey {
+			deadLetters = append(deadLetters, copyDeadLetter(d))
+		}
+	}
+	return deadLetters, nil
+}
+
+func (s *memoryStore) FindDeadLetter(id int) (*DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.deadLetters[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	d = copyDeadLetter(d)
+	return &d, nil
+}
+
+func (s *memoryStore) FindDeadLetterByEventKey(key string) (*DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, d := range s.deadLetters {
+		if d.EventKey == key {
+			d = copyDeadLetter(d)
+			return &d, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) SaveDeadLetter(d *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// As with the durable stores, an event has at most one dead letter.
+	for id, existing := range s.deadLetters {
+		if existing.EventKey == d.EventKey && id != d.ID {
+			delete(s.deadLetters, id)
+		}
+	}
+
+	if d.ID == 0 {
+		s.nextLetterID++
+		d.ID = s.nextLetterID
+	} else if d.ID > s.nextLetterID {
+		s.nextLetterID = d.ID
+	}
+	s.deadLetters[d.ID] = copyDeadLetter(*d)
+	return nil
+}
+
+func (s *memoryStore) DeleteDeadLetter(d *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deadLetters[d.ID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.deadLetters, d.ID)
+	return nil
+}
+
+func (s *memoryStore) CountDeadLetters() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.deadLetters), nil
+}
+
+func (s *memoryStore) DeleteDeadLetters() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters = make(map[int]DeadLetter)
+	return nil
+}
+
+// Settings are kept as JSON, as they would be on disk, so any value can be
+// read back into the type it was set from.
+func (s *memoryStore) GetSetting(key string, value interface{}) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.settings[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (s *memoryStore) SetSetting(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.settings[key] = data
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = make(map[int]Event)
+	s.deadLetters = make(map[int]DeadLetter)
+	s.settings = make(map[string][]byte)
+	return nil
+}
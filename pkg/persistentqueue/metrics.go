@@ -0,0 +1,177 @@
+package persistentqueue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the send latency
+// histogram.
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// DefaultQueueTimeBuckets are the upper bounds, in seconds, of the histograms
+// of time events spend queued, which may span retries and outages.
+var DefaultQueueTimeBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600, 21600, 86400}
+
+// Metrics is a point-in-time snapshot of queue activity.
+//
+// Counters cover the lifetime of the process, while `Pending` reflects the
+// persistent store at the time the snapshot is taken, including events
+// in-flight.
+type Metrics struct {
+	Pending      int
+	InFlight     int
+	Enqueued     int
+	Delivered    int
+	DeadLettered int
+	WorkerStalls int
+	SendLatency  Histogram
+
+	// Suppressed counts duplicates suppressed within the dedup window by
+	// masked routing key.
+	Suppressed map[string]int
+
+	// DeliveryLatency is the time from events being enqueued until PagerDuty
+	// confirmed them, and DeadLetterLatency until they were dead-lettered.
+	DeliveryLatency   Histogram
+	DeadLetterLatency Histogram
+}
+
+// Histogram is a cumulative histogram, where `Counts[i]` is the number of
+// observations less than or equal to `Buckets[i]`.
+type Histogram struct {
+	Buckets []float64
+	Counts  []int
+	Count   int
+	Sum     float64
+}
+
+func newHistogram(buckets []float64) Histogram {
+	return Histogram{
+		Buckets: buckets,
+		Counts:  make([]int, len(buckets)),
+	}
+}
+
+func (h *Histogram) observe(v float64) {
+	for i, upper := range h.Buckets {
+		if v <= upper {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += v
+}
+
+func (h Histogram) copy() Histogram {
+	c := h
+	c.Counts = append([]int(nil), h.Counts...)
+	return c
+}
+
+// metrics tracks process-lifetime counters for a `PersistentQueue`.
+type metrics struct {
+	mu                  sync.Mutex
+	inFlight            int
+	enqueued            int
+	delivered           int
+	deadLettered        int
+	consecutiveFailures int
+	lastSuccess         time.Time
+	sendLatency         Histogram
+	deliveryLatency     Histogram
+	deadLetterLatency   Histogram
+	suppressed          map[string]int
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		sendLatency:       newHistogram(DefaultLatencyBuckets),
+		deliveryLatency:   newHistogram(DefaultQueueTimeBuckets),
+		deadLetterLatency: newHistogram(DefaultQueueTimeBuckets),
+		suppressed:        make(map[string]int),
+	}
+}
+
+func (m *metrics) incEnqueued() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueued++
+}
+
+func (m *metrics) incSuppressed(routingKey string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.suppressed[common.MaskSecret(routingKey)]++
+}
+
+func (m *metrics) sendStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight++
+}
+
+func (m *metrics) sendFinished(started, now time.Time, delivered bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	if delivered {
+		m.delivered++
+		m.consecutiveFailures = 0
+		m.lastSuccess = now
+	} else {
+		m.consecutiveFailures++
+	}
+	m.sendLatency.observe(now.Sub(started).Seconds())
+}
+
+// observeDelivered records how long an event delivered at `now` was queued.
+func (m *metrics) observeDelivered(e *Event, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveryLatency.observe(now.Sub(e.CreatedAt).Seconds())
+}
+
+func (m *metrics) incDeadLettered(e *Event, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deadLettered++
+	m.deadLetterLatency.observe(now.Sub(e.CreatedAt).Seconds())
+}
+
+// Metrics returns a snapshot of the queue's metrics.
+func (q *PersistentQueue) Metrics() (Metrics, error) {
+	pending, err := q.Store.CountEvents(undeliveredStatuses...)
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	var stalls int
+	if s, ok := q.EventQueue.(stallCounter); ok {
+		stalls = s.Stalls()
+	}
+
+	q.metrics.mu.Lock()
+	defer q.metrics.mu.Unlock()
+
+	suppressed := make(map[string]int, len(q.metrics.suppressed))
+	for rk, n := range q.metrics.suppressed {
+		suppressed[rk] = n
+	}
+
+	return Metrics{
+		Pending:      pending,
+		InFlight:     q.metrics.inFlight,
+		Enqueued:     q.metrics.enqueued,
+		Delivered:    q.metrics.delivered,
+		DeadLettered: q.metrics.deadLettered,
+		WorkerStalls: stalls,
+		SendLatency:  q.metrics.sendLatency.copy(),
+		Suppressed:   suppressed,
+
+		DeliveryLatency:   q.metrics.deliveryLatency.copy(),
+		DeadLetterLatency: q.metrics.deadLetterLatency.copy(),
+	}, nil
+}
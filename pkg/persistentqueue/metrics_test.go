@@ -0,0 +1,132 @@
+package persistentqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestPersistentQueueMetrics(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()))
+
+	err := q.Start()
+	if err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+
+	if _, err := q.Enqueue(&eventContainer); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	m, err := q.Metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Enqueued != 1 {
+		t.Errorf("Expected one enqueued event, was %v.", m.Enqueued)
+	}
+	if m.Delivered != 1 {
+		t.Errorf("Expected one delivered event, was %v.", m.Delivered)
+	}
+	if m.Pending != 0 || m.InFlight != 0 {
+		t.Errorf("Expected no pending or in-flight events, were %v and %v.", m.Pending, m.InFlight)
+	}
+	if m.SendLatency.Count != 1 {
+		t.Errorf("Expected one latency observation, was %v.", m.SendLatency.Count)
+	}
+
+	h, err := q.Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.LastSuccess.IsZero() || h.ConsecutiveFailures != 0 {
+		t.Errorf("Expected a recent success and no failures, were %v and %v.", h.LastSuccess, h.ConsecutiveFailures)
+	}
+
+	_ = q.Shutdown()
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 5})
+	h.observe(0.5)
+	h.observe(2)
+	h.observe(10)
+
+	if h.Counts[0] != 1 || h.Counts[1] != 2 {
+		t.Errorf("Expected cumulative bucket counts of [1 2], were %v.", h.Counts)
+	}
+	if h.Count != 3 || h.Sum != 12.5 {
+		t.Errorf("Expected count 3 and sum 12.5, were %v and %v.", h.Count, h.Sum)
+	}
+}
+
+func TestPersistentQueueMetricsTimeInQueue(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    eventqueue.Response
+		deadLetters bool
+	}{
+		{name: "delivered"},
+		{
+			name:        "deadLettered",
+			response:    eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}},
+			deadLetters: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			defer teardown(t)
+			clock := test.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+			eq := NewMockEventQueue()
+			eq.Response = tt.response
+			eq.Delay = 50 * time.Millisecond
+			q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithClock(clock))
+			if err := q.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer q.Shutdown()
+
+			if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(90 * time.Second)
+			time.Sleep(150 * time.Millisecond)
+
+			m, err := q.Metrics()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			observed, other := m.DeliveryLatency, m.DeadLetterLatency
+			if tt.deadLetters {
+				observed, other = other, observed
+			}
+			if observed.Count != 1 || observed.Sum != 90 {
+				t.Errorf("Expected one observation of 90s, got %v totalling %v.", observed.Count, observed.Sum)
+			}
+			if other.Count != 0 {
+				t.Errorf("Expected no observations in the other histogram, got %v.", other.Count)
+			}
+			// Buckets 0-6 are up to 60s, so only those from 300s count it.
+			if observed.Counts[6] != 0 || observed.Counts[7] != 1 {
+				t.Errorf("Expected the observation in the 300s bucket, got %v.", observed.Counts)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package persistentqueue
+
+import (
+	"strings"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// severityLevels ranks v2 event severities, from least to most severe.
+var severityLevels = map[string]int{
+	"info":     1,
+	"warning":  2,
+	"error":    3,
+	"critical": 4,
+}
+
+// WithMinSeverity is an option dropping v2 triggers of a lower severity than
+// `severity`, e.g. "warning" to drop info events, rather than enqueuing them.
+// Dropped events are logged and recorded in any audit log.
+//
+// Only triggers are dropped, so acknowledging or resolving an incident is
+// never prevented, and events without a severity are always enqueued. An
+// empty severity drops nothing.
+func WithMinSeverity(severity string) Option {
+	return func(q *PersistentQueue) {
+		q.minSeverity = severityLevels[strings.ToLower(severity)]
+	}
+}
+
+// belowMinSeverity returns whether an event should be dropped for its
+// severity.
+func (q *PersistentQueue) belowMinSeverity(event eventsapi.Event) bool {
+	if q.minSeverity == 0 {
+		return false
+	}
+
+	e, ok := event.(*eventsapi.EventV2)
+	if !ok || e.EventAction != "trigger" {
+		return false
+	}
+
+	level, ok := severityLevels[strings.ToLower(e.Payload.Severity)]
+	return ok && level < q.minSeverity
+}
+
+// drop records an event dropped for its severity, returning the key it would
+// have been enqueued with, so it can be found in the audit log.
+func (q *PersistentQueue) drop(eventContainer *eventsapi.EventContainer, event eventsapi.Event) (string, error) {
+	e, err := NewEventAt(eventContainer, q.clock.Now())
+	if err != nil {
+		return "", err
+	}
+
+	severity := event.(*eventsapi.EventV2).Payload.Severity
+	q.logger.Infow("Dropped event below the minimum severity.", common.LogFieldEventID, e.Key, common.LogFieldRoutingKey, common.MaskSecret(e.RoutingKey), "severity", severity)
+	q.audit(AuditDropped, e, eventqueue.Response{})
+	return e.Key, nil
+}
@@ -0,0 +1,101 @@
+package persistentqueue
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func severityEventContainer(action, severity string) *eventsapi.EventContainer {
+	return &eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData: []byte(`
+			{
+				"routing_key":  "11863b592c824bfc8989d9cba76abcde",
+				"event_action": "` + action + `",
+				"dedup_key":    "disk-full",
+				"payload": {
+					"summary":  "PagerDuty Agent Severity Test",
+					"source":   "pdagent",
+					"severity": "` + severity + `"
+				}
+			}
+		`),
+	}
+}
+
+func TestPersistentQueueMinSeverity(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer os.Remove(tmpAuditLog)
+
+	eq := NewMockEventQueue()
+	q := startAudited(t, eq, WithMinSeverity("warning"))
+
+	droppedKey, err := q.Enqueue(severityEventContainer("trigger", "info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(severityEventContainer("trigger", "warning")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(severityEventContainer("resolve", "info")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if count := countEvents(t, q); count != 2 {
+		t.Errorf("Expected the info trigger not to be enqueued, found %v events.", count)
+	}
+
+	sent := eq.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("Expected the warning trigger and info resolve to be sent, sent %v.", len(sent))
+	}
+	for i, action := range []string{"trigger", "resolve"} {
+		event, err := sent[i].UnmarshalEvent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := event.(*eventsapi.EventV2); e.EventAction != action {
+			t.Errorf("Expected event %v to be a %v, was %v.", i, action, e.EventAction)
+		}
+	}
+
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	var dropped []AuditEntry
+	for _, entry := range readAuditLog(t, tmpAuditLog) {
+		if entry.Action == AuditDropped {
+			dropped = append(dropped, entry)
+		}
+	}
+	if len(dropped) != 1 || dropped[0].EventID != droppedKey || dropped[0].RoutingKey != "11****de" {
+		t.Errorf("Expected a dropped audit entry for %v, got %+v.", droppedKey, dropped)
+	}
+}
+
+func TestPersistentQueueMinSeverityDisabled(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if _, err := q.Enqueue(severityEventContainer("trigger", "info")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if sent := len(eq.Sent()); sent != 1 {
+		t.Errorf("Expected the info trigger to be sent without a minimum severity, sent %v.", sent)
+	}
+}
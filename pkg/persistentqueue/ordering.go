@@ -0,0 +1,124 @@
+package persistentqueue
+
+import (
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// orderingKey identifies the incident an event belongs to by its routing and
+// dedup (or incident) key, returning whether it's a trigger. The key is empty
+// for events without a dedup key, which can't be ordered against others.
+//
+// Acknowledges and resolves wait for any earlier trigger with the same
+// ordering key until it's delivered or fails for good, including while it's
+// scheduled for a retry, as sending them first would make PagerDuty reject
+// them or leave the trigger's incident open.
+func orderingKey(eventContainer *eventsapi.EventContainer) (string, bool) {
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return "", false
+	}
+
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		if e.IncidentKey == "" {
+			return "", false
+		}
+		return e.ServiceKey + "/" + e.IncidentKey, e.EventType == "trigger"
+	case *eventsapi.EventV2:
+		if e.DedupKey == "" {
+			return "", false
+		}
+		return e.RoutingKey + "/" + e.DedupKey, e.EventAction == "trigger"
+	default:
+		return "", false
+	}
+}
+
+// triggerPending returns true if a trigger stored before a follow-up event,
+// with the same ordering key, is yet to be delivered or to fail for good. That
+// includes triggers that are pending, in flight, or scheduled for a retry.
+//
+// Callers must hold `sendingMu`.
+func (q *PersistentQueue) triggerPending(e *Event, orderKey string) (bool, error) {
+	events, err := q.Store.FindEvents(EventQuery{
+		Statuses:   []string{StatusPending, StatusInFlight, StatusScheduled},
+		RoutingKey: e.RoutingKey,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for i := range events {
+		if events[i].ID >= e.ID {
+			break
+		}
+		if key, trigger := orderingKey(events[i].Event); trigger && key == orderKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sendAfterTrigger holds an event until the trigger it follows settles, then
+// sends it. Returns a channel closed once the event's own send completes.
+//
+// Callers must hold `sendingMu`. The event is registered as held while it
+// waits, so it isn't sent again in the meantime, e.g. by a flush.
+func (q *PersistentQueue) sendAfterTrigger(e *Event, orderKey string) <-chan struct{} {
+	key := e.Key
+	done := make(chan struct{})
+	q.held[key] = done
+
+	settled, ok := q.triggers[orderKey]
+	if !ok {
+		settled = make(chan struct{})
+		q.triggers[orderKey] = settled
+	}
+
+	q.logger.Infof("Waiting for trigger with the same dedup key to be sent before sending %v.", key)
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		defer close(done)
+		<-settled
+
+		q.sendingMu.Lock()
+		delete(q.held, key)
+		q.sendingMu.Unlock()
+
+		// Reloading, as the event may have been purged or sent meanwhile.
+		// Sending it checks for another trigger still pending.
+		e, err := q.Store.FindEventByKey(key)
+		if err != nil || e.Status != StatusPending {
+			return
+		}
+		if sent := q.processEvent(e); sent != nil {
+			<-sent
+		}
+	}()
+
+	return done
+}
+
+// triggerSettled wakes any events held for a trigger with the ordering key,
+// once it's delivered, failed for good, or removed.
+//
+// Callers must hold `sendingMu`.
+func (q *PersistentQueue) triggerSettled(orderKey string) {
+	if settled, ok := q.triggers[orderKey]; ok {
+		close(settled)
+		delete(q.triggers, orderKey)
+	}
+}
+
+// releaseHeld wakes all held events, e.g. to shut down or once their triggers
+// have been purged. Those still waiting on a trigger are held again.
+func (q *PersistentQueue) releaseHeld() {
+	q.sendingMu.Lock()
+	defer q.sendingMu.Unlock()
+
+	for orderKey := range q.triggers {
+		q.triggerSettled(orderKey)
+	}
+}
@@ -0,0 +1,166 @@
+package persistentqueue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+// sentActions returns the v2 event actions sent by a mock event queue.
+func sentActions(t *testing.T, eq *MockEventQueue) []string {
+	var actions []string
+	for _, eventContainer := range eq.Sent() {
+		event, err := eventContainer.UnmarshalEvent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, event.(*eventsapi.EventV2).EventAction)
+	}
+	return actions
+}
+
+func TestPersistentQueueResolveWaitsForTrigger(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Delay = 200 * time.Millisecond
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	var wg sync.WaitGroup
+	var triggerKey, resolveKey string
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		var err error
+		if triggerKey, err = q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+			t.Error(err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// Only just after the trigger, so it's the first enqueued.
+		time.Sleep(10 * time.Millisecond)
+		var err error
+		if resolveKey, err = q.Enqueue(ttlEventContainer("resolve", 0)); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+	time.Sleep(50 * time.Millisecond)
+
+	if actions := sentActions(t, eq); len(actions) != 1 || actions[0] != "trigger" {
+		t.Errorf("Expected only the trigger to be sent while it's in flight, sent %v.", actions)
+	}
+	resolve, err := q.Store.FindEventByKey(resolveKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolve.Status != StatusPending {
+		t.Errorf("Expected resolve to remain %v while its trigger is in flight, was %v.", StatusPending, resolve.Status)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+
+	if actions := sentActions(t, eq); len(actions) != 2 || actions[0] != "trigger" || actions[1] != "resolve" {
+		t.Errorf("Expected the trigger then the resolve to be sent, sent %v.", actions)
+	}
+	for _, key := range []string{triggerKey, resolveKey} {
+		event, err := q.Store.FindEventByKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Status != StatusSuccess {
+			t.Errorf("Expected %v to be sent, was %v.", key, event.Status)
+		}
+	}
+}
+
+func TestPersistentQueueResolveWithoutPendingTrigger(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Delay = 200 * time.Millisecond
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	other := ttlEventContainer("trigger", 0)
+	other.EventData = []byte(`{"routing_key": "11863b592c824bfc8989d9cba76abcde", "event_action": "trigger", "dedup_key": "other", "payload": {"summary": "Other", "source": "pdagent", "severity": "error"}}`)
+	if _, err := q.Enqueue(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(ttlEventContainer("resolve", 0)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if actions := sentActions(t, eq); len(actions) != 2 {
+		t.Errorf("Expected a resolve for another dedup key to be sent without waiting, sent %v.", actions)
+	}
+}
+
+func TestPersistentQueueResolveWaitsForRetriedTrigger(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	clock := test.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(3), WithClock(clock))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	triggerKey, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the trigger's first attempt fails, it's scheduled for a retry.
+	clock.BlockUntil(1)
+	trigger, err := q.Store.FindEventByKey(triggerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trigger.Status != StatusScheduled {
+		t.Fatalf("Expected trigger to be %v after failing, was %v.", StatusScheduled, trigger.Status)
+	}
+
+	resolveKey, err := q.Enqueue(ttlEventContainer("resolve", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if actions := sentActions(t, eq); len(actions) != 1 {
+		t.Errorf("Expected the resolve to wait for the trigger's retry, sent %v.", actions)
+	}
+	resolve, err := q.Store.FindEventByKey(resolveKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolve.Status != StatusPending {
+		t.Errorf("Expected resolve to remain %v while its trigger is scheduled, was %v.", StatusPending, resolve.Status)
+	}
+
+	eq.SetResponse(eventqueue.Response{})
+	clock.Advance(DefaultRetryMaxDelay)
+	eq.WaitForCalls(t, 3)
+
+	if actions := sentActions(t, eq); len(actions) != 3 || actions[1] != "trigger" || actions[2] != "resolve" {
+		t.Errorf("Expected the trigger to be retried then the resolve sent, sent %v.", actions)
+	}
+}
@@ -0,0 +1,129 @@
+package persistentqueue
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// Policies for enqueuing a trigger once the queue is at its maximum depth.
+const (
+	// OverflowReject rejects the trigger with `ErrQueueFull`.
+	OverflowReject = "reject"
+
+	// OverflowDropOldest drops the oldest of the lowest priority triggers
+	// waiting to be sent to make room, rejecting the trigger only if there
+	// are none.
+	OverflowDropOldest = "drop-oldest"
+)
+
+// ErrQueueFull occurs when enqueuing a trigger while the queue is at its
+// maximum depth.
+var ErrQueueFull = errors.New("queue is full, try again later")
+
+// queuedStatuses are those of events counting towards the queue's depth.
+var queuedStatuses = []string{StatusPending, StatusInFlight, StatusScheduled}
+
+// WithMaxQueueDepth is an option limiting the queue to `depth` events waiting
+// to be sent, including those in flight or scheduled for a retry, so it can't
+// grow without bound while PagerDuty is unreachable.
+//
+// Triggers enqueued at the limit are handled by the `overflow` policy, either
+// `OverflowReject` or `OverflowDropOldest`. Acknowledges and resolves are
+// always enqueued, so incidents can still be closed. A depth of 0 disables
+// the limit.
+func WithMaxQueueDepth(depth int, overflow string) Option {
+	return func(q *PersistentQueue) {
+		q.maxQueueDepth = depth
+		q.overflow = overflow
+	}
+}
+
+// makeRoom returns `ErrQueueFull` if an event can't be enqueued without
+// exceeding the maximum depth, first dropping an older event to make room
+// if the overflow policy allows. Callers hold `overflowMu` until the event
+// is stored, so concurrent enqueues can't both take the last place.
+func (q *PersistentQueue) makeRoom(event eventsapi.Event) error {
+	if isAcknowledgeOrResolve(event) {
+		return nil
+	}
+
+	depth, err := q.Store.CountEvents(queuedStatuses...)
+	if err != nil {
+		return err
+	}
+	if depth < q.maxQueueDepth {
+		return nil
+	}
+
+	if q.overflow == OverflowDropOldest {
+		if ok, err := q.dropOldest(); err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+	}
+
+	q.logger.Warnw("Rejected event, the queue is full.", common.LogFieldRoutingKey, common.MaskSecret(event.GetRoutingKey()), "depth", depth)
+	return ErrQueueFull
+}
+
+// dropOldest drops the oldest of the lowest priority triggers waiting to be
+// sent, returning false if there are none. Events in flight can't be dropped.
+func (q *PersistentQueue) dropOldest() (bool, error) {
+	events, err := q.Store.FindEvents(EventQuery{Statuses: []string{StatusPending, StatusScheduled}})
+	if err != nil {
+		return false, err
+	}
+
+	type candidate struct {
+		event    *Event
+		priority eventsapi.Priority
+	}
+	var candidates []candidate
+	for i := range events {
+		event, err := events[i].Event.UnmarshalEvent()
+		if err != nil || isAcknowledgeOrResolve(event) {
+			continue
+		}
+		candidates = append(candidates, candidate{&events[i], eventsapi.EventPriority(events[i].Event, event)})
+	}
+	if len(candidates) == 0 {
+		return false, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].priority != candidates[j].priority {
+			return candidates[i].priority < candidates[j].priority
+		}
+		return candidates[i].event.CreatedAt.Before(candidates[j].event.CreatedAt)
+	})
+
+	e := candidates[0].event
+	q.disarmScheduled(e.Key)
+	if err := q.Store.DeleteEvent(e); err != nil {
+		return false, err
+	}
+	if orderKey, trigger := orderingKey(e.Event); trigger {
+		q.sendingMu.Lock()
+		q.triggerSettled(orderKey)
+		q.sendingMu.Unlock()
+	}
+	q.logger.Warnw("Dropped the oldest lowest priority event to make room, the queue is full.", common.LogFieldEventID, e.Key, common.LogFieldRoutingKey, common.MaskSecret(e.RoutingKey), "priority", candidates[0].priority.String())
+	q.audit(AuditDropped, e, eventqueue.Response{})
+	return true, nil
+}
+
+func isAcknowledgeOrResolve(event eventsapi.Event) bool {
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		return e.EventType == "acknowledge" || e.EventType == "resolve"
+	case *eventsapi.EventV2:
+		return e.EventAction == "acknowledge" || e.EventAction == "resolve"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,102 @@
+package persistentqueue
+
+import (
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func priorityEventContainer(action string, priority eventsapi.Priority) *eventsapi.EventContainer {
+	eventContainer := ttlEventContainer(action, 0)
+	eventContainer.Priority = priority
+	return eventContainer
+}
+
+func TestPersistentQueueMaxQueueDepthReject(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	// Events stay pending during maintenance, filling the queue.
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithFile(tmpDbFile), WithMaintenance(true), WithMaxQueueDepth(2, OverflowReject))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != ErrQueueFull {
+		t.Errorf("Expected a trigger at the limit to be rejected, got %v.", err)
+	}
+	if _, err := q.Enqueue(ttlEventContainer("resolve", 0)); err != nil {
+		t.Errorf("Expected a resolve at the limit to be enqueued, got %v.", err)
+	}
+
+	events, err := q.List(ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Errorf("Expected 2 triggers and a resolve to be queued, got %+v.", events)
+	}
+}
+
+func TestPersistentQueueMaxQueueDepthDropOldest(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithFile(tmpDbFile), WithMaintenance(true), WithMaxQueueDepth(3, OverflowDropOldest))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	var keys []string
+	for _, priority := range []eventsapi.Priority{eventsapi.PriorityNormal, eventsapi.PriorityLow, eventsapi.PriorityLow} {
+		key, err := q.Enqueue(priorityEventContainer("trigger", priority))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	// The oldest low priority trigger makes room, not the older normal one.
+	key, err := q.Enqueue(priorityEventContainer("trigger", eventsapi.PriorityHigh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Store.FindEventByKey(keys[1]); err != ErrNotFound {
+		t.Errorf("Expected the oldest low priority trigger to be dropped, got %v.", err)
+	}
+	for _, k := range []string{keys[0], keys[2], key} {
+		if _, err := q.Store.FindEventByKey(k); err != nil {
+			t.Errorf("Expected event %v to be queued, got %v.", k, err)
+		}
+	}
+
+	// Resolves are enqueued past the limit, and aren't dropped to make room.
+	resolveKey, err := q.Enqueue(priorityEventContainer("resolve", eventsapi.PriorityLow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := q.Enqueue(priorityEventContainer("trigger", eventsapi.PriorityNormal)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := q.Store.FindEventByKey(resolveKey); err != nil {
+		t.Errorf("Expected the resolve to be kept, got %v.", err)
+	}
+	events, err := q.List(ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Errorf("Expected 3 triggers and a resolve to be queued, got %+v.", events)
+	}
+}
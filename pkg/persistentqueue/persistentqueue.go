@@ -1,32 +1,97 @@
 package persistentqueue
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path"
 	"sync"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
-	"github.com/asdine/storm"
 	"go.uber.org/zap"
 )
 
+const DefaultShutdownGracePeriod = 30 * time.Second
+
+// cancelGracePeriod is how long `Shutdown` waits for sends to record their
+// cancellation once the shutdown grace period has elapsed.
+const cancelGracePeriod = time.Second
+
+var ErrQueueShutdown = errors.New("queue is shutting down")
+
+// ErrQueueLocked occurs when starting a queue whose database is already open
+// in another process, most likely another agent.
+var ErrQueueLocked = errors.New("queue database is locked, is another agent already running with it?")
+
+// dbLockTimeout is how long to wait for another process to release the
+// database before giving up with `ErrQueueLocked`.
+var dbLockTimeout = time.Second
+
 type EventQueue interface {
 	Enqueue(*eventsapi.EventContainer, chan<- eventqueue.Response) error
 	Shutdown()
 }
 
+// canceler is implemented by event queues able to cancel in-flight sends,
+// such as `eventqueue.EventQueue`.
+type canceler interface {
+	Cancel()
+}
+
+// stallCounter is implemented by event queues restarting stalled workers,
+// such as `eventqueue.EventQueue`.
+type stallCounter interface {
+	Stalls() int
+}
+
 type PersistentQueue struct {
-	DB         *storm.DB
-	Events     storm.Node
+	Store      Store
 	EventQueue EventQueue
 
-	path   string
-	logger *zap.SugaredLogger
-	tmp    bool
-	wg     sync.WaitGroup
+	path                string
+	auditLog            *AuditLog
+	backend             string
+	clock               common.Clock
+	dedup               *dedupCache
+	draining            bool
+	eventTTL            time.Duration
+	failureAlert        *failureAlerter
+	forceMaintenance    bool
+	held                map[string]chan struct{}
+	logger              *zap.SugaredLogger
+	maintenance         bool
+	maxQueueDepth       int
+	maxRetries          int
+	minSeverity         int
+	metrics             *metrics
+	mu                  sync.RWMutex
+	overflow            string
+	overflowMu          sync.Mutex
+	resolveEventTTL     time.Duration
+	retryBaseDelay      time.Duration
+	retryMaxDelay       time.Duration
+	scheduled           map[string]chan struct{}
+	scheduledMu         sync.Mutex
+	sending             map[string]chan struct{}
+	sendingMu           sync.Mutex
+	shutdownGracePeriod time.Duration
+	spoolDone           chan struct{}
+	spoolMu             sync.Mutex
+	spoolPath           string
+	spoolStop           chan struct{}
+	started             bool
+	statsDone           chan struct{}
+	statsInterval       time.Duration
+	statsPath           string
+	statsStop           chan struct{}
+	successHooks        []SuccessHook
+	stopping            bool
+	tmp                 bool
+	triggers            map[string]chan struct{}
+	wg                  sync.WaitGroup
 }
 
 type Option func(*PersistentQueue)
@@ -38,20 +103,70 @@ func WithFile(path string) Option {
 	}
 }
 
+// WithSQLite is an option storing the queue in a SQLite database rather than
+// BoltDB, at the file given by `WithFile`, e.g. to inspect it with external
+// tooling.
+func WithSQLite() Option {
+	return func(q *PersistentQueue) {
+		q.backend = BackendSQLite
+	}
+}
+
 func WithEventQueue(eq EventQueue) Option {
 	return func(q *PersistentQueue) {
 		q.EventQueue = eq
 	}
 }
 
+// WithClock is an option replacing the system time for the queue's retries,
+// TTLs, dedup window, scheduled events, and metrics, e.g. with a fake clock in
+// tests. The event queue's sends are timed by its own `Clock`.
+func WithClock(clock common.Clock) Option {
+	return func(q *PersistentQueue) {
+		q.clock = clock
+	}
+}
+
+// WithShutdownGracePeriod is an option limiting how long `Shutdown` waits for
+// in-flight sends to complete.
+func WithShutdownGracePeriod(d time.Duration) Option {
+	return func(q *PersistentQueue) {
+		q.shutdownGracePeriod = d
+	}
+}
+
+// WithDedupWindow is an option suppressing enqueues of an event identical to
+// one enqueued within the window, returning the original event's key instead.
+//
+// Events are considered identical when their routing key, incident or dedup
+// key, event type, and description match. A zero window disables this.
+func WithDedupWindow(d time.Duration) Option {
+	return func(q *PersistentQueue) {
+		if d > 0 {
+			q.dedup = newDedupCache(d)
+		} else {
+			q.dedup = nil
+		}
+	}
+}
+
 func NewPersistentQueue(options ...Option) *PersistentQueue {
 	logger := common.Logger.Named("PersistentQueue")
 	logger.Info("Creating new PersistentQueue.")
 
 	q := PersistentQueue{
-		EventQueue: eventqueue.NewEventQueue(),
-		logger:     logger,
-		tmp:        true,
+		EventQueue:          eventqueue.NewEventQueue(),
+		clock:               common.RealClock{},
+		held:                make(map[string]chan struct{}),
+		logger:              logger,
+		metrics:             newMetrics(),
+		retryBaseDelay:      DefaultRetryBaseDelay,
+		retryMaxDelay:       DefaultRetryMaxDelay,
+		scheduled:           make(map[string]chan struct{}),
+		sending:             make(map[string]chan struct{}),
+		shutdownGracePeriod: DefaultShutdownGracePeriod,
+		tmp:                 true,
+		triggers:            make(map[string]chan struct{}),
 	}
 
 	for _, option := range options {
@@ -62,48 +177,186 @@ func NewPersistentQueue(options ...Option) *PersistentQueue {
 }
 
 func (q *PersistentQueue) Start() error {
+	store, err := q.openStore()
+	if err != nil {
+		return err
+	}
+	q.Store = store
+
+	if err := q.loadMaintenance(); err != nil {
+		q.logger.Error("Error loading maintenance mode: ", err)
+		return err
+	}
+
+	recovered, err := q.recoverInFlight()
+	if err != nil {
+		q.logger.Error("Error recovering in-flight events: ", err)
+		return err
+	}
+	if recovered > 0 {
+		q.logger.Warnf("Recovered %v events interrupted while sending, these will be resent.", recovered)
+	}
+
+	if err := q.loadScheduled(); err != nil {
+		q.logger.Error("Error loading scheduled events: ", err)
+		return err
+	}
+
+	q.mu.Lock()
+	q.started = true
+	q.mu.Unlock()
+
+	if q.maintenance {
+		q.logger.Info("Starting in maintenance mode, pending events will be sent once it is disabled.")
+	} else if err := q.sendPending(); err != nil {
+		return err
+	}
+
+	// Sweeping after sending pending events, so those imported aren't sent
+	// twice.
+	if q.spoolPath != "" {
+		q.spoolStop = make(chan struct{})
+		q.spoolDone = make(chan struct{})
+		go q.sweepSpoolPeriodically()
+	}
+
+	if q.statsPath != "" {
+		q.statsStop = make(chan struct{})
+		q.statsDone = make(chan struct{})
+		go q.writeStatsPeriodically()
+	}
+
+	return nil
+}
+
+// openStore opens the queue's store, in memory, at a temporary file, or at
+// the configured path.
+func (q *PersistentQueue) openStore() (Store, error) {
+	if q.backend == BackendMemory {
+		q.logger.Warn("Using in-memory queue, undelivered events and dead letters will be lost on restart.")
+		return newMemoryStore(), nil
+	}
+
 	if q.tmp {
 		dbFile, err := ioutil.TempFile("", "go-pdagent.*.db")
 		if err != nil {
-			return err
+			return nil, err
 		}
 		q.path = dbFile.Name()
 		dbFile.Close()
 	} else {
 		if err := os.MkdirAll(path.Dir(q.path), 0744); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	db, err := storm.Open(q.path)
-	if err != nil {
-		return err
+	if q.backend == BackendSQLite {
+		return openSQLiteStore(q.path, dbLockTimeout)
 	}
+	return openBoltStore(q.path, dbLockTimeout)
+}
 
-	q.DB = db
-	q.Events = q.DB.From("events")
-
-	var pendingEvents []Event
-	if err := q.Events.Find("Status", StatusPending, &pendingEvents); err != nil && err != storm.ErrNotFound {
+// sendPending hands all pending events to the event queue.
+func (q *PersistentQueue) sendPending() error {
+	pendingEvents, err := q.Store.FindEvents(EventQuery{Statuses: []string{StatusPending}})
+	if err != nil {
 		q.logger.Error("Error querying for pending events: ", err)
 		return err
 	}
 
 	q.logger.Infof("Enqueuing %v pending events.", len(pendingEvents))
-	for _, e := range pendingEvents {
-		q.processEvent(&e)
+	for i := range pendingEvents {
+		q.processEvent(&pendingEvents[i])
 	}
 
 	return nil
 }
 
+// recoverInFlight resets events marked in-flight back to pending, returning
+// how many were reset.
+//
+// An event is only in-flight between being handed to the event queue and its
+// response being recorded, so any found when not sending were interrupted,
+// e.g. by a crash. They may or may not have reached PagerDuty, and resending
+// risks a duplicate rather than losing the event.
+func (q *PersistentQueue) recoverInFlight() (int, error) {
+	events, err := q.Store.FindEvents(EventQuery{Statuses: []string{StatusInFlight}})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range events {
+		events[i].Status = StatusPending
+		if err := events[i].UpdateAt(q.Store, q.clock.Now()); err != nil {
+			return i, err
+		}
+	}
+
+	return len(events), nil
+}
+
 // Stop a `PersistentQueue`, performing any necessary cleanup.
+//
+// New events are rejected immediately, then in-flight sends are given up to
+// the shutdown grace period to complete. Any events still undelivered after
+// that remain pending in the database and are sent on the next start.
 func (q *PersistentQueue) Shutdown() error {
 	q.logger.Info("Shutting down PersistentQueue.")
 
-	q.EventQueue.Shutdown()
-	q.wg.Wait()
-	if err := q.DB.Close(); err != nil {
+	q.mu.Lock()
+	q.stopping = true
+	q.mu.Unlock()
+	q.stopScheduled()
+	q.releaseHeld()
+	if q.spoolStop != nil {
+		close(q.spoolStop)
+		<-q.spoolDone
+	}
+	if q.statsStop != nil {
+		close(q.statsStop)
+		<-q.statsDone
+	}
+
+	done := make(chan struct{})
+	go func() {
+		q.EventQueue.Shutdown()
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.logger.Info("All in-flight events completed.")
+	case <-q.clock.After(q.shutdownGracePeriod):
+		// Cancelled sends return their events to pending, anything else still
+		// in-flight is reset below.
+		if c, ok := q.EventQueue.(canceler); ok {
+			c.Cancel()
+			select {
+			case <-done:
+			case <-q.clock.After(cancelGracePeriod):
+			}
+		}
+
+		if _, err := q.recoverInFlight(); err != nil {
+			q.logger.Errorf("Failed to reset in-flight events to pending: %v", err)
+		}
+
+		m, err := q.Metrics()
+		if err != nil {
+			q.logger.Warnf("Shutdown grace period of %v elapsed, unable to determine pending events: %v", q.shutdownGracePeriod, err)
+		} else {
+			q.logger.Warnf("Shutdown grace period of %v elapsed with %v events pending, these will be sent on next start.", q.shutdownGracePeriod, m.Pending)
+		}
+	}
+
+	if q.auditLog != nil {
+		if err := q.auditLog.Close(); err != nil {
+			q.logger.Errorf("Failed to close audit log: %v", err)
+		}
+	}
+
+	if err := q.Store.Close(); err != nil {
 		return err
 	}
 
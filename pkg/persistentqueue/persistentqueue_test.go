@@ -1,10 +1,13 @@
 package persistentqueue
 
 import (
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
 )
 
 func TestPersistentQueueSimple(t *testing.T) {
@@ -42,7 +45,7 @@ func TestPersistentQueueSimple(t *testing.T) {
 
 	time.Sleep(time.Second)
 
-	persistedEvent, err := FindEventByKey(q.Events, key)
+	persistedEvent, err := q.Store.FindEventByKey(key)
 	if err != nil {
 		t.Fatal("Could not find persisted event.")
 	}
@@ -53,3 +56,177 @@ func TestPersistentQueueSimple(t *testing.T) {
 
 	_ = q.Shutdown()
 }
+
+func TestPersistentQueueShutdownGracePeriod(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Delay = 10 * time.Second
+
+	q := NewPersistentQueue(
+		WithFile(tmpDbFile),
+		WithEventQueue(eq),
+		WithShutdownGracePeriod(100*time.Millisecond),
+	)
+
+	err := q.Start()
+	if err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+
+	var keys []string
+	for i := 0; i < 2; i++ {
+		eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+		key, err := q.Enqueue(&eventContainer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	start := time.Now()
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected shutdown to respect grace period, took %v.", elapsed)
+	}
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	if _, err := q.Enqueue(&eventContainer); err != ErrQueueShutdown {
+		t.Errorf("Expected enqueue after shutdown to be rejected, was %v.", err)
+	}
+
+	db, err := openBoltStore(tmpDbFile, dbLockTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, key := range keys {
+		persistedEvent, err := db.FindEventByKey(key)
+		if err != nil {
+			t.Fatalf("Could not find persisted event %v.", key)
+		}
+
+		if persistedEvent.Status != StatusPending {
+			t.Errorf("Expected undelivered event to remain pending, was %v.", persistedEvent.Status)
+		}
+	}
+}
+
+// Simulating a crash mid-send by closing the store without shutting down, the
+// in-flight event should be redelivered once the queue is restarted.
+func TestPersistentQueueRecoversInFlightEvents(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	stalled := NewMockEventQueue()
+	stalled.Delay = time.Hour
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(stalled))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	key, err := q.Enqueue(&eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	persistedEvent, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal("Could not find persisted event.")
+	}
+	if persistedEvent.Status != StatusInFlight {
+		t.Fatalf("Expected event status to be in-flight, was %v.", persistedEvent.Status)
+	}
+
+	if err := q.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	eq := NewMockEventQueue()
+	restarted := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := restarted.Start(); err != nil {
+		t.Fatal("Error restarting persistent queue.")
+	}
+	defer restarted.Shutdown()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		persistedEvent, err = restarted.Store.FindEventByKey(key)
+		if err != nil {
+			t.Fatal("Could not find persisted event after restart.")
+		}
+		if persistedEvent.Status == StatusSuccess || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if persistedEvent.Status != StatusSuccess {
+		t.Errorf("Expected event to be redelivered after restart, status was %v.", persistedEvent.Status)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected event to be sent once after restart, was sent %v times.", calls)
+	}
+}
+
+func TestPersistentQueueReady(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := q.Ready(); err != ErrQueueNotStarted {
+		t.Errorf("Expected ErrQueueNotStarted before starting, got %v.", err)
+	}
+
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ready(); err != nil {
+		t.Errorf("Expected queue to be ready once started, got %v.", err)
+	}
+
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ready(); err != ErrQueueShutdown {
+		t.Errorf("Expected ErrQueueShutdown after shutdown, got %v.", err)
+	}
+}
+
+func TestPersistentQueueLocked(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	oldTimeout := dbLockTimeout
+	dbLockTimeout = 50 * time.Millisecond
+	defer func() { dbLockTimeout = oldTimeout }()
+
+	first := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := first.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := second.Start(); !errors.Is(err, ErrQueueLocked) {
+		t.Fatalf("Expected ErrQueueLocked opening a queue already in use, got %v.", err)
+	}
+
+	if err := first.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Released on shutdown, so the file can be used again.
+	third := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := third.Start(); err != nil {
+		t.Fatalf("Expected the queue to open once released, got %v.", err)
+	}
+	if err := third.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package persistentqueue
+
+import (
+	"errors"
+	"time"
+)
+
+// purgeSendTimeout bounds how long `Purge` waits for in-flight sends, kept
+// below the server's write timeout.
+const purgeSendTimeout = 5 * time.Second
+
+var ErrPurgeSendsInProgress = errors.New("timed out waiting for in-flight sends to complete, try again")
+
+// PurgeResult summarizes the entries removed by a `Purge`.
+type PurgeResult struct {
+	Pending     int `json:"pending"`
+	InFlight    int `json:"in_flight"`
+	DeadLetters int `json:"dead_letters"`
+}
+
+// Purge deletes all undelivered events, and all dead letters if
+// `deadLetters` is set, e.g. to clear out test events.
+//
+// Sends already in progress are allowed to complete first, as their events
+// may already have reached PagerDuty, and no new sends start while purging,
+// so a response is never recorded against a deleted event. Should they not
+// complete in time nothing is deleted. Any events still marked in-flight
+// afterwards, e.g. left by a send abandoned at shutdown, are deleted along
+// with pending ones.
+func (q *PersistentQueue) Purge(deadLetters bool) (PurgeResult, error) {
+	var result PurgeResult
+
+	// processEvent needs a read lock to start a send.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.waitForSends(purgeSendTimeout); err != nil {
+		return result, err
+	}
+
+	events, err := q.Store.FindEvents(EventQuery{Statuses: undeliveredStatuses})
+	if err != nil {
+		return result, err
+	}
+
+	if deadLetters {
+		count, err := q.Store.CountDeadLetters()
+		if err != nil {
+			return result, err
+		}
+		if err := q.Store.DeleteDeadLetters(); err != nil {
+			return result, err
+		}
+		result.DeadLetters = count
+	}
+
+	for i := range events {
+		e := &events[i]
+		if err := q.Store.DeleteEvent(e); err != nil {
+			return result, err
+		}
+		if err := q.clearDeadLetter(e); err != nil {
+			return result, err
+		}
+
+		if e.Status == StatusInFlight {
+			result.InFlight++
+		} else {
+			result.Pending++
+		}
+	}
+
+	// Waking events held for purged triggers, which are themselves purged.
+	q.releaseHeld()
+
+	// Forgetting purged events, so identical events are enqueued afresh
+	// rather than suppressed in favor of a deleted one.
+	if q.dedup != nil {
+		q.dedup.clear()
+	}
+
+	q.logger.Infof("Purged %v pending and %v in-flight events, and %v dead letters.", result.Pending, result.InFlight, result.DeadLetters)
+	return result, nil
+}
+
+// waitForSends blocks until every send in progress completes, or returns
+// ErrPurgeSendsInProgress once the timeout elapses.
+func (q *PersistentQueue) waitForSends(timeout time.Duration) error {
+	q.sendingMu.Lock()
+	sends := make([]chan struct{}, 0, len(q.sending))
+	for _, done := range q.sending {
+		sends = append(sends, done)
+	}
+	q.sendingMu.Unlock()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for _, done := range sends {
+		select {
+		case <-done:
+		case <-deadline.C:
+			return ErrPurgeSendsInProgress
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package persistentqueue
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestPersistentQueuePurge(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{
+		Response: &eventsapi.ResponseV2{
+			BaseResponse: eventsapi.BaseResponse{HTTPResponse: &http.Response{StatusCode: 400}},
+		},
+		Error: errors.New("invalid event"),
+	}
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	// One failed event leaves a dead letter, while events enqueued during
+	// maintenance remain pending.
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	if _, err := q.Enqueue(&eventContainer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+		if _, err := q.Enqueue(&eventContainer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := q.Purge(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (PurgeResult{Pending: 2}) {
+		t.Errorf("Expected 2 pending events purged, result was %+v.", result)
+	}
+
+	health, err := q.Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Pending != 0 {
+		t.Errorf("Expected no pending events after purge, was %v.", health.Pending)
+	}
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 {
+		t.Errorf("Expected dead letters to be kept, found %v.", len(deadLetters))
+	}
+
+	result, err = q.Purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (PurgeResult{DeadLetters: 1}) {
+		t.Errorf("Expected 1 dead letter purged, result was %+v.", result)
+	}
+
+	deadLetters, err = q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 0 {
+		t.Errorf("Expected no dead letters after purge, found %v.", len(deadLetters))
+	}
+}
+
+func TestPersistentQueuePurgeWaitsForSends(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Delay = 200 * time.Millisecond
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal("Error starting persistent queue.")
+	}
+	defer q.Shutdown()
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	key, err := q.Enqueue(&eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	result, err := q.Purge(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected purge to wait for the in-progress send, returned after %v.", elapsed)
+	}
+	if result != (PurgeResult{}) {
+		t.Errorf("Expected the completed send not to be purged, result was %+v.", result)
+	}
+
+	e, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != StatusSuccess {
+		t.Errorf("Expected the send's response to be recorded, status was %v.", e.Status)
+	}
+}
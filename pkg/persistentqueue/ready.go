@@ -0,0 +1,35 @@
+package persistentqueue
+
+import (
+	"errors"
+)
+
+// readyKey holds the time of the last readiness check, written to confirm the
+// store accepts writes.
+const readyKey = "ready_checked_at"
+
+// ErrQueueNotStarted occurs when checking readiness before `Start`.
+var ErrQueueNotStarted = errors.New("queue has not been started")
+
+// Ready returns an error unless the queue is started, not shutting down or
+// draining, and its store is open and writable.
+//
+// Checking writes a single small record, so is cheap enough for frequent
+// probes, and never depends on reaching PagerDuty.
+func (q *PersistentQueue) Ready() error {
+	q.mu.RLock()
+	started, stopping, draining := q.started, q.stopping, q.draining
+	q.mu.RUnlock()
+
+	if !started {
+		return ErrQueueNotStarted
+	}
+	if stopping {
+		return ErrQueueShutdown
+	}
+	if draining {
+		return ErrQueueDraining
+	}
+
+	return q.Store.SetSetting(readyKey, q.clock.Now())
+}
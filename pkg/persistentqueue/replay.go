@@ -0,0 +1,138 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// Replay re-sends a dead letter's original event, e.g. once a bad routing key
+// is fixed, returning the replayed event's key. A non-empty `routingKey`
+// replaces the event's own.
+//
+// Unlike `RetryDeadLetter`, the event starts over with no attempts and no
+// expiry, then is sent as any newly enqueued event, retried as usual before
+// it can be dead-lettered again.
+func (q *PersistentQueue) Replay(id int, routingKey string) (string, error) {
+	deadLetter, err := q.Store.FindDeadLetter(id)
+	if err != nil {
+		return "", err
+	}
+
+	return q.replay(deadLetter, routingKey)
+}
+
+// ReplayAll replays every dead letter as with `Replay`, returning how many
+// were replayed.
+func (q *PersistentQueue) ReplayAll(routingKey string) (int, error) {
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deadLetters {
+		if _, err := q.replay(&deadLetters[i], routingKey); err != nil {
+			return i, err
+		}
+	}
+
+	return len(deadLetters), nil
+}
+
+func (q *PersistentQueue) replay(deadLetter *DeadLetter, routingKey string) (string, error) {
+	if q.isStopping() {
+		return "", ErrQueueShutdown
+	}
+
+	eventContainer := deadLetter.Event
+	if routingKey != "" {
+		common.RegisterSecret(routingKey)
+
+		var err error
+		eventContainer, err = withRoutingKey(eventContainer, routingKey)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	e, err := q.replayEvent(deadLetter.EventKey, eventContainer)
+	if err != nil {
+		return "", err
+	}
+
+	if err := q.Store.DeleteDeadLetter(deadLetter); err != nil {
+		return "", err
+	}
+
+	q.logger.Infow("Replaying dead letter.", "dead_letter", deadLetter.ID, common.LogFieldEventID, e.Key, common.LogFieldRoutingKey, common.MaskSecret(e.RoutingKey))
+	q.processEvent(e)
+
+	return e.Key, nil
+}
+
+// replayEvent resets a dead-lettered event to be sent afresh with
+// `eventContainer`, recreating it should it since have been deleted.
+func (q *PersistentQueue) replayEvent(key string, eventContainer *eventsapi.EventContainer) (*Event, error) {
+	e, err := q.Store.FindEventByKey(key)
+	if err == ErrNotFound {
+		now := q.clock.Now()
+		e, err = NewEventAt(eventContainer, now)
+		if err != nil {
+			return nil, err
+		}
+		return e, e.CreateAt(q.Store, now)
+	} else if err != nil {
+		return nil, err
+	}
+
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	e.Event = eventContainer
+	e.RoutingKey = event.GetRoutingKey()
+	e.Status = StatusPending
+	e.Attempts = 0
+	e.ExpiresAt = time.Time{}
+	if err := e.UpdateAt(q.Store, q.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// withRoutingKey returns a copy of an event with its routing (or service) key
+// replaced.
+func withRoutingKey(eventContainer *eventsapi.EventContainer, routingKey string) (*eventsapi.EventContainer, error) {
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		e.ServiceKey = routingKey
+	case *eventsapi.EventV2:
+		e.RoutingKey = routingKey
+	case *eventsapi.ChangeEventV2:
+		e.RoutingKey = routingKey
+	default:
+		return nil, eventsapi.ErrUnrecognizedEventType
+	}
+
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	replayed := *eventContainer
+	replayed.EventData = data
+	return &replayed, nil
+}
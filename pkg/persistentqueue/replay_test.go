@@ -0,0 +1,138 @@
+package persistentqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+const replayRoutingKey = "22863b592c824bfc8989d9cba76abcde"
+
+// startDeadLettered starts a queue whose sends fail, with `count` events
+// already dead-lettered.
+func startDeadLettered(t *testing.T, count int) (*PersistentQueue, *MockEventQueue) {
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid routing key")}}
+
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithEventTTL(time.Hour, 0))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < count; i++ {
+		if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != count {
+		t.Fatalf("Expected %v dead letters, found %v.", count, len(deadLetters))
+	}
+
+	eq.SetResponse(eventqueue.Response{})
+	return q, eq
+}
+
+// assertReplayed checks an event was replayed to `routingKey` and delivered
+// on its first attempt.
+func assertReplayed(t *testing.T, q *PersistentQueue, key, routingKey string) {
+	e, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != StatusSuccess {
+		t.Errorf("Expected replayed event to be sent, was %v.", e.Status)
+	}
+	if e.Attempts != 1 {
+		t.Errorf("Expected replayed event to start over with fresh attempts, had %v.", e.Attempts)
+	}
+	if !e.ExpiresAt.IsZero() {
+		t.Errorf("Expected replayed event not to expire, expires at %v.", e.ExpiresAt)
+	}
+	if e.RoutingKey != routingKey {
+		t.Errorf("Expected replayed event to use %v, was %v.", routingKey, e.RoutingKey)
+	}
+
+	event, err := e.Event.UnmarshalEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetRoutingKey() != routingKey {
+		t.Errorf("Expected replayed payload to use %v, was %v.", routingKey, event.GetRoutingKey())
+	}
+}
+
+func TestPersistentQueueReplay(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q, eq := startDeadLettered(t, 1)
+	defer q.Shutdown()
+
+	deadLetters, _ := q.DeadLetters("")
+	key, err := q.Replay(deadLetters[0].ID, replayRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != deadLetters[0].EventKey {
+		t.Errorf("Expected the original event %v to be replayed, was %v.", deadLetters[0].EventKey, key)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	assertReplayed(t, q, key, replayRoutingKey)
+
+	sent := eq.Sent()
+	last, _ := sent[len(sent)-1].UnmarshalEvent()
+	if last.GetRoutingKey() != replayRoutingKey {
+		t.Errorf("Expected replay to be sent to %v, was %v.", replayRoutingKey, last.GetRoutingKey())
+	}
+
+	if deadLetters, _ = q.DeadLetters(""); len(deadLetters) != 0 {
+		t.Errorf("Expected dead letter to be removed after replay, found %v.", len(deadLetters))
+	}
+}
+
+func TestPersistentQueueReplayNotFound(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q, _ := startDeadLettered(t, 0)
+	defer q.Shutdown()
+
+	if _, err := q.Replay(42, ""); err == nil {
+		t.Error("Expected replaying a missing dead letter to fail.")
+	}
+}
+
+func TestPersistentQueueReplayAll(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q, _ := startDeadLettered(t, 3)
+	defer q.Shutdown()
+
+	deadLetters, _ := q.DeadLetters("")
+	count, err := q.ReplayAll(replayRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 dead letters replayed, was %v.", count)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, deadLetter := range deadLetters {
+		assertReplayed(t, q, deadLetter.EventKey, replayRoutingKey)
+	}
+	if deadLetters, _ = q.DeadLetters(""); len(deadLetters) != 0 {
+		t.Errorf("Expected dead letters to be removed after replay, found %v.", len(deadLetters))
+	}
+}
@@ -1,21 +1,77 @@
 package persistentqueue
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotRetryable is returned when manually retrying an event that's neither
+// in an error state nor scheduled for a retry.
+var ErrNotRetryable = errors.New("only failed or scheduled events can be retried")
+
 // Retries events that are in an error state, either for an routing key or
 // for all events in error if none is provided.
+//
+// Retried events no longer expire, as retrying is a deliberate choice to send
+// them late.
 func (q *PersistentQueue) Retry(routingKey string) (int, error) {
-	var err error
-	var events []Event
-
-	err = q.Events.Find("Status", StatusError, &events)
+	events, err := q.Store.FindEvents(EventQuery{Statuses: []string{StatusError}, RoutingKey: routingKey})
 	if err != nil {
 		return 0, err
 	}
 
-	for _, event := range events {
-		if routingKey == "" || event.RoutingKey == routingKey {
-			q.processEvent(&event)
+	for i := range events {
+		if err := q.resetAttempts(&events[i]); err != nil {
+			return 0, err
 		}
+		q.processEvent(&events[i])
 	}
 
 	return len(events), nil
 }
+
+// RetryEvent immediately retries a single failed or scheduled event by ID.
+//
+// As with `Retry`, its attempt counter is reset, so should the retry fail too
+// it's resent with the usual growing delay and full retry budget, rather than
+// being dead-lettered straight away.
+func (q *PersistentQueue) RetryEvent(id int) error {
+	e, err := q.Store.FindEvent(id)
+	if err != nil {
+		return err
+	}
+	if e.Status != StatusError && e.Status != StatusScheduled {
+		return ErrNotRetryable
+	}
+
+	if e.Status == StatusScheduled {
+		q.disarmScheduled(e.Key)
+	}
+	if err := q.resetAttempts(e); err != nil {
+		return err
+	}
+
+	q.logger.Infof("Manually retrying event %v.", e.Key)
+	q.processEvent(e)
+	return nil
+}
+
+// resetAttempts prepares an event to be manually retried, clearing its
+// attempts and expiry and making it due now.
+func (q *PersistentQueue) resetAttempts(e *Event) error {
+	e.Attempts = 0
+	e.SendAt = q.clock.Now()
+	e.ExpiresAt = time.Time{}
+	return q.Store.UpdateEvent(e)
+}
+
+// disarmScheduled stops waiting on a scheduled event, if it's armed.
+func (q *PersistentQueue) disarmScheduled(key string) {
+	q.scheduledMu.Lock()
+	defer q.scheduledMu.Unlock()
+
+	if stop, ok := q.scheduled[key]; ok {
+		close(stop)
+		delete(q.scheduled, key)
+	}
+}
@@ -0,0 +1,81 @@
+package persistentqueue
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+)
+
+// Default bounds on the delay between an event's failed attempts and its
+// next, matching those of the HTTP transport's retries within an attempt.
+const (
+	DefaultRetryBaseDelay = time.Second
+	DefaultRetryMaxDelay  = 30 * time.Second
+)
+
+// jitter returns a random duration in [0, n), replaceable in tests.
+var jitter = rand.Int63n
+
+// retryDelay returns how long to wait before resending an event that has
+// failed `attempts` times.
+//
+// The delay is chosen uniformly up to a window doubling from the base delay
+// with each attempt and capped at the max delay ("full jitter"), so agents
+// recovering from the same outage don't resend in lockstep.
+var retryDelay = func(attempts int, baseDelay, maxDelay time.Duration) time.Duration {
+	window := baseDelay
+	for i := 1; i < attempts && window < maxDelay; i++ {
+		window *= 2
+	}
+	if window > maxDelay {
+		window = maxDelay
+	}
+	if window <= 0 {
+		return 0
+	}
+	return time.Duration(jitter(int64(window) + 1))
+}
+
+// WithMaxRetries is an option giving each event a budget of `n` attempts to
+// be delivered, counted across restarts, before it's dead-lettered with its
+// last error.
+//
+// Each attempt is a send via the underlying event queue, which may itself
+// retry transport errors. Attempts failing with an error a later retry may
+// fix are resent after a growing delay; terminal failures, such as an invalid
+// event, are dead-lettered immediately. This is independent of any TTL, which
+// limits an event's age rather than its attempts. A budget of 0 or 1
+// dead-letters events on their first failure.
+func WithMaxRetries(n int) Option {
+	return func(q *PersistentQueue) {
+		q.maxRetries = n
+	}
+}
+
+// WithRetryDelay is an option bounding the delay before an event's next
+// attempt, which grows from `baseDelay` up to `maxDelay` with each failed
+// attempt. See `retryDelay`.
+func WithRetryDelay(baseDelay, maxDelay time.Duration) Option {
+	return func(q *PersistentQueue) {
+		q.retryBaseDelay = baseDelay
+		q.retryMaxDelay = maxDelay
+	}
+}
+
+// retryLater schedules an event's next attempt, returning false if it's out
+// of attempts or failed with an error resending won't fix.
+//
+// The event is stored as scheduled, so the attempt survives a restart, and
+// armed by the caller once its send has completed.
+func (q *PersistentQueue) retryLater(e *Event, resp eventqueue.Response) bool {
+	if !isRetryable(resp.Error) || e.Attempts >= q.maxRetries {
+		return false
+	}
+
+	e.Status = StatusScheduled
+	e.SendAt = q.clock.Now().Add(retryDelay(e.Attempts, q.retryBaseDelay, q.retryMaxDelay))
+	fields := append(eventLogFields(e, resp), "attempts", e.Attempts, "send_at", e.SendAt)
+	q.logger.Infow("Failed to send event, will retry.", fields...)
+	return true
+}
@@ -0,0 +1,363 @@
+package persistentqueue
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+// withRetryDelay replaces the delay between attempts, returning a function
+// restoring it.
+func withRetryDelay(d time.Duration) func() {
+	old := retryDelay
+	retryDelay = func(int, time.Duration, time.Duration) time.Duration { return d }
+	return func() { retryDelay = old }
+}
+
+// withMaxJitter makes each retry delay the longest it may be, returning a
+// function restoring it.
+func withMaxJitter() func() {
+	old := jitter
+	jitter = func(n int64) int64 { return n - 1 }
+	return func() { jitter = old }
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, window := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second} {
+		for i := 0; i < 100; i++ {
+			if delay := retryDelay(attempts, time.Second, 30*time.Second); delay < 0 || delay > window {
+				t.Fatalf("Expected a delay of up to %v after %v attempts, got %v.", window, attempts, delay)
+			}
+		}
+	}
+
+	defer withMaxJitter()()
+	if delay := retryDelay(1000, time.Second, 30*time.Second); delay != 30*time.Second {
+		t.Errorf("Expected the delay to be capped at 30s, got %v.", delay)
+	}
+}
+
+func TestPersistentQueueMaxRetries(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer withRetryDelay(10 * time.Millisecond)()
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500, Err: errors.New("server error")}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(3))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 3 {
+		t.Errorf("Expected 3 attempts, was sent %v times.", calls)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusError || event.Attempts != 3 {
+		t.Errorf("Expected %v after 3 attempts, was %v after %v.", StatusError, event.Status, event.Attempts)
+	}
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].Error != "server error (HTTP 500)" {
+		t.Errorf("Expected a dead letter with the last error, got %+v.", deadLetters)
+	}
+}
+
+func TestPersistentQueueMaxRetriesTerminalError(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer withRetryDelay(10 * time.Millisecond)()
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(3))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected a terminal failure not to be retried, was sent %v times.", calls)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusError {
+		t.Errorf("Expected %v, was %v.", StatusError, event.Status)
+	}
+}
+
+func TestPersistentQueueMaxRetriesTTL(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer withRetryDelay(100 * time.Millisecond)()
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(5))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected an expired event not to be retried, was sent %v times.", calls)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusError {
+		t.Errorf("Expected %v, was %v.", StatusError, event.Status)
+	}
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].Error != ErrEventExpired.Error() {
+		t.Errorf("Expected an expired dead letter, got %+v.", deadLetters)
+	}
+}
+
+func TestPersistentQueueMaxRetriesSurvivesRestart(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer withRetryDelay(100 * time.Millisecond)()
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(2))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	eq = NewMockEventQueue()
+	q = NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(2))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	time.Sleep(200 * time.Millisecond)
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusSuccess || event.Attempts != 2 {
+		t.Errorf("Expected %v on the second attempt, was %v after %v.", StatusSuccess, event.Status, event.Attempts)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 1 {
+		t.Errorf("Expected the retry to be sent once after restart, was sent %v times.", calls)
+	}
+}
+
+func TestPersistentQueueMaxRetriesBackoff(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer withMaxJitter()()
+
+	clock := test.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(3), WithClock(clock))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each failed attempt schedules the next after a doubling delay.
+	for attempt, delay := range []time.Duration{DefaultRetryBaseDelay, 2 * DefaultRetryBaseDelay} {
+		clock.BlockUntil(1)
+		if calls := atomic.LoadInt32(&eq.Calls); calls != int32(attempt+1) {
+			t.Fatalf("Expected %v attempts before the delay, was sent %v times.", attempt+1, calls)
+		}
+
+		clock.Advance(delay - time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		if calls := atomic.LoadInt32(&eq.Calls); calls != int32(attempt+1) {
+			t.Fatalf("Expected no retry before %v, was sent %v times.", delay, calls)
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 3 {
+		t.Errorf("Expected 3 attempts, was sent %v times.", calls)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusError || event.Attempts != 3 {
+		t.Errorf("Expected %v after 3 attempts, was %v after %v.", StatusError, event.Status, event.Attempts)
+	}
+}
+
+func TestPersistentQueueRetryEvent(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer withMaxJitter()()
+
+	clock := test.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithMaxRetries(2), WithClock(clock))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.BlockUntil(1)
+	clock.Advance(DefaultRetryBaseDelay)
+	eq.WaitForCalls(t, 2)
+	if err := q.waitForSends(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusError || event.Attempts != 2 {
+		t.Fatalf("Expected %v after 2 attempts, was %v after %v.", StatusError, event.Status, event.Attempts)
+	}
+
+	// A manual retry starts the attempts over, so when it fails too it's
+	// scheduled with the usual delay rather than dead-lettered or resent
+	// straight away.
+	if err := q.RetryEvent(event.ID); err != nil {
+		t.Fatal(err)
+	}
+	clock.BlockUntil(1)
+
+	event, err = q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusScheduled || event.Attempts != 1 || !event.SendAt.Equal(clock.Now().Add(DefaultRetryBaseDelay)) {
+		t.Errorf("Expected %v after 1 attempt, was %v after %v, next at %v.", StatusScheduled, event.Status, event.Attempts, event.SendAt)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 3 {
+		t.Errorf("Expected the retry to be sent once, was sent %v times in all.", calls)
+	}
+
+	// Retrying a scheduled event sends it now instead of when it's due.
+	if err := q.RetryEvent(event.ID); err != nil {
+		t.Fatal(err)
+	}
+	eq.WaitForCalls(t, 4)
+	if err := q.waitForSends(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 4 {
+		t.Errorf("Expected the scheduled event to be sent once more, was sent %v times in all.", calls)
+	}
+
+	eq.SetResponse(eventqueue.Response{})
+	clock.BlockUntil(1)
+	clock.Advance(DefaultRetryBaseDelay)
+	eq.WaitForCalls(t, 5)
+	if err := q.waitForSends(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err = q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusSuccess || event.Attempts != 2 {
+		t.Errorf("Expected %v after 2 attempts, was %v after %v.", StatusSuccess, event.Status, event.Attempts)
+	}
+	if err := q.RetryEvent(event.ID); err != ErrNotRetryable {
+		t.Errorf("Expected a delivered event not to be retryable, got %v.", err)
+	}
+}
+
+func TestPersistentQueueRetryRoutingKey(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	eq.Response = eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}}
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	for _, routingKey := range []string{"11863b592c824bfc8989d9cba76abcde", "21863b592c824bfc8989d9cba76abcde"} {
+		eventContainer := test.BuildV2EventContainer(routingKey)
+		if _, err := q.Enqueue(&eventContainer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the events in error for the routing key are counted.
+	if retried, err := q.Retry("11863b592c824bfc8989d9cba76abcde"); err != nil || retried != 1 {
+		t.Errorf("Expected 1 event to be retried, was %v (%v).", retried, err)
+	}
+	if _, err := q.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if retried, err := q.Retry(""); err != nil || retried != 2 {
+		t.Errorf("Expected 2 events to be retried, was %v (%v).", retried, err)
+	}
+}
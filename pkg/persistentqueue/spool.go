@@ -0,0 +1,159 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+)
+
+// spoolSweepInterval is how often spooled events are imported into the
+// store.
+var spoolSweepInterval = 30 * time.Second
+
+// WithSpool is an option falling back to appending events to a
+// newline-delimited JSON file at `path` should storing them fail, e.g. with
+// the disk full or the database unwritable, so they aren't lost.
+//
+// Spooled events are accepted as if they'd been stored, and imported into
+// the store once it's healthy again, checked on start and every
+// `spoolSweepInterval`. An empty path disables this.
+func WithSpool(path string) Option {
+	return func(q *PersistentQueue) {
+		q.spoolPath = path
+	}
+}
+
+// spool appends an event that couldn't be stored with `storeErr` to the
+// spool file, returning an error only if that fails too.
+func (q *PersistentQueue) spool(e *Event, storeErr error) error {
+	if q.spoolPath == "" {
+		q.logger.Errorf("Failed to create event %v: %v.", e.Key, storeErr)
+		return storeErr
+	}
+
+	line, err := json.Marshal(e)
+	if err == nil {
+		err = q.appendSpool(append(line, '\n'))
+	}
+	if err != nil {
+		q.logger.Errorf("Failed to create event %v: %v, and failed to spool it to %v: %v.", e.Key, storeErr, q.spoolPath, err)
+		return fmt.Errorf("%v, and failed to spool event: %v", storeErr, err)
+	}
+
+	q.logger.Errorw("Failed to store event, spooled it to be imported once the store recovers.", common.LogFieldEventID, e.Key, common.LogFieldRoutingKey, common.MaskSecret(e.RoutingKey), "spool", q.spoolPath, "error", storeErr)
+	return nil
+}
+
+func (q *PersistentQueue) appendSpool(line []byte) error {
+	q.spoolMu.Lock()
+	defer q.spoolMu.Unlock()
+
+	if err := os.MkdirAll(path.Dir(q.spoolPath), 0744); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(q.spoolPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// sweepSpool imports spooled events into the store, sending them as if just
+// enqueued, and returns how many were imported. Events that still can't be
+// stored are left in the spool for the next sweep.
+func (q *PersistentQueue) sweepSpool() (int, error) {
+	q.spoolMu.Lock()
+	defer q.spoolMu.Unlock()
+
+	data, err := ioutil.ReadFile(q.spoolPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	imported := 0
+	var importErr error
+	for len(lines) > 0 && !q.isStopping() {
+		var e Event
+		if err := json.Unmarshal(lines[0], &e); err != nil {
+			q.logger.Errorf("Discarding unreadable spooled event: %v", err)
+			lines = lines[1:]
+			continue
+		}
+
+		// Stored events are given a new ID, keeping their key.
+		e.ID = 0
+		if importErr = e.CreateAt(q.Store, q.clock.Now()); importErr != nil {
+			break
+		}
+		lines = lines[1:]
+		imported++
+		q.logger.Infof("Imported spooled event with key %v, ID %v.", e.Key, e.ID)
+		q.enqueued(&e)
+	}
+
+	if err := q.rewriteSpool(lines); err != nil {
+		return imported, err
+	}
+	return imported, importErr
+}
+
+// rewriteSpool replaces the spool with the events not yet imported, removing
+// it if there are none.
+func (q *PersistentQueue) rewriteSpool(lines [][]byte) error {
+	if len(lines) == 0 {
+		return os.Remove(q.spoolPath)
+	}
+
+	tmp := q.spoolPath + ".tmp"
+	data := append(bytes.Join(lines, []byte("\n")), '\n')
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.spoolPath)
+}
+
+// sweepSpoolPeriodically sweeps the spool until the queue shuts down.
+func (q *PersistentQueue) sweepSpoolPeriodically() {
+	defer close(q.spoolDone)
+
+	ticker := time.NewTicker(spoolSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		q.logSweep(q.sweepSpool())
+
+		select {
+		case <-ticker.C:
+		case <-q.spoolStop:
+			return
+		}
+	}
+}
+
+func (q *PersistentQueue) logSweep(imported int, err error) {
+	if imported > 0 {
+		q.logger.Warnf("Imported %v spooled events into the store.", imported)
+	}
+	if err != nil {
+		q.logger.Errorf("Failed to import spooled events from %v, will retry: %v", q.spoolPath, err)
+	}
+}
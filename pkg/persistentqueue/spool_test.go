@@ -0,0 +1,153 @@
+package persistentqueue
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var tmpSpoolFile = path.Join(tmpDir, "test.spool")
+
+// failingStore fails to create events while `fail` is set, as with a full
+// disk.
+type failingStore struct {
+	Store
+	fail *int32
+}
+
+func (s failingStore) CreateEvent(e *Event) error {
+	if atomic.LoadInt32(s.fail) == 1 {
+		return errors.New("disk full")
+	}
+	return s.Store.CreateEvent(e)
+}
+
+func spooledLines(t *testing.T) []string {
+	data, err := ioutil.ReadFile(tmpSpoolFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestPersistentQueueSpool(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer os.Remove(tmpSpoolFile)
+
+	eq := NewMockEventQueue()
+	q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithSpool(tmpSpoolFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	fail := int32(1)
+	q.Store = failingStore{Store: q.Store, fail: &fail}
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatalf("Expected the event to be spooled, got %v.", err)
+	}
+	if key == "" {
+		t.Error("Expected a key for the spooled event.")
+	}
+	if lines := spooledLines(t); len(lines) != 1 {
+		t.Errorf("Expected 1 spooled event, got %v.", lines)
+	}
+	if sent := eq.Sent(); len(sent) != 0 {
+		t.Errorf("Expected the spooled event not to be sent, got %v.", sent)
+	}
+
+	// Still failing, the event stays spooled.
+	if imported, err := q.sweepSpool(); imported != 0 || err == nil {
+		t.Errorf("Expected the sweep to fail, imported %v with %v.", imported, err)
+	}
+	if lines := spooledLines(t); len(lines) != 1 {
+		t.Errorf("Expected the event to stay spooled, got %v.", lines)
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	if imported, err := q.sweepSpool(); imported != 1 || err != nil {
+		t.Fatalf("Expected 1 event imported, imported %v with %v.", imported, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusSuccess {
+		t.Errorf("Expected the imported event to be sent, was %v.", event.Status)
+	}
+	if sent := eq.Sent(); len(sent) != 1 {
+		t.Errorf("Expected 1 event sent, got %v.", len(sent))
+	}
+	if _, err := os.Stat(tmpSpoolFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the spool to be removed once imported, got %v.", err)
+	}
+}
+
+func TestPersistentQueueSpoolDisabled(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	fail := int32(1)
+	q.Store = failingStore{Store: q.Store, fail: &fail}
+
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err == nil || err.Error() != "disk full" {
+		t.Errorf("Expected the store error without a spool, got %v.", err)
+	}
+}
+
+func TestPersistentQueueSpoolImportedOnStart(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+	defer os.Remove(tmpSpoolFile)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithFile(tmpDbFile), WithSpool(tmpSpoolFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	store := q.Store
+	fail := int32(1)
+	q.Store = failingStore{Store: store, fail: &fail}
+
+	key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Store = store
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	eq := NewMockEventQueue()
+	q = NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile), WithSpool(tmpSpoolFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	time.Sleep(100 * time.Millisecond)
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatalf("Expected the spooled event to be imported, got %v.", err)
+	}
+	if event.Status != StatusSuccess || len(eq.Sent()) != 1 {
+		t.Errorf("Expected the imported event to be sent once, was %v and sent %v times.", event.Status, len(eq.Sent()))
+	}
+}
@@ -0,0 +1,447 @@
+package persistentqueue
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteSchema stores events and dead letters as rows, so the queue can be
+// inspected with external tooling, e.g. `sqlite3 pdagent.db 'SELECT id,
+// status, attempts FROM events'`. Payloads are the events as JSON, and
+// timestamps are RFC 3339 in UTC, or NULL if unset. An event's
+// `next_attempt_at` is when it's next due to be sent, or NULL if due now.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	key             TEXT NOT NULL,
+	routing_key     TEXT NOT NULL,
+	status          TEXT NOT NULL,
+	payload         TEXT,
+	response_body   BLOB,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	dedup_key       TEXT NOT NULL DEFAULT '',
+	next_attempt_at TEXT,
+	expires_at      TEXT,
+	created_at      TEXT NOT NULL,
+	updated_at      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_key ON events (key);
+CREATE INDEX IF NOT EXISTS events_routing_key ON events (routing_key);
+CREATE INDEX IF NOT EXISTS events_status ON events (status);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_key   TEXT NOT NULL UNIQUE,
+	routing_key TEXT NOT NULL,
+	payload     TEXT,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error       TEXT NOT NULL,
+	retryable   INTEGER NOT NULL,
+	created_at  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS dead_letters_routing_key ON dead_letters (routing_key);
+
+CREATE TABLE IF NOT EXISTS settings (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+const eventColumns = "id, key, routing_key, status, payload, response_body, attempts, dedup_key, next_attempt_at, expires_at, created_at, updated_at"
+
+const deadLetterColumns = "id, event_key, routing_key, payload, status_code, error, retryable, created_at"
+
+// sqliteTimeFormat is RFC 3339 with a fixed number of fractional digits, so
+// timestamps sort as text.
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// sqliteStore is a `Store` in a SQLite database.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSQLiteStore opens a SQLite store at `path`, creating its schema if
+// need be, and waiting up to `lockTimeout` for another process to release it.
+//
+// As with BoltDB, the database is locked exclusively while open, and each
+// write is synced to disk before returning, using a write-ahead log.
+func openSQLiteStore(path string, lockTimeout time.Duration) (*sqliteStore, error) {
+	dsn := fmt.Sprintf("%v?_pragma=busy_timeout(%d)&_pragma=locking_mode(EXCLUSIVE)&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)",
+		path, lockTimeout.Milliseconds())
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single connection holds the lock for as long as the store is open.
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+
+	// Taking the lock now, rather than on the first write, so a second agent
+	// fails to start.
+	if _, err := db.Exec("BEGIN EXCLUSIVE; COMMIT;"); err != nil {
+		db.Close()
+		if isBusy(err) {
+			return nil, fmt.Errorf("%w: %v", ErrQueueLocked, path)
+		}
+		return nil, err
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+// isBusy returns true if an error is SQLite's, as the database is locked.
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+}
+
+// rowScanner is either a `*sql.Row` or `*sql.Rows`.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+func parseTime(s sql.NullString) (time.Time, error) {
+	if !s.Valid {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s.String)
+}
+
+func marshalPayload(eventContainer *eventsapi.EventContainer) (interface{}, error) {
+	if eventContainer == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(eventContainer)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func unmarshalPayload(payload sql.NullString) (*eventsapi.EventContainer, error) {
+	if !payload.Valid {
+		return nil, nil
+	}
+	var eventContainer eventsapi.EventContainer
+	if err := json.Unmarshal([]byte(payload.String), &eventContainer); err != nil {
+		return nil, err
+	}
+	return &eventContainer, nil
+}
+
+// eventValues returns an event's column values, in the order of
+// `eventColumns` without the ID.
+func eventValues(e *Event) ([]interface{}, error) {
+	payload, err := marshalPayload(e.Event)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		e.Key, e.RoutingKey, e.Status, payload, e.ResponseBody, e.Attempts, e.DedupKey,
+		formatTime(e.SendAt), formatTime(e.ExpiresAt), formatTime(e.CreatedAt), formatTime(e.UpdatedAt),
+	}, nil
+}
+
+func scanEvent(row rowScanner) (*Event, error) {
+	var e Event
+	var payload, sendAt, expiresAt, createdAt, updatedAt sql.NullString
+	if err := row.Scan(&e.ID, &e.Key, &e.RoutingKey, &e.Status, &payload, &e.ResponseBody, &e.Attempts, &e.DedupKey,
+		&sendAt, &expiresAt, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if e.Event, err = unmarshalPayload(payload); err != nil {
+		return nil, err
+	}
+	for _, t := range []struct {
+		to   *time.Time
+		from sql.NullString
+	}{{&e.SendAt, sendAt}, {&e.ExpiresAt, expiresAt}, {&e.CreatedAt, createdAt}, {&e.UpdatedAt, updatedAt}} {
+		if *t.to, err = parseTime(t.from); err != nil {
+			return nil, err
+		}
+	}
+	return &e, nil
+}
+
+func scanDeadLetter(row rowScanner) (*DeadLetter, error) {
+	var d DeadLetter
+	var payload, createdAt sql.NullString
+	if err := row.Scan(&d.ID, &d.EventKey, &d.RoutingKey, &payload, &d.StatusCode, &d.Error, &d.Retryable, &createdAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if d.Event, err = unmarshalPayload(payload); err != nil {
+		return nil, err
+	}
+	if d.CreatedAt, err = parseTime(createdAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// placeholders returns `n` comma-separated query placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (s *sqliteStore) CreateEvent(e *Event) error {
+	values, err := eventValues(e)
+	if err != nil {
+		return err
+	}
+
+	var id interface{}
+	if e.ID != 0 {
+		id = e.ID
+	}
+	result, err := s.db.Exec("INSERT INTO events ("+eventColumns+") VALUES ("+placeholders(12)+")", append([]interface{}{id}, values...)...)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.ID = int(inserted)
+	return nil
+}
+
+func (s *sqliteStore) UpdateEvent(e *Event) error {
+	values, err := eventValues(e)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`UPDATE events SET key = ?, routing_key = ?, status = ?, payload = ?, response_body = ?, attempts = ?, dedup_key = ?,
+		next_attempt_at = ?, expires_at = ?, created_at = ?, updated_at = ? WHERE id = ?`, append(values, e.ID)...)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s *sqliteStore) DeleteEvent(e *Event) error {
+	result, err := s.db.Exec("DELETE FROM events WHERE id = ?", e.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// requireAffected returns `ErrNotFound` if a statement changed no rows.
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqliteStore) findEvent(where string, args ...interface{}) (*Event, error) {
+	e, err := scanEvent(s.db.QueryRow("SELECT "+eventColumns+" FROM events WHERE "+where+" ORDER BY id LIMIT 1", args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return e, err
+}
+
+func (s *sqliteStore) FindEvent(id int) (*Event, error) {
+	return s.findEvent("id = ?", id)
+}
+
+func (s *sqliteStore) FindEventByKey(key string) (*Event, error) {
+	return s.findEvent("key = ?", key)
+}
+
+func (s *sqliteStore) FindEvents(query EventQuery) ([]Event, error) {
+	var conditions []string
+	var args []interface{}
+	if len(query.Statuses) > 0 {
+		conditions = append(conditions, "status IN ("+placeholders(len(query.Statuses))+")")
+		for _, status := range query.Statuses {
+			args = append(args, status)
+		}
+	}
+	if query.RoutingKey != "" {
+		conditions = append(conditions, "routing_key = ?")
+		args = append(args, query.RoutingKey)
+	}
+
+	statement := "SELECT " + eventColumns + " FROM events"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY id"
+	if query.Limit > 0 {
+		statement += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
+
+func (s *sqliteStore) CountEvents(statuses ...string) (int, error) {
+	if len(statuses) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM events WHERE status IN ("+placeholders(len(statuses))+")", args...).Scan(&count)
+	return count, err
+}
+
+func (s *sqliteStore) DeadLetters(routingKey string) ([]DeadLetter, error) {
+	statement := "SELECT " + deadLetterColumns + " FROM dead_letters"
+	var args []interface{}
+	if routingKey != "" {
+		statement += " WHERE routing_key = ?"
+		args = append(args, routingKey)
+	}
+
+	rows, err := s.db.Query(statement+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deadLetters []DeadLetter
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, *d)
+	}
+	return deadLetters, rows.Err()
+}
+
+func (s *sqliteStore) findDeadLetter(where string, args ...interface{}) (*DeadLetter, error) {
+	d, err := scanDeadLetter(s.db.QueryRow("SELECT "+deadLetterColumns+" FROM dead_letters WHERE "+where, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
+
+func (s *sqliteStore) FindDeadLetter(id int) (*DeadLetter, error) {
+	return s.findDeadLetter("id = ?", id)
+}
+
+func (s *sqliteStore) FindDeadLetterByEventKey(key string) (*DeadLetter, error) {
+	return s.findDeadLetter("event_key = ?", key)
+}
+
+func (s *sqliteStore) SaveDeadLetter(d *DeadLetter) error {
+	payload, err := marshalPayload(d.Event)
+	if err != nil {
+		return err
+	}
+
+	var id interface{}
+	if d.ID != 0 {
+		id = d.ID
+	}
+	result, err := s.db.Exec("INSERT OR REPLACE INTO dead_letters ("+deadLetterColumns+") VALUES ("+placeholders(8)+")",
+		id, d.EventKey, d.RoutingKey, payload, d.StatusCode, d.Error, d.Retryable, formatTime(d.CreatedAt))
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	d.ID = int(inserted)
+	return nil
+}
+
+func (s *sqliteStore) DeleteDeadLetter(d *DeadLetter) error {
+	result, err := s.db.Exec("DELETE FROM dead_letters WHERE id = ?", d.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+func (s *sqliteStore) CountDeadLetters() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM dead_letters").Scan(&count)
+	return count, err
+}
+
+func (s *sqliteStore) DeleteDeadLetters() error {
+	_, err := s.db.Exec("DELETE FROM dead_letters")
+	return err
+}
+
+func (s *sqliteStore) GetSetting(key string, value interface{}) error {
+	var data string
+	err := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), value)
+}
+
+func (s *sqliteStore) SetSetting(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, string(data))
+	return err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,66 @@
+package persistentqueue
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+// The queue can be inspected with external tooling, querying its columns
+// directly.
+func TestSQLiteStoreSchema(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := NewMockEventQueue()
+	clock := test.NewFakeClock(time.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC))
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithSQLite(), WithEventQueue(eq), WithClock(clock), WithMaintenance(true))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	key, err := q.Enqueue(&eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var id, attempts int
+	var status, payload, createdAt string
+	var nextAttemptAt sql.NullString
+	err = db.QueryRow("SELECT id, status, attempts, payload, next_attempt_at, created_at FROM events WHERE key = ?", key).
+		Scan(&id, &status, &attempts, &payload, &nextAttemptAt, &createdAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 || status != StatusPending || attempts != 0 || nextAttemptAt.Valid {
+		t.Errorf("Expected a pending event with no attempts, was %v, %v, %v, %v.", id, status, attempts, nextAttemptAt)
+	}
+	if createdAt != "2020-06-01T12:30:00.000000000Z" {
+		t.Errorf("Expected the creation time in UTC, was %q.", createdAt)
+	}
+
+	stored, err := unmarshalPayload(sql.NullString{String: payload, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := stored.UnmarshalEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.EventVersion != eventsapi.EventVersion2 || event.GetRoutingKey() != "11863b592c824bfc8989d9cba76abcde" {
+		t.Errorf("Expected the event as its payload, was %v.", payload)
+	}
+}
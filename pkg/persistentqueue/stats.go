@@ -0,0 +1,102 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// Stats is a small snapshot of the queue written to the stats file, see
+// `WithStatsFile`.
+type Stats struct {
+	Time time.Time `json:"time"`
+
+	// Depth counts events waiting to be sent, including those in flight or
+	// scheduled for a retry.
+	Depth               int        `json:"depth"`
+	Enqueued            int        `json:"enqueued"`
+	Delivered           int        `json:"delivered"`
+	DeadLettered        int        `json:"dead_lettered"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+}
+
+// WithStatsFile is an option writing a JSON `Stats` snapshot to `path` on
+// start and every `interval` after, for monitoring without scraping
+// `/metrics`. Each write replaces the file atomically, so readers never see
+// a partial snapshot. An empty path disables this.
+func WithStatsFile(path string, interval time.Duration) Option {
+	return func(q *PersistentQueue) {
+		q.statsPath = path
+		q.statsInterval = interval
+	}
+}
+
+// Stats returns a snapshot of the queue's depth and delivery counters.
+//
+// Counters cover the lifetime of the process, as with `Metrics`.
+func (q *PersistentQueue) Stats() (Stats, error) {
+	depth, err := q.Store.CountEvents(queuedStatuses...)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	q.metrics.mu.Lock()
+	defer q.metrics.mu.Unlock()
+
+	stats := Stats{
+		Time:                q.clock.Now(),
+		Depth:               depth,
+		Enqueued:            q.metrics.enqueued,
+		Delivered:           q.metrics.delivered,
+		DeadLettered:        q.metrics.deadLettered,
+		ConsecutiveFailures: q.metrics.consecutiveFailures,
+	}
+	if !q.metrics.lastSuccess.IsZero() {
+		lastSuccess := q.metrics.lastSuccess
+		stats.LastSuccess = &lastSuccess
+	}
+	return stats, nil
+}
+
+// writeStats writes the current stats to a temporary file alongside the
+// stats file, then renames it into place.
+func (q *PersistentQueue) writeStats() error {
+	stats, err := q.Stats()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(q.statsPath), 0744); err != nil {
+		return err
+	}
+	tmp := q.statsPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.statsPath)
+}
+
+// writeStatsPeriodically writes the stats file every `statsInterval` until
+// the queue shuts down.
+func (q *PersistentQueue) writeStatsPeriodically() {
+	defer close(q.statsDone)
+
+	for {
+		if err := q.writeStats(); err != nil {
+			q.logger.Errorf("Failed to write stats to %v: %v", q.statsPath, err)
+		}
+
+		select {
+		case <-q.clock.After(q.statsInterval):
+		case <-q.statsStop:
+			return
+		}
+	}
+}
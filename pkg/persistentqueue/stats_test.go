@@ -0,0 +1,77 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func readStats(t *testing.T, statsFile string) Stats {
+	data, err := ioutil.ReadFile(statsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats Stats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+func TestPersistentQueueStatsFile(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	statsFile := path.Join(tmpDir, "stats", "stats.json")
+	defer os.RemoveAll(path.Dir(statsFile))
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := test.NewFakeClock(start)
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithFile(tmpDbFile), WithClock(clock), WithStatsFile(statsFile, time.Minute))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	// Written on start, then waiting for the interval.
+	clock.BlockUntil(1)
+	stats := readStats(t, statsFile)
+	if !stats.Time.Equal(start) || stats.Depth != 0 || stats.Enqueued != 0 || stats.LastSuccess != nil {
+		t.Errorf("Expected empty stats on start, got %+v.", stats)
+	}
+
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(ttlEventContainer("trigger", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged until the interval elapses.
+	if stats := readStats(t, statsFile); stats.Enqueued != 0 {
+		t.Errorf("Expected stats not to be written before the interval, got %+v.", stats)
+	}
+
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	stats = readStats(t, statsFile)
+	if !stats.Time.Equal(start.Add(time.Minute)) || stats.Depth != 1 || stats.Enqueued != 2 || stats.Delivered != 1 {
+		t.Errorf("Expected stats with one event delivered and one pending, got %+v.", stats)
+	}
+	if stats.LastSuccess == nil || !stats.LastSuccess.Equal(start) {
+		t.Errorf("Expected the last success at %v, got %v.", start, stats.LastSuccess)
+	}
+
+	if _, err := os.Stat(statsFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be renamed into place, got %v.", err)
+	}
+}
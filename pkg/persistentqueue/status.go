@@ -9,15 +9,9 @@ type StatusItem struct {
 
 // Returns aggregate stats per routing key for pending and enqueued events.
 func (q *PersistentQueue) Status(routingKey string) ([]StatusItem, error) {
-	var err error
-	var events []Event
 	agg := map[string]*StatusItem{}
 
-	if routingKey == "" {
-		err = q.Events.All(&events)
-	} else {
-		err = q.Events.Find("RoutingKey", routingKey, &events)
-	}
+	events, err := q.Store.FindEvents(EventQuery{RoutingKey: routingKey})
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +23,7 @@ func (q *PersistentQueue) Status(routingKey string) ([]StatusItem, error) {
 		}
 
 		switch event.Status {
-		case StatusPending:
+		case StatusPending, StatusInFlight:
 			item.Pending++
 		case StatusSuccess:
 			item.Success++
@@ -47,3 +41,40 @@ func (q *PersistentQueue) Status(routingKey string) ([]StatusItem, error) {
 
 	return items, nil
 }
+
+// EventStatus is the delivery state of a single event.
+type EventStatus struct {
+	Key      string `json:"key"`
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	DedupKey string `json:"dedup_key,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// EventStatus returns the delivery state of the event with `key`, including
+// the dedup key PagerDuty returned once it's delivered or, should it fail,
+// the error from its dead letter.
+func (q *PersistentQueue) EventStatus(key string) (EventStatus, error) {
+	e, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		return EventStatus{}, err
+	}
+
+	status := EventStatus{
+		Key:      e.Key,
+		Status:   e.Status,
+		Attempts: e.Attempts,
+		DedupKey: e.DedupKey,
+	}
+
+	if e.Status == StatusError {
+		deadLetter, err := q.Store.FindDeadLetterByEventKey(e.Key)
+		if err == nil {
+			status.Error = deadLetter.Error
+		} else if err != ErrNotFound {
+			return EventStatus{}, err
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,72 @@
+package persistentqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+func TestPersistentQueueEventStatus(t *testing.T) {
+	tests := []struct {
+		name             string
+		response         eventqueue.Response
+		expectedStatus   string
+		expectedDedupKey string
+		expectedError    bool
+	}{
+		{"delivered", dedupKeyResponse("returned-dedup-key"), StatusSuccess, "returned-dedup-key", false},
+		{"failed", eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}, StatusError, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			defer teardown(t)
+
+			eq := NewMockEventQueue()
+			eq.Response = tt.response
+			q := NewPersistentQueue(WithEventQueue(eq), WithFile(tmpDbFile))
+			if err := q.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer q.Shutdown()
+
+			key, err := q.Enqueue(ttlEventContainer("trigger", 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(50 * time.Millisecond)
+
+			status, err := q.EventStatus(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Key != key || status.Status != tt.expectedStatus {
+				t.Errorf("Expected %v to be %v, was %v.", key, tt.expectedStatus, status.Status)
+			}
+			if status.DedupKey != tt.expectedDedupKey {
+				t.Errorf("Expected dedup key %q, was %q.", tt.expectedDedupKey, status.DedupKey)
+			}
+			if tt.expectedError != (status.Error != "") {
+				t.Errorf("Expected error %v, was %q.", tt.expectedError, status.Error)
+			}
+		})
+	}
+}
+
+func TestPersistentQueueEventStatusNotFound(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithEventQueue(NewMockEventQueue()), WithFile(tmpDbFile))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if _, err := q.EventStatus("missing"); err == nil {
+		t.Error("Expected an error for an unknown event.")
+	}
+}
@@ -0,0 +1,63 @@
+package persistentqueue
+
+import "errors"
+
+// ErrNotFound is returned by a `Store` when no event, dead letter, or setting
+// matches.
+var ErrNotFound = errors.New("not found")
+
+// Queue storage backends, as selected with the server's `--queue-backend`.
+const (
+	BackendBolt   = "bolt"
+	BackendMemory = "memory"
+	BackendSQLite = "sqlite"
+)
+
+// EventQuery selects events from a `Store`. Empty fields match all events,
+// and a zero `Limit` returns every match.
+type EventQuery struct {
+	Statuses   []string
+	RoutingKey string
+	Limit      int
+}
+
+// Store persists a queue's events, dead letters, and settings.
+//
+// Writes are durable once they return, so an event stored before a crash is
+// sent on the next start. Events are returned in the order they were stored,
+// by ID, and as copies: changes are only persisted by `UpdateEvent`.
+type Store interface {
+	// CreateEvent stores a new event, assigning its ID.
+	CreateEvent(e *Event) error
+
+	// UpdateEvent replaces a stored event, returning `ErrNotFound` should it
+	// have been deleted.
+	UpdateEvent(e *Event) error
+
+	DeleteEvent(e *Event) error
+	FindEvent(id int) (*Event, error)
+	FindEventByKey(key string) (*Event, error)
+	FindEvents(query EventQuery) ([]Event, error)
+
+	// CountEvents counts events with any of `statuses`.
+	CountEvents(statuses ...string) (int, error)
+
+	// DeadLetters returns dead letters for a routing key, or for all routing
+	// keys if it's empty.
+	DeadLetters(routingKey string) ([]DeadLetter, error)
+	FindDeadLetter(id int) (*DeadLetter, error)
+	FindDeadLetterByEventKey(key string) (*DeadLetter, error)
+
+	// SaveDeadLetter stores a new dead letter, assigning its ID, or replaces
+	// an existing one.
+	SaveDeadLetter(d *DeadLetter) error
+	DeleteDeadLetter(d *DeadLetter) error
+	CountDeadLetters() (int, error)
+	DeleteDeadLetters() error
+
+	// GetSetting reads a setting, such as maintenance mode, into `value`.
+	GetSetting(key string, value interface{}) error
+	SetSetting(key string, value interface{}) error
+
+	Close() error
+}
@@ -0,0 +1,332 @@
+package persistentqueue
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+// backends are the queue's storage backends, each run against the shared
+// queue behaviors.
+var backends = []struct {
+	name    string
+	options []Option
+	durable bool
+}{
+	{BackendBolt, []Option{WithFile(tmpDbFile)}, true},
+	{BackendSQLite, []Option{WithFile(tmpDbFile), WithSQLite()}, true},
+	{BackendMemory, []Option{WithMemory()}, false},
+}
+
+// queueBehaviors are run against each queue backend, which should be
+// indistinguishable other than in what survives a restart.
+var queueBehaviors = []struct {
+	name string
+	run  func(*testing.T, *PersistentQueue, *MockEventQueue)
+}{
+	{"send", testQueueSend},
+	{"deadLetterAndRetry", testQueueDeadLetterAndRetry},
+	{"replay", testQueueReplay},
+	{"maintenance", testQueueMaintenance},
+	{"list", testQueueList},
+	{"purge", testQueuePurge},
+}
+
+func TestPersistentQueueBackends(t *testing.T) {
+	for _, backend := range backends {
+		for _, behavior := range queueBehaviors {
+			t.Run(backend.name+"/"+behavior.name, func(t *testing.T) {
+				setup(t)
+				defer teardown(t)
+
+				eq := NewMockEventQueue()
+				q := NewPersistentQueue(append(backend.options, WithEventQueue(eq))...)
+				if err := q.Start(); err != nil {
+					t.Fatalf("Error starting persistent queue: %v", err)
+				}
+				defer q.Shutdown()
+
+				behavior.run(t, q, eq)
+			})
+		}
+	}
+}
+
+// Durable backends keep undelivered events, dead letters, and maintenance
+// mode across a restart.
+func TestPersistentQueueBackendsRestart(t *testing.T) {
+	for _, backend := range backends {
+		if !backend.durable {
+			continue
+		}
+
+		t.Run(backend.name, func(t *testing.T) {
+			setup(t)
+			defer teardown(t)
+
+			eq := NewMockEventQueue()
+			eq.SetResponse(eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}})
+			q := NewPersistentQueue(append(backend.options, WithEventQueue(eq))...)
+			if err := q.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			failed := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+			if _, err := q.Enqueue(&failed); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := q.Flush(time.Second); err != nil {
+				t.Fatal(err)
+			}
+			if err := q.SetMaintenance(true); err != nil {
+				t.Fatal(err)
+			}
+			pending := test.BuildV2EventContainer("22863b592c824bfc8989d9cba76abcde")
+			key, err := q.Enqueue(&pending)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := q.Shutdown(); err != nil {
+				t.Fatal(err)
+			}
+
+			q = NewPersistentQueue(append(backend.options, WithEventQueue(NewMockEventQueue()))...)
+			if err := q.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer q.Shutdown()
+
+			if !q.Maintenance() {
+				t.Error("Expected maintenance mode to be restored.")
+			}
+			e, err := q.Store.FindEventByKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Status != StatusPending || e.RoutingKey != "22863b592c824bfc8989d9cba76abcde" {
+				t.Errorf("Expected the undelivered event to be restored, was %+v.", e)
+			}
+			deadLetters, err := q.DeadLetters("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deadLetters) != 1 || deadLetters[0].Error != "invalid event (HTTP 400)" || deadLetters[0].Retryable {
+				t.Errorf("Expected the dead letter to be restored, was %+v.", deadLetters)
+			}
+		})
+	}
+}
+
+// Durable backends lock their database, so a second agent can't share it.
+func TestPersistentQueueBackendsLocked(t *testing.T) {
+	for _, backend := range backends {
+		if !backend.durable {
+			continue
+		}
+
+		t.Run(backend.name, func(t *testing.T) {
+			setup(t)
+			defer teardown(t)
+
+			first := NewPersistentQueue(append(backend.options, WithEventQueue(NewMockEventQueue()))...)
+			if err := first.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer first.Shutdown()
+
+			second := NewPersistentQueue(append(backend.options, WithEventQueue(NewMockEventQueue()))...)
+			if err := second.Start(); !errors.Is(err, ErrQueueLocked) {
+				t.Errorf("Expected ErrQueueLocked opening a queue already in use, got %v.", err)
+			}
+		})
+	}
+}
+
+func testQueueSend(t *testing.T, q *PersistentQueue, eq *MockEventQueue) {
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	key, err := q.Enqueue(&eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != StatusSuccess || event.Attempts != 1 {
+		t.Errorf("Expected event to be sent once, was %v after %v attempts.", event.Status, event.Attempts)
+	}
+}
+
+func testQueueDeadLetterAndRetry(t *testing.T, q *PersistentQueue, eq *MockEventQueue) {
+	eq.SetResponse(eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}})
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	if _, err := q.Enqueue(&eventContainer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 {
+		t.Fatalf("Expected one dead letter, found %v.", len(deadLetters))
+	}
+
+	eq.SetResponse(eventqueue.Response{})
+	if err := q.RetryDeadLetter(deadLetters[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if deadLetters, err = q.DeadLetters(""); err != nil || len(deadLetters) != 0 {
+		t.Errorf("Expected dead letter to be removed after retry, found %v (%v).", len(deadLetters), err)
+	}
+}
+
+func testQueueReplay(t *testing.T, q *PersistentQueue, eq *MockEventQueue) {
+	eq.SetResponse(eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}})
+
+	eventContainer := ttlEventContainer("trigger", time.Hour)
+	key, err := q.Enqueue(eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	deadLetters, err := q.DeadLetters("")
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("Expected one dead letter, found %v (%v).", len(deadLetters), err)
+	}
+
+	// Attempts and expiry are cleared, as well as set, by the store.
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Replay(deadLetters[0].ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	e, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != StatusPending || e.Attempts != 0 || !e.ExpiresAt.IsZero() {
+		t.Errorf("Expected the replayed event to start over, was %v after %v attempts expiring at %v.", e.Status, e.Attempts, e.ExpiresAt)
+	}
+}
+
+func testQueueMaintenance(t *testing.T, q *PersistentQueue, eq *MockEventQueue) {
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+
+	eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	key, err := q.Enqueue(&eventContainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := atomic.LoadInt32(&eq.Calls); calls != 0 {
+		t.Errorf("Expected no events to be sent during maintenance, %v were sent.", calls)
+	}
+
+	if err := q.SetMaintenance(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	e, err := q.Store.FindEventByKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != StatusSuccess || atomic.LoadInt32(&eq.Calls) != 1 {
+		t.Errorf("Expected event to be sent once after maintenance, was %v after %v sends.", e.Status, atomic.LoadInt32(&eq.Calls))
+	}
+}
+
+func testQueueList(t *testing.T, q *PersistentQueue, eq *MockEventQueue) {
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, routingKey := range []string{"11863b592c824bfc8989d9cba76abcde", "22863b592c824bfc8989d9cba76abcde", "11863b592c824bfc8989d9cba76abcde"} {
+		eventContainer := test.BuildV2EventContainer(routingKey)
+		key, err := q.Enqueue(&eventContainer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	tests := []struct {
+		name     string
+		options  ListOptions
+		expected []string
+	}{
+		{"all", ListOptions{}, keys},
+		{"status", ListOptions{Status: StatusPending}, keys},
+		{"noMatchingStatus", ListOptions{Status: StatusError}, nil},
+		{"routingKey", ListOptions{RoutingKey: "11863b592c824bfc8989d9cba76abcde"}, []string{keys[0], keys[2]}},
+		{"limit", ListOptions{Limit: 2}, keys[:2]},
+	}
+
+	for _, tt := range tests {
+		events, err := q.List(tt.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var listed []string
+		for _, e := range events {
+			listed = append(listed, e.Key)
+		}
+		if len(listed) != len(tt.expected) {
+			t.Errorf("%v: expected %v in the order enqueued, was %v.", tt.name, tt.expected, listed)
+			continue
+		}
+		for i := range listed {
+			if listed[i] != tt.expected[i] {
+				t.Errorf("%v: expected %v in the order enqueued, was %v.", tt.name, tt.expected, listed)
+				break
+			}
+		}
+	}
+}
+
+func testQueuePurge(t *testing.T, q *PersistentQueue, eq *MockEventQueue) {
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		eventContainer := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+		if _, err := q.Enqueue(&eventContainer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := q.Purge(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (PurgeResult{Pending: 2}) {
+		t.Errorf("Expected 2 pending events purged, result was %+v.", result)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/version"
+)
+
+// AgentStatusHandler reports overall agent health, e.g. for operators
+// checking whether events are being delivered.
+func (s *Server) AgentStatusHandler(rw http.ResponseWriter, _ *http.Request) {
+	health, err := s.Queue.Health()
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	resp := AgentStatusResponse{
+		Version:             version.Version,
+		QueueDepth:          health.Pending,
+		ConsecutiveFailures: health.ConsecutiveFailures,
+		Maintenance:         health.Maintenance,
+	}
+	if s.Breaker != nil {
+		resp.CircuitBreaker = s.Breaker.State()
+	}
+	if !health.OldestPending.IsZero() {
+		resp.OldestPendingAgeSeconds = time.Since(health.OldestPending).Seconds()
+	}
+	if !health.LastSuccess.IsZero() {
+		lastSuccess := health.LastSuccess.UTC()
+		resp.LastSuccessAt = &lastSuccess
+	}
+
+	okResp(rw, resp)
+}
+
+type AgentStatusResponse struct {
+	Version                 string     `json:"version"`
+	QueueDepth              int        `json:"queue_depth"`
+	OldestPendingAgeSeconds float64    `json:"oldest_pending_age_seconds"`
+	ConsecutiveFailures     int        `json:"consecutive_failures"`
+	LastSuccessAt           *time.Time `json:"last_success_at,omitempty"`
+	CircuitBreaker          string     `json:"circuit_breaker,omitempty"`
+	Maintenance             bool       `json:"maintenance"`
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/version"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestAgentStatusHandler(t *testing.T) {
+	release := make(chan struct{})
+
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		<-release
+		job.ResponseChan <- eventqueue.Response{Error: errors.New("server error")}
+	}
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	event := test.BuildV2EventContainer(common.GenerateKey())
+	if _, err := q.Enqueue(&event); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	status := getAgentStatus(t, s)
+
+	if status.Version != version.Version {
+		t.Errorf("Expected version %v, was %v.", version.Version, status.Version)
+	}
+	if status.QueueDepth != 1 {
+		t.Errorf("Expected one pending event, was %v.", status.QueueDepth)
+	}
+	if status.OldestPendingAgeSeconds <= 0 {
+		t.Errorf("Expected oldest pending age to be set, was %v.", status.OldestPendingAgeSeconds)
+	}
+
+	close(release)
+	time.Sleep(100 * time.Millisecond)
+	status = getAgentStatus(t, s)
+
+	if status.QueueDepth != 0 || status.OldestPendingAgeSeconds != 0 {
+		t.Errorf("Expected no pending events, was %v aged %v.", status.QueueDepth, status.OldestPendingAgeSeconds)
+	}
+	if status.ConsecutiveFailures != 1 {
+		t.Errorf("Expected one consecutive failure, was %v.", status.ConsecutiveFailures)
+	}
+	if status.LastSuccessAt != nil {
+		t.Errorf("Expected no last successful send, was %v.", status.LastSuccessAt)
+	}
+}
+
+func TestAgentStatusHandler_circuitBreaker(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eventqueue.NewEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+	if status := getAgentStatus(t, s); status.CircuitBreaker != "" {
+		t.Errorf("Expected no circuit breaker state, was %v.", status.CircuitBreaker)
+	}
+
+	breaker := eventqueue.NewCircuitBreaker(1, time.Minute)
+	s = NewServer("127.0.0.1:0", "secret", "", q, WithCircuitBreaker(breaker))
+	if status := getAgentStatus(t, s); status.CircuitBreaker != eventqueue.BreakerClosed {
+		t.Errorf("Expected closed circuit breaker, was %v.", status.CircuitBreaker)
+	}
+
+	breaker.Record(true)
+	if status := getAgentStatus(t, s); status.CircuitBreaker != eventqueue.BreakerOpen {
+		t.Errorf("Expected open circuit breaker, was %v.", status.CircuitBreaker)
+	}
+}
+
+func getAgentStatus(t *testing.T, s *Server) AgentStatusResponse {
+	rw := httptest.NewRecorder()
+	s.AgentStatusHandler(rw, httptest.NewRequest("GET", "/status", nil))
+
+	if rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	var status AgentStatusResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// alertmanagerRoutingKeyLabel overrides the default routing key for a group.
+const alertmanagerRoutingKeyLabel = "pagerduty_routing_key"
+
+// Limits from the Events API, beyond which events are rejected.
+const (
+	maxDedupKeyLength = 255
+	maxSummaryLength  = 1024
+)
+
+var alertmanagerToPagerDutyEventAction = map[string]string{
+	"firing":   "trigger",
+	"resolved": "resolve",
+}
+
+// AlertmanagerWebhook corresponds to a version 4 Alertmanager webhook
+// payload, describing a single group of alerts.
+type AlertmanagerWebhook struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	TruncatedAlerts   int                 `json:"truncatedAlerts"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts"`
+}
+
+// AlertmanagerAlert corresponds to an individual alert within a webhook.
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint,omitempty"`
+}
+
+// AlertmanagerHandler acts as an Alertmanager webhook receiver, enqueuing one
+// v2 event per group of alerts.
+//
+// Firing groups trigger and resolved groups resolve an incident deduplicated
+// by the group key.
+func (s *Server) AlertmanagerHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		errorResp(rw, 405, []string{"Expected a POST request."})
+		return
+	}
+
+	if !s.authorizeIngest(rw, req) {
+		return
+	}
+
+	var webhook AlertmanagerWebhook
+	decoder := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxIngestBodyBytes))
+	if err := decoder.Decode(&webhook); err != nil {
+		errorResp(rw, 400, []string{"Expected an Alertmanager webhook JSON body of at most 512KB: " + err.Error()})
+		return
+	}
+
+	event, err := buildAlertmanagerEvent(webhook, s.alertmanagerRoutingKey())
+	if err != nil {
+		errorResp(rw, 400, []string{err.Error()})
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	eventContainer := eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData:    body,
+		Integration:  "alertmanager",
+	}
+
+	key, err := s.Queue.Enqueue(&eventContainer)
+	if err == persistentqueue.ErrQueueShutdown || err == persistentqueue.ErrQueueFull || err == persistentqueue.ErrQueueDraining {
+		errorResp(rw, 503, []string{err.Error()})
+		return
+	} else if err == eventsapi.ErrInvalidRoutingKey {
+		errorResp(rw, 400, []string{err.Error()})
+		return
+	} else if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, SendResponse{Key: key})
+}
+
+func buildAlertmanagerEvent(webhook AlertmanagerWebhook, defaultRoutingKey string) (*eventsapi.EventV2, error) {
+	if webhook.Version != "4" {
+		return nil, fmt.Errorf("unsupported Alertmanager webhook version %q, expected \"4\"", webhook.Version)
+	}
+
+	eventAction, ok := alertmanagerToPagerDutyEventAction[webhook.Status]
+	if !ok {
+		return nil, fmt.Errorf("unsupported Alertmanager status %q, expected \"firing\" or \"resolved\"", webhook.Status)
+	}
+
+	if webhook.GroupKey == "" {
+		return nil, fmt.Errorf("groupKey is required")
+	}
+
+	routingKey := webhook.CommonLabels[alertmanagerRoutingKeyLabel]
+	if routingKey == "" {
+		routingKey = defaultRoutingKey
+	}
+	if routingKey == "" {
+		return nil, fmt.Errorf("no routing key, expected a %v label or a configured default", alertmanagerRoutingKeyLabel)
+	}
+
+	severity := webhook.CommonLabels["severity"]
+	if !contains(allowedIngestSeverities, severity) {
+		severity = "error"
+	}
+
+	source := webhook.CommonLabels["instance"]
+	if source == "" {
+		source = webhook.ExternalURL
+	}
+	if source == "" {
+		source = "alertmanager"
+	}
+
+	firing, resolved := describeAlertmanagerAlerts(webhook.Alerts)
+
+	event := eventsapi.EventV2{
+		RoutingKey:  routingKey,
+		EventAction: eventAction,
+		DedupKey:    alertmanagerDedupKey(webhook.GroupKey),
+		Payload: eventsapi.PayloadV2{
+			Summary:  buildAlertmanagerSummary(webhook, firing),
+			Source:   source,
+			Severity: severity,
+			Class:    webhook.CommonLabels["alertname"],
+			CustomDetails: map[string]interface{}{
+				"firing":             firing,
+				"resolved":           resolved,
+				"num_firing":         len(firing),
+				"num_resolved":       len(resolved),
+				"truncated_alerts":   webhook.TruncatedAlerts,
+				"group_labels":       webhook.GroupLabels,
+				"common_labels":      webhook.CommonLabels,
+				"common_annotations": webhook.CommonAnnotations,
+			},
+		},
+	}
+
+	if webhook.ExternalURL != "" {
+		event.Links = []eventsapi.LinkV2{{Href: webhook.ExternalURL, Text: "Alertmanager"}}
+	}
+
+	return &event, nil
+}
+
+// describeAlertmanagerAlerts splits alerts into brief descriptions of those
+// firing and resolved.
+func describeAlertmanagerAlerts(alerts []AlertmanagerAlert) ([]string, []string) {
+	firing := []string{}
+	resolved := []string{}
+	for _, alert := range alerts {
+		description := formatLabels(alert.Labels)
+		if summary := alert.Annotations["summary"]; summary != "" {
+			description = summary + " " + description
+		}
+
+		if alert.Status == "resolved" {
+			resolved = append(resolved, description)
+		} else {
+			firing = append(firing, description)
+		}
+	}
+	return firing, resolved
+}
+
+// buildAlertmanagerSummary uses the group's common summary annotation if
+// there is one, otherwise mimicking Alertmanager's default title, e.g.
+// `[FIRING:2] HighLatency (job=api)`.
+func buildAlertmanagerSummary(webhook AlertmanagerWebhook, firing []string) string {
+	summary := webhook.CommonAnnotations["summary"]
+	if summary == "" {
+		summary = fmt.Sprintf("[%v:%v] %v", strings.ToUpper(webhook.Status), len(firing), formatLabels(webhook.GroupLabels))
+	}
+
+	if len(summary) > maxSummaryLength {
+		summary = summary[:maxSummaryLength]
+	}
+	return summary
+}
+
+// alertmanagerDedupKey uses the group key as is unless it's too long, in
+// which case a hash of it is used.
+func alertmanagerDedupKey(groupKey string) string {
+	if len(groupKey) <= maxDedupKeyLength {
+		return groupKey
+	}
+
+	sum := sha256.Sum256([]byte(groupKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// formatLabels formats labels as `alertname (k1=v1, k2=v2)`, sorted by key.
+func formatLabels(labels map[string]string) string {
+	var pairs []string
+	for k, v := range labels {
+		if k != "alertname" {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	sort.Strings(pairs)
+
+	formatted := labels["alertname"]
+	if len(pairs) > 0 {
+		formatted = strings.TrimSpace(formatted + " (" + strings.Join(pairs, ", ") + ")")
+	}
+	return formatted
+}
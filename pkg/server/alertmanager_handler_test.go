@@ -0,0 +1,221 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+const alertmanagerFiringPayload = `{
+	"version": "4",
+	"groupKey": "{}/{severity=\"critical\"}:{alertname=\"HighLatency\"}",
+	"truncatedAlerts": 0,
+	"status": "firing",
+	"receiver": "pdagent",
+	"groupLabels": {"alertname": "HighLatency"},
+	"commonLabels": {"alertname": "HighLatency", "job": "api", "severity": "critical"},
+	"commonAnnotations": {},
+	"externalURL": "http://alertmanager.example.com:9093",
+	"alerts": [
+		{
+			"status": "firing",
+			"labels": {"alertname": "HighLatency", "instance": "api-1:9100", "job": "api", "severity": "critical"},
+			"annotations": {"summary": "p99 latency above 1s"},
+			"startsAt": "2020-07-17T08:42:58Z",
+			"endsAt": "0001-01-01T00:00:00Z",
+			"generatorURL": "http://prometheus.example.com:9090/graph",
+			"fingerprint": "c48bb2a5a1f7e8f0"
+		},
+		{
+			"status": "firing",
+			"labels": {"alertname": "HighLatency", "instance": "api-2:9100", "job": "api", "severity": "critical"},
+			"annotations": {"summary": "p99 latency above 1s"},
+			"startsAt": "2020-07-17T08:43:12Z",
+			"endsAt": "0001-01-01T00:00:00Z",
+			"generatorURL": "http://prometheus.example.com:9090/graph",
+			"fingerprint": "2d8a2cd3b4b1f1a9"
+		}
+	]
+}`
+
+const alertmanagerResolvedPayload = `{
+	"version": "4",
+	"groupKey": "{}/{severity=\"critical\"}:{alertname=\"HighLatency\"}",
+	"status": "resolved",
+	"receiver": "pdagent",
+	"groupLabels": {"alertname": "HighLatency"},
+	"commonLabels": {"alertname": "HighLatency", "job": "api", "severity": "critical", "pagerduty_routing_key": "22863b592c824bfc8989d9cba76abcde"},
+	"commonAnnotations": {"summary": "p99 latency above 1s"},
+	"externalURL": "http://alertmanager.example.com:9093",
+	"alerts": [
+		{
+			"status": "resolved",
+			"labels": {"alertname": "HighLatency", "instance": "api-1:9100", "job": "api", "severity": "critical"},
+			"annotations": {"summary": "p99 latency above 1s"},
+			"startsAt": "2020-07-17T08:42:58Z",
+			"endsAt": "2020-07-17T09:01:00Z",
+			"generatorURL": "http://prometheus.example.com:9090/graph"
+		}
+	]
+}`
+
+const defaultAlertmanagerRoutingKey = "11863b592c824bfc8989d9cba76abcde"
+
+func TestBuildAlertmanagerEventFiring(t *testing.T) {
+	var webhook AlertmanagerWebhook
+	if err := json.Unmarshal([]byte(alertmanagerFiringPayload), &webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := buildAlertmanagerEvent(webhook, defaultAlertmanagerRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.RoutingKey != defaultAlertmanagerRoutingKey {
+		t.Errorf("Expected default routing key, was %v.", event.RoutingKey)
+	}
+	if event.EventAction != "trigger" {
+		t.Errorf("Expected firing group to trigger, was %v.", event.EventAction)
+	}
+	if event.DedupKey != webhook.GroupKey {
+		t.Errorf("Expected dedup key to be the group key, was %v.", event.DedupKey)
+	}
+	if event.Payload.Summary != "[FIRING:2] HighLatency" {
+		t.Errorf("Unexpected summary %q.", event.Payload.Summary)
+	}
+	if event.Payload.Severity != "critical" {
+		t.Errorf("Expected critical severity, was %v.", event.Payload.Severity)
+	}
+	if event.Payload.Source != "http://alertmanager.example.com:9093" {
+		t.Errorf("Expected external URL as source, was %v.", event.Payload.Source)
+	}
+
+	firing := event.Payload.CustomDetails["firing"].([]string)
+	expectedFiring := []string{
+		"p99 latency above 1s HighLatency (instance=api-1:9100, job=api, severity=critical)",
+		"p99 latency above 1s HighLatency (instance=api-2:9100, job=api, severity=critical)",
+	}
+	if strings.Join(firing, "\n") != strings.Join(expectedFiring, "\n") {
+		t.Errorf("Unexpected firing alerts %v.", firing)
+	}
+}
+
+func TestBuildAlertmanagerEventResolved(t *testing.T) {
+	var webhook AlertmanagerWebhook
+	if err := json.Unmarshal([]byte(alertmanagerResolvedPayload), &webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := buildAlertmanagerEvent(webhook, defaultAlertmanagerRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.RoutingKey != "22863b592c824bfc8989d9cba76abcde" {
+		t.Errorf("Expected routing key from label, was %v.", event.RoutingKey)
+	}
+	if event.EventAction != "resolve" {
+		t.Errorf("Expected resolved group to resolve, was %v.", event.EventAction)
+	}
+	if event.DedupKey != webhook.GroupKey {
+		t.Errorf("Expected dedup key to be the group key, was %v.", event.DedupKey)
+	}
+	if event.Payload.Summary != "p99 latency above 1s" {
+		t.Errorf("Expected common summary annotation, was %q.", event.Payload.Summary)
+	}
+	if n := event.Payload.CustomDetails["num_resolved"]; n != 1 {
+		t.Errorf("Expected 1 resolved alert, was %v.", n)
+	}
+}
+
+func TestBuildAlertmanagerEventErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook AlertmanagerWebhook
+		key     string
+	}{
+		{"unsupportedVersion", AlertmanagerWebhook{Version: "3", GroupKey: "g", Status: "firing"}, defaultAlertmanagerRoutingKey},
+		{"unsupportedStatus", AlertmanagerWebhook{Version: "4", GroupKey: "g", Status: "pending"}, defaultAlertmanagerRoutingKey},
+		{"missingGroupKey", AlertmanagerWebhook{Version: "4", Status: "firing"}, defaultAlertmanagerRoutingKey},
+		{"missingRoutingKey", AlertmanagerWebhook{Version: "4", GroupKey: "g", Status: "firing"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := buildAlertmanagerEvent(tt.webhook, tt.key); err == nil {
+				t.Error("Expected an error building event.")
+			}
+		})
+	}
+}
+
+func TestAlertmanagerDedupKeyLongGroupKey(t *testing.T) {
+	key := alertmanagerDedupKey(strings.Repeat("a", 300))
+	if len(key) != 64 {
+		t.Errorf("Expected long group key to be hashed, was %v.", key)
+	}
+}
+
+func TestAlertmanagerHandler(t *testing.T) {
+	received := make(chan *eventsapi.EventContainer, 2)
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		received <- job.EventContainer
+		job.ResponseChan <- eventqueue.Response{}
+	}
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q,
+		WithIngestToken("ingest-token"),
+		WithAlertmanagerRoutingKey(defaultAlertmanagerRoutingKey),
+	)
+	router := Router(s)
+
+	tests := []struct {
+		name           string
+		auth           string
+		body           string
+		expectedCode   int
+		expectedAction string
+	}{
+		{"firing", "Bearer ingest-token", alertmanagerFiringPayload, 200, "trigger"},
+		{"resolved", "Bearer ingest-token", alertmanagerResolvedPayload, 200, "resolve"},
+		{"badToken", "Bearer secret", alertmanagerFiringPayload, 401, ""},
+		{"malformed", "Bearer ingest-token", `{"version": `, 400, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/alertmanager", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", tt.auth)
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Fatalf("Expected %v response, was %v: %v", tt.expectedCode, rw.Code, rw.Body.String())
+			}
+			if tt.expectedAction == "" {
+				return
+			}
+
+			event, err := (<-received).UnmarshalEvent()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action := event.(*eventsapi.EventV2).EventAction; action != tt.expectedAction {
+				t.Errorf("Expected %v event, was %v.", tt.expectedAction, action)
+			}
+		})
+	}
+}
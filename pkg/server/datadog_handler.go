@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// datadogRoutingKeyParam overrides the default routing key for a webhook.
+const datadogRoutingKeyParam = "routing_key"
+
+// datadogToPagerDutyEventAction maps lowercased alert transitions to event
+// actions. Anything but a recovery re-triggers, deduplicating against any
+// open incident.
+var datadogToPagerDutyEventAction = map[string]string{
+	"triggered":    "trigger",
+	"re-triggered": "trigger",
+	"warn":         "trigger",
+	"re-warn":      "trigger",
+	"no data":      "trigger",
+	"re-no data":   "trigger",
+	"renotify":     "trigger",
+	"recovered":    "resolve",
+}
+
+var datadogToPagerDutySeverity = map[string]string{
+	"error":   "error",
+	"warning": "warning",
+	"success": "info",
+	"info":    "info",
+}
+
+// DatadogWebhook is the body of a Datadog webhook, whose payload is templated
+// in Datadog, e.g. `"alert_transition": "$ALERT_TRANSITION"`.
+//
+// Only `alert_transition` and `aggreg_key` are required, along with `title`
+// for triggers.
+type DatadogWebhook struct {
+	AlertTransition string `json:"alert_transition"`
+	AlertType       string `json:"alert_type"`
+	AggregKey       string `json:"aggreg_key"`
+	Title           string `json:"title"`
+	Body            string `json:"body"`
+	AlertID         string `json:"alert_id,omitempty"`
+	Hostname        string `json:"hostname,omitempty"`
+	Link            string `json:"link,omitempty"`
+	Tags            string `json:"tags,omitempty"`
+}
+
+// DatadogHandler acts as a Datadog webhook receiver, enqueuing a v2 event for
+// each monitor notification.
+//
+// Recovered monitors resolve and others trigger an incident deduplicated by
+// the aggregation key, routed using the `routing_key` query parameter or the
+// configured default.
+func (s *Server) DatadogHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		errorResp(rw, 405, []string{"Expected a POST request."})
+		return
+	}
+
+	if !s.authorizeIngest(rw, req) {
+		return
+	}
+
+	var webhook DatadogWebhook
+	decoder := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxIngestBodyBytes))
+	if err := decoder.Decode(&webhook); err != nil {
+		errorResp(rw, 400, []string{"Expected a Datadog webhook JSON body of at most 512KB: " + err.Error()})
+		return
+	}
+
+	routingKey := req.URL.Query().Get(datadogRoutingKeyParam)
+	if routingKey == "" {
+		routingKey = s.datadogRoutingKey()
+	}
+
+	event, err := buildDatadogEvent(webhook, routingKey)
+	if err != nil {
+		errorResp(rw, 400, []string{err.Error()})
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	eventContainer := eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData:    body,
+		Integration:  "datadog",
+	}
+
+	key, err := s.Queue.Enqueue(&eventContainer)
+	if err == persistentqueue.ErrQueueShutdown || err == persistentqueue.ErrQueueFull || err == persistentqueue.ErrQueueDraining {
+		errorResp(rw, 503, []string{err.Error()})
+		return
+	} else if err == eventsapi.ErrInvalidRoutingKey {
+		errorResp(rw, 400, []string{err.Error()})
+		return
+	} else if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, SendResponse{Key: key})
+}
+
+func buildDatadogEvent(webhook DatadogWebhook, routingKey string) (*eventsapi.EventV2, error) {
+	eventAction, ok := datadogToPagerDutyEventAction[strings.ToLower(webhook.AlertTransition)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported Datadog alert_transition %q, expected e.g. \"Triggered\" or \"Recovered\"", webhook.AlertTransition)
+	}
+
+	if webhook.AggregKey == "" {
+		return nil, fmt.Errorf("aggreg_key is required")
+	}
+	if eventAction == "trigger" && webhook.Title == "" {
+		return nil, fmt.Errorf("title is required for triggered alerts")
+	}
+	if routingKey == "" {
+		return nil, fmt.Errorf("no routing key, expected a %v query parameter or a configured default", datadogRoutingKeyParam)
+	}
+
+	severity, ok := datadogToPagerDutySeverity[strings.ToLower(webhook.AlertType)]
+	if !ok {
+		severity = "error"
+	}
+
+	source := webhook.Hostname
+	if source == "" {
+		source = "datadog"
+	}
+
+	summary := webhook.Title
+	if len(summary) > maxSummaryLength {
+		summary = summary[:maxSummaryLength]
+	}
+
+	event := eventsapi.EventV2{
+		RoutingKey:  routingKey,
+		EventAction: eventAction,
+		DedupKey:    alertmanagerDedupKey(webhook.AggregKey),
+		Payload: eventsapi.PayloadV2{
+			Summary:  summary,
+			Source:   source,
+			Severity: severity,
+			CustomDetails: map[string]interface{}{
+				"body":             webhook.Body,
+				"alert_transition": webhook.AlertTransition,
+				"alert_type":       webhook.AlertType,
+				"alert_id":         webhook.AlertID,
+				"tags":             webhook.Tags,
+			},
+		},
+	}
+
+	if webhook.Link != "" {
+		event.Links = []eventsapi.LinkV2{{Href: webhook.Link, Text: "Datadog"}}
+	}
+
+	return &event, nil
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+const datadogTriggeredPayload = `{
+	"alert_id": "12345678",
+	"alert_transition": "Triggered",
+	"alert_type": "error",
+	"aggreg_key": "ab12cd34ef56",
+	"title": "[Triggered] CPU usage high on web-1",
+	"body": "CPU usage is above 90% for the last 5 minutes.",
+	"hostname": "web-1",
+	"link": "https://app.datadoghq.com/event/event?id=5421234",
+	"tags": "env:prod,role:web"
+}`
+
+const datadogRecoveredPayload = `{
+	"alert_id": "12345678",
+	"alert_transition": "Recovered",
+	"alert_type": "success",
+	"aggreg_key": "ab12cd34ef56",
+	"title": "[Recovered] CPU usage high on web-1",
+	"body": "CPU usage is back below 90%.",
+	"hostname": "web-1",
+	"tags": "env:prod,role:web"
+}`
+
+const defaultDatadogRoutingKey = "11863b592c824bfc8989d9cba76abcde"
+
+func TestBuildDatadogEventTriggered(t *testing.T) {
+	var webhook DatadogWebhook
+	if err := json.Unmarshal([]byte(datadogTriggeredPayload), &webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := buildDatadogEvent(webhook, defaultDatadogRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.RoutingKey != defaultDatadogRoutingKey {
+		t.Errorf("Expected default routing key, was %v.", event.RoutingKey)
+	}
+	if event.EventAction != "trigger" {
+		t.Errorf("Expected triggered alert to trigger, was %v.", event.EventAction)
+	}
+	if event.DedupKey != "ab12cd34ef56" {
+		t.Errorf("Expected dedup key to be the aggregation key, was %v.", event.DedupKey)
+	}
+	if event.Payload.Summary != "[Triggered] CPU usage high on web-1" {
+		t.Errorf("Expected title as summary, was %q.", event.Payload.Summary)
+	}
+	if event.Payload.Severity != "error" {
+		t.Errorf("Expected error severity, was %v.", event.Payload.Severity)
+	}
+	if event.Payload.Source != "web-1" {
+		t.Errorf("Expected hostname as source, was %v.", event.Payload.Source)
+	}
+	if len(event.Links) != 1 || event.Links[0].Href != "https://app.datadoghq.com/event/event?id=5421234" {
+		t.Errorf("Expected a link to Datadog, was %v.", event.Links)
+	}
+	if body := event.Payload.CustomDetails["body"]; body != "CPU usage is above 90% for the last 5 minutes." {
+		t.Errorf("Expected body in details, was %v.", body)
+	}
+}
+
+func TestBuildDatadogEventRecovered(t *testing.T) {
+	var webhook DatadogWebhook
+	if err := json.Unmarshal([]byte(datadogRecoveredPayload), &webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := buildDatadogEvent(webhook, defaultDatadogRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.EventAction != "resolve" {
+		t.Errorf("Expected recovered alert to resolve, was %v.", event.EventAction)
+	}
+	if event.DedupKey != "ab12cd34ef56" {
+		t.Errorf("Expected dedup key to be the aggregation key, was %v.", event.DedupKey)
+	}
+	if event.Payload.Severity != "info" {
+		t.Errorf("Expected success to map to info severity, was %v.", event.Payload.Severity)
+	}
+	if len(event.Links) != 0 {
+		t.Errorf("Expected no links without a link, were %v.", event.Links)
+	}
+}
+
+func TestBuildDatadogEventSeverity(t *testing.T) {
+	tests := []struct {
+		alertType string
+		expected  string
+	}{
+		{"error", "error"},
+		{"warning", "warning"},
+		{"success", "info"},
+		{"info", "info"},
+		{"Warning", "warning"},
+		{"", "error"},
+		{"unknown", "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alertType, func(t *testing.T) {
+			webhook := DatadogWebhook{AlertTransition: "Triggered", AlertType: tt.alertType, AggregKey: "k", Title: "t"}
+			event, err := buildDatadogEvent(webhook, defaultDatadogRoutingKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Payload.Severity != tt.expected {
+				t.Errorf("Expected %v severity, was %v.", tt.expected, event.Payload.Severity)
+			}
+		})
+	}
+}
+
+func TestBuildDatadogEventErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook DatadogWebhook
+		key     string
+	}{
+		{"unsupportedTransition", DatadogWebhook{AlertTransition: "Muted", AggregKey: "k", Title: "t"}, defaultDatadogRoutingKey},
+		{"missingAggregKey", DatadogWebhook{AlertTransition: "Triggered", Title: "t"}, defaultDatadogRoutingKey},
+		{"missingTitle", DatadogWebhook{AlertTransition: "Triggered", AggregKey: "k"}, defaultDatadogRoutingKey},
+		{"missingRoutingKey", DatadogWebhook{AlertTransition: "Triggered", AggregKey: "k", Title: "t"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := buildDatadogEvent(tt.webhook, tt.key); err == nil {
+				t.Error("Expected an error building event.")
+			}
+		})
+	}
+}
+
+func TestDatadogHandler(t *testing.T) {
+	received := make(chan *eventsapi.EventContainer, 2)
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		received <- job.EventContainer
+		job.ResponseChan <- eventqueue.Response{}
+	}
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q,
+		WithIngestToken("ingest-token"),
+		WithDatadogRoutingKey(defaultDatadogRoutingKey),
+	)
+	router := Router(s)
+
+	tests := []struct {
+		name               string
+		target             string
+		token              string
+		body               string
+		expectedCode       int
+		expectedAction     string
+		expectedRoutingKey string
+	}{
+		{"triggered", "/datadog", "ingest-token", datadogTriggeredPayload, 200, "trigger", defaultDatadogRoutingKey},
+		{"recovered", "/datadog", "ingest-token", datadogRecoveredPayload, 200, "resolve", defaultDatadogRoutingKey},
+		{"routingKeyOverride", "/datadog?routing_key=22863b592c824bfc8989d9cba76abcde", "ingest-token", datadogTriggeredPayload, 200, "trigger", "22863b592c824bfc8989d9cba76abcde"},
+		{"badToken", "/datadog", "secret", datadogTriggeredPayload, 401, "", ""},
+		{"malformed", "/datadog", "ingest-token", `{"alert_transition": `, 400, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.target, strings.NewReader(tt.body))
+			req.Header.Set(ingestTokenHeader, tt.token)
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Fatalf("Expected %v response, was %v: %v", tt.expectedCode, rw.Code, rw.Body.String())
+			}
+			if tt.expectedAction == "" {
+				return
+			}
+
+			event, err := (<-received).UnmarshalEvent()
+			if err != nil {
+				t.Fatal(err)
+			}
+			v2 := event.(*eventsapi.EventV2)
+			if v2.EventAction != tt.expectedAction {
+				t.Errorf("Expected %v event, was %v.", tt.expectedAction, v2.EventAction)
+			}
+			if v2.RoutingKey != tt.expectedRoutingKey {
+				t.Errorf("Expected routing key %v, was %v.", tt.expectedRoutingKey, v2.RoutingKey)
+			}
+		})
+	}
+}
+
+func TestLoggedRequestURIMasksRoutingKey(t *testing.T) {
+	req := httptest.NewRequest("POST", "/datadog?routing_key=22863b592c824bfc8989d9cba76abcde", nil)
+	if uri := loggedRequestURI(req); strings.Contains(uri, "22863b592c824bfc8989d9cba76abcde") {
+		t.Errorf("Expected routing key to be masked, was %v.", uri)
+	}
+
+	req = httptest.NewRequest("GET", "/queue?status=pending", nil)
+	if uri := loggedRequestURI(req); uri != "/queue?status=pending" {
+		t.Errorf("Expected other requests to be logged as is, was %v.", uri)
+	}
+}
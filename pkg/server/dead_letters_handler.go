@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+func (s *Server) DeadLettersHandler(rw http.ResponseWriter, req *http.Request) {
+	rk := req.URL.Query().Get("rk")
+
+	if rk == "" {
+		s.logger.Debugf("Dead letters for all routing keys.")
+	} else {
+		s.logger.Debugf("Dead letters for routing key %v", rk)
+	}
+
+	deadLetters, err := s.Queue.DeadLetters(rk)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, DeadLettersResponse{DeadLetters: deadLetters})
+}
+
+type DeadLettersResponse struct {
+	DeadLetters []persistentqueue.DeadLetter `json:"dead_letters,omitempty"`
+}
+
+func (s *Server) DeadLetterRetryHandler(rw http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.URL.Query().Get("id"))
+	if err != nil {
+		errorResp(rw, 400, []string{"Expected a numeric dead letter id."})
+		return
+	}
+
+	s.logger.Debugf("Retrying dead letter %v", id)
+
+	err = s.Queue.RetryDeadLetter(id)
+	if err == persistentqueue.ErrNotFound {
+		errorResp(rw, 404, []string{fmt.Sprintf("Dead letter %v not found.", id)})
+		return
+	} else if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, RetryResponse{fmt.Sprintf("Retrying dead letter %v.", id)})
+}
+
+// DeadLetterReplayHandler re-sends the event of the dead letter `id`, or of
+// every dead letter if `all` is true, optionally replacing its routing key
+// with `rk`.
+func (s *Server) DeadLetterReplayHandler(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	rk := query.Get("rk")
+
+	all := false
+	if val := query.Get("all"); val != "" {
+		var err error
+		all, err = strconv.ParseBool(val)
+		if err != nil {
+			errorResp(rw, 400, []string{"Expected all to be true or false."})
+			return
+		}
+	}
+
+	if all {
+		s.logger.Debugf("Replaying all dead letters.")
+
+		count, err := s.Queue.ReplayAll(rk)
+		if err == persistentqueue.ErrQueueShutdown {
+			errorResp(rw, 503, []string{err.Error()})
+			return
+		} else if err != nil {
+			errorResp(rw, 500, []string{err.Error()})
+			return
+		}
+
+		okResp(rw, ReplayResponse{Message: fmt.Sprintf("Replaying %v dead letters.", count)})
+		return
+	}
+
+	id, err := strconv.Atoi(query.Get("id"))
+	if err != nil {
+		errorResp(rw, 400, []string{"Expected a numeric dead letter id."})
+		return
+	}
+
+	s.logger.Debugf("Replaying dead letter %v", id)
+
+	key, err := s.Queue.Replay(id, rk)
+	if err == persistentqueue.ErrNotFound {
+		errorResp(rw, 404, []string{fmt.Sprintf("Dead letter %v not found.", id)})
+		return
+	} else if err == persistentqueue.ErrQueueShutdown {
+		errorResp(rw, 503, []string{err.Error()})
+		return
+	} else if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, ReplayResponse{Message: fmt.Sprintf("Replaying dead letter %v.", id), Key: key})
+}
+
+type ReplayResponse struct {
+	Message string `json:"message"`
+	Key     string `json:"key,omitempty"`
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// DedupReportHandler reports duplicates suppressed within the dedup window:
+// totals by masked routing key since the agent started, and the most recent
+// suppressions.
+func (s *Server) DedupReportHandler(rw http.ResponseWriter, _ *http.Request) {
+	m, err := s.Queue.Metrics()
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, DedupReportResponse{
+		Suppressed:   m.Suppressed,
+		Suppressions: s.Queue.Suppressions(),
+	})
+}
+
+type DedupReportResponse struct {
+	Suppressed   map[string]int                `json:"suppressed"`
+	Suppressions []persistentqueue.Suppression `json:"suppressions"`
+}
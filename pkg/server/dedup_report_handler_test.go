@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestDedupReportHandler(t *testing.T) {
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		job.ResponseChan <- eventqueue.Response{}
+	}
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq), persistentqueue.WithDedupWindow(time.Minute))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	var key string
+	for i := 0; i < 2; i++ {
+		event := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+		var err error
+		if key, err = q.Enqueue(&event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	s.DedupReportHandler(rw, httptest.NewRequest("GET", "/dedup", nil))
+
+	if rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	var report DedupReportResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Suppressed["11****de"] != 1 || len(report.Suppressions) != 1 || report.Suppressions[0].EventKey != key {
+		t.Errorf("Expected one suppression of %v, got %+v.", key, report)
+	}
+
+	rw = httptest.NewRecorder()
+	s.MetricsHandler(rw, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rw.Body.String(), `pdagent_events_suppressed_total{routing_key="11****de"} 1`) {
+		t.Errorf("Expected the suppression counter in metrics, got %v", rw.Body.String())
+	}
+}
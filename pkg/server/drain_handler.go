@@ -0,0 +1,45 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultDrainTimeout = 5 * time.Minute
+
+var errAlreadyDraining = errors.New("the queue is already draining")
+
+// DrainHandler starts draining the queue on POST, after which the server
+// exits once every event is sent or the `timeout` query parameter elapses.
+//
+// Responds immediately rather than once drained, as the server won't be
+// around to respond.
+func (s *Server) DrainHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		errorResp(rw, 405, []string{"Expected a POST request."})
+		return
+	}
+
+	timeout := defaultDrainTimeout
+	if val := req.URL.Query().Get("timeout"); val != "" {
+		var err error
+		timeout, err = time.ParseDuration(val)
+		if err != nil || timeout < 0 {
+			errorResp(rw, 400, []string{"Expected a non-negative timeout duration, e.g. 5m."})
+			return
+		}
+	}
+
+	if err := s.Drain(timeout); err == errAlreadyDraining {
+		errorResp(rw, 409, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, DrainResponse{Message: fmt.Sprintf("Draining queue, the server will exit once it's empty or after %v.", timeout)})
+}
+
+type DrainResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+func sendTrigger(s *Server) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/send", strings.NewReader(`{"routing_key":"11863b592c824bfc8989d9cba76abcde","event_action":"trigger","payload":{"summary":"summary","source":"source","severity":"error"}}`))
+	req.Header.Set("Pd-Event-Version", "v2")
+	rw := httptest.NewRecorder()
+	s.SendHandler(rw, req)
+	return rw
+}
+
+func TestDrainHandler(t *testing.T) {
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		job.ResponseChan <- eventqueue.Response{}
+	}
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq), persistentqueue.WithMaintenance(true))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+	if rw := sendTrigger(s); rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	rw := httptest.NewRecorder()
+	s.DrainHandler(rw, httptest.NewRequest("POST", "/drain?timeout=1s", nil))
+	if rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	select {
+	case err := <-s.drained:
+		if err != nil {
+			t.Errorf("Expected the queue to drain, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the drain to complete.")
+	}
+
+	if rw := sendTrigger(s); rw.Code != 503 {
+		t.Errorf("Expected 503 response once draining, was %v: %v", rw.Code, rw.Body.String())
+	}
+	rw = httptest.NewRecorder()
+	s.DrainHandler(rw, httptest.NewRequest("POST", "/drain", nil))
+	if rw.Code != 409 {
+		t.Errorf("Expected 409 response draining again, was %v: %v", rw.Code, rw.Body.String())
+	}
+}
+
+func TestDrainHandlerTimeout(t *testing.T) {
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		job.ResponseChan <- eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500, Err: errors.New("server error")}}
+	}
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq), persistentqueue.WithMaintenance(true), persistentqueue.WithMaxRetries(100))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+	if rw := sendTrigger(s); rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	rw := httptest.NewRecorder()
+	s.DrainHandler(rw, httptest.NewRequest("GET", "/drain", nil))
+	if rw.Code != 405 {
+		t.Errorf("Expected 405 response for a GET, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	if err := s.Drain(200 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-s.drained:
+		if err != persistentqueue.ErrDrainTimeout {
+			t.Errorf("Expected the drain to time out, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the drain to time out.")
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+const defaultFlushTimeout = 5 * time.Second
+
+// FlushHandler immediately sends all pending events, responding once they
+// complete or the `timeout` query parameter elapses.
+//
+// The timeout is capped below the server's write timeout so that a response
+// can always be written.
+func (s *Server) FlushHandler(rw http.ResponseWriter, req *http.Request) {
+	timeout := defaultFlushTimeout
+	if val := req.URL.Query().Get("timeout"); val != "" {
+		var err error
+		timeout, err = time.ParseDuration(val)
+		if err != nil || timeout < 0 {
+			errorResp(rw, 400, []string{"Expected a non-negative timeout duration, e.g. 5s."})
+			return
+		}
+	}
+
+	if max := s.HTTPServer.WriteTimeout - time.Second; s.HTTPServer.WriteTimeout > 0 && timeout > max {
+		s.logger.Infof("Flush timeout of %v exceeds server write timeout, using %v.", timeout, max)
+		timeout = max
+	}
+
+	s.logger.Debugf("Flushing pending events with timeout %v.", timeout)
+
+	result, err := s.Queue.Flush(timeout)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, result)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+func TestFlushHandler(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue()
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	rw := httptest.NewRecorder()
+	s.FlushHandler(rw, httptest.NewRequest("POST", "/queue/flush?timeout=1m", nil))
+
+	if rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	var result persistentqueue.FlushResult
+	if err := json.Unmarshal(rw.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result != (persistentqueue.FlushResult{}) {
+		t.Errorf("Expected nothing to flush, was %+v.", result)
+	}
+}
+
+func TestFlushHandlerInvalidTimeout(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "secret", "", nil)
+
+	for _, query := range []string{"?timeout=abc", "?timeout=-1s"} {
+		rw := httptest.NewRecorder()
+		s.FlushHandler(rw, httptest.NewRequest("POST", "/queue/flush"+query, nil))
+
+		if rw.Code != 400 {
+			t.Errorf("Expected 400 response for %v, was %v.", query, rw.Code)
+		}
+	}
+}
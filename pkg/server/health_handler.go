@@ -5,9 +5,39 @@ import (
 	"net/http"
 )
 
+// Probe endpoints for load balancers and orchestrators, e.g. Kubernetes.
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+)
+
+// probePaths never depend on reaching PagerDuty, and may optionally skip
+// authentication.
+var probePaths = map[string]bool{"/health": true, livenessPath: true, readinessPath: true}
+
 func (s *Server) HealthHandler(rw http.ResponseWriter, _ *http.Request) {
 	_, err := fmt.Fprint(rw, "OK")
 	if err != nil {
 		s.logger.Error("Error responding to healthcheck.")
 	}
 }
+
+// LivenessHandler responds 200 so long as the server is handling requests.
+func (s *Server) LivenessHandler(rw http.ResponseWriter, r *http.Request) {
+	s.HealthHandler(rw, r)
+}
+
+// ReadinessHandler responds 200 only if the queue is running and its store is
+// writable, otherwise 503.
+func (s *Server) ReadinessHandler(rw http.ResponseWriter, _ *http.Request) {
+	if err := s.Queue.Ready(); err != nil {
+		s.logger.Warn("Readiness check failed: ", err)
+		errorResp(rw, 503, []string{fmt.Sprintf("Not ready: %v", err)})
+		return
+	}
+
+	_, err := fmt.Fprint(rw, "OK")
+	if err != nil {
+		s.logger.Error("Error responding to readiness check.")
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	// The store is closed out from under the queue, leaving shutdown unable to
+	// clean up a default temporary database.
+	dir, err := ioutil.TempDir("", "go-pdagent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithFile(path.Join(dir, "test.db")))
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	ready := func() int {
+		rw := httptest.NewRecorder()
+		s.ReadinessHandler(rw, httptest.NewRequest("GET", readinessPath, nil))
+		return rw.Code
+	}
+
+	if code := ready(); code != 503 {
+		t.Errorf("Expected 503 before the queue is started, was %v.", code)
+	}
+
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if code := ready(); code != 200 {
+		t.Errorf("Expected 200 once the queue is started, was %v.", code)
+	}
+
+	if err := q.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := ready(); code != 503 {
+		t.Errorf("Expected 503 once the store is closed, was %v.", code)
+	}
+
+	rw := httptest.NewRecorder()
+	s.LivenessHandler(rw, httptest.NewRequest("GET", livenessPath, nil))
+	if rw.Code != 200 {
+		t.Errorf("Expected liveness to be unaffected by the store, was %v.", rw.Code)
+	}
+}
+
+func TestProbeAuthentication(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue()
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	tests := []struct {
+		name                  string
+		unauthenticatedProbes bool
+		path                  string
+		expected              int
+	}{
+		{"livenessRequiresAuth", false, livenessPath, 401},
+		{"readinessRequiresAuth", false, readinessPath, 401},
+		{"liveness", true, livenessPath, 200},
+		{"readiness", true, readinessPath, 200},
+		{"health", true, "/health", 200},
+		{"otherEndpointsRequireAuth", true, "/status", 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("127.0.0.1:0", "secret", "", q, WithUnauthenticatedProbes(tt.unauthenticatedProbes))
+
+			rw := httptest.NewRecorder()
+			Router(s).ServeHTTP(rw, httptest.NewRequest("GET", tt.path, nil))
+
+			if rw.Code != tt.expected {
+				t.Errorf("Expected %v for %v, was %v: %v", tt.expected, tt.path, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
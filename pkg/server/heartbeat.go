@@ -35,8 +35,9 @@ type heartbeatResponseBody struct {
 	HeartBeatIntervalSeconds int `json:"heartbeat_interval_secs"`
 }
 
-func NewHeartbeat() Heartbeat {
+func NewHeartbeat(baseTransport http.RoundTripper) Heartbeat {
 	transport := common.NewRetryTransport()
+	transport.Transport = baseTransport
 	transport.MaxRetries = maxRetries
 	transport.MaxInterval = maxRetryInterval
 
@@ -99,7 +100,7 @@ func (hb *heartbeat) doHeartbeatRequest() (*heartbeatResponseBody, error) {
 		return nil, err
 	}
 
-	req.Header.Add("User-Agent", common.UserAgent())
+	req.Header.Add("User-Agent", common.UserAgent(""))
 	req.Header.Add("Accept", "application/json")
 
 	httpResp, err := hb.client.Do(req)
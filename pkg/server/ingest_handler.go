@@ -0,0 +1,161 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+const (
+	ingestPath       = "/ingest"
+	alertmanagerPath = "/alertmanager"
+	datadogPath      = "/datadog"
+)
+
+// ingestPaths authenticate with the ingest token rather than the agent's
+// secret, so that it can be handed to other tooling.
+var ingestPaths = map[string]bool{ingestPath: true, alertmanagerPath: true, datadogPath: true}
+
+// ingestTokenHeader carries the ingest token, though a bearer token in the
+// `Authorization` header is also accepted for tools like Alertmanager.
+const ingestTokenHeader = "X-Agent-Token"
+
+// maxIngestBodyBytes matches the Events API's own payload size limit.
+const maxIngestBodyBytes = 512 * 1024
+
+var allowedIngestEventActions = []string{"trigger", "acknowledge", "resolve"}
+var allowedIngestSeverities = []string{"critical", "error", "warning", "info"}
+
+// IngestRequest is a generic JSON alert accepted by `/ingest`.
+//
+// Only `routing_key` and `summary` are required for triggers, with the action
+// defaulting to trigger, severity to error, and source to the agent's host.
+// Acknowledges and resolves instead require a `dedup_key`.
+type IngestRequest struct {
+	RoutingKey  string                 `json:"routing_key"`
+	EventAction string                 `json:"event_action,omitempty"`
+	DedupKey    string                 `json:"dedup_key,omitempty"`
+	Summary     string                 `json:"summary"`
+	Source      string                 `json:"source,omitempty"`
+	Severity    string                 `json:"severity,omitempty"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+}
+
+// IngestHandler enqueues a generic JSON alert as a v2 event, authenticated by
+// the `X-Agent-Token` header rather than the agent's secret.
+func (s *Server) IngestHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		errorResp(rw, 405, []string{"Expected a POST request."})
+		return
+	}
+
+	if !s.authorizeIngest(rw, req) {
+		return
+	}
+
+	var ingestReq IngestRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxIngestBodyBytes))
+	if err := decoder.Decode(&ingestReq); err != nil {
+		errorResp(rw, 400, []string{"Expected a JSON body of at most 512KB: " + err.Error()})
+		return
+	}
+
+	if errs := validateIngestRequest(&ingestReq); len(errs) > 0 {
+		errorResp(rw, 400, errs)
+		return
+	}
+
+	body, err := json.Marshal(buildIngestEvent(ingestReq))
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	eventContainer := eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData:    body,
+	}
+
+	key, err := s.Queue.Enqueue(&eventContainer)
+	if err == persistentqueue.ErrQueueShutdown || err == persistentqueue.ErrQueueFull || err == persistentqueue.ErrQueueDraining {
+		errorResp(rw, 503, []string{err.Error()})
+		return
+	} else if err == eventsapi.ErrInvalidRoutingKey {
+		errorResp(rw, 400, []string{err.Error()})
+		return
+	} else if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, SendResponse{Key: key})
+}
+
+// authorizeIngest checks a request's ingest token, responding with a 401 and
+// returning false if it doesn't match.
+func (s *Server) authorizeIngest(rw http.ResponseWriter, req *http.Request) bool {
+	token := req.Header.Get(ingestTokenHeader)
+	if token == "" {
+		token = strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.IngestToken)) != 1 {
+		s.logger.Infof("Authorization failure for %v.", req.URL.Path)
+		errorResp(rw, 401, []string{"Unauthorized, expected matching token in " + ingestTokenHeader + " header."})
+		return false
+	}
+	return true
+}
+
+// validateIngestRequest checks required fields, filling in any defaults.
+func validateIngestRequest(ingestReq *IngestRequest) []string {
+	var errs []string
+
+	if ingestReq.EventAction == "" {
+		ingestReq.EventAction = "trigger"
+	}
+	if ingestReq.Severity == "" {
+		ingestReq.Severity = "error"
+	}
+	if ingestReq.Source == "" {
+		ingestReq.Source, _ = os.Hostname()
+	}
+
+	if ingestReq.RoutingKey == "" {
+		errs = append(errs, "routing_key is required.")
+	}
+
+	if !contains(allowedIngestEventActions, ingestReq.EventAction) {
+		errs = append(errs, "event_action must be one of: trigger, acknowledge, resolve.")
+	} else if ingestReq.EventAction == "trigger" {
+		if ingestReq.Summary == "" {
+			errs = append(errs, "summary is required for trigger events.")
+		}
+		if !contains(allowedIngestSeverities, ingestReq.Severity) {
+			errs = append(errs, "severity must be one of: critical, error, warning, info.")
+		}
+	} else if ingestReq.DedupKey == "" {
+		errs = append(errs, "dedup_key is required for acknowledge and resolve events.")
+	}
+
+	return errs
+}
+
+func buildIngestEvent(ingestReq IngestRequest) *eventsapi.EventV2 {
+	return &eventsapi.EventV2{
+		RoutingKey:  ingestReq.RoutingKey,
+		EventAction: ingestReq.EventAction,
+		DedupKey:    ingestReq.DedupKey,
+		Payload: eventsapi.PayloadV2{
+			Summary:       ingestReq.Summary,
+			Source:        ingestReq.Source,
+			Severity:      ingestReq.Severity,
+			CustomDetails: ingestReq.Details,
+		},
+	}
+}
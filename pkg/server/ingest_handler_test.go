@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+func TestIngestHandler(t *testing.T) {
+	received := make(chan *eventsapi.EventContainer, 1)
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		received <- job.EventContainer
+		job.ResponseChan <- eventqueue.Response{}
+	}
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q, WithIngestToken("ingest-token"))
+	router := Router(s)
+
+	body := `{
+		"routing_key": "11863b592c824bfc8989d9cba76abcde",
+		"summary": "Disk full",
+		"source": "db01",
+		"severity": "critical",
+		"details": {"mount": "/var"}
+	}`
+
+	tests := []struct {
+		name         string
+		token        string
+		body         string
+		expectedCode int
+	}{
+		{"valid", "ingest-token", body, 200},
+		{"missingToken", "", body, 401},
+		{"badToken", "secret", body, 401},
+		{"malformedJSON", "ingest-token", `{"routing_key": `, 400},
+		{"oversized", "ingest-token", `{"summary": "` + strings.Repeat("a", maxIngestBodyBytes) + `"}`, 400},
+		{"missingSummary", "ingest-token", `{"routing_key": "11863b592c824bfc8989d9cba76abcde"}`, 400},
+		{"invalidSeverity", "ingest-token", `{"routing_key": "11863b592c824bfc8989d9cba76abcde", "summary": "a", "severity": "bad"}`, 400},
+		{"resolveMissingDedupKey", "ingest-token", `{"routing_key": "11863b592c824bfc8989d9cba76abcde", "event_action": "resolve"}`, 400},
+		{"invalidRoutingKey", "ingest-token", `{"routing_key": "abc", "summary": "a"}`, 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/ingest", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("X-Agent-Token", tt.token)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Errorf("Expected %v response, was %v: %v", tt.expectedCode, rw.Code, rw.Body.String())
+			}
+		})
+	}
+
+	eventContainer := <-received
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventV2 := event.(*eventsapi.EventV2)
+	if eventV2.EventAction != "trigger" || eventV2.Payload.Summary != "Disk full" || eventV2.Payload.Severity != "critical" {
+		t.Errorf("Expected ingested trigger to match request, was %+v.", eventV2)
+	}
+	if details, _ := json.Marshal(eventV2.Payload.CustomDetails); string(details) != `{"mount":"/var"}` {
+		t.Errorf("Expected ingested details to match request, was %v.", string(details))
+	}
+}
+
+func TestIngestHandlerDisabledWithoutToken(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "", "", nil)
+
+	rw := httptest.NewRecorder()
+	Router(s).ServeHTTP(rw, httptest.NewRequest("POST", "/ingest", strings.NewReader(`{}`)))
+
+	if rw.Code != 404 {
+		t.Errorf("Expected 404 response without an ingest token, was %v.", rw.Code)
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// MaintenanceHandler reports maintenance mode, or toggles it on POST using the
+// `enabled` query parameter.
+func (s *Server) MaintenanceHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		enabled, err := strconv.ParseBool(req.URL.Query().Get("enabled"))
+		if err != nil {
+			errorResp(rw, 400, []string{"Expected enabled to be true or false."})
+			return
+		}
+
+		s.logger.Infof("Setting maintenance mode to %v.", enabled)
+
+		if err := s.Queue.SetMaintenance(enabled); err != nil {
+			errorResp(rw, 500, []string{err.Error()})
+			return
+		}
+	}
+
+	okResp(rw, MaintenanceResponse{Maintenance: s.Queue.Maintenance()})
+}
+
+type MaintenanceResponse struct {
+	Maintenance bool `json:"maintenance"`
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+func TestMaintenanceHandler(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue()
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	tests := []struct {
+		method   string
+		query    string
+		expected bool
+	}{
+		{"GET", "", false},
+		{"POST", "?enabled=true", true},
+		{"GET", "", true},
+		{"POST", "?enabled=false", false},
+	}
+
+	for _, tt := range tests {
+		rw := httptest.NewRecorder()
+		s.MaintenanceHandler(rw, httptest.NewRequest(tt.method, "/maintenance"+tt.query, nil))
+
+		if rw.Code != 200 {
+			t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+		}
+
+		var resp MaintenanceResponse
+		if err := json.Unmarshal(rw.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Maintenance != tt.expected {
+			t.Errorf("Expected maintenance %v after %v %v, was %v.", tt.expected, tt.method, tt.query, resp.Maintenance)
+		}
+	}
+}
+
+func TestMaintenanceHandlerInvalidState(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "secret", "", nil)
+
+	rw := httptest.NewRecorder()
+	s.MaintenanceHandler(rw, httptest.NewRequest("POST", "/maintenance?enabled=paused", nil))
+
+	if rw.Code != 400 {
+		t.Errorf("Expected 400 response, was %v.", rw.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// MetricsHandler exposes queue metrics in the Prometheus text format.
+func (s *Server) MetricsHandler(rw http.ResponseWriter, _ *http.Request) {
+	m, err := s.Queue.Metrics()
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	writeMetric(&buf, "pdagent_queue_depth", "gauge", "Events pending in the persistent queue.", m.Pending)
+	writeMetric(&buf, "pdagent_sends_in_flight", "gauge", "Events awaiting a response from the Events API.", m.InFlight)
+	writeMetric(&buf, "pdagent_events_enqueued_total", "counter", "Events enqueued since the agent started.", m.Enqueued)
+	writeMetric(&buf, "pdagent_events_delivered_total", "counter", "Events successfully delivered since the agent started.", m.Delivered)
+	writeMetric(&buf, "pdagent_events_dead_lettered_total", "counter", "Events dead-lettered since the agent started.", m.DeadLettered)
+	writeLabeledMetric(&buf, "pdagent_events_suppressed_total", "counter", "Duplicate events suppressed within the dedup window since the agent started, by masked routing key.", "routing_key", m.Suppressed)
+	writeMetric(&buf, "pdagent_worker_stalls_total", "counter", "Stalled send workers restarted since the agent started.", m.WorkerStalls)
+	writeHistogram(&buf, "pdagent_send_duration_seconds", "Time from an event being sent to the event queue until a response is received.", m.SendLatency)
+	writeHistogram(&buf, "pdagent_event_delivery_seconds", "Time from an event being enqueued until PagerDuty confirmed it, including any retries.", m.DeliveryLatency)
+	writeHistogram(&buf, "pdagent_event_dead_letter_seconds", "Time from an event being enqueued until it was dead-lettered.", m.DeadLetterLatency)
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	rw.WriteHeader(200)
+	_, _ = rw.Write(buf.Bytes())
+}
+
+func writeMetric(buf *bytes.Buffer, name, metricType, help string, value int) {
+	fmt.Fprintf(buf, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %v %v\n", name, metricType)
+	fmt.Fprintf(buf, "%v %v\n", name, value)
+}
+
+// writeLabeledMetric writes a metric with a value per `label`, sorted so the
+// output is stable.
+func writeLabeledMetric(buf *bytes.Buffer, name, metricType, help, label string, values map[string]int) {
+	fmt.Fprintf(buf, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %v %v\n", name, metricType)
+
+	labels := make([]string, 0, len(values))
+	for l := range values {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for _, l := range labels {
+		fmt.Fprintf(buf, "%v{%v=%q} %v\n", name, label, l, values[l])
+	}
+}
+
+func writeHistogram(buf *bytes.Buffer, name, help string, h persistentqueue.Histogram) {
+	fmt.Fprintf(buf, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %v histogram\n", name)
+	for i, upper := range h.Buckets {
+		fmt.Fprintf(buf, "%v_bucket{le=\"%v\"} %v\n", name, strconv.FormatFloat(upper, 'g', -1, 64), h.Counts[i])
+	}
+	fmt.Fprintf(buf, "%v_bucket{le=\"+Inf\"} %v\n", name, h.Count)
+	fmt.Fprintf(buf, "%v_sum %v\n", name, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	fmt.Fprintf(buf, "%v_count %v\n", name, h.Count)
+}
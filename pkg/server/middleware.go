@@ -1,33 +1,63 @@
 package server
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
 	"fmt"
-	"go.uber.org/zap"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"go.uber.org/zap"
 )
 
 func loggingMiddleware(logger *zap.SugaredLogger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			logger.Infof("Handling request: %v", r.RequestURI)
+			// Probes may arrive every few seconds, so are only logged when
+			// debugging.
+			if probePaths[r.URL.Path] {
+				logger.Debugf("Handling request: %v", loggedRequestURI(r))
+			} else {
+				logger.Infof("Handling request: %v", loggedRequestURI(r))
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// loggedRequestURI returns a request's URI with any routing key passed as a
+// query parameter, e.g. to `/datadog`, masked.
+func loggedRequestURI(r *http.Request) string {
+	query := r.URL.Query()
+	routingKey := query.Get(datadogRoutingKeyParam)
+	if routingKey == "" {
+		return r.RequestURI
+	}
+
+	query.Set(datadogRoutingKeyParam, common.MaskSecret(routingKey))
+	return r.URL.Path + "?" + query.Encode()
+}
+
 func authMiddleware(s *Server) func(http.Handler) http.Handler {
 	serverHeader := fmt.Sprintf("token %v", s.secret)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if s.secret == "" {
+			// Ingest endpoints have their own token, letting tooling
+			// without access to the agent's secret send events.
+			if s.secret == "" || (s.IngestToken != "" && ingestPaths[r.URL.Path]) || (s.UnauthenticatedProbes && probePaths[r.URL.Path]) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
 			clientHeader := r.Header.Get("Authorization")
-			if clientHeader != serverHeader {
-				s.logger.Infof("Authorization failure with client auth header: %v", clientHeader)
+			if subtle.ConstantTimeCompare([]byte(clientHeader), []byte(serverHeader)) != 1 {
+				s.logger.Infof("Authorization failure for %v.", r.URL.Path)
 				errorResp(w, 401, []string{"Unauthorized, expected matching secret token in Authorization header."})
 				return
 			}
@@ -36,3 +66,66 @@ func authMiddleware(s *Server) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// enqueuePaths accept events, and so are subject to the ingest rate limit.
+var enqueuePaths = map[string]bool{"/send": true, ingestPath: true, alertmanagerPath: true, datadogPath: true}
+
+func rateLimitMiddleware(s *Server) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter := s.rateLimiter()
+			if limiter == nil || !enqueuePaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if ok, retryAfter := limiter.Allow(); !ok {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				s.logger.Warnf("Rate limiting request to %v, retry after %vs.", r.URL.Path, seconds)
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				errorResp(w, 429, []string{fmt.Sprintf("Too many events, retry after %v seconds.", seconds)})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// signedPaths require a valid `common.SignatureHeader` when the server has a
+// signing secret. Webhook receivers such as `/alertmanager` can't sign their
+// requests, so rely on the ingest token alone.
+var signedPaths = map[string]bool{"/send": true, ingestPath: true}
+
+func signatureMiddleware(s *Server) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.SigningSecret == "" || !signedPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Limiting the body, as it's read in full before it's verified.
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					errorResp(w, 413, []string{fmt.Sprintf("Request body exceeds %v bytes.", tooLarge.Limit)})
+					return
+				}
+				errorResp(w, 400, []string{err.Error()})
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			err = common.VerifySignature(s.SigningSecret, r.Header.Get(common.SignatureHeader), body, time.Now(), common.DefaultSignatureSkew)
+			if err != nil {
+				s.logger.Infof("Signature failure for request to %v: %v", r.URL.Path, err)
+				errorResp(w, 401, []string{fmt.Sprintf("Unauthorized, %v. Expected a valid %v header.", err, common.SignatureHeader)})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eventqueue.NewEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	core, logs := observer.New(zap.InfoLevel)
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+	s.logger = zap.New(core).Sugar()
+	router := Router(s)
+
+	tests := []struct {
+		name     string
+		header   string
+		expected int
+	}{
+		{"valid", "token secret", 200},
+		{"missing", "", 401},
+		{"wrongSecret", "token guessed-secret", 401},
+		{"prefix", "token secre", 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/health", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expected {
+				t.Errorf("Expected %v, was %v: %v", tt.expected, rw.Code, rw.Body.String())
+			}
+		})
+	}
+
+	for _, entry := range logs.All() {
+		if strings.Contains(entry.Message, "guessed-secret") {
+			t.Errorf("Expected the client's Authorization header not to be logged, was %q.", entry.Message)
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		job.ResponseChan <- eventqueue.Response{}
+	}
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q, WithIngestRateLimit(0.1, 2))
+	router := Router(s)
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/send", strings.NewReader(`{
+			"routing_key": "11863b592c824bfc8989d9cba76abcde",
+			"event_action": "trigger",
+			"payload": {"summary": "Test", "source": "test", "severity": "info"}
+		}`))
+		req.Header.Set("Authorization", "token secret")
+		req.Header.Set("Pd-Event-Version", "v2")
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	for i := 0; i < 2; i++ {
+		if rw := send(); rw.Code != 200 {
+			t.Fatalf("Expected burst to be accepted, was %v: %v", rw.Code, rw.Body.String())
+		}
+	}
+
+	rw := send()
+	if rw.Code != 429 {
+		t.Errorf("Expected 429 once over the limit, was %v", rw.Code)
+	}
+	if retryAfter := rw.Header().Get("Retry-After"); retryAfter != "10" {
+		t.Errorf("Expected Retry-After of 10 seconds, was %q", retryAfter)
+	}
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Authorization", "token secret")
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	if rw.Code != 200 {
+		t.Errorf("Expected endpoints not accepting events to be unlimited, was %v", rw.Code)
+	}
+}
+
+func TestSignatureMiddleware(t *testing.T) {
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		job.ResponseChan <- eventqueue.Response{}
+	}
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q, WithSigningSecret("signing-secret"))
+	router := Router(s)
+
+	body := `{
+		"routing_key": "11863b592c824bfc8989d9cba76abcde",
+		"event_action": "trigger",
+		"payload": {"summary": "Test", "source": "test", "severity": "info"}
+	}`
+	valid := common.SignBody("signing-secret", []byte(body), time.Now())
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		expected  int
+	}{
+		{"valid", body, valid, 200},
+		{"missing", body, "", 401},
+		{"tampered", strings.Replace(body, "trigger", "resolve", 1), valid, 401},
+		{"stale", body, common.SignBody("signing-secret", []byte(body), time.Now().Add(-time.Hour)), 401},
+		{"wrongSecret", body, common.SignBody("other-secret", []byte(body), time.Now()), 401},
+		{"oversized", strings.Repeat("a", maxIngestBodyBytes+1), valid, 413},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/send", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "token secret")
+			req.Header.Set("Pd-Event-Version", "v2")
+			if tt.signature != "" {
+				req.Header.Set(common.SignatureHeader, tt.signature)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expected {
+				t.Errorf("Expected %v, was %v: %v", tt.expected, rw.Code, rw.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Authorization", "token secret")
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	if rw.Code != 200 {
+		t.Errorf("Expected endpoints not accepting events to need no signature, was %v", rw.Code)
+	}
+}
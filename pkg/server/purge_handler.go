@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// PurgeHandler deletes all undelivered events on POST, along with all dead
+// letters if the `dead_letters` query parameter is true.
+func (s *Server) PurgeHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		errorResp(rw, 405, []string{"Expected a POST request."})
+		return
+	}
+
+	deadLetters := false
+	if val := req.URL.Query().Get("dead_letters"); val != "" {
+		var err error
+		deadLetters, err = strconv.ParseBool(val)
+		if err != nil {
+			errorResp(rw, 400, []string{"Expected dead_letters to be true or false."})
+			return
+		}
+	}
+
+	s.logger.Warnf("Purging queue, including dead letters: %v.", deadLetters)
+
+	result, err := s.Queue.Purge(deadLetters)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, result)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+func TestPurgeHandler(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue()
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	rw := httptest.NewRecorder()
+	s.PurgeHandler(rw, httptest.NewRequest("POST", "/queue/purge?dead_letters=true", nil))
+
+	if rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	var result persistentqueue.PurgeResult
+	if err := json.Unmarshal(rw.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result != (persistentqueue.PurgeResult{}) {
+		t.Errorf("Expected nothing to purge, was %+v.", result)
+	}
+}
+
+func TestPurgeHandlerInvalidRequests(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "secret", "", nil)
+
+	tests := []struct {
+		method       string
+		query        string
+		expectedCode int
+	}{
+		{"GET", "", 405},
+		{"POST", "?dead_letters=abc", 400},
+	}
+
+	for _, tt := range tests {
+		rw := httptest.NewRecorder()
+		s.PurgeHandler(rw, httptest.NewRequest(tt.method, "/queue/purge"+tt.query, nil))
+
+		if rw.Code != tt.expectedCode {
+			t.Errorf("Expected %v response for %v %v, was %v.", tt.expectedCode, tt.method, tt.query, rw.Code)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/version"
+)
+
+// QueueExportHandler returns a support bundle of the queue's undelivered events
+// and dead letters, along with the agent's version and a summary of its
+// configuration. The queue is only read, so this is safe while events are
+// being sent.
+//
+// As they grant access to create incidents, routing keys are masked wherever
+// they appear, including within the events themselves.
+func (s *Server) QueueExportHandler(rw http.ResponseWriter, _ *http.Request) {
+	pending, err := s.exportEvents(persistentqueue.StatusPending, persistentqueue.StatusScheduled)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+	inFlight, err := s.exportEvents(persistentqueue.StatusInFlight)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	deadLetters, err := s.Queue.DeadLetters("")
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+	masked := make([]persistentqueue.DeadLetter, 0, len(deadLetters))
+	for _, d := range deadLetters {
+		masked = append(masked, exportDeadLetter(d))
+	}
+
+	okResp(rw, QueueExportResponse{
+		GeneratedAt: time.Now().UTC(),
+		Version:     version.Version,
+		Config:      s.configSummary(),
+		Pending:     pending,
+		InFlight:    inFlight,
+		DeadLetters: masked,
+	})
+}
+
+type QueueExportResponse struct {
+	GeneratedAt time.Time                    `json:"generated_at"`
+	Version     string                       `json:"version"`
+	Config      QueueExportConfig            `json:"config"`
+	Pending     []QueueExportEvent           `json:"pending"`
+	InFlight    []QueueExportEvent           `json:"in_flight"`
+	DeadLetters []persistentqueue.DeadLetter `json:"dead_letters"`
+}
+
+// QueueExportConfig summarizes the server's configuration, without secrets.
+type QueueExportConfig struct {
+	MetricsEnabled         bool    `json:"metrics_enabled"`
+	IngestEnabled          bool    `json:"ingest_enabled"`
+	SigningEnabled         bool    `json:"signing_enabled"`
+	UnauthenticatedProbes  bool    `json:"unauthenticated_probes"`
+	IngestRateLimit        float64 `json:"ingest_rate_limit"`
+	IngestBurst            int     `json:"ingest_burst"`
+	AlertmanagerRoutingKey string  `json:"alertmanager_routing_key,omitempty"`
+	DatadogRoutingKey      string  `json:"datadog_routing_key,omitempty"`
+	CircuitBreaker         string  `json:"circuit_breaker,omitempty"`
+	Maintenance            bool    `json:"maintenance"`
+}
+
+type QueueExportEvent struct {
+	ID           int             `json:"id"`
+	Key          string          `json:"key"`
+	RoutingKey   string          `json:"routing_key"`
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	EventVersion string          `json:"event_version"`
+	Event        json.RawMessage `json:"event"`
+	EnqueuedAt   time.Time       `json:"enqueued_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	SendAt       *time.Time      `json:"send_at,omitempty"`
+	ExpiresAt    *time.Time      `json:"expires_at,omitempty"`
+}
+
+func (s *Server) configSummary() QueueExportConfig {
+	s.mu.RLock()
+	config := QueueExportConfig{
+		MetricsEnabled:         s.MetricsEnabled,
+		IngestEnabled:          s.IngestToken != "",
+		SigningEnabled:         s.SigningSecret != "",
+		UnauthenticatedProbes:  s.UnauthenticatedProbes,
+		IngestRateLimit:        s.IngestRateLimit,
+		IngestBurst:            s.IngestBurst,
+		AlertmanagerRoutingKey: redactKey(s.AlertmanagerRoutingKey),
+		DatadogRoutingKey:      redactKey(s.DatadogRoutingKey),
+	}
+	s.mu.RUnlock()
+
+	config.Maintenance = s.Queue.Maintenance()
+	if s.Breaker != nil {
+		config.CircuitBreaker = s.Breaker.State()
+	}
+	return config
+}
+
+// exportEvents returns the queued events with any of `statuses`.
+func (s *Server) exportEvents(statuses ...string) ([]QueueExportEvent, error) {
+	items := []QueueExportEvent{}
+	for _, status := range statuses {
+		events, err := s.Queue.List(persistentqueue.ListOptions{Status: status})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			// Listing pending events includes those in flight.
+			if e.Status == status {
+				items = append(items, exportEvent(e))
+			}
+		}
+	}
+	return items, nil
+}
+
+func exportEvent(e persistentqueue.Event) QueueExportEvent {
+	item := QueueExportEvent{
+		ID:         e.ID,
+		Key:        e.Key,
+		RoutingKey: common.MaskSecret(e.RoutingKey),
+		Status:     e.Status,
+		Attempts:   e.Attempts,
+		EnqueuedAt: e.CreatedAt,
+		UpdatedAt:  e.UpdatedAt,
+	}
+	if e.Event != nil {
+		item.EventVersion = string(e.Event.EventVersion)
+		item.Event = maskKey(e.Event.EventData, e.RoutingKey)
+	}
+	if !e.SendAt.IsZero() {
+		item.SendAt = &e.SendAt
+	}
+	if !e.ExpiresAt.IsZero() {
+		item.ExpiresAt = &e.ExpiresAt
+	}
+	return item
+}
+
+// exportDeadLetter returns a copy of a dead letter with its routing key
+// masked.
+func exportDeadLetter(d persistentqueue.DeadLetter) persistentqueue.DeadLetter {
+	if d.Event != nil {
+		event := *d.Event
+		event.EventData = maskKey(event.EventData, d.RoutingKey)
+		d.Event = &event
+	}
+	if d.RoutingKey != "" {
+		d.Error = strings.Replace(d.Error, d.RoutingKey, common.MaskSecret(d.RoutingKey), -1)
+	}
+	d.RoutingKey = redactKey(d.RoutingKey)
+	return d
+}
+
+// maskKey replaces each occurrence of a routing key in an event.
+func maskKey(data []byte, key string) json.RawMessage {
+	if key == "" {
+		return data
+	}
+	return bytes.Replace(data, []byte(key), []byte(common.MaskSecret(key)), -1)
+}
+
+func redactKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	return common.MaskSecret(key)
+}
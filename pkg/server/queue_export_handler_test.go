@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/version"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestQueueExportHandler(t *testing.T) {
+	stuckKey := "11863b592c824bfc8989d9cba76abcde"
+	rejectedKey := "22863b592c824bfc8989d9cba76abcde"
+
+	// Sends to `stuckKey` block, leaving its event in flight, while those to
+	// `rejectedKey` are dead-lettered.
+	release := make(chan struct{})
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		if strings.Contains(string(job.EventContainer.EventData), rejectedKey) {
+			job.ResponseChan <- eventqueue.Response{Error: &eventsapi.TerminalError{StatusCode: 400, Err: errors.New("invalid event")}}
+			return
+		}
+		<-release
+		job.ResponseChan <- eventqueue.Response{}
+	}
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		close(release)
+		q.Shutdown()
+	}()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q, WithIngestToken("token"), WithDatadogRoutingKey(rejectedKey))
+
+	for _, key := range []string{stuckKey, rejectedKey} {
+		event := test.BuildV2EventContainer(key)
+		if _, err := q.Enqueue(&event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Events enqueued during maintenance stay pending.
+	if err := q.SetMaintenance(true); err != nil {
+		t.Fatal(err)
+	}
+	event := test.BuildV2EventContainer(stuckKey)
+	if _, err := q.Enqueue(&event); err != nil {
+		t.Fatal(err)
+	}
+
+	rw := httptest.NewRecorder()
+	s.QueueExportHandler(rw, httptest.NewRequest("GET", "/queue/export", nil))
+
+	if rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+	if body := rw.Body.String(); strings.Contains(body, stuckKey) || strings.Contains(body, rejectedKey) {
+		t.Errorf("Expected routing keys to be masked, bundle was %v", body)
+	}
+
+	var bundle QueueExportResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	if bundle.Version != version.Version || !bundle.Config.IngestEnabled || bundle.Config.DatadogRoutingKey != "22****de" {
+		t.Errorf("Expected the version and config summary, got %+v and %+v.", bundle.Version, bundle.Config)
+	}
+	if len(bundle.Pending) != 1 || bundle.Pending[0].ID != 3 || bundle.Pending[0].RoutingKey != "11****de" {
+		t.Errorf("Expected the last event to be pending, got %+v.", bundle.Pending)
+	}
+	if len(bundle.InFlight) != 1 || bundle.InFlight[0].ID != 1 || !strings.Contains(string(bundle.InFlight[0].Event), `"routing_key":"11****de"`) {
+		t.Errorf("Expected the first event to be in flight, got %+v.", bundle.InFlight)
+	}
+	if len(bundle.DeadLetters) != 1 || bundle.DeadLetters[0].RoutingKey != "22****de" || bundle.DeadLetters[0].Error != "invalid event (HTTP 400)" {
+		t.Errorf("Expected the rejected event to be dead-lettered, got %+v.", bundle.DeadLetters)
+	}
+
+	events, err := q.List(persistentqueue.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[0].Status != persistentqueue.StatusInFlight || events[2].Status != persistentqueue.StatusPending {
+		t.Errorf("Expected the export to leave the queue unchanged, got %+v.", events)
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+var allowedListStatuses = []string{persistentqueue.StatusPending, persistentqueue.StatusInFlight, persistentqueue.StatusError, persistentqueue.StatusSuccess, persistentqueue.StatusScheduled}
+
+// QueueListHandler lists queued events, filtered by the `status`, `rk`, and
+// `limit` query parameters.
+//
+// Routing keys are masked as they grant access to create incidents.
+func (s *Server) QueueListHandler(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	options := persistentqueue.ListOptions{
+		Status:     query.Get("status"),
+		RoutingKey: query.Get("rk"),
+	}
+
+	if options.Status != "" && !contains(allowedListStatuses, options.Status) {
+		errorResp(rw, 400, []string{"Expected status to be one of: " + strings.Join(allowedListStatuses, ", ")})
+		return
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		options.Limit, err = strconv.Atoi(limit)
+		if err != nil || options.Limit < 0 {
+			errorResp(rw, 400, []string{"Expected a non-negative numeric limit."})
+			return
+		}
+	}
+
+	events, err := s.Queue.List(options)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	items := make([]QueueListItem, 0, len(events))
+	for _, e := range events {
+		item := QueueListItem{
+			ID:         e.ID,
+			Key:        e.Key,
+			RoutingKey: common.MaskSecret(e.RoutingKey),
+			EventType:  eventType(e.Event),
+			Status:     e.Status,
+			Attempts:   e.Attempts,
+			EnqueuedAt: e.CreatedAt,
+		}
+		if e.Status == persistentqueue.StatusScheduled {
+			sendAt := e.SendAt
+			item.NextAttemptAt = &sendAt
+		}
+		items = append(items, item)
+	}
+
+	okResp(rw, QueueListResponse{Events: items})
+}
+
+type QueueListResponse struct {
+	Events []QueueListItem `json:"events"`
+}
+
+type QueueListItem struct {
+	ID         int       `json:"id"`
+	Key        string    `json:"key"`
+	RoutingKey string    `json:"routing_key"`
+	EventType  string    `json:"event_type"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+
+	// NextAttemptAt is when a scheduled event, such as a retry, is next sent.
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// eventType returns the V1 event type or V2 event action of a queued event.
+func eventType(eventContainer *eventsapi.EventContainer) string {
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return ""
+	}
+
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		return e.EventType
+	case *eventsapi.EventV2:
+		return e.EventAction
+	case *eventsapi.ChangeEventV2:
+		return "change"
+	default:
+		return ""
+	}
+}
+
+func contains(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
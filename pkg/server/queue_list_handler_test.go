@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestQueueListHandler(t *testing.T) {
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		job.ResponseChan <- eventqueue.Response{}
+	}
+
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	key1 := "11863b592c824bfc8989d9cba76abcde"
+	key2 := "22863b592c824bfc8989d9cba76abcde"
+	for _, key := range []string{key1, key2} {
+		event := test.BuildV2EventContainer(key)
+		if _, err := q.Enqueue(&event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	tests := []struct {
+		name        string
+		query       string
+		expectedIDs []int
+	}{
+		{"all", "", []int{1, 2}},
+		{"routingKey", "?rk=" + key2, []int{2}},
+		{"status", "?status=success&limit=1", []int{1}},
+		{"noMatchingStatus", "?status=pending", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			s.QueueListHandler(rw, httptest.NewRequest("GET", "/queue"+tt.query, nil))
+
+			if rw.Code != 200 {
+				t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+			}
+
+			var list QueueListResponse
+			if err := json.Unmarshal(rw.Body.Bytes(), &list); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(list.Events) != len(tt.expectedIDs) {
+				t.Fatalf("Expected %v events, found %v.", len(tt.expectedIDs), len(list.Events))
+			}
+			for i, e := range list.Events {
+				if e.ID != tt.expectedIDs[i] {
+					t.Errorf("Expected event %v, was %v.", tt.expectedIDs[i], e.ID)
+				}
+				if e.RoutingKey == key1 || e.RoutingKey == key2 || len(e.RoutingKey) != 8 {
+					t.Errorf("Expected routing key to be masked, was %v.", e.RoutingKey)
+				}
+				if e.EventType != "trigger" {
+					t.Errorf("Expected trigger event type, was %v.", e.EventType)
+				}
+			}
+		})
+	}
+}
+
+func TestQueueListHandlerInvalid(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "secret", "", nil)
+
+	for _, query := range []string{"?status=unknown", "?limit=abc", "?limit=-1"} {
+		rw := httptest.NewRecorder()
+		s.QueueListHandler(rw, httptest.NewRequest("GET", "/queue"+query, nil))
+
+		if rw.Code != 400 {
+			t.Errorf("Expected 400 response for %v, was %v.", query, rw.Code)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+)
+
+// WithReloader is an option setting how the server applies configuration
+// changes on `SIGHUP`, e.g. re-reading its config file.
+func WithReloader(reloader func() error) Option {
+	return func(s *Server) {
+		s.reloader = reloader
+	}
+}
+
+// Reload applies configuration changes with the server's reloader, leaving
+// its queue and listener untouched. Errors are logged and returned, keeping
+// the current configuration for any setting not yet applied.
+func (s *Server) Reload() error {
+	if s.reloader == nil {
+		s.logger.Warn("Reload requested but not supported, ignoring.")
+		return nil
+	}
+
+	s.logger.Info("Reloading configuration.")
+	if err := s.reloader(); err != nil {
+		s.logger.Errorf("Failed to reload configuration: %v", err)
+		return err
+	}
+	s.logger.Info("Reloaded configuration.")
+	return nil
+}
+
+// SetIngestRateLimit changes the ingest rate limit, e.g. on reload. A zero
+// rate disables it.
+func (s *Server) SetIngestRateLimit(rate float64, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.IngestRateLimit = rate
+	s.IngestBurst = burst
+	s.ingestLimiter = nil
+	if rate > 0 {
+		s.ingestLimiter = common.NewTokenBucket(rate, burst)
+	}
+}
+
+// SetDefaultRoutingKeys changes the routing keys used for Alertmanager and
+// Datadog webhooks without one of their own, e.g. on reload.
+func (s *Server) SetDefaultRoutingKeys(alertmanager, datadog string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.AlertmanagerRoutingKey = alertmanager
+	s.DatadogRoutingKey = datadog
+}
+
+func (s *Server) rateLimiter() *common.TokenBucket {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ingestLimiter
+}
+
+func (s *Server) alertmanagerRoutingKey() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.AlertmanagerRoutingKey
+}
+
+func (s *Server) datadogRoutingKey() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.DatadogRoutingKey
+}
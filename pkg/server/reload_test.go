@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+func TestServerReload(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue()
+
+	reloads := 0
+	s := NewServer("127.0.0.1:0", "secret", "", q, WithReloader(func() error {
+		reloads++
+		return nil
+	}))
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if reloads != 1 {
+		t.Errorf("Expected the reloader to be called once, was %v.", reloads)
+	}
+
+	failing := errors.New("invalid config")
+	s = NewServer("127.0.0.1:0", "secret", "", q, WithReloader(func() error { return failing }))
+	if err := s.Reload(); err != failing {
+		t.Errorf("Expected the reloader's error, got %v.", err)
+	}
+
+	// Without a reloader there's nothing to apply.
+	s = NewServer("127.0.0.1:0", "secret", "", q)
+	if err := s.Reload(); err != nil {
+		t.Errorf("Expected no error without a reloader, got %v.", err)
+	}
+}
+
+func TestServerSetIngestRateLimit(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "secret", "", persistentqueue.NewPersistentQueue())
+	if s.rateLimiter() != nil {
+		t.Fatal("Expected no ingest rate limit by default.")
+	}
+
+	s.SetIngestRateLimit(0.1, 1)
+	limiter := s.rateLimiter()
+	if limiter == nil {
+		t.Fatal("Expected an ingest rate limit once set.")
+	}
+	if ok, _ := limiter.Allow(); !ok {
+		t.Error("Expected the burst to be allowed.")
+	}
+	if ok, _ := limiter.Allow(); ok {
+		t.Error("Expected requests over the new limit to be refused.")
+	}
+
+	s.SetIngestRateLimit(0, 1)
+	if s.rateLimiter() != nil {
+		t.Error("Expected a zero rate to remove the ingest rate limit.")
+	}
+}
+
+func TestServerSetDefaultRoutingKeys(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "secret", "", persistentqueue.NewPersistentQueue(),
+		WithAlertmanagerRoutingKey("11863b592c824bfc8989d9cba76abcde"),
+	)
+
+	s.SetDefaultRoutingKeys("22863b592c824bfc8989d9cba76abcde", "33863b592c824bfc8989d9cba76abcde")
+	if key := s.alertmanagerRoutingKey(); key != "22863b592c824bfc8989d9cba76abcde" {
+		t.Errorf("Expected the new Alertmanager routing key, was %v.", key)
+	}
+	if key := s.datadogRoutingKey(); key != "33863b592c824bfc8989d9cba76abcde" {
+		t.Errorf("Expected the new Datadog routing key, was %v.", key)
+	}
+}
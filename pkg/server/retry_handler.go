@@ -3,10 +3,23 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
 )
 
+// RetryHandler retries failed events, either a single failed or scheduled
+// event given by the `id` query parameter, or all failed events for the `rk`
+// routing key, or for all routing keys if none is given.
 func (s *Server) RetryHandler(rw http.ResponseWriter, req *http.Request) {
-	rk := req.URL.Query().Get("rk")
+	query := req.URL.Query()
+
+	if query.Get("id") != "" {
+		s.retryEvent(rw, query.Get("id"))
+		return
+	}
+
+	rk := query.Get("rk")
 
 	if rk == "" {
 		s.logger.Debugf("Retrying for all routing keys.")
@@ -23,6 +36,30 @@ func (s *Server) RetryHandler(rw http.ResponseWriter, req *http.Request) {
 	okResp(rw, RetryResponse{fmt.Sprintf("Retrying %v events.", count)})
 }
 
+func (s *Server) retryEvent(rw http.ResponseWriter, rawID string) {
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		errorResp(rw, 400, []string{"Expected a numeric event id."})
+		return
+	}
+
+	s.logger.Debugf("Retrying event %v", id)
+
+	err = s.Queue.RetryEvent(id)
+	if err == persistentqueue.ErrNotFound {
+		errorResp(rw, 404, []string{fmt.Sprintf("Event %v not found.", id)})
+		return
+	} else if err == persistentqueue.ErrNotRetryable {
+		errorResp(rw, 409, []string{err.Error()})
+		return
+	} else if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, RetryResponse{fmt.Sprintf("Retrying event %v.", id)})
+}
+
 type RetryResponse struct {
 	Message string `json:"message"`
 }
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/test"
+)
+
+func TestRetryHandlerEvent(t *testing.T) {
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		job.ResponseChan <- eventqueue.Response{Error: &eventsapi.RetryableError{StatusCode: 500}}
+	}
+
+	clock := test.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq), persistentqueue.WithMaxRetries(3), persistentqueue.WithRetryDelay(time.Minute, time.Hour), persistentqueue.WithClock(clock))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	event := test.BuildV2EventContainer("11863b592c824bfc8989d9cba76abcde")
+	if _, err := q.Enqueue(&event); err != nil {
+		t.Fatal(err)
+	}
+	clock.BlockUntil(1)
+
+	listItem := func() QueueListItem {
+		rw := httptest.NewRecorder()
+		s.QueueListHandler(rw, httptest.NewRequest("GET", "/queue", nil))
+
+		var list QueueListResponse
+		if err := json.Unmarshal(rw.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Events) != 1 {
+			t.Fatalf("Expected 1 event, found %v.", len(list.Events))
+		}
+		return list.Events[0]
+	}
+
+	// The first retry is due within the base delay.
+	scheduled := func(item QueueListItem) bool {
+		return item.NextAttemptAt != nil && !item.NextAttemptAt.Before(clock.Now()) && !item.NextAttemptAt.After(clock.Now().Add(time.Minute))
+	}
+
+	item := listItem()
+	if item.Status != persistentqueue.StatusScheduled || item.Attempts != 1 || !scheduled(item) {
+		t.Errorf("Expected a retry scheduled after 1 attempt, got %+v.", item)
+	}
+
+	rw := httptest.NewRecorder()
+	s.RetryHandler(rw, httptest.NewRequest("POST", "/queue/retry?id=1", nil))
+	if rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	item = listItem()
+	if item.Attempts != 1 || !scheduled(item) {
+		t.Errorf("Expected the retry to reset the attempts and backoff, got %+v.", item)
+	}
+
+	for query, code := range map[string]int{"?id=2": 404, "?id=one": 400} {
+		rw := httptest.NewRecorder()
+		s.RetryHandler(rw, httptest.NewRequest("POST", "/queue/retry"+query, nil))
+		if rw.Code != code {
+			t.Errorf("Expected %v response for %v, was %v: %v", code, query, rw.Code, rw.Body.String())
+		}
+	}
+}
@@ -8,12 +8,38 @@ func Router(s *Server) *mux.Router {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/health", s.HealthHandler)
+	r.HandleFunc(livenessPath, s.LivenessHandler)
+	r.HandleFunc(readinessPath, s.ReadinessHandler)
 	r.HandleFunc("/send", s.SendHandler)
+	r.HandleFunc("/status", s.AgentStatusHandler)
+	r.HandleFunc("/queue", s.QueueListHandler)
+	r.HandleFunc("/queue/flush", s.FlushHandler)
+	r.HandleFunc("/queue/purge", s.PurgeHandler)
 	r.HandleFunc("/queue/retry", s.RetryHandler)
 	r.HandleFunc("/queue/status", s.StatusHandler)
+	r.HandleFunc("/queue/event", s.EventStatusHandler)
+	r.HandleFunc("/queue/export", s.QueueExportHandler)
+	r.HandleFunc("/dead-letters", s.DeadLettersHandler)
+	r.HandleFunc("/dead-letters/retry", s.DeadLetterRetryHandler)
+	r.HandleFunc("/dead-letters/replay", s.DeadLetterReplayHandler)
+	r.HandleFunc("/maintenance", s.MaintenanceHandler)
+	r.HandleFunc("/drain", s.DrainHandler)
+	r.HandleFunc("/dedup", s.DedupReportHandler)
+
+	if s.MetricsEnabled {
+		r.HandleFunc("/metrics", s.MetricsHandler)
+	}
+
+	if s.IngestToken != "" {
+		r.HandleFunc(ingestPath, s.IngestHandler)
+		r.HandleFunc(alertmanagerPath, s.AlertmanagerHandler)
+		r.HandleFunc(datadogPath, s.DatadogHandler)
+	}
 
 	r.Use(loggingMiddleware(s.logger))
 	r.Use(authMiddleware(s))
+	r.Use(signatureMiddleware(s))
+	r.Use(rateLimitMiddleware(s))
 
 	return r
 }
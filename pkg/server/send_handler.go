@@ -1,12 +1,20 @@
 package server
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"regexp"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
 )
 
+// integrationPattern matches the integration names accepted in the
+// `Pd-Integration` header, safe to include in a `User-Agent`.
+var integrationPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
 func (s *Server) SendHandler(rw http.ResponseWriter, req *http.Request) {
 	body, err := ioutil.ReadAll(req.Body)
 	if err != nil {
@@ -19,10 +27,46 @@ func (s *Server) SendHandler(rw http.ResponseWriter, req *http.Request) {
 	eventContainer := eventsapi.EventContainer{
 		EventVersion: eventsapi.StringToEventVersion[req.Header["Pd-Event-Version"][0]],
 		EventData:    body,
+		TraceParent:  req.Header.Get("Traceparent"),
+	}
+
+	if ttl := req.Header.Get("Pd-Event-Ttl"); ttl != "" {
+		eventContainer.TTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			errorResp(rw, 400, []string{fmt.Sprintf("Invalid Pd-Event-Ttl header: %v", err)})
+			return
+		}
+	}
+
+	if delay := req.Header.Get("Pd-Auto-Resolve-After"); delay != "" {
+		eventContainer.AutoResolveAfter, err = time.ParseDuration(delay)
+		if err != nil {
+			errorResp(rw, 400, []string{fmt.Sprintf("Invalid Pd-Auto-Resolve-After header: %v", err)})
+			return
+		}
+	}
+
+	if integration := req.Header.Get("Pd-Integration"); integration != "" {
+		if !integrationPattern.MatchString(integration) {
+			errorResp(rw, 400, []string{"Invalid Pd-Integration header: expected up to 32 letters, digits, dashes, or underscores"})
+			return
+		}
+		eventContainer.Integration = integration
+	}
+
+	if priority := req.Header.Get("Pd-Priority"); priority != "" {
+		eventContainer.Priority, err = eventsapi.ParsePriority(priority)
+		if err != nil {
+			errorResp(rw, 400, []string{fmt.Sprintf("Invalid Pd-Priority header: %v", err)})
+			return
+		}
 	}
 
 	key, err := s.Queue.Enqueue(&eventContainer)
-	if err != nil {
+	if err == persistentqueue.ErrQueueShutdown || err == persistentqueue.ErrQueueFull || err == persistentqueue.ErrQueueDraining {
+		errorResp(rw, 503, []string{err.Error()})
+		return
+	} else if err != nil {
 		errorResp(rw, 500, []string{err.Error()})
 		return
 	}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/tracing"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// deliveredHook is closed once an event is delivered.
+type deliveredHook chan struct{}
+
+func (h deliveredHook) OnSuccess(*persistentqueue.Event, string) error {
+	close(h)
+	return nil
+}
+
+func TestSendHandlerTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	// PagerDuty fails the first attempt, so the event has two send spans.
+	attempts := 0
+	pagerDuty := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if attempts++; attempts == 1 {
+			rw.WriteHeader(500)
+			return
+		}
+		rw.WriteHeader(202)
+		fmt.Fprint(rw, `{"status":"success","dedup_key":"abc"}`)
+	}))
+	defer pagerDuty.Close()
+	viper.Set("events-url", pagerDuty.URL)
+	defer viper.Set("events-url", nil)
+
+	transport := common.NewRetryTransport()
+	transport.Transport = tracing.NewTransport(http.DefaultTransport)
+	transport.Backoff = func(int, time.Duration, time.Duration) time.Duration { return time.Millisecond }
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = eventqueue.NewEventProcessor(eventsapi.WithHTTPClient(eventsapi.NewHTTPClient(transport)))
+
+	delivered := make(deliveredHook)
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq), persistentqueue.WithSuccessHook(delivered))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	caller := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	req := httptest.NewRequest("POST", "/send", strings.NewReader(`{"routing_key":"11863b592c824bfc8989d9cba76abcde","event_action":"trigger","payload":{"summary":"summary","source":"source","severity":"error"}}`))
+	req.Header.Set("Pd-Event-Version", "v2")
+	req.Header.Set("Traceparent", caller)
+	rw := httptest.NewRecorder()
+	s.SendHandler(rw, req)
+	if rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	// Every span has ended once the event is delivered.
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the event to be delivered.")
+	}
+
+	spans := map[string]tracetest.SpanStubs{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = append(spans[span.Name], span)
+	}
+	if len(spans["pdagent.enqueue"]) != 1 || len(spans["pdagent.queue_wait"]) != 1 || len(spans["pdagent.send_attempt"]) != 2 {
+		t.Fatalf("Expected an enqueue, queue wait, and 2 send attempt spans, got %+v.", spans)
+	}
+
+	enqueue := spans["pdagent.enqueue"][0]
+	if enqueue.SpanContext.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" || enqueue.Parent.SpanID().String() != "b7ad6b7169203331" {
+		t.Errorf("Expected the enqueue span to continue the caller's trace, was %v with parent %v.", enqueue.SpanContext.TraceID(), enqueue.Parent.SpanID())
+	}
+
+	children := append(spans["pdagent.queue_wait"], spans["pdagent.send_attempt"]...)
+	for _, span := range children {
+		if span.SpanContext.TraceID() != enqueue.SpanContext.TraceID() || span.Parent.SpanID() != enqueue.SpanContext.SpanID() {
+			t.Errorf("Expected %v to be a child of the enqueue span, was %v with parent %v.", span.Name, span.SpanContext.TraceID(), span.Parent.SpanID())
+		}
+	}
+
+	failed, sent := spans["pdagent.send_attempt"][0], spans["pdagent.send_attempt"][1]
+	if statusCode(failed) != 500 || failed.Status.Code != codes.Error {
+		t.Errorf("Expected the first attempt to fail with a 500, got %v and %+v.", failed.Attributes, failed.Status)
+	}
+	if statusCode(sent) != 202 || sent.Status.Code == codes.Error {
+		t.Errorf("Expected the second attempt to succeed with a 202, got %v and %+v.", sent.Attributes, sent.Status)
+	}
+}
+
+// statusCode returns a send attempt span's HTTP status, or 0 if it has none.
+func statusCode(span tracetest.SpanStub) int64 {
+	for _, kv := range span.Attributes {
+		if kv.Key == "http.status_code" {
+			return kv.Value.AsInt64()
+		}
+	}
+	return 0
+}
+
+func TestSendHandlerQueueFull(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eventqueue.NewEventQueue()), persistentqueue.WithMaintenance(true), persistentqueue.WithMaxQueueDepth(1, persistentqueue.OverflowReject))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	send := func(action string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/send", strings.NewReader(`{"routing_key":"11863b592c824bfc8989d9cba76abcde","event_action":"`+action+`","dedup_key":"abc","payload":{"summary":"summary","source":"source","severity":"error"}}`))
+		req.Header.Set("Pd-Event-Version", "v2")
+		rw := httptest.NewRecorder()
+		s.SendHandler(rw, req)
+		return rw
+	}
+
+	if rw := send("trigger"); rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+	if rw := send("trigger"); rw.Code != 503 || !strings.Contains(rw.Body.String(), persistentqueue.ErrQueueFull.Error()) {
+		t.Errorf("Expected 503 response once the queue is full, was %v: %v", rw.Code, rw.Body.String())
+	}
+	if rw := send("resolve"); rw.Code != 200 {
+		t.Errorf("Expected 200 response for a resolve, was %v: %v", rw.Code, rw.Body.String())
+	}
+}
+
+func TestSendHandlerIntegration(t *testing.T) {
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eventqueue.NewEventQueue()), persistentqueue.WithMaintenance(true))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+
+	send := func(integration string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/send", strings.NewReader(`{"routing_key":"11863b592c824bfc8989d9cba76abcde","event_action":"trigger","payload":{"summary":"summary","source":"source","severity":"error"}}`))
+		req.Header.Set("Pd-Event-Version", "v2")
+		req.Header.Set("Pd-Integration", integration)
+		rw := httptest.NewRecorder()
+		s.SendHandler(rw, req)
+		return rw
+	}
+
+	if rw := send("icinga2"); rw.Code != 200 {
+		t.Fatalf("Expected 200 response, was %v: %v", rw.Code, rw.Body.String())
+	}
+	if rw := send("nagios; evil"); rw.Code != 400 {
+		t.Errorf("Expected 400 response for an invalid integration, was %v: %v", rw.Code, rw.Body.String())
+	}
+
+	events, err := q.List(persistentqueue.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Event.Integration != "icinga2" {
+		t.Errorf("Expected the event to record its integration, got %+v.", events)
+	}
+}
@@ -6,21 +6,40 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 )
 
 type Queue interface {
+	DeadLetters(string) ([]persistentqueue.DeadLetter, error)
+	Drain(time.Duration) (int, error)
 	Enqueue(*eventsapi.EventContainer) (string, error)
+	EventStatus(string) (persistentqueue.EventStatus, error)
+	Flush(time.Duration) (persistentqueue.FlushResult, error)
+	Health() (persistentqueue.Health, error)
+	List(persistentqueue.ListOptions) ([]persistentqueue.Event, error)
+	Maintenance() bool
+	Metrics() (persistentqueue.Metrics, error)
+	Purge(bool) (persistentqueue.PurgeResult, error)
+	Ready() error
+	Replay(int, string) (string, error)
+	ReplayAll(string) (int, error)
+	RetryDeadLetter(int) error
 	Retry(string) (int, error)
+	RetryEvent(int) error
+	SetMaintenance(bool) error
 	Shutdown() error
 	Start() error
 	Status(string) ([]persistentqueue.StatusItem, error)
+	Suppressions() []persistentqueue.Suppression
 }
 
 type Server struct {
@@ -28,16 +47,155 @@ type Server struct {
 	Queue      Queue
 	Heartbeat  Heartbeat
 
-	pidfile string
-	secret  string
-	logger  *zap.SugaredLogger
+	// MetricsEnabled exposes queue metrics on `/metrics` when set.
+	MetricsEnabled bool
+
+	// IngestToken enables `/ingest`, `/alertmanager`, and `/datadog` when
+	// set, requiring it in the `X-Agent-Token` header or as a bearer token.
+	IngestToken string
+
+	// AlertmanagerRoutingKey is used for Alertmanager groups without a
+	// `pagerduty_routing_key` label.
+	AlertmanagerRoutingKey string
+
+	// DatadogRoutingKey is used for Datadog webhooks without a `routing_key`
+	// query parameter.
+	DatadogRoutingKey string
+
+	// Breaker, when set, has its state reported on `/status`.
+	Breaker *eventqueue.CircuitBreaker
+
+	// IngestRateLimit, when positive, caps events accepted per second across
+	// `/send` and the ingest endpoints, allowing bursts of up to
+	// IngestBurst. Requests beyond it receive a 429 with a `Retry-After`.
+	IngestRateLimit float64
+	IngestBurst     int
+
+	// SigningSecret, when set, requires `/send` and `/ingest` requests to be
+	// signed with it, see `common.SignBody`.
+	SigningSecret string
+
+	// UnauthenticatedProbes lets `/health`, `/healthz`, and `/readyz` be
+	// requested without the secret, e.g. by load balancers.
+	UnauthenticatedProbes bool
+
+	ingestLimiter *common.TokenBucket
+
+	// drainOnStart drains the queue as soon as the server starts, within
+	// drainTimeout, see `Drain`.
+	drainOnStart bool
+	drainTimeout time.Duration
+	drained      chan error
+
+	// tracerProvider, when set, is shut down as the server stops, exporting
+	// any spans still buffered.
+	tracerProvider *sdktrace.TracerProvider
+
+	// mu guards the settings changed on reload, and whether draining.
+	mu        sync.RWMutex
+	draining  bool
+	pidfile   string
+	reloader  func() error
+	transport http.RoundTripper
+	secret    string
+	logger    *zap.SugaredLogger
 }
 
 type Option func(*Server)
 
-func NewServer(address, secret, pidfile string, queue Queue) *Server {
+// WithMetricsEnabled is an option toggling the `/metrics` endpoint.
+func WithMetricsEnabled(enabled bool) Option {
+	return func(s *Server) {
+		s.MetricsEnabled = enabled
+	}
+}
+
+// WithIngestToken is an option enabling the `/ingest`, `/alertmanager`, and
+// `/datadog` endpoints, authenticated by the given token.
+func WithIngestToken(token string) Option {
+	return func(s *Server) {
+		s.IngestToken = token
+	}
+}
+
+// WithAlertmanagerRoutingKey is an option setting the default routing key for
+// Alertmanager webhooks.
+func WithAlertmanagerRoutingKey(routingKey string) Option {
+	return func(s *Server) {
+		s.AlertmanagerRoutingKey = routingKey
+	}
+}
+
+// WithDatadogRoutingKey is an option setting the default routing key for
+// Datadog webhooks.
+func WithDatadogRoutingKey(routingKey string) Option {
+	return func(s *Server) {
+		s.DatadogRoutingKey = routingKey
+	}
+}
+
+// WithCircuitBreaker is an option reporting the send path's circuit breaker
+// state on `/status`.
+func WithCircuitBreaker(breaker *eventqueue.CircuitBreaker) Option {
+	return func(s *Server) {
+		s.Breaker = breaker
+	}
+}
+
+// WithIngestRateLimit is an option limiting the rate events are accepted,
+// protecting the queue from runaway clients.
+func WithIngestRateLimit(rate float64, burst int) Option {
+	return func(s *Server) {
+		s.IngestRateLimit = rate
+		s.IngestBurst = burst
+	}
+}
+
+// WithSigningSecret is an option requiring `/send` and `/ingest` requests to
+// carry an HMAC signature made with the given secret, e.g. when the server is
+// reachable beyond localhost.
+func WithSigningSecret(secret string) Option {
+	return func(s *Server) {
+		s.SigningSecret = secret
+	}
+}
+
+// WithUnauthenticatedProbes is an option skipping authentication for health
+// and readiness probes.
+func WithUnauthenticatedProbes(enabled bool) Option {
+	return func(s *Server) {
+		s.UnauthenticatedProbes = enabled
+	}
+}
+
+// WithTransport is an option overriding the transport used for the server's
+// own requests to PagerDuty, e.g. heartbeats.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(s *Server) {
+		s.transport = transport
+	}
+}
+
+// WithDrain is an option draining the queue once the server starts, sending
+// any backlog and then exiting rather than accepting new events.
+func WithDrain(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.drainOnStart = true
+		s.drainTimeout = timeout
+	}
+}
+
+// WithTracerProvider is an option shutting down the given tracer provider
+// once the server and its queue have stopped, so the last spans are exported
+// before the process exits.
+func WithTracerProvider(provider *sdktrace.TracerProvider) Option {
+	return func(s *Server) {
+		s.tracerProvider = provider
+	}
+}
+
+func NewServer(address, secret, pidfile string, queue Queue, options ...Option) *Server {
 	logger := common.Logger.Named("Server")
-	heartbeat := NewHeartbeat()
 
 	server := Server{
 		HTTPServer: &http.Server{
@@ -47,12 +205,23 @@ func NewServer(address, secret, pidfile string, queue Queue) *Server {
 			MaxHeaderBytes: 1 << 20,
 		},
 		Queue:     queue,
-		Heartbeat: heartbeat,
+		drained:   make(chan error, 1),
 		pidfile:   pidfile,
+		transport: http.DefaultTransport,
 		secret:    secret,
 		logger:    logger,
 	}
 
+	for _, option := range options {
+		option(&server)
+	}
+
+	server.Heartbeat = NewHeartbeat(server.transport)
+
+	if server.IngestRateLimit > 0 {
+		server.ingestLimiter = common.NewTokenBucket(server.IngestRateLimit, server.IngestBurst)
+	}
+
 	server.HTTPServer.Handler = Router(&server)
 
 	return &server
@@ -60,13 +229,18 @@ func NewServer(address, secret, pidfile string, queue Queue) *Server {
 
 func (s *Server) Start() error {
 	s.logger.Infof("Server starting at %v", s.HTTPServer.Addr)
+	defer s.shutdownTracing()
 
 	if err := s.initPidfile(); err != nil {
 		return err
 	}
 
 	if err := s.Queue.Start(); err != nil {
-		s.logger.Error("Failed to start server's queue.")
+		s.logger.Errorf("Failed to start server's queue: %v", err)
+		// The pidfile is ours, so mustn't be left to block the next start.
+		if err := common.RemovePidfile(s.pidfile); err != nil {
+			s.logger.Errorf("Failed to remove pidfile: %v", err)
+		}
 		return err
 	}
 
@@ -76,9 +250,10 @@ func (s *Server) Start() error {
 		s.logger.Info(s.HTTPServer.ListenAndServe())
 	}()
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+	if s.drainOnStart {
+		_ = s.Drain(s.drainTimeout)
+	}
+	drainErr := s.waitForStop()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -96,9 +271,43 @@ func (s *Server) Start() error {
 	if err := common.RemovePidfile(s.pidfile); err != nil {
 		return err
 	}
+	return drainErr
+}
 
-	os.Exit(0)
-	return nil
+// shutdownTracing shuts down the tracer provider, if any, flushing the spans
+// it has yet to export.
+func (s *Server) shutdownTracing() {
+	if s.tracerProvider == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.tracerProvider.Shutdown(ctx); err != nil {
+		s.logger.Errorf("Failed to export remaining traces: %v", err)
+	}
+}
+
+// waitForStop blocks until the server is signalled to stop or a drain
+// completes, returning the drain's error, and reloading its configuration on
+// each `SIGHUP` in the meantime.
+func (s *Server) waitForStop() error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	for {
+		select {
+		case <-reload:
+			_ = s.Reload()
+		case <-stop:
+			return nil
+		case err := <-s.drained:
+			return err
+		}
+	}
 }
 
 func (s *Server) initPidfile() error {
@@ -117,3 +326,30 @@ func (s *Server) initPidfile() error {
 	s.logger.Infof("Successfully wrote pidfile: %v", s.pidfile)
 	return nil
 }
+
+// Drain starts draining the queue in the background, refusing new events,
+// with the server stopping once it's done. Returns `errAlreadyDraining` if a
+// drain was already started.
+//
+// Should events remain after `timeout`, they're left in the store for the
+// next start, and `Start` returns the drain's error so the server can exit
+// non-zero.
+func (s *Server) Drain(timeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.draining {
+		return errAlreadyDraining
+	}
+	s.draining = true
+
+	s.logger.Infof("Draining queue with timeout %v.", timeout)
+	go func() {
+		remaining, err := s.Queue.Drain(timeout)
+		if err != nil {
+			s.logger.Errorf("Failed to drain queue, %v events left for the next start: %v", remaining, err)
+		}
+		s.drained <- err
+	}()
+	return nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/client"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestServerListensOnConfiguredAddress(t *testing.T) {
+	// Find a free port to configure the server with.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	s := NewServer(address, "secret", "", nil)
+
+	ln, err = net.Listen("tcp", s.HTTPServer.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.HTTPServer.Serve(ln) }()
+	defer s.HTTPServer.Close()
+
+	c := client.NewClient(http.DefaultClient, address, "secret")
+
+	req, _ := http.NewRequest("GET", "http://"+c.ServerAddress+"/health", nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "OK" {
+		t.Errorf("Expected healthy response from %v, was %v %v.", address, resp.StatusCode, string(body))
+	}
+}
+
+// keptSpansExporter is an in-memory exporter keeping its spans on shut down,
+// so they can be checked after.
+type keptSpansExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keptSpansExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// For this test the server drains its empty queue on start, so stops on its
+// own, with a span still waiting on the batcher to export it.
+//
+// The expectation is `Start` returns, rather than exiting the process, once
+// the span has been exported.
+func TestServerStartExportsSpans(t *testing.T) {
+	exporter := keptSpansExporter{tracetest.NewInMemoryExporter()}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(time.Hour)))
+	_, span := provider.Tracer("test").Start(context.Background(), "send")
+	span.End()
+
+	eq := eventqueue.NewEventQueue()
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithEventQueue(eq), persistentqueue.WithMemory())
+	pidfile := filepath.Join(t.TempDir(), "pdagent.pid")
+	s := NewServer("127.0.0.1:0", "secret", pidfile, q, WithDrain(time.Second), WithTracerProvider(provider))
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].Name != "send" {
+		t.Errorf("Expected the buffered span to be exported on stopping, got %v.", spans)
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
@@ -27,3 +28,24 @@ func (s *Server) StatusHandler(rw http.ResponseWriter, req *http.Request) {
 type StatusResponse struct {
 	StatusItems []persistentqueue.StatusItem `json:"status_items,omitempty"`
 }
+
+// EventStatusHandler returns the delivery state of the event `key`, as
+// returned when it was enqueued.
+func (s *Server) EventStatusHandler(rw http.ResponseWriter, req *http.Request) {
+	key := req.URL.Query().Get("key")
+	if key == "" {
+		errorResp(rw, 400, []string{"Expected an event key."})
+		return
+	}
+
+	status, err := s.Queue.EventStatus(key)
+	if err == persistentqueue.ErrNotFound {
+		errorResp(rw, 404, []string{fmt.Sprintf("Event %v not found.", key)})
+		return
+	} else if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, status)
+}
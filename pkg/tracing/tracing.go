@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer spans are started with.
+const instrumentationName = "github.com/PagerDuty/go-pdagent"
+
+// propagator encodes span contexts as W3C `traceparent` headers, as stored
+// with each event.
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the tracer from OpenTelemetry's global tracer provider, e.g.
+// to follow an event from being enqueued to each attempt sending it.
+//
+// Tracing is disabled until a provider is set with `otel.SetTracerProvider`,
+// with spans started before then doing nothing.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// NewTracerProvider returns a tracer provider exporting spans in batches to
+// the OpenTelemetry collector at `endpoint`, e.g. "http://localhost:4318",
+// using OTLP over HTTP, until shut down.
+//
+// Spans are dropped rather than slowing sends should the collector be
+// unreachable for long enough to fill the export buffer.
+func NewTracerProvider(endpoint string, client *http.Client) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimRight(endpoint, "/")+"/v1/traces"),
+		otlptracehttp.WithHTTPClient(client),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "pdagent"))),
+	), nil
+}
+
+// ContextWithTraceparent returns a context carrying the span context encoded
+// in a W3C `traceparent` header, so spans started from it continue its trace.
+// Malformed headers are ignored.
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	return propagator.Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// Traceparent encodes the context's span context as a W3C `traceparent`
+// header, or an empty string if it has none.
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// SetError marks a span as failed with `err`, if it's not nil.
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
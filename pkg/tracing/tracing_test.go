@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		valid       bool
+	}{
+		{"valid", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", true},
+		{"empty", "", false},
+		{"shortTraceID", "00-0af7651916cd43dd-b7ad6b7169203331-01", false},
+		{"notHex", "00-0af7651916cd43dd8448eb211c8031zz-b7ad6b7169203331-01", false},
+		{"zeroIDs", "00-00000000000000000000000000000000-0000000000000000-01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := ContextWithTraceparent(context.Background(), tt.traceparent)
+			if valid := trace.SpanContextFromContext(ctx).IsValid(); valid != tt.valid {
+				t.Fatalf("Expected valid to be %v, was %v.", tt.valid, valid)
+			}
+			if tt.valid && Traceparent(ctx) != tt.traceparent {
+				t.Errorf("Expected %v to round trip, was %v.", tt.traceparent, Traceparent(ctx))
+			}
+			if !tt.valid && Traceparent(ctx) != "" {
+				t.Errorf("Expected no traceparent, was %v.", Traceparent(ctx))
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(500)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport)}
+
+	// Requests outside of a trace aren't traced.
+	resp, err := client.Get(server.URL + "/v2/enqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("Expected no spans outside of a trace, got %v.", spans)
+	}
+
+	ctx := ContextWithTraceparent(context.Background(), "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	req, _ := http.NewRequestWithContext(ctx, "POST", server.URL+"/v2/enqueue", nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %v.", spans)
+	}
+	span := spans[0]
+	if span.Name != "pdagent.send_attempt" || span.Parent.SpanID().String() != "b7ad6b7169203331" {
+		t.Errorf("Expected a send attempt span beneath the request's parent, was %v beneath %v.", span.Name, span.Parent.SpanID())
+	}
+	if span.Status.Code != codes.Error || span.Status.Description != "HTTP 500" {
+		t.Errorf("Expected the span to fail with the HTTP status, was %+v.", span.Status)
+	}
+
+	expected := map[attribute.Key]attribute.Value{
+		"http.method":      attribute.StringValue("POST"),
+		"http.url":         attribute.StringValue(server.URL + "/v2/enqueue"),
+		"http.status_code": attribute.IntValue(500),
+	}
+	for _, kv := range span.Attributes {
+		if want, ok := expected[kv.Key]; ok && kv.Value != want {
+			t.Errorf("Expected %v to be %v, was %v.", kv.Key, want.Emit(), kv.Value.Emit())
+		}
+		delete(expected, kv.Key)
+	}
+	if len(expected) != 0 {
+		t.Errorf("Expected attributes %v to be set.", expected)
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests <- req
+	}))
+	defer collector.Close()
+
+	provider, err := NewTracerProvider(collector.URL+"/", collector.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := provider.Tracer(instrumentationName).Start(context.Background(), "pdagent.enqueue")
+	span.End()
+
+	// Shutting down flushes buffered spans.
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case req := <-requests:
+		if req.URL.Path != "/v1/traces" || req.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("Unexpected export to %v with %v.", req.URL.Path, req.Header.Get("Content-Type"))
+		}
+	default:
+		t.Fatal("Expected spans to be exported on shutdown.")
+	}
+}
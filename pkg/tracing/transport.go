@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport is an `http.RoundTripper` recording a span for each request made
+// within a trace, e.g. each attempt sending an event when beneath a
+// `common.RetryTransport`.
+type Transport struct {
+	Transport http.RoundTripper
+}
+
+func NewTransport(transport http.RoundTripper) Transport {
+	return Transport{Transport: transport}
+}
+
+// Implementing the `http.RoundTripper` interface.
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(req.Context()).IsValid() {
+		return t.Transport.RoundTrip(req)
+	}
+
+	_, span := Tracer().Start(req.Context(), "pdagent.send_attempt", trace.WithAttributes(
+		attribute.String("http.method", req.Method),
+		attribute.String("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path),
+	))
+	defer span.End()
+
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		SetError(span, err)
+		return resp, err
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		SetError(span, &statusError{resp.StatusCode})
+	}
+	return resp, nil
+}
+
+type statusError struct {
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return "HTTP " + strconv.Itoa(e.statusCode)
+}
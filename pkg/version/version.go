@@ -0,0 +1,27 @@
+package version
+
+// Build information, normally set at build time using `-ldflags`, e.g.:
+//
+//     -X 'github.com/PagerDuty/go-pdagent/pkg/version.Version=v1.0.0'
+//
+// Builds without ldflags report a "dev" version with an "unknown" commit and
+// date.
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+func init() {
+	// An empty value, e.g. from `git describe` outside a repository, still
+	// falls back to the defaults.
+	if Version == "" {
+		Version = "dev"
+	}
+	if Commit == "" {
+		Commit = "unknown"
+	}
+	if Date == "" {
+		Date = "unknown"
+	}
+}